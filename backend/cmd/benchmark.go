@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lifesupport/backend/pkg/workflows"
+
+	"github.com/rs/zerolog/log"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+// scheduleBenchmarkExport creates the schedule running BenchmarkExportWorkflow, or
+// updates an existing one to the configured cron expression and window
+func scheduleBenchmarkExport(ctx context.Context, c client.Client, taskQueue string, opts WorkerOptions) error {
+	spec := client.ScheduleSpec{CronExpressions: []string{opts.BenchmarkExportCron}}
+	action := &client.ScheduleWorkflowAction{
+		ID:        workflows.BenchmarkExportScheduleID,
+		Workflow:  "BenchmarkExportWorkflow",
+		Args:      []interface{}{workflows.BenchmarkExportParams{Window: opts.BenchmarkExportWindow}},
+		TaskQueue: taskQueue,
+	}
+
+	_, err := c.ScheduleClient().Create(ctx, client.ScheduleOptions{
+		ID:     workflows.BenchmarkExportScheduleID,
+		Spec:   spec,
+		Action: action,
+	})
+	if err == nil {
+		log.Info().Str("cron", opts.BenchmarkExportCron).Msg("Scheduled benchmark export")
+		return nil
+	}
+	if !errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		return fmt.Errorf("failed to create benchmark export schedule: %w", err)
+	}
+
+	handle := c.ScheduleClient().GetHandle(ctx, workflows.BenchmarkExportScheduleID)
+	err = handle.Update(ctx, client.ScheduleUpdateOptions{
+		DoUpdate: func(in client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+			in.Description.Schedule.Spec = &spec
+			in.Description.Schedule.Action = action
+			return &client.ScheduleUpdate{Schedule: &in.Description.Schedule}, nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update benchmark export schedule: %w", err)
+	}
+	log.Info().Str("cron", opts.BenchmarkExportCron).Msg("Updated benchmark export schedule")
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"time"
+
+	"lifesupport/backend/pkg/changefeed"
+	"lifesupport/backend/pkg/lease"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/transport"
+
+	"github.com/rs/zerolog/log"
+)
+
+// changeFeedPruneInterval is how often changes older than --change-feed-retention are
+// deleted
+const changeFeedPruneInterval = time.Hour
+
+// buildChangeFeedLoops creates a relay loop for each change feed destination in opts,
+// and a loop pruning old changes. Each runs under its own lock, so exactly one worker
+// relays to each destination, resuming from the destination's cursor after a failover.
+func buildChangeFeedLoops(opts WorkerOptions, locker *lease.Locker, store storer.Interface, t transport.Transport) ([]*lease.Elector, error) {
+	var loops []*lease.Elector
+	add := func(name string, publisher changefeed.Publisher) {
+		relay := changefeed.NewRelay(store, name, publisher, changefeed.WithLogger(log.Logger))
+		loops = append(loops, locker.Loop("changefeed/"+name, opts.ChangeFeedInterval, relay.Tick))
+		log.Info().Str("consumer", name).Msg("Change feed relay enabled")
+	}
+	if opts.ChangeFeedNATSURL != "" {
+		nats, err := changefeed.NewNATS(opts.ChangeFeedNATSURL, opts.ChangeFeedNATSSubject)
+		if err != nil {
+			return nil, err
+		}
+		add("nats", nats)
+	}
+	if opts.ChangeFeedKafkaURL != "" {
+		add("kafka", changefeed.NewKafka(opts.ChangeFeedKafkaURL, opts.ChangeFeedKafkaTopic))
+	}
+	if opts.ChangeFeedTransportSubject != "" {
+		add("transport", changefeed.NewTransport(t, opts.ChangeFeedTransportSubject))
+	}
+	if opts.ChangeFeedRetention > 0 {
+		loops = append(loops, locker.Loop("changefeed/prune", changeFeedPruneInterval, changefeed.Prune(store, opts.ChangeFeedRetention)))
+	}
+	return loops, nil
+}
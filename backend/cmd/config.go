@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const redacted = "REDACTED"
+
+// secretFlagWords mark flags whose values are left out of the admin config entirely
+var secretFlagWords = []string{"token", "password", "secret", "key", "dsn"}
+
+// dsnPassword matches the password of a key=value database connection string
+var dsnPassword = regexp.MustCompile(`password=\S+`)
+
+// adminConfig describes the effective flags of cmd, including inherited ones, for
+// /api/admin/config
+func adminConfig(cmd *cobra.Command) *api.AdminConfig {
+	cfg := &api.AdminConfig{
+		Command: cmd.Name(),
+		Release: buildRelease(),
+		Flags:   map[string]string{},
+	}
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		cfg.Flags[f.Name] = redactFlag(f.Name, f.Value.String())
+	})
+	return cfg
+}
+
+// redactFlag hides the value of a secret flag, and the credentials in any connection
+// string, such as a database password or a NATS token
+func redactFlag(name, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, word := range secretFlagWords {
+		if strings.Contains(name, word) {
+			return redacted
+		}
+	}
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		u.User = url.User(redacted)
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(value, "password="+redacted)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"lifesupport/backend/pkg/diagnostics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StartDebugServer serves pprof profiles and runtime stats on addr. Without a token file
+// the endpoints are only served to localhost, so addr must be a loopback address.
+func StartDebugServer(addr, tokenFile string, opts ...diagnostics.Option) (*http.Server, error) {
+	if tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read debug token: %w", err)
+		}
+		if strings.TrimSpace(string(token)) == "" {
+			return nil, fmt.Errorf("debug token file %s is empty", tokenFile)
+		}
+		opts = append(opts, diagnostics.WithToken(strings.TrimSpace(string(token))))
+	} else if !diagnostics.IsLoopback(addr) {
+		return nil, fmt.Errorf("debug address %s must be on localhost unless a debug token file is set", addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	server := &http.Server{Handler: diagnostics.NewHandler(opts...)}
+	go func() {
+		log.Info().Str("addr", addr).Msg("Debug server starting")
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Debug server error")
+		}
+	}()
+	return server, nil
+}
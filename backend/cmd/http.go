@@ -5,12 +5,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"lifesupport/backend/pkg/blob"
+	"lifesupport/backend/pkg/changefeed"
+	"lifesupport/backend/pkg/control"
+	"lifesupport/backend/pkg/diagnostics"
 	"lifesupport/backend/pkg/drivers"
 	"lifesupport/backend/pkg/drivers/shelly"
 	"lifesupport/backend/pkg/httpapi"
+	"lifesupport/backend/pkg/latency"
+	"lifesupport/backend/pkg/presence"
+	"lifesupport/backend/pkg/supervise"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -24,15 +32,43 @@ var httpCmd = &cobra.Command{
 }
 
 var (
-	httpOptions CommonOptions
-	httpPort    string
+	httpOptions       CommonOptions
+	httpPort          string
+	readCacheTTL      time.Duration
+	httpReactions     string
+	httpMQTTOptions   MQTTOptions
+	httpPresence      string
+	httpAdminToken    string
+	statusPageOptions StatusPageOptions
 )
 
+// StatusPageOptions holds public status page configuration
+type StatusPageOptions struct {
+	Title    string
+	Tags     []string
+	CacheTTL time.Duration
+	Port     string
+}
+
 func init() {
 	// HTTP-specific flags
 	httpCmd.Flags().StringVarP(&httpPort, "port", "p", "8080", "Port to run the HTTP server on")
+	httpCmd.Flags().DurationVar(&readCacheTTL, "read-cache-ttl", 2*time.Second, "How long responses from read-heavy endpoints are shared between clients (0 disables)")
+	httpCmd.Flags().StringVar(&httpAdminToken, "admin-token-file", "", "File holding the bearer token required by administrative endpoints such as maintenance mode, feature flags and /debug/ profiles; they are unavailable if empty")
+	httpCmd.Flags().StringVar(&httpReactions, "reactions-config", "", "Worker reactions config, used to report rules depending on resources before they are deleted and to serve its composite rules, actuator groups and scenes")
+
+	// Status page flags
+	httpCmd.Flags().StringVar(&statusPageOptions.Title, "status-page-title", "Life Support Status", "Title shown on the public status page")
+	httpCmd.Flags().StringSliceVar(&statusPageOptions.Tags, "status-page-tag", nil, "Sensor or actuator tag to show on the public status page, optionally as label=tag (repeatable)")
+	httpCmd.Flags().DurationVar(&statusPageOptions.CacheTTL, "status-page-cache-ttl", time.Minute, "How long the public status page is cached")
+	httpCmd.Flags().StringVar(&statusPageOptions.Port, "status-page-port", "", "Also serve only the public status page on this port")
 
 	// Add common database and temporal flags
+	// Actuator commands from the frontend session need a broker; they are disabled if
+	// none is given
+	AddMQTTFlags(httpCmd, &httpMQTTOptions, "", "lifesupport-http")
+	httpCmd.Flags().StringVar(&httpPresence, "presence-prefix", presence.DefaultPrefix, "MQTT topic prefix of worker presence summaries, reported by /api/workers")
+
 	AddCommonFlags(httpCmd, &httpOptions)
 	rootCmd.AddCommand(httpCmd)
 }
@@ -43,8 +79,18 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 	// Initialize options
 	InitCommonOptions(&httpOptions)
 
+	reporter, err := InitErrorReporter(httpOptions.Sentry)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure error reporting")
+	}
+	defer flushErrorReporter(reporter)
+	if reporter != nil {
+		supervise.SetReporter(reporter)
+	}
+	defer reporter.Recover(map[string]string{"command": "http"})
+
 	// Initialize database
-	store, err := InitDatabase(ctx, httpOptions.DB)
+	store, err := InitDatabase(ctx, httpOptions.DB, httpOptions.storerOptions()...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to database")
 	}
@@ -69,12 +115,114 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 		defer temporalClient.Close()
 	}
 
+	vault, err := httpOptions.credentialVault(store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load device credentials")
+	}
+	shellyOpts := []shelly.Option{shelly.WithIDNamespace(httpOptions.ShellyIDNamespace)}
+	if vault != nil {
+		shellyOpts = append(shellyOpts, shelly.WithCredentials(vault))
+	}
+
 	driversManager := drivers.NewManager()
-	driversManager.Register("shelly", shelly.New(nil, clickhouseConn))
+	var shellyDriver *shelly.Driver
+	var tracker *presence.Tracker
+	if httpMQTTOptions.Broker != "" {
+		mqttClient, err := ConnectMQTT(httpMQTTOptions)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to connect to MQTT broker")
+		}
+		defer mqttClient.Disconnect(250)
+		// A client name of its own keeps RPC replies apart from the worker's
+		shellyDriver = shelly.New(mqttClient, clickhouseConn,
+			append(shellyOpts, shelly.WithClientName(httpMQTTOptions.ClientID))...)
+		if err := shellyDriver.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Failed to start Shelly driver")
+		}
+		driversManager.Register("shelly", shellyDriver)
+		if httpPresence != "" {
+			tracker = presence.NewTracker(mqttClient, httpPresence, presence.WithTrackerLogger(log.Logger))
+			if err := tracker.Start(ctx); err != nil {
+				log.Fatal().Err(err).Msg("Failed to track worker presence")
+			}
+		}
+	} else {
+		log.Warn().Msg("No MQTT broker configured - actuator commands and worker presence will not be available")
+		driversManager.Register("shelly", shelly.New(nil, clickhouseConn, shellyOpts...))
+	}
 
 	// Create API handler and setup router
 	handler := httpapi.NewHandler(store, temporalClient, driversManager)
+	latencies := latency.NewRecorder()
+	if shellyDriver != nil {
+		dispatcherOpts, err := httpOptions.dispatcherOptions()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load power budgets")
+		}
+		dispatcher := drivers.NewDispatcher(store, driversManager,
+			append(dispatcherOpts, drivers.WithCommandLatency(latencies.Histogram(latency.CommandConfirmed)))...)
+		handler.Commander = dispatcher
+		handler.Readings = dispatcher
+	}
+	if tracker != nil {
+		handler.Workers = tracker
+	}
+	bucket, err := OpenBlobStore(httpOptions)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to open blob storage")
+	}
+	if bucket != nil {
+		handler.Assets = blob.WithPrefix(bucket, "assets/")
+	} else {
+		log.Warn().Msg("No blob storage configured - device photo and diagram uploads will not be available")
+	}
+	if vault != nil {
+		handler.Credentials = vault
+	}
+	if httpAdminToken != "" {
+		token, err := os.ReadFile(httpAdminToken)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to read admin token")
+		}
+		handler.AdminToken = strings.TrimSpace(string(token))
+		if handler.AdminToken == "" {
+			log.Fatal().Str("file", httpAdminToken).Msg("Admin token file is empty")
+		}
+	}
+	notifyConfig, err := loadNotifyConfig(httpOptions.NotifyConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load notify config")
+	}
+	handler.SlackInteractions = notifyConfig.slackInteractions(changefeed.NewAlertRecorder(store).Ack)
+	handler.Config = adminConfig(cmd)
+	handler.StatusPage = buildStatusPageConfig(statusPageOptions)
+	handler.ReadCacheTTL = readCacheTTL
+	if reporter != nil {
+		handler.Errors = reporter
+	}
+	if httpReactions != "" {
+		cfg, err := loadReactionsConfig(httpReactions)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load reactions config")
+		}
+		handler.Rules = cfg.ruleRefs()
+		handler.CompositeRules = cfg.CompositeRules
+		handler.ActuatorGroups = cfg.ActuatorGroups
+		handler.Scenes = cfg.Scenes
+		if handler.Readings != nil {
+			handler.Readings = control.NewMeasurements(cfg.LogicalMeasurements, handler.Readings, nil)
+		}
+	}
 	router := handler.SetupRouter()
+	if handler.AdminToken != "" {
+		// Profiles and runtime stats share the admin token rather than a listener of
+		// their own
+		debugOpts := []diagnostics.Option{diagnostics.WithToken(handler.AdminToken), diagnostics.WithLatency(latencies)}
+		if shellyDriver != nil {
+			debugOpts = append(debugOpts, diagnostics.WithGauge("shelly.pending_rpcs", shellyDriver.PendingRequests))
+		}
+		router.PathPrefix("/debug/").Handler(diagnostics.NewHandler(debugOpts...))
+	}
 
 	server := &http.Server{
 		Addr:    ":" + httpPort,
@@ -89,6 +237,20 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	var statusPageServer *http.Server
+	if statusPageOptions.Port != "" {
+		statusPageServer = &http.Server{
+			Addr:    ":" + statusPageOptions.Port,
+			Handler: handler.SetupStatusPageRouter(),
+		}
+		go func() {
+			log.Info().Str("port", statusPageOptions.Port).Msg("Public status page server starting")
+			if err := statusPageServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Status page server error")
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -98,9 +260,44 @@ func runHTTPServer(cmd *cobra.Command, args []string) {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if statusPageServer != nil {
+		if err := statusPageServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Status page server forced to shutdown")
+		}
+	}
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatal().Err(err).Msg("HTTP server forced to shutdown")
 	}
+	if tracker != nil {
+		if err := tracker.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error stopping worker presence tracking")
+		}
+	}
+	if shellyDriver != nil {
+		if err := shellyDriver.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error stopping Shelly driver")
+		}
+	}
 
 	log.Info().Msg("HTTP server stopped")
 }
+
+// buildStatusPageConfig converts status page flags into handler configuration; tags may be
+// given as "label=tag" or as a bare tag which is also used as the label
+func buildStatusPageConfig(opts StatusPageOptions) *httpapi.StatusPageConfig {
+	if len(opts.Tags) == 0 {
+		return nil
+	}
+	cfg := &httpapi.StatusPageConfig{
+		Title:    opts.Title,
+		CacheTTL: opts.CacheTTL,
+	}
+	for _, t := range opts.Tags {
+		label, tag, ok := strings.Cut(t, "=")
+		if !ok {
+			tag = label
+		}
+		cfg.Entries = append(cfg.Entries, httpapi.StatusPageEntry{Label: label, Tag: tag})
+	}
+	return cfg
+}
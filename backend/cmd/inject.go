@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/inject"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var injectCmd = &cobra.Command{
+	Use:   "inject",
+	Short: "Inject a synthetic sensor reading",
+	Long: `Post a synthetic reading for a sensor through the HTTP ingestion API of a running
+server. The reading is flagged as synthetic so it can be told apart from real
+measurements, and lets alert rules and automations be exercised during commissioning.`,
+	Example: `  lifesupport-backend inject --sensor sump-ph --value 6.2 --unit pH`,
+	Run:     runInject,
+}
+
+var (
+	injectURL       string
+	injectSensor    string
+	injectValue     float64
+	injectUnit      string
+	injectTimestamp string
+)
+
+func init() {
+	injectCmd.Flags().StringVar(&injectURL, "url", "http://localhost:8080", "Base URL of the HTTP API server")
+	injectCmd.Flags().StringVar(&injectSensor, "sensor", "", "Tag of the sensor the reading is attributed to")
+	injectCmd.Flags().Float64Var(&injectValue, "value", 0, "Reading value")
+	injectCmd.Flags().StringVar(&injectUnit, "unit", "", "Reading unit")
+	injectCmd.Flags().StringVar(&injectTimestamp, "timestamp", "", "Reading timestamp in RFC3339 (defaults to now)")
+	injectCmd.MarkFlagRequired("sensor")
+	injectCmd.MarkFlagRequired("value")
+	rootCmd.AddCommand(injectCmd)
+}
+
+func runInject(cmd *cobra.Command, args []string) {
+	r := inject.Reading{
+		SensorTag: injectSensor,
+		Value:     injectValue,
+		Unit:      api.Unit(injectUnit),
+	}
+	if injectTimestamp != "" {
+		ts, err := time.Parse(time.RFC3339, injectTimestamp)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid timestamp")
+		}
+		r.Timestamp = ts
+	}
+
+	rec, err := inject.New(injectURL, nil).Inject(cmd.Context(), r)
+	if err != nil {
+		log.Fatal().Err(err).Str("sensor", injectSensor).Msg("Failed to inject reading")
+	}
+	log.Info().
+		Str("device_id", rec.DeviceID).
+		Str("sensor_id", rec.SensorID).
+		Float64("value", rec.Reading.Value).
+		Msg("Injected synthetic reading")
+	json.NewEncoder(os.Stdout).Encode(rec)
+}
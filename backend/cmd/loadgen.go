@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"lifesupport/backend/pkg/loadgen"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var loadgenCmd = &cobra.Command{
+	Use:   "loadgen",
+	Short: "Simulate devices and dashboards against the HTTP API",
+	Long: `Simulate N devices publishing sensor readings and dashboards querying them against a
+running HTTP API server, then report throughput and latency percentiles.`,
+	Run: runLoadgen,
+}
+
+var loadgenConfig loadgen.Config
+
+func init() {
+	loadgenCmd.Flags().StringVar(&loadgenConfig.BaseURL, "url", "http://localhost:8080", "Base URL of the HTTP API server")
+	loadgenCmd.Flags().IntVar(&loadgenConfig.Devices, "devices", 10, "Number of simulated devices")
+	loadgenCmd.Flags().IntVar(&loadgenConfig.SensorsPerDevice, "sensors-per-device", 5, "Number of sensors on each simulated device")
+	loadgenCmd.Flags().DurationVar(&loadgenConfig.Interval, "interval", 5*time.Second, "How often each device publishes its readings")
+	loadgenCmd.Flags().DurationVar(&loadgenConfig.Duration, "duration", time.Minute, "How long to generate load")
+	loadgenCmd.Flags().BoolVar(&loadgenConfig.Compact, "compact", false, "Publish readings in the compact CBOR form instead of JSON")
+	loadgenCmd.Flags().IntVar(&loadgenConfig.Readers, "readers", 2, "Number of simulated dashboards querying readings")
+	loadgenCmd.Flags().DurationVar(&loadgenConfig.ReadInterval, "read-interval", time.Second, "How often each dashboard queries readings")
+	loadgenCmd.Flags().StringVar(&loadgenConfig.DevicePrefix, "device-prefix", "loadgen", "ID prefix for simulated devices")
+	loadgenCmd.Flags().BoolVar(&loadgenConfig.Cleanup, "cleanup", true, "Delete simulated devices and their readings afterwards")
+	rootCmd.AddCommand(loadgenCmd)
+}
+
+func runLoadgen(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Info().
+		Str("url", loadgenConfig.BaseURL).
+		Int("devices", loadgenConfig.Devices).
+		Int("sensors_per_device", loadgenConfig.SensorsPerDevice).
+		Dur("duration", loadgenConfig.Duration).
+		Msg("Starting load generation")
+
+	report, err := loadgen.New(loadgenConfig, nil).Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Load generation failed")
+	}
+	os.Stdout.WriteString(report.String())
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/spf13/cobra"
+)
+
+type MQTTOptions struct {
+	Broker                string
+	ClientID              string
+	Username              string
+	Password              string
+	KeepAlive             time.Duration
+	CleanSession          bool
+	AutoReconnect         bool
+	ConnectTimeout        time.Duration
+	TLSCACert             string
+	TLSClientCert         string
+	TLSClientKey          string
+	TLSInsecureSkipVerify bool
+}
+
+// AddMQTTFlags adds the MQTT connection flags to a command
+func AddMQTTFlags(cmd *cobra.Command, opts *MQTTOptions, defaultBroker, defaultClientID string) {
+	cmd.Flags().StringVar(&opts.Broker, "mqtt-broker", defaultBroker, "MQTT broker URL")
+	cmd.Flags().StringVar(&opts.ClientID, "mqtt-client-id", defaultClientID, "MQTT client ID")
+	cmd.Flags().StringVar(&opts.Username, "mqtt-username", "", "MQTT username")
+	cmd.Flags().StringVar(&opts.Password, "mqtt-password", "", "MQTT password")
+	cmd.Flags().DurationVar(&opts.KeepAlive, "mqtt-keepalive", 60*time.Second, "MQTT keep alive interval")
+	cmd.Flags().BoolVar(&opts.CleanSession, "mqtt-clean-session", true, "MQTT clean session")
+	cmd.Flags().BoolVar(&opts.AutoReconnect, "mqtt-auto-reconnect", true, "MQTT auto reconnect")
+	cmd.Flags().DurationVar(&opts.ConnectTimeout, "mqtt-connect-timeout", 30*time.Second, "MQTT connection timeout")
+	cmd.Flags().StringVar(&opts.TLSCACert, "mqtt-tls-ca-cert", "", "MQTT TLS CA certificate file path")
+	cmd.Flags().StringVar(&opts.TLSClientCert, "mqtt-tls-client-cert", "", "MQTT TLS client certificate file path")
+	cmd.Flags().StringVar(&opts.TLSClientKey, "mqtt-tls-client-key", "", "MQTT TLS client key file path")
+	cmd.Flags().BoolVar(&opts.TLSInsecureSkipVerify, "mqtt-tls-insecure-skip-verify", false, "MQTT TLS skip certificate verification")
+}
+
+// ConnectMQTT connects to the broker described by opts. configure may adjust the client
+// options before connecting, e.g. to set a last will.
+func ConnectMQTT(opts MQTTOptions, configure ...func(*mqtt.ClientOptions) error) (mqtt.Client, error) {
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetKeepAlive(opts.KeepAlive).
+		SetCleanSession(opts.CleanSession).
+		SetAutoReconnect(opts.AutoReconnect).
+		SetConnectTimeout(opts.ConnectTimeout)
+
+	if opts.Username != "" {
+		clientOptions.SetUsername(opts.Username)
+	}
+	if opts.Password != "" {
+		clientOptions.SetPassword(opts.Password)
+	}
+
+	// Configure TLS if certificates are provided
+	if opts.TLSCACert != "" || opts.TLSClientCert != "" || opts.TLSInsecureSkipVerify {
+		tlsConfig, err := createTLSConfig(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS config for MQTT: %w", err)
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	for _, fn := range configure {
+		if err := fn(clientOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	client := mqtt.NewClient(clientOptions)
+	token := client.Connect()
+	token.WaitTimeout(opts.ConnectTimeout)
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func createTLSConfig(opts MQTTOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.TLSInsecureSkipVerify,
+	}
+
+	// Load CA certificate if provided
+	if opts.TLSCACert != "" {
+		caCert, err := os.ReadFile(opts.TLSCACert)
+		if err != nil {
+			return nil, err
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, err
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	// Load client certificate and key if provided
+	if opts.TLSClientCert != "" && opts.TLSClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.TLSClientCert, opts.TLSClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NotifyConfig is the file format for --notify-config: the channels alerts are
+// delivered to besides the change feed
+type NotifyConfig struct {
+	Slack    *SlackConfig    `json:"slack"`
+	Discord  *DiscordConfig  `json:"discord"`
+	Telegram *TelegramConfig `json:"telegram"`
+	Ntfy     *NtfyConfig     `json:"ntfy"`
+	Matrix   *MatrixConfig   `json:"matrix"`
+}
+
+// ChannelRoute is which alerts a channel receives: those of MinSeverity or higher, or
+// every alert if it is empty
+type ChannelRoute struct {
+	MinSeverity api.AlertSeverity `json:"min_severity"`
+}
+
+// SlackConfig delivers alerts to a Slack incoming webhook. With a signing secret each
+// alert carries an Acknowledge button, whose clicks the HTTP server takes at
+// /api/slack/interactions.
+type SlackConfig struct {
+	ChannelRoute
+	WebhookURL    string `json:"webhook_url"`
+	SigningSecret string `json:"signing_secret"`
+}
+
+// DiscordConfig delivers alerts to a Discord channel webhook
+type DiscordConfig struct {
+	ChannelRoute
+	WebhookURL string `json:"webhook_url"`
+	Username   string `json:"username"`
+}
+
+// NtfyConfig publishes alerts to an ntfy topic, on ntfy.sh unless Server is set
+type NtfyConfig struct {
+	ChannelRoute
+	Topic  string `json:"topic"`
+	Server string `json:"server"`
+	Token  string `json:"token"`
+}
+
+// MatrixConfig posts alerts to a Matrix room the access token's user has joined
+type MatrixConfig struct {
+	ChannelRoute
+	Homeserver  string `json:"homeserver"`
+	RoomID      string `json:"room_id"`
+	AccessToken string `json:"access_token"`
+}
+
+// TelegramConfig delivers alerts to a Telegram chat and answers bot commands in that chat
+// and Chats, ignoring every other chat. Users grants each user, by ID, a role: viewers
+// may run /status, and operators may also run /ack and /lights. Without users, anyone in
+// those chats may run every command.
+type TelegramConfig struct {
+	ChannelRoute
+	Token  string             `json:"token"`
+	ChatID int64              `json:"chat_id"`
+	Chats  []int64            `json:"chats"`
+	Users  map[int64]api.Role `json:"users"`
+	// Lights are the actuator tags /lights on and /lights off switch
+	Lights []string `json:"lights"`
+}
+
+// telegramCommandRoles is the role each Telegram command requires
+var telegramCommandRoles = map[string]api.Role{
+	"status": api.RoleViewer,
+	"ack":    api.RoleOperator,
+	"lights": api.RoleOperator,
+}
+
+// loadNotifyConfig reads --notify-config; an empty path configures no channels
+func loadNotifyConfig(path string) (*NotifyConfig, error) {
+	if path == "" {
+		return &NotifyConfig{}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify config: %w", err)
+	}
+	var cfg NotifyConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse notify config: %w", err)
+	}
+	if cfg.Slack != nil && cfg.Slack.WebhookURL == "" {
+		return nil, errors.New("slack notifications need a webhook_url")
+	}
+	if cfg.Discord != nil && cfg.Discord.WebhookURL == "" {
+		return nil, errors.New("discord notifications need a webhook_url")
+	}
+	if cfg.Ntfy != nil && cfg.Ntfy.Topic == "" {
+		return nil, errors.New("ntfy notifications need a topic")
+	}
+	if cfg.Matrix != nil && (cfg.Matrix.Homeserver == "" || cfg.Matrix.RoomID == "" || cfg.Matrix.AccessToken == "") {
+		return nil, errors.New("matrix notifications need a homeserver, room_id and access_token")
+	}
+	if cfg.Telegram != nil {
+		if cfg.Telegram.Token == "" || cfg.Telegram.ChatID == 0 {
+			return nil, errors.New("telegram notifications need a token and chat_id")
+		}
+		for user, role := range cfg.Telegram.Users {
+			if !role.Valid() {
+				return nil, fmt.Errorf("telegram user %d has unknown role %q", user, role)
+			}
+		}
+	}
+	for name, route := range cfg.routes() {
+		switch route.MinSeverity {
+		case "", api.AlertSeverityInfo, api.AlertSeverityWarning, api.AlertSeverityCritical:
+		default:
+			return nil, fmt.Errorf("%s notifications have unknown min_severity %q", name, route.MinSeverity)
+		}
+	}
+	return &cfg, nil
+}
+
+// slack creates the configured Slack notifier, or returns nil
+func (cfg *NotifyConfig) slack() *notify.Slack {
+	if cfg.Slack == nil {
+		return nil
+	}
+	opts := []notify.SlackOption{notify.WithSlackLogger(log.Logger)}
+	if cfg.Slack.SigningSecret != "" {
+		opts = append(opts, notify.WithSlackAckButton())
+	}
+	return notify.NewSlack(cfg.Slack.WebhookURL, opts...)
+}
+
+// telegram creates the configured Telegram bot, or returns nil. Its commands
+// acknowledge alerts with ack, report status's health score, and switch the lights with
+// commander.
+func (cfg *NotifyConfig) telegram(ack notify.AckFunc, status func(ctx context.Context) (*api.HealthScore, error), commander notify.Commander) *notify.Telegram {
+	if cfg.Telegram == nil {
+		return nil
+	}
+	opts := []notify.TelegramOption{notify.WithTelegramLogger(log.Logger), notify.WithTelegramChats(cfg.Telegram.Chats...)}
+	if len(cfg.Telegram.Users) > 0 {
+		opts = append(opts, notify.WithTelegramAuthorizer(notify.RoleAuthorizer(cfg.Telegram.Users, telegramCommandRoles)))
+	}
+	tg := notify.NewTelegram(cfg.Telegram.Token, cfg.Telegram.ChatID, opts...)
+	tg.Handle("/status", notify.StatusCommand(status))
+	tg.Handle("/ack", notify.AckCommand(ack))
+	if len(cfg.Telegram.Lights) > 0 {
+		tg.Handle("/lights", notify.SwitchCommand("lights", commander, cfg.Telegram.Lights))
+	}
+	return tg
+}
+
+// routes returns the route of each configured channel, by name
+func (cfg *NotifyConfig) routes() map[string]ChannelRoute {
+	routes := map[string]ChannelRoute{}
+	if cfg.Slack != nil {
+		routes["slack"] = cfg.Slack.ChannelRoute
+	}
+	if cfg.Discord != nil {
+		routes["discord"] = cfg.Discord.ChannelRoute
+	}
+	if cfg.Telegram != nil {
+		routes["telegram"] = cfg.Telegram.ChannelRoute
+	}
+	if cfg.Ntfy != nil {
+		routes["ntfy"] = cfg.Ntfy.ChannelRoute
+	}
+	if cfg.Matrix != nil {
+		routes["matrix"] = cfg.Matrix.ChannelRoute
+	}
+	return routes
+}
+
+// router creates a router delivering each alert to the configured channels whose
+// minimum severity it meets, sending Telegram alerts through telegram
+func (cfg *NotifyConfig) router(telegram *notify.Telegram) *notify.SeverityRouter {
+	router := notify.NewSeverityRouter()
+	route := func(r ChannelRoute, n notify.Notifier) {
+		severity := r.MinSeverity
+		if severity == "" {
+			severity = api.AlertSeverityInfo
+		}
+		router.Route(severity, n)
+	}
+	if slack := cfg.slack(); slack != nil {
+		route(cfg.Slack.ChannelRoute, slack)
+	}
+	if cfg.Discord != nil {
+		route(cfg.Discord.ChannelRoute, notify.NewDiscord(cfg.Discord.WebhookURL,
+			notify.WithDiscordUsername(cfg.Discord.Username), notify.WithDiscordLogger(log.Logger)))
+	}
+	if telegram != nil {
+		route(cfg.Telegram.ChannelRoute, telegram)
+	}
+	if cfg.Ntfy != nil {
+		opts := []notify.NtfyOption{notify.WithNtfyLogger(log.Logger)}
+		if cfg.Ntfy.Server != "" {
+			opts = append(opts, notify.WithNtfyServer(cfg.Ntfy.Server))
+		}
+		if cfg.Ntfy.Token != "" {
+			opts = append(opts, notify.WithNtfyToken(cfg.Ntfy.Token))
+		}
+		route(cfg.Ntfy.ChannelRoute, notify.NewNtfy(cfg.Ntfy.Topic, opts...))
+	}
+	if cfg.Matrix != nil {
+		route(cfg.Matrix.ChannelRoute, notify.NewMatrix(cfg.Matrix.Homeserver, cfg.Matrix.RoomID, cfg.Matrix.AccessToken,
+			notify.WithMatrixLogger(log.Logger)))
+	}
+	return router
+}
+
+// slackInteractions returns the handler for Slack's acknowledge button clicks, passing
+// them to ack, or nil when Slack has no signing secret to check them with
+func (cfg *NotifyConfig) slackInteractions(ack notify.AckFunc) http.Handler {
+	if cfg.Slack == nil || cfg.Slack.SigningSecret == "" {
+		return nil
+	}
+	return cfg.slack().InteractionHandler(cfg.Slack.SigningSecret, ack)
+}
+
+// telegramBot is the role of the worker answering Telegram commands: the Bot API hands
+// each update to one poller, so only the lease holder polls
+type telegramBot struct {
+	bot *notify.Telegram
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (b *telegramBot) Start(ctx context.Context) error {
+	// The bot outlives the campaign step which starts it
+	ctx, b.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	b.done = make(chan struct{})
+	go func() {
+		defer close(b.done)
+		b.bot.Run(ctx)
+	}()
+	return nil
+}
+
+func (b *telegramBot) Stop(ctx context.Context) error {
+	if b.cancel == nil {
+		return nil
+	}
+	b.cancel()
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/control"
+	"lifesupport/backend/pkg/lease"
+	"lifesupport/backend/pkg/notify"
+)
+
+// ReactionsConfig is the file format for --reactions-config
+type ReactionsConfig struct {
+	// LogicalMeasurements may be named by the rules below in place of a sensor tag
+	LogicalMeasurements []api.LogicalMeasurement `json:"logical_measurements"`
+	LeakResponses       []api.LeakResponse       `json:"leak_responses"`
+	EventReactions      []api.EventReaction      `json:"event_reactions"`
+	PumpRotations       []api.PumpRotation       `json:"pump_rotations"`
+	DryRunRules         []api.DryRunRule         `json:"dry_run_rules"`
+	SPCRules            []api.SPCRule            `json:"spc_rules"`
+	RateRules           []api.RateOfChangeRule   `json:"rate_of_change_rules"`
+	CompositeRules      []api.CompositeRule      `json:"composite_rules"`
+	// ActuatorGroups are not run by the worker; the HTTP server starts and stops them
+	ActuatorGroups []api.ActuatorGroup `json:"actuator_groups"`
+	// Scenes are activated through the HTTP server, and by the worker at their times of
+	// day
+	Scenes []api.Scene `json:"scenes"`
+}
+
+func loadReactionsConfig(path string) (*ReactionsConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reactions config: %w", err)
+	}
+	var cfg ReactionsConfig
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse reactions config: %w", err)
+	}
+	for _, m := range cfg.LogicalMeasurements {
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, scene := range cfg.Scenes {
+		if err := scene.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// ruleRefs lists the rules in cfg with the tags they depend on
+func (cfg *ReactionsConfig) ruleRefs() []api.RuleRef {
+	var refs []api.RuleRef
+	for _, m := range cfg.LogicalMeasurements {
+		refs = append(refs, m.Ref())
+	}
+	for _, leak := range cfg.LeakResponses {
+		refs = append(refs, leak.Ref())
+	}
+	for _, r := range cfg.EventReactions {
+		refs = append(refs, r.Ref())
+	}
+	for _, r := range cfg.PumpRotations {
+		refs = append(refs, r.Ref())
+	}
+	for _, r := range cfg.DryRunRules {
+		refs = append(refs, r.Ref())
+	}
+	for _, r := range cfg.SPCRules {
+		refs = append(refs, r.Ref())
+	}
+	for _, r := range cfg.RateRules {
+		refs = append(refs, r.Ref())
+	}
+	for _, r := range cfg.CompositeRules {
+		refs = append(refs, r.Ref())
+	}
+	for _, g := range cfg.ActuatorGroups {
+		refs = append(refs, g.Ref())
+	}
+	for _, scene := range cfg.Scenes {
+		refs = append(refs, scene.Ref())
+	}
+	return refs
+}
+
+// brokenRulesRefresh is how often the worker picks up rules disabled or re-enabled by
+// resources being deleted or recreated through the API
+const brokenRulesRefresh = 30 * time.Second
+
+// controlLoopInterval is how often leak sensors are polled, pump rotations, dry-run,
+// SPC, rate-of-change and composite rules evaluated, and scheduled scenes checked
+const controlLoopInterval = 10 * time.Second
+
+// buildReactions creates the fast-path reactions described by cfg, raising alerts through
+// notifier. Reactions are skipped while broken reports their rule as disabled, and may
+// name cfg's logical measurements in place of a sensor tag.
+func buildReactions(cfg *ReactionsConfig, commander control.Commander, readings control.ReadingSource, notifier notify.Notifier, broken *control.BrokenRules) []control.Reaction {
+	readings = control.NewMeasurements(cfg.LogicalMeasurements, readings, nil)
+	var reactions []control.Reaction
+	add := func(ref api.RuleRef, reaction control.Reaction) {
+		reactions = append(reactions, broken.Guard(ref, control.Attributed(ref, reaction)))
+	}
+	for _, leak := range cfg.LeakResponses {
+		add(leak.Ref(), control.NewLeakResponder(leak, commander, readings, notifier))
+	}
+	for _, r := range cfg.EventReactions {
+		add(r.Ref(), control.NewTriggerReaction(r, commander))
+	}
+	return reactions
+}
+
+// buildControlLoops creates a loop for each periodic rule in cfg, and one polling each
+// leak response's sensors in case the fast path misses an event. Each runs under its own
+// lock, so redundant workers never evaluate a rule at once and a standby resumes it when
+// its worker dies. Loops skip their turn while broken reports their rule as disabled.
+// SPC and rate-of-change rules judge the stored readings in history, and pump rotations
+// keep their pumps' runtimes in runtimes. A non-nil tracer records every evaluation. Rules
+// may name cfg's logical measurements in place of a sensor tag.
+func buildControlLoops(cfg *ReactionsConfig, locker *lease.Locker, commander control.Commander, readings control.ReadingSource, history control.ReadingHistory, runtimes control.RuntimeStore, notifier notify.Notifier, broken *control.BrokenRules, tracer *control.Tracer) []*lease.Elector {
+	measurements := control.NewMeasurements(cfg.LogicalMeasurements, readings, history)
+	readings, history = measurements, measurements
+	if tracer != nil {
+		commander, readings, notifier = control.TraceCommands(commander), control.TraceReadings(readings), control.TraceAlerts(notifier)
+	}
+	var loops []*lease.Elector
+	add := func(ref api.RuleRef, fn func(ctx context.Context, now time.Time) error) {
+		origin := api.CommandOrigin{Source: api.CommandSourceRule, Name: ref.Kind + "/" + ref.Name}
+		run := broken.GuardLoop(ref, func(ctx context.Context, now time.Time) error {
+			return fn(api.WithCommandOrigin(ctx, origin), now)
+		})
+		loops = append(loops, locker.Loop("loop/"+ref.Kind+"/"+ref.Name, controlLoopInterval, tracer.Loop(ref, run)))
+	}
+	for _, leak := range cfg.LeakResponses {
+		// A fallback for the fast path, should a leak sensor's event be missed
+		responder := control.NewLeakResponder(leak, commander, readings, notifier)
+		add(leak.Ref(), func(ctx context.Context, now time.Time) error {
+			return responder.Check(ctx)
+		})
+	}
+	for _, r := range cfg.PumpRotations {
+		add(r.Ref(), control.NewRotationController(r, commander, readings, runtimes, notifier).Tick)
+	}
+	for _, r := range cfg.DryRunRules {
+		add(r.Ref(), control.NewDryRunDetector(r, commander, readings, notifier).Check)
+	}
+	for _, r := range cfg.SPCRules {
+		add(r.Ref(), control.NewSPCDetector(r, history, notifier).Check)
+	}
+	for _, r := range cfg.RateRules {
+		add(r.Ref(), control.NewRateDetector(r, history, notifier).Check)
+	}
+	for _, r := range cfg.CompositeRules {
+		add(r.Ref(), control.NewCompositeDetector(r, commander, readings, notifier).Check)
+	}
+	for _, s := range cfg.Scenes {
+		if len(s.At) == 0 {
+			continue
+		}
+		scene := control.NewSceneController(s, commander, readings)
+		origin := api.CommandOrigin{Source: api.CommandSourceSchedule, Name: s.Name}
+		add(s.Ref(), func(ctx context.Context, now time.Time) error {
+			return scene.Tick(api.WithCommandOrigin(ctx, origin), now)
+		})
+	}
+	return loops
+}
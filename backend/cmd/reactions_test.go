@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/control"
+	"lifesupport/backend/pkg/lease"
+	"lifesupport/backend/pkg/storer"
+)
+
+func TestBuildControlLoops_LeakResponses(t *testing.T) {
+	store := storer.NewMemory()
+	cfg := &ReactionsConfig{
+		LeakResponses: []api.LeakResponse{{Subsystem: "sump", LeakTags: []string{"leak.sump"}, PumpTags: []string{"pump.return"}}},
+	}
+	loops := buildControlLoops(cfg, lease.NewLocker(store, "worker-1"), nil, nil, store, store, nil, control.NewBrokenRules(store, time.Minute), nil)
+
+	var names []string
+	for _, loop := range loops {
+		names = append(names, loop.Name())
+	}
+	if !slices.Contains(names, "loop/leak_response/sump") {
+		t.Errorf("Expected a loop polling the sump's leak sensors, got %v", names)
+	}
+}
+
+// writeReactionsConfig writes config to a file for loadReactionsConfig
+func writeReactionsConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reactions.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadReactionsConfig_LogicalMeasurement(t *testing.T) {
+	path := writeReactionsConfig(t, `{"logical_measurements": [{"name": "ph", "sensor_tags": ["ph.a", "ph.b", "ph.c"], "max_deviation": 0.1}]}`)
+	cfg, err := loadReactionsConfig(path)
+	if err != nil {
+		t.Fatalf("loadReactionsConfig() error = %v", err)
+	}
+	if len(cfg.LogicalMeasurements) != 1 {
+		t.Errorf("Expected 1 logical measurement, got %d", len(cfg.LogicalMeasurements))
+	}
+}
+
+func TestLoadReactionsConfig_LogicalMeasurementMaxDeviation(t *testing.T) {
+	for _, deviation := range []string{"0", "-0.1"} {
+		path := writeReactionsConfig(t, `{"logical_measurements": [{"name": "ph", "sensor_tags": ["ph.a", "ph.b"], "max_deviation": `+deviation+`}]}`)
+		if _, err := loadReactionsConfig(path); err == nil || !strings.Contains(err.Error(), "max_deviation") {
+			t.Errorf("max_deviation %s: expected a max_deviation error, got %v", deviation, err)
+		}
+	}
+}
+
+func TestLoadReactionsConfig_LogicalMeasurementQuorum(t *testing.T) {
+	for _, quorum := range []string{"-1", "4"} {
+		path := writeReactionsConfig(t, `{"logical_measurements": [{"name": "ph", "sensor_tags": ["ph.a", "ph.b", "ph.c"], "max_deviation": 0.1, "quorum": `+quorum+`}]}`)
+		if _, err := loadReactionsConfig(path); err == nil || !strings.Contains(err.Error(), "quorum") {
+			t.Errorf("quorum %s: expected a quorum error, got %v", quorum, err)
+		}
+	}
+	// Without probes even the default quorum can't be met
+	path := writeReactionsConfig(t, `{"logical_measurements": [{"name": "ph", "max_deviation": 0.1}]}`)
+	if _, err := loadReactionsConfig(path); err == nil || !strings.Contains(err.Error(), "quorum") {
+		t.Errorf("Expected a quorum error for a measurement without probes, got %v", err)
+	}
+}
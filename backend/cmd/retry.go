@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// loadRetryPolicies reads --activity-retry-config: a JSON object mapping activity names,
+// or "default", to retry policies
+func loadRetryPolicies(path string) (map[string]api.RetryPolicy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity retry config: %w", err)
+	}
+	var policies map[string]api.RetryPolicy
+	if err := json.Unmarshal(b, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse activity retry config: %w", err)
+	}
+	return policies, nil
+}
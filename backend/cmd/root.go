@@ -3,8 +3,12 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/logging"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -14,6 +18,21 @@ import (
 var (
 	logFormat string
 	logLevel  string
+	logLevels string
+	logOutput string
+
+	// logFileOptions configures the "file" log output
+	logFileOptions struct {
+		Path       string
+		MaxSizeMB  int64
+		MaxAge     time.Duration
+		MaxBackups int
+	}
+	logSyslogAddr string
+	// logTag identifies this process to syslog and journald
+	logTag string
+
+	failOnSchemaDrift bool
 )
 
 var rootCmd = &cobra.Command{
@@ -38,41 +57,91 @@ func init() {
 	// Global flags for logging configuration
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "pretty", "Log output format (json or pretty)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logLevels, "log-levels", "", "Per-component log levels overriding --log-level, e.g. storer=debug,shelly=info,httpapi=warn")
+	rootCmd.PersistentFlags().StringVar(&logOutput, "log-output", "stderr", "Comma separated log outputs: stderr, file, syslog or journald")
+	rootCmd.PersistentFlags().StringVar(&logFileOptions.Path, "log-file", "/var/log/lifesupport/lifesupport.log", "Log file for the file output")
+	rootCmd.PersistentFlags().Int64Var(&logFileOptions.MaxSizeMB, "log-file-max-size", 10, "Size in MB at which the log file is rotated (0 never rotates)")
+	rootCmd.PersistentFlags().DurationVar(&logFileOptions.MaxAge, "log-file-max-age", 7*24*time.Hour, "Remove rotated log files older than this (0 keeps them)")
+	rootCmd.PersistentFlags().IntVar(&logFileOptions.MaxBackups, "log-file-max-backups", 5, "Number of rotated log files to keep (0 keeps all)")
+	rootCmd.PersistentFlags().StringVar(&logSyslogAddr, "syslog-addr", "", "Remote syslog server for the syslog output, e.g. udp://loghost:514 (default local syslog)")
+	rootCmd.PersistentFlags().StringVar(&logTag, "log-tag", "lifesupport", "Identifier for syslog and journald entries")
+	rootCmd.PersistentFlags().BoolVar(&failOnSchemaDrift, "fail-on-schema-drift", false, "Refuse to start when the database schema differs from the expected schema")
+}
+
+// openLogOutput opens one --log-output. Syslog and journald always receive JSON, which
+// carries each event's level through to them.
+func openLogOutput(output, format string) (io.Writer, error) {
+	switch output {
+	case "stderr":
+		if format == "json" {
+			return os.Stderr, nil
+		}
+		return zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "15:04:05"}, nil
+	case "file":
+		f, err := logging.OpenRotatingFile(logFileOptions.Path,
+			logging.WithMaxSize(logFileOptions.MaxSizeMB<<20),
+			logging.WithMaxAge(logFileOptions.MaxAge),
+			logging.WithMaxBackups(logFileOptions.MaxBackups),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if format == "json" {
+			return f, nil
+		}
+		return zerolog.ConsoleWriter{Out: f, NoColor: true, TimeFormat: time.RFC3339}, nil
+	case "syslog":
+		return logging.NewSyslog(logSyslogAddr, logTag)
+	case "journald":
+		return logging.NewJournald(logging.JournaldSocket, logTag)
+	}
+	return nil, fmt.Errorf("unknown log output (expected stderr, file, syslog or journald)")
 }
 
 // initLogger initializes the global zerolog logger based on the provided flags
 func initLogger() {
 	// Set log level
-	level := zerolog.InfoLevel
-	switch strings.ToLower(logLevel) {
-	case "debug":
-		level = zerolog.DebugLevel
-	case "info":
-		level = zerolog.InfoLevel
-	case "warn", "warning":
-		level = zerolog.WarnLevel
-	case "error":
-		level = zerolog.ErrorLevel
-	default:
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil || level == zerolog.Disabled {
 		fmt.Fprintf(os.Stderr, "Invalid log level '%s', defaulting to 'info'\n", logLevel)
 		level = zerolog.InfoLevel
 	}
-	zerolog.SetGlobalLevel(level)
+	logging.SetLevel(level)
+	componentLevels, err := logging.ParseComponentLevels(logLevels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid component log levels '%s', ignoring: %v\n", logLevels, err)
+	} else {
+		logging.SetComponentLevels(componentLevels)
+	}
 
 	// Set log format
-	switch strings.ToLower(logFormat) {
-	case "json":
-		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
-	case "pretty":
-		log.Logger = log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stderr,
-			TimeFormat: "15:04:05",
-		})
-	default:
+	format := strings.ToLower(logFormat)
+	if format != "json" && format != "pretty" {
 		fmt.Fprintf(os.Stderr, "Invalid log format '%s', defaulting to 'pretty'\n", logFormat)
-		log.Logger = log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stderr,
-			TimeFormat: "15:04:05",
-		})
+		format = "pretty"
+	}
+
+	// Open log outputs; any which fail are reported and skipped
+	var writers []io.Writer
+	for _, output := range strings.Split(logOutput, ",") {
+		w, err := openLogOutput(strings.ToLower(strings.TrimSpace(output)), format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log output '%s': %v\n", output, err)
+			continue
+		}
+		writers = append(writers, w)
 	}
+	if len(writers) == 0 {
+		w, _ := openLogOutput("stderr", format)
+		writers = append(writers, w)
+	}
+	w := writers[0]
+	if len(writers) > 1 {
+		w = zerolog.MultiLevelWriter(writers...)
+	}
+	log.Logger = zerolog.New(w).With().Timestamp().Logger()
+
+	// Components with a more verbose level of their own lower the global level, so the
+	// default level is enforced by the root logger
+	log.Logger = log.Logger.Level(level)
 }
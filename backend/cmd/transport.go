@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/transport"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	TransportMQTT      = "mqtt"
+	TransportJetStream = "jetstream"
+)
+
+type TransportOptions struct {
+	Kind              string
+	JetStreamURL      string
+	JetStreamStream   string
+	JetStreamSubjects []string
+	JetStreamMaxAge   time.Duration
+}
+
+// AddTransportFlags adds the flags selecting the transport for internal events
+func AddTransportFlags(cmd *cobra.Command, opts *TransportOptions) {
+	cmd.Flags().StringVar(&opts.Kind, "transport", TransportMQTT, "Transport for internal events such as ingested readings, the change feed and alerts: mqtt or jetstream")
+	cmd.Flags().StringVar(&opts.JetStreamURL, "jetstream-url", "nats://localhost:4222", "NATS server URL, e.g. nats://token@localhost:4222, when --transport=jetstream")
+	cmd.Flags().StringVar(&opts.JetStreamStream, "jetstream-stream", "LIFESUPPORT", "JetStream stream holding internal events; created if missing")
+	cmd.Flags().StringSliceVar(&opts.JetStreamSubjects, "jetstream-subjects", []string{"lifesupport.>"}, "Subjects captured by the stream when it is created")
+	cmd.Flags().DurationVar(&opts.JetStreamMaxAge, "jetstream-max-age", 7*24*time.Hour, "How long the stream keeps events when it is created; 0 keeps them until the server's limits")
+}
+
+// OpenTransport returns the transport selected by opts. MQTT uses the existing client,
+// while JetStream connects to its server; close releases that connection.
+func OpenTransport(ctx context.Context, opts TransportOptions, mqttClient mqtt.Client) (t transport.Transport, close func() error, err error) {
+	switch opts.Kind {
+	case TransportMQTT:
+		return transport.NewMQTT(mqttClient), func() error { return nil }, nil
+	case TransportJetStream:
+		js, err := transport.NewJetStream(opts.JetStreamURL, opts.JetStreamStream,
+			transport.WithStreamSubjects(opts.JetStreamSubjects...),
+			transport.WithStreamMaxAge(opts.JetStreamMaxAge),
+			transport.WithJetStreamLogger(log.Logger))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := js.Connect(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to JetStream: %w", err)
+		}
+		return js, js.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown transport %q", opts.Kind)
+	}
+}
@@ -2,15 +2,32 @@ package cmd
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/blob"
+	"lifesupport/backend/pkg/changefeed"
+	"lifesupport/backend/pkg/chaos"
+	"lifesupport/backend/pkg/control"
+	"lifesupport/backend/pkg/diagnostics"
+	"lifesupport/backend/pkg/drivers"
 	"lifesupport/backend/pkg/drivers/shelly"
+	"lifesupport/backend/pkg/health"
+	"lifesupport/backend/pkg/ingest"
+	"lifesupport/backend/pkg/latency"
+	"lifesupport/backend/pkg/lease"
+	"lifesupport/backend/pkg/notify"
+	"lifesupport/backend/pkg/presence"
+	"lifesupport/backend/pkg/quota"
+	"lifesupport/backend/pkg/retention"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/supervise"
 	"lifesupport/backend/pkg/workflows"
 
 	temporalWorker "go.temporal.io/sdk/worker"
@@ -28,29 +45,44 @@ var workerCmd = &cobra.Command{
 }
 
 var (
-	workerOptions WorkerOptions
-	commonOptions CommonOptions
-	mqttOptions   MQTTOptions
+	workerOptions    WorkerOptions
+	commonOptions    CommonOptions
+	mqttOptions      MQTTOptions
+	transportOptions TransportOptions
 )
 
-type MQTTOptions struct {
-	Broker                string
-	ClientID              string
-	Username              string
-	Password              string
-	KeepAlive             time.Duration
-	CleanSession          bool
-	AutoReconnect         bool
-	ConnectTimeout        time.Duration
-	TLSCACert             string
-	TLSClientCert         string
-	TLSClientKey          string
-	TLSInsecureSkipVerify bool
-}
-
 type WorkerOptions struct {
 	MaxConcurrentActivityExecutionSize     int
 	MaxConcurrentWorkflowTaskExecutionSize int
+	ReactionsConfig                        string
+	RuleTraceDepth                         int
+	IngestTopic                            string
+	IngestBatch                            ingest.BatchConfig
+	NotificationReadings                   bool
+	ActivityRetryConfig                    string
+	Chaos                                  bool
+	ChaosConfig                            chaos.Config
+	BenchmarkExportDir                     string
+	BenchmarkExportBlob                    bool
+	BenchmarkExportCron                    string
+	BenchmarkExportWindow                  time.Duration
+	PresencePrefix                         string
+	PresenceInterval                       time.Duration
+	BrokerLease                            bool
+	LeaseTTL                               time.Duration
+	ChangeFeedNATSURL                      string
+	ChangeFeedNATSSubject                  string
+	ChangeFeedKafkaURL                     string
+	ChangeFeedKafkaTopic                   string
+	ChangeFeedTransportSubject             string
+	ChangeFeedInterval                     time.Duration
+	ChangeFeedRetention                    time.Duration
+	RetentionInterval                      time.Duration
+	QuotaInterval                          time.Duration
+	QuotaRawWindow                         time.Duration
+	AlertSubject                           string
+	DebugAddr                              string
+	DebugTokenFile                         string
 }
 
 func init() {
@@ -62,53 +94,55 @@ func init() {
 	// Worker-specific temporal flags
 	workerCmd.Flags().StringVar(&commonOptions.Temporal.TaskQueue, "task-queue", "lifesupport-tasks", "Task queue name")
 
-	// MQTT flags
-	workerCmd.Flags().StringVar(&mqttOptions.Broker, "mqtt-broker", "tcp://localhost:1883", "MQTT broker URL")
-	workerCmd.Flags().StringVar(&mqttOptions.ClientID, "mqtt-client-id", "lifesupport-worker", "MQTT client ID")
-	workerCmd.Flags().StringVar(&mqttOptions.Username, "mqtt-username", "", "MQTT username")
-	workerCmd.Flags().StringVar(&mqttOptions.Password, "mqtt-password", "", "MQTT password")
-	workerCmd.Flags().DurationVar(&mqttOptions.KeepAlive, "mqtt-keepalive", 60*time.Second, "MQTT keep alive interval")
-	workerCmd.Flags().BoolVar(&mqttOptions.CleanSession, "mqtt-clean-session", true, "MQTT clean session")
-	workerCmd.Flags().BoolVar(&mqttOptions.AutoReconnect, "mqtt-auto-reconnect", true, "MQTT auto reconnect")
-	workerCmd.Flags().DurationVar(&mqttOptions.ConnectTimeout, "mqtt-connect-timeout", 30*time.Second, "MQTT connection timeout")
-	workerCmd.Flags().StringVar(&mqttOptions.TLSCACert, "mqtt-tls-ca-cert", "", "MQTT TLS CA certificate file path")
-	workerCmd.Flags().StringVar(&mqttOptions.TLSClientCert, "mqtt-tls-client-cert", "", "MQTT TLS client certificate file path")
-	workerCmd.Flags().StringVar(&mqttOptions.TLSClientKey, "mqtt-tls-client-key", "", "MQTT TLS client key file path")
-	workerCmd.Flags().BoolVar(&mqttOptions.TLSInsecureSkipVerify, "mqtt-tls-insecure-skip-verify", false, "MQTT TLS skip certificate verification")
+	AddMQTTFlags(workerCmd, &mqttOptions, "tcp://localhost:1883", "lifesupport-worker")
+	AddTransportFlags(workerCmd, &transportOptions)
 
 	// Worker flags
 	workerCmd.Flags().IntVar(&workerOptions.MaxConcurrentActivityExecutionSize, "max-concurrent-activities", 10, "Maximum concurrent activity executions")
 	workerCmd.Flags().IntVar(&workerOptions.MaxConcurrentWorkflowTaskExecutionSize, "max-concurrent-workflows", 10, "Maximum concurrent workflow task executions")
-}
-
-func createTLSConfig(opts MQTTOptions) (*tls.Config, error) {
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: opts.TLSInsecureSkipVerify,
-	}
-
-	// Load CA certificate if provided
-	if opts.TLSCACert != "" {
-		caCert, err := os.ReadFile(opts.TLSCACert)
-		if err != nil {
-			return nil, err
-		}
-		caCertPool := x509.NewCertPool()
-		if !caCertPool.AppendCertsFromPEM(caCert) {
-			return nil, err
-		}
-		tlsConfig.RootCAs = caCertPool
-	}
-
-	// Load client certificate and key if provided
-	if opts.TLSClientCert != "" && opts.TLSClientKey != "" {
-		cert, err := tls.LoadX509KeyPair(opts.TLSClientCert, opts.TLSClientKey)
-		if err != nil {
-			return nil, err
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
-	}
-
-	return tlsConfig, nil
+	workerCmd.Flags().StringVar(&workerOptions.IngestTopic, "ingest-topic", "", "Topic, or JetStream subject with --transport=jetstream, on which gateways publish sensor reading batches (JSON or compact CBOR); disabled if empty")
+	workerCmd.Flags().IntVar(&workerOptions.IngestBatch.MaxBatch, "ingest-batch-size", 500, "Readings written per multi-row insert; 1 writes each reading as it arrives")
+	workerCmd.Flags().DurationVar(&workerOptions.IngestBatch.FlushInterval, "ingest-flush-interval", time.Second, "Longest a reading is buffered before being written")
+	workerCmd.Flags().IntVar(&workerOptions.IngestBatch.MaxPending, "ingest-max-pending", 0, "Buffered readings before ingestion blocks; defaults to ten batches")
+	workerCmd.Flags().StringVar(&workerOptions.PresencePrefix, "presence-prefix", presence.DefaultPrefix, "MQTT topic prefix of the retained worker presence summary and last will; disabled if empty")
+	workerCmd.Flags().DurationVar(&workerOptions.PresenceInterval, "presence-interval", presence.DefaultInterval, "How often the worker refreshes its presence")
+	workerCmd.Flags().BoolVar(&workerOptions.BrokerLease, "broker-lease", true, "Only handle device events and ingestion while holding the broker's lease, so redundant workers on one broker don't act twice")
+	workerCmd.Flags().DurationVar(&workerOptions.LeaseTTL, "lease-ttl", lease.DefaultTTL, "How long a broker lease or control loop lock outlives its holder; another worker takes over within this long of a crash")
+	workerCmd.Flags().BoolVar(&workerOptions.NotificationReadings, "notification-readings", true, "Store readings from Shelly status notifications for sensors named <component>.<field>, e.g. switch:0.apower")
+	workerCmd.Flags().StringVar(&workerOptions.AlertSubject, "alert-subject", "", "Also publish alerts on the transport under this subject prefix, followed by the severity; disabled if empty")
+	workerCmd.Flags().StringVar(&workerOptions.ReactionsConfig, "reactions-config", "", "JSON file of leak responses and event reactions to run on the fast path")
+	workerCmd.Flags().IntVar(&workerOptions.RuleTraceDepth, "rule-trace-depth", 50, "Evaluations of each control loop rule kept for GET /api/rules/{kind}/{name}/trace; 0 disables tracing")
+	workerCmd.Flags().StringVar(&workerOptions.ActivityRetryConfig, "activity-retry-config", "", "JSON file of activity retry policies keyed by activity name or \"default\"")
+
+	// Benchmark export flags; exports only run when a directory or --benchmark-export-blob is given
+	workerCmd.Flags().StringVar(&workerOptions.BenchmarkExportDir, "benchmark-export-dir", "", "Opt in to scheduled anonymized benchmark exports, written to this directory")
+	workerCmd.Flags().BoolVar(&workerOptions.BenchmarkExportBlob, "benchmark-export-blob", false, "Opt in to scheduled anonymized benchmark exports, written under benchmarks/ in the --blob-url storage")
+	workerCmd.Flags().StringVar(&workerOptions.BenchmarkExportCron, "benchmark-export-cron", "0 3 * * *", "Cron schedule of benchmark exports")
+	workerCmd.Flags().DurationVar(&workerOptions.BenchmarkExportWindow, "benchmark-export-window", 24*time.Hour, "How much history each benchmark export covers")
+
+	// Change feed flags; each configured destination is relayed by one worker at a time
+	workerCmd.Flags().StringVar(&workerOptions.ChangeFeedNATSURL, "change-feed-nats-url", "", "Relay the change feed to this NATS server, e.g. nats://token@localhost:4222; disabled if empty")
+	workerCmd.Flags().StringVar(&workerOptions.ChangeFeedNATSSubject, "change-feed-nats-subject", "lifesupport.changes", "NATS subject prefix of relayed changes; the change type is appended")
+	workerCmd.Flags().StringVar(&workerOptions.ChangeFeedKafkaURL, "change-feed-kafka-rest-url", "", "Relay the change feed to Kafka through the REST Proxy at this URL; disabled if empty")
+	workerCmd.Flags().StringVar(&workerOptions.ChangeFeedKafkaTopic, "change-feed-kafka-topic", "lifesupport-changes", "Kafka topic of relayed changes")
+	workerCmd.Flags().StringVar(&workerOptions.ChangeFeedTransportSubject, "change-feed-transport-subject", "", "Relay the change feed on the transport under this subject prefix, followed by the change type; disabled if empty")
+	workerCmd.Flags().DurationVar(&workerOptions.ChangeFeedInterval, "change-feed-interval", time.Second, "How often relays check for new changes")
+	workerCmd.Flags().DurationVar(&workerOptions.ChangeFeedRetention, "change-feed-retention", 7*24*time.Hour, "How long changes are kept for relays and API consumers to catch up; 0 keeps them forever")
+
+	workerCmd.Flags().DurationVar(&workerOptions.RetentionInterval, "retention-interval", time.Hour, "How often readings older than their sensor type's retention policy are deleted, by one worker at a time; 0 disables")
+	workerCmd.Flags().DurationVar(&workerOptions.QuotaInterval, "quota-interval", time.Hour, "How often sensors are held to their storage quotas, by one worker at a time; 0 disables")
+	workerCmd.Flags().DurationVar(&workerOptions.QuotaRawWindow, "quota-raw-window", quota.DefaultRawWindow, "How recent readings of a sensor over its storage quota are kept at full resolution when downsampling")
+
+	// Debug flags, for profiling a running worker; safe in production behind a token
+	workerCmd.Flags().StringVar(&workerOptions.DebugAddr, "debug-addr", "", "Serve pprof profiles and runtime stats on this address, e.g. 127.0.0.1:6060; disabled if empty")
+	workerCmd.Flags().StringVar(&workerOptions.DebugTokenFile, "debug-token-file", "", "File holding the bearer token required by the debug endpoints; without one they only listen on localhost")
+
+	// Chaos flags, for testing alerting and failsafes; never enable in production
+	workerCmd.Flags().BoolVar(&workerOptions.Chaos, "chaos", false, "Inject random delays, dropped readings and offline devices (testing only)")
+	workerCmd.Flags().DurationVar(&workerOptions.ChaosConfig.MaxDelay, "chaos-max-delay", 2*time.Second, "Maximum random delay added to driver calls in chaos mode")
+	workerCmd.Flags().Float64Var(&workerOptions.ChaosConfig.DropRate, "chaos-drop-rate", 0.1, "Fraction of device events and ingested readings dropped in chaos mode")
+	workerCmd.Flags().Float64Var(&workerOptions.ChaosConfig.OfflineRate, "chaos-offline-rate", 0.01, "Chance per device interaction of the device dropping offline in chaos mode")
+	workerCmd.Flags().DurationVar(&workerOptions.ChaosConfig.OfflineDuration, "chaos-offline-duration", time.Minute, "How long a device stays offline in chaos mode")
 }
 
 func runWorker(cmd *cobra.Command, args []string) {
@@ -119,8 +153,18 @@ func runWorker(cmd *cobra.Command, args []string) {
 	// Initialize options
 	InitCommonOptions(&commonOptions)
 
+	reporter, err := InitErrorReporter(commonOptions.Sentry)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to configure error reporting")
+	}
+	defer flushErrorReporter(reporter)
+	if reporter != nil {
+		supervise.SetReporter(reporter)
+	}
+	defer reporter.Recover(map[string]string{"command": "worker"})
+
 	// Initialize database
-	store, err := InitDatabase(ctx, commonOptions.DB)
+	store, err := InitDatabase(ctx, commonOptions.DB, commonOptions.storerOptions()...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Unable to create storer")
 	}
@@ -141,50 +185,281 @@ func runWorker(cmd *cobra.Command, args []string) {
 	}
 	defer clickhouseConn.Close()
 
-	// Configure MQTT client
-	mqttClientOptions := mqtt.NewClientOptions().
-		AddBroker(mqttOptions.Broker).
-		SetClientID(mqttOptions.ClientID).
-		SetKeepAlive(mqttOptions.KeepAlive).
-		SetCleanSession(mqttOptions.CleanSession).
-		SetAutoReconnect(mqttOptions.AutoReconnect).
-		SetConnectTimeout(mqttOptions.ConnectTimeout)
-
-	if mqttOptions.Username != "" {
-		mqttClientOptions.SetUsername(mqttOptions.Username)
+	// The worker is identified by its MQTT client ID, which the broker already requires
+	// to be unique
+	hostname, _ := os.Hostname()
+	workerPresence := api.WorkerPresence{
+		ID:        mqttOptions.ClientID,
+		Broker:    presence.RedactBroker(mqttOptions.Broker),
+		Hostname:  hostname,
+		TaskQueue: commonOptions.Temporal.TaskQueue,
+		Drivers:   []api.DriverName{api.DriverShelly},
+		StartedAt: time.Now(),
+	}
+	var configureMQTT []func(*mqtt.ClientOptions) error
+	if workerOptions.PresencePrefix != "" {
+		configureMQTT = append(configureMQTT, func(opts *mqtt.ClientOptions) error {
+			return presence.Will(opts, workerOptions.PresencePrefix, workerPresence)
+		})
+	}
+	mqttClient, err := ConnectMQTT(mqttOptions, configureMQTT...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to connect to MQTT broker")
+	}
+	var presencePublisher *presence.Publisher
+	if workerOptions.PresencePrefix != "" {
+		presencePublisher = presence.NewPublisher(mqttClient, workerOptions.PresencePrefix, workerPresence,
+			presence.WithInterval(workerOptions.PresenceInterval), presence.WithLogger(log.Logger))
+		if err := presencePublisher.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Unable to announce worker presence")
+		}
 	}
-	if mqttOptions.Password != "" {
-		mqttClientOptions.SetPassword(mqttOptions.Password)
+	eventTransport, closeTransport, err := OpenTransport(ctx, transportOptions, mqttClient)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to open event transport")
+	}
+	log.Info().Str("transport", transportOptions.Kind).Msg("Opened event transport")
+	driversManager := drivers.NewManager()
+	// Reaction times are measured on the live paths: device readings until stored and
+	// commands until the device confirms them
+	latencies := latency.NewRecorder()
+	dispatcherOpts, err := commonOptions.dispatcherOptions()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load power budgets")
+	}
+	dispatcher := drivers.NewDispatcher(store, driversManager,
+		append(dispatcherOpts, drivers.WithCommandLatency(latencies.Histogram(latency.CommandConfirmed)))...)
+
+	var monkey *chaos.Monkey
+	if workerOptions.Chaos {
+		monkey = chaos.New(workerOptions.ChaosConfig, chaos.WithLogger(log.Logger))
+		log.Warn().
+			Dur("max_delay", workerOptions.ChaosConfig.MaxDelay).
+			Float64("drop_rate", workerOptions.ChaosConfig.DropRate).
+			Float64("offline_rate", workerOptions.ChaosConfig.OfflineRate).
+			Dur("offline_duration", workerOptions.ChaosConfig.OfflineDuration).
+			Msg("Chaos mode enabled: device interactions will fail at random")
 	}
 
-	// Configure TLS if certificates are provided
-	if mqttOptions.TLSCACert != "" || mqttOptions.TLSClientCert != "" || mqttOptions.TLSInsecureSkipVerify {
-		tlsConfig, err := createTLSConfig(mqttOptions)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Unable to create TLS config for MQTT")
+	// Readings from gateways and Shelly notifications share one write buffer, so many
+	// devices streaming at once become a few multi-row inserts rather than one per reading
+	timedStore := latency.Readings(store, latencies.Histogram(latency.ReadingStored))
+	readings := batchedReadings{Interface: store, readings: timedStore}
+	var batcher *ingest.Batcher
+	if workerOptions.IngestBatch.MaxBatch > 1 {
+		batcher = ingest.NewBatcher(timedStore, workerOptions.IngestBatch, ingest.WithBatcherLogger(log.Logger))
+		if err := batcher.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Unable to start reading write batching")
 		}
-		mqttClientOptions.SetTLSConfig(tlsConfig)
+		readings.readings = batcher
 	}
 
-	mqttClient := mqtt.NewClient(mqttClientOptions)
-	token := mqttClient.Connect()
-	token.WaitTimeout(mqttOptions.ConnectTimeout)
-	if err := token.Error(); err != nil {
-		log.Fatal().Err(err).Msg("Unable to connect to MQTT broker")
+	shellyOpts := []shelly.Option{shelly.WithIDNamespace(commonOptions.ShellyIDNamespace)}
+	vault, err := commonOptions.credentialVault(store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load device credentials")
+	}
+	if vault != nil {
+		shellyOpts = append(shellyOpts, shelly.WithCredentials(vault))
+	}
+	locker := lease.NewLocker(store, mqttOptions.ClientID, lease.WithLockTTL(workerOptions.LeaseTTL), lease.WithLockerLogger(log.Logger))
+	notifyConfig, err := loadNotifyConfig(commonOptions.NotifyConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to load notify config")
+	}
+	alertRecorder := changefeed.NewAlertRecorder(store)
+	telegram := notifyConfig.telegram(alertRecorder.Ack, func(ctx context.Context) (*api.HealthScore, error) {
+		return health.Score(ctx, store, time.Now(), health.DefaultStaleAfter)
+	}, dispatcher)
+	// Alerts always reach the change feed, and also the configured channels their
+	// severity is routed to
+	alerts := notify.Multi{alertRecorder, notifyConfig.router(telegram)}
+	if workerOptions.AlertSubject != "" {
+		alerts = append(alerts, notify.NewTransport(eventTransport, workerOptions.AlertSubject))
+	}
+	var controlLoops []*lease.Elector
+	if workerOptions.ReactionsConfig != "" {
+		cfg, err := loadReactionsConfig(workerOptions.ReactionsConfig)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Unable to load reactions config")
+		}
+		broken := control.NewBrokenRules(store, brokenRulesRefresh)
+		fastPath := control.NewFastPath(dispatcher, buildReactions(cfg, dispatcher, dispatcher, alerts, broken)...)
+		handler := drivers.EventHandler(fastPath.HandleEvent)
+		if monkey != nil {
+			handler = monkey.EventHandler(handler)
+		}
+		shellyOpts = append(shellyOpts, shelly.WithEventHandler(handler))
+		log.Info().
+			Int("leak_responses", len(cfg.LeakResponses)).
+			Int("event_reactions", len(cfg.EventReactions)).
+			Msg("Fast-path reactions enabled")
+
+		var tracer *control.Tracer
+		if workerOptions.RuleTraceDepth > 0 {
+			tracer = control.NewTracer(store, workerOptions.RuleTraceDepth)
+		}
+		controlLoops = buildControlLoops(cfg, locker, dispatcher, dispatcher, store, store, alerts, broken, tracer)
+		log.Info().
+			Int("pump_rotations", len(cfg.PumpRotations)).
+			Int("dry_run_rules", len(cfg.DryRunRules)).
+			Int("spc_rules", len(cfg.SPCRules)).
+			Int("rate_of_change_rules", len(cfg.RateRules)).
+			Int("composite_rules", len(cfg.CompositeRules)).
+			Int("scenes", len(cfg.Scenes)).
+			Int("rule_trace_depth", workerOptions.RuleTraceDepth).
+			Msg("Control loops enabled")
+	}
+	if workerOptions.NotificationReadings {
+		shellyOpts = append(shellyOpts, shelly.WithReadingStore(readings))
+	}
+	if workerOptions.BrokerLease {
+		shellyOpts = append(shellyOpts, shelly.WithDeferredEvents())
+	}
+	shellyDriver := shelly.New(mqttClient, clickhouseConn, shellyOpts...)
+	if monkey != nil {
+		driversManager.Register(api.DriverShelly, monkey.Driver(shellyDriver))
+	} else {
+		driversManager.Register(api.DriverShelly, shellyDriver)
 	}
-	shellyDriver := shelly.New(mqttClient, clickhouseConn)
 	if err := shellyDriver.Start(ctx); err != nil {
 		log.Fatal().Err(err).Msg("Unable to start Shelly driver")
 	}
 
-	workflowCtx := workflows.New(log.Logger, store, shellyDriver)
+	var ingester *ingest.Subscriber
+	if workerOptions.IngestTopic != "" {
+		var readingStore ingest.ReadingStore = readings
+		if monkey != nil {
+			readingStore = monkey.ReadingStore(readingStore)
+		}
+		ingester = ingest.NewSubscriber(eventTransport, workerOptions.IngestTopic, "ingest", readingStore, ingest.WithLogger(log.Logger))
+	}
+	// JetStream shares ingested readings between the workers subscribed to its durable
+	// consumer, so every worker ingests rather than only the broker's lease holder
+	sharedIngest := transportOptions.Kind == TransportJetStream
+
+	// Every worker sends commands and runs activities, but only one per broker reacts to
+	// device events and ingests readings
+	owner := &brokerOwner{}
+	if !sharedIngest {
+		owner.ingester = ingester
+	}
+	if workerOptions.ReactionsConfig != "" || workerOptions.NotificationReadings {
+		owner.shelly = shellyDriver
+	}
+	var elector *lease.Elector
+	if workerOptions.BrokerLease {
+		name := "broker/" + presence.RedactBroker(mqttOptions.Broker)
+		elector = lease.NewElector(store, name, mqttOptions.ClientID, owner,
+			lease.WithTTL(workerOptions.LeaseTTL), lease.WithLogger(log.Logger))
+		if err := elector.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Unable to campaign for broker lease")
+		}
+	}
+	if ingester != nil && (sharedIngest || elector == nil) {
+		if err := ingester.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Unable to start reading ingestion")
+		}
+	}
+
+	var workflowOpts []workflows.Option
+	if workerOptions.ActivityRetryConfig != "" {
+		policies, err := loadRetryPolicies(workerOptions.ActivityRetryConfig)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Unable to load activity retry config")
+		}
+		workflowOpts = append(workflowOpts, workflows.WithRetryPolicies(policies))
+	}
+	var benchmarkExports blob.Bucket
+	switch {
+	case workerOptions.BenchmarkExportDir != "":
+		benchmarkExports = blob.NewFilesystem(workerOptions.BenchmarkExportDir)
+	case workerOptions.BenchmarkExportBlob:
+		bucket, err := OpenBlobStore(commonOptions)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Unable to open blob storage")
+		}
+		if bucket == nil {
+			log.Fatal().Msg("--benchmark-export-blob requires --blob-url")
+		}
+		benchmarkExports = blob.WithPrefix(bucket, "benchmarks/")
+	}
+	if benchmarkExports != nil {
+		workflowOpts = append(workflowOpts, workflows.WithBenchmarkExports(benchmarkExports))
+		if err := scheduleBenchmarkExport(ctx, c, commonOptions.Temporal.TaskQueue, workerOptions); err != nil {
+			log.Fatal().Err(err).Msg("Unable to schedule benchmark export")
+		}
+	}
+	workflowCtx := workflows.New(log.Logger, store, shellyDriver, workflowOpts...)
+
+	changeFeedLoops, err := buildChangeFeedLoops(workerOptions, locker, store, eventTransport)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to configure change feed")
+	}
+	controlLoops = append(controlLoops, changeFeedLoops...)
+	if telegram != nil {
+		controlLoops = append(controlLoops, lease.NewElector(store, "telegram", mqttOptions.ClientID, &telegramBot{bot: telegram},
+			lease.WithTTL(workerOptions.LeaseTTL), lease.WithLogger(log.Logger)))
+		log.Info().Msg("Telegram bot commands enabled")
+	}
+
+	var enforcer *retention.Enforcer
+	if workerOptions.RetentionInterval > 0 {
+		enforcer = retention.NewEnforcer(store, retention.WithLogger(log.Logger))
+		controlLoops = append(controlLoops, locker.Loop("retention", workerOptions.RetentionInterval, enforcer.Tick))
+		log.Info().Dur("interval", workerOptions.RetentionInterval).Msg("Reading retention enabled")
+	}
+	var quotas *quota.Enforcer
+	if workerOptions.QuotaInterval > 0 {
+		quotas = quota.NewEnforcer(store,
+			quota.WithNotifier(alerts),
+			quota.WithRawWindow(workerOptions.QuotaRawWindow),
+			quota.WithLogger(log.Logger))
+		controlLoops = append(controlLoops, locker.Loop("quota", workerOptions.QuotaInterval, quotas.Tick))
+		log.Info().Dur("interval", workerOptions.QuotaInterval).Msg("Storage quotas enabled")
+	}
+
+	for _, loop := range controlLoops {
+		if err := loop.Start(ctx); err != nil {
+			log.Fatal().Err(err).Msg("Unable to start control loop")
+		}
+	}
+
+	var debugServer *http.Server
+	if workerOptions.DebugAddr != "" {
+		gauges := []diagnostics.Option{
+			diagnostics.WithGauge("shelly.pending_rpcs", shellyDriver.PendingRequests),
+			diagnostics.WithLatency(latencies),
+		}
+		if batcher != nil {
+			gauges = append(gauges, diagnostics.WithGauge("ingest.pending_readings", func() int { return batcher.Stats().Pending }))
+		}
+		if enforcer != nil {
+			gauges = append(gauges,
+				diagnostics.WithGauge("retention.deleted_readings", func() int { return int(enforcer.Stats().Deleted) }),
+				diagnostics.WithGauge("retention.failed_runs", func() int { return int(enforcer.Stats().Failed) }))
+		}
+		if quotas != nil {
+			gauges = append(gauges,
+				diagnostics.WithGauge("quota.thinned_readings", func() int { return int(quotas.Stats().Thinned) }),
+				diagnostics.WithGauge("quota.over_quota", func() int { return quotas.Stats().OverQuota }))
+		}
+		debugServer, err = StartDebugServer(workerOptions.DebugAddr, workerOptions.DebugTokenFile, gauges...)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Unable to serve debug endpoints")
+		}
+	}
 
 	// Create worker
-	w := temporalWorker.New(c, commonOptions.Temporal.TaskQueue, temporalWorker.Options{
+	workerOpts := temporalWorker.Options{
 		MaxConcurrentActivityExecutionSize:     workerOptions.MaxConcurrentActivityExecutionSize,
 		MaxConcurrentWorkflowTaskExecutionSize: workerOptions.MaxConcurrentWorkflowTaskExecutionSize,
 		Identity:                               commonOptions.Temporal.Identity,
-	})
+	}
+	if reporter != nil {
+		workerOpts.Interceptors = append(workerOpts.Interceptors, reporter.WorkerInterceptor())
+	}
+	w := temporalWorker.New(c, commonOptions.Temporal.TaskQueue, workerOpts)
 
 	workflowCtx.Register(w)
 
@@ -203,6 +478,7 @@ func runWorker(cmd *cobra.Command, args []string) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer reporter.Recover(map[string]string{"command": "worker"})
 		err := w.Run(temporalWorker.InterruptCh())
 		if err != nil {
 			log.Fatal().Err(err).Msg("Unable to start worker")
@@ -222,10 +498,90 @@ func runWorker(cmd *cobra.Command, args []string) {
 
 	log.Info().Msg("Shutting down Temporal worker...")
 	w.Stop()
+	if debugServer != nil {
+		if err := debugServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error stopping debug server")
+		}
+	}
+	for _, loop := range controlLoops {
+		if err := loop.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error handing over control loop")
+		}
+	}
+	if elector != nil {
+		if err := elector.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error handing over broker lease")
+		}
+	}
+	if ingester != nil && (sharedIngest || elector == nil) {
+		if err := ingester.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error stopping reading ingestion")
+		}
+	}
 	if err := shellyDriver.Stop(shutdownCtx); err != nil {
 		log.Error().Err(err).Msg("Error stopping Shelly driver")
 	}
+	if batcher != nil {
+		if err := batcher.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error flushing buffered readings")
+		}
+	}
+	if presencePublisher != nil {
+		if err := presencePublisher.Stop(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Error announcing worker shutdown")
+		}
+	}
+	if err := closeTransport(); err != nil {
+		log.Error().Err(err).Msg("Error closing event transport")
+	}
 	mqttClient.Disconnect(250)
 	wg.Wait()
 
 }
+
+// batchedReadings is the store with reading writes redirected, typically to an
+// ingest.Batcher
+type batchedReadings struct {
+	storer.Interface
+	readings ingest.BatchStore
+}
+
+func (b batchedReadings) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	return b.readings.StoreSensorReading(ctx, rec)
+}
+
+func (b batchedReadings) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	return b.readings.StoreSensorReadings(ctx, recs)
+}
+
+// brokerOwner is the work only the broker's lease holder does: handling device events
+// and ingesting gateway readings. Either part may be nil.
+type brokerOwner struct {
+	shelly   *shelly.Driver
+	ingester *ingest.Subscriber
+}
+
+func (o *brokerOwner) Start(ctx context.Context) error {
+	if o.shelly != nil {
+		if err := o.shelly.StartEvents(ctx); err != nil {
+			return err
+		}
+	}
+	if o.ingester != nil {
+		if err := o.ingester.Start(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *brokerOwner) Stop(ctx context.Context) error {
+	var errs []error
+	if o.ingester != nil {
+		errs = append(errs, o.ingester.Stop(ctx))
+	}
+	if o.shelly != nil {
+		errs = append(errs, o.shelly.StopEvents(ctx))
+	}
+	return errors.Join(errs...)
+}
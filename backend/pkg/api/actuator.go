@@ -9,6 +9,7 @@ const (
 	ActuatorTypeRelay           ActuatorType = "relay"
 	ActuatorTypePeristalticPump ActuatorType = "peristaltic_pump"
 	ActuatorTypeDimmableLight   ActuatorType = "dimmable_light"
+	ActuatorTypeValve           ActuatorType = "valve"
 )
 
 // ActuatorState represents the current state of an actuator
@@ -17,6 +18,8 @@ type ActuatorState struct {
 	Parameters map[string]float64 `json:"parameters,omitempty"`
 	Timestamp  time.Time          `json:"timestamp"`
 	Error      string             `json:"error,omitempty"`
+	// Origin is who or what issued the command which set this state
+	Origin *CommandOrigin `json:"origin,omitempty"`
 }
 
 // ActuatorCommand represents a command to send to an actuator
@@ -33,6 +36,10 @@ type Actuator struct {
 	ActuatorType ActuatorType      `json:"actuator_type"`
 	Metadata     map[string]string `json:"metadata,omitempty"`
 	Tags         []string          `json:"tags,omitempty"`
+	ExternalID   string            `json:"external_id,omitempty"`
+	// Version counts the changes to the entity; updates must carry the version they
+	// were based on, and are refused if it has since changed
+	Version int64 `json:"version"`
 }
 
 func (a *Actuator) GetID() string {
@@ -0,0 +1,45 @@
+package api
+
+import "time"
+
+// AlertSeverity identifies how urgent an alert is
+type AlertSeverity string
+
+const (
+	AlertSeverityInfo     AlertSeverity = "info"
+	AlertSeverityWarning  AlertSeverity = "warning"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+// Alert keys identify the kind of alert, so clients can show a localized title from the
+// i18n catalog in place of the English Title
+const (
+	AlertKeyLeakDetected         = "leak_detected"
+	AlertKeyPumpDryRun           = "pump_dry_run"
+	AlertKeyPumpSwitchoverFailed = "pump_switchover_failed"
+	AlertKeySPCOutOfControl      = "spc_out_of_control"
+	AlertKeyRateOfChange         = "rate_of_change"
+	AlertKeyCompositeRule        = "composite_rule"
+	AlertKeyStorageQuota         = "storage_quota"
+)
+
+// Alert represents a condition that should be brought to a human's attention
+type Alert struct {
+	ID        string            `json:"id"`
+	Severity  AlertSeverity     `json:"severity"`
+	Title     string            `json:"title"`
+	Message   string            `json:"message"`
+	Key       string            `json:"key,omitempty"`    // AlertKey* naming the localized title template
+	Params    map[string]string `json:"params,omitempty"` // values for the title template's placeholders
+	Source    string            `json:"source,omitempty"` // tag of the sensor/actuator/device that raised the alert
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// AlertAck records that a human acknowledged an alert
+type AlertAck struct {
+	AlertID   string    `json:"alert_id"`
+	User      string    `json:"user"`
+	Channel   string    `json:"channel"` // e.g. "slack"
+	Timestamp time.Time `json:"timestamp"`
+}
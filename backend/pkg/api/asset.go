@@ -0,0 +1,31 @@
+package api
+
+import "time"
+
+// AssetKind says what an attached image shows
+type AssetKind string
+
+const (
+	// AssetKindPhoto is a photo of the equipment itself
+	AssetKindPhoto AssetKind = "photo"
+	// AssetKindDiagram is a plumbing or wiring diagram
+	AssetKindDiagram AssetKind = "diagram"
+)
+
+// Asset is an image attached to a device or a subsystem, so the UI can show what
+// "pump-3" physically is. Exactly one of DeviceID and Subsystem is set; Subsystem is a
+// subsystem type, such as "aquarium". The image and its thumbnail are kept in blob
+// storage.
+type Asset struct {
+	ID            string    `json:"id"`
+	DeviceID      string    `json:"device_id,omitempty"`
+	Subsystem     string    `json:"subsystem,omitempty"`
+	Kind          AssetKind `json:"kind"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	Width         int       `json:"width"`
+	Height        int       `json:"height"`
+	ThumbnailType string    `json:"thumbnail_type"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AuditEntityType is the kind of entity an audit entry describes
+type AuditEntityType string
+
+const (
+	AuditEntityDevice   AuditEntityType = "device"
+	AuditEntitySensor   AuditEntityType = "sensor"
+	AuditEntityActuator AuditEntityType = "actuator"
+)
+
+// AuditAction is what happened to an audited entity
+type AuditAction string
+
+const (
+	AuditActionCreated AuditAction = "created"
+	AuditActionUpdated AuditAction = "updated"
+	AuditActionDeleted AuditAction = "deleted"
+	// AuditActionCommanded records a command sent to an actuator
+	AuditActionCommanded AuditAction = "commanded"
+)
+
+// AuditEntry records who changed an entity and how. It is written in the same
+// transaction as the change.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	EntityType AuditEntityType `json:"entity_type"`
+	// EntityID is the device ID, or "{device_id}/{id}" for sensors and actuators
+	EntityID string      `json:"entity_id"`
+	Action   AuditAction `json:"action"`
+	// Actor is who made the change, as attached by WithActor, or the name of a command's
+	// origin; empty if unknown
+	Actor string `json:"actor,omitempty"`
+	// Source is what kind of actor made the change: user for changes with an actor, or
+	// the source of a command's origin
+	Source CommandSource `json:"source,omitempty"`
+	// Before and After are the stored entity either side of the change; Before is
+	// omitted for creations and After for deletions. Devices are recorded without their
+	// sensors and actuators. After is the command record for commands.
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+type actorKey struct{}
+
+// WithActor returns a context attributing changes stored with it to actor, such as a
+// user name
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor attached by WithActor, or "" if there is none
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// FieldChange is one field set differently either side of a change. Before is omitted
+// for a field the change set, and After for one it cleared.
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// DeviceRevision is one change to a device's configuration, from its audit entry
+type DeviceRevision struct {
+	// AuditID is the ID of the audit entry recording the change
+	AuditID   int64         `json:"audit_id"`
+	Action    AuditAction   `json:"action"`
+	Actor     string        `json:"actor,omitempty"`
+	Source    CommandSource `json:"source,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	// Device is the device as the change left it, without its sensors and actuators;
+	// omitted for deletions
+	Device *Device `json:"device,omitempty"`
+	// Changes lists the fields the change set differently, ordered by field. Metadata is
+	// compared by key, as "metadata.{key}".
+	Changes []FieldChange `json:"changes"`
+}
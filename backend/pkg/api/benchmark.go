@@ -0,0 +1,35 @@
+package api
+
+import "time"
+
+// MetadataSubsystemType is the device metadata key naming the subsystem type a device
+// serves (e.g. "aquarium", "hydroponics"). It is the only device detail kept in
+// benchmark datasets.
+const MetadataSubsystemType = "subsystem_type"
+
+// BenchmarkDataset is an anonymized summary of readings for community benchmarking. It
+// carries no IDs, names, tags or metadata beyond sensor and subsystem types.
+type BenchmarkDataset struct {
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Series []BenchmarkSeries `json:"series"`
+}
+
+// BenchmarkSeries holds the hourly summaries of one sensor, identified only by a
+// pseudonym that is not stable between datasets
+type BenchmarkSeries struct {
+	Sensor        string           `json:"sensor"`
+	SensorType    SensorType       `json:"sensor_type"`
+	SubsystemType string           `json:"subsystem_type,omitempty"`
+	Unit          Unit             `json:"unit"`
+	Points        []BenchmarkPoint `json:"points"`
+}
+
+// BenchmarkPoint summarises the valid readings of one hour
+type BenchmarkPoint struct {
+	Hour  time.Time `json:"hour"`
+	Mean  float64   `json:"mean"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"count"`
+}
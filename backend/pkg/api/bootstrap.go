@@ -0,0 +1,21 @@
+package api
+
+// Bootstrap is everything the dashboard loads on start, in one response
+type Bootstrap struct {
+	// User is who the request was made as, from its X-User header; empty if unknown
+	User string `json:"user,omitempty"`
+	// Subsystems groups devices, with their sensors and actuators, by the subsystem type
+	// in their metadata, ordered by name
+	Subsystems []*SubsystemDevices `json:"subsystems"`
+	// LatestReadings holds the most recent reading of each sensor with any
+	LatestReadings []*ReadingRecord `json:"latest_readings"`
+	// Features reports which feature flags are on for the calling client
+	Features    map[string]bool  `json:"features"`
+	Maintenance *MaintenanceMode `json:"maintenance"`
+}
+
+// SubsystemDevices is the devices of one subsystem type, ordered by name
+type SubsystemDevices struct {
+	Subsystem string    `json:"subsystem"`
+	Devices   []*Device `json:"devices"`
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ChangeType identifies what happened to an entity
+type ChangeType string
+
+const (
+	ChangeDeviceCreated ChangeType = "device.created"
+	ChangeDeviceUpdated ChangeType = "device.updated"
+	ChangeDeviceDeleted ChangeType = "device.deleted"
+	ChangeReadingStored ChangeType = "reading.stored"
+	// ChangeActuatorState is a state an actuator reported in answer to a command
+	ChangeActuatorState ChangeType = "actuator.state"
+	ChangeAlertFired    ChangeType = "alert.fired"
+	// ChangeAlertAcknowledged is a human acknowledging an alert
+	ChangeAlertAcknowledged ChangeType = "alert.acknowledged"
+)
+
+// ChangeEvent is an entry in the change feed. Events are recorded in the same
+// transaction as the change itself and numbered by Seq, which only increases, so a
+// consumer can mirror state by applying events in order from its last Seq.
+type ChangeEvent struct {
+	Seq  int64      `json:"seq"`
+	Type ChangeType `json:"type"`
+	// EntityID is the device ID, "{device_id}/{sensor_id}" for readings,
+	// "{device_id}/{actuator_id}" for actuator states, or the alert ID for alerts and
+	// their acknowledgements
+	EntityID string `json:"entity_id"`
+	// Data is the entity after the change: a Device, ReadingRecord, Alert, AlertAck, or
+	// the CommandRecord carrying an actuator state. It is omitted for deletions.
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// ChangeFeed is a page of the change feed. Cursor is the Seq to pass as after for the
+// next page; it is unchanged when there are no new events.
+type ChangeFeed struct {
+	Changes []*ChangeEvent `json:"changes"`
+	Cursor  int64          `json:"cursor"`
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"maps"
+	"strings"
+)
+
+// DeviceClone asks for a copy of a device, with its sensors and actuators, under a new
+// ID, for standing up a second rig identical to the first
+type DeviceClone struct {
+	// ID is the new device's ID
+	ID string `json:"id"`
+	// Name defaults to the copied device's name
+	Name string `json:"name,omitempty"`
+	// TagPrefix moves the copied tags to a new prefix
+	TagPrefix TagPrefixRemap `json:"tag_prefix"`
+}
+
+// TagPrefixRemap rewrites tags starting with From to start with To instead, such as
+// rack1.light to rack2.light
+type TagPrefixRemap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Apply returns the tags under From moved under To, dropping the rest
+func (p TagPrefixRemap) Apply(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		if rest, ok := strings.CutPrefix(tag, p.From); ok {
+			out = append(out, p.To+rest)
+		}
+	}
+	return out
+}
+
+// Clone returns a copy of d described by req. Tags outside the old prefix, such as the
+// device's default tag, are dropped rather than shared with d, so the store gives the
+// copy default tags of its own. External IDs are left for the store to assign.
+func (d *Device) Clone(req DeviceClone) *Device {
+	clone := &Device{
+		ID:          req.ID,
+		Driver:      d.Driver,
+		Name:        req.Name,
+		Description: d.Description,
+		Metadata:    maps.Clone(d.Metadata),
+		Tags:        req.TagPrefix.Apply(d.Tags),
+	}
+	if clone.Name == "" {
+		clone.Name = d.Name
+	}
+	for _, s := range d.Sensors {
+		clone.Sensors = append(clone.Sensors, &Sensor{
+			ID:         s.ID,
+			DeviceID:   req.ID,
+			Name:       s.Name,
+			SensorType: s.SensorType,
+			Metadata:   maps.Clone(s.Metadata),
+			Tags:       req.TagPrefix.Apply(s.Tags),
+		})
+	}
+	for _, a := range d.Actuators {
+		clone.Actuators = append(clone.Actuators, &Actuator{
+			ID:           a.ID,
+			DeviceID:     req.ID,
+			Name:         a.Name,
+			ActuatorType: a.ActuatorType,
+			Metadata:     maps.Clone(a.Metadata),
+			Tags:         req.TagPrefix.Apply(a.Tags),
+		})
+	}
+	return clone
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// CommandSource is what kind of thing issued an actuator command
+type CommandSource string
+
+const (
+	CommandSourceUser     CommandSource = "user"
+	CommandSourceRule     CommandSource = "rule"
+	CommandSourceSchedule CommandSource = "schedule"
+	CommandSourceWorkflow CommandSource = "workflow"
+)
+
+// CommandOrigin identifies who or what issued a command: a user name, a rule as
+// "<kind>/<name>", a schedule ID or a workflow ID
+type CommandOrigin struct {
+	Source CommandSource `json:"source"`
+	Name   string        `json:"name,omitempty"`
+}
+
+// CommandRecord is one actuator command in a device's command history
+type CommandRecord struct {
+	ID         int64           `json:"id"`
+	DeviceID   string          `json:"device_id"`
+	ActuatorID string          `json:"actuator_id"`
+	Tag        string          `json:"tag,omitempty"`
+	Origin     CommandOrigin   `json:"origin"`
+	Command    ActuatorCommand `json:"command"`
+	// State is the actuator state the driver reported; empty if the command failed
+	State     *ActuatorState `json:"state,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	IssuedAt  time.Time      `json:"issued_at"`
+	LatencyMS int64          `json:"latency_ms"`
+}
+
+// Succeeded reports whether the command was carried out
+func (c *CommandRecord) Succeeded() bool {
+	return c.Error == ""
+}
+
+type commandOriginKey struct{}
+
+// WithCommandOrigin returns a context attributing commands issued with it to origin
+func WithCommandOrigin(ctx context.Context, origin CommandOrigin) context.Context {
+	return context.WithValue(ctx, commandOriginKey{}, origin)
+}
+
+// CommandOriginFrom returns the origin attached by WithCommandOrigin; the source is
+// empty if there is none
+func CommandOriginFrom(ctx context.Context) CommandOrigin {
+	origin, _ := ctx.Value(commandOriginKey{}).(CommandOrigin)
+	return origin
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConditionOp compares a reading with a condition's value
+type ConditionOp string
+
+const (
+	OpEqual        ConditionOp = "=="
+	OpNotEqual     ConditionOp = "!="
+	OpLess         ConditionOp = "<"
+	OpLessEqual    ConditionOp = "<="
+	OpGreater      ConditionOp = ">"
+	OpGreaterEqual ConditionOp = ">="
+)
+
+// Compare reports whether value stands in the relation op to want, e.g. value < want
+func (op ConditionOp) Compare(value, want float64) bool {
+	switch op {
+	case OpEqual:
+		return value == want
+	case OpNotEqual:
+		return value != want
+	case OpLess:
+		return value < want
+	case OpLessEqual:
+		return value <= want
+	case OpGreater:
+		return value > want
+	case OpGreaterEqual:
+		return value >= want
+	}
+	return false
+}
+
+// Condition is a boolean test over sensor readings and actuator states, combined with
+// AND (All), OR (Any) and NOT. Exactly one of Tag, All, Any and Not is set.
+type Condition struct {
+	// Tag names the sensor or actuator compared with Value; actuators read 1 when on
+	// and 0 when off
+	Tag   string      `json:"tag,omitempty"`
+	Op    ConditionOp `json:"op,omitempty"`
+	Value float64     `json:"value,omitempty"`
+
+	All []Condition `json:"all,omitempty"`
+	Any []Condition `json:"any,omitempty"`
+	Not *Condition  `json:"not,omitempty"`
+}
+
+// Validate checks that each part of the condition sets exactly one form
+func (c Condition) Validate() error {
+	forms := 0
+	for _, set := range []bool{c.Tag != "", len(c.All) > 0, len(c.Any) > 0, c.Not != nil} {
+		if set {
+			forms++
+		}
+	}
+	if forms != 1 {
+		return errors.New("condition must set exactly one of tag, all, any and not")
+	}
+	switch {
+	case c.Tag != "":
+		if !c.Op.valid() {
+			return fmt.Errorf("condition on %s has unknown op %q", c.Tag, c.Op)
+		}
+	case c.Not != nil:
+		return c.Not.Validate()
+	default:
+		for _, child := range slices.Concat(c.All, c.Any) {
+			if err := child.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (op ConditionOp) valid() bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+		return true
+	}
+	return false
+}
+
+// Tags lists the sensor and actuator tags the condition reads, each once
+func (c Condition) Tags() []string {
+	var tags []string
+	var walk func(c Condition)
+	walk = func(c Condition) {
+		if c.Tag != "" && !slices.Contains(tags, c.Tag) {
+			tags = append(tags, c.Tag)
+		}
+		for _, child := range slices.Concat(c.All, c.Any) {
+			walk(child)
+		}
+		if c.Not != nil {
+			walk(*c.Not)
+		}
+	}
+	walk(c)
+	return tags
+}
+
+// String renders the condition as an expression, e.g. "(heater.main == 1 AND flow.main == 0)"
+func (c Condition) String() string {
+	join := func(children []Condition, sep string) string {
+		parts := make([]string, len(children))
+		for i, child := range children {
+			parts[i] = child.String()
+		}
+		return "(" + strings.Join(parts, sep) + ")"
+	}
+	switch {
+	case c.Tag != "":
+		return c.Tag + " " + string(c.Op) + " " + strconv.FormatFloat(c.Value, 'g', -1, 64)
+	case len(c.All) > 0:
+		return join(c.All, " AND ")
+	case len(c.Any) > 0:
+		return join(c.Any, " OR ")
+	case c.Not != nil:
+		return "NOT " + c.Not.String()
+	}
+	return ""
+}
+
+// CompositeRule fires once its condition has held for For, e.g. "heater on AND flow == 0
+// for 2 minutes". Firing raises an alert and, when ActuatorTags is set, switches them
+// with Action. The rule re-arms once the condition stops holding.
+type CompositeRule struct {
+	Name      string        `json:"name"`
+	Condition Condition     `json:"condition"`
+	For       time.Duration `json:"for,omitempty"`
+	// Severity of the alert raised; defaults to warning
+	Severity     AlertSeverity `json:"severity,omitempty"`
+	ActuatorTags []string      `json:"actuator_tags,omitempty"`
+	Action       string        `json:"action,omitempty"`
+}
+
+// Validate checks the rule's condition and that actuators come with an action
+func (r CompositeRule) Validate() error {
+	if err := r.Condition.Validate(); err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if len(r.ActuatorTags) > 0 && r.Action == "" {
+		return fmt.Errorf("rule %s switches actuators but has no action", r.Name)
+	}
+	return nil
+}
+
+// ConditionResult is the outcome of evaluating a condition. Unknown means a reading was
+// missing or invalid; it spreads through AND, OR and NOT only where it could change the
+// outcome, and a rule never fires on it.
+type ConditionResult string
+
+const (
+	ConditionTrue    ConditionResult = "true"
+	ConditionFalse   ConditionResult = "false"
+	ConditionUnknown ConditionResult = "unknown"
+	// ConditionSkipped marks parts left unevaluated because an earlier part of an AND or
+	// OR already settled it
+	ConditionSkipped ConditionResult = "skipped"
+)
+
+// ConditionStatus reports how each part of a condition evaluated
+type ConditionStatus struct {
+	Condition string          `json:"condition"`
+	Result    ConditionResult `json:"result"`
+	// Value and Unit are the reading a comparison was made against
+	Value    *float64           `json:"value,omitempty"`
+	Unit     Unit               `json:"unit,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Children []*ConditionStatus `json:"children,omitempty"`
+}
+
+// CompositeRuleStatus is a composite rule's condition evaluated against the latest
+// readings
+type CompositeRuleStatus struct {
+	Name      string           `json:"name"`
+	Result    ConditionResult  `json:"result"`
+	For       time.Duration    `json:"for,omitempty"`
+	Condition *ConditionStatus `json:"condition"`
+	Timestamp time.Time        `json:"timestamp"`
+}
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// DegradedPolicy controls how a logical measurement behaves when too few probes remain
+// to reach quorum
+type DegradedPolicy string
+
+const (
+	// DegradedFailSafe reports the measurement as invalid so consumers fall back to
+	// their safe state
+	DegradedFailSafe DegradedPolicy = "fail_safe"
+	// DegradedTrustRemaining keeps using the sole remaining probe, flagged as degraded
+	DegradedTrustRemaining DegradedPolicy = "trust_remaining"
+)
+
+// LogicalMeasurement combines several redundant probes into a single voted value
+type LogicalMeasurement struct {
+	Name string `json:"name"`
+	// SensorTags lists the tags of the redundant probes
+	SensorTags []string `json:"sensor_tags"`
+	// Quorum is the minimum number of agreeing probes required for a trusted value;
+	// defaults to a simple majority of SensorTags
+	Quorum int `json:"quorum,omitempty"`
+	// MaxDeviation is how far a probe may be from the median and still agree with it
+	MaxDeviation float64 `json:"max_deviation"`
+	// MaxAge excludes readings older than this from the vote; zero disables the check
+	MaxAge   time.Duration  `json:"max_age,omitempty"`
+	Degraded DegradedPolicy `json:"degraded,omitempty"`
+}
+
+// Validate checks the measurement's probes can agree: MaxDeviation must be positive and
+// Quorum, once defaulted, between one and the number of probes
+func (m LogicalMeasurement) Validate() error {
+	if m.MaxDeviation <= 0 {
+		return fmt.Errorf("logical measurement %s has max_deviation %v, want more than 0", m.Name, m.MaxDeviation)
+	}
+	quorum := m.Quorum
+	if quorum == 0 {
+		quorum = len(m.SensorTags)/2 + 1
+	}
+	if quorum < 1 || quorum > len(m.SensorTags) {
+		return fmt.Errorf("logical measurement %s has quorum %d, want 1 to %d", m.Name, quorum, len(m.SensorTags))
+	}
+	return nil
+}
+
+// VoteResult is the outcome of voting across a logical measurement's probes
+type VoteResult struct {
+	Value     float64   `json:"value"`
+	Unit      Unit      `json:"unit,omitempty"`
+	Valid     bool      `json:"valid"`
+	Degraded  bool      `json:"degraded"`
+	Voters    []string  `json:"voters,omitempty"`
+	Outvoted  []string  `json:"outvoted,omitempty"`
+	Missing   []string  `json:"missing,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RotationPolicy controls which member of an actuator group starts first
+type RotationPolicy string
+
+const (
+	// RotationNone always starts members in the configured order
+	RotationNone RotationPolicy = "none"
+	// RotationRoundRobin advances the first member by one on every start so wear is
+	// spread across the group
+	RotationRoundRobin RotationPolicy = "round_robin"
+)
+
+// ActuatorGroup switches several actuators together, e.g. a bank of return pumps or lights
+type ActuatorGroup struct {
+	Name         string   `json:"name"`
+	ActuatorTags []string `json:"actuator_tags"`
+	// StaggerOffset is the delay between starting consecutive members, limiting inrush
+	// current on a shared circuit
+	StaggerOffset time.Duration  `json:"stagger_offset,omitempty"`
+	Rotation      RotationPolicy `json:"rotation,omitempty"`
+}
+
+// ActuatorGroupStatus is an actuator group with the order its members will next start in
+type ActuatorGroupStatus struct {
+	ActuatorGroup
+	NextOrder []string `json:"next_order"`
+}
+
+// ScenePolicy controls what a scene does when some of its actuators cannot be commanded,
+// typically because the driver or device behind them is offline
+type ScenePolicy string
+
+const (
+	// SceneAbortAll checks every actuator is reachable before sending anything, and
+	// sends nothing if one is not
+	SceneAbortAll ScenePolicy = "abort_all"
+	// SceneContinue sends every command it can, skipping unreachable actuators
+	SceneContinue ScenePolicy = "continue"
+	// SceneRollback checks every actuator is reachable like SceneAbortAll, and if a
+	// command still fails switches the actuators already commanded back to how they were
+	SceneRollback ScenePolicy = "rollback"
+)
+
+// SceneCommand is one actuator's part in a scene
+type SceneCommand struct {
+	ActuatorTag string          `json:"actuator_tag"`
+	Command     ActuatorCommand `json:"command"`
+}
+
+// Scene applies a set of commands together, e.g. a "night mode" dimming the lights and
+// slowing the return pumps, which may span actuators of several drivers
+type Scene struct {
+	Name     string         `json:"name"`
+	Commands []SceneCommand `json:"commands"`
+	// Policy defaults to SceneAbortAll
+	Policy ScenePolicy `json:"policy,omitempty"`
+	// MaxStatusAge treats an actuator whose last status is older than this as
+	// unreachable; zero disables the check
+	MaxStatusAge time.Duration `json:"max_status_age,omitempty"`
+	// At lists the times of day, as HH:MM in the worker's time zone, the worker applies
+	// the scene
+	At []string `json:"at,omitempty"`
+}
+
+// SceneTimeLayout is the layout of a scene's times of day
+const SceneTimeLayout = "15:04"
+
+// Validate checks the scene has commands, a known policy and valid times of day
+func (s Scene) Validate() error {
+	if len(s.Commands) == 0 {
+		return fmt.Errorf("scene %s has no commands", s.Name)
+	}
+	switch s.Policy {
+	case "", SceneAbortAll, SceneContinue, SceneRollback:
+	default:
+		return fmt.Errorf("scene %s has unknown policy %q", s.Name, s.Policy)
+	}
+	for _, at := range s.At {
+		if _, err := time.Parse(SceneTimeLayout, at); err != nil {
+			return fmt.Errorf("scene %s has invalid time %q, want HH:MM", s.Name, at)
+		}
+	}
+	return nil
+}
+
+// SceneResult is the outcome of applying a scene
+type SceneResult struct {
+	Scene string `json:"scene"`
+	// Applied lists the actuators left in the scene's state
+	Applied []string `json:"applied"`
+	// Unreachable lists the actuators which failed the pre-dispatch check
+	Unreachable []string `json:"unreachable,omitempty"`
+	// Failed lists the actuators whose commands were sent but failed
+	Failed []string `json:"failed,omitempty"`
+	// RolledBack lists the actuators switched back after a failure
+	RolledBack []string `json:"rolled_back,omitempty"`
+}
+
+// BudgetPolicy controls what happens to a command that would take a circuit over its
+// power budget
+type BudgetPolicy string
+
+const (
+	// BudgetReject refuses the command straight away
+	BudgetReject BudgetPolicy = "reject"
+	// BudgetDefer holds the command until the circuit has room for it, refusing it if
+	// none frees up within MaxDefer
+	BudgetDefer BudgetPolicy = "defer"
+)
+
+// PowerBudget caps the total draw of the actuators on one circuit, such as everything
+// plugged into a single GFCI outlet. The budget is MaxWatts, or MaxAmps at Volts.
+type PowerBudget struct {
+	Circuit  string  `json:"circuit"`
+	MaxWatts float64 `json:"max_watts,omitempty"`
+	MaxAmps  float64 `json:"max_amps,omitempty"`
+	Volts    float64 `json:"volts,omitempty"`
+	// PowerTags are the sensors measuring the circuit's draw, in watts; their latest
+	// readings are summed
+	PowerTags []string `json:"power_tags"`
+	// Loads is the rated draw in watts of each actuator on the circuit, by tag, which
+	// is added to the measured draw when a command would switch it on
+	Loads map[string]float64 `json:"loads"`
+	// Policy defaults to BudgetReject
+	Policy   BudgetPolicy  `json:"policy,omitempty"`
+	MaxDefer time.Duration `json:"max_defer,omitempty"`
+}
+
+// Limit returns the budget in watts
+func (b PowerBudget) Limit() float64 {
+	if b.MaxWatts > 0 {
+		return b.MaxWatts
+	}
+	return b.MaxAmps * b.Volts
+}
+
+// PumpRotation alternates a set of redundant pumps so their runtime hours stay even
+type PumpRotation struct {
+	Name     string   `json:"name"`
+	PumpTags []string `json:"pump_tags"`
+	// FlowTag identifies the flow sensor downstream of the pumps
+	FlowTag string `json:"flow_tag"`
+	// MinFlow is the lowest flow reading accepted as proof the pump is running
+	MinFlow float64 `json:"min_flow"`
+	// RotateAfter is how long the active pump runs before handing over to the standby
+	// with the least runtime
+	RotateAfter time.Duration `json:"rotate_after"`
+	// FlowSettle is how long to wait after starting a pump before checking flow
+	FlowSettle time.Duration `json:"flow_settle,omitempty"`
+}
+
+// DryRunRule detects a pump that is switched on but not moving water, indicating it has
+// run dry or is blocked. Either PowerTag or FlowTag (or both) must be set.
+type DryRunRule struct {
+	Name    string `json:"name"`
+	PumpTag string `json:"pump_tag"`
+	// PowerTag and MinPower flag the pump when its draw falls below MinPower
+	PowerTag string  `json:"power_tag,omitempty"`
+	MinPower float64 `json:"min_power,omitempty"`
+	// FlowTag and MinFlow flag the pump when flow falls below MinFlow
+	FlowTag string  `json:"flow_tag,omitempty"`
+	MinFlow float64 `json:"min_flow,omitempty"`
+	// For is how long the condition must hold before the pump is shut off
+	For time.Duration `json:"for"`
+}
+
+// SPCRule raises an alert when a sensor goes out of statistical control, judged against a
+// control chart of its own rolling baseline rather than fixed limits. It suits parameters
+// like conductivity whose normal value drifts seasonally.
+type SPCRule struct {
+	Name      string `json:"name"`
+	SensorTag string `json:"sensor_tag"`
+	// Baseline is how far back readings are kept for the chart; the mean and standard
+	// deviation come from the readings older than those being checked
+	Baseline time.Duration `json:"baseline"`
+	// Sigma is the control limit in standard deviations from the mean; defaults to 3
+	Sigma float64 `json:"sigma,omitempty"`
+	// RunRules adds the Western Electric run rules, catching shifts and drifts that stay
+	// inside the control limits
+	RunRules bool `json:"run_rules,omitempty"`
+	// MinBaseline is the fewest baseline readings needed to alert; defaults to 30
+	MinBaseline int `json:"min_baseline,omitempty"`
+}
+
+// RateOfChangeRule raises an alert when a sensor changes faster than allowed, e.g. a
+// temperature rising more than 1°C per 10 minutes or a water level dropping more than
+// 2cm per hour. It catches failures long before absolute limits are crossed. Set MaxRise,
+// MaxFall or both.
+type RateOfChangeRule struct {
+	Name      string `json:"name"`
+	SensorTag string `json:"sensor_tag"`
+	// MaxRise and MaxFall are the fastest allowed changes, in the sensor's unit per Per
+	MaxRise float64       `json:"max_rise,omitempty"`
+	MaxFall float64       `json:"max_fall,omitempty"`
+	Per     time.Duration `json:"per"`
+	// Window is how far back readings are fitted to measure the rate; defaults to Per
+	Window time.Duration `json:"window,omitempty"`
+}
+
+// LeakResponse describes what to shut down when any of a subsystem's leak sensors
+// detects water. Valves are switched off, so they should be normally-closed.
+type LeakResponse struct {
+	Subsystem string   `json:"subsystem"`
+	LeakTags  []string `json:"leak_tags"`
+	ValveTags []string `json:"valve_tags,omitempty"`
+	PumpTags  []string `json:"pump_tags,omitempty"`
+}
+
+// EventReaction switches actuators as soon as a device reports a matching state, e.g.
+// turning the top-off pump off when the high-level float switch closes
+type EventReaction struct {
+	Name string `json:"name"`
+	// Tag is the sensor or actuator whose events trigger the reaction
+	Tag string `json:"tag"`
+	// Field restricts the reaction to one event field, e.g. "state"; empty matches any
+	Field string `json:"field,omitempty"`
+	// Value is the reading that fires the reaction; it fires once per transition to it
+	Value        float64  `json:"value"`
+	ActuatorTags []string `json:"actuator_tags"`
+	Action       string   `json:"action"`
+}
+
+// Rule kinds name the control rule types in a RuleRef
+const (
+	RuleKindLogicalMeasurement = "logical_measurement"
+	RuleKindActuatorGroup      = "actuator_group"
+	RuleKindPumpRotation       = "pump_rotation"
+	RuleKindDryRun             = "dry_run"
+	RuleKindLeakResponse       = "leak_response"
+	RuleKindEventReaction      = "event_reaction"
+	RuleKindSPC                = "spc"
+	RuleKindRateOfChange       = "rate_of_change"
+	RuleKindComposite          = "composite"
+	RuleKindScene              = "scene"
+)
+
+// RuleRef identifies a configured control rule and the sensor and actuator tags it
+// depends on
+type RuleRef struct {
+	Kind string   `json:"kind"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// Ref returns the measurement's dependencies
+func (m LogicalMeasurement) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindLogicalMeasurement, Name: m.Name, Tags: m.SensorTags}
+}
+
+// Ref returns the group's dependencies
+func (g ActuatorGroup) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindActuatorGroup, Name: g.Name, Tags: g.ActuatorTags}
+}
+
+// Ref returns the rotation's dependencies
+func (r PumpRotation) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindPumpRotation, Name: r.Name, Tags: nonEmpty(append(append([]string{}, r.PumpTags...), r.FlowTag))}
+}
+
+// Ref returns the rule's dependencies
+func (r DryRunRule) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindDryRun, Name: r.Name, Tags: nonEmpty([]string{r.PumpTag, r.PowerTag, r.FlowTag})}
+}
+
+// Ref returns the rule's dependencies
+func (r SPCRule) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindSPC, Name: r.Name, Tags: nonEmpty([]string{r.SensorTag})}
+}
+
+// Ref returns the rule's dependencies
+func (r RateOfChangeRule) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindRateOfChange, Name: r.Name, Tags: nonEmpty([]string{r.SensorTag})}
+}
+
+// Ref returns the rule's dependencies
+func (r CompositeRule) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindComposite, Name: r.Name, Tags: append(r.Condition.Tags(), r.ActuatorTags...)}
+}
+
+// Ref returns the scene's dependencies
+func (s Scene) Ref() RuleRef {
+	tags := make([]string, 0, len(s.Commands))
+	for _, c := range s.Commands {
+		tags = append(tags, c.ActuatorTag)
+	}
+	return RuleRef{Kind: RuleKindScene, Name: s.Name, Tags: tags}
+}
+
+// Ref returns the response's dependencies; it is named after its subsystem
+func (l LeakResponse) Ref() RuleRef {
+	tags := append(append(append([]string{}, l.LeakTags...), l.ValveTags...), l.PumpTags...)
+	return RuleRef{Kind: RuleKindLeakResponse, Name: l.Subsystem, Tags: tags}
+}
+
+// Ref returns the reaction's dependencies
+func (r EventReaction) Ref() RuleRef {
+	return RuleRef{Kind: RuleKindEventReaction, Name: r.Name, Tags: nonEmpty(append([]string{r.Tag}, r.ActuatorTags...))}
+}
+
+func nonEmpty(tags []string) []string {
+	out := tags[:0]
+	for _, tag := range tags {
+		if tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// BrokenReference records a rule that depended on a tag whose device, sensor or actuator
+// was deleted. Rules with broken references are disabled until the tag resolves again or
+// the references are cleared.
+type BrokenReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+	// Resource describes what was deleted, e.g. "device sump-dev"
+	Resource   string    `json:"resource"`
+	DetectedAt time.Time `json:"detected_at"`
+}
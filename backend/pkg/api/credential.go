@@ -0,0 +1,30 @@
+package api
+
+import "time"
+
+// Credential describes a secret a device needs, such as its RTSP password or Shelly
+// auth password. The secret itself is encrypted at rest and never returned by the API;
+// drivers and rules refer to it by ID.
+type Credential struct {
+	ID       string `json:"id"`
+	DeviceID string `json:"device_id"`
+	Name     string `json:"name"`
+	// KeyID identifies the master key the secret was sealed with
+	KeyID     string    `json:"key_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SealedCredential is a credential with its envelope-encrypted secret, as stored. The
+// secret is encrypted with a data key of its own, and only the data key is encrypted
+// with the master key.
+type SealedCredential struct {
+	Credential
+	WrappedKey []byte `json:"-"`
+	Ciphertext []byte `json:"-"`
+}
+
+// SetCredentialRequest carries a credential's secret on its way in
+type SetCredentialRequest struct {
+	Secret string `json:"secret"`
+}
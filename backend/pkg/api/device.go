@@ -17,16 +17,29 @@ type Device struct {
 	Actuators   []*Actuator       `json:"actuators"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
 	Tags        []string          `json:"tags,omitempty"`
+	ExternalID  string            `json:"external_id,omitempty"`
+	// Version counts the changes to the entity; updates must carry the version they
+	// were based on, and are refused if it has since changed
+	Version int64 `json:"version"`
 }
 
-// DefaultTag returns the default hierarchical tag for this device
+// DeviceBatchResult is the outcome of creating one device of a batch
+type DeviceBatchResult struct {
+	ID string `json:"id"`
+	// Device is the created device, with the external IDs and version it was given
+	Device *Device `json:"device,omitempty"`
+	// Error says why the device was not created; empty if it was
+	Error string `json:"error,omitempty"`
+}
+
+// DefaultTag returns the built-in default hierarchical tag for this device
 func (d *Device) DefaultTag() string {
 	return "device." + d.ID
 }
 
-// EnsureDefaultTag ensures the device has its default tag
-func (d *Device) EnsureDefaultTag() {
-	defaultTag := d.DefaultTag()
+// EnsureDefaultTag ensures the device has its default tag under templates
+func (d *Device) EnsureDefaultTag(templates TagTemplates) {
+	defaultTag := templates.DeviceTag(d)
 	hasDefault := false
 	for _, tag := range d.Tags {
 		if tag == defaultTag {
@@ -80,3 +93,20 @@ func (d *Device) GetActuatorsByType(actuatorType ActuatorType) []*Actuator {
 	}
 	return result
 }
+
+// DeletePreview reports what deleting a device would take with it, so its blast radius
+// can be reviewed before confirming
+type DeletePreview struct {
+	DeviceID  string `json:"device_id"`
+	Sensors   int    `json:"sensors"`
+	Actuators int    `json:"actuators"`
+	// Readings is how many stored readings are deleted along with the device's sensors
+	Readings int64 `json:"readings"`
+	// Tags lists the device, sensor and actuator tags that will stop resolving
+	Tags []string `json:"tags"`
+	// Aliases lists aliases that will be left pointing at one of Tags
+	Aliases []TagAlias `json:"aliases,omitempty"`
+	// Rules lists configured rules depending on one of Tags, directly or via an alias;
+	// each is trimmed to the affected tags
+	Rules []RuleRef `json:"rules,omitempty"`
+}
@@ -4,7 +4,8 @@ import "time"
 
 // DiscoveryOptions configures device discovery behavior
 type DiscoveryOptions struct {
-	// Add any options needed for device discovery, e.g. timeouts, concurrency limits, etc.
+	// Retry overrides the worker's retry policy for the discovery activity
+	Retry *RetryPolicy `json:"retry,omitempty"`
 }
 
 type StatusOptions struct {
@@ -14,4 +15,12 @@ type StatusOptions struct {
 // DiscoveryResult contains the results of device discovery
 type DiscoveryResult struct {
 	DiscoveredTags []string `json:"discovered_tags"`
+	// Errors lists devices which answered but could not be added
+	Errors []DiscoveryError `json:"errors,omitempty"`
+}
+
+// DiscoveryError is why a device which answered discovery was not added
+type DiscoveryError struct {
+	DeviceID string `json:"device_id"`
+	Error    string `json:"error"`
 }
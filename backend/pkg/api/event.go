@@ -0,0 +1,9 @@
+package api
+
+// ResourceEvent is a state change pushed by a device for one of its sensors or actuators
+type ResourceEvent struct {
+	DeviceID   string        `json:"device_id"`
+	ResourceID string        `json:"resource_id"`
+	Field      string        `json:"field"` // e.g. "output", "state", "apower"
+	Reading    SensorReading `json:"reading"`
+}
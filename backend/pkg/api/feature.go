@@ -0,0 +1,38 @@
+package api
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// FeatureFlag rolls an experimental feature, such as the rules engine, out to a share of
+// clients. Each client lands in the same bucket of a flag every time, so raising Percent
+// only ever adds clients.
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Percent of clients the feature is on for, from 0 (off) to 100 (everyone)
+	Percent   int       `json:"percent"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EnabledFor reports whether the feature is on for the client identified by client
+func (f *FeatureFlag) EnabledFor(client string) bool {
+	if f.Percent >= 100 {
+		return true
+	}
+	if f.Percent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + "/" + client))
+	return int(h.Sum32()%100) < f.Percent
+}
+
+// AdminConfig is the server's effective configuration, with secrets redacted
+type AdminConfig struct {
+	Command string `json:"command"`
+	Release string `json:"release,omitempty"`
+	// Flags holds every command line flag by name, whether set or defaulted
+	Flags map[string]string `json:"flags"`
+}
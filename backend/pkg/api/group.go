@@ -0,0 +1,22 @@
+package api
+
+import "errors"
+
+// Group is a named set of devices, such as a grow bed or a quarantine tank, with its own
+// metadata. Unlike tags, groups are managed as a whole and a device can be in several.
+type Group struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// DeviceIDs are the group's members, ordered by ID
+	DeviceIDs []string `json:"device_ids"`
+}
+
+// Validate checks the group has an ID and a name
+func (g *Group) Validate() error {
+	if g.ID == "" || g.Name == "" {
+		return errors.New("id and name are required")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import "time"
+
+// Health factors contributing to a subsystem's score
+const (
+	// HealthFactorDeviation scores the latest readings against their sensors' target ranges
+	HealthFactorDeviation = "deviation"
+	// HealthFactorStaleSensors scores the share of sensors reporting recently
+	HealthFactorStaleSensors = "stale_sensors"
+)
+
+// HealthScore is an at-a-glance 0-100 rating of the whole system, with the per-subsystem
+// scores it is built from. Score is null when there are no sensors to rate.
+type HealthScore struct {
+	Score       *float64          `json:"score"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Subsystems  []SubsystemHealth `json:"subsystems"`
+}
+
+// SubsystemHealth rates the sensors of the devices sharing a subsystem type. Score is the
+// weighted mean of the factors which apply.
+type SubsystemHealth struct {
+	Subsystem string         `json:"subsystem"`
+	Score     float64        `json:"score"`
+	Sensors   int            `json:"sensors"`
+	Factors   []HealthFactor `json:"factors"`
+}
+
+// HealthFactor is one input to a subsystem's score, listing the sensors that cost points
+type HealthFactor struct {
+	Name         string              `json:"name"`
+	Weight       float64             `json:"weight"`
+	Score        float64             `json:"score"`
+	Contributors []HealthContributor `json:"contributors,omitempty"`
+}
+
+// HealthContributor is a sensor lowering a factor's score
+type HealthContributor struct {
+	DeviceID string      `json:"device_id"`
+	SensorID string      `json:"sensor_id"`
+	Level    TargetLevel `json:"level,omitempty"`
+	Value    *float64    `json:"value,omitempty"`
+	// LastSeen is when a stale sensor last reported, if ever
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"errors"
+	"time"
+)
+
+// ReadingLabel marks the readings taken in [Start, End) with a key/value label, such as
+// experiment=reduced-photoperiod, so they can be grouped and compared later. A label
+// without a device covers every sensor, and one without a sensor covers each of its
+// device's sensors.
+type ReadingLabel struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	SensorID  string    `json:"sensor_id,omitempty"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the label has a key and value, a window, and a device for its sensor
+func (l *ReadingLabel) Validate() error {
+	switch {
+	case l.Key == "" || l.Value == "":
+		return errors.New("key and value are required")
+	case l.Start.IsZero() || !l.Start.Before(l.End):
+		return errors.New("start must be before end")
+	case l.SensorID != "" && l.DeviceID == "":
+		return errors.New("device_id is required with sensor_id")
+	}
+	return nil
+}
+
+// Covers reports whether the label applies to deviceID/sensorID's readings
+func (l *ReadingLabel) Covers(deviceID, sensorID string) bool {
+	return (l.DeviceID == "" || l.DeviceID == deviceID) && (l.SensorID == "" || l.SensorID == sensorID)
+}
+
+// LabelGroup summarises a sensor's readings under one value of a label. Stats spans the
+// first labelled window's start to the last one's end, counting overlapping windows once.
+type LabelGroup struct {
+	Value   string      `json:"value"`
+	Windows int         `json:"windows"`
+	Stats   WindowStats `json:"stats"`
+}
+
+// LabelComparison compares a sensor's readings across the values of a label, ordered by
+// value
+type LabelComparison struct {
+	DeviceID string       `json:"device_id"`
+	SensorID string       `json:"sensor_id"`
+	Key      string       `json:"key"`
+	Unit     Unit         `json:"unit,omitempty"`
+	Groups   []LabelGroup `json:"groups"`
+}
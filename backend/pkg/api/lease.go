@@ -0,0 +1,13 @@
+package api
+
+import "time"
+
+// Lease grants one worker exclusive ownership of a named responsibility until ExpiresAt.
+// The holder renews it well before then; if the holder dies, another worker takes over
+// once it expires.
+type Lease struct {
+	Name       string    `json:"name"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,9 @@
+package api
+
+// LogLevels are the log levels of one server process
+type LogLevels struct {
+	// Level is the default level, set by --log-level
+	Level string `json:"level"`
+	// Components maps a component, such as storer or shelly, to its own level
+	Components map[string]string `json:"components"`
+}
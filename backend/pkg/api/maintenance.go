@@ -0,0 +1,13 @@
+package api
+
+import "time"
+
+// MaintenanceMode is set by an administrator while backups or migrations run. While
+// ReadOnly is set the API rejects every change, and the dashboard shows Message as a
+// banner.
+type MaintenanceMode struct {
+	ReadOnly bool   `json:"read_only"`
+	Message  string `json:"message,omitempty"`
+	// UpdatedAt is when the mode was last changed; zero if it never has been
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+}
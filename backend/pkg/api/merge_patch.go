@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// DeviceMergePatch is a JSON Merge Patch (RFC 7396) of one device: the fields it names
+// are changed and the rest are left as they are. Unlike DevicePatch, which applies to
+// every device a selector matches, it may change any editable field.
+type DeviceMergePatch struct {
+	Driver      *DriverName
+	Name        *string
+	Description *string
+	Metadata    MetadataPatch
+	// Tags replaces the device's tags; the default tag is kept
+	Tags *[]string
+	// Version, when set, is the version the update was based on, and the update is
+	// refused if the device has since changed
+	Version int64
+}
+
+// UnmarshalJSON decodes a merge patch object. A null description or tags clears it;
+// null metadata removes every key, and a null metadata value removes that key.
+func (u *DeviceMergePatch) UnmarshalJSON(data []byte) error {
+	*u = DeviceMergePatch{}
+	return decodeMergePatch(data, map[string]func(json.RawMessage) error{
+		"driver":      patchRequired(&u.Driver),
+		"name":        patchRequired(&u.Name),
+		"description": patchOptional(&u.Description),
+		"metadata":    u.Metadata.decode,
+		"tags":        patchTags(&u.Tags),
+		"version":     patchVersion(&u.Version),
+	})
+}
+
+// Apply changes the fields of dev the update names
+func (u *DeviceMergePatch) Apply(dev *Device) {
+	if u.Driver != nil {
+		dev.Driver = *u.Driver
+	}
+	if u.Name != nil {
+		dev.Name = *u.Name
+	}
+	if u.Description != nil {
+		dev.Description = *u.Description
+	}
+	dev.Metadata = u.Metadata.apply(dev.Metadata)
+	if u.Tags != nil {
+		dev.Tags = slices.Clone(*u.Tags)
+	}
+}
+
+// SensorMergePatch is a JSON Merge Patch of one sensor, as DeviceMergePatch is of a
+// device
+type SensorMergePatch struct {
+	Name       *string
+	SensorType *SensorType
+	Metadata   MetadataPatch
+	Tags       *[]string
+	Version    int64
+}
+
+// UnmarshalJSON decodes a merge patch object, as DeviceMergePatch does
+func (u *SensorMergePatch) UnmarshalJSON(data []byte) error {
+	*u = SensorMergePatch{}
+	return decodeMergePatch(data, map[string]func(json.RawMessage) error{
+		"name":        patchRequired(&u.Name),
+		"sensor_type": patchRequired(&u.SensorType),
+		"metadata":    u.Metadata.decode,
+		"tags":        patchTags(&u.Tags),
+		"version":     patchVersion(&u.Version),
+	})
+}
+
+// Apply changes the fields of sensor the update names
+func (u *SensorMergePatch) Apply(sensor *Sensor) {
+	if u.Name != nil {
+		sensor.Name = *u.Name
+	}
+	if u.SensorType != nil {
+		sensor.SensorType = *u.SensorType
+	}
+	sensor.Metadata = u.Metadata.apply(sensor.Metadata)
+	if u.Tags != nil {
+		sensor.Tags = slices.Clone(*u.Tags)
+	}
+}
+
+// ActuatorMergePatch is a JSON Merge Patch of one actuator, as DeviceMergePatch is of a
+// device
+type ActuatorMergePatch struct {
+	Name         *string
+	ActuatorType *ActuatorType
+	Metadata     MetadataPatch
+	Tags         *[]string
+	Version      int64
+}
+
+// UnmarshalJSON decodes a merge patch object, as DeviceMergePatch does
+func (u *ActuatorMergePatch) UnmarshalJSON(data []byte) error {
+	*u = ActuatorMergePatch{}
+	return decodeMergePatch(data, map[string]func(json.RawMessage) error{
+		"name":          patchRequired(&u.Name),
+		"actuator_type": patchRequired(&u.ActuatorType),
+		"metadata":      u.Metadata.decode,
+		"tags":          patchTags(&u.Tags),
+		"version":       patchVersion(&u.Version),
+	})
+}
+
+// Apply changes the fields of actuator the update names
+func (u *ActuatorMergePatch) Apply(actuator *Actuator) {
+	if u.Name != nil {
+		actuator.Name = *u.Name
+	}
+	if u.ActuatorType != nil {
+		actuator.ActuatorType = *u.ActuatorType
+	}
+	actuator.Metadata = u.Metadata.apply(actuator.Metadata)
+	if u.Tags != nil {
+		actuator.Tags = slices.Clone(*u.Tags)
+	}
+}
+
+// MetadataPatch merges into an entity's metadata. Clear removes every key first, then
+// each key in Set is set to its value, or removed if the value is nil.
+type MetadataPatch struct {
+	Clear bool
+	Set   map[string]*string
+}
+
+func (p *MetadataPatch) decode(raw json.RawMessage) error {
+	if isNull(raw) {
+		p.Clear = true
+		return nil
+	}
+	return json.Unmarshal(raw, &p.Set)
+}
+
+// apply returns metadata with the patch merged in, leaving metadata as it was
+func (p MetadataPatch) apply(metadata map[string]string) map[string]string {
+	if p.Clear {
+		return nil
+	}
+	if len(p.Set) == 0 {
+		return metadata
+	}
+	metadata = maps.Clone(metadata)
+	for k, v := range p.Set {
+		if v == nil {
+			delete(metadata, k)
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[k] = *v
+	}
+	return metadata
+}
+
+// decodeMergePatch decodes a merge patch object, passing each member to the decoder for
+// its name; members without one cannot be patched
+func decodeMergePatch(data []byte, fields map[string]func(json.RawMessage) error) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	if members == nil {
+		return errors.New("merge patch must be an object")
+	}
+	for name, raw := range members {
+		decode, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("%s cannot be patched", name)
+		}
+		if err := decode(raw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return string(raw) == "null"
+}
+
+// patchRequired decodes a member which cannot be cleared
+func patchRequired[T ~string](dst **T) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		var v T
+		if isNull(raw) {
+			return errors.New("cannot be cleared")
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if v == "" {
+			return errors.New("must not be empty")
+		}
+		*dst = &v
+		return nil
+	}
+}
+
+// patchOptional decodes a member which null clears
+func patchOptional(dst **string) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		var v string
+		if !isNull(raw) {
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return err
+			}
+		}
+		*dst = &v
+		return nil
+	}
+}
+
+// patchTags decodes a replacement list of tags, which null empties
+func patchTags(dst **[]string) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		tags := []string{}
+		if !isNull(raw) {
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return err
+			}
+		}
+		if slices.Contains(tags, "") {
+			return errors.New("must not hold an empty tag")
+		}
+		*dst = &tags
+		return nil
+	}
+}
+
+func patchVersion(dst *int64) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		if isNull(raw) {
+			return errors.New("cannot be cleared")
+		}
+		if err := json.Unmarshal(raw, dst); err != nil {
+			return err
+		}
+		if *dst <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	}
+}
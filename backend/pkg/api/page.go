@@ -0,0 +1,22 @@
+package api
+
+// DevicePage is a page of devices. NextCursor fetches the next page; it is empty on the
+// last page.
+type DevicePage struct {
+	Devices    []*Device `json:"devices"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// SensorPage is a page of sensors. NextCursor fetches the next page; it is empty on the
+// last page.
+type SensorPage struct {
+	Sensors    []*Sensor `json:"sensors"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// ActuatorPage is a page of actuators. NextCursor fetches the next page; it is empty on
+// the last page.
+type ActuatorPage struct {
+	Actuators  []*Actuator `json:"actuators"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
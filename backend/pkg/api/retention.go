@@ -0,0 +1,12 @@
+package api
+
+import "time"
+
+// RetentionPolicy sets how long readings of sensors of one type are kept. Readings of
+// types without a policy are kept forever.
+type RetentionPolicy struct {
+	SensorType SensorType `json:"sensor_type"`
+	// Retention is how old a reading gets before it is deleted
+	Retention time.Duration `json:"retention"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
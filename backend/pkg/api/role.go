@@ -0,0 +1,28 @@
+package api
+
+// Role is a level of access matching the API's: viewers may read, operators may also
+// make the changes an X-User may, such as acknowledging alerts and commanding actuators,
+// and admins may also do what the admin token allows
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// Allows reports whether r may do what required may; an unknown role allows nothing
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
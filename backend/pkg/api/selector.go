@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DeviceSelector picks out devices by their driver, tags and metadata, such as every
+// Shelly device at one site. A device must match every term set.
+type DeviceSelector struct {
+	Driver DriverName
+	// Tags are tags the device must carry; one ending in * matches as a prefix
+	Tags     []string
+	Metadata map[string]string
+}
+
+// ParseDeviceSelector parses a comma separated list of terms: driver=<driver>,
+// tag=<tag> (tag=<prefix>* to match a prefix) and metadata.<key>=<value>, such as
+// driver=shelly,metadata.site=north. At least one term is required, so an empty
+// selector can't select every device by mistake.
+func ParseDeviceSelector(s string) (DeviceSelector, error) {
+	var sel DeviceSelector
+	if strings.TrimSpace(s) == "" {
+		return sel, errors.New("selector is required")
+	}
+	for _, term := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(term), "=")
+		if !ok || value == "" {
+			return sel, fmt.Errorf("selector term %q must be key=value", term)
+		}
+		switch {
+		case key == "driver":
+			if sel.Driver != "" && sel.Driver != DriverName(value) {
+				return sel, errors.New("selector has more than one driver")
+			}
+			sel.Driver = DriverName(value)
+		case key == "tag":
+			sel.Tags = append(sel.Tags, value)
+		case strings.HasPrefix(key, "metadata.") && key != "metadata.":
+			if sel.Metadata == nil {
+				sel.Metadata = make(map[string]string)
+			}
+			sel.Metadata[strings.TrimPrefix(key, "metadata.")] = value
+		default:
+			return sel, fmt.Errorf("unknown selector key %q", key)
+		}
+	}
+	return sel, nil
+}
+
+// Matches reports whether dev matches every term of the selector
+func (s DeviceSelector) Matches(dev *Device) bool {
+	if s.Driver != "" && dev.Driver != s.Driver {
+		return false
+	}
+	for _, tag := range s.Tags {
+		prefix, isPrefix := strings.CutSuffix(tag, "*")
+		found := slices.ContainsFunc(dev.Tags, func(t string) bool {
+			return t == tag || isPrefix && strings.HasPrefix(t, prefix)
+		})
+		if !found {
+			return false
+		}
+	}
+	for k, v := range s.Metadata {
+		if got, ok := dev.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DevicePatch changes the metadata and tags of devices, leaving the rest of each as it
+// is, so one patch can apply to many devices
+type DevicePatch struct {
+	// Metadata sets each key to its value; a null value removes the key
+	Metadata   map[string]*string `json:"metadata,omitempty"`
+	AddTags    []string           `json:"add_tags,omitempty"`
+	RemoveTags []string           `json:"remove_tags,omitempty"`
+}
+
+// Validate checks the patch changes something
+func (p *DevicePatch) Validate() error {
+	if len(p.Metadata) == 0 && len(p.AddTags) == 0 && len(p.RemoveTags) == 0 {
+		return errors.New("patch must set metadata, add_tags or remove_tags")
+	}
+	for _, tag := range p.AddTags {
+		if tag == "" {
+			return errors.New("add_tags must not hold an empty tag")
+		}
+	}
+	return nil
+}
+
+// Apply patches dev, reporting whether it changed. Tags are removed before they are
+// added, so a tag in both ends up on the device.
+func (p *DevicePatch) Apply(dev *Device) bool {
+	metadata := maps.Clone(dev.Metadata)
+	for k, v := range p.Metadata {
+		if v == nil {
+			delete(metadata, k)
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[k] = *v
+	}
+	tags := slices.DeleteFunc(slices.Clone(dev.Tags), func(t string) bool {
+		return slices.Contains(p.RemoveTags, t)
+	})
+	for _, tag := range p.AddTags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if maps.Equal(metadata, dev.Metadata) && slices.Equal(tags, dev.Tags) {
+		return false
+	}
+	dev.Metadata, dev.Tags = metadata, tags
+	return true
+}
@@ -15,6 +15,8 @@ const (
 	SensorTypeDissolvedOxygen SensorType = "dissolved_oxygen"
 	SensorTypeBoolean         SensorType = "boolean"
 	SensorTypeVolume          SensorType = "volume"
+	SensorTypeLeak            SensorType = "leak"
+	SensorTypeAmmonia         SensorType = "ammonia"
 )
 
 // Sensor provides a base implementation for sensors with tag support
@@ -25,12 +27,20 @@ type Sensor struct {
 	SensorType SensorType        `json:"sensor_type"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
 	Tags       []string          `json:"tags,omitempty"`
+	ExternalID string            `json:"external_id,omitempty"`
+	// Version counts the changes to the entity; updates must carry the version they
+	// were based on, and are refused if it has since changed
+	Version int64 `json:"version"`
 }
 
 func (s *Sensor) GetID() string {
 	return s.ID
 }
 
+func (s *Sensor) GetDeviceID() string {
+	return s.DeviceID
+}
+
 func (s *Sensor) GetName() string {
 	return s.Name
 }
@@ -0,0 +1,46 @@
+package api
+
+// SessionMessageType identifies a message on the frontend session WebSocket
+type SessionMessageType string
+
+const (
+	// SessionCommand is sent by a client to command an actuator
+	SessionCommand SessionMessageType = "command"
+	// SessionCommandProgress reports a command's progress back to the client which sent it
+	SessionCommandProgress SessionMessageType = "command_progress"
+)
+
+// CommandStage is how far an actuator command has progressed. A command moves from
+// queued to sent to confirmed, or ends failed at any point.
+type CommandStage string
+
+const (
+	// CommandStageQueued means the server accepted the command and it is waiting behind
+	// the session's earlier commands
+	CommandStageQueued CommandStage = "queued"
+	// CommandStageSent means the command was handed to the device's driver
+	CommandStageSent CommandStage = "sent"
+	// CommandStageConfirmed means the device reported its new state
+	CommandStageConfirmed CommandStage = "confirmed"
+	CommandStageFailed    CommandStage = "failed"
+)
+
+// SessionRequest is a message from a client. ID is chosen by the client and echoed in
+// every reply, so it can match progress to the control which issued the command.
+type SessionRequest struct {
+	Type    SessionMessageType `json:"type"`
+	ID      string             `json:"id"`
+	Tag     string             `json:"tag,omitempty"`
+	Command ActuatorCommand    `json:"command"`
+}
+
+// CommandProgress is sent to the client which issued a command each time it progresses.
+// State is the hardware's reported state once confirmed.
+type CommandProgress struct {
+	Type  SessionMessageType `json:"type"`
+	ID    string             `json:"id"`
+	Tag   string             `json:"tag,omitempty"`
+	Stage CommandStage       `json:"stage"`
+	State *ActuatorState     `json:"state,omitempty"`
+	Error string             `json:"error,omitempty"`
+}
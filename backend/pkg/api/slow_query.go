@@ -0,0 +1,18 @@
+package api
+
+import "time"
+
+// SlowQuery summarizes the captured executions of one database query which took at
+// least the slow query threshold
+type SlowQuery struct {
+	Query    string        `json:"query"`
+	Count    int64         `json:"count"`
+	Total    time.Duration `json:"total"`
+	Mean     time.Duration `json:"mean"`
+	Max      time.Duration `json:"max"`
+	LastSeen time.Time     `json:"last_seen"`
+	// Plan is the EXPLAIN output of the latest capture
+	Plan string `json:"plan"`
+	// Suggestions are indexes which might serve the query, or notes where none can
+	Suggestions []string `json:"suggestions"`
+}
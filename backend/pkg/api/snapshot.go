@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ConfigSnapshot is the system's configuration at one point in time: its devices with
+// their sensors and actuators, tag aliases and sensor targets, but no readings or
+// history
+type ConfigSnapshot struct {
+	TakenAt    time.Time      `json:"taken_at"`
+	Devices    []*Device      `json:"devices"`
+	TagAliases []*TagAlias    `json:"tag_aliases"`
+	Targets    []*TargetRange `json:"targets"`
+}
+
+// ConfigKind is the kind of entity a configuration change is to
+type ConfigKind string
+
+const (
+	ConfigKindDevice   ConfigKind = "device"
+	ConfigKindSensor   ConfigKind = "sensor"
+	ConfigKindActuator ConfigKind = "actuator"
+	ConfigKindTagAlias ConfigKind = "tag_alias"
+	ConfigKindTarget   ConfigKind = "target"
+)
+
+// ConfigChangeType is how an entity differs between two snapshots
+type ConfigChangeType string
+
+const (
+	ConfigAdded   ConfigChangeType = "added"
+	ConfigRemoved ConfigChangeType = "removed"
+	ConfigChanged ConfigChangeType = "changed"
+)
+
+// ConfigChange is one entity that differs between two snapshots
+type ConfigChange struct {
+	Kind ConfigKind `json:"kind"`
+	// ID is the device ID, "{device_id}/{id}" for sensors, actuators and targets, or the
+	// alias
+	ID     string           `json:"id"`
+	Change ConfigChangeType `json:"change"`
+	// Fields lists the fields that differ, for changed entities
+	Fields []string `json:"fields,omitempty"`
+	// Before and After are the entity in each snapshot, without its version; devices are
+	// shown without their sensors and actuators, which are compared separately
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// ConfigDiff lists what changes going from one snapshot to another, ordered by kind and
+// ID
+type ConfigDiff struct {
+	Changes []ConfigChange `json:"changes"`
+}
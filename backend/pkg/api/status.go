@@ -17,6 +17,9 @@ const (
 	UnitMicroSiemens Unit = "µS/cm"
 	UnitMgPerL       Unit = "mg/L"
 	UnitMilliliters  Unit = "mL"
+	UnitVolts        Unit = "V"
+	UnitAmps         Unit = "A"
+	UnitWattHours    Unit = "Wh"
 )
 
 // SensorReading represents a single sensor measurement
@@ -26,4 +29,19 @@ type SensorReading struct {
 	Timestamp time.Time `json:"timestamp"`
 	Valid     bool      `json:"valid"`
 	Error     string    `json:"error,omitempty"`
+	// Synthetic marks readings injected by hand for testing rather than measured
+	Synthetic bool `json:"synthetic,omitempty"`
+}
+
+// ReadingRecord is a sensor reading together with the sensor it belongs to
+type ReadingRecord struct {
+	// ID is assigned when the reading is stored; with the timestamp it keys pages of
+	// readings
+	ID       int64         `json:"id,omitempty"`
+	DeviceID string        `json:"device_id"`
+	SensorID string        `json:"sensor_id"`
+	Reading  SensorReading `json:"reading"`
+	// Level and Target are filled in by the API when the sensor has a target range
+	Level  TargetLevel  `json:"level,omitempty"`
+	Target *TargetRange `json:"target,omitempty"`
 }
@@ -0,0 +1,18 @@
+package api
+
+import "time"
+
+// StatusPage is the public, read-only view of a curated set of sensors and actuators
+type StatusPage struct {
+	Title       string           `json:"title"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Items       []StatusPageItem `json:"items"`
+}
+
+// StatusPageItem is the latest reading for one curated tag. Reading is nil when no
+// current value is available; internal error details are never exposed.
+type StatusPageItem struct {
+	Label   string         `json:"label"`
+	Tag     string         `json:"tag"`
+	Reading *SensorReading `json:"reading,omitempty"`
+}
@@ -0,0 +1,54 @@
+package api
+
+import "time"
+
+// StorageQuota caps how many readings of one sensor are kept. A sensor over its quota is
+// downsampled if Downsample is set, and raises an alert if it stays over.
+type StorageQuota struct {
+	DeviceID    string `json:"device_id"`
+	SensorID    string `json:"sensor_id"`
+	MaxReadings int64  `json:"max_readings"`
+	// Downsample thins the sensor's older readings to one per interval when it is over
+	// quota; zero only alerts
+	Downsample time.Duration `json:"downsample,omitempty"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
+
+// TableUsage is the space one table takes in the database
+type TableUsage struct {
+	Table string `json:"table"`
+	// Rows is exact for SQLite and the in-memory store, and the planner's estimate for
+	// Postgres
+	Rows  int64 `json:"rows"`
+	Bytes int64 `json:"bytes"`
+}
+
+// SensorUsage is the readings kept of one sensor
+type SensorUsage struct {
+	DeviceID string `json:"device_id"`
+	SensorID string `json:"sensor_id"`
+	Readings int64  `json:"readings"`
+	// Recent counts the readings taken within the report's window
+	Recent int64 `json:"recent"`
+	// Bytes estimates the space the readings take, from the readings table's size
+	Bytes int64 `json:"bytes"`
+	// PerDay projects how many readings the sensor adds a day, from its recent ones
+	PerDay float64       `json:"per_day"`
+	Quota  *StorageQuota `json:"quota,omitempty"`
+	// DaysToQuota projects how long until the sensor exceeds its quota; zero if it
+	// already has, and unset without a quota or growth
+	DaysToQuota *float64 `json:"days_to_quota,omitempty"`
+}
+
+// StorageReport is the space the database takes, by table and by sensor, and how fast it
+// is growing
+type StorageReport struct {
+	Tables     []TableUsage  `json:"tables"`
+	Sensors    []SensorUsage `json:"sensors"`
+	TotalBytes int64         `json:"total_bytes"`
+	// GrowthPerDay projects the bytes the readings grow by a day
+	GrowthPerDay float64 `json:"growth_per_day"`
+	// Window is the span of recent readings growth is projected from
+	Window      time.Duration `json:"window"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
@@ -0,0 +1,59 @@
+package api
+
+// StreamMessageType identifies a message on the live stream WebSocket
+type StreamMessageType string
+
+const (
+	// StreamSubscribe is sent by a client to receive the readings and actuator states of
+	// a tag prefix or device
+	StreamSubscribe StreamMessageType = "subscribe"
+	// StreamUnsubscribe is sent by a client to end a subscription
+	StreamUnsubscribe StreamMessageType = "unsubscribe"
+	// StreamSubscribed acknowledges a subscription
+	StreamSubscribed StreamMessageType = "subscribed"
+	// StreamReading carries a stored sensor reading
+	StreamReading StreamMessageType = "reading"
+	// StreamActuatorState carries a state an actuator reported in answer to a command
+	StreamActuatorState StreamMessageType = "actuator_state"
+	// StreamAlert carries an alert; it is only returned by the long poll
+	StreamAlert StreamMessageType = "alert"
+	// StreamAlertAcknowledged carries an alert acknowledgement; it is only returned by the
+	// long poll
+	StreamAlertAcknowledged StreamMessageType = "alert_acknowledged"
+	// StreamError reports a request that could not be carried out
+	StreamError StreamMessageType = "error"
+)
+
+// StreamRequest is a message from a stream client. ID is chosen by the client and names
+// the subscription; a subscription selects by TagPrefix or DeviceID, one of which is
+// required.
+type StreamRequest struct {
+	Type      StreamMessageType `json:"type"`
+	ID        string            `json:"id"`
+	TagPrefix string            `json:"tag_prefix,omitempty"`
+	DeviceID  string            `json:"device_id,omitempty"`
+}
+
+// StreamMessage is sent to a stream client, once per reading or actuator state however
+// many of its subscriptions select it, and returned by the long poll. Seq is the change
+// feed position of readings, states and alerts, so a client which reconnects can catch up
+// from GET /api/changes.
+type StreamMessage struct {
+	Type StreamMessageType `json:"type"`
+	// ID is the subscription a subscribed or error message answers
+	ID      string         `json:"id,omitempty"`
+	Seq     int64          `json:"seq,omitempty"`
+	Reading *ReadingRecord `json:"reading,omitempty"`
+	// Command is the command the actuator answered, carrying its reported state
+	Command *CommandRecord `json:"command,omitempty"`
+	Alert   *Alert         `json:"alert,omitempty"`
+	Ack     *AlertAck      `json:"ack,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// PollResponse answers a long poll with the messages after its cursor, which may be
+// none if the poll timed out. Cursor is passed as after for the next poll.
+type PollResponse struct {
+	Messages []*StreamMessage `json:"messages"`
+	Cursor   int64            `json:"cursor"`
+}
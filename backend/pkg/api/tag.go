@@ -0,0 +1,28 @@
+package api
+
+// TagAlias is an additional name for an existing tag. Lookups by tag fall back to
+// aliases when no device, sensor or actuator carries the tag itself, so automations can
+// refer to an alias and keep working when the underlying tag is renamed and the alias
+// retargeted.
+type TagAlias struct {
+	Alias  string `json:"alias"`
+	Target string `json:"target"`
+}
+
+// TagCounts counts the devices, sensors and actuators carrying a tag or one beneath it
+type TagCounts struct {
+	Devices   int `json:"devices"`
+	Sensors   int `json:"sensors"`
+	Actuators int `json:"actuators"`
+}
+
+// TagNode is one level of the tag hierarchy, in which each dot-separated segment of a
+// tag is a level: "tank.temp" is the node "temp" beneath the node "tank"
+type TagNode struct {
+	// Name is the node's own segment, and Tag the full tag down to it
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+	// Counts counts each entity once however many of its tags are at or beneath the node
+	Counts   TagCounts  `json:"counts"`
+	Children []*TagNode `json:"children"`
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TagKind is the kind of entity a default tag names
+type TagKind string
+
+const (
+	TagKindDevice   TagKind = "device"
+	TagKindSensor   TagKind = "sensor"
+	TagKindActuator TagKind = "actuator"
+)
+
+// TagTemplate replaces the built-in device.<id> pattern of one kind of entity's default
+// tag, e.g. "site.{site}.{subsystem}.{device}.{kind}.{id}". Placeholders name a built-in
+// value (kind, id, device, driver, and type for sensors and actuators) or else a key of
+// the entity's metadata, then of its device's.
+type TagTemplate struct {
+	Kind      TagKind   `json:"kind"`
+	Template  string    `json:"template"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the template is well formed and names each entity of its kind apart
+func (t *TagTemplate) Validate() error {
+	var required []string
+	switch t.Kind {
+	case TagKindDevice:
+		required = []string{"id"}
+		if strings.Contains(t.Template, "{device}") {
+			required = nil
+		}
+	case TagKindSensor, TagKindActuator:
+		required = []string{"device", "id"}
+	default:
+		return fmt.Errorf("unknown tag kind %q", t.Kind)
+	}
+	if t.Template == "" {
+		return fmt.Errorf("template is required")
+	}
+	if _, err := renderTag(t.Template, func(string) string { return "x" }); err != nil {
+		return err
+	}
+	for _, name := range required {
+		if !strings.Contains(t.Template, "{"+name+"}") {
+			return fmt.Errorf("%s template must include {%s}", t.Kind, name)
+		}
+	}
+	return nil
+}
+
+// TagTemplates are the tag templates by kind. Kinds without one, and entities missing a
+// value the template needs, get the built-in default tag.
+type TagTemplates map[TagKind]string
+
+// DeviceTag returns the default tag of dev
+func (t TagTemplates) DeviceTag(dev *Device) string {
+	tag, err := renderTag(t[TagKindDevice], func(name string) string {
+		switch name {
+		case "kind":
+			return string(TagKindDevice)
+		case "id", "device":
+			return dev.ID
+		case "driver":
+			return string(dev.Driver)
+		}
+		return dev.Metadata[name]
+	})
+	if err != nil || tag == "" {
+		return dev.DefaultTag()
+	}
+	return tag
+}
+
+// SensorTag returns the default tag of sensor, whose device has deviceMetadata
+func (t TagTemplates) SensorTag(sensor *Sensor, deviceMetadata map[string]string) string {
+	tag, err := renderTag(t[TagKindSensor], componentValues(TagKindSensor, sensor.DeviceID, sensor.ID,
+		string(sensor.SensorType), sensor.Metadata, deviceMetadata))
+	if err != nil || tag == "" {
+		return sensor.DefaultTag(sensor.DeviceID)
+	}
+	return tag
+}
+
+// ActuatorTag returns the default tag of actuator, whose device has deviceMetadata
+func (t TagTemplates) ActuatorTag(actuator *Actuator, deviceMetadata map[string]string) string {
+	tag, err := renderTag(t[TagKindActuator], componentValues(TagKindActuator, actuator.DeviceID, actuator.ID,
+		string(actuator.ActuatorType), actuator.Metadata, deviceMetadata))
+	if err != nil || tag == "" {
+		return actuator.DefaultTag(actuator.DeviceID)
+	}
+	return tag
+}
+
+func componentValues(kind TagKind, deviceID, id, typ string, metadata, deviceMetadata map[string]string) func(string) string {
+	return func(name string) string {
+		switch name {
+		case "kind":
+			return string(kind)
+		case "id":
+			return id
+		case "device":
+			return deviceID
+		case "type":
+			return typ
+		}
+		if v, ok := metadata[name]; ok {
+			return v
+		}
+		return deviceMetadata[name]
+	}
+}
+
+// renderTag fills the {placeholders} of template with value. It fails if a placeholder
+// is malformed or has no value, and returns "" for an empty template.
+func renderTag(template string, value func(name string) string) (string, error) {
+	var b strings.Builder
+	rest := template
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		if rest[open] == '}' {
+			return "", fmt.Errorf("unmatched } in tag template %q", template)
+		}
+		b.WriteString(rest[:open])
+		rest = rest[open+1:]
+		end := strings.IndexAny(rest, "{}")
+		if end < 0 || rest[end] == '{' {
+			return "", fmt.Errorf("unclosed { in tag template %q", template)
+		}
+		name := rest[:end]
+		if name == "" {
+			return "", fmt.Errorf("empty placeholder in tag template %q", template)
+		}
+		v := value(name)
+		if v == "" {
+			return "", fmt.Errorf("no value for {%s}", name)
+		}
+		b.WriteString(v)
+		rest = rest[end+1:]
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TargetLevel classifies a reading against its sensor's target range
+type TargetLevel string
+
+const (
+	TargetLevelIdeal TargetLevel = "ideal"
+	// TargetLevelOK is outside the ideal band but inside the warning band
+	TargetLevelOK       TargetLevel = "ok"
+	TargetLevelWarning  TargetLevel = "warning"
+	TargetLevelCritical TargetLevel = "critical"
+)
+
+// Band is an inclusive range of values; a missing bound is unlimited
+type Band struct {
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+}
+
+// Contains reports whether v lies within the band
+func (b Band) Contains(v float64) bool {
+	return (b.Min == nil || v >= *b.Min) && (b.Max == nil || v <= *b.Max)
+}
+
+// within reports whether b lies inside outer
+func (b Band) within(outer Band) bool {
+	if outer.Min != nil && (b.Min == nil || *b.Min < *outer.Min) {
+		return false
+	}
+	if outer.Max != nil && (b.Max == nil || *b.Max > *outer.Max) {
+		return false
+	}
+	return true
+}
+
+func (b Band) validate(name string) error {
+	if b.Min != nil && b.Max != nil && *b.Min > *b.Max {
+		return fmt.Errorf("%s band min %g is above max %g", name, *b.Min, *b.Max)
+	}
+	return nil
+}
+
+// TargetRange is the range a sensor's readings should stay in, used to color-code charts
+// and readings. Readings inside Ideal are ideal; those outside Warning warrant a warning
+// and those outside Critical are critical. Each band must lie inside the next.
+type TargetRange struct {
+	DeviceID string `json:"device_id"`
+	SensorID string `json:"sensor_id"`
+	Ideal    Band   `json:"ideal"`
+	Warning  Band   `json:"warning"`
+	Critical Band   `json:"critical"`
+}
+
+// Validate checks each band is ordered and nested inside the next
+func (t *TargetRange) Validate() error {
+	for _, b := range []struct {
+		name string
+		band Band
+	}{{"ideal", t.Ideal}, {"warning", t.Warning}, {"critical", t.Critical}} {
+		if err := b.band.validate(b.name); err != nil {
+			return err
+		}
+	}
+	if !t.Ideal.within(t.Warning) {
+		return errors.New("ideal band must lie inside the warning band")
+	}
+	if !t.Warning.within(t.Critical) {
+		return errors.New("warning band must lie inside the critical band")
+	}
+	return nil
+}
+
+// Level classifies a reading value against the range
+func (t *TargetRange) Level(v float64) TargetLevel {
+	switch {
+	case !t.Critical.Contains(v):
+		return TargetLevelCritical
+	case !t.Warning.Contains(v):
+		return TargetLevelWarning
+	case !t.Ideal.Contains(v):
+		return TargetLevelOK
+	default:
+		return TargetLevelIdeal
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+// TopologyNodeKind is the kind of entity a topology node stands for
+type TopologyNodeKind string
+
+const (
+	TopologyNodeSubsystem TopologyNodeKind = "subsystem"
+	TopologyNodeDevice    TopologyNodeKind = "device"
+	TopologyNodeSensor    TopologyNodeKind = "sensor"
+	TopologyNodeActuator  TopologyNodeKind = "actuator"
+	TopologyNodeRule      TopologyNodeKind = "rule"
+)
+
+// TopologyEdgeKind is how two topology nodes are related
+type TopologyEdgeKind string
+
+const (
+	// TopologyEdgeContains links a subsystem to its devices and a device to its sensors
+	// and actuators
+	TopologyEdgeContains TopologyEdgeKind = "contains"
+	// TopologyEdgeReads links a rule to a sensor it reads
+	TopologyEdgeReads TopologyEdgeKind = "reads"
+	// TopologyEdgeDrives links a rule to an actuator it drives
+	TopologyEdgeDrives TopologyEdgeKind = "drives"
+)
+
+// TopologyNode is one entity in the topology graph. IDs are prefixed with the kind, e.g.
+// "device:tank-1", "sensor:tank-1/temp" or "rule:dry_run/main-pump".
+type TopologyNode struct {
+	ID    string           `json:"id"`
+	Kind  TopologyNodeKind `json:"kind"`
+	Label string           `json:"label"`
+	// RuleKind is the kind of rule a rule node stands for
+	RuleKind string `json:"rule_kind,omitempty"`
+}
+
+// TopologyEdge relates two topology nodes by ID
+type TopologyEdge struct {
+	From string           `json:"from"`
+	To   string           `json:"to"`
+	Kind TopologyEdgeKind `json:"kind"`
+	// Tag is the tag a rule refers to the sensor or actuator by
+	Tag string `json:"tag,omitempty"`
+}
+
+// Topology is a graph of how the system fits together: subsystems, their devices with
+// sensors and actuators, and the control rules reading and driving them
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+	// Unresolved lists tags rules refer to which match no sensor or actuator
+	Unresolved []string `json:"unresolved"`
+}
+
+// ImpactCategory groups what a failing sensor affects
+type ImpactCategory string
+
+const (
+	// ImpactControlLoop is a periodically evaluated rule, such as a pump rotation
+	ImpactControlLoop ImpactCategory = "control_loop"
+	// ImpactRule is a rule reacting to readings as they arrive, such as a leak response
+	ImpactRule ImpactCategory = "rule"
+	// ImpactDerivedSensor is a measurement computed from other sensors' readings
+	ImpactDerivedSensor ImpactCategory = "derived_sensor"
+)
+
+// RuleImpact is a rule affected by a sensor failing
+type RuleImpact struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Tags are the tags the rule reads the sensor by
+	Tags []string `json:"tags"`
+	// Fallback is what the rule does while the sensor is not reporting
+	Fallback string `json:"fallback"`
+	// OtherSensors counts the other sensors the rule still reads
+	OtherSensors int `json:"other_sensors"`
+	// Actuators lists the actuators the rule drives, as "{device_id}/{id}"
+	Actuators []string `json:"actuators"`
+}
+
+// SensorImpact lists what is affected if a sensor fails, by category
+type SensorImpact struct {
+	DeviceID       string       `json:"device_id"`
+	SensorID       string       `json:"sensor_id"`
+	ControlLoops   []RuleImpact `json:"control_loops"`
+	Rules          []RuleImpact `json:"rules"`
+	DerivedSensors []RuleImpact `json:"derived_sensors"`
+}
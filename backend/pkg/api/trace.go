@@ -0,0 +1,64 @@
+package api
+
+import "time"
+
+// TraceOutcome summarizes one evaluation of a rule
+type TraceOutcome string
+
+const (
+	// TraceIdle means the rule found nothing to act on
+	TraceIdle TraceOutcome = "idle"
+	// TraceFired means the rule raised an alert or sent commands
+	TraceFired TraceOutcome = "fired"
+	// TraceSuppressed means the rule's condition held but it held back, e.g. while
+	// waiting out its duration or after already firing
+	TraceSuppressed TraceOutcome = "suppressed"
+	// TraceDisabled means the rule was skipped because a resource it depends on was
+	// deleted
+	TraceDisabled TraceOutcome = "disabled"
+	TraceError    TraceOutcome = "error"
+)
+
+// TraceInput is a reading a rule read during an evaluation
+type TraceInput struct {
+	Tag   string   `json:"tag"`
+	Value *float64 `json:"value,omitempty"`
+	Unit  Unit     `json:"unit,omitempty"`
+	Valid bool     `json:"valid"`
+	Error string   `json:"error,omitempty"`
+}
+
+// TraceActionOutcome says what became of an action a rule wanted to take
+type TraceActionOutcome string
+
+const (
+	TraceActionTaken      TraceActionOutcome = "taken"
+	TraceActionFailed     TraceActionOutcome = "failed"
+	TraceActionSuppressed TraceActionOutcome = "suppressed"
+)
+
+// TraceAction is a command or alert a rule sent, or held back, during an evaluation
+type TraceAction struct {
+	// Tag is the actuator commanded; empty for alerts
+	Tag string `json:"tag,omitempty"`
+	// Action is the command's action, or "alert" with the alert's title in Detail
+	Action  string             `json:"action"`
+	Outcome TraceActionOutcome `json:"outcome"`
+	Detail  string             `json:"detail,omitempty"`
+}
+
+// RuleTrace records one evaluation of a control rule: what it read, how its condition
+// came out and what it did
+type RuleTrace struct {
+	ID        int64        `json:"id"`
+	Kind      string       `json:"kind"`
+	Name      string       `json:"name"`
+	Timestamp time.Time    `json:"timestamp"`
+	Outcome   TraceOutcome `json:"outcome"`
+	// Reason explains the outcome, e.g. why a rule held back
+	Reason    string           `json:"reason,omitempty"`
+	Inputs    []TraceInput     `json:"inputs,omitempty"`
+	Condition *ConditionStatus `json:"condition,omitempty"`
+	Actions   []TraceAction    `json:"actions,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
@@ -0,0 +1,65 @@
+package api
+
+import "time"
+
+// TrendDirection says which way a sensor moved between two windows
+type TrendDirection string
+
+const (
+	TrendUp   TrendDirection = "up"
+	TrendDown TrendDirection = "down"
+	// TrendFlat means the change was not significant
+	TrendFlat TrendDirection = "flat"
+)
+
+// WindowStats summarises a sensor's valid, measured readings in [Start, End)
+type WindowStats struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Count  int       `json:"count"`
+	Mean   *float64  `json:"mean"`
+	Min    *float64  `json:"min"`
+	Max    *float64  `json:"max"`
+	StdDev *float64  `json:"stddev"`
+}
+
+// TrendComparison compares a sensor's current window against a baseline window. The
+// deltas are current minus baseline, and are null when either window has no readings.
+type TrendComparison struct {
+	DeviceID string      `json:"device_id"`
+	SensorID string      `json:"sensor_id"`
+	Unit     Unit        `json:"unit,omitempty"`
+	Baseline WindowStats `json:"baseline"`
+	Current  WindowStats `json:"current"`
+	// MeanDelta and MeanDeltaPercent compare the window means
+	MeanDelta        *float64 `json:"mean_delta"`
+	MeanDeltaPercent *float64 `json:"mean_delta_percent"`
+	// PValue is from Welch's t-test on the two windows, and is null with fewer than two
+	// readings in either
+	PValue      *float64       `json:"p_value"`
+	Significant bool           `json:"significant"`
+	Direction   TrendDirection `json:"direction"`
+}
+
+// AggregateFunc names a statistic of the readings within each bucket of an aggregation
+type AggregateFunc string
+
+const (
+	AggregateAvg   AggregateFunc = "avg"
+	AggregateMin   AggregateFunc = "min"
+	AggregateMax   AggregateFunc = "max"
+	AggregateCount AggregateFunc = "count"
+)
+
+// AggregateFuncs are all the aggregate functions, in the order they are reported
+var AggregateFuncs = []AggregateFunc{AggregateAvg, AggregateMin, AggregateMax, AggregateCount}
+
+// ReadingBucket aggregates a sensor's valid, measured readings in [Start, Start+interval).
+// Only the statistics asked for are set.
+type ReadingBucket struct {
+	Start time.Time `json:"start"`
+	Avg   *float64  `json:"avg,omitempty"`
+	Min   *float64  `json:"min,omitempty"`
+	Max   *float64  `json:"max,omitempty"`
+	Count *int64    `json:"count,omitempty"`
+}
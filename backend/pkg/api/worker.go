@@ -0,0 +1,31 @@
+package api
+
+import "time"
+
+// WorkerStatus is whether a worker is running, as far as its presence messages tell
+type WorkerStatus string
+
+const (
+	WorkerOnline WorkerStatus = "online"
+	// WorkerOffline is published by a worker shutting down, or by the broker as its last
+	// will when the worker's connection drops
+	WorkerOffline WorkerStatus = "offline"
+	// WorkerUnresponsive is an online worker which has missed several heartbeats
+	WorkerUnresponsive WorkerStatus = "unresponsive"
+)
+
+// WorkerPresence summarises a worker. Each worker publishes its own as a retained MQTT
+// message, refreshed every heartbeat.
+type WorkerPresence struct {
+	// ID is the worker's MQTT client ID
+	ID     string       `json:"id"`
+	Status WorkerStatus `json:"status"`
+	// Broker is the MQTT broker the worker's drivers use, without credentials
+	Broker           string       `json:"broker"`
+	Hostname         string       `json:"hostname,omitempty"`
+	TaskQueue        string       `json:"task_queue,omitempty"`
+	Drivers          []DriverName `json:"drivers,omitempty"`
+	StartedAt        time.Time    `json:"started_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	HeartbeatSeconds int          `json:"heartbeat_seconds"`
+}
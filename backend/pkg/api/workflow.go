@@ -12,6 +12,10 @@ const (
 	WorkflowStatusError      WorkflowStatus = "error"
 )
 
+// EventWorkflowStatus names the event on GET /api/events carrying a WorkflowInfo whose
+// status changed; alert events are named by their ChangeType
+const EventWorkflowStatus = "workflow.status"
+
 // WorkflowInfo contains information about a workflow execution
 type WorkflowInfo struct {
 	WorkflowID string         `json:"workflow_id"`
@@ -38,3 +42,16 @@ type StartWorkflowResponse struct {
 	WorkflowID string `json:"workflow_id"`
 	RunID      string `json:"run_id"`
 }
+
+// RetryPolicy controls how a workflow retries a failed activity. Zero fields inherit
+// from the policy it overrides.
+type RetryPolicy struct {
+	InitialInterval    time.Duration `json:"initial_interval,omitempty"`
+	BackoffCoefficient float64       `json:"backoff_coefficient,omitempty"`
+	MaximumInterval    time.Duration `json:"maximum_interval,omitempty"`
+	// MaximumAttempts bounds the total number of attempts; 0 inherits and a negative
+	// value retries without limit
+	MaximumAttempts int32 `json:"maximum_attempts,omitempty"`
+	// NonRetryableErrorTypes lists activity error types that fail immediately
+	NonRetryableErrorTypes []string `json:"non_retryable_error_types,omitempty"`
+}
@@ -0,0 +1,122 @@
+// Package assets prepares uploaded images, such as device photos and plumbing diagrams,
+// for storage: it checks they are images of a supported format and renders thumbnails.
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers GIF with image.Decode
+	"image/jpeg"
+	"image/png"
+)
+
+// ThumbnailSize bounds the width and height of thumbnails
+const ThumbnailSize = 256
+
+// MaxPixels bounds the size of images accepted, so a small file can't decode into an
+// enormous bitmap
+const MaxPixels = 50_000_000
+
+// ErrUnsupported is returned for uploads which are not JPEG, PNG or GIF images
+var ErrUnsupported = errors.New("unsupported image")
+
+// Image describes a processed upload and carries its thumbnail
+type Image struct {
+	ContentType   string
+	Width, Height int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// contentTypes maps the formats image.Decode reports to MIME types
+var contentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// Process decodes an uploaded image and renders its thumbnail. JPEG photos get JPEG
+// thumbnails; PNG and GIF diagrams get PNG thumbnails, keeping sharp lines and
+// transparency.
+func Process(data []byte) (*Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, format)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d image exceeds %d pixels", ErrUnsupported, cfg.Width, cfg.Height, MaxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+
+	processed := &Image{ContentType: contentType, Width: cfg.Width, Height: cfg.Height}
+	thumb := Thumbnail(img, ThumbnailSize)
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		processed.ThumbnailType = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		processed.ThumbnailType = "image/png"
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	processed.Thumbnail = buf.Bytes()
+	return processed, nil
+}
+
+// Thumbnail scales img to fit within size by size, keeping its aspect ratio. Each
+// thumbnail pixel averages the source pixels it covers. Images which already fit are
+// returned unscaled.
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= size && sh <= size {
+		return img
+	}
+	dw, dh := size, size
+	if sw > sh {
+		dh = max(1, sh*size/sw)
+	} else {
+		dw = max(1, sw*size/sh)
+	}
+
+	src, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, sw, sh))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			off := y*dst.Stride + x*4
+			for i := range sum {
+				dst.Pix[off+i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
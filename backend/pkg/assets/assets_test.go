@@ -0,0 +1,94 @@
+package assets
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess_Diagram(t *testing.T) {
+	// Left half red, right half transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 1024, 512))
+	for y := 0; y < 512; y++ {
+		for x := 0; x < 512; x++ {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	processed, err := Process(encodePNG(t, img))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if processed.ContentType != "image/png" || processed.Width != 1024 || processed.Height != 512 {
+		t.Errorf("Unexpected image %+v", processed)
+	}
+	if processed.ThumbnailType != "image/png" {
+		t.Errorf("Expected a PNG thumbnail, got %s", processed.ThumbnailType)
+	}
+	thumb, err := png.Decode(bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		t.Fatalf("Failed to decode thumbnail: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 256 || b.Dy() != 128 {
+		t.Errorf("Expected a 256x128 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, a := thumb.At(10, 10).RGBA(); r>>8 != 255 || a>>8 != 255 {
+		t.Errorf("Expected opaque red on the left, got r=%d a=%d", r>>8, a>>8)
+	}
+	if _, _, _, a := thumb.At(200, 10).RGBA(); a != 0 {
+		t.Errorf("Expected transparency kept on the right, got a=%d", a>>8)
+	}
+}
+
+func TestProcess_Photo(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 600))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if processed.ContentType != "image/jpeg" || processed.ThumbnailType != "image/jpeg" {
+		t.Errorf("Expected JPEG photo and thumbnail, got %+v", processed)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		t.Fatalf("Failed to decode thumbnail: %v", err)
+	}
+	if cfg.Width != 128 || cfg.Height != 256 {
+		t.Errorf("Expected a 128x256 thumbnail, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestProcess_SmallImageUnscaled(t *testing.T) {
+	processed, err := Process(encodePNG(t, image.NewRGBA(image.Rect(0, 0, 40, 30))))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	cfg, _ := png.DecodeConfig(bytes.NewReader(processed.Thumbnail))
+	if cfg.Width != 40 || cfg.Height != 30 {
+		t.Errorf("Expected small image kept at 40x30, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestProcess_Unsupported(t *testing.T) {
+	if _, err := Process([]byte("<svg></svg>")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// Package benchmark builds anonymized reading datasets that owners can opt in to share
+// for community benchmarking of parameters across similar systems
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Build summarises the readings taken in [start, end) into hourly points per sensor.
+// Synthetic and invalid readings are left out, sensors are renamed to pseudonyms
+// shuffled per dataset, and nothing identifying the devices is kept.
+func Build(ctx context.Context, store storer.Interface, start, end time.Time) (*api.BenchmarkDataset, error) {
+	sensors, err := store.ListSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	subsystems := map[string]string{}
+	devices, err := store.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	for _, dev := range devices {
+		subsystems[dev.ID] = dev.Metadata[api.MetadataSubsystemType]
+	}
+
+	start, end = start.UTC(), end.UTC()
+	dataset := &api.BenchmarkDataset{Start: start, End: end, Series: []api.BenchmarkSeries{}}
+	for _, sensor := range sensors {
+		readings, err := store.GetSensorReadings(ctx, storer.SensorReadingFilters{
+			DeviceID:  sensor.DeviceID,
+			SensorID:  sensor.ID,
+			StartTime: &start,
+			EndTime:   &end,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get readings: %w", err)
+		}
+		points, unit := summarise(readings)
+		if len(points) == 0 {
+			continue
+		}
+		dataset.Series = append(dataset.Series, api.BenchmarkSeries{
+			SensorType:    sensor.SensorType,
+			SubsystemType: subsystems[sensor.DeviceID],
+			Unit:          unit,
+			Points:        points,
+		})
+	}
+
+	// Store order follows sensor names, so shuffle before numbering
+	rand.Shuffle(len(dataset.Series), func(i, j int) {
+		dataset.Series[i], dataset.Series[j] = dataset.Series[j], dataset.Series[i]
+	})
+	for i := range dataset.Series {
+		dataset.Series[i].Sensor = fmt.Sprintf("sensor-%d", i+1)
+	}
+	return dataset, nil
+}
+
+// summarise groups valid, measured readings into hourly points, oldest first
+func summarise(readings []*api.ReadingRecord) ([]api.BenchmarkPoint, api.Unit) {
+	var unit api.Unit
+	hours := map[time.Time]*api.BenchmarkPoint{}
+	for _, rec := range readings {
+		r := rec.Reading
+		if !r.Valid || r.Synthetic {
+			continue
+		}
+		if unit == "" {
+			unit = r.Unit
+		}
+		hour := r.Timestamp.UTC().Truncate(time.Hour)
+		p, ok := hours[hour]
+		if !ok {
+			p = &api.BenchmarkPoint{Hour: hour, Min: r.Value, Max: r.Value}
+			hours[hour] = p
+		}
+		p.Mean += r.Value // summed here, divided below
+		p.Min = min(p.Min, r.Value)
+		p.Max = max(p.Max, r.Value)
+		p.Count++
+	}
+
+	points := make([]api.BenchmarkPoint, 0, len(hours))
+	for _, p := range hours {
+		p.Mean /= float64(p.Count)
+		points = append(points, *p)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Hour.Before(points[j].Hour) })
+	return points, unit
+}
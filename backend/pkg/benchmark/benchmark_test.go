@@ -0,0 +1,74 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer/storertest"
+)
+
+func TestBuild(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:          "smith-house-sump",
+		Driver:      "gateway",
+		Name:        "Smith house sump",
+		Description: "123 Main St",
+		Metadata:    map[string]string{api.MetadataSubsystemType: "aquarium", "owner": "smith"},
+		Sensors: []*api.Sensor{
+			{ID: "temp", DeviceID: "smith-house-sump", Name: "Sump temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"sump-temp"}},
+			{ID: "ph", DeviceID: "smith-house-sump", Name: "Sump pH", SensorType: api.SensorTypePH},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	start := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+	for i, r := range []api.SensorReading{
+		{Value: 25, Valid: true},
+		{Value: 27, Valid: true},
+		{Value: 99, Valid: true, Synthetic: true},
+		{Value: -1, Valid: false},
+	} {
+		r.Unit = api.UnitCelsius
+		r.Timestamp = start.Add(time.Duration(i) * 10 * time.Minute)
+		if err := store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "smith-house-sump", SensorID: "temp", Reading: r}); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	late := api.SensorReading{Value: 26, Valid: true, Unit: api.UnitCelsius, Timestamp: start.Add(90 * time.Minute)}
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "smith-house-sump", SensorID: "temp", Reading: late})
+	outside := api.SensorReading{Value: 8.1, Valid: true, Timestamp: start.Add(-time.Hour)}
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "smith-house-sump", SensorID: "ph", Reading: outside})
+
+	dataset, err := Build(ctx, store, start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if len(dataset.Series) != 1 {
+		t.Fatalf("Expected only the sensor with readings in range, got %d series", len(dataset.Series))
+	}
+	s := dataset.Series[0]
+	if s.Sensor != "sensor-1" || s.SensorType != api.SensorTypeTemperature || s.SubsystemType != "aquarium" || s.Unit != api.UnitCelsius {
+		t.Errorf("Unexpected series header %+v", s)
+	}
+	if len(s.Points) != 2 {
+		t.Fatalf("Expected 2 hourly points, got %+v", s.Points)
+	}
+	if p := s.Points[0]; p.Mean != 26 || p.Min != 25 || p.Max != 27 || p.Count != 2 {
+		t.Errorf("Expected first hour to summarise 25 and 27 only, got %+v", p)
+	}
+
+	b, _ := json.Marshal(dataset)
+	for _, secret := range []string{"smith", "Main St", "sump-temp", "Sump temperature", "temp\""} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("Expected dataset to leave out %q, got %s", secret, b)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package blob stores files such as camera snapshots, backups, exports and report
+// artifacts in one configured place: a local directory or an S3-compatible object store
+// such as AWS S3 or MinIO. Features take a Bucket, usually narrowed WithPrefix, rather
+// than inventing storage paths of their own.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when getting a blob that does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// Object describes a stored blob
+type Object struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Bucket is a flat namespace of blobs. Keys are slash-separated relative paths, such as
+// "benchmarks/benchmark-20260131T1000Z.json".
+type Bucket interface {
+	// Put stores the contents of r under key, replacing any existing blob. Readers never
+	// see a partially written blob.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the blob stored under key, or returns ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	// List describes the blobs whose keys start with prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Location is where the blob stored under key lives, for logs and results: a file
+	// path or an s3:// URL
+	Location(key string) string
+}
+
+// Open returns the bucket described by rawURL:
+//
+//	/var/lib/lifesupport/blobs or file:///var/lib/lifesupport/blobs
+//	s3://bucket/optional/prefix?region=us-east-1
+//	s3://bucket?endpoint=http://minio:9000&path_style=true
+//
+// S3 credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN.
+func Open(rawURL string) (Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse blob URL: %w", err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		if u.Path == "" {
+			return nil, errors.New("blob URL has no directory")
+		}
+		return NewFilesystem(u.Path), nil
+	case "s3":
+		if u.Host == "" {
+			return nil, errors.New("blob URL has no bucket")
+		}
+		q := u.Query()
+		cfg := S3Config{
+			Bucket:          u.Host,
+			Region:          q.Get("region"),
+			Endpoint:        q.Get("endpoint"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if v := q.Get("path_style"); v != "" {
+			if cfg.PathStyle, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("invalid path_style %q: %w", v, err)
+			}
+		}
+		var b Bucket
+		if b, err = NewS3(cfg); err != nil {
+			return nil, err
+		}
+		if prefix := strings.Trim(u.Path, "/"); prefix != "" {
+			b = WithPrefix(b, prefix+"/")
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unsupported blob URL scheme %q", u.Scheme)
+}
+
+// WithPrefix narrows b to the keys under prefix, such as "snapshots/"
+func WithPrefix(b Bucket, prefix string) Bucket {
+	return &prefixed{bucket: b, prefix: prefix}
+}
+
+type prefixed struct {
+	bucket Bucket
+	prefix string
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return p.bucket.Put(ctx, p.prefix+key, r, contentType)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	return p.bucket.Get(ctx, p.prefix+key)
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return p.bucket.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := p.bucket.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, nil
+}
+
+func (p *prefixed) Location(key string) string {
+	return p.bucket.Location(p.prefix + key)
+}
+
+// validKey rejects keys which could escape the bucket or mean different things to
+// different backends
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
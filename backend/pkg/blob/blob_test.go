@@ -0,0 +1,127 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exerciseBucket runs the behaviour every backend shares
+func exerciseBucket(t *testing.T, b Bucket) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := b.Get(ctx, "snapshots/missing.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing blob, got %v", err)
+	}
+	for key, body := range map[string]string{
+		"snapshots/pump 3.jpg":   "jpeg",
+		"snapshots/sump.jpg":     "jpeg too",
+		"exports/benchmark.json": "{}",
+	} {
+		if err := b.Put(ctx, key, strings.NewReader(body), "application/octet-stream"); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	if err := b.Put(ctx, "snapshots/sump.jpg", strings.NewReader("replaced"), "image/jpeg"); err != nil {
+		t.Fatalf("Put() replace error = %v", err)
+	}
+
+	r, err := b.Get(ctx, "snapshots/sump.jpg")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if string(got) != "replaced" {
+		t.Errorf("Expected replaced contents, got %q", got)
+	}
+
+	objects, err := b.List(ctx, "snapshots/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "snapshots/pump 3.jpg" || objects[1].Size != int64(len("replaced")) {
+		t.Errorf("Expected both snapshots, got %+v", objects)
+	}
+
+	if err := b.Delete(ctx, "snapshots/pump 3.jpg"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := b.Delete(ctx, "snapshots/pump 3.jpg"); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed, got %v", err)
+	}
+	if objects, _ := b.List(ctx, "snapshots/"); len(objects) != 1 {
+		t.Errorf("Expected one snapshot after delete, got %+v", objects)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../escape", "a//b", `a\b`} {
+		if err := b.Put(ctx, key, strings.NewReader("x"), ""); err == nil {
+			t.Errorf("Expected invalid key %q to be rejected", key)
+		}
+	}
+}
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	b := NewFilesystem(filepath.Join(dir, "blobs"))
+
+	if objects, err := b.List(context.Background(), ""); err != nil || len(objects) != 0 {
+		t.Errorf("Expected an empty listing before the directory exists, got %v, %v", objects, err)
+	}
+	exerciseBucket(t, b)
+
+	if _, err := os.Stat(filepath.Join(dir, "blobs", "exports", "benchmark.json")); err != nil {
+		t.Errorf("Expected blob stored as a file: %v", err)
+	}
+	if b.Location("exports/benchmark.json") != filepath.Join(dir, "blobs", "exports", "benchmark.json") {
+		t.Errorf("Unexpected location %s", b.Location("exports/benchmark.json"))
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	root := NewFilesystem(t.TempDir())
+	b := WithPrefix(root, "site-a/")
+	exerciseBucket(t, b)
+
+	objects, err := root.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for _, o := range objects {
+		if !strings.HasPrefix(o.Key, "site-a/") {
+			t.Errorf("Expected every key under the prefix, got %s", o.Key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, rawURL := range []string{dir, "file://" + dir} {
+		b, err := Open(rawURL)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", rawURL, err)
+		}
+		if _, ok := b.(*Filesystem); !ok {
+			t.Errorf("Expected a filesystem bucket for %s, got %T", rawURL, b)
+		}
+	}
+
+	b, err := Open("s3://lifesupport/site-a?endpoint=http://minio:9000&path_style=true")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if loc := b.Location("snapshots/sump.jpg"); loc != "s3://lifesupport/site-a/snapshots/sump.jpg" {
+		t.Errorf("Unexpected location %s", loc)
+	}
+
+	for _, rawURL := range []string{"s3:///no-bucket", "gs://bucket", "s3://bucket?path_style=maybe"} {
+		if _, err := Open(rawURL); err == nil {
+			t.Errorf("Expected Open(%s) to fail", rawURL)
+		}
+	}
+}
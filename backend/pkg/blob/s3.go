@@ -0,0 +1,324 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Config locates an S3-compatible bucket and the credentials to reach it
+type S3Config struct {
+	Bucket string
+	// Region defaults to us-east-1, which is also what MinIO expects unless configured
+	// otherwise
+	Region string
+	// Endpoint defaults to AWS, https://s3.<region>.amazonaws.com; set it for MinIO
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of as a subdomain,
+	// as MinIO requires
+	PathStyle bool
+}
+
+// S3 stores blobs in an S3-compatible object store, signing requests with AWS
+// Signature Version 4
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+type S3Option func(*S3)
+
+// WithHTTPClient sets the client used to reach the object store
+func WithHTTPClient(client *http.Client) S3Option {
+	return func(s *S3) {
+		s.client = client
+	}
+}
+
+// NewS3 returns a bucket backed by the S3-compatible store described by cfg
+func NewS3(cfg S3Config, opts ...S3Option) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	s := &S3{cfg: cfg, endpoint: endpoint, client: http.DefaultClient, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Put uploads the blob in a single request, buffering it to sign its contents
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read blob: %w", err)
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, nil, header, body)
+	if err != nil {
+		return fmt.Errorf("failed to put blob: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get downloads the blob stored under key
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes the blob stored under key
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// listBucketResult is the ListObjectsV2 response
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List describes the blobs whose keys start with prefix, following continuation tokens
+// until the listing is complete
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode blob listing: %w", err)
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Location is the blob's s3:// URL
+func (s *S3) Location(key string) string {
+	return "s3://" + s.cfg.Bucket + "/" + key
+}
+
+// s3Error is the body of an S3 error response
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do sends a signed request for key, or for the bucket itself when key is empty. Error
+// responses are returned as errors, with missing keys matching ErrNotFound.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	path := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		path += "/" + s.cfg.Bucket
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	path += "/" + key
+	u.Path, u.RawPath = path, escapePath(path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	s.sign(req, payloadHash, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var s3Err s3Error
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&s3Err)
+	if resp.StatusCode == http.StatusNotFound && (s3Err.Code == "" || s3Err.Code == "NoSuchKey") {
+		return nil, ErrNotFound
+	}
+	if s3Err.Code == "" {
+		return nil, fmt.Errorf("s3 returned %s", resp.Status)
+	}
+	return nil, fmt.Errorf("s3 returned %s: %s: %s", resp.Status, s3Err.Code, s3Err.Message)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header covering the host and every
+// header already set on req
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath percent-encodes everything in path but unreserved characters and slashes,
+// as SigV4 canonical URIs require
+func escapePath(path string) string {
+	return strings.ReplaceAll(uriEncode(path), "%2F", "/")
+}
+
+// canonicalQuery encodes query sorted by key with SigV4's escaping, which differs from
+// url.Values.Encode in escaping spaces as %20
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			pairs = append(pairs, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
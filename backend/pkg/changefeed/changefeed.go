@@ -0,0 +1,166 @@
+// Package changefeed relays the store's change feed to external systems, so they can
+// mirror devices, readings and alerts without polling the API
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultBatchSize is how many changes a relay reads and publishes at once
+const DefaultBatchSize = 500
+
+// Publisher delivers changes to an external system. Publish must not return until the
+// system has accepted every change, in order; the relay advances its cursor after it
+// returns, and publishes the whole batch again if it fails.
+type Publisher interface {
+	Publish(ctx context.Context, changes []*api.ChangeEvent) error
+}
+
+// Store holds the change feed and its consumers' cursors; storer.Interface satisfies it
+type Store interface {
+	ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error)
+	GetChangeCursor(ctx context.Context, consumer string) (int64, error)
+	SetChangeCursor(ctx context.Context, consumer string, seq int64) error
+}
+
+type Option func(*Relay)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(r *Relay) {
+		r.log = logger
+	}
+}
+
+// WithBatchSize sets how many changes are read and published at once
+func WithBatchSize(n int) Option {
+	return func(r *Relay) {
+		r.batchSize = n
+	}
+}
+
+// Relay publishes the change feed to a Publisher, keeping its place under a named
+// cursor in the store. Delivery is at least once: changes published just before a
+// crash or failed cursor update are published again. Only one relay may use a name at a
+// time, so relays are run under a lock.
+type Relay struct {
+	store     Store
+	name      string
+	publisher Publisher
+	batchSize int
+	log       zerolog.Logger
+}
+
+func NewRelay(store Store, name string, publisher Publisher, opts ...Option) *Relay {
+	r := &Relay{
+		store:     store,
+		name:      name,
+		publisher: publisher,
+		batchSize: DefaultBatchSize,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Relay) logCtx(ctx context.Context) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = r.log.With()
+	}
+	return logging.Component(ll.Str("component", "changefeed").Str("consumer", r.name).Logger(), "changefeed")
+}
+
+// Tick publishes every change after the relay's cursor, a batch at a time, advancing the
+// cursor after each batch. It has the signature of a lease.Locker loop.
+func (r *Relay) Tick(ctx context.Context, now time.Time) error {
+	cursor, err := r.store.GetChangeCursor(ctx, r.name)
+	if err != nil {
+		return err
+	}
+	for {
+		changes, err := r.store.ListChanges(ctx, cursor, r.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := r.publisher.Publish(ctx, changes); err != nil {
+			return fmt.Errorf("failed to publish changes after %d: %w", cursor, err)
+		}
+		cursor = changes[len(changes)-1].Seq
+		if err := r.store.SetChangeCursor(ctx, r.name, cursor); err != nil {
+			return err
+		}
+		ll := r.logCtx(ctx)
+		ll.Debug().Int("changes", len(changes)).Int64("cursor", cursor).Msg("published changes")
+		if len(changes) < r.batchSize {
+			return nil
+		}
+	}
+}
+
+// Pruner deletes changes from the store once they are older than a retention period
+type Pruner interface {
+	DeleteOldChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Prune returns a lease.Locker loop deleting changes older than retention. Relays that
+// fall further behind than that skip the pruned changes.
+func Prune(store Pruner, retention time.Duration) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		deleted, err := store.DeleteOldChanges(ctx, now.Add(-retention))
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			log.Ctx(ctx).Info().Int64("deleted", deleted).Msg("pruned change feed")
+		}
+		return nil
+	}
+}
+
+// Recorder appends changes to the feed; storer.Interface satisfies it
+type Recorder interface {
+	RecordChange(ctx context.Context, change *api.ChangeEvent) error
+}
+
+// AlertRecorder is a notify.Notifier adding each alert to the change feed. Alerts are
+// not otherwise stored, so this is how they reach the feed.
+type AlertRecorder struct {
+	store Recorder
+}
+
+func NewAlertRecorder(store Recorder) *AlertRecorder {
+	return &AlertRecorder{store: store}
+}
+
+func (a *AlertRecorder) Notify(ctx context.Context, alert *api.Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	return a.store.RecordChange(ctx, &api.ChangeEvent{Type: api.ChangeAlertFired, EntityID: alert.ID, Data: data})
+}
+
+// Ack adds an acknowledgement to the change feed. It is a notify.AckFunc, so channels
+// which take acknowledgements can record them.
+func (a *AlertRecorder) Ack(ctx context.Context, ack *api.AlertAck) error {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert acknowledgement: %w", err)
+	}
+	return a.store.RecordChange(ctx, &api.ChangeEvent{Type: api.ChangeAlertAcknowledged, EntityID: ack.AlertID, Data: data})
+}
@@ -0,0 +1,93 @@
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// fakePublisher records published batches, failing while err is set
+type fakePublisher struct {
+	batches [][]int64
+	err     error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, changes []*api.ChangeEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	var seqs []int64
+	for _, change := range changes {
+		seqs = append(seqs, change.Seq)
+	}
+	p.batches = append(p.batches, seqs)
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	alerts := NewAlertRecorder(store)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := alerts.Notify(ctx, &api.Alert{ID: id, Severity: api.AlertSeverityWarning, Title: "Leak"}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	pub := &fakePublisher{}
+	relay := NewRelay(store, "test", pub, WithBatchSize(2))
+	if err := relay.Tick(ctx, time.Now()); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if len(pub.batches) != 3 || len(pub.batches[2]) != 1 || pub.batches[2][0] != 5 {
+		t.Errorf("Expected batches [1 2] [3 4] [5], got %v", pub.batches)
+	}
+	if cursor, _ := store.GetChangeCursor(ctx, "test"); cursor != 5 {
+		t.Errorf("Expected cursor 5, got %d", cursor)
+	}
+
+	alerts.Notify(ctx, &api.Alert{ID: "f"})
+	pub.err = errors.New("broker down")
+	if err := relay.Tick(ctx, time.Now()); err == nil {
+		t.Error("Expected Tick to fail while the publisher does")
+	}
+	if cursor, _ := store.GetChangeCursor(ctx, "test"); cursor != 5 {
+		t.Errorf("Expected the cursor to stay at 5 after a failure, got %d", cursor)
+	}
+	pub.err = nil
+	if err := relay.Tick(ctx, time.Now()); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if last := pub.batches[len(pub.batches)-1]; len(last) != 1 || last[0] != 6 {
+		t.Errorf("Expected the failed change to be published again, got %v", last)
+	}
+
+	changes, _ := store.ListChanges(ctx, 5, 1)
+	if len(changes) != 1 || changes[0].Type != api.ChangeAlertFired || changes[0].EntityID != "f" {
+		t.Errorf("Expected alert f in the feed, got %+v", changes)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	NewAlertRecorder(store).Notify(ctx, &api.Alert{ID: "old"})
+
+	prune := Prune(store, time.Hour)
+	if err := prune(ctx, time.Now()); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	if changes, _ := store.ListChanges(ctx, 0, 10); len(changes) != 1 {
+		t.Errorf("Expected a recent change to be kept, got %d changes", len(changes))
+	}
+	if err := prune(ctx, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("prune() error = %v", err)
+	}
+	if changes, _ := store.ListChanges(ctx, 0, 10); len(changes) != 0 {
+		t.Errorf("Expected an old change to be pruned, got %d changes", len(changes))
+	}
+}
@@ -0,0 +1,106 @@
+package changefeed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const defaultKafkaTimeout = 30 * time.Second
+
+type KafkaOption func(*Kafka)
+
+func WithKafkaHTTPClient(client *http.Client) KafkaOption {
+	return func(k *Kafka) {
+		k.client = client
+	}
+}
+
+// Kafka publishes changes to a Kafka topic through a REST Proxy speaking the v2 API,
+// such as Confluent's or Redpanda's. Each batch is one request; records are keyed by
+// entity ID, so every change to an entity lands on one partition in order.
+type Kafka struct {
+	topicURL string
+	client   *http.Client
+}
+
+// NewKafka creates a publisher to topic through the REST Proxy at proxyURL
+func NewKafka(proxyURL, topic string, opts ...KafkaOption) *Kafka {
+	k := &Kafka{
+		topicURL: strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:   &http.Client{Timeout: defaultKafkaTimeout},
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	return k
+}
+
+type kafkaRecord struct {
+	Key   string           `json:"key"`
+	Value *api.ChangeEvent `json:"value"`
+}
+
+type kafkaProduceRequest struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int     `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (k *Kafka) Publish(ctx context.Context, changes []*api.ChangeEvent) error {
+	body := kafkaProduceRequest{Records: make([]kafkaRecord, len(changes))}
+	for i, change := range changes {
+		body.Records[i] = kafkaRecord{Key: change.EntityID, Value: change}
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal records: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.topicURL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	// The proxy accepts the request even when individual records fail
+	var produced kafkaProduceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&produced); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	for i, offset := range produced.Offsets {
+		if (offset.ErrorCode != nil || offset.Error != nil) && i < len(changes) {
+			msg := "unknown error"
+			if offset.Error != nil {
+				msg = *offset.Error
+			}
+			return fmt.Errorf("failed to produce change %d: %s", changes[i].Seq, msg)
+		}
+	}
+	return nil
+}
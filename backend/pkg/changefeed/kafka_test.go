@@ -0,0 +1,52 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestKafka(t *testing.T) {
+	var got kafkaProduceRequest
+	var path, contentType string
+	failRecord := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/vnd.kafka.v2+json")
+		if failRecord {
+			w.Write([]byte(`{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"Kafka error: topic not found"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":41,"error_code":null,"error":null},{"partition":1,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	pub := NewKafka(server.URL+"/", "lifesupport-changes")
+	changes := []*api.ChangeEvent{
+		{Seq: 1, Type: api.ChangeDeviceCreated, EntityID: "pump-3"},
+		{Seq: 2, Type: api.ChangeReadingStored, EntityID: "pump-3/power"},
+	}
+	if err := pub.Publish(context.Background(), changes); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if path != "/topics/lifesupport-changes" {
+		t.Errorf("Expected path /topics/lifesupport-changes, got %s", path)
+	}
+	if contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("Expected the JSON embedded format, got %s", contentType)
+	}
+	if len(got.Records) != 2 || got.Records[1].Key != "pump-3/power" || got.Records[1].Value.Seq != 2 {
+		t.Errorf("Unexpected records %+v", got.Records)
+	}
+
+	failRecord = true
+	if err := pub.Publish(context.Background(), changes[:1]); err == nil || !strings.Contains(err.Error(), "topic not found") {
+		t.Errorf("Expected a record error, got %v", err)
+	}
+}
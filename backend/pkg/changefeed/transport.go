@@ -0,0 +1,37 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/transport"
+)
+
+// Transport publishes changes on the internal transport, one message per change on the
+// subject prefix followed by the change type's parts, e.g. lifesupport.changes.device.created
+// on JetStream or lifesupport/changes/device/created on MQTT
+type Transport struct {
+	transport transport.Transport
+	subject   string
+}
+
+func NewTransport(t transport.Transport, subject string) *Transport {
+	return &Transport{transport: t, subject: subject}
+}
+
+func (t *Transport) Publish(ctx context.Context, changes []*api.ChangeEvent) error {
+	for _, change := range changes {
+		payload, err := json.Marshal(change)
+		if err != nil {
+			return fmt.Errorf("failed to marshal change %d: %w", change.Seq, err)
+		}
+		parts := append([]string{t.subject}, strings.Split(string(change.Type), ".")...)
+		if err := t.transport.Publish(ctx, t.transport.Subject(parts...), payload); err != nil {
+			return fmt.Errorf("failed to publish change %d: %w", change.Seq, err)
+		}
+	}
+	return nil
+}
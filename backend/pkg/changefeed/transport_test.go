@@ -0,0 +1,50 @@
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/transport"
+)
+
+// fakeTransport records published messages by subject
+type fakeTransport struct {
+	subjects []string
+	payloads [][]byte
+}
+
+func (f *fakeTransport) Publish(ctx context.Context, subject string, payload []byte) error {
+	f.subjects = append(f.subjects, subject)
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+func (f *fakeTransport) Subscribe(ctx context.Context, subject, durable string, handler transport.Handler) (transport.Subscription, error) {
+	return nil, nil
+}
+
+func (f *fakeTransport) Subject(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+func TestTransport(t *testing.T) {
+	ft := &fakeTransport{}
+	pub := NewTransport(ft, "lifesupport/changes")
+	changes := []*api.ChangeEvent{
+		{Seq: 1, Type: api.ChangeDeviceCreated, EntityID: "pump-3"},
+		{Seq: 2, Type: api.ChangeReadingStored, EntityID: "pump-3/power"},
+	}
+	if err := pub.Publish(context.Background(), changes); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(ft.subjects) != 2 || ft.subjects[0] != "lifesupport/changes/device/created" || ft.subjects[1] != "lifesupport/changes/reading/stored" {
+		t.Fatalf("Expected a message per change by type, got %v", ft.subjects)
+	}
+	var change api.ChangeEvent
+	if err := json.Unmarshal(ft.payloads[1], &change); err != nil || change.Seq != 2 || change.EntityID != "pump-3/power" {
+		t.Errorf("Expected change 2, got %+v (%v)", change, err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package chaos injects failures between the worker and its devices: slow responses,
+// lost readings and devices dropping offline. It exists to prove that alerting,
+// watchdogs and failsafes trigger as designed, and must never be enabled in production.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/ingest"
+	"lifesupport/backend/pkg/storer"
+
+	"github.com/rs/zerolog"
+)
+
+// Config describes the failures to inject. Zero values disable each kind of failure.
+type Config struct {
+	// MaxDelay bounds the random delay added before each driver call
+	MaxDelay time.Duration
+	// DropRate is the fraction of device events and ingested readings silently dropped
+	DropRate float64
+	// OfflineRate is the chance, per device interaction, of the device dropping offline
+	OfflineRate float64
+	// OfflineDuration is how long a device stays offline once dropped
+	OfflineDuration time.Duration
+}
+
+// Enabled reports whether the config injects any failure
+func (c Config) Enabled() bool {
+	return c.MaxDelay > 0 || c.DropRate > 0 || c.OfflineRate > 0
+}
+
+type Option func(*Monkey)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(m *Monkey) {
+		m.log = logger
+	}
+}
+
+// WithRand sets the random source, for reproducible runs
+func WithRand(r *rand.Rand) Option {
+	return func(m *Monkey) {
+		m.rand = r
+	}
+}
+
+// Monkey decides which interactions fail. One Monkey should wrap every path to the
+// same devices, so a device it takes offline is offline for commands, status and
+// readings alike.
+type Monkey struct {
+	cfg Config
+	log zerolog.Logger
+	now func() time.Time
+
+	lock    sync.Mutex
+	rand    *rand.Rand
+	offline map[string]time.Time // device ID -> back online at
+}
+
+func New(cfg Config, opts ...Option) *Monkey {
+	m := &Monkey{
+		cfg:     cfg,
+		log:     zerolog.Nop(),
+		now:     time.Now,
+		rand:    rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		offline: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// chance reports true with probability p
+func (m *Monkey) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.rand.Float64() < p
+}
+
+// delay sleeps for a random duration up to MaxDelay, or until ctx is done
+func (m *Monkey) delay(ctx context.Context) error {
+	if m.cfg.MaxDelay <= 0 {
+		return nil
+	}
+	m.lock.Lock()
+	d := time.Duration(m.rand.Int64N(int64(m.cfg.MaxDelay)))
+	m.lock.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isOffline reports whether deviceID is offline, possibly taking it offline now
+func (m *Monkey) isOffline(deviceID string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.now()
+	if until, ok := m.offline[deviceID]; ok {
+		if now.Before(until) {
+			return true
+		}
+		delete(m.offline, deviceID)
+	}
+	if m.cfg.OfflineRate <= 0 || m.rand.Float64() >= m.cfg.OfflineRate {
+		return false
+	}
+	m.offline[deviceID] = now.Add(m.cfg.OfflineDuration)
+	m.log.Warn().Str("device_id", deviceID).Dur("duration", m.cfg.OfflineDuration).Msg("chaos: device dropped offline")
+	return true
+}
+
+// dropped reports whether a message from deviceID should be lost
+func (m *Monkey) dropped(deviceID string) bool {
+	return m.isOffline(deviceID) || m.chance(m.cfg.DropRate)
+}
+
+// Driver wraps next so its calls are delayed and fail for devices that are offline
+func (m *Monkey) Driver(next drivers.Driver) drivers.Driver {
+	return &driver{next: next, monkey: m}
+}
+
+// EventHandler wraps next so events are dropped at DropRate and while their device is
+// offline
+func (m *Monkey) EventHandler(next drivers.EventHandler) drivers.EventHandler {
+	return func(ctx context.Context, ev *api.ResourceEvent) {
+		if m.dropped(ev.DeviceID) {
+			m.log.Debug().Str("device_id", ev.DeviceID).Str("resource_id", ev.ResourceID).Msg("chaos: dropped event")
+			return
+		}
+		next(ctx, ev)
+	}
+}
+
+// ReadingStore wraps next so ingested readings are dropped at DropRate and while their
+// device is offline. Dropped readings report success, as a reading lost on the way
+// would never have reached the ingester.
+func (m *Monkey) ReadingStore(next ingest.ReadingStore) ingest.ReadingStore {
+	return &readingStore{next: next, monkey: m}
+}
+
+type driver struct {
+	next   drivers.Driver
+	monkey *Monkey
+}
+
+func (d *driver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error) {
+	if err := d.monkey.delay(ctx); err != nil {
+		return nil, err
+	}
+	return d.next.DiscoverDevices(ctx, opt, s)
+}
+
+func (d *driver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource drivers.Statuser) (*api.SensorReading, error) {
+	if err := d.monkey.delay(ctx); err != nil {
+		return nil, err
+	}
+	if d.monkey.isOffline(resource.GetDeviceID()) {
+		return nil, fmt.Errorf("chaos: %s: %w", resource.GetDeviceID(), drivers.ErrDeviceOffline)
+	}
+	return d.next.GetLastStatus(ctx, opt, resource)
+}
+
+func (d *driver) SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	if err := d.monkey.delay(ctx); err != nil {
+		return nil, err
+	}
+	if d.monkey.isOffline(actuator.DeviceID) {
+		return nil, fmt.Errorf("chaos: %s: %w", actuator.DeviceID, drivers.ErrDeviceOffline)
+	}
+	return d.next.SetActuator(ctx, actuator, cmd)
+}
+
+type readingStore struct {
+	next   ingest.ReadingStore
+	monkey *Monkey
+}
+
+func (r *readingStore) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	if r.monkey.dropped(rec.DeviceID) {
+		r.monkey.log.Debug().Str("device_id", rec.DeviceID).Str("sensor_id", rec.SensorID).Msg("chaos: dropped reading")
+		return nil
+	}
+	return r.next.StoreSensorReading(ctx, rec)
+}
@@ -0,0 +1,118 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+)
+
+type fakeDriver struct {
+	commands int
+}
+
+func (f *fakeDriver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error) {
+	return &api.DiscoveryResult{}, nil
+}
+
+func (f *fakeDriver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource drivers.Statuser) (*api.SensorReading, error) {
+	return &api.SensorReading{Value: 1, Valid: true}, nil
+}
+
+func (f *fakeDriver) SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	f.commands++
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+type fakeStore struct {
+	stored int
+}
+
+func (f *fakeStore) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	f.stored++
+	return nil
+}
+
+func seeded() Option {
+	return WithRand(rand.New(rand.NewPCG(1, 2)))
+}
+
+func TestDriver_Disabled(t *testing.T) {
+	next := &fakeDriver{}
+	d := New(Config{}, seeded()).Driver(next)
+	actuator := &api.Actuator{ID: "switch:0", DeviceID: "pump"}
+	for i := 0; i < 100; i++ {
+		if _, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "on"}); err != nil {
+			t.Fatalf("SetActuator() error = %v", err)
+		}
+	}
+	if next.commands != 100 {
+		t.Errorf("Expected 100 commands to reach the driver, got %d", next.commands)
+	}
+}
+
+func TestDriver_Offline(t *testing.T) {
+	next := &fakeDriver{}
+	m := New(Config{OfflineRate: 1, OfflineDuration: time.Minute}, seeded())
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	d := m.Driver(next)
+	actuator := &api.Actuator{ID: "switch:0", DeviceID: "pump"}
+
+	_, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "on"})
+	if !errors.Is(err, drivers.ErrDeviceOffline) {
+		t.Fatalf("Expected ErrDeviceOffline, got %v", err)
+	}
+	if _, err := d.GetLastStatus(context.Background(), api.StatusOptions{}, actuator); !errors.Is(err, drivers.ErrDeviceOffline) {
+		t.Errorf("Expected status of an offline device to fail, got %v", err)
+	}
+	if next.commands != 0 {
+		t.Errorf("Expected no commands to reach an offline device, got %d", next.commands)
+	}
+
+	// The device comes back once its outage ends, unless it drops again
+	m.cfg.OfflineRate = 0
+	now = now.Add(2 * time.Minute)
+	if _, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "on"}); err != nil {
+		t.Errorf("Expected device back online after its outage, got %v", err)
+	}
+}
+
+func TestDriver_Delay(t *testing.T) {
+	d := New(Config{MaxDelay: time.Hour}, seeded()).Driver(&fakeDriver{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := d.SetActuator(ctx, &api.Actuator{ID: "switch:0", DeviceID: "pump"}, api.ActuatorCommand{Action: "on"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a delayed call to honour its deadline, got %v", err)
+	}
+}
+
+func TestReadingStore_Drops(t *testing.T) {
+	next := &fakeStore{}
+	s := New(Config{DropRate: 0.5}, seeded()).ReadingStore(next)
+	for i := 0; i < 1000; i++ {
+		if err := s.StoreSensorReading(context.Background(), &api.ReadingRecord{DeviceID: "probe", SensorID: "ph"}); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	if next.stored < 400 || next.stored > 600 {
+		t.Errorf("Expected about half of 1000 readings stored, got %d", next.stored)
+	}
+}
+
+func TestEventHandler_Drops(t *testing.T) {
+	delivered := 0
+	h := New(Config{DropRate: 1}, seeded()).EventHandler(func(context.Context, *api.ResourceEvent) {
+		delivered++
+	})
+	h(context.Background(), &api.ResourceEvent{DeviceID: "pump", ResourceID: "switch:0"})
+	if delivered != 0 {
+		t.Errorf("Expected event to be dropped, got %d delivered", delivered)
+	}
+}
@@ -0,0 +1,236 @@
+package compact
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// This file implements the subset of CBOR (RFC 8949) needed for the compact reading
+// form: unsigned and negative integers, text strings, arrays, floats, booleans and null.
+
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+var errTruncated = errors.New("cbor: unexpected end of data")
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) head(major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, m|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, m|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, m|25)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, m|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, m|27)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
+
+func (e *encoder) int(v int64) {
+	if v >= 0 {
+		e.head(majorUint, uint64(v))
+	} else {
+		e.head(majorNegInt, uint64(-1-v))
+	}
+}
+
+func (e *encoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) array(n int) {
+	e.head(majorArray, uint64(n))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, 0xf5)
+	} else {
+		e.buf = append(e.buf, 0xf4)
+	}
+}
+
+// float writes v using the shortest of float32 or float64 that preserves it exactly,
+// or as an integer when it is whole
+func (e *encoder) float(v float64) {
+	if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+		e.int(int64(v))
+		return
+	}
+	if f32 := float32(v); float64(f32) == v {
+		e.buf = append(e.buf, majorSimple<<5|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(f32))
+		return
+	}
+	e.buf = append(e.buf, majorSimple<<5|27)
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// decode parses a single CBOR item into int64, float64, string, bool, nil or []any
+func decode(data []byte) (any, error) {
+	d := &decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("cbor: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+const maxDepth = 16
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) head() (major byte, info byte, arg uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		b, err = d.next(1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(b[0]), nil
+	case info == 25:
+		b, err = d.next(2)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err = d.next(4)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err = d.next(8)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return major, info, binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+}
+
+func (d *decoder) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("cbor: nesting too deep")
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), nil
+	case majorNegInt:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), nil
+	case majorText:
+		b, err := d.next(int(arg))
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case majorArray:
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, errTruncated
+		}
+		out := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case majorSimple:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			return halfToFloat(uint16(arg)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(arg))), nil
+		case 27:
+			return math.Float64frombits(arg), nil
+		}
+		return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+	default:
+		return nil, fmt.Errorf("cbor: unsupported major type %d", major)
+	}
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := (h >> 10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, int(exp)-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,163 @@
+// Package compact implements a CBOR array encoding of sensor readings for bandwidth
+// constrained links, such as edge gateways on cellular connections.
+//
+// A batch is encoded as
+//
+//	[1, base_ms, [device_id, ...], [[device_index, sensor_id, offset_ms, value, unit?, valid?], ...]]
+//
+// where each reading's timestamp is base_ms+offset_ms since the Unix epoch, device IDs are
+// listed once and referenced by index, unit defaults to "" and valid defaults to true.
+package compact
+
+import (
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ContentType identifies the compact form in HTTP requests
+const ContentType = "application/cbor"
+
+const version = 1
+
+// Encode packs readings into the compact form
+func Encode(readings []*api.ReadingRecord) []byte {
+	var base int64
+	for i, r := range readings {
+		if ms := r.Reading.Timestamp.UnixMilli(); i == 0 || ms < base {
+			base = ms
+		}
+	}
+
+	var devices []string
+	deviceIndex := make(map[string]int)
+	for _, r := range readings {
+		if _, ok := deviceIndex[r.DeviceID]; !ok {
+			deviceIndex[r.DeviceID] = len(devices)
+			devices = append(devices, r.DeviceID)
+		}
+	}
+
+	e := &encoder{}
+	e.array(4)
+	e.int(version)
+	e.int(base)
+	e.array(len(devices))
+	for _, d := range devices {
+		e.text(d)
+	}
+	e.array(len(readings))
+	for _, r := range readings {
+		fields := 4
+		if !r.Reading.Valid {
+			fields = 6
+		} else if r.Reading.Unit != "" {
+			fields = 5
+		}
+		e.array(fields)
+		e.int(int64(deviceIndex[r.DeviceID]))
+		e.text(r.SensorID)
+		e.int(r.Reading.Timestamp.UnixMilli() - base)
+		e.float(r.Reading.Value)
+		if fields > 4 {
+			e.text(string(r.Reading.Unit))
+		}
+		if fields > 5 {
+			e.bool(r.Reading.Valid)
+		}
+	}
+	return e.buf
+}
+
+// Decode unpacks readings from the compact form
+func Decode(data []byte) ([]*api.ReadingRecord, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	top, ok := v.([]any)
+	if !ok || len(top) != 4 {
+		return nil, fmt.Errorf("compact: expected a 4 element array")
+	}
+	if ver, _ := top[0].(int64); ver != version {
+		return nil, fmt.Errorf("compact: unsupported version %v", top[0])
+	}
+	base, ok := top[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("compact: base timestamp must be an integer")
+	}
+	rawDevices, ok := top[2].([]any)
+	if !ok {
+		return nil, fmt.Errorf("compact: device table must be an array")
+	}
+	devices := make([]string, len(rawDevices))
+	for i, d := range rawDevices {
+		if devices[i], ok = d.(string); !ok {
+			return nil, fmt.Errorf("compact: device %d is not a string", i)
+		}
+	}
+	rows, ok := top[3].([]any)
+	if !ok {
+		return nil, fmt.Errorf("compact: readings must be an array")
+	}
+
+	out := make([]*api.ReadingRecord, 0, len(rows))
+	for i, raw := range rows {
+		row, ok := raw.([]any)
+		if !ok || len(row) < 4 || len(row) > 6 {
+			return nil, fmt.Errorf("compact: reading %d must be an array of 4 to 6 elements", i)
+		}
+		idx, ok := row[0].(int64)
+		if !ok || idx < 0 || idx >= int64(len(devices)) {
+			return nil, fmt.Errorf("compact: reading %d has invalid device index", i)
+		}
+		sensorID, ok := row[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("compact: reading %d has invalid sensor id", i)
+		}
+		offset, ok := row[2].(int64)
+		if !ok {
+			return nil, fmt.Errorf("compact: reading %d has invalid time offset", i)
+		}
+		value, ok := number(row[3])
+		if !ok {
+			return nil, fmt.Errorf("compact: reading %d has invalid value", i)
+		}
+		rec := &api.ReadingRecord{
+			DeviceID: devices[idx],
+			SensorID: sensorID,
+			Reading: api.SensorReading{
+				Value:     value,
+				Timestamp: time.UnixMilli(base + offset).UTC(),
+				Valid:     true,
+			},
+		}
+		if len(row) > 4 {
+			unit, ok := row[4].(string)
+			if !ok {
+				return nil, fmt.Errorf("compact: reading %d has invalid unit", i)
+			}
+			rec.Reading.Unit = api.Unit(unit)
+		}
+		if len(row) > 5 {
+			valid, ok := row[5].(bool)
+			if !ok {
+				return nil, fmt.Errorf("compact: reading %d has invalid validity flag", i)
+			}
+			rec.Reading.Valid = valid
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,105 @@
+package compact
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func testReadings() []*api.ReadingRecord {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []*api.ReadingRecord{
+		{DeviceID: "gw-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Unit: api.UnitCelsius, Timestamp: base, Valid: true}},
+		{DeviceID: "gw-1", SensorID: "ph", Reading: api.SensorReading{Value: 8.1, Timestamp: base.Add(1500 * time.Millisecond), Valid: true}},
+		{DeviceID: "gw-2", SensorID: "level", Reading: api.SensorReading{Value: -3, Timestamp: base.Add(time.Second), Valid: false}},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := testReadings()
+	out, err := Decode(Encode(in))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		for i := range out {
+			t.Logf("got %d: %+v", i, *out[i])
+		}
+		t.Errorf("Round trip mismatch")
+	}
+}
+
+func TestEncode_SmallerThanJSON(t *testing.T) {
+	var readings []*api.ReadingRecord
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		readings = append(readings, &api.ReadingRecord{
+			DeviceID: "shellyplus1pm-a8032ab12345",
+			SensorID: "switch:0",
+			Reading:  api.SensorReading{Value: 42.25, Unit: api.UnitWatts, Timestamp: base.Add(time.Duration(i) * 5 * time.Second), Valid: true},
+		})
+	}
+	compact := Encode(readings)
+	js, _ := json.Marshal(readings)
+	if len(compact)*3 > len(js) {
+		t.Errorf("Expected compact form to be under a third of JSON size, got %d vs %d bytes", len(compact), len(js))
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	valid := Encode(testReadings())
+	tests := map[string][]byte{
+		"empty":       {},
+		"truncated":   valid[:len(valid)-2],
+		"not array":   {0x01},
+		"huge array":  {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"bad version": {0x84, 0x02, 0x00, 0x80, 0x80},
+	}
+	for name, data := range tests {
+		if _, err := Decode(data); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestHalfToFloat(t *testing.T) {
+	tests := map[uint16]float64{0x3c00: 1, 0xc000: -2, 0x3e00: 1.5, 0x0001: 5.960464477539063e-08}
+	for in, want := range tests {
+		if got := halfToFloat(in); got != want {
+			t.Errorf("halfToFloat(%#x) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func benchmarkBatch() []*api.ReadingRecord {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	readings := make([]*api.ReadingRecord, 50)
+	for i := range readings {
+		readings[i] = &api.ReadingRecord{
+			DeviceID: "gw-1",
+			SensorID: "sensor",
+			Reading:  api.SensorReading{Value: 25.1 + float64(i), Unit: api.UnitCelsius, Timestamp: base.Add(time.Duration(i) * time.Second), Valid: true},
+		}
+	}
+	return readings
+}
+
+func BenchmarkEncode(b *testing.B) {
+	readings := benchmarkBatch()
+	for b.Loop() {
+		Encode(readings)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := Encode(benchmarkBatch())
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		if _, err := Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package control
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BrokenReferenceSource lists rules left depending on deleted resources
+type BrokenReferenceSource interface {
+	ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error)
+}
+
+type ruleKey struct {
+	kind, name string
+}
+
+// BrokenRules tracks which rules are disabled by broken references. The list is fetched
+// at most once per refresh interval so checks on the fast path stay cheap; if it cannot
+// be fetched the last known state is kept.
+type BrokenRules struct {
+	source  BrokenReferenceSource
+	refresh time.Duration
+	now     func() time.Time
+
+	lock    sync.Mutex
+	fetched time.Time
+	broken  map[ruleKey]bool
+}
+
+func NewBrokenRules(source BrokenReferenceSource, refresh time.Duration) *BrokenRules {
+	return &BrokenRules{
+		source:  source,
+		refresh: refresh,
+		now:     time.Now,
+		broken:  make(map[ruleKey]bool),
+	}
+}
+
+// Disabled reports whether the rule has broken references
+func (b *BrokenRules) Disabled(ctx context.Context, ref api.RuleRef) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if now := b.now(); b.fetched.IsZero() || now.Sub(b.fetched) >= b.refresh {
+		refs, err := b.source.ListBrokenReferences(ctx)
+		if err != nil {
+			ll := logging.Component(*log.Ctx(ctx), "control")
+			ll.Warn().Err(err).Str("component", "control").Msg("unable to refresh broken references")
+		} else {
+			b.broken = make(map[ruleKey]bool, len(refs))
+			for _, r := range refs {
+				b.broken[ruleKey{r.Kind, r.Name}] = true
+			}
+		}
+		b.fetched = now
+	}
+	return b.broken[ruleKey{ref.Kind, ref.Name}]
+}
+
+// Guard wraps a reaction so it is skipped while its rule is disabled
+func (b *BrokenRules) Guard(ref api.RuleRef, next Reaction) Reaction {
+	return &guardedReaction{ref: ref, next: next, rules: b}
+}
+
+type guardedReaction struct {
+	ref   api.RuleRef
+	next  Reaction
+	rules *BrokenRules
+}
+
+func (g *guardedReaction) HandleEvent(ctx context.Context, tag string, ev *api.ResourceEvent) error {
+	if g.rules.Disabled(ctx, g.ref) {
+		return nil
+	}
+	return g.next.HandleEvent(ctx, tag, ev)
+}
+
+// GuardLoop wraps a control loop's evaluation so it is skipped while its rule is disabled
+func (b *BrokenRules) GuardLoop(ref api.RuleRef, fn func(ctx context.Context, now time.Time) error) func(ctx context.Context, now time.Time) error {
+	return func(ctx context.Context, now time.Time) error {
+		if b.Disabled(ctx, ref) {
+			traceFrom(ctx).disable()
+			return nil
+		}
+		return fn(ctx, now)
+	}
+}
@@ -0,0 +1,59 @@
+package control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type staticBrokenSource struct {
+	refs  []*api.BrokenReference
+	calls int
+}
+
+func (s *staticBrokenSource) ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error) {
+	s.calls++
+	return s.refs, nil
+}
+
+func TestBrokenRules_Guard(t *testing.T) {
+	source := &staticBrokenSource{}
+	broken := NewBrokenRules(source, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	broken.now = func() time.Time { return now }
+
+	rule := api.EventReaction{Name: "ato-high", Tag: "float.ato-high", Field: "state", Value: 1, ActuatorTags: []string{"pump.ato"}, Action: "off"}
+	cmd := &recordingCommander{}
+	resolver := &staticResolver{tags: map[string][]string{"shelly-1/input:0": {"float.ato-high"}}}
+	fp := NewFastPath(resolver, broken.Guard(rule.Ref(), NewTriggerReaction(rule, cmd)))
+
+	event := func(v float64) *api.ResourceEvent {
+		return &api.ResourceEvent{DeviceID: "shelly-1", ResourceID: "input:0", Field: "state", Reading: api.SensorReading{Value: v, Valid: true}}
+	}
+	ctx := context.Background()
+	fp.HandleEvent(ctx, event(1))
+
+	// The pump was deleted; the cached state hides it until the next refresh
+	source.refs = []*api.BrokenReference{{Kind: api.RuleKindEventReaction, Name: "ato-high", Tag: "pump.ato"}}
+	fp.HandleEvent(ctx, event(0))
+	now = now.Add(time.Minute)
+	fp.HandleEvent(ctx, event(1))
+
+	if want := []string{"off:pump.ato"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected the disabled rule to be skipped, got %v", cmd.calls)
+	}
+	if source.calls != 2 {
+		t.Errorf("Expected 2 refreshes, got %d", source.calls)
+	}
+
+	source.refs = nil
+	now = now.Add(time.Minute)
+	fp.HandleEvent(ctx, event(0))
+	fp.HandleEvent(ctx, event(1))
+	if len(cmd.calls) != 2 {
+		t.Errorf("Expected the rule to run again once cleared, got %v", cmd.calls)
+	}
+}
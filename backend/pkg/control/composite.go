@@ -0,0 +1,117 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+// CompositeDetector evaluates a composite rule's condition and fires the rule once the
+// condition has held for the rule's duration
+type CompositeDetector struct {
+	rule      api.CompositeRule
+	commander Commander
+	readings  ReadingSource
+	notifier  notify.Notifier
+
+	lock  sync.Mutex
+	since time.Time // when the condition was first seen to hold; zero while it doesn't
+	fired bool
+}
+
+func NewCompositeDetector(rule api.CompositeRule, commander Commander, readings ReadingSource, notifier notify.Notifier) *CompositeDetector {
+	if rule.Severity == "" {
+		rule.Severity = api.AlertSeverityWarning
+	}
+	return &CompositeDetector{
+		rule:      rule,
+		commander: commander,
+		readings:  readings,
+		notifier:  notifier,
+	}
+}
+
+// Fired reports whether the rule has fired. It re-arms once the condition is seen not to
+// hold.
+func (d *CompositeDetector) Fired() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.fired
+}
+
+// Check evaluates the rule at now
+func (d *CompositeDetector) Check(ctx context.Context, now time.Time) error {
+	if err := d.rule.Validate(); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	status := EvaluateCondition(ctx, d.readings, d.rule.Condition)
+	rt := traceFrom(ctx)
+	rt.condition(status)
+	if status.Result != api.ConditionTrue {
+		d.since = time.Time{}
+		d.fired = false
+		rt.note("condition is %s", status.Result)
+		return nil
+	}
+	if d.since.IsZero() {
+		d.since = now
+	}
+	held := now.Sub(d.since)
+	switch {
+	case held < d.rule.For:
+		rt.suppress("condition has held for %s of %s", held.Round(time.Second), d.rule.For)
+		d.suppressActions(rt)
+		return nil
+	case d.fired:
+		rt.suppress("already fired; re-arms once the condition stops holding")
+		d.suppressActions(rt)
+		return nil
+	}
+	d.fired = true
+
+	var errs []error
+	for _, tag := range d.rule.ActuatorTags {
+		if _, err := d.commander.Command(ctx, tag, api.ActuatorCommand{Action: d.rule.Action}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to %s %q: %w", d.rule.Action, tag, err))
+		}
+	}
+	msg := fmt.Sprintf("%s has held for %s.", d.rule.Condition, now.Sub(d.since).Round(time.Second))
+	if len(d.rule.ActuatorTags) > 0 {
+		msg += fmt.Sprintf(" Sent %s to %s.", d.rule.Action, strings.Join(d.rule.ActuatorTags, ", "))
+	}
+	if len(errs) > 0 {
+		msg += " " + errors.Join(errs...).Error()
+	}
+	if d.notifier != nil {
+		d.notifier.Notify(ctx, &api.Alert{
+			ID:        uuid.New().String(),
+			Severity:  d.rule.Severity,
+			Title:     "Rule triggered: " + d.rule.Name,
+			Message:   msg,
+			Key:       api.AlertKeyCompositeRule,
+			Params:    map[string]string{"rule": d.rule.Name},
+			Source:    d.rule.Name,
+			Timestamp: now,
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// suppressActions notes the commands the rule holds back
+func (d *CompositeDetector) suppressActions(rt *ruleTrace) {
+	for _, tag := range d.rule.ActuatorTags {
+		rt.action(api.TraceAction{Tag: tag, Action: d.rule.Action, Outcome: api.TraceActionSuppressed})
+	}
+}
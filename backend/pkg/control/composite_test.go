@@ -0,0 +1,104 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestCompositeDetector(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := mapSource{
+		"heater.main": {Value: 1, Valid: true},
+		"flow.main":   {Value: 0, Unit: api.UnitLitersPerMin, Valid: true},
+	}
+	cmd := &recordingCommander{}
+	alerts := &alertRecorder{}
+	d := NewCompositeDetector(api.CompositeRule{
+		Name: "heater-no-flow",
+		Condition: api.Condition{All: []api.Condition{
+			{Tag: "heater.main", Op: api.OpEqual, Value: 1},
+			{Tag: "flow.main", Op: api.OpEqual, Value: 0},
+		}},
+		For:          2 * time.Minute,
+		ActuatorTags: []string{"heater.main"},
+		Action:       "off",
+	}, cmd, src, alerts)
+
+	ctx := context.Background()
+	for _, offset := range []time.Duration{0, time.Minute, 119 * time.Second} {
+		if err := d.Check(ctx, start.Add(offset)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if d.Fired() || len(alerts.alerts) != 0 {
+		t.Fatalf("Expected no firing before the condition held for 2m, got %+v", alerts.alerts)
+	}
+
+	for _, offset := range []time.Duration{2 * time.Minute, 3 * time.Minute} {
+		if err := d.Check(ctx, start.Add(offset)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if !d.Fired() {
+		t.Error("Expected the rule to fire")
+	}
+	if want := []string{"off:heater.main"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected one alert, got %d", len(alerts.alerts))
+	}
+	if a := alerts.alerts[0]; a.Severity != api.AlertSeverityWarning || a.Key != api.AlertKeyCompositeRule || a.Params["rule"] != "heater-no-flow" {
+		t.Errorf("Expected a warning keyed to the rule, got %+v", a)
+	}
+
+	// Flow returning re-arms the rule; an unreadable sensor never fires it.
+	src["flow.main"] = &api.SensorReading{Value: 5, Valid: true}
+	if err := d.Check(ctx, start.Add(4*time.Minute)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.Fired() {
+		t.Error("Expected the rule to re-arm")
+	}
+	delete(src, "flow.main")
+	if err := d.Check(ctx, start.Add(10*time.Minute)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("Expected no alert while flow is unknown, got %d", len(alerts.alerts))
+	}
+}
+
+func TestCompositeDetector_CommandFailure(t *testing.T) {
+	cmd := &recordingCommander{fail: map[string]error{"heater.main": errors.New("offline")}}
+	alerts := &alertRecorder{}
+	d := NewCompositeDetector(api.CompositeRule{
+		Name:         "overheat",
+		Condition:    api.Condition{Tag: "temp.main", Op: api.OpGreater, Value: 30},
+		Severity:     api.AlertSeverityCritical,
+		ActuatorTags: []string{"heater.main"},
+		Action:       "off",
+	}, cmd, mapSource{"temp.main": {Value: 31, Valid: true}}, alerts)
+
+	if err := d.Check(context.Background(), time.Now()); err == nil {
+		t.Error("Expected the failed command to be reported")
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Severity != api.AlertSeverityCritical {
+		t.Errorf("Expected one critical alert, got %+v", alerts.alerts)
+	}
+}
+
+func TestCompositeDetector_Invalid(t *testing.T) {
+	d := NewCompositeDetector(api.CompositeRule{
+		Name:      "bad",
+		Condition: api.Condition{Tag: "temp.main", Op: "~", Value: 30},
+	}, &recordingCommander{}, mapSource{}, nil)
+	if err := d.Check(context.Background(), time.Now()); err == nil {
+		t.Error("Expected an error for an unknown op")
+	}
+}
@@ -0,0 +1,81 @@
+package control
+
+import (
+	"context"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// EvaluateCondition evaluates cond against the latest readings, reporting the outcome of
+// each part. All stops at its first false part and Any at its first true one; the parts
+// after are reported as skipped and never read. A comparison whose sensor can't be read,
+// or reads invalid, is unknown rather than false, so NOT never turns a missing reading
+// into a match.
+func EvaluateCondition(ctx context.Context, readings ReadingSource, cond api.Condition) *api.ConditionStatus {
+	status := &api.ConditionStatus{Condition: cond.String()}
+	switch {
+	case cond.Tag != "":
+		r, err := readings.LatestReading(ctx, cond.Tag)
+		switch {
+		case err != nil:
+			status.Result = api.ConditionUnknown
+			status.Error = err.Error()
+		case !r.Valid:
+			status.Result = api.ConditionUnknown
+			status.Error = "invalid reading"
+			if r.Error != "" {
+				status.Error += ": " + r.Error
+			}
+		default:
+			value := r.Value
+			status.Value, status.Unit = &value, r.Unit
+			status.Result = resultOf(cond.Op.Compare(r.Value, cond.Value))
+		}
+
+	case cond.Not != nil:
+		child := EvaluateCondition(ctx, readings, *cond.Not)
+		status.Children = []*api.ConditionStatus{child}
+		switch child.Result {
+		case api.ConditionTrue:
+			status.Result = api.ConditionFalse
+		case api.ConditionFalse:
+			status.Result = api.ConditionTrue
+		default:
+			status.Result = api.ConditionUnknown
+		}
+
+	default:
+		// All is settled by a false part and Any by a true one; otherwise an unknown part
+		// leaves the whole unknown.
+		children, settles := cond.All, api.ConditionFalse
+		if len(cond.Any) > 0 {
+			children, settles = cond.Any, api.ConditionTrue
+		}
+		status.Result = api.ConditionTrue
+		if settles == api.ConditionTrue {
+			status.Result = api.ConditionFalse
+		}
+		for _, c := range children {
+			if status.Result == settles {
+				status.Children = append(status.Children, &api.ConditionStatus{Condition: c.String(), Result: api.ConditionSkipped})
+				continue
+			}
+			child := EvaluateCondition(ctx, readings, c)
+			status.Children = append(status.Children, child)
+			switch child.Result {
+			case settles:
+				status.Result = settles
+			case api.ConditionUnknown:
+				status.Result = api.ConditionUnknown
+			}
+		}
+	}
+	return status
+}
+
+func resultOf(b bool) api.ConditionResult {
+	if b {
+		return api.ConditionTrue
+	}
+	return api.ConditionFalse
+}
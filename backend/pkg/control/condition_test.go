@@ -0,0 +1,89 @@
+package control
+
+import (
+	"context"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// countingSource records which tags were read
+type countingSource struct {
+	mapSource
+	read []string
+}
+
+func (c *countingSource) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	c.read = append(c.read, tag)
+	return c.mapSource.LatestReading(ctx, tag)
+}
+
+func leaf(tag string, op api.ConditionOp, value float64) api.Condition {
+	return api.Condition{Tag: tag, Op: op, Value: value}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	src := mapSource{
+		"heater.main": {Value: 1, Valid: true},
+		"flow.main":   {Value: 0, Unit: api.UnitLitersPerMin, Valid: true},
+		"temp.main":   {Value: 26, Valid: true},
+		"temp.broken": {Valid: false, Error: "probe disconnected"},
+	}
+	heaterOn := leaf("heater.main", api.OpEqual, 1)
+	noFlow := leaf("flow.main", api.OpEqual, 0)
+	missing := leaf("level.sump", api.OpLess, 10)
+	broken := leaf("temp.broken", api.OpGreater, 30)
+
+	tests := []struct {
+		name string
+		cond api.Condition
+		want api.ConditionResult
+	}{
+		{"comparison", leaf("temp.main", api.OpGreaterEqual, 26), api.ConditionTrue},
+		{"and", api.Condition{All: []api.Condition{heaterOn, noFlow}}, api.ConditionTrue},
+		{"and false", api.Condition{All: []api.Condition{heaterOn, leaf("flow.main", api.OpGreater, 0)}}, api.ConditionFalse},
+		{"or", api.Condition{Any: []api.Condition{leaf("temp.main", api.OpGreater, 30), noFlow}}, api.ConditionTrue},
+		{"not", api.Condition{Not: &heaterOn}, api.ConditionFalse},
+		{"missing is unknown", missing, api.ConditionUnknown},
+		{"not unknown", api.Condition{Not: &broken}, api.ConditionUnknown},
+		{"and unknown", api.Condition{All: []api.Condition{heaterOn, missing}}, api.ConditionUnknown},
+		{"false settles and", api.Condition{All: []api.Condition{missing, leaf("heater.main", api.OpEqual, 0)}}, api.ConditionFalse},
+		{"true settles or", api.Condition{Any: []api.Condition{broken, heaterOn}}, api.ConditionTrue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EvaluateCondition(context.Background(), src, tt.cond); got.Result != tt.want {
+				t.Errorf("Expected %s for %s, got %s", tt.want, tt.cond, got.Result)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition_ShortCircuit(t *testing.T) {
+	src := &countingSource{mapSource: mapSource{
+		"heater.main": {Value: 0, Valid: true},
+		"flow.main":   {Value: 0, Unit: api.UnitLitersPerMin, Valid: true},
+	}}
+	cond := api.Condition{All: []api.Condition{
+		leaf("heater.main", api.OpEqual, 1),
+		leaf("flow.main", api.OpEqual, 0),
+	}}
+
+	status := EvaluateCondition(context.Background(), src, cond)
+	if status.Result != api.ConditionFalse {
+		t.Errorf("Expected false, got %s", status.Result)
+	}
+	if len(src.read) != 1 || src.read[0] != "heater.main" {
+		t.Errorf("Expected only heater.main to be read, got %v", src.read)
+	}
+	if len(status.Children) != 2 {
+		t.Fatalf("Expected a status for each part, got %d", len(status.Children))
+	}
+	heater, flow := status.Children[0], status.Children[1]
+	if heater.Result != api.ConditionFalse || heater.Value == nil || *heater.Value != 0 {
+		t.Errorf("Expected heater.main false at 0, got %+v", heater)
+	}
+	if flow.Result != api.ConditionSkipped || flow.Condition != "flow.main == 0" {
+		t.Errorf("Expected flow.main == 0 to be skipped, got %+v", flow)
+	}
+}
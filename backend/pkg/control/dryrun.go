@@ -0,0 +1,137 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+// DryRunDetector watches a pump that is switched on and shuts it off, raising a critical
+// alert, once its power draw or downstream flow has stayed too low for the rule's
+// duration. This protects the pump from burning out when dry or blocked.
+type DryRunDetector struct {
+	rule      api.DryRunRule
+	commander Commander
+	readings  ReadingSource
+	notifier  notify.Notifier
+
+	lock    sync.Mutex
+	since   time.Time // when the condition was first observed; zero while healthy
+	tripped bool
+}
+
+func NewDryRunDetector(rule api.DryRunRule, commander Commander, readings ReadingSource, notifier notify.Notifier) *DryRunDetector {
+	return &DryRunDetector{
+		rule:      rule,
+		commander: commander,
+		readings:  readings,
+		notifier:  notifier,
+	}
+}
+
+// Tripped reports whether the detector has shut the pump off. It re-arms once the pump
+// is observed off and later switched back on.
+func (d *DryRunDetector) Tripped() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.tripped
+}
+
+// Check evaluates the rule at now
+func (d *DryRunDetector) Check(ctx context.Context, now time.Time) error {
+	if d.rule.PowerTag == "" && d.rule.FlowTag == "" {
+		return errors.New("dry-run rule needs a power or flow sensor")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	pump, err := d.readings.LatestReading(ctx, d.rule.PumpTag)
+	if err != nil {
+		return fmt.Errorf("failed to read pump state %q: %w", d.rule.PumpTag, err)
+	}
+	if !pump.Valid || pump.Value == 0 {
+		d.since = time.Time{}
+		d.tripped = false
+		traceFrom(ctx).note("pump is off")
+		return nil
+	}
+	if d.tripped {
+		// Waiting for the stale "on" state to clear after our shutoff.
+		traceFrom(ctx).suppress("pump already shut off; waiting for it to report off")
+		return nil
+	}
+
+	starved, reason, err := d.starved(ctx)
+	if err != nil {
+		return err
+	}
+	if !starved {
+		d.since = time.Time{}
+		return nil
+	}
+	if d.since.IsZero() {
+		d.since = now
+	}
+	if held := now.Sub(d.since); held < d.rule.For {
+		traceFrom(ctx).suppress("%s for %s of %s", reason, held.Round(time.Second), d.rule.For)
+		return nil
+	}
+
+	d.tripped = true
+	_, cmdErr := d.commander.Command(ctx, d.rule.PumpTag, api.ActuatorCommand{Action: "off"})
+	msg := fmt.Sprintf("Pump %s has been on with %s for %s; it has been shut off to prevent burnout.",
+		d.rule.PumpTag, reason, now.Sub(d.since).Round(time.Second))
+	if cmdErr != nil {
+		msg = fmt.Sprintf("Pump %s has been on with %s for %s and could NOT be shut off: %v",
+			d.rule.PumpTag, reason, now.Sub(d.since).Round(time.Second), cmdErr)
+	}
+	if d.notifier != nil {
+		d.notifier.Notify(ctx, &api.Alert{
+			ID:        uuid.New().String(),
+			Severity:  api.AlertSeverityCritical,
+			Title:     "Pump dry-run / blockage: " + d.rule.Name,
+			Message:   msg,
+			Key:       api.AlertKeyPumpDryRun,
+			Params:    map[string]string{"rule": d.rule.Name},
+			Source:    d.rule.PumpTag,
+			Timestamp: now,
+		})
+	}
+	if cmdErr != nil {
+		return fmt.Errorf("failed to shut off %q: %w", d.rule.PumpTag, cmdErr)
+	}
+	return nil
+}
+
+// starved reports whether the configured power or flow sensor is below its minimum.
+// Unreadable sensors are treated as healthy so a sensor outage alone never stops a pump.
+func (d *DryRunDetector) starved(ctx context.Context) (bool, string, error) {
+	var errs []error
+	if d.rule.PowerTag != "" {
+		r, err := d.readings.LatestReading(ctx, d.rule.PowerTag)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to read power %q: %w", d.rule.PowerTag, err))
+		case r.Valid && r.Value < d.rule.MinPower:
+			return true, fmt.Sprintf("power draw %.1f%s below %.1f", r.Value, r.Unit, d.rule.MinPower), nil
+		}
+	}
+	if d.rule.FlowTag != "" {
+		r, err := d.readings.LatestReading(ctx, d.rule.FlowTag)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to read flow %q: %w", d.rule.FlowTag, err))
+		case r.Valid && r.Value < d.rule.MinFlow:
+			return true, fmt.Sprintf("flow %.1f%s below %.1f", r.Value, r.Unit, d.rule.MinFlow), nil
+		}
+	}
+	return false, "", errors.Join(errs...)
+}
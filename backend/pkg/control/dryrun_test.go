@@ -0,0 +1,93 @@
+package control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestDryRunDetector(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := mapSource{
+		"pump.return":  {Value: 1, Valid: true},
+		"power.return": {Value: 3, Unit: api.UnitWatts, Valid: true},
+	}
+	cmd := &recordingCommander{}
+	alerts := &alertRecorder{}
+	d := NewDryRunDetector(api.DryRunRule{
+		Name:     "return",
+		PumpTag:  "pump.return",
+		PowerTag: "power.return",
+		MinPower: 20,
+		For:      30 * time.Second,
+	}, cmd, src, alerts)
+
+	ctx := context.Background()
+	for _, offset := range []time.Duration{0, 10 * time.Second, 29 * time.Second} {
+		if err := d.Check(ctx, start.Add(offset)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if d.Tripped() || len(cmd.calls) != 0 {
+		t.Fatalf("Expected no shutoff before duration elapsed, got calls %v", cmd.calls)
+	}
+
+	if err := d.Check(ctx, start.Add(30*time.Second)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !d.Tripped() {
+		t.Error("Expected detector to trip")
+	}
+	if want := []string{"off:pump.return"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Severity != api.AlertSeverityCritical {
+		t.Fatalf("Expected one critical alert, got %+v", alerts.alerts)
+	}
+
+	// Further checks while the pump still reports on must not repeat the shutoff.
+	if err := d.Check(ctx, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(cmd.calls) != 1 || len(alerts.alerts) != 1 {
+		t.Errorf("Expected a single shutoff, got calls %v", cmd.calls)
+	}
+
+	// Re-arms once the pump is seen off.
+	src["pump.return"] = &api.SensorReading{Value: 0, Valid: true}
+	if err := d.Check(ctx, start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.Tripped() {
+		t.Error("Expected detector to re-arm after pump turned off")
+	}
+}
+
+func TestDryRunDetector_ConditionClears(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := mapSource{
+		"pump.return": {Value: 1, Valid: true},
+		"flow.return": {Value: 0, Valid: true},
+	}
+	cmd := &recordingCommander{}
+	d := NewDryRunDetector(api.DryRunRule{
+		PumpTag: "pump.return",
+		FlowTag: "flow.return",
+		MinFlow: 1,
+		For:     30 * time.Second,
+	}, cmd, src, nil)
+
+	ctx := context.Background()
+	d.Check(ctx, start)
+	src["flow.return"] = &api.SensorReading{Value: 12, Valid: true}
+	d.Check(ctx, start.Add(20*time.Second))
+	src["flow.return"] = &api.SensorReading{Value: 0, Valid: true}
+	d.Check(ctx, start.Add(40*time.Second))
+
+	if d.Tripped() || len(cmd.calls) != 0 {
+		t.Errorf("Expected timer to reset when flow recovered, got calls %v", cmd.calls)
+	}
+}
@@ -0,0 +1,165 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TagResolver maps a device resource to the tags addressing it
+type TagResolver interface {
+	TagsFor(ctx context.Context, deviceID, resourceID string) ([]string, error)
+}
+
+// Reaction responds to device events without waiting for a periodic evaluation. It is
+// called once per tag of the resource the event came from.
+type Reaction interface {
+	HandleEvent(ctx context.Context, tag string, ev *api.ResourceEvent) error
+}
+
+// Attributed wraps a reaction so the commands it issues are recorded in command history
+// as coming from its rule
+func Attributed(ref api.RuleRef, next Reaction) Reaction {
+	return &attributedReaction{
+		origin: api.CommandOrigin{Source: api.CommandSourceRule, Name: ref.Kind + "/" + ref.Name},
+		next:   next,
+	}
+}
+
+type attributedReaction struct {
+	origin api.CommandOrigin
+	next   Reaction
+}
+
+func (a *attributedReaction) HandleEvent(ctx context.Context, tag string, ev *api.ResourceEvent) error {
+	return a.next.HandleEvent(api.WithCommandOrigin(ctx, a.origin), tag, ev)
+}
+
+// tagCacheTTL bounds how long a resource's tags are cached, and so how long after a tag
+// is added, renamed or aliased the fast path takes to react to it
+const tagCacheTTL = 30 * time.Second
+
+// FastPath dispatches device events to reactions synchronously in the driver's ingestion
+// goroutine. Only safety-critical, cheap reactions (leaks, float switches, stalls)
+// belong here; trend rules stay on the periodic evaluator.
+type FastPath struct {
+	resolver  TagResolver
+	reactions []Reaction
+	now       func() time.Time
+
+	lock sync.RWMutex
+	tags map[string]cachedTags // device/resource -> tags
+}
+
+type cachedTags struct {
+	tags    []string
+	expires time.Time
+}
+
+func NewFastPath(resolver TagResolver, reactions ...Reaction) *FastPath {
+	return &FastPath{
+		resolver:  resolver,
+		reactions: reactions,
+		now:       time.Now,
+		tags:      make(map[string]cachedTags),
+	}
+}
+
+// HandleEvent matches drivers.EventHandler
+func (f *FastPath) HandleEvent(ctx context.Context, ev *api.ResourceEvent) {
+	if len(f.reactions) == 0 {
+		return
+	}
+	ll := logging.Component(log.Ctx(ctx).With().
+		Str("component", "control").
+		Str("subcomponent", "fastpath").
+		Str("device_id", ev.DeviceID).
+		Str("resource_id", ev.ResourceID).
+		Logger(), "control")
+
+	tags, err := f.tagsFor(ctx, ev.DeviceID, ev.ResourceID)
+	if err != nil {
+		ll.Debug().Err(err).Msg("unable to resolve event resource")
+		return
+	}
+	for _, tag := range tags {
+		for _, r := range f.reactions {
+			if err := r.HandleEvent(ctx, tag, ev); err != nil {
+				ll.Error().Err(err).Str("tag", tag).Msg("event reaction failed")
+			}
+		}
+	}
+}
+
+// tagsFor resolves a resource's tags, caching them for tagCacheTTL. An untagged resource
+// isn't cached, so a sensor reporting before it is tagged reacts as soon as it is.
+func (f *FastPath) tagsFor(ctx context.Context, deviceID, resourceID string) ([]string, error) {
+	key := deviceID + "/" + resourceID
+	now := f.now()
+	f.lock.RLock()
+	cached, ok := f.tags[key]
+	f.lock.RUnlock()
+	if ok && now.Before(cached.expires) {
+		return cached.tags, nil
+	}
+
+	tags, err := f.resolver.TagsFor(ctx, deviceID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	f.lock.Lock()
+	if len(tags) > 0 {
+		f.tags[key] = cachedTags{tags: tags, expires: now.Add(tagCacheTTL)}
+	} else {
+		delete(f.tags, key)
+	}
+	f.lock.Unlock()
+	return tags, nil
+}
+
+// TriggerReaction switches actuators when an event reports the configured value
+type TriggerReaction struct {
+	reaction  api.EventReaction
+	commander Commander
+
+	lock  sync.Mutex
+	fired bool
+}
+
+func NewTriggerReaction(reaction api.EventReaction, commander Commander) *TriggerReaction {
+	return &TriggerReaction{
+		reaction:  reaction,
+		commander: commander,
+	}
+}
+
+func (t *TriggerReaction) HandleEvent(ctx context.Context, tag string, ev *api.ResourceEvent) error {
+	if tag != t.reaction.Tag || (t.reaction.Field != "" && ev.Field != t.reaction.Field) || !ev.Reading.Valid {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	match := ev.Reading.Value == t.reaction.Value
+	wasFired := t.fired
+	t.fired = match
+	if !match || wasFired {
+		return nil
+	}
+
+	var errs []error
+	for _, actuator := range t.reaction.ActuatorTags {
+		if _, err := t.commander.Command(ctx, actuator, api.ActuatorCommand{Action: t.reaction.Action}); err != nil {
+			errs = append(errs, fmt.Errorf("reaction %q failed to command %q: %w", t.reaction.Name, actuator, err))
+		}
+	}
+	return errors.Join(errs...)
+}
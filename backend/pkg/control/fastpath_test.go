@@ -0,0 +1,158 @@
+package control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type staticResolver struct {
+	tags  map[string][]string
+	calls int
+}
+
+func (s *staticResolver) TagsFor(ctx context.Context, deviceID, resourceID string) ([]string, error) {
+	s.calls++
+	return s.tags[deviceID+"/"+resourceID], nil
+}
+
+func TestFastPath_TriggerReaction(t *testing.T) {
+	resolver := &staticResolver{tags: map[string][]string{
+		"shelly-1/input:0": {"float.ato-high"},
+	}}
+	cmd := &recordingCommander{}
+	fp := NewFastPath(resolver, NewTriggerReaction(api.EventReaction{
+		Name:         "ato-high",
+		Tag:          "float.ato-high",
+		Field:        "state",
+		Value:        1,
+		ActuatorTags: []string{"pump.ato"},
+		Action:       "off",
+	}, cmd))
+
+	event := func(v float64) *api.ResourceEvent {
+		return &api.ResourceEvent{
+			DeviceID:   "shelly-1",
+			ResourceID: "input:0",
+			Field:      "state",
+			Reading:    api.SensorReading{Value: v, Valid: true},
+		}
+	}
+
+	ctx := context.Background()
+	fp.HandleEvent(ctx, event(0))
+	fp.HandleEvent(ctx, event(1))
+	fp.HandleEvent(ctx, event(1))
+	fp.HandleEvent(ctx, event(0))
+	fp.HandleEvent(ctx, event(1))
+
+	if want := []string{"off:pump.ato", "off:pump.ato"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected one command per transition %v, got %v", want, cmd.calls)
+	}
+	if resolver.calls != 1 {
+		t.Errorf("Expected tag resolution to be cached, got %d lookups", resolver.calls)
+	}
+}
+
+func TestFastPath_TagCache(t *testing.T) {
+	resolver := &staticResolver{tags: map[string][]string{}}
+	cmd := &recordingCommander{}
+	fp := NewFastPath(resolver, NewTriggerReaction(api.EventReaction{
+		Name:         "ato-high",
+		Tag:          "float.ato-high",
+		Value:        1,
+		ActuatorTags: []string{"pump.ato"},
+		Action:       "off",
+	}, cmd))
+	now := time.Now()
+	fp.now = func() time.Time { return now }
+	ctx := context.Background()
+	event := func(v float64) *api.ResourceEvent {
+		return &api.ResourceEvent{DeviceID: "shelly-1", ResourceID: "input:0", Reading: api.SensorReading{Value: v, Valid: true}}
+	}
+
+	// The switch reports before it is tagged, then reacts once it is
+	fp.HandleEvent(ctx, event(1))
+	resolver.tags["shelly-1/input:0"] = []string{"float.ato-high"}
+	fp.HandleEvent(ctx, event(1))
+	if want := []string{"off:pump.ato"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Fatalf("Expected the newly tagged switch to react, got %v", cmd.calls)
+	}
+
+	// A retag is picked up once the cached tags expire
+	resolver.tags["shelly-1/input:0"] = []string{"float.renamed"}
+	fp.HandleEvent(ctx, event(0))
+	if resolver.calls != 2 {
+		t.Errorf("Expected cached tags within the TTL, got %d lookups", resolver.calls)
+	}
+	now = now.Add(tagCacheTTL)
+	fp.HandleEvent(ctx, event(1))
+	if resolver.calls != 3 || len(cmd.calls) != 1 {
+		t.Errorf("Expected the expired tags to be resolved again, got %d lookups and calls %v", resolver.calls, cmd.calls)
+	}
+}
+
+func TestFastPath_LeakResponder(t *testing.T) {
+	resolver := &staticResolver{tags: map[string][]string{
+		"flood-1/flood:0": {"leak.sump"},
+	}}
+	cmd := &recordingCommander{}
+	fp := NewFastPath(resolver, NewLeakResponder(api.LeakResponse{
+		Subsystem: "sump",
+		LeakTags:  []string{"leak.sump"},
+		PumpTags:  []string{"pump.return"},
+	}, cmd, mapSource{}, nil))
+
+	fp.HandleEvent(context.Background(), &api.ResourceEvent{
+		DeviceID:   "flood-1",
+		ResourceID: "flood:0",
+		Field:      "state",
+		Reading:    api.SensorReading{Value: 1, Valid: true},
+	})
+
+	if want := []string{"off:pump.return"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
+
+func BenchmarkFastPath_HandleEvent(b *testing.B) {
+	resolver := &staticResolver{tags: map[string][]string{"shelly-1/input:0": {"float.ato-high"}}}
+	fp := NewFastPath(resolver, NewTriggerReaction(api.EventReaction{
+		Tag:          "float.ato-high",
+		Value:        1,
+		ActuatorTags: []string{"pump.ato"},
+		Action:       "off",
+	}, &recordingCommander{}))
+	ev := &api.ResourceEvent{DeviceID: "shelly-1", ResourceID: "input:0", Field: "state", Reading: api.SensorReading{Valid: true}}
+	ctx := context.Background()
+	for b.Loop() {
+		fp.HandleEvent(ctx, ev)
+	}
+}
+
+// originCommander records the origin each command was issued with
+type originCommander struct {
+	origins []api.CommandOrigin
+}
+
+func (o *originCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	o.origins = append(o.origins, api.CommandOriginFrom(ctx))
+	return &api.ActuatorState{}, nil
+}
+
+func TestAttributed(t *testing.T) {
+	rule := api.EventReaction{Name: "ato-high", Tag: "float.ato-high", Field: "state", Value: 1, ActuatorTags: []string{"pump.ato"}, Action: "off"}
+	cmd := &originCommander{}
+	resolver := &staticResolver{tags: map[string][]string{"shelly-1/input:0": {"float.ato-high"}}}
+	fp := NewFastPath(resolver, Attributed(rule.Ref(), NewTriggerReaction(rule, cmd)))
+
+	fp.HandleEvent(context.Background(), &api.ResourceEvent{DeviceID: "shelly-1", ResourceID: "input:0", Field: "state", Reading: api.SensorReading{Value: 1, Valid: true}})
+
+	want := []api.CommandOrigin{{Source: api.CommandSourceRule, Name: "event_reaction/ato-high"}}
+	if !reflect.DeepEqual(cmd.origins, want) {
+		t.Errorf("Expected commands attributed to the rule, got %+v", cmd.origins)
+	}
+}
@@ -0,0 +1,140 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ErrStopped is returned by a Start which Stop interrupted before every member started
+var ErrStopped = errors.New("actuator group stopped while starting")
+
+// Commander sends a command to the actuator identified by tag
+type Commander interface {
+	Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error)
+}
+
+// GroupController starts and stops the members of an actuator group, staggering starts
+// and rotating the lead member according to the group's policy
+type GroupController struct {
+	group     api.ActuatorGroup
+	commander Commander
+	// after is swapped out by tests to avoid real sleeps
+	after func(time.Duration) <-chan time.Time
+
+	lock sync.Mutex
+	next int
+	// stops has a channel for each Start in progress, closed by Stop to interrupt it
+	stops map[chan struct{}]struct{}
+}
+
+func NewGroupController(group api.ActuatorGroup, commander Commander) *GroupController {
+	return &GroupController{
+		group:     group,
+		commander: commander,
+		after:     time.After,
+		stops:     make(map[chan struct{}]struct{}),
+	}
+}
+
+// Group returns the group g controls
+func (g *GroupController) Group() api.ActuatorGroup {
+	return g.group
+}
+
+// Order returns the tags in the order the next Start will switch them on
+func (g *GroupController) Order() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.order()
+}
+
+func (g *GroupController) order() []string {
+	tags := g.group.ActuatorTags
+	if len(tags) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(tags))
+	for i := range tags {
+		out = append(out, tags[(g.next+i)%len(tags)])
+	}
+	return out
+}
+
+// Start switches every member on, waiting StaggerOffset between members. A member that
+// fails to start does not prevent the rest from starting; all failures are returned. The
+// lock is only held while commanding a member, so a Stop during the waits interrupts the
+// start, which returns ErrStopped.
+func (g *GroupController) Start(ctx context.Context) error {
+	g.lock.Lock()
+	order := g.order()
+	if g.group.Rotation == api.RotationRoundRobin && len(g.group.ActuatorTags) > 0 {
+		g.next = (g.next + 1) % len(g.group.ActuatorTags)
+	}
+	stop := make(chan struct{})
+	g.stops[stop] = struct{}{}
+	g.lock.Unlock()
+	defer func() {
+		g.lock.Lock()
+		delete(g.stops, stop)
+		g.lock.Unlock()
+	}()
+
+	var errs []error
+	for i, tag := range order {
+		if i > 0 && g.group.StaggerOffset > 0 {
+			select {
+			case <-g.after(g.group.StaggerOffset):
+			case <-stop:
+				return errors.Join(append(errs, ErrStopped)...)
+			case <-ctx.Done():
+				return errors.Join(append(errs, ctx.Err())...)
+			}
+		}
+		if err := g.startMember(ctx, tag, stop); err != nil {
+			if errors.Is(err, ErrStopped) {
+				return errors.Join(append(errs, err)...)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// startMember switches tag on unless Stop has closed stop, holding the lock so a member
+// is never switched on after Stop switched it off
+func (g *GroupController) startMember(ctx context.Context, tag string, stop chan struct{}) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	select {
+	case <-stop:
+		return ErrStopped
+	default:
+	}
+	if _, err := g.commander.Command(ctx, tag, api.ActuatorCommand{Action: "on"}); err != nil {
+		return fmt.Errorf("failed to start %q: %w", tag, err)
+	}
+	return nil
+}
+
+// Stop switches every member off immediately, interrupting any Start in progress
+func (g *GroupController) Stop(ctx context.Context) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	for stop := range g.stops {
+		close(stop)
+		delete(g.stops, stop)
+	}
+	var errs []error
+	for _, tag := range g.group.ActuatorTags {
+		if _, err := g.commander.Command(ctx, tag, api.ActuatorCommand{Action: "off"}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %q: %w", tag, err))
+		}
+	}
+	return errors.Join(errs...)
+}
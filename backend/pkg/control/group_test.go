@@ -0,0 +1,164 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type recordingCommander struct {
+	lock  sync.Mutex
+	calls []string
+	fail  map[string]error
+}
+
+func (r *recordingCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.calls = append(r.calls, cmd.Action+":"+tag)
+	if err := r.fail[tag]; err != nil {
+		return nil, err
+	}
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+func TestGroupController_StartStaggered(t *testing.T) {
+	cmd := &recordingCommander{}
+	g := NewGroupController(api.ActuatorGroup{
+		Name:          "returns",
+		ActuatorTags:  []string{"pump.a", "pump.b", "pump.c"},
+		StaggerOffset: 5 * time.Second,
+	}, cmd)
+
+	var waits []time.Duration
+	g.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+
+	if err := g.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if want := []string{"on:pump.a", "on:pump.b", "on:pump.c"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if want := []time.Duration{5 * time.Second, 5 * time.Second}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("Expected waits %v, got %v", want, waits)
+	}
+}
+
+func TestGroupController_RoundRobin(t *testing.T) {
+	cmd := &recordingCommander{}
+	g := NewGroupController(api.ActuatorGroup{
+		ActuatorTags: []string{"light.a", "light.b", "light.c"},
+		Rotation:     api.RotationRoundRobin,
+	}, cmd)
+
+	want := [][]string{
+		{"light.a", "light.b", "light.c"},
+		{"light.b", "light.c", "light.a"},
+		{"light.c", "light.a", "light.b"},
+		{"light.a", "light.b", "light.c"},
+	}
+	for i, w := range want {
+		if got := g.Order(); !reflect.DeepEqual(got, w) {
+			t.Errorf("start %d: expected order %v, got %v", i, w, got)
+		}
+		if err := g.Start(context.Background()); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+	}
+}
+
+func TestGroupController_StartContinuesPastFailure(t *testing.T) {
+	failErr := errors.New("offline")
+	cmd := &recordingCommander{fail: map[string]error{"pump.a": failErr}}
+	g := NewGroupController(api.ActuatorGroup{ActuatorTags: []string{"pump.a", "pump.b"}}, cmd)
+
+	err := g.Start(context.Background())
+	if !errors.Is(err, failErr) {
+		t.Errorf("Expected error %v, got %v", failErr, err)
+	}
+	if want := []string{"on:pump.a", "on:pump.b"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
+
+func TestGroupController_StartCancelled(t *testing.T) {
+	cmd := &recordingCommander{}
+	g := NewGroupController(api.ActuatorGroup{
+		ActuatorTags:  []string{"pump.a", "pump.b"},
+		StaggerOffset: time.Hour,
+	}, cmd)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.after = func(time.Duration) <-chan time.Time {
+		cancel()
+		return make(chan time.Time)
+	}
+
+	if err := g.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if want := []string{"on:pump.a"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
+
+func TestGroupController_Stop(t *testing.T) {
+	cmd := &recordingCommander{}
+	g := NewGroupController(api.ActuatorGroup{ActuatorTags: []string{"pump.a", "pump.b"}}, cmd)
+
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if want := []string{"off:pump.a", "off:pump.b"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
+
+func TestGroupController_StopDuringStart(t *testing.T) {
+	cmd := &recordingCommander{}
+	g := NewGroupController(api.ActuatorGroup{
+		ActuatorTags:  []string{"pump.a", "pump.b"},
+		StaggerOffset: time.Hour,
+	}, cmd)
+
+	waiting := make(chan struct{})
+	g.after = func(time.Duration) <-chan time.Time {
+		close(waiting)
+		return make(chan time.Time)
+	}
+	started := make(chan error, 1)
+	go func() { started <- g.Start(context.Background()) }()
+	<-waiting
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- g.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked behind the start's stagger wait")
+	}
+	select {
+	case err := <-started:
+		if !errors.Is(err, ErrStopped) {
+			t.Errorf("Expected ErrStopped, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Start was not interrupted by Stop")
+	}
+	if want := []string{"on:pump.a", "off:pump.a", "off:pump.b"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
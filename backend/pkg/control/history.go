@@ -0,0 +1,66 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// ReadingHistory looks up a sensor by tag and its stored readings
+type ReadingHistory interface {
+	GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error)
+	GetSensorReadings(ctx context.Context, filters storer.SensorReadingFilters) ([]*api.ReadingRecord, error)
+}
+
+// recentReadings returns the valid, measured readings of the sensor tagged tag taken
+// between start and end, oldest first. Synthetic readings are left out so test
+// injections never skew a rule's statistics. A traced rule records the newest as its
+// input. When history is a Measurements, a tag naming a logical measurement is read as
+// the vote of its probes.
+func recentReadings(ctx context.Context, history ReadingHistory, tag string, start, end time.Time) ([]api.SensorReading, error) {
+	if m, ok := history.(*Measurements); ok {
+		lm, ok := m.measurements[tag]
+		if !ok {
+			return recentReadings(ctx, m.history, tag, start, end)
+		}
+		readings, err := m.votedReadings(ctx, lm, start, end)
+		if err != nil {
+			traceFrom(ctx).input(tag, nil, err)
+			return nil, err
+		}
+		if len(readings) > 0 {
+			traceFrom(ctx).input(tag, &readings[len(readings)-1], nil)
+		}
+		return readings, nil
+	}
+	sensor, err := history.GetSensorByTag(ctx, tag)
+	if err != nil {
+		traceFrom(ctx).input(tag, nil, err)
+		return nil, fmt.Errorf("failed to find sensor %q: %w", tag, err)
+	}
+	recs, err := history.GetSensorReadings(ctx, storer.SensorReadingFilters{
+		DeviceID:  sensor.DeviceID,
+		SensorID:  sensor.ID,
+		StartTime: &start,
+		EndTime:   &end,
+	})
+	if err != nil {
+		traceFrom(ctx).input(tag, nil, err)
+		return nil, fmt.Errorf("failed to read history of %q: %w", tag, err)
+	}
+
+	// Readings come newest first.
+	var readings []api.SensorReading
+	for i := len(recs) - 1; i >= 0; i-- {
+		if r := recs[i].Reading; r.Valid && !r.Synthetic {
+			readings = append(readings, r)
+		}
+	}
+	if len(readings) > 0 {
+		traceFrom(ctx).input(tag, &readings[len(readings)-1], nil)
+	}
+	return readings, nil
+}
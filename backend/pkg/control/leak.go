@@ -0,0 +1,138 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+// LeakResponder isolates a subsystem when one of its leak sensors reports water, closing
+// supply valves before stopping pumps. Readings can be pushed through HandleReading as
+// they arrive so the response does not wait for the next evaluation interval; Check
+// polls every sensor as a fallback.
+type LeakResponder struct {
+	policy    api.LeakResponse
+	commander Commander
+	readings  ReadingSource
+	notifier  notify.Notifier
+
+	lock    sync.Mutex
+	leaking map[string]bool
+}
+
+func NewLeakResponder(policy api.LeakResponse, commander Commander, readings ReadingSource, notifier notify.Notifier) *LeakResponder {
+	return &LeakResponder{
+		policy:    policy,
+		commander: commander,
+		readings:  readings,
+		notifier:  notifier,
+		leaking:   make(map[string]bool),
+	}
+}
+
+// Watches reports whether tag is one of this responder's leak sensors
+func (l *LeakResponder) Watches(tag string) bool {
+	return slices.Contains(l.policy.LeakTags, tag)
+}
+
+// Leaking returns the leak sensors currently reporting water
+func (l *LeakResponder) Leaking() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var out []string
+	for _, tag := range l.policy.LeakTags {
+		if l.leaking[tag] {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// HandleReading processes a new reading from the leak sensor tag. The shutdown runs once
+// per leak; it is repeated only after the sensor has reported dry again.
+func (l *LeakResponder) HandleReading(ctx context.Context, tag string, reading *api.SensorReading) error {
+	if !l.Watches(tag) || reading == nil || !reading.Valid {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	wet := reading.Value != 0
+	wasWet := l.leaking[tag]
+	l.leaking[tag] = wet
+	if !wet || wasWet {
+		return nil
+	}
+	return l.isolate(ctx, tag, reading.Timestamp)
+}
+
+// HandleEvent lets the responder react directly to device events on the fast path
+func (l *LeakResponder) HandleEvent(ctx context.Context, tag string, ev *api.ResourceEvent) error {
+	return l.HandleReading(ctx, tag, &ev.Reading)
+}
+
+// Check polls every leak sensor, so a leak is isolated even if the event reporting it is
+// missed. The worker runs it as a control loop.
+func (l *LeakResponder) Check(ctx context.Context) error {
+	var errs []error
+	for _, tag := range l.policy.LeakTags {
+		r, err := l.readings.LatestReading(ctx, tag)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read leak sensor %q: %w", tag, err))
+			continue
+		}
+		if err := l.HandleReading(ctx, tag, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l *LeakResponder) isolate(ctx context.Context, source string, at time.Time) error {
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var errs []error
+	for _, tag := range l.policy.ValveTags {
+		if _, err := l.commander.Command(ctx, tag, api.ActuatorCommand{Action: "off"}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close valve %q: %w", tag, err))
+		}
+	}
+	for _, tag := range l.policy.PumpTags {
+		if _, err := l.commander.Command(ctx, tag, api.ActuatorCommand{Action: "off"}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop pump %q: %w", tag, err))
+		}
+	}
+	err := errors.Join(errs...)
+
+	if l.notifier != nil {
+		msg := fmt.Sprintf("Leak sensor %s detected water. Closed %d valve(s) and stopped %d pump(s) in %s.",
+			source, len(l.policy.ValveTags), len(l.policy.PumpTags), l.policy.Subsystem)
+		if err != nil {
+			msg = fmt.Sprintf("Leak sensor %s detected water but %s could not be fully isolated: %v",
+				source, l.policy.Subsystem, err)
+		}
+		l.notifier.Notify(ctx, &api.Alert{
+			ID:        uuid.New().String(),
+			Severity:  api.AlertSeverityCritical,
+			Title:     "Leak detected: " + l.policy.Subsystem,
+			Message:   msg,
+			Key:       api.AlertKeyLeakDetected,
+			Params:    map[string]string{"subsystem": l.policy.Subsystem},
+			Source:    source,
+			Labels:    map[string]string{"subsystem": l.policy.Subsystem},
+			Timestamp: at,
+		})
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package control
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestLeakResponder_HandleReading(t *testing.T) {
+	cmd := &recordingCommander{}
+	alerts := &alertRecorder{}
+	l := NewLeakResponder(api.LeakResponse{
+		Subsystem: "sump",
+		LeakTags:  []string{"leak.sump", "leak.cabinet"},
+		ValveTags: []string{"valve.ato"},
+		PumpTags:  []string{"pump.return", "pump.skimmer"},
+	}, cmd, mapSource{}, alerts)
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wet := &api.SensorReading{Value: 1, Valid: true, Timestamp: now}
+	dry := &api.SensorReading{Value: 0, Valid: true, Timestamp: now}
+
+	if err := l.HandleReading(ctx, "leak.sump", dry); err != nil {
+		t.Fatalf("HandleReading() error = %v", err)
+	}
+	if len(cmd.calls) != 0 {
+		t.Fatalf("Expected no action for dry sensor, got %v", cmd.calls)
+	}
+
+	if err := l.HandleReading(ctx, "leak.sump", wet); err != nil {
+		t.Fatalf("HandleReading() error = %v", err)
+	}
+	want := []string{"off:valve.ato", "off:pump.return", "off:pump.skimmer"}
+	if !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Labels["subsystem"] != "sump" {
+		t.Fatalf("Expected one sump alert, got %+v", alerts.alerts)
+	}
+
+	// Repeated wet readings do not repeat the shutdown.
+	l.HandleReading(ctx, "leak.sump", wet)
+	if len(cmd.calls) != len(want) {
+		t.Errorf("Expected shutdown once, got %v", cmd.calls)
+	}
+	if got := l.Leaking(); !reflect.DeepEqual(got, []string{"leak.sump"}) {
+		t.Errorf("Expected leak.sump leaking, got %v", got)
+	}
+
+	// Readings from unrelated sensors are ignored.
+	l.HandleReading(ctx, "leak.other", wet)
+	if len(cmd.calls) != len(want) {
+		t.Errorf("Expected unrelated sensor to be ignored, got %v", cmd.calls)
+	}
+}
+
+func TestLeakResponder_Check(t *testing.T) {
+	cmd := &recordingCommander{}
+	l := NewLeakResponder(api.LeakResponse{
+		Subsystem: "sump",
+		LeakTags:  []string{"leak.sump"},
+		PumpTags:  []string{"pump.return"},
+	}, cmd, mapSource{"leak.sump": {Value: 1, Valid: true}}, nil)
+
+	if err := l.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if want := []string{"off:pump.return"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+}
@@ -0,0 +1,139 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+// minRateReadings is the fewest readings a rate is fitted to, so one noisy reading can't
+// raise an alert by itself
+const minRateReadings = 3
+
+// RateDetector raises a warning when a sensor's readings change faster than its rule
+// allows. The rate is the least-squares slope of the readings in the rule's window, which
+// is steadier than comparing the first and last reading.
+type RateDetector struct {
+	rule     api.RateOfChangeRule
+	history  ReadingHistory
+	notifier notify.Notifier
+
+	lock     sync.Mutex
+	latest   time.Time // timestamp of the newest reading judged so far
+	alerting bool
+}
+
+func NewRateDetector(rule api.RateOfChangeRule, history ReadingHistory, notifier notify.Notifier) *RateDetector {
+	if rule.Window <= 0 {
+		rule.Window = rule.Per
+	}
+	return &RateDetector{
+		rule:     rule,
+		history:  history,
+		notifier: notifier,
+	}
+}
+
+// Exceeded reports whether the rate was beyond the rule's limits at the last check. It
+// re-arms once a newer reading brings the rate back within them.
+func (d *RateDetector) Exceeded() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.alerting
+}
+
+// Check evaluates the rule at now
+func (d *RateDetector) Check(ctx context.Context, now time.Time) error {
+	if d.rule.Per <= 0 {
+		return errors.New("rate-of-change rule needs a per duration")
+	}
+	if d.rule.MaxRise <= 0 && d.rule.MaxFall <= 0 {
+		return errors.New("rate-of-change rule needs a max rise or max fall")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	readings, err := recentReadings(ctx, d.history, d.rule.SensorTag, now.Add(-d.rule.Window), now)
+	if err != nil {
+		return err
+	}
+	if len(readings) < minRateReadings {
+		traceFrom(ctx).note("%d readings in the window, need %d", len(readings), minRateReadings)
+		return nil
+	}
+	first, newest := readings[0], readings[len(readings)-1]
+	if newest.Timestamp.Sub(first.Timestamp) < d.rule.Window/2 {
+		// Too short a span to tell a trend from noise.
+		traceFrom(ctx).note("readings span %s, need %s", newest.Timestamp.Sub(first.Timestamp), d.rule.Window/2)
+		return nil
+	}
+	if !newest.Timestamp.After(d.latest) {
+		// Nothing new since the last check.
+		traceFrom(ctx).note("no new readings since the last check")
+		return nil
+	}
+	d.latest = newest.Timestamp
+
+	rate := slope(readings) * d.rule.Per.Seconds()
+	var reason string
+	switch {
+	case d.rule.MaxRise > 0 && rate > d.rule.MaxRise:
+		reason = fmt.Sprintf("rising %.2f%s per %s, faster than %.2f", rate, newest.Unit, d.rule.Per, d.rule.MaxRise)
+	case d.rule.MaxFall > 0 && -rate > d.rule.MaxFall:
+		reason = fmt.Sprintf("falling %.2f%s per %s, faster than %.2f", -rate, newest.Unit, d.rule.Per, d.rule.MaxFall)
+	}
+	if reason == "" {
+		d.alerting = false
+		return nil
+	}
+	if d.alerting {
+		traceFrom(ctx).suppress("still %s; already alerted", reason)
+		return nil
+	}
+	d.alerting = true
+
+	if d.notifier != nil {
+		d.notifier.Notify(ctx, &api.Alert{
+			ID:       uuid.New().String(),
+			Severity: api.AlertSeverityWarning,
+			Title:    "Changing too fast: " + d.rule.Name,
+			Message: fmt.Sprintf("Sensor %s is %s (now %.2f%s, over the last %s).",
+				d.rule.SensorTag, reason, newest.Value, newest.Unit, d.rule.Window),
+			Key:       api.AlertKeyRateOfChange,
+			Params:    map[string]string{"rule": d.rule.Name},
+			Source:    d.rule.SensorTag,
+			Timestamp: now,
+		})
+	}
+	return nil
+}
+
+// slope fits a line to readings by least squares and returns its gradient per second
+func slope(readings []api.SensorReading) float64 {
+	origin := readings[0].Timestamp
+	var sumX, sumY float64
+	for _, r := range readings {
+		sumX += r.Timestamp.Sub(origin).Seconds()
+		sumY += r.Value
+	}
+	n := float64(len(readings))
+	meanX, meanY := sumX/n, sumY/n
+	var cov, varX float64
+	for _, r := range readings {
+		dx := r.Timestamp.Sub(origin).Seconds() - meanX
+		cov += dx * (r.Value - meanY)
+		varX += dx * dx
+	}
+	if varX == 0 {
+		return 0
+	}
+	return cov / varX
+}
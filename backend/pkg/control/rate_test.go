@@ -0,0 +1,93 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ramp returns n readings stepping by step from start
+func ramp(n int, start, step float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = start + float64(i)*step
+	}
+	return values
+}
+
+func TestRateDetector_Rise(t *testing.T) {
+	h := &chartHistory{start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	alerts := &alertRecorder{}
+	d := NewRateDetector(api.RateOfChangeRule{Name: "tank", SensorTag: "temp.tank", MaxRise: 1, Per: 10 * time.Minute}, h, alerts)
+	ctx := context.Background()
+
+	now := h.add(ramp(11, 25, 0.05)...)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.Exceeded() || len(alerts.alerts) != 0 {
+		t.Fatalf("Expected a slow rise to be allowed, got alerts %+v", alerts.alerts)
+	}
+
+	// 0.2 a minute is 2 per 10 minutes, twice the limit.
+	now = h.add(ramp(10, 25.7, 0.2)...)
+	for i := 0; i < 2; i++ {
+		if err := d.Check(ctx, now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if !d.Exceeded() {
+		t.Error("Expected the fast rise to exceed the rule")
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected one alert, got %d", len(alerts.alerts))
+	}
+	if a := alerts.alerts[0]; a.Severity != api.AlertSeverityWarning || a.Key != api.AlertKeyRateOfChange || a.Source != "temp.tank" {
+		t.Errorf("Expected a warning keyed to the rule, got %+v", a)
+	}
+
+	now = h.add(ramp(11, 27.5, 0)...)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.Exceeded() {
+		t.Error("Expected the detector to re-arm once the reading levels off")
+	}
+}
+
+func TestRateDetector_Fall(t *testing.T) {
+	h := &chartHistory{start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	alerts := &alertRecorder{}
+	d := NewRateDetector(api.RateOfChangeRule{Name: "sump", SensorTag: "level.sump", MaxRise: 100, MaxFall: 2, Per: time.Hour, Window: 20 * time.Minute}, h, alerts)
+
+	// 0.05 a minute is 3 an hour.
+	now := h.add(ramp(21, 40, -0.05)...)
+	if err := d.Check(context.Background(), now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("Expected the fall to raise an alert, got %d", len(alerts.alerts))
+	}
+}
+
+func TestRateDetector_NeedsSpan(t *testing.T) {
+	h := &chartHistory{start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	alerts := &alertRecorder{}
+	d := NewRateDetector(api.RateOfChangeRule{Name: "tank", SensorTag: "temp.tank", MaxRise: 1, Per: 10 * time.Minute}, h, alerts)
+
+	// Three readings over two minutes can't show a trend over ten.
+	now := h.add(25, 27, 29)
+	if err := d.Check(context.Background(), now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 0 {
+		t.Errorf("Expected no alert from a short span, got %d", len(alerts.alerts))
+	}
+
+	bad := NewRateDetector(api.RateOfChangeRule{Name: "tank", SensorTag: "temp.tank", Per: time.Minute}, h, nil)
+	if err := bad.Check(context.Background(), now); err == nil {
+		t.Error("Expected an error for a rule without limits")
+	}
+}
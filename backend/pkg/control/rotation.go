@@ -0,0 +1,303 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+const defaultFlowSettle = 10 * time.Second
+
+// RuntimeStore keeps the runtime of each pump of a rotation and which pump is running, so
+// they survive restarts and the rotation moving between workers
+type RuntimeStore interface {
+	GetPumpRuntimes(ctx context.Context, rotation string) (map[string]time.Duration, string, error)
+	SetPumpRuntimes(ctx context.Context, rotation string, runtimes map[string]time.Duration, active string) error
+}
+
+// RotationController runs one pump of a redundant set at a time, tracking runtime per
+// pump and switching to the least-used standby once the active pump has run for
+// RotateAfter. Every switchover is verified against the flow sensor; if the standby
+// fails to establish flow the previous pump is restarted and an alert is raised.
+type RotationController struct {
+	cfg       api.PumpRotation
+	commander Commander
+	readings  ReadingSource
+	// runtimes, if set, seeds the tracked runtimes and running pump on the first Tick and
+	// saves them on every Tick after
+	runtimes RuntimeStore
+	notifier notify.Notifier
+	// after is swapped out by tests to avoid real sleeps
+	after func(time.Duration) <-chan time.Time
+
+	lock       sync.Mutex
+	loaded     bool
+	saved      string // the pump saved as running, resumed by the first start
+	active     string
+	activeAt   time.Time // when runtime was last accrued for the active pump
+	runSince   time.Time // when the active pump took over
+	runtime    map[string]time.Duration
+	lastFailed map[string]time.Time
+}
+
+func NewRotationController(cfg api.PumpRotation, commander Commander, readings ReadingSource, runtimes RuntimeStore, notifier notify.Notifier) *RotationController {
+	return &RotationController{
+		cfg:        cfg,
+		commander:  commander,
+		readings:   readings,
+		runtimes:   runtimes,
+		notifier:   notifier,
+		after:      time.After,
+		runtime:    make(map[string]time.Duration),
+		lastFailed: make(map[string]time.Time),
+	}
+}
+
+// Runtimes returns the tracked runtime of every pump
+func (r *RotationController) Runtimes() map[string]time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	out := make(map[string]time.Duration, len(r.cfg.PumpTags))
+	for _, tag := range r.cfg.PumpTags {
+		out[tag] = r.runtime[tag]
+	}
+	return out
+}
+
+// Active returns the tag of the running pump, or "" before the first Tick
+func (r *RotationController) Active() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.active
+}
+
+// Tick accrues runtime for the active pump and performs a switchover when one is due.
+// The first Tick loads the saved runtimes and starts the pump saved as running, or else
+// the one with the least runtime, switching every other pump off. Until a pump starts
+// with flow, each Tick tries again.
+func (r *RotationController) Tick(ctx context.Context, now time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.cfg.PumpTags) == 0 {
+		return errors.New("pump rotation has no pumps configured")
+	}
+
+	if !r.loaded && r.runtimes != nil {
+		saved, active, err := r.runtimes.GetPumpRuntimes(ctx, r.cfg.Name)
+		if err != nil {
+			return fmt.Errorf("failed to load pump runtimes: %w", err)
+		}
+		for tag, d := range saved {
+			r.runtime[tag] = d
+		}
+		r.saved = active
+	}
+	r.loaded = true
+
+	if r.active == "" {
+		if err := r.start(ctx, now); err != nil {
+			return err
+		}
+		return r.save(ctx)
+	}
+
+	r.runtime[r.active] += now.Sub(r.activeAt)
+	r.activeAt = now
+	if len(r.cfg.PumpTags) < 2 || now.Sub(r.runSince) < r.cfg.RotateAfter {
+		return r.save(ctx)
+	}
+	standby := r.leastUsed(r.active)
+	switchErr := r.switchover(ctx, standby, now)
+	// A failed save is retried on the next Tick
+	return errors.Join(switchErr, r.save(ctx))
+}
+
+// start runs the pump saved as running, unless it has failed to establish flow since,
+// or else the least-used pump. Every other pump is switched off first, as a previous
+// worker may have left one running, so the flow check sees the chosen pump alone. If
+// the pump fails to establish flow it is switched off again and an alert is raised; the
+// next Tick prefers a pump which hasn't failed.
+func (r *RotationController) start(ctx context.Context, now time.Time) error {
+	tag := r.saved
+	if !slices.Contains(r.cfg.PumpTags, tag) || !r.lastFailed[tag].IsZero() {
+		tag = r.leastUsed("")
+	}
+
+	var stopErrs []error
+	for _, other := range r.cfg.PumpTags {
+		if other == tag {
+			continue
+		}
+		if _, err := r.commander.Command(ctx, other, api.ActuatorCommand{Action: "off"}); err != nil {
+			stopErrs = append(stopErrs, fmt.Errorf("failed to stop %q: %w", other, err))
+		}
+	}
+	stopErr := errors.Join(stopErrs...)
+
+	flowErr := r.startVerified(ctx, tag)
+	if flowErr == nil {
+		r.active, r.activeAt, r.runSince = tag, now, now
+		if stopErr != nil {
+			msg := fmt.Sprintf("Pump %s started but the other pumps could not be switched off: %v", tag, stopErr)
+			r.alert(ctx, api.AlertSeverityCritical, tag, api.AlertKeyPumpSwitchoverFailed, "Pump switchover failed: "+r.cfg.Name, msg, now)
+		}
+		return stopErr
+	}
+
+	// Each Tick tries again, so only a pump's first failure to start is alerted
+	repeated := !r.lastFailed[tag].IsZero()
+	r.lastFailed[tag] = now
+	r.saved = ""
+	_, offErr := r.commander.Command(ctx, tag, api.ActuatorCommand{Action: "off"})
+	if offErr != nil {
+		offErr = fmt.Errorf("failed to stop %q: %w", tag, offErr)
+	}
+
+	// No pump is circulating, so this is critical whatever else went wrong
+	msg := fmt.Sprintf("Pump %s failed to establish flow (%v); no pump is running.", tag, flowErr)
+	if offErr != nil {
+		// The pump may be left running dry
+		msg += fmt.Sprintf(" %s could not be switched off: %v", tag, offErr)
+	}
+	if stopErr != nil {
+		msg += fmt.Sprintf(" Other pumps could not be switched off: %v", stopErr)
+	}
+	if !repeated || offErr != nil {
+		r.alert(ctx, api.AlertSeverityCritical, tag, api.AlertKeyPumpSwitchoverFailed, "Pump switchover failed: "+r.cfg.Name, msg, now)
+	}
+	return errors.Join(flowErr, offErr, stopErr)
+}
+
+// save writes the tracked runtimes and the active pump to the runtime store, if there is
+// one
+func (r *RotationController) save(ctx context.Context) error {
+	if r.runtimes == nil {
+		return nil
+	}
+	runtimes := make(map[string]time.Duration, len(r.cfg.PumpTags))
+	for _, tag := range r.cfg.PumpTags {
+		runtimes[tag] = r.runtime[tag]
+	}
+	if err := r.runtimes.SetPumpRuntimes(ctx, r.cfg.Name, runtimes, r.active); err != nil {
+		return fmt.Errorf("failed to save pump runtimes: %w", err)
+	}
+	return nil
+}
+
+// switchover stops the active pump, starts standby and checks flow. On failure the
+// previous pump is restarted so circulation is never lost for longer than FlowSettle.
+func (r *RotationController) switchover(ctx context.Context, standby string, now time.Time) error {
+	previous := r.active
+	if _, err := r.commander.Command(ctx, previous, api.ActuatorCommand{Action: "off"}); err != nil {
+		return fmt.Errorf("failed to stop %q for rotation: %w", previous, err)
+	}
+
+	flowErr := r.startVerified(ctx, standby)
+	if flowErr == nil {
+		r.active, r.activeAt, r.runSince = standby, now, now
+		return nil
+	}
+
+	r.lastFailed[standby] = now
+	_, stopErr := r.commander.Command(ctx, standby, api.ActuatorCommand{Action: "off"})
+	if stopErr != nil {
+		stopErr = fmt.Errorf("failed to stop %q: %w", standby, stopErr)
+	}
+	_, restartErr := r.commander.Command(ctx, previous, api.ActuatorCommand{Action: "on"})
+	if restartErr != nil {
+		restartErr = fmt.Errorf("failed to restart %q: %w", previous, restartErr)
+	}
+	// Give the previous pump another full interval before trying again.
+	r.runSince = now
+
+	severity := api.AlertSeverityWarning
+	msg := fmt.Sprintf("Standby pump %s failed to establish flow (%v); %s restarted.", standby, flowErr, previous)
+	if restartErr != nil {
+		severity = api.AlertSeverityCritical
+		msg = fmt.Sprintf("Standby pump %s failed to establish flow (%v) and %s could not be restarted: %v", standby, flowErr, previous, restartErr)
+	}
+	if stopErr != nil {
+		// The standby may be left running dry
+		severity = api.AlertSeverityCritical
+		msg += fmt.Sprintf(" %s could not be switched off: %v", standby, stopErr)
+	}
+	r.alert(ctx, severity, standby, api.AlertKeyPumpSwitchoverFailed, "Pump switchover failed: "+r.cfg.Name, msg, now)
+	return errors.Join(flowErr, stopErr, restartErr)
+}
+
+// startVerified switches tag on and confirms the flow sensor reaches MinFlow
+func (r *RotationController) startVerified(ctx context.Context, tag string) error {
+	if _, err := r.commander.Command(ctx, tag, api.ActuatorCommand{Action: "on"}); err != nil {
+		return fmt.Errorf("failed to start %q: %w", tag, err)
+	}
+	if r.cfg.FlowTag == "" {
+		return nil
+	}
+
+	settle := r.cfg.FlowSettle
+	if settle <= 0 {
+		settle = defaultFlowSettle
+	}
+	select {
+	case <-r.after(settle):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	reading, err := r.readings.LatestReading(ctx, r.cfg.FlowTag)
+	if err != nil {
+		return fmt.Errorf("failed to read flow from %q: %w", r.cfg.FlowTag, err)
+	}
+	if !reading.Valid || reading.Value < r.cfg.MinFlow {
+		return fmt.Errorf("flow %.2f below minimum %.2f", reading.Value, r.cfg.MinFlow)
+	}
+	return nil
+}
+
+// leastUsed picks the pump with the least runtime other than exclude, preferring pumps
+// which have not recently failed a switchover
+func (r *RotationController) leastUsed(exclude string) string {
+	var best string
+	for _, tag := range r.cfg.PumpTags {
+		if tag == exclude {
+			continue
+		}
+		if best == "" {
+			best = tag
+			continue
+		}
+		bestFailed, tagFailed := r.lastFailed[best], r.lastFailed[tag]
+		switch {
+		case tagFailed.Before(bestFailed):
+			best = tag
+		case tagFailed.Equal(bestFailed) && r.runtime[tag] < r.runtime[best]:
+			best = tag
+		}
+	}
+	return best
+}
+
+func (r *RotationController) alert(ctx context.Context, severity api.AlertSeverity, source, key, title, msg string, now time.Time) {
+	if r.notifier == nil {
+		return
+	}
+	r.notifier.Notify(ctx, &api.Alert{
+		ID:        uuid.New().String(),
+		Severity:  severity,
+		Title:     title,
+		Message:   msg,
+		Key:       key,
+		Params:    map[string]string{"rule": r.cfg.Name},
+		Source:    source,
+		Timestamp: now,
+	})
+}
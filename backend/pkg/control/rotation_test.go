@@ -0,0 +1,225 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// pumpRig simulates pumps feeding a flow sensor; broken pumps switch on but move no water
+type pumpRig struct {
+	recordingCommander
+	running map[string]bool
+	broken  map[string]bool
+}
+
+func (p *pumpRig) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	state, err := p.recordingCommander.Command(ctx, tag, cmd)
+	if err == nil {
+		p.running[tag] = cmd.Action == "on"
+	}
+	return state, err
+}
+
+func (p *pumpRig) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	var flow float64
+	for pump, on := range p.running {
+		if on && !p.broken[pump] {
+			flow += 20
+		}
+	}
+	return &api.SensorReading{Value: flow, Valid: true}, nil
+}
+
+func newTestRotation(rig *pumpRig, runtimes RuntimeStore, notifier *alertRecorder) *RotationController {
+	r := NewRotationController(api.PumpRotation{
+		Name:        "return",
+		PumpTags:    []string{"pump.a", "pump.b"},
+		FlowTag:     "flow.return",
+		MinFlow:     10,
+		RotateAfter: time.Hour,
+	}, rig, rig, runtimes, notifier)
+	r.after = func(time.Duration) <-chan time.Time {
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+	return r
+}
+
+type alertRecorder struct {
+	alerts []*api.Alert
+}
+
+func (a *alertRecorder) Notify(ctx context.Context, alert *api.Alert) error {
+	a.alerts = append(a.alerts, alert)
+	return nil
+}
+
+func TestRotationController_EqualizesRuntime(t *testing.T) {
+	rig := &pumpRig{running: map[string]bool{}}
+	alerts := &alertRecorder{}
+	store := storer.NewMemory()
+	store.SetPumpRuntimes(context.Background(), "return", map[string]time.Duration{"pump.a": 100 * time.Hour, "pump.b": 40 * time.Hour}, "")
+	r := newTestRotation(rig, store, alerts)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.Tick(context.Background(), start); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.b" {
+		t.Fatalf("Expected least-used pump.b to start, got %s", r.Active())
+	}
+
+	if err := r.Tick(context.Background(), start.Add(30*time.Minute)); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.b" {
+		t.Errorf("Expected no rotation before RotateAfter, got %s", r.Active())
+	}
+
+	if err := r.Tick(context.Background(), start.Add(time.Hour)); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.a" {
+		t.Errorf("Expected rotation to pump.a, got %s", r.Active())
+	}
+	if got := r.Runtimes()["pump.b"]; got != 41*time.Hour {
+		t.Errorf("Expected pump.b runtime 41h, got %v", got)
+	}
+	// A worker taking the rotation over carries on from the saved runtimes
+	if saved, active, _ := store.GetPumpRuntimes(context.Background(), "return"); saved["pump.b"] != 41*time.Hour || active != "pump.a" {
+		t.Errorf("Expected pump.b's 41h saved with pump.a running, got %v, %q", saved, active)
+	}
+	if want := []string{"off:pump.a", "on:pump.b", "off:pump.b", "on:pump.a"}; !reflect.DeepEqual(rig.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, rig.calls)
+	}
+	if len(alerts.alerts) != 0 {
+		t.Errorf("Expected no alerts, got %d", len(alerts.alerts))
+	}
+}
+
+func TestRotationController_StandbyNoFlow(t *testing.T) {
+	rig := &pumpRig{running: map[string]bool{}, broken: map[string]bool{"pump.b": true}}
+	alerts := &alertRecorder{}
+	r := newTestRotation(rig, nil, alerts)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.Tick(context.Background(), start); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.a" {
+		t.Fatalf("Expected pump.a to start, got %s", r.Active())
+	}
+
+	if err := r.Tick(context.Background(), start.Add(time.Hour)); err == nil {
+		t.Fatal("Expected error when standby fails to establish flow")
+	}
+	if r.Active() != "pump.a" {
+		t.Errorf("Expected pump.a to remain active, got %s", r.Active())
+	}
+	if !rig.running["pump.a"] || rig.running["pump.b"] {
+		t.Errorf("Expected only pump.a running, got %v", rig.running)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts.alerts))
+	}
+	if alerts.alerts[0].Source != "pump.b" || alerts.alerts[0].Severity != api.AlertSeverityWarning {
+		t.Errorf("Unexpected alert: %+v", alerts.alerts[0])
+	}
+}
+
+func TestRotationController_StandbyStuckOn(t *testing.T) {
+	rig := &pumpRig{running: map[string]bool{}, broken: map[string]bool{"pump.b": true}}
+	alerts := &alertRecorder{}
+	r := newTestRotation(rig, nil, alerts)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.Tick(context.Background(), start); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	// The standby's relay won't switch off again once it is on
+	rig.fail = map[string]error{}
+	r.after = func(time.Duration) <-chan time.Time {
+		rig.fail["pump.b"] = errors.New("device offline")
+		ch := make(chan time.Time, 1)
+		ch <- time.Time{}
+		return ch
+	}
+	err := r.Tick(context.Background(), start.Add(time.Hour))
+	if err == nil || !strings.Contains(err.Error(), `failed to stop "pump.b"`) {
+		t.Fatalf("Expected the failure to stop the standby, got %v", err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts.alerts))
+	}
+	if alert := alerts.alerts[0]; alert.Severity != api.AlertSeverityCritical || !strings.Contains(alert.Message, "pump.b could not be switched off") {
+		t.Errorf("Expected a critical alert naming the running standby, got %+v", alert)
+	}
+}
+
+func TestRotationController_ResumesSavedPump(t *testing.T) {
+	// A previous worker left pump.a running, and a restart left pump.b on as well
+	rig := &pumpRig{running: map[string]bool{"pump.a": true, "pump.b": true}}
+	alerts := &alertRecorder{}
+	store := storer.NewMemory()
+	store.SetPumpRuntimes(context.Background(), "return", map[string]time.Duration{"pump.a": 100 * time.Hour, "pump.b": 40 * time.Hour}, "pump.a")
+	r := newTestRotation(rig, store, alerts)
+
+	if err := r.Tick(context.Background(), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.a" {
+		t.Errorf("Expected saved pump.a to carry on, got %s", r.Active())
+	}
+	if !rig.running["pump.a"] || rig.running["pump.b"] {
+		t.Errorf("Expected only pump.a running, got %v", rig.running)
+	}
+	if len(alerts.alerts) != 0 {
+		t.Errorf("Expected no alerts, got %d", len(alerts.alerts))
+	}
+}
+
+func TestRotationController_FirstStartNoFlow(t *testing.T) {
+	rig := &pumpRig{running: map[string]bool{}, broken: map[string]bool{"pump.a": true}}
+	alerts := &alertRecorder{}
+	store := storer.NewMemory()
+	r := newTestRotation(rig, store, alerts)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.Tick(context.Background(), start); err == nil {
+		t.Fatal("Expected error when the first pump fails to establish flow")
+	}
+	if r.Active() != "" {
+		t.Errorf("Expected no active pump, got %s", r.Active())
+	}
+	if rig.running["pump.a"] || rig.running["pump.b"] {
+		t.Errorf("Expected the dry pump switched off, got %v", rig.running)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected 1 alert, got %d", len(alerts.alerts))
+	}
+	if alert := alerts.alerts[0]; alert.Source != "pump.a" || alert.Severity != api.AlertSeverityCritical {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+
+	// The next Tick moves on to the pump which hasn't failed
+	if err := r.Tick(context.Background(), start.Add(10*time.Second)); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if r.Active() != "pump.b" {
+		t.Errorf("Expected pump.b to start, got %s", r.Active())
+	}
+	if !rig.running["pump.b"] || rig.running["pump.a"] {
+		t.Errorf("Expected only pump.b running, got %v", rig.running)
+	}
+	if _, active, _ := store.GetPumpRuntimes(context.Background(), "return"); active != "pump.b" {
+		t.Errorf("Expected pump.b saved as running, got %q", active)
+	}
+}
@@ -0,0 +1,154 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// SceneController applies a scene's commands across however many drivers its actuators
+// belong to. Before sending anything it reads every actuator's last status, so an
+// offline driver or device is caught up front rather than half-way through, and then
+// follows the scene's policy.
+type SceneController struct {
+	scene     api.Scene
+	commander Commander
+	status    ReadingSource
+	// now is swapped out by tests
+	now func() time.Time
+
+	lock     sync.Mutex
+	lastTick time.Time
+}
+
+func NewSceneController(scene api.Scene, commander Commander, status ReadingSource) *SceneController {
+	return &SceneController{
+		scene:     scene,
+		commander: commander,
+		status:    status,
+		now:       time.Now,
+	}
+}
+
+// Check reads the last status of every actuator in the scene, returning the statuses of
+// those reachable by tag and the tags of those which are not
+func (s *SceneController) Check(ctx context.Context) (map[string]*api.SensorReading, []string) {
+	statuses := make(map[string]*api.SensorReading, len(s.scene.Commands))
+	var unreachable []string
+	for _, c := range s.scene.Commands {
+		r, err := s.status.LatestReading(ctx, c.ActuatorTag)
+		switch {
+		case err != nil, r.Error != "":
+			unreachable = append(unreachable, c.ActuatorTag)
+		case s.scene.MaxStatusAge > 0 && s.now().Sub(r.Timestamp) > s.scene.MaxStatusAge:
+			unreachable = append(unreachable, c.ActuatorTag)
+		default:
+			statuses[c.ActuatorTag] = r
+		}
+	}
+	return statuses, unreachable
+}
+
+// Apply sends the scene's commands in order. Under SceneAbortAll and SceneRollback nothing
+// is sent unless every actuator passes Check; under SceneRollback a command that still
+// fails switches the actuators already commanded back on or off as Check found them.
+// Under SceneContinue unreachable actuators are skipped and the rest commanded. The
+// result says what happened to each actuator; an error is returned unless all of them
+// were applied.
+func (s *SceneController) Apply(ctx context.Context) (*api.SceneResult, error) {
+	res := &api.SceneResult{Scene: s.scene.Name, Applied: []string{}}
+	statuses, unreachable := s.Check(ctx)
+	res.Unreachable = unreachable
+
+	policy := s.scene.Policy
+	if policy == "" {
+		policy = api.SceneAbortAll
+	}
+	if len(unreachable) > 0 && policy != api.SceneContinue {
+		return res, fmt.Errorf("scene %q not applied: unreachable actuators %v", s.scene.Name, unreachable)
+	}
+
+	var errs []error
+	for _, c := range s.scene.Commands {
+		if _, ok := statuses[c.ActuatorTag]; !ok {
+			continue
+		}
+		if _, err := s.commander.Command(ctx, c.ActuatorTag, c.Command); err != nil {
+			res.Failed = append(res.Failed, c.ActuatorTag)
+			errs = append(errs, fmt.Errorf("failed to command %q: %w", c.ActuatorTag, err))
+			if policy == api.SceneRollback {
+				errs = append(errs, s.rollback(ctx, res, statuses))
+				return res, errors.Join(errs...)
+			}
+			continue
+		}
+		res.Applied = append(res.Applied, c.ActuatorTag)
+	}
+	if len(unreachable) > 0 {
+		errs = append(errs, fmt.Errorf("unreachable actuators %v skipped", unreachable))
+	}
+	return res, errors.Join(errs...)
+}
+
+// rollback switches the actuators applied so far back to their state before the scene,
+// latest first
+func (s *SceneController) rollback(ctx context.Context, res *api.SceneResult, statuses map[string]*api.SensorReading) error {
+	var errs []error
+	var kept []string
+	for i := len(res.Applied) - 1; i >= 0; i-- {
+		tag := res.Applied[i]
+		action := "off"
+		if statuses[tag].Value != 0 {
+			action = "on"
+		}
+		if _, err := s.commander.Command(ctx, tag, api.ActuatorCommand{Action: action}); err != nil {
+			kept = append(kept, tag)
+			errs = append(errs, fmt.Errorf("failed to roll back %q: %w", tag, err))
+			continue
+		}
+		res.RolledBack = append(res.RolledBack, tag)
+	}
+	res.Applied = append(res.Applied[:0], kept...)
+	return errors.Join(errs...)
+}
+
+// Tick applies the scene when one of its times of day has passed since the previous
+// tick. The first tick only notes the time, so a worker starting up or taking over the
+// scene doesn't replay a time already passed.
+func (s *SceneController) Tick(ctx context.Context, now time.Time) error {
+	if err := s.scene.Validate(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	last := s.lastTick
+	s.lastTick = now
+	if last.IsZero() || !s.due(last, now) {
+		return nil
+	}
+	_, err := s.Apply(ctx)
+	return err
+}
+
+// due reports whether one of the scene's times of day falls after last and by now
+func (s *SceneController) due(last, now time.Time) bool {
+	for _, at := range s.scene.At {
+		t, err := time.Parse(api.SceneTimeLayout, at)
+		if err != nil {
+			continue
+		}
+		// Ticks are seconds apart, so only today's and yesterday's times can fall between
+		for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+			when := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+			if when.After(last) && !when.After(now) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func nightMode(policy api.ScenePolicy) api.Scene {
+	return api.Scene{
+		Name:   "night",
+		Policy: policy,
+		Commands: []api.SceneCommand{
+			{ActuatorTag: "light.main", Command: api.ActuatorCommand{Action: "off"}},
+			{ActuatorTag: "light.moon", Command: api.ActuatorCommand{Action: "on"}},
+			{ActuatorTag: "pump.wave", Command: api.ActuatorCommand{Action: "off"}},
+		},
+	}
+}
+
+func TestSceneController_AbortAll(t *testing.T) {
+	now := time.Now()
+	cmd := &recordingCommander{}
+	// The wave pump's driver is offline, so it has no status
+	status := mapSource{"light.main": reading(1, now), "light.moon": reading(0, now)}
+	s := NewSceneController(nightMode(""), cmd, status)
+
+	res, err := s.Apply(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error with an actuator unreachable")
+	}
+	if len(cmd.calls) != 0 {
+		t.Errorf("Expected nothing to be sent, got %v", cmd.calls)
+	}
+	if want := []string{"pump.wave"}; !reflect.DeepEqual(res.Unreachable, want) {
+		t.Errorf("Expected unreachable %v, got %v", want, res.Unreachable)
+	}
+}
+
+func TestSceneController_StaleStatus(t *testing.T) {
+	now := time.Now()
+	scene := nightMode(api.SceneAbortAll)
+	scene.MaxStatusAge = time.Minute
+	status := mapSource{"light.main": reading(1, now), "light.moon": reading(0, now), "pump.wave": reading(1, now.Add(-time.Hour))}
+	s := NewSceneController(scene, &recordingCommander{}, status)
+	s.now = func() time.Time { return now }
+
+	if _, unreachable := s.Check(context.Background()); !reflect.DeepEqual(unreachable, []string{"pump.wave"}) {
+		t.Errorf("Expected the stale pump to be unreachable, got %v", unreachable)
+	}
+}
+
+func TestSceneController_Continue(t *testing.T) {
+	now := time.Now()
+	cmd := &recordingCommander{}
+	status := mapSource{"light.main": reading(1, now), "light.moon": reading(0, now)}
+	s := NewSceneController(nightMode(api.SceneContinue), cmd, status)
+
+	res, err := s.Apply(context.Background())
+	if err == nil {
+		t.Error("Expected an error reporting the skipped actuator")
+	}
+	if want := []string{"off:light.main", "on:light.moon"}; !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if want := []string{"light.main", "light.moon"}; !reflect.DeepEqual(res.Applied, want) {
+		t.Errorf("Expected applied %v, got %v", want, res.Applied)
+	}
+}
+
+func TestSceneController_Rollback(t *testing.T) {
+	now := time.Now()
+	cmd := &recordingCommander{fail: map[string]error{"pump.wave": errors.New("timeout")}}
+	status := mapSource{"light.main": reading(1, now), "light.moon": reading(0, now), "pump.wave": reading(1, now)}
+	s := NewSceneController(nightMode(api.SceneRollback), cmd, status)
+
+	res, err := s.Apply(context.Background())
+	if err == nil {
+		t.Fatal("Expected an error with a command failing")
+	}
+	want := []string{"off:light.main", "on:light.moon", "off:pump.wave", "off:light.moon", "on:light.main"}
+	if !reflect.DeepEqual(cmd.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, cmd.calls)
+	}
+	if len(res.Applied) != 0 || !reflect.DeepEqual(res.RolledBack, []string{"light.moon", "light.main"}) || !reflect.DeepEqual(res.Failed, []string{"pump.wave"}) {
+		t.Errorf("Expected everything rolled back, got %+v", res)
+	}
+}
+
+func TestSceneController_Tick(t *testing.T) {
+	now := time.Date(2024, 1, 15, 20, 59, 55, 0, time.Local)
+	scene := nightMode(api.SceneContinue)
+	scene.At = []string{"21:00"}
+	cmd := &recordingCommander{}
+	status := mapSource{"light.main": reading(1, now), "light.moon": reading(0, now), "pump.wave": reading(1, now)}
+	s := NewSceneController(scene, cmd, status)
+	ctx := context.Background()
+
+	// A worker taking over at 21:00:05 must not replay 21:00
+	if err := s.Tick(ctx, now.Add(10*time.Second)); err != nil || len(cmd.calls) != 0 {
+		t.Fatalf("Expected the first tick only to note the time, got %v, %v", err, cmd.calls)
+	}
+
+	s = NewSceneController(scene, cmd, status)
+	for _, tick := range []time.Time{now, now.Add(10 * time.Second), now.Add(20 * time.Second)} {
+		if err := s.Tick(ctx, tick); err != nil {
+			t.Fatalf("Tick at %s: %v", tick.Format(time.TimeOnly), err)
+		}
+	}
+	if len(cmd.calls) != 3 {
+		t.Errorf("Expected the scene applied once at 21:00, got %v", cmd.calls)
+	}
+
+	scene.At = []string{"9pm"}
+	if err := NewSceneController(scene, cmd, status).Tick(ctx, now); err == nil {
+		t.Error("Expected an invalid time of day to be refused")
+	}
+}
@@ -0,0 +1,182 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSPCSigma       = 3.0
+	defaultSPCMinBaseline = 30
+	// spcRunLength is the number of most recent readings judged against the chart; it is
+	// the longest run the Western Electric rules look at
+	spcRunLength = 9
+)
+
+// SPCDetector keeps a control chart of a sensor's recent readings and raises a warning
+// when the sensor goes out of statistical control. The chart's centre line and limits
+// come from the rule's baseline window, so they follow slow seasonal drift while still
+// catching sudden shifts.
+type SPCDetector struct {
+	rule     api.SPCRule
+	history  ReadingHistory
+	notifier notify.Notifier
+
+	lock     sync.Mutex
+	latest   time.Time // timestamp of the newest reading judged so far
+	alerting bool
+}
+
+func NewSPCDetector(rule api.SPCRule, history ReadingHistory, notifier notify.Notifier) *SPCDetector {
+	if rule.Sigma <= 0 {
+		rule.Sigma = defaultSPCSigma
+	}
+	if rule.MinBaseline <= 0 {
+		rule.MinBaseline = defaultSPCMinBaseline
+	}
+	return &SPCDetector{
+		rule:     rule,
+		history:  history,
+		notifier: notifier,
+	}
+}
+
+// OutOfControl reports whether the sensor was out of control at the last check. It
+// re-arms once a newer reading is back in control.
+func (d *SPCDetector) OutOfControl() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.alerting
+}
+
+// Check evaluates the rule at now
+func (d *SPCDetector) Check(ctx context.Context, now time.Time) error {
+	if d.rule.Baseline <= 0 {
+		return errors.New("spc rule needs a baseline window")
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	readings, err := recentReadings(ctx, d.history, d.rule.SensorTag, now.Add(-d.rule.Baseline), now)
+	if err != nil {
+		return err
+	}
+	if len(readings) < spcRunLength+d.rule.MinBaseline {
+		traceFrom(ctx).note("%d readings in the baseline window, need %d", len(readings), spcRunLength+d.rule.MinBaseline)
+		return nil
+	}
+	newest := readings[len(readings)-1]
+	if !newest.Timestamp.After(d.latest) {
+		// Nothing new since the last check.
+		traceFrom(ctx).note("no new readings since the last check")
+		return nil
+	}
+	d.latest = newest.Timestamp
+
+	values := make([]float64, len(readings))
+	for i, r := range readings {
+		values[i] = r.Value
+	}
+
+	baseline, recent := values[:len(values)-spcRunLength], values[len(values)-spcRunLength:]
+	mean, sd := meanStdDev(baseline)
+	if sd == 0 {
+		// A perfectly flat baseline gives no limits to judge against.
+		traceFrom(ctx).note("baseline is flat")
+		return nil
+	}
+	reason := d.violation(recent, mean, sd)
+	if reason == "" {
+		d.alerting = false
+		return nil
+	}
+	if d.alerting {
+		traceFrom(ctx).suppress("still out of control (%s); already alerted", reason)
+		return nil
+	}
+	d.alerting = true
+
+	if d.notifier != nil {
+		last := recent[len(recent)-1]
+		d.notifier.Notify(ctx, &api.Alert{
+			ID:       uuid.New().String(),
+			Severity: api.AlertSeverityWarning,
+			Title:    "Out of statistical control: " + d.rule.Name,
+			Message: fmt.Sprintf("Sensor %s read %.2f%s: %s (baseline mean %.2f, standard deviation %.2f over %d readings).",
+				d.rule.SensorTag, last, newest.Unit, reason, mean, sd, len(baseline)),
+			Key:       api.AlertKeySPCOutOfControl,
+			Params:    map[string]string{"rule": d.rule.Name},
+			Source:    d.rule.SensorTag,
+			Timestamp: now,
+		})
+	}
+	return nil
+}
+
+// violation describes the first control-chart rule broken by the recent readings, or
+// returns "" while the sensor is in control. Zones are thirds of the control limit, so
+// with the default 3σ limit they are the familiar 1σ and 2σ bands.
+func (d *SPCDetector) violation(recent []float64, mean, sd float64) string {
+	zone := d.rule.Sigma / 3
+	z := make([]float64, len(recent))
+	for i, v := range recent {
+		z[i] = (v - mean) / sd
+	}
+	if last := z[len(z)-1]; math.Abs(last) > d.rule.Sigma {
+		return fmt.Sprintf("%.1fσ from the mean, beyond the %.1fσ control limit", math.Abs(last), d.rule.Sigma)
+	}
+	if !d.rule.RunRules {
+		return ""
+	}
+	if beyondOnOneSide(z[len(z)-3:], 2*zone, 2) {
+		return fmt.Sprintf("2 of the last 3 readings beyond %.1fσ on the same side", 2*zone)
+	}
+	if beyondOnOneSide(z[len(z)-5:], zone, 4) {
+		return fmt.Sprintf("4 of the last 5 readings beyond %.1fσ on the same side", zone)
+	}
+	if beyondOnOneSide(z, 0, len(z)) {
+		return fmt.Sprintf("the last %d readings all on the same side of the mean", len(z))
+	}
+	return ""
+}
+
+// beyondOnOneSide reports whether at least n of z lie further than limit from zero on
+// the same side
+func beyondOnOneSide(z []float64, limit float64, n int) bool {
+	var above, below int
+	for _, v := range z {
+		switch {
+		case v > limit:
+			above++
+		case v < -limit:
+			below++
+		}
+	}
+	return above >= n || below >= n
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	if len(values) < 2 {
+		return mean, 0
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
@@ -0,0 +1,134 @@
+package control
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// chartHistory serves one sensor's readings, newest first like the stores
+type chartHistory struct {
+	start time.Time
+	recs  []*api.ReadingRecord
+}
+
+func (h *chartHistory) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
+	return &api.Sensor{ID: "cond", DeviceID: "sump"}, nil
+}
+
+func (h *chartHistory) GetSensorReadings(ctx context.Context, filters storer.SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	var out []*api.ReadingRecord
+	for i := len(h.recs) - 1; i >= 0; i-- {
+		ts := h.recs[i].Reading.Timestamp
+		if filters.StartTime != nil && ts.Before(*filters.StartTime) || filters.EndTime != nil && ts.After(*filters.EndTime) {
+			continue
+		}
+		out = append(out, h.recs[i])
+	}
+	return out, nil
+}
+
+func (h *chartHistory) add(values ...float64) time.Time {
+	for _, v := range values {
+		h.recs = append(h.recs, &api.ReadingRecord{DeviceID: "sump", SensorID: "cond", Reading: api.SensorReading{
+			Value: v, Unit: api.UnitMicroSiemens, Valid: true,
+			Timestamp: h.start.Add(time.Duration(len(h.recs)) * time.Minute),
+		}})
+	}
+	return h.recs[len(h.recs)-1].Reading.Timestamp
+}
+
+// alternating returns n readings swinging between lo and hi
+func alternating(n int, lo, hi float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = lo
+		if i%2 == 1 {
+			values[i] = hi
+		}
+	}
+	return values
+}
+
+func TestSPCDetector_ControlLimit(t *testing.T) {
+	h := &chartHistory{start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	alerts := &alertRecorder{}
+	d := NewSPCDetector(api.SPCRule{Name: "sump", SensorTag: "cond.sump", Baseline: 24 * time.Hour}, h, alerts)
+	ctx := context.Background()
+
+	now := h.add(alternating(20, 1000, 1010)...)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.OutOfControl() {
+		t.Error("Expected no judgement before the baseline is long enough")
+	}
+
+	now = h.add(alternating(30, 1000, 1010)...)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.OutOfControl() || len(alerts.alerts) != 0 {
+		t.Fatalf("Expected an in-control sensor, got alerts %+v", alerts.alerts)
+	}
+
+	now = h.add(1100)
+	for i := 0; i < 2; i++ {
+		if err := d.Check(ctx, now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	if !d.OutOfControl() {
+		t.Error("Expected the spike to be out of control")
+	}
+	if len(alerts.alerts) != 1 {
+		t.Fatalf("Expected one alert, got %d", len(alerts.alerts))
+	}
+	if a := alerts.alerts[0]; a.Severity != api.AlertSeverityWarning || a.Key != api.AlertKeySPCOutOfControl || a.Source != "cond.sump" {
+		t.Errorf("Expected a warning keyed to the rule, got %+v", a)
+	}
+
+	now = h.add(1005)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if d.OutOfControl() {
+		t.Error("Expected the detector to re-arm once back in control")
+	}
+	now = h.add(900)
+	if err := d.Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 2 {
+		t.Errorf("Expected a second alert after re-arming, got %d", len(alerts.alerts))
+	}
+}
+
+func TestSPCDetector_RunRules(t *testing.T) {
+	for _, runRules := range []bool{false, true} {
+		h := &chartHistory{start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		alerts := &alertRecorder{}
+		d := NewSPCDetector(api.SPCRule{Name: "sump", SensorTag: "cond.sump", Baseline: 24 * time.Hour, RunRules: runRules}, h, alerts)
+
+		// A shift of under one standard deviation never crosses the control limit but
+		// keeps every recent reading above the centre line.
+		h.add(alternating(40, 1000, 1010)...)
+		now := h.add(1007, 1008, 1007, 1009, 1008, 1007, 1008, 1009, 1007)
+		if err := d.Check(context.Background(), now); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		if got := len(alerts.alerts) == 1; got != runRules {
+			t.Errorf("Expected alert %v with run rules %v, got %d alerts", runRules, runRules, len(alerts.alerts))
+		}
+	}
+}
+
+func TestSPCDetector_NeedsBaseline(t *testing.T) {
+	d := NewSPCDetector(api.SPCRule{Name: "sump", SensorTag: "cond.sump"}, &chartHistory{}, nil)
+	if err := d.Check(context.Background(), time.Now()); err == nil {
+		t.Error("Expected an error for a rule without a baseline window")
+	}
+}
@@ -0,0 +1,220 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/notify"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TraceRecorder stores rule traces, keeping each rule's newest keep
+type TraceRecorder interface {
+	RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error
+}
+
+// Tracer records every evaluation of periodic rules: the readings they read, how their
+// conditions came out and the commands and alerts they sent or held back. Rules read and
+// act through TraceReadings, TraceCommands and TraceAlerts for those to be captured. A
+// nil Tracer records nothing.
+type Tracer struct {
+	recorder TraceRecorder
+	keep     int
+}
+
+func NewTracer(recorder TraceRecorder, keep int) *Tracer {
+	return &Tracer{recorder: recorder, keep: keep}
+}
+
+// Loop wraps fn, the evaluation of the rule ref, so each run is recorded. Failing to
+// record a trace is logged and never fails the rule.
+func (t *Tracer) Loop(ref api.RuleRef, fn func(ctx context.Context, now time.Time) error) func(ctx context.Context, now time.Time) error {
+	if t == nil {
+		return fn
+	}
+	return func(ctx context.Context, now time.Time) error {
+		rt := &ruleTrace{trace: api.RuleTrace{Kind: ref.Kind, Name: ref.Name, Timestamp: now}}
+		err := fn(context.WithValue(ctx, ruleTraceKey{}, rt), now)
+
+		trace := rt.finish(err)
+		if recErr := t.recorder.RecordRuleTrace(ctx, trace, t.keep); recErr != nil {
+			ll := logging.Component(log.Ctx(ctx).With().
+				Str("component", "control").
+				Str("subcomponent", "trace").
+				Str("kind", ref.Kind).
+				Str("name", ref.Name).
+				Logger(), "control")
+			ll.Warn().Err(recErr).Msg("unable to record rule trace")
+		}
+		return err
+	}
+}
+
+type ruleTraceKey struct{}
+
+// ruleTrace collects one evaluation of a rule. Its methods do nothing on a nil trace, so
+// rules note what they do whether or not they are traced.
+type ruleTrace struct {
+	lock  sync.Mutex
+	trace api.RuleTrace
+}
+
+// traceFrom returns the trace being collected in ctx, or nil
+func traceFrom(ctx context.Context) *ruleTrace {
+	rt, _ := ctx.Value(ruleTraceKey{}).(*ruleTrace)
+	return rt
+}
+
+// input notes a reading the rule read, or failed to read
+func (rt *ruleTrace) input(tag string, r *api.SensorReading, err error) {
+	if rt == nil {
+		return
+	}
+	in := api.TraceInput{Tag: tag}
+	switch {
+	case err != nil:
+		in.Error = err.Error()
+	default:
+		value := r.Value
+		in.Value, in.Unit, in.Valid, in.Error = &value, r.Unit, r.Valid, r.Error
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Inputs = append(rt.trace.Inputs, in)
+}
+
+// condition notes how the rule's condition came out
+func (rt *ruleTrace) condition(status *api.ConditionStatus) {
+	if rt == nil {
+		return
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Condition = status
+}
+
+// action notes a command or alert the rule sent or held back
+func (rt *ruleTrace) action(a api.TraceAction) {
+	if rt == nil {
+		return
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Actions = append(rt.trace.Actions, a)
+}
+
+// note explains the evaluation's outcome without changing it
+func (rt *ruleTrace) note(format string, args ...interface{}) {
+	if rt == nil {
+		return
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Reason = fmt.Sprintf(format, args...)
+}
+
+// suppress marks the evaluation as held back for the given reason
+func (rt *ruleTrace) suppress(format string, args ...interface{}) {
+	if rt == nil {
+		return
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Outcome = api.TraceSuppressed
+	rt.trace.Reason = fmt.Sprintf(format, args...)
+}
+
+// disable marks the evaluation as skipped because the rule is disabled
+func (rt *ruleTrace) disable() {
+	if rt == nil {
+		return
+	}
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	rt.trace.Outcome = api.TraceDisabled
+	rt.trace.Reason = "a resource the rule depends on was deleted; see /api/rules/broken"
+}
+
+// finish settles the outcome once the evaluation returned err: an error, or else one
+// the rule chose, or else fired when it sent anything and idle otherwise
+func (rt *ruleTrace) finish(err error) *api.RuleTrace {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	trace := rt.trace
+	switch {
+	case err != nil:
+		trace.Outcome = api.TraceError
+		trace.Error = err.Error()
+	case trace.Outcome != "":
+	default:
+		trace.Outcome = api.TraceIdle
+		for _, a := range trace.Actions {
+			if a.Outcome != api.TraceActionSuppressed {
+				trace.Outcome = api.TraceFired
+				break
+			}
+		}
+	}
+	return &trace
+}
+
+// TraceReadings wraps readings so traced rules record what they read
+func TraceReadings(readings ReadingSource) ReadingSource {
+	return tracedReadings{next: readings}
+}
+
+type tracedReadings struct {
+	next ReadingSource
+}
+
+func (t tracedReadings) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	r, err := t.next.LatestReading(ctx, tag)
+	traceFrom(ctx).input(tag, r, err)
+	return r, err
+}
+
+// TraceCommands wraps commander so traced rules record the commands they send
+func TraceCommands(commander Commander) Commander {
+	return tracedCommander{next: commander}
+}
+
+type tracedCommander struct {
+	next Commander
+}
+
+func (t tracedCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	state, err := t.next.Command(ctx, tag, cmd)
+	a := api.TraceAction{Tag: tag, Action: cmd.Action, Outcome: api.TraceActionTaken}
+	if err != nil {
+		a.Outcome, a.Detail = api.TraceActionFailed, err.Error()
+	}
+	traceFrom(ctx).action(a)
+	return state, err
+}
+
+// TraceAlerts wraps notifier so traced rules record the alerts they raise
+func TraceAlerts(notifier notify.Notifier) notify.Notifier {
+	if notifier == nil {
+		return nil
+	}
+	return tracedNotifier{next: notifier}
+}
+
+type tracedNotifier struct {
+	next notify.Notifier
+}
+
+func (t tracedNotifier) Notify(ctx context.Context, alert *api.Alert) error {
+	err := t.next.Notify(ctx, alert)
+	a := api.TraceAction{Action: "alert", Outcome: api.TraceActionTaken, Detail: alert.Title}
+	if err != nil {
+		a.Outcome, a.Detail = api.TraceActionFailed, alert.Title+": "+err.Error()
+	}
+	traceFrom(ctx).action(a)
+	return err
+}
@@ -0,0 +1,135 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type traceRecorder struct {
+	traces []*api.RuleTrace
+	fail   error
+}
+
+func (r *traceRecorder) RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error {
+	if r.fail != nil {
+		return r.fail
+	}
+	r.traces = append(r.traces, trace)
+	return nil
+}
+
+func TestTracer_CompositeRule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	src := mapSource{
+		"heater.main": {Value: 1, Valid: true},
+		"flow.main":   {Value: 0, Unit: api.UnitLitersPerMin, Valid: true},
+	}
+	cmd := &recordingCommander{}
+	rule := api.CompositeRule{
+		Name: "heater-no-flow",
+		Condition: api.Condition{All: []api.Condition{
+			{Tag: "heater.main", Op: api.OpEqual, Value: 1},
+			{Tag: "flow.main", Op: api.OpEqual, Value: 0},
+		}},
+		For:          2 * time.Minute,
+		ActuatorTags: []string{"heater.main"},
+		Action:       "off",
+	}
+	recorder := &traceRecorder{}
+	d := NewCompositeDetector(rule, TraceCommands(cmd), TraceReadings(src), TraceAlerts(&alertRecorder{}))
+	check := NewTracer(recorder, 10).Loop(rule.Ref(), d.Check)
+
+	ctx := context.Background()
+	for _, offset := range []time.Duration{0, 2 * time.Minute, 3 * time.Minute} {
+		if err := check(ctx, start.Add(offset)); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	src["flow.main"] = &api.SensorReading{Value: 2, Unit: api.UnitLitersPerMin, Valid: true}
+	if err := check(ctx, start.Add(4*time.Minute)); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	var outcomes []api.TraceOutcome
+	for _, tr := range recorder.traces {
+		outcomes = append(outcomes, tr.Outcome)
+	}
+	want := []api.TraceOutcome{api.TraceSuppressed, api.TraceFired, api.TraceSuppressed, api.TraceIdle}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Fatalf("Expected outcomes %v, got %v", want, outcomes)
+	}
+
+	held := recorder.traces[0]
+	if len(held.Actions) != 1 || held.Actions[0].Outcome != api.TraceActionSuppressed {
+		t.Errorf("Expected the held command to be traced as suppressed, got %+v", held.Actions)
+	}
+	fired := recorder.traces[1]
+	if len(fired.Inputs) != 2 || fired.Inputs[1].Tag != "flow.main" || *fired.Inputs[1].Value != 0 {
+		t.Errorf("Expected both readings as inputs, got %+v", fired.Inputs)
+	}
+	if fired.Condition == nil || fired.Condition.Result != api.ConditionTrue || len(fired.Condition.Children) != 2 {
+		t.Errorf("Expected the per-condition results, got %+v", fired.Condition)
+	}
+	wantActions := []api.TraceAction{
+		{Tag: "heater.main", Action: "off", Outcome: api.TraceActionTaken},
+		{Action: "alert", Outcome: api.TraceActionTaken, Detail: "Rule triggered: heater-no-flow"},
+	}
+	if !reflect.DeepEqual(fired.Actions, wantActions) {
+		t.Errorf("Expected actions %+v, got %+v", wantActions, fired.Actions)
+	}
+	if idle := recorder.traces[3]; idle.Condition.Result != api.ConditionFalse || idle.Kind != api.RuleKindComposite || idle.Name != rule.Name {
+		t.Errorf("Expected an idle trace of the rule with a false condition, got %+v", idle)
+	}
+}
+
+func TestTracer_DisabledAndErrors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ref := api.RuleRef{Kind: api.RuleKindDryRun, Name: "return-dry"}
+	source := &staticBrokenSource{refs: []*api.BrokenReference{{Kind: ref.Kind, Name: ref.Name, Tag: "pump.return"}}}
+	broken := NewBrokenRules(source, time.Minute)
+	recorder := &traceRecorder{}
+	tracer := NewTracer(recorder, 10)
+
+	ran := false
+	check := tracer.Loop(ref, broken.GuardLoop(ref, func(ctx context.Context, now time.Time) error {
+		ran = true
+		return nil
+	}))
+	if err := check(context.Background(), now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if ran {
+		t.Error("Expected a disabled rule to be skipped")
+	}
+	if len(recorder.traces) != 1 || recorder.traces[0].Outcome != api.TraceDisabled {
+		t.Fatalf("Expected a disabled trace, got %+v", recorder.traces)
+	}
+
+	check = tracer.Loop(ref, func(ctx context.Context, now time.Time) error {
+		return errors.New("no pump")
+	})
+	if err := check(context.Background(), now); err == nil || err.Error() != "no pump" {
+		t.Errorf("Expected the rule's error, got %v", err)
+	}
+	if tr := recorder.traces[1]; tr.Outcome != api.TraceError || tr.Error != "no pump" {
+		t.Errorf("Expected an error trace, got %+v", tr)
+	}
+
+	// Failing to store a trace never fails the rule.
+	recorder.fail = errors.New("database down")
+	check = tracer.Loop(ref, func(ctx context.Context, now time.Time) error { return nil })
+	if err := check(context.Background(), now); err != nil {
+		t.Errorf("Expected no error when recording fails, got %v", err)
+	}
+
+	// A nil tracer leaves the rule as is.
+	var none *Tracer
+	if err := none.Loop(ref, func(ctx context.Context, now time.Time) error { return nil })(context.Background(), now); err != nil {
+		t.Errorf("Expected no error from an untraced rule, got %v", err)
+	}
+}
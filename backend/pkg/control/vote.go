@@ -0,0 +1,222 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// ReadingSource provides the latest reading for a sensor tag
+type ReadingSource interface {
+	LatestReading(ctx context.Context, tag string) (*api.SensorReading, error)
+}
+
+// Measure fetches the latest reading of every probe in m from src and votes on them.
+// Probes whose reading cannot be fetched are treated as missing.
+func Measure(ctx context.Context, m *api.LogicalMeasurement, src ReadingSource, now time.Time) *api.VoteResult {
+	readings := make(map[string]*api.SensorReading, len(m.SensorTags))
+	for _, tag := range m.SensorTags {
+		r, err := src.LatestReading(ctx, tag)
+		if err != nil {
+			continue
+		}
+		readings[tag] = r
+	}
+	return Vote(m, readings, now)
+}
+
+// Vote combines the readings of a logical measurement's probes. Probes agreeing with the
+// median (within MaxDeviation) vote; if at least Quorum probes agree, their median is
+// trusted. Otherwise the result is invalid, unless exactly one probe remains and the
+// degraded policy allows trusting it.
+func Vote(m *api.LogicalMeasurement, readings map[string]*api.SensorReading, now time.Time) *api.VoteResult {
+	result := &api.VoteResult{Timestamp: now}
+
+	type probe struct {
+		tag     string
+		reading *api.SensorReading
+	}
+	var fresh []probe
+	for _, tag := range m.SensorTags {
+		r := readings[tag]
+		if r == nil || !r.Valid || (m.MaxAge > 0 && now.Sub(r.Timestamp) > m.MaxAge) {
+			result.Missing = append(result.Missing, tag)
+			continue
+		}
+		fresh = append(fresh, probe{tag: tag, reading: r})
+	}
+
+	quorum := m.Quorum
+	if quorum <= 0 {
+		quorum = len(m.SensorTags)/2 + 1
+	}
+
+	if len(fresh) == 0 {
+		result.Degraded = true
+		result.Reason = "no probes available"
+		return result
+	}
+
+	values := make([]float64, len(fresh))
+	for i, p := range fresh {
+		values[i] = p.reading.Value
+	}
+	center := median(values)
+
+	var agreeing []probe
+	for _, p := range fresh {
+		if math.Abs(p.reading.Value-center) <= m.MaxDeviation {
+			agreeing = append(agreeing, p)
+		} else {
+			result.Outvoted = append(result.Outvoted, p.tag)
+		}
+	}
+
+	use := func(probes []probe) {
+		vals := make([]float64, len(probes))
+		var newest time.Time
+		for i, p := range probes {
+			vals[i] = p.reading.Value
+			result.Voters = append(result.Voters, p.tag)
+			if p.reading.Timestamp.After(newest) {
+				newest = p.reading.Timestamp
+			}
+		}
+		result.Value = median(vals)
+		result.Timestamp = newest
+		result.Unit = probes[0].reading.Unit
+		result.Valid = true
+	}
+
+	switch {
+	case len(agreeing) >= quorum:
+		use(agreeing)
+		result.Degraded = len(result.Missing) > 0 || len(result.Outvoted) > 0
+	case len(fresh) == 1 && m.Degraded == api.DegradedTrustRemaining:
+		use(fresh)
+		result.Degraded = true
+		result.Reason = "only one probe available"
+	default:
+		result.Degraded = true
+		result.Reason = fmt.Sprintf("quorum not reached: %d of %d required probes agree", len(agreeing), quorum)
+	}
+	return result
+}
+
+// Measurements lets rules refer to a logical measurement by name wherever they take a
+// sensor tag. The latest reading of a name is the vote of its probes' latest readings,
+// and its history is their vote at each of their stored readings. Other tags are read
+// from the wrapped sources as they are.
+type Measurements struct {
+	measurements map[string]*api.LogicalMeasurement
+	readings     ReadingSource
+	history      ReadingHistory
+}
+
+// NewMeasurements resolves the names of measurements, reading their probes from readings
+// and history. history may be nil when only latest readings are needed.
+func NewMeasurements(measurements []api.LogicalMeasurement, readings ReadingSource, history ReadingHistory) *Measurements {
+	m := &Measurements{
+		measurements: make(map[string]*api.LogicalMeasurement, len(measurements)),
+		readings:     readings,
+		history:      history,
+	}
+	for i := range measurements {
+		m.measurements[measurements[i].Name] = &measurements[i]
+	}
+	return m
+}
+
+// LatestReading returns the vote of a logical measurement's probes, which is invalid
+// when they reach no quorum, or the latest reading of any other tag
+func (m *Measurements) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	lm, ok := m.measurements[tag]
+	if !ok {
+		return m.readings.LatestReading(ctx, tag)
+	}
+	return voteReading(Measure(ctx, lm, m.readings, time.Now())), nil
+}
+
+func (m *Measurements) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
+	return m.history.GetSensorByTag(ctx, tag)
+}
+
+func (m *Measurements) GetSensorReadings(ctx context.Context, filters storer.SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	return m.history.GetSensorReadings(ctx, filters)
+}
+
+// votedReadings votes on the probes of lm at the time of each of their readings taken
+// between start and end, oldest first, leaving out the times no quorum is reached.
+// Probes whose history can't be read are treated as missing.
+func (m *Measurements) votedReadings(ctx context.Context, lm *api.LogicalMeasurement, start, end time.Time) ([]api.SensorReading, error) {
+	series := make(map[string][]api.SensorReading, len(lm.SensorTags))
+	var times []time.Time
+	var errs []error
+	for _, tag := range lm.SensorTags {
+		// Readings from before the window may still be fresh enough to vote at its start
+		readings, err := recentReadings(ctx, m.history, tag, start.Add(-lm.MaxAge), end)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		series[tag] = readings
+		for _, r := range readings {
+			if !r.Timestamp.Before(start) {
+				times = append(times, r.Timestamp)
+			}
+		}
+	}
+	if len(series) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("no probe of %q could be read: %w", lm.Name, errors.Join(errs...))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	var voted []api.SensorReading
+	next := make(map[string]int, len(series))
+	for i, at := range times {
+		if i > 0 && at.Equal(times[i-1]) {
+			continue
+		}
+		latest := make(map[string]*api.SensorReading, len(series))
+		for tag, readings := range series {
+			for next[tag] < len(readings) && !readings[next[tag]].Timestamp.After(at) {
+				next[tag]++
+			}
+			if next[tag] > 0 {
+				latest[tag] = &readings[next[tag]-1]
+			}
+		}
+		if r := voteReading(Vote(lm, latest, at)); r.Valid {
+			r.Timestamp = at
+			voted = append(voted, *r)
+		}
+	}
+	return voted, nil
+}
+
+// voteReading is the reading a vote stands for
+func voteReading(result *api.VoteResult) *api.SensorReading {
+	return &api.SensorReading{
+		Value:     result.Value,
+		Unit:      result.Unit,
+		Timestamp: result.Timestamp,
+		Valid:     result.Valid,
+		Error:     result.Reason,
+	}
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,240 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+func reading(v float64, at time.Time) *api.SensorReading {
+	return &api.SensorReading{Value: v, Unit: api.UnitPH, Timestamp: at, Valid: true}
+}
+
+func TestVote(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := &api.LogicalMeasurement{
+		Name:         "sump-ph",
+		SensorTags:   []string{"ph.a", "ph.b", "ph.c"},
+		MaxDeviation: 0.2,
+		MaxAge:       time.Minute,
+	}
+
+	tests := []struct {
+		name         string
+		policy       api.DegradedPolicy
+		readings     map[string]*api.SensorReading
+		wantValid    bool
+		wantDegraded bool
+		wantValue    float64
+		wantOutvoted []string
+	}{
+		{
+			name: "all probes agree",
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now), "ph.b": reading(8.2, now), "ph.c": reading(8.15, now),
+			},
+			wantValid: true,
+			wantValue: 8.15,
+		},
+		{
+			name: "failed probe is outvoted",
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now), "ph.b": reading(8.2, now), "ph.c": reading(4.0, now),
+			},
+			wantValid:    true,
+			wantDegraded: true,
+			wantValue:    8.15,
+			wantOutvoted: []string{"ph.c"},
+		},
+		{
+			name: "stale reading does not vote",
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now), "ph.b": reading(8.3, now), "ph.c": reading(8.2, now.Add(-time.Hour)),
+			},
+			wantValid:    true,
+			wantDegraded: true,
+			wantValue:    8.2,
+		},
+		{
+			name: "single probe fails safe by default",
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now),
+			},
+			wantValid:    false,
+			wantDegraded: true,
+		},
+		{
+			name:   "single probe trusted when policy allows",
+			policy: api.DegradedTrustRemaining,
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now),
+			},
+			wantValid:    true,
+			wantDegraded: true,
+			wantValue:    8.1,
+		},
+		{
+			name:   "two disagreeing probes cannot reach quorum",
+			policy: api.DegradedTrustRemaining,
+			readings: map[string]*api.SensorReading{
+				"ph.a": reading(8.1, now), "ph.b": reading(6.0, now),
+			},
+			wantValid:    false,
+			wantDegraded: true,
+			wantOutvoted: []string{"ph.a", "ph.b"},
+		},
+		{
+			name:         "no probes",
+			readings:     map[string]*api.SensorReading{},
+			wantValid:    false,
+			wantDegraded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mm := *m
+			mm.Degraded = tt.policy
+			got := Vote(&mm, tt.readings, now)
+			if got.Valid != tt.wantValid {
+				t.Errorf("Vote() Valid = %v, want %v (reason %q)", got.Valid, tt.wantValid, got.Reason)
+			}
+			if got.Degraded != tt.wantDegraded {
+				t.Errorf("Vote() Degraded = %v, want %v", got.Degraded, tt.wantDegraded)
+			}
+			if tt.wantValid && math.Abs(got.Value-tt.wantValue) > 1e-9 {
+				t.Errorf("Vote() Value = %v, want %v", got.Value, tt.wantValue)
+			}
+			if len(got.Outvoted) != len(tt.wantOutvoted) {
+				t.Errorf("Vote() Outvoted = %v, want %v", got.Outvoted, tt.wantOutvoted)
+			}
+		})
+	}
+}
+
+type mapSource map[string]*api.SensorReading
+
+func (m mapSource) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	r, ok := m[tag]
+	if !ok {
+		return nil, errors.New("no data")
+	}
+	return r, nil
+}
+
+func TestMeasure_MissingProbe(t *testing.T) {
+	now := time.Now()
+	m := &api.LogicalMeasurement{
+		SensorTags:   []string{"temp.a", "temp.b"},
+		MaxDeviation: 0.5,
+	}
+	src := mapSource{"temp.a": reading(25, now)}
+
+	got := Measure(context.Background(), m, src, now)
+	if got.Valid {
+		t.Error("Measure() should be invalid with one of two probes and fail-safe policy")
+	}
+	if len(got.Missing) != 1 || got.Missing[0] != "temp.b" {
+		t.Errorf("Measure() Missing = %v, want [temp.b]", got.Missing)
+	}
+}
+
+func TestMeasurements_LatestReading(t *testing.T) {
+	now := time.Now()
+	m := NewMeasurements([]api.LogicalMeasurement{{
+		Name:         "sump-ph",
+		SensorTags:   []string{"ph.a", "ph.b", "ph.c"},
+		MaxDeviation: 0.2,
+	}}, mapSource{
+		"ph.a": reading(8.1, now), "ph.b": reading(8.2, now), "ph.c": reading(4.0, now), "temp": reading(25, now),
+	}, nil)
+	ctx := context.Background()
+
+	// A composite rule naming the measurement sees the vote, not the failed probe
+	status := EvaluateCondition(ctx, m, api.Condition{Tag: "sump-ph", Op: api.OpGreater, Value: 8})
+	if status.Result != api.ConditionTrue || status.Value == nil || math.Abs(*status.Value-8.15) > 1e-9 {
+		t.Errorf("Expected the voted 8.15 to be over 8, got %+v", status)
+	}
+	if r, err := m.LatestReading(ctx, "temp"); err != nil || r.Value != 25 {
+		t.Errorf("Expected other tags to be read as they are, got %+v, %v", r, err)
+	}
+
+	quorumless := NewMeasurements([]api.LogicalMeasurement{{Name: "sump-ph", SensorTags: []string{"ph.a", "ph.x", "ph.y"}}}, mapSource{"ph.a": reading(8.1, now)}, nil)
+	if r, err := quorumless.LatestReading(ctx, "sump-ph"); err != nil || r.Valid || r.Error == "" {
+		t.Errorf("Expected an invalid reading without quorum, got %+v, %v", r, err)
+	}
+}
+
+// probeHistories serves a chartHistory for each probe tag
+type probeHistories map[string]*chartHistory
+
+func (p probeHistories) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
+	if _, ok := p[tag]; !ok {
+		return nil, storer.ErrNotFound
+	}
+	return &api.Sensor{ID: tag, DeviceID: "sump"}, nil
+}
+
+func (p probeHistories) GetSensorReadings(ctx context.Context, filters storer.SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	return p[filters.SensorID].GetSensorReadings(ctx, filters)
+}
+
+func TestMeasurements_History(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	probes := probeHistories{"cond.a": {start: start}, "cond.b": {start: start}, "cond.c": {start: start}}
+	for _, h := range probes {
+		h.add(alternating(40, 1000, 1010)...)
+	}
+	probes["cond.a"].add(alternating(9, 1000, 1010)...)
+	probes["cond.b"].add(alternating(9, 1002, 1008)...)
+	// A failing probe drifts far enough to trip the rule by itself
+	now := probes["cond.c"].add(alternating(9, 1100, 1110)...)
+	m := NewMeasurements([]api.LogicalMeasurement{{
+		Name:         "sump-cond",
+		SensorTags:   []string{"cond.a", "cond.b", "cond.c", "cond.gone"},
+		Quorum:       2,
+		MaxDeviation: 20,
+	}}, nil, probes)
+	ctx := context.Background()
+
+	alerts := &alertRecorder{}
+	rule := api.SPCRule{Name: "sump", SensorTag: "sump-cond", Baseline: 24 * time.Hour}
+	if err := NewSPCDetector(rule, m, alerts).Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 0 {
+		t.Errorf("Expected the vote to outvote the failing probe, got alerts %+v", alerts.alerts)
+	}
+
+	rule.SensorTag = "cond.c"
+	if err := NewSPCDetector(rule, m, alerts).Check(ctx, now); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(alerts.alerts) != 1 {
+		t.Errorf("Expected the failing probe alone to raise an alert, got %d", len(alerts.alerts))
+	}
+
+	voted, err := m.votedReadings(ctx, m.measurements["sump-cond"], start, now)
+	if err != nil {
+		t.Fatalf("votedReadings() error = %v", err)
+	}
+	if len(voted) != 49 || voted[48].Value != 1001 {
+		t.Errorf("Expected 49 votes ending at 1001, got %+v", voted)
+	}
+}
+
+func BenchmarkVote(b *testing.B) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := &api.LogicalMeasurement{SensorTags: []string{"ph.a", "ph.b", "ph.c"}, MaxDeviation: 0.2}
+	readings := map[string]*api.SensorReading{
+		"ph.a": reading(8.1, now), "ph.b": reading(8.2, now), "ph.c": reading(9.0, now),
+	}
+	for b.Loop() {
+		Vote(m, readings, now)
+	}
+}
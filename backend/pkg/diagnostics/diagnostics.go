@@ -0,0 +1,145 @@
+// Package diagnostics serves pprof profiles and runtime statistics for diagnosing a
+// process that has started lagging
+package diagnostics
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/latency"
+)
+
+// RuntimeStats is a snapshot of the Go runtime and the process's own queues
+type RuntimeStats struct {
+	Goroutines  int           `json:"goroutines"`
+	HeapAlloc   uint64        `json:"heap_alloc"`
+	HeapInuse   uint64        `json:"heap_inuse"`
+	HeapObjects uint64        `json:"heap_objects"`
+	NumGC       uint32        `json:"num_gc"`
+	PauseTotal  time.Duration `json:"gc_pause_total"`
+	// Gauges are the registered queue depths and table sizes, by name
+	Gauges map[string]int `json:"gauges,omitempty"`
+}
+
+type Option func(*Handler)
+
+// WithToken requires requests to carry token as a bearer token. Without one, only
+// requests from a loopback address are served.
+func WithToken(token string) Option {
+	return func(h *Handler) {
+		h.token = token
+	}
+}
+
+// WithGauge reports the value of fn under name in the runtime stats, such as the depth
+// of a queue. fn must be safe to call concurrently.
+func WithGauge(name string, fn func() int) Option {
+	return func(h *Handler) {
+		h.gauges[name] = fn
+	}
+}
+
+// WithLatency serves the histograms of rec at /debug/latency and its SLO report at
+// /debug/slo
+func WithLatency(rec *latency.Recorder) Option {
+	return func(h *Handler) {
+		h.latency = rec
+	}
+}
+
+// Handler serves /debug/pprof/ and the runtime stats at /debug/runtime
+type Handler struct {
+	token   string
+	gauges  map[string]func() int
+	latency *latency.Recorder
+	mux     *http.ServeMux
+}
+
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{gauges: map[string]func() int{}, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	h.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	h.mux.HandleFunc("/debug/runtime", h.serveRuntime)
+	if h.latency != nil {
+		h.mux.HandleFunc("/debug/latency", h.latency.ServeHistograms)
+		h.mux.HandleFunc("/debug/slo", h.latency.ServeReport)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r) {
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// Stats returns the current runtime stats
+func (h *Handler) Stats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := RuntimeStats{
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   mem.HeapAlloc,
+		HeapInuse:   mem.HeapInuse,
+		HeapObjects: mem.HeapObjects,
+		NumGC:       mem.NumGC,
+		PauseTotal:  time.Duration(mem.PauseTotalNs),
+	}
+	if len(h.gauges) > 0 {
+		stats.Gauges = make(map[string]int, len(h.gauges))
+		for name, fn := range h.gauges {
+			stats.Gauges[name] = fn()
+		}
+	}
+	return stats
+}
+
+func (h *Handler) serveRuntime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Stats())
+}
+
+// authorize checks the request carries the token, or without one comes from a loopback
+// address, writing the error response if not
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if h.token == "" {
+		if !IsLoopback(r.RemoteAddr) {
+			http.Error(w, "Debug endpoints are only served to localhost", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// IsLoopback reports whether addr, a host:port or bare host, is a loopback address or
+// localhost
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
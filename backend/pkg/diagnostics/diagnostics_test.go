@@ -0,0 +1,117 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/latency"
+)
+
+func TestHandler_LocalhostOnlyWithoutToken(t *testing.T) {
+	h := NewHandler()
+
+	req := httptest.NewRequest("GET", "/debug/runtime", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d for a remote request, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req.RemoteAddr = "127.0.0.1:52100"
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d for a local request, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestHandler_Token(t *testing.T) {
+	h := NewHandler(WithToken("secret"))
+
+	for _, tc := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/debug/pprof/", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("Expected status %d for %q, got %d", tc.want, tc.auth, rr.Code)
+		}
+	}
+}
+
+func TestHandler_RuntimeStats(t *testing.T) {
+	depth := 7
+	h := NewHandler(WithToken("secret"), WithGauge("ingest.pending_readings", func() int { return depth }))
+
+	req := httptest.NewRequest("GET", "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var stats RuntimeStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Goroutines == 0 || stats.HeapAlloc == 0 {
+		t.Errorf("Expected runtime stats to be filled in, got %+v", stats)
+	}
+	if stats.Gauges["ingest.pending_readings"] != 7 {
+		t.Errorf("Expected gauge 7, got %v", stats.Gauges)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:6060": true,
+		"[::1]:6060":     true,
+		"localhost:6060": true,
+		":6060":          false,
+		"0.0.0.0:6060":   false,
+		"10.0.0.4:6060":  false,
+	} {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("Expected IsLoopback(%q) = %v, got %v", addr, want, got)
+		}
+	}
+}
+
+func TestHandler_Latency(t *testing.T) {
+	rec := latency.NewRecorder()
+	rec.Histogram(latency.CommandConfirmed).Observe(200 * time.Millisecond)
+	h := NewHandler(WithToken("secret"), WithLatency(rec))
+
+	for _, path := range []string{"/debug/latency", "/debug/slo"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusOK, path, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	NewHandler(WithToken("secret")).ServeHTTP(rr, func() *http.Request {
+		req := httptest.NewRequest("GET", "/debug/slo", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return req
+	}())
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without a recorder, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package drivers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog/log"
+)
+
+// budgetPollInterval is how often a deferred command rechecks its circuit's draw
+const budgetPollInterval = 5 * time.Second
+
+// WithPowerBudgets holds commands switching on actuators to the power budgets of their
+// circuits
+func WithPowerBudgets(budgets []api.PowerBudget) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.budgets = budgets
+	}
+}
+
+// awaitBudgets returns once cmd fits within the power budget of every circuit the
+// actuator tagged tag is on, waiting while a deferring budget has no room, and fails
+// with ErrOverBudget if it doesn't fit in time
+func (d *Dispatcher) awaitBudgets(ctx context.Context, tag string, cmd api.ActuatorCommand) error {
+	// Switching off only ever frees power
+	if cmd.Action == "off" {
+		return nil
+	}
+	for _, budget := range d.budgets {
+		load, ok := budget.Loads[tag]
+		if !ok {
+			continue
+		}
+		var deadline time.Time
+		if budget.Policy == api.BudgetDefer {
+			deadline = time.Now().Add(budget.MaxDefer)
+		}
+		for {
+			draw, fits := d.fits(ctx, budget, tag, load)
+			if fits {
+				break
+			}
+			if budget.Policy != api.BudgetDefer || !time.Now().Before(deadline) {
+				return fmt.Errorf("%w: %q needs %.0fW on circuit %q, which is drawing %.0fW of %.0fW", ErrOverBudget, tag, load, budget.Circuit, draw, budget.Limit())
+			}
+			select {
+			case <-d.after(min(budgetPollInterval, time.Until(deadline))):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// storedReading returns the latest stored reading of the sensor tagged tag. Power is
+// measured from stored readings, as the driver's status of a switch is its output.
+func (d *Dispatcher) storedReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	sensor, err := d.store.GetSensorByTag(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	return d.store.GetLatestSensorReading(ctx, sensor.DeviceID, sensor.ID)
+}
+
+// fits reports whether the circuit has room for load more watts, along with its measured
+// draw. An actuator that is already on is part of the measured draw, so always fits. A
+// power sensor that can't be read is left out of the draw rather than blocking life
+// support equipment.
+func (d *Dispatcher) fits(ctx context.Context, budget api.PowerBudget, tag string, load float64) (float64, bool) {
+	if status, err := d.LatestReading(ctx, tag); err == nil && status.Value != 0 {
+		return 0, true
+	}
+	var draw float64
+	for _, powerTag := range budget.PowerTags {
+		r, err := d.storedReading(ctx, powerTag)
+		if err != nil || !r.Valid {
+			ll := logging.Component(*log.Ctx(ctx), "drivers")
+			ll.Warn().Err(err).Str("circuit", budget.Circuit).Str("tag", powerTag).Msg("unable to measure circuit draw")
+			continue
+		}
+		draw += r.Value
+	}
+	return draw, draw+load <= budget.Limit()
+}
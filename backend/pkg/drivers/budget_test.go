@@ -0,0 +1,109 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// switchDriver switches actuators in memory, reporting every actuator as off
+type switchDriver struct {
+	sent []string
+}
+
+func (d *switchDriver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error) {
+	return &api.DiscoveryResult{}, nil
+}
+
+func (d *switchDriver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource Statuser) (*api.SensorReading, error) {
+	return &api.SensorReading{Valid: true}, nil
+}
+
+func (d *switchDriver) SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	d.sent = append(d.sent, cmd.Action+":"+actuator.ID)
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+func newBudgetRig(t *testing.T, policy api.BudgetPolicy) (*Dispatcher, *switchDriver, storer.Interface) {
+	t.Helper()
+	ctx := context.Background()
+	store := storer.NewMemory()
+	dev := &api.Device{
+		ID:      "outlet",
+		Driver:  api.DriverShelly,
+		Name:    "GFCI outlet",
+		Sensors: []*api.Sensor{{ID: "power", Name: "Power", SensorType: api.SensorTypePower, Tags: []string{"outlet.power"}}},
+		Actuators: []*api.Actuator{
+			{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.heater"}},
+			{ID: "pump", Name: "Pump", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.pump"}},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	setDraw(t, store, 1500)
+
+	driver := &switchDriver{}
+	manager := NewManager()
+	manager.Register(api.DriverShelly, driver)
+	d := NewDispatcher(store, manager, WithPowerBudgets([]api.PowerBudget{{
+		Circuit:   "gfci",
+		MaxAmps:   15,
+		Volts:     120,
+		PowerTags: []string{"outlet.power"},
+		Loads:     map[string]float64{"tank.heater": 500, "tank.pump": 200},
+		Policy:    policy,
+		MaxDefer:  time.Minute,
+	}}))
+	return d, driver, store
+}
+
+func setDraw(t *testing.T, store storer.Interface, watts float64) {
+	t.Helper()
+	rec := &api.ReadingRecord{DeviceID: "outlet", SensorID: "power", Reading: api.SensorReading{Value: watts, Unit: api.UnitWatts, Timestamp: time.Now(), Valid: true}}
+	if err := store.StoreSensorReading(context.Background(), rec); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+}
+
+func TestDispatcher_PowerBudgetReject(t *testing.T) {
+	d, driver, _ := newBudgetRig(t, "")
+	ctx := context.Background()
+
+	if _, err := d.Command(ctx, "tank.heater", api.ActuatorCommand{Action: "on"}); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("Expected ErrOverBudget switching on the heater at 1500W of 1800W, got %v", err)
+	}
+	if _, err := d.Command(ctx, "tank.pump", api.ActuatorCommand{Action: "on"}); err != nil {
+		t.Errorf("Expected the pump to fit, got %v", err)
+	}
+	if _, err := d.Command(ctx, "tank.heater", api.ActuatorCommand{Action: "off"}); err != nil {
+		t.Errorf("Expected switching off to be allowed, got %v", err)
+	}
+	if want := []string{"on:pump", "off:heater"}; len(driver.sent) != 2 || driver.sent[0] != want[0] || driver.sent[1] != want[1] {
+		t.Errorf("Expected commands %v to be sent, got %v", want, driver.sent)
+	}
+}
+
+func TestDispatcher_PowerBudgetDefer(t *testing.T) {
+	d, driver, store := newBudgetRig(t, api.BudgetDefer)
+	waits := 0
+	d.after = func(time.Duration) <-chan time.Time {
+		// Something else switches off while the heater waits
+		waits++
+		setDraw(t, store, 1000)
+		ch := make(chan time.Time, 1)
+		ch <- time.Now()
+		return ch
+	}
+
+	if _, err := d.Command(context.Background(), "tank.heater", api.ActuatorCommand{Action: "on"}); err != nil {
+		t.Fatalf("Expected the deferred command to be sent once there was room, got %v", err)
+	}
+	if waits != 1 || len(driver.sent) != 1 || driver.sent[0] != "on:heater" {
+		t.Errorf("Expected the heater switched on after one wait, got %d waits and %v", waits, driver.sent)
+	}
+}
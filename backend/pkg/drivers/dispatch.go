@@ -0,0 +1,179 @@
+package drivers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/latency"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/storer"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Dispatcher resolves sensors and actuators by tag and routes requests to the driver
+// owning their device
+type Dispatcher struct {
+	store   storer.Interface
+	manager *Manager
+	// commandLatency times commands from request to the driver confirming them
+	commandLatency *latency.Histogram
+	budgets        []api.PowerBudget
+	// after is swapped out by tests to avoid real sleeps
+	after func(time.Duration) <-chan time.Time
+}
+
+type DispatcherOption func(*Dispatcher)
+
+// WithCommandLatency observes into h how long each command takes from being requested
+// to the device confirming the actuator's state, counting commands the driver fails as
+// failures
+func WithCommandLatency(h *latency.Histogram) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.commandLatency = h
+	}
+}
+
+func NewDispatcher(store storer.Interface, manager *Manager, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		store:   store,
+		manager: manager,
+		after:   time.After,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Command sends cmd to the actuator tagged tag and records it in the device's command
+// history and the audit log, attributed to the origin attached to ctx by
+// api.WithCommandOrigin. The returned state carries the origin too. A command that
+// would take a circuit over its power budget is refused with ErrOverBudget, or held
+// until there is room under a deferring budget, without being sent or recorded.
+func (d *Dispatcher) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	actuator, err := d.store.GetActuatorByTag(ctx, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve actuator %q: %w", tag, err)
+	}
+	if err := d.awaitBudgets(ctx, tag, cmd); err != nil {
+		return nil, err
+	}
+
+	origin := api.CommandOriginFrom(ctx)
+	start := time.Now()
+	state, err := d.setActuator(ctx, actuator, cmd)
+	took := time.Since(start)
+	if err != nil {
+		d.commandLatency.Fail()
+	} else {
+		d.commandLatency.Observe(took)
+	}
+	if state != nil {
+		state.Origin = &origin
+	}
+	rec := &api.CommandRecord{
+		DeviceID:   actuator.DeviceID,
+		ActuatorID: actuator.ID,
+		Tag:        tag,
+		Origin:     origin,
+		Command:    cmd,
+		State:      state,
+		IssuedAt:   start,
+		LatencyMS:  took.Milliseconds(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	// The command has already happened; failing to record it must not fail the caller
+	if recErr := d.store.RecordCommand(ctx, rec); recErr != nil {
+		ll := logging.Component(*log.Ctx(ctx), "drivers")
+		ll.Warn().Err(recErr).Str("component", "drivers").Str("tag", tag).Msg("unable to record command history")
+	}
+	return state, err
+}
+
+func (d *Dispatcher) setActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	driver, err := d.driverFor(ctx, actuator.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	return driver.SetActuator(ctx, actuator, cmd)
+}
+
+// LatestReading returns the most recent status of the sensor, or failing that the
+// actuator, tagged tag
+func (d *Dispatcher) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	var resource Statuser
+	sensor, err := d.store.GetSensorByTag(ctx, tag)
+	switch {
+	case err == nil:
+		resource = sensor
+	case errors.Is(err, storer.ErrNotFound):
+		actuator, err := d.store.GetActuatorByTag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		resource = actuator
+	default:
+		return nil, err
+	}
+
+	driver, err := d.driverFor(ctx, resource.GetDeviceID())
+	if err != nil {
+		return nil, err
+	}
+	return driver.GetLastStatus(ctx, api.StatusOptions{}, resource)
+}
+
+// TagsFor returns the tags of the sensor or actuator resourceID on deviceID, along with
+// any aliases pointing at them so reactions configured against an alias still match
+func (d *Dispatcher) TagsFor(ctx context.Context, deviceID, resourceID string) ([]string, error) {
+	var tags []string
+	sensor, err := d.store.GetSensor(ctx, deviceID, resourceID)
+	switch {
+	case err == nil:
+		tags = append(tags, sensor.Tags...)
+	case !errors.Is(err, storer.ErrNotFound):
+		return nil, err
+	}
+	actuator, err := d.store.GetActuator(ctx, deviceID, resourceID)
+	switch {
+	case err == nil:
+		tags = append(tags, actuator.Tags...)
+	case !errors.Is(err, storer.ErrNotFound):
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	aliases, err := d.store.ListTagAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		for _, tag := range tags {
+			if alias.Target == tag {
+				tags = append(tags, alias.Alias)
+				break
+			}
+		}
+	}
+	return tags, nil
+}
+
+func (d *Dispatcher) driverFor(ctx context.Context, deviceID string) (Driver, error) {
+	device, err := d.store.GetDevice(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device %q: %w", deviceID, err)
+	}
+	driver, exists := d.manager.Get(device.Driver)
+	if !exists {
+		return nil, fmt.Errorf("driver not found: %s", device.Driver)
+	}
+	return driver, nil
+}
@@ -0,0 +1,214 @@
+// Package driverstest holds the conformance suite every drivers.Driver implementation
+// must pass, so drivers agree on discovery, command and status semantics.
+//
+// A driver's own tests provide a Harness backed by fake devices and call Run:
+//
+//	func TestConformance(t *testing.T) {
+//		driverstest.Run(t, &harness{})
+//	}
+package driverstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Harness connects the suite to a driver under test and its fake devices
+type Harness interface {
+	// Setup returns a started driver able to discover and command fake devices with
+	// the given IDs. Each device must expose at least one actuator accepting "on",
+	// "off" and "toggle". Cleanup is registered on t.
+	Setup(t *testing.T, deviceIDs ...string) drivers.Driver
+	// Output reports whether an actuator is physically on, as seen by the fake device
+	Output(t *testing.T, deviceID, actuatorID string) bool
+}
+
+// unreachableTimeout bounds commands sent to devices that will never answer
+const unreachableTimeout = 500 * time.Millisecond
+
+// Run executes the conformance suite as subtests of t
+func Run(t *testing.T, h Harness) {
+	t.Run("Discovery", func(t *testing.T) { testDiscovery(t, h) })
+	t.Run("DiscoveryIdempotent", func(t *testing.T) { testDiscoveryIdempotent(t, h) })
+	t.Run("CommandIdempotent", func(t *testing.T) { testCommandIdempotent(t, h) })
+	t.Run("Toggle", func(t *testing.T) { testToggle(t, h) })
+	t.Run("UnsupportedAction", func(t *testing.T) { testUnsupportedAction(t, h) })
+	t.Run("UnknownDevice", func(t *testing.T) { testUnknownDevice(t, h) })
+	t.Run("StatusMapping", func(t *testing.T) { testStatusMapping(t, h) })
+}
+
+// discover runs discovery into store and returns the result and the first
+// discovered actuator
+func discover(t *testing.T, d drivers.Driver, store storer.Interface) (*api.DiscoveryResult, *api.Actuator) {
+	t.Helper()
+	result, err := d.DiscoverDevices(context.Background(), api.DiscoveryOptions{}, store)
+	if err != nil {
+		t.Fatalf("DiscoverDevices() error = %v", err)
+	}
+	actuators, err := store.ListActuators(context.Background())
+	if err != nil {
+		t.Fatalf("ListActuators() error = %v", err)
+	}
+	if len(actuators) == 0 {
+		return result, nil
+	}
+	return result, actuators[0]
+}
+
+func mustActuator(t *testing.T, h Harness) (drivers.Driver, *api.Actuator) {
+	t.Helper()
+	d := h.Setup(t, "conformance-a")
+	_, actuator := discover(t, d, storer.NewMemory())
+	if actuator == nil {
+		t.Fatal("Expected discovery to find an actuator")
+	}
+	return d, actuator
+}
+
+func testDiscovery(t *testing.T, h Harness) {
+	d := h.Setup(t, "conformance-a", "conformance-b")
+	store := storer.NewMemory()
+	result, _ := discover(t, d, store)
+	ctx := context.Background()
+
+	if len(result.DiscoveredTags) != 2 {
+		t.Fatalf("Expected 2 discovered tags, got %v", result.DiscoveredTags)
+	}
+	for _, tag := range result.DiscoveredTags {
+		if _, err := store.GetDeviceByTag(ctx, tag); err != nil {
+			t.Errorf("Expected discovered tag %s to resolve to a stored device: %v", tag, err)
+		}
+	}
+	for _, id := range []string{"conformance-a", "conformance-b"} {
+		dev, err := store.GetDevice(ctx, id)
+		if err != nil {
+			t.Errorf("Expected device %s to be stored: %v", id, err)
+			continue
+		}
+		if dev.Driver == "" {
+			t.Errorf("Expected device %s to record its driver", id)
+		}
+		if len(dev.Actuators) == 0 {
+			t.Errorf("Expected device %s to have actuators", id)
+		}
+		for _, a := range dev.Actuators {
+			if a.DeviceID != id {
+				t.Errorf("Expected actuator %s to belong to %s, got %s", a.ID, id, a.DeviceID)
+			}
+		}
+	}
+}
+
+func testDiscoveryIdempotent(t *testing.T, h Harness) {
+	d := h.Setup(t, "conformance-a")
+	store := storer.NewMemory()
+	discover(t, d, store)
+	before, _ := store.ListActuators(context.Background())
+
+	result, _ := discover(t, d, store)
+	if len(result.DiscoveredTags) != 0 {
+		t.Errorf("Expected rediscovery to report no new devices, got %v", result.DiscoveredTags)
+	}
+	after, _ := store.ListActuators(context.Background())
+	if len(after) != len(before) {
+		t.Errorf("Expected rediscovery to leave %d actuators, got %d", len(before), len(after))
+	}
+}
+
+func testCommandIdempotent(t *testing.T, h Harness) {
+	d, actuator := mustActuator(t, h)
+	ctx := context.Background()
+
+	for _, action := range []string{"on", "on", "off", "off"} {
+		state, err := d.SetActuator(ctx, actuator, api.ActuatorCommand{Action: action})
+		if err != nil {
+			t.Fatalf("SetActuator(%s) error = %v", action, err)
+		}
+		want := action == "on"
+		if state.Active != want {
+			t.Errorf("Expected %s to report active=%v, got %v", action, want, state.Active)
+		}
+		if got := h.Output(t, actuator.DeviceID, actuator.ID); got != want {
+			t.Errorf("Expected device output %v after %s, got %v", want, action, got)
+		}
+	}
+}
+
+func testToggle(t *testing.T, h Harness) {
+	d, actuator := mustActuator(t, h)
+	ctx := context.Background()
+
+	if _, err := d.SetActuator(ctx, actuator, api.ActuatorCommand{Action: "off"}); err != nil {
+		t.Fatalf("SetActuator(off) error = %v", err)
+	}
+	for _, want := range []bool{true, false} {
+		state, err := d.SetActuator(ctx, actuator, api.ActuatorCommand{Action: "toggle"})
+		if err != nil {
+			t.Fatalf("SetActuator(toggle) error = %v", err)
+		}
+		if state.Active != want || h.Output(t, actuator.DeviceID, actuator.ID) != want {
+			t.Errorf("Expected toggle to leave actuator active=%v, got reported %v", want, state.Active)
+		}
+	}
+}
+
+func testUnsupportedAction(t *testing.T, h Harness) {
+	d, actuator := mustActuator(t, h)
+	ctx := context.Background()
+
+	if _, err := d.SetActuator(ctx, actuator, api.ActuatorCommand{Action: "launch"}); err == nil {
+		t.Error("Expected error for unsupported action")
+	}
+	if h.Output(t, actuator.DeviceID, actuator.ID) {
+		t.Error("Expected unsupported action to leave actuator off")
+	}
+}
+
+func testUnknownDevice(t *testing.T, h Harness) {
+	d, actuator := mustActuator(t, h)
+	ctx, cancel := context.WithTimeout(context.Background(), unreachableTimeout)
+	defer cancel()
+
+	missing := *actuator
+	missing.DeviceID = "conformance-missing"
+	if _, err := d.SetActuator(ctx, &missing, api.ActuatorCommand{Action: "on"}); err == nil {
+		t.Error("Expected error commanding an unknown device")
+	}
+}
+
+func testStatusMapping(t *testing.T, h Harness) {
+	d, actuator := mustActuator(t, h)
+	ctx := context.Background()
+
+	if _, err := d.SetActuator(ctx, actuator, api.ActuatorCommand{Action: "on"}); err != nil {
+		t.Fatalf("SetActuator(on) error = %v", err)
+	}
+
+	// Drivers without a status history must say so with ErrNoData; those with one must
+	// report the commanded state as a valid 1/0 reading.
+	var reading *api.SensorReading
+	var err error
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		reading, err = d.GetLastStatus(ctx, api.StatusOptions{}, actuator)
+		if err != nil || reading.Value == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		if !errors.Is(err, drivers.ErrNoData) {
+			t.Errorf("Expected status error to wrap ErrNoData, got %v", err)
+		}
+		return
+	}
+	if !reading.Valid || reading.Value != 1 {
+		t.Errorf("Expected valid reading of 1 for an active actuator, got %+v", reading)
+	}
+}
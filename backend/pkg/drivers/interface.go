@@ -9,13 +9,39 @@ import (
 
 var ErrNoData = errors.New("no data available")
 
+// ErrRejected reports that a device understood a request and refused it; retrying the
+// same request will not help
+var ErrRejected = errors.New("rejected by device")
+
+// Driver failures. Drivers wrap these so callers can tell a device that is unreachable
+// from one that refused a bad request, and decide whether to retry, alert or blame the
+// caller.
+var (
+	// ErrDeviceOffline reports that the device is known to be disconnected
+	ErrDeviceOffline = errors.New("device offline")
+	// ErrTimeout reports that the device did not answer in time; it may be busy or gone
+	ErrTimeout = errors.New("device timed out")
+	// ErrAuth reports that the device refused our credentials
+	ErrAuth = errors.New("device authentication failed")
+	// ErrUnsupported reports a resource or action the driver or device cannot handle
+	ErrUnsupported = errors.New("unsupported by device")
+	// ErrOverBudget reports a command refused because it would take its circuit over
+	// its power budget; it was never sent to the device
+	ErrOverBudget = errors.New("power budget exceeded")
+)
+
+// EventHandler receives resource events as soon as a driver observes them. It is called
+// from the driver's ingestion goroutine, so it must not block for long.
+type EventHandler func(ctx context.Context, ev *api.ResourceEvent)
+
 type Statuser interface {
 	GetID() string
 	GetDeviceID() string
 }
 
 type Driver interface {
-	DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s *storer.Storer) (*api.DiscoveryResult, error)
+	DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error)
 	GetLastStatus(ctx context.Context, opt api.StatusOptions, resource Statuser) (*api.SensorReading, error)
+	SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error)
 }
 
@@ -0,0 +1,30 @@
+package drivers
+
+import "strings"
+
+// NamespaceSeparator joins a driver's ID namespace to a device's native ID
+const NamespaceSeparator = ":"
+
+// NamespacedID returns the stored ID of a device whose driver calls it nativeID. Drivers
+// configured with a namespace, such as "shelly", store devices as "shelly:<native ID>",
+// so two drivers discovering devices with the same native ID cannot collide. An empty
+// namespace keeps the native ID.
+func NamespacedID(namespace, nativeID string) string {
+	if namespace == "" {
+		return nativeID
+	}
+	return namespace + NamespaceSeparator + nativeID
+}
+
+// NativeID reverses NamespacedID, returning the ID the device itself answers to. IDs
+// outside the namespace, such as devices stored before it was configured, are returned
+// unchanged.
+func NativeID(namespace, id string) string {
+	if namespace == "" {
+		return id
+	}
+	if native, ok := strings.CutPrefix(id, namespace+NamespaceSeparator); ok {
+		return native
+	}
+	return id
+}
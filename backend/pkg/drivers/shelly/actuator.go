@@ -0,0 +1,64 @@
+package shelly
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+
+	"github.com/jcodybaker/go-shelly"
+)
+
+const defaultCommandTimeout = 5 * time.Second
+
+func (d *Driver) SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	if d.mqttClient == nil {
+		return nil, fmt.Errorf("shelly driver has no MQTT client configured: %w", drivers.ErrDeviceOffline)
+	}
+	ll := d.logCtx(ctx, "actuator").With().
+		Str("device_id", actuator.DeviceID).
+		Str("actuator_id", actuator.ID).
+		Str("action", cmd.Action).
+		Logger()
+
+	component, idStr, ok := strings.Cut(actuator.ID, ":")
+	if !ok || component != "switch" {
+		return nil, fmt.Errorf("shelly actuator %q: %w", actuator.ID, drivers.ErrUnsupported)
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid shelly switch id %q: %w", actuator.ID, err)
+	}
+
+	var method string
+	var params any
+	switch cmd.Action {
+	case "on", "off":
+		method = "Switch.Set"
+		params = &shelly.SwitchSetRequest{ID: id, On: cmd.Action == "on"}
+	case "toggle":
+		method = "Switch.Toggle"
+		params = &shelly.SwitchToggleRequest{ID: id}
+	default:
+		return nil, fmt.Errorf("action %q for shelly switch: %w", cmd.Action, drivers.ErrUnsupported)
+	}
+
+	ll.Debug().Msg("sending actuator command")
+	resp := &shelly.SwitchActionResponse{}
+	if err := d.roundTrip(ctx, d.nativeID(actuator.DeviceID), method, params, resp, defaultCommandTimeout); err != nil {
+		return nil, fmt.Errorf("sending %s to %s: %w", method, actuator.DeviceID, err)
+	}
+
+	active := cmd.Action == "on"
+	if cmd.Action == "toggle" {
+		active = !resp.WasOn
+	}
+	return &api.ActuatorState{
+		Active:    active,
+		Timestamp: time.Now(),
+	}, nil
+}
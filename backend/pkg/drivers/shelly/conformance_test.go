@@ -0,0 +1,47 @@
+package shelly
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/drivers/driverstest"
+	mqtttest "lifesupport/backend/pkg/testutil/mqtt"
+)
+
+// conformanceHarness runs the shelly driver against fake devices on an embedded broker
+type conformanceHarness struct {
+	mu      sync.Mutex
+	devices map[string]*mqtttest.Shelly
+}
+
+func (h *conformanceHarness) Setup(t *testing.T, deviceIDs ...string) drivers.Driver {
+	b := mqtttest.NewBroker(t)
+	h.mu.Lock()
+	h.devices = make(map[string]*mqtttest.Shelly)
+	for _, id := range deviceIDs {
+		h.devices[id] = mqtttest.NewShelly(b, id, 2)
+	}
+	h.mu.Unlock()
+	return startHarnessDriver(t, b)
+}
+
+func (h *conformanceHarness) Output(t *testing.T, deviceID, actuatorID string) bool {
+	h.mu.Lock()
+	dev, ok := h.devices[deviceID]
+	h.mu.Unlock()
+	if !ok {
+		t.Fatalf("Unknown fake device %s", deviceID)
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(actuatorID, "switch:"))
+	if err != nil {
+		t.Fatalf("Unexpected actuator %s", actuatorID)
+	}
+	return dev.Output(id)
+}
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, &conformanceHarness{})
+}
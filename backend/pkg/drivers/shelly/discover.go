@@ -7,24 +7,56 @@ import (
 	"fmt"
 	"lifesupport/backend/pkg/api"
 	"lifesupport/backend/pkg/storer"
+	"slices"
+	"sort"
 	"sync"
-	"sync/atomic"
-	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/jcodybaker/go-shelly"
+	"golang.org/x/sync/errgroup"
 )
 
-func (d *Driver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s *storer.Storer) (*api.DiscoveryResult, error) {
-	result := &api.DiscoveryResult{}
-	var resultMutex sync.Mutex
-	stopSearch := new(atomic.Bool)
+// DiscoverDevices asks every Shelly on the broker to announce itself, and stores each
+// device that answers within the discovery timeout. Announcements queue for a fixed pool
+// of workers; when the queue is full, the MQTT callback waits for a worker rather than
+// dropping the device. Each device gets its own time budget to report its config and be
+// stored. Devices that fail, or answer after the window has closed, are reported in the
+// result's errors rather than failing the whole discovery.
+func (d *Driver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error) {
 	ll := d.logCtx(ctx, "discovery")
+	results := &discoveryResults{seen: make(map[string]bool)}
 
-	searchBuf := make(chan *shelly.ShellyGetDeviceInfoResponse, d.discoveryBufferSize)
-	var wg sync.WaitGroup
+	listenCtx, stopListening := context.WithTimeout(ctx, d.discoveryTimeout)
+	defer stopListening()
 
-	d.mqttClient.Subscribe("shellies/announce", 1, func(_ mqtt.Client, m mqtt.Message) {
+	// queueLock guards closing the queue against callbacks still delivering to it
+	var queueLock sync.RWMutex
+	queueClosed := false
+	queue := make(chan *shelly.ShellyGetDeviceInfoResponse, d.discoveryBufferSize)
+
+	g, gctx := errgroup.WithContext(ctx)
+	for range d.discoveryWorkers {
+		g.Go(func() error {
+			for deviceInfo := range queue {
+				d.discoverDevice(gctx, deviceInfo, s, results)
+			}
+			return nil
+		})
+	}
+	closeQueue := func() {
+		queueLock.Lock()
+		defer queueLock.Unlock()
+		if !queueClosed {
+			queueClosed = true
+			close(queue)
+		}
+	}
+	defer func() {
+		closeQueue()
+		g.Wait()
+	}()
+
+	token := d.mqttClient.Subscribe("shellies/announce", 1, func(_ mqtt.Client, m mqtt.Message) {
 		var deviceInfo shelly.ShellyGetDeviceInfoResponse
 		if err := json.Unmarshal(m.Payload(), &deviceInfo); err != nil {
 			ll.Err(err).
@@ -33,102 +65,145 @@ func (d *Driver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions,
 				Msg("parsing MQTT message as device info")
 			return
 		}
-		if stopSearch.Load() {
-			ll.Warn().
-				Uint16("message_id", m.MessageID()).
-				Str("topic", m.Topic()).
-				Str("device_id", deviceInfo.ID).
-				Msg("discarding late MQTT search response")
+		ll := ll.With().Str("device_id", deviceInfo.ID).Logger()
+		if !results.first(deviceInfo.ID) {
+			ll.Debug().Msg("ignoring repeated MQTT search response")
 			return
 		}
-		ll.Debug().
-			Uint16("message_id", m.MessageID()).
-			Str("topic", m.Topic()).
-			Str("device_id", deviceInfo.ID).
-			Msg("got MQTT search response")
-		searchBuf <- &deviceInfo
-	})
 
-	wg.Add(1)
-	workerLimiter := make(chan struct{}, d.discoveryWorkers)
-	go func() {
-		defer wg.Done()
-		for deviceInfo := range searchBuf {
-			wg.Add(1)
-			// Occupy a space in the workerLimiter buffer or block until one is available.
-			workerLimiter <- struct{}{}
-			go func(deviceInfo *shelly.ShellyGetDeviceInfoResponse) {
-				defer wg.Done()
-				defer func() { <-workerLimiter }()
-				ll := ll.With().Str("device_id", deviceInfo.ID).Logger()
-				ll.Debug().Msg("Processing discovered device")
-				shellyConfig := &shelly.ShellyGetConfigResponse{}
-				if err := d.roundTrip(ctx, deviceInfo.ID, "Shelly.GetConfig", nil, shellyConfig, time.Second*5); err != nil {
-					ll.Err(err).
-						Str("device_id", deviceInfo.ID).
-						Msg("querying shelly for full device config")
-					return
-				}
-				ll.Debug().
-					Int("switch_count", len(shellyConfig.Switches)).
-					Int("input_count", len(shellyConfig.Inputs)).
-					Msg("Successfully retrieved device config, converting to internal model and storing")
-
-				dev := d.deviceInfoToDevice(deviceInfo, shellyConfig)
-				if err := s.CreateDevice(ctx, dev); err != nil {
-					if errors.Is(err, storer.ErrAlreadyExists) {
-						ll.Debug().
-							Err(err).
-							Str("device_id", deviceInfo.ID).
-							Msg("device already exists in store")
-						return
-					}
-					ll.Err(err).
-						Str("device_id", deviceInfo.ID).
-						Msg("storing discovered device")
-					return
-				}
-				resultMutex.Lock()
-				result.DiscoveredTags = append(result.DiscoveredTags, dev.DefaultTag())
-				resultMutex.Unlock()
-				ll.Info().
-					Str("device_id", deviceInfo.ID).
-					Msg("discovered new device")
-			}(deviceInfo)
+		queueLock.RLock()
+		defer queueLock.RUnlock()
+		if queueClosed {
+			ll.Warn().Msg("device responded after the discovery window closed")
+			results.fail(d.deviceID(deviceInfo.ID), errDiscoveryLate)
+			return
 		}
-	}()
+		ll.Debug().Msg("got MQTT search response")
+		select {
+		case queue <- &deviceInfo:
+		case <-listenCtx.Done():
+			ll.Warn().Msg("device responded after the discovery window closed")
+			results.fail(d.deviceID(deviceInfo.ID), errDiscoveryLate)
+		}
+	})
+	if err := waitToken(ctx, token); err != nil {
+		return nil, fmt.Errorf("subscribing to mqtt search responses: %w", err)
+	}
 
 	// Ok, we're ready for responses; make our request.
-	token := d.mqttClient.Publish("shellies/command", 1, false, []byte("announce"))
-	token.Wait()
-	if err := token.Error(); err != nil {
+	token = d.mqttClient.Publish("shellies/command", 1, false, []byte("announce"))
+	if err := waitToken(ctx, token); err != nil {
 		return nil, fmt.Errorf("publishing search message to mqtt: %w", err)
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(d.discoveryTimeout):
+	<-listenCtx.Done()
+	closeQueue()
+	token = d.mqttClient.Unsubscribe("shellies/announce")
+	unsubscribeErr := waitToken(context.WithoutCancel(ctx), token)
+	g.Wait()
+
+	result := results.result()
+	if err := ctx.Err(); err != nil {
+		return result, err
 	}
+	if unsubscribeErr != nil {
+		return result, fmt.Errorf("unsubscribing from mqtt search message responses: %w", unsubscribeErr)
+	}
+	return result, nil
+}
 
-	// We can't guarantee that the mqtt has coallesed and processed all incoming messages. So it's difficult
-	// be certain we can close the channel. The atomic stopSearch makes this safer, but it's not a guarantee.
-	stopSearch.Store(true)
-	token = d.mqttClient.Unsubscribe("shellies/announce")
-	token.Wait()
-	if err := token.Error(); err != nil {
-		return nil, fmt.Errorf("unsubscribing from mqtt search message responses: %w", err)
+var errDiscoveryLate = errors.New("responded after the discovery window closed")
+
+// discoverDevice fetches an announced device's config and stores it, within the
+// per-device budget
+func (d *Driver) discoverDevice(ctx context.Context, deviceInfo *shelly.ShellyGetDeviceInfoResponse, s storer.Interface, results *discoveryResults) {
+	ctx, cancel := context.WithTimeout(ctx, d.discoveryDeviceTimeout)
+	defer cancel()
+	ll := d.logCtx(ctx, "discovery").With().Str("device_id", deviceInfo.ID).Logger()
+	ll.Debug().Msg("Processing discovered device")
+
+	shellyConfig := &shelly.ShellyGetConfigResponse{}
+	if err := d.roundTrip(ctx, deviceInfo.ID, "Shelly.GetConfig", nil, shellyConfig, d.discoveryDeviceTimeout); err != nil {
+		ll.Err(err).Msg("querying shelly for full device config")
+		results.fail(d.deviceID(deviceInfo.ID), fmt.Errorf("querying device config: %w", err))
+		return
 	}
-	close(searchBuf)
+	ll.Debug().
+		Int("switch_count", len(shellyConfig.Switches)).
+		Int("input_count", len(shellyConfig.Inputs)).
+		Msg("Successfully retrieved device config, converting to internal model and storing")
 
-	wg.Wait()
-	close(workerLimiter)
-	return result, nil
+	dev := d.deviceInfoToDevice(deviceInfo, shellyConfig)
+	if err := s.CreateDevice(ctx, dev); err != nil {
+		if errors.Is(err, storer.ErrAlreadyExists) {
+			ll.Debug().Err(err).Msg("device already exists in store")
+			return
+		}
+		ll.Err(err).Msg("storing discovered device")
+		results.fail(d.deviceID(deviceInfo.ID), fmt.Errorf("storing device: %w", err))
+		return
+	}
+	// The store gave the device its default tag first, under any tag template
+	results.discovered(dev.Tags[0])
+	ll.Info().Msg("discovered new device")
+}
+
+// discoveryResults collects the outcome of each device as workers finish with it
+type discoveryResults struct {
+	lock   sync.Mutex
+	seen   map[string]bool
+	tags   []string
+	errors []api.DiscoveryError
+}
+
+// first reports whether this is the first announcement from the device
+func (r *discoveryResults) first(deviceID string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.seen[deviceID] {
+		return false
+	}
+	r.seen[deviceID] = true
+	return true
+}
+
+func (r *discoveryResults) discovered(tag string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.tags = append(r.tags, tag)
+}
+
+func (r *discoveryResults) fail(deviceID string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errors = append(r.errors, api.DiscoveryError{DeviceID: deviceID, Error: err.Error()})
+}
+
+func (r *discoveryResults) result() *api.DiscoveryResult {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := &api.DiscoveryResult{
+		DiscoveredTags: slices.Clone(r.tags),
+		Errors:         slices.Clone(r.errors),
+	}
+	sort.Strings(result.DiscoveredTags)
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].DeviceID < result.Errors[j].DeviceID })
+	return result
+}
+
+// waitToken waits for an MQTT operation to complete or ctx to end
+func waitToken(ctx context.Context, t mqtt.Token) error {
+	select {
+	case <-t.Done():
+		return t.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (d *Driver) deviceInfoToDevice(info *shelly.ShellyGetDeviceInfoResponse, config *shelly.ShellyGetConfigResponse) *api.Device {
 	dev := &api.Device{
-		ID:          info.ID,
+		ID:          d.deviceID(info.ID),
 		Driver:      api.DriverShelly,
 		Name:        info.ID,
 		Description: fmt.Sprintf("Shelly %s %s", info.App, info.MAC),
@@ -147,7 +222,6 @@ func (d *Driver) deviceInfoToDevice(info *shelly.ShellyGetDeviceInfoResponse, co
 			Name:         name,
 		}
 
-		r.Tags = []string{r.DefaultTag(dev.ID)}
 		dev.Actuators = append(dev.Actuators, r)
 	}
 	return dev
@@ -8,6 +8,10 @@ import (
 	"sync"
 	"time"
 
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/supervise"
+
 	clickhouse "github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/rs/zerolog"
@@ -19,21 +23,35 @@ const (
 	defaultDiscoveryBufferSize = 10
 	defaultDiscoveryTimeout    = 10 * time.Second
 	defaultDiscoveryWorkers    = 5
+	// defaultDiscoveryDeviceTimeout is each discovered device's budget to report its
+	// config and be stored
+	defaultDiscoveryDeviceTimeout = 5 * time.Second
 )
 
+// AuthCredential names the device credential holding the admin password of a Shelly
+// with authentication enabled
+const AuthCredential = "auth"
+
+// Credentials reveals device secrets; secrets.Vault satisfies it
+type Credentials interface {
+	RevealNamed(ctx context.Context, deviceID, name string) ([]byte, error)
+}
+
 func New(mqttClient mqtt.Client, clickhouseConn clickhouse.Conn, opts ...Option) *Driver {
 	hostname, _ := os.Hostname()
 	nextID := rand.Uint64()
 	rt := &Driver{
-		mqttClient:          mqttClient,
-		clickhouseConn:      clickhouseConn,
-		nextID:              nextID,
-		clientName:          hostname,
-		baseName:            defaultBaseName,
-		discoveryBufferSize: defaultDiscoveryBufferSize,
-		discoveryTimeout:    defaultDiscoveryTimeout,
-		discoveryWorkers:    defaultDiscoveryWorkers,
-		router:              make(map[uint64]chan []byte),
+		mqttClient:             mqttClient,
+		clickhouseConn:         clickhouseConn,
+		nextID:                 nextID,
+		clientName:             hostname,
+		baseName:               defaultBaseName,
+		discoveryBufferSize:    defaultDiscoveryBufferSize,
+		discoveryTimeout:       defaultDiscoveryTimeout,
+		discoveryWorkers:       defaultDiscoveryWorkers,
+		discoveryDeviceTimeout: defaultDiscoveryDeviceTimeout,
+		router:                 make(map[uint64]chan []byte),
+		offline:                make(map[string]bool),
 	}
 	for _, opt := range opts {
 		opt(rt)
@@ -46,9 +64,10 @@ type Driver struct {
 	clickhouseConn clickhouse.Conn
 
 	// discovery
-	discoveryBufferSize int
-	discoveryTimeout    time.Duration
-	discoveryWorkers    int
+	discoveryBufferSize    int
+	discoveryTimeout       time.Duration
+	discoveryWorkers       int
+	discoveryDeviceTimeout time.Duration
 
 	// rtt
 	nextID     uint64
@@ -57,6 +76,20 @@ type Driver struct {
 	router     map[uint64]chan []byte
 	lock       sync.Mutex
 	log        zerolog.Logger
+
+	// idNamespace prefixes the IDs of stored devices; see drivers.NamespacedID
+	idNamespace string
+
+	// offline holds devices whose last connection state was offline
+	offline map[string]bool
+
+	// credentials reveals the passwords of devices with authentication enabled
+	credentials Credentials
+
+	// events
+	eventHandler drivers.EventHandler
+	readingStore ReadingStore
+	deferEvents  bool
 }
 
 func (r *Driver) Start(ctx context.Context) error {
@@ -66,7 +99,51 @@ func (r *Driver) Start(ctx context.Context) error {
 	ll := r.logCtx(ctx, "mqtt")
 	topic := r.buildTopic()
 	ll.Info().Str("topic", topic).Msg("Starting Shelly Driver: Subscribing to MQTT topic")
-	t := r.mqttClient.Subscribe(topic, 1, r.handleMessage)
+	t := r.mqttClient.Subscribe(topic, 1, supervise.MQTTHandler("shelly.status", r.handleMessage))
+	select {
+	case <-t.Done():
+		if err := t.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	ll.Info().Str("topic", onlineTopic).Msg("Subscribing to Shelly connection state")
+	t = r.mqttClient.Subscribe(onlineTopic, 1, supervise.MQTTHandler("shelly.online", r.handleOnline))
+	select {
+	case <-t.Done():
+		if err := t.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !r.subscribesEvents() || r.deferEvents {
+		return nil
+	}
+	return r.StartEvents(ctx)
+}
+
+// StartEvents subscribes to device event notifications. Start does so itself unless the
+// driver was built WithDeferredEvents.
+func (r *Driver) StartEvents(ctx context.Context) error {
+	ll := r.logCtx(ctx, "mqtt")
+	ll.Info().Str("topic", eventsTopic).Msg("Subscribing to Shelly event notifications")
+	t := r.mqttClient.Subscribe(eventsTopic, 0, supervise.MQTTHandler("shelly.events", r.handleEvent))
+	select {
+	case <-t.Done():
+		return t.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StopEvents unsubscribes from device event notifications
+func (r *Driver) StopEvents(ctx context.Context) error {
+	ll := r.logCtx(ctx, "mqtt")
+	ll.Info().Str("topic", eventsTopic).Msg("Unsubscribing from Shelly event notifications")
+	t := r.mqttClient.Unsubscribe(eventsTopic)
 	select {
 	case <-t.Done():
 		return t.Error()
@@ -79,7 +156,11 @@ func (r *Driver) Stop(ctx context.Context) error {
 	topic := r.buildTopic()
 	ll := r.logCtx(ctx, "mqtt")
 	ll.Info().Str("topic", topic).Msg("Stopping Shelly Driver: Unsubscribing from MQTT topic")
-	t := r.mqttClient.Unsubscribe(topic)
+	topics := []string{topic, onlineTopic}
+	if r.subscribesEvents() && !r.deferEvents {
+		topics = append(topics, eventsTopic)
+	}
+	t := r.mqttClient.Unsubscribe(topics...)
 	select {
 	case <-t.Done():
 		return t.Error()
@@ -88,6 +169,23 @@ func (r *Driver) Stop(ctx context.Context) error {
 	}
 }
 
+// PendingRequests returns how many RPCs are waiting on a reply in the router
+func (r *Driver) PendingRequests() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return len(r.router)
+}
+
+// deviceID returns the stored ID of the device shelly calls nativeID
+func (d *Driver) deviceID(nativeID string) string {
+	return drivers.NamespacedID(d.idNamespace, nativeID)
+}
+
+// nativeID returns the ID a stored device answers to over MQTT
+func (d *Driver) nativeID(deviceID string) string {
+	return drivers.NativeID(d.idNamespace, deviceID)
+}
+
 func (d *Driver) logCtx(ctx context.Context, sub string) zerolog.Logger {
 	var ll zerolog.Context
 	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
@@ -99,7 +197,7 @@ func (d *Driver) logCtx(ctx context.Context, sub string) zerolog.Logger {
 	if sub != "" {
 		ll = ll.Str("subcomponent", sub)
 	}
-	return ll.Logger()
+	return logging.Component(ll.Logger(), "shelly")
 }
 
 // func (d *Driver) MQTTConnect(ctx context.Context) error {
@@ -0,0 +1,208 @@
+package shelly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// eventsTopic matches the notifications every Shelly publishes under its topic prefix
+const eventsTopic = "+/events/rpc"
+
+// NotificationFrame is an unsolicited RPC notification published by a device
+type NotificationFrame struct {
+	Src    string                     `json:"src"`
+	Dst    string                     `json:"dst,omitempty"`
+	Method string                     `json:"method"`
+	Params map[string]json.RawMessage `json:"params"`
+}
+
+// eventFields maps component fields to the unit of the readings they produce. Fields of
+// nested objects are named by their path, e.g. "temperature.tC". Boolean fields are
+// reported as 1 or 0.
+var eventFields = map[string]api.Unit{
+	"output":         "",
+	"state":          "",
+	"apower":         api.UnitWatts,
+	"voltage":        api.UnitVolts,
+	"current":        api.UnitAmps,
+	"aenergy.total":  api.UnitWattHours,
+	"temperature.tC": api.UnitCelsius,
+	"tC":             api.UnitCelsius,
+	"rh":             api.UnitPercent,
+}
+
+// ReadingStore persists the readings carried by status notifications
+type ReadingStore interface {
+	ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Sensor, error)
+	StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error
+}
+
+// ReadingSensorID is the ID of the sensor receiving readings of a component field from
+// status notifications, e.g. "switch:0.apower"
+func ReadingSensorID(component, field string) string {
+	return component + "." + field
+}
+
+func (d *Driver) subscribesEvents() bool {
+	return d.eventHandler != nil || d.readingStore != nil
+}
+
+func (d *Driver) handleEvent(_ mqtt.Client, m mqtt.Message) {
+	ctx := d.log.WithContext(context.Background())
+	ll := d.logCtx(ctx, "events")
+
+	events, err := parseNotification(m.Payload())
+	if err != nil {
+		ll.Debug().Err(err).Str("topic", m.Topic()).Msg("ignoring malformed notification")
+		return
+	}
+	for i := range events {
+		events[i].DeviceID = d.deviceID(events[i].DeviceID)
+	}
+	if d.eventHandler != nil {
+		for i := range events {
+			d.eventHandler(ctx, &events[i])
+		}
+	}
+	if d.readingStore != nil && len(events) > 0 {
+		if err := d.storeReadings(ctx, events); err != nil {
+			ll.Error().Err(err).Str("device_id", events[0].DeviceID).Msg("failed to store notification readings")
+		}
+	}
+}
+
+// storeReadings writes the events of one notification which have a matching sensor as a
+// single batch, rather than one write per sensor
+func (d *Driver) storeReadings(ctx context.Context, events []api.ResourceEvent) error {
+	deviceID := events[0].DeviceID
+	sensors, err := d.readingStore.ListSensorsByDeviceID(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to list sensors of %s: %w", deviceID, err)
+	}
+	known := make(map[string]bool, len(sensors))
+	for _, sensor := range sensors {
+		known[sensor.ID] = true
+	}
+
+	var recs []*api.ReadingRecord
+	for _, ev := range events {
+		sensorID := ReadingSensorID(ev.ResourceID, ev.Field)
+		if !known[sensorID] {
+			continue
+		}
+		recs = append(recs, &api.ReadingRecord{DeviceID: deviceID, SensorID: sensorID, Reading: ev.Reading})
+	}
+	if len(recs) == 0 {
+		return nil
+	}
+	if err := d.readingStore.StoreSensorReadings(ctx, recs); err != nil {
+		return fmt.Errorf("failed to store readings of %s: %w", deviceID, err)
+	}
+	return nil
+}
+
+// parseNotification converts a NotifyStatus or NotifyFullStatus frame into one resource
+// event per recognised component field. Other notifications produce no events.
+func parseNotification(payload []byte) ([]api.ResourceEvent, error) {
+	var frame NotificationFrame
+	if err := json.Unmarshal(payload, &frame); err != nil {
+		return nil, err
+	}
+	if frame.Method != "NotifyStatus" && frame.Method != "NotifyFullStatus" {
+		return nil, nil
+	}
+	if frame.Src == "" {
+		return nil, fmt.Errorf("notification has no src")
+	}
+
+	ts := time.Now()
+	if raw, ok := frame.Params["ts"]; ok {
+		var secs float64
+		if err := json.Unmarshal(raw, &secs); err == nil && secs > 0 {
+			whole, frac := math.Modf(secs)
+			ts = time.Unix(int64(whole), int64(frac*1e9))
+		}
+	}
+
+	// Sort components so events are emitted in a stable order.
+	components := make([]string, 0, len(frame.Params))
+	for key := range frame.Params {
+		if strings.Contains(key, ":") {
+			components = append(components, key)
+		}
+	}
+	sort.Strings(components)
+
+	var events []api.ResourceEvent
+	for _, component := range components {
+		var raw map[string]any
+		if err := json.Unmarshal(frame.Params[component], &raw); err != nil {
+			continue
+		}
+		fields := flattenFields(raw)
+		for _, field := range sortedKeys(fields) {
+			unit, ok := eventFields[field]
+			if !ok {
+				continue
+			}
+			var value float64
+			switch v := fields[field].(type) {
+			case bool:
+				if v {
+					value = 1
+				}
+			case float64:
+				value = v
+			default:
+				continue
+			}
+			events = append(events, api.ResourceEvent{
+				DeviceID:   frame.Src,
+				ResourceID: component,
+				Field:      field,
+				Reading: api.SensorReading{
+					Value:     value,
+					Unit:      unit,
+					Timestamp: ts,
+					Valid:     true,
+				},
+			})
+		}
+	}
+	return events, nil
+}
+
+// flattenFields lifts the fields of nested objects, such as a switch's "temperature" and
+// "aenergy", to the top level under dotted names
+func flattenFields(fields map[string]any) map[string]any {
+	flat := make(map[string]any, len(fields))
+	for key, value := range fields {
+		nested, ok := value.(map[string]any)
+		if !ok {
+			flat[key] = value
+			continue
+		}
+		for sub, v := range nested {
+			flat[key+"."+sub] = v
+		}
+	}
+	return flat
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
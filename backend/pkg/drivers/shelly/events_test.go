@@ -0,0 +1,65 @@
+package shelly
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNotification(t *testing.T) {
+	payload := []byte(`{
+		"src": "shellyplus1pm-a8032ab12345",
+		"dst": "shellyplus1pm-a8032ab12345/events",
+		"method": "NotifyStatus",
+		"params": {
+			"ts": 1700000000.5,
+			"switch:0": {"id": 0, "output": true, "apower": 42.5},
+			"input:0": {"id": 0, "state": false}
+		}
+	}`)
+
+	events, err := parseNotification(payload)
+	if err != nil {
+		t.Fatalf("parseNotification() error = %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	want := []struct {
+		resource, field string
+		value           float64
+	}{
+		{"input:0", "state", 0},
+		{"switch:0", "apower", 42.5},
+		{"switch:0", "output", 1},
+	}
+	for i, w := range want {
+		ev := events[i]
+		if ev.DeviceID != "shellyplus1pm-a8032ab12345" || ev.ResourceID != w.resource || ev.Field != w.field || ev.Reading.Value != w.value {
+			t.Errorf("event %d: expected %s %s=%v, got %+v", i, w.resource, w.field, w.value, ev)
+		}
+	}
+	if want := time.Unix(1700000000, 5e8); !events[0].Reading.Timestamp.Equal(want) {
+		t.Errorf("Expected timestamp %v, got %v", want, events[0].Reading.Timestamp)
+	}
+}
+
+func TestParseNotification_IgnoresOtherMethods(t *testing.T) {
+	events, err := parseNotification([]byte(`{"src":"dev","method":"NotifyEvent","params":{"events":[]}}`))
+	if err != nil {
+		t.Fatalf("parseNotification() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events, got %+v", events)
+	}
+}
+
+func BenchmarkParseNotification(b *testing.B) {
+	payload := []byte(`{"src":"shellyplus1pm-a8032ab12345","method":"NotifyStatus","params":{"ts":1700000000.5,` +
+		`"switch:0":{"id":0,"output":true,"apower":42.5,"voltage":230.1,"current":0.2,"aenergy":{"total":1234.5}}}}`)
+	for b.Loop() {
+		if _, err := parseNotification(payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
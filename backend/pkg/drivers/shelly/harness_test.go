@@ -0,0 +1,350 @@
+package shelly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+	mqtttest "lifesupport/backend/pkg/testutil/mqtt"
+)
+
+func startHarnessDriver(t *testing.T, b *mqtttest.Broker, opts ...Option) *Driver {
+	t.Helper()
+	opts = append([]Option{WithClientName("test"), WithDiscoveryTimeout(200 * time.Millisecond)}, opts...)
+	d := New(b.Client(t, "driver"), nil, opts...)
+	ctx := context.Background()
+	if err := d.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { d.Stop(ctx) })
+	return d
+}
+
+func TestHarness_DiscoverDevices(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+	mqtttest.NewShelly(b, "shellypro4pm-b", 4)
+	offline := mqtttest.NewShelly(b, "shellyplus1pm-c", 1)
+	offline.Silence(true)
+
+	d := startHarnessDriver(t, b)
+	store := storer.NewMemory()
+	result, err := d.DiscoverDevices(context.Background(), api.DiscoveryOptions{}, store)
+	if err != nil {
+		t.Fatalf("DiscoverDevices() error = %v", err)
+	}
+	if len(result.DiscoveredTags) != 2 {
+		t.Errorf("Expected 2 discovered devices, got %v", result.DiscoveredTags)
+	}
+
+	dev, err := store.GetDevice(context.Background(), "shellypro4pm-b")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if len(dev.Actuators) != 4 {
+		t.Errorf("Expected 4 switch actuators, got %d", len(dev.Actuators))
+	}
+	if _, err := store.GetDevice(context.Background(), "shellyplus1pm-c"); err == nil {
+		t.Error("Expected silent device not to be discovered")
+	}
+}
+
+func TestHarness_DiscoverDevicesPartial(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+	slow := mqtttest.NewShelly(b, "shellyplus1pm-slow", 1)
+	slow.Handle("Shelly.GetConfig", func(json.RawMessage) (any, error) {
+		time.Sleep(300 * time.Millisecond)
+		return nil, errors.New("too late")
+	})
+	broken := mqtttest.NewShelly(b, "shellyplus1pm-broken", 1)
+	broken.Handle("Shelly.GetConfig", func(json.RawMessage) (any, error) {
+		return nil, &mqtttest.RPCError{Code: 500, Message: "config unavailable"}
+	})
+
+	d := startHarnessDriver(t, b, WithDiscoveryDeviceTimeout(100*time.Millisecond), WithDiscoveryWorkers(1))
+	store := storer.NewMemory()
+	result, err := d.DiscoverDevices(context.Background(), api.DiscoveryOptions{}, store)
+	if err != nil {
+		t.Fatalf("DiscoverDevices() error = %v", err)
+	}
+	if len(result.DiscoveredTags) != 1 {
+		t.Errorf("Expected 1 discovered device, got %v", result.DiscoveredTags)
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("Expected 2 device errors, got %+v", result.Errors)
+	}
+	if result.Errors[0].DeviceID != "shellyplus1pm-broken" || result.Errors[1].DeviceID != "shellyplus1pm-slow" {
+		t.Errorf("Expected errors for the broken and slow devices, got %+v", result.Errors)
+	}
+	if _, err := store.GetDevice(context.Background(), "shellyplus1pm-slow"); err == nil {
+		t.Error("Expected slow device not to be stored")
+	}
+}
+
+func TestHarness_IDNamespace(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+
+	events := make(chan api.ResourceEvent, 10)
+	d := startHarnessDriver(t, b, WithIDNamespace("shelly"), WithEventHandler(func(_ context.Context, ev *api.ResourceEvent) {
+		events <- *ev
+	}))
+	ctx := context.Background()
+	store := storer.NewMemory()
+	result, err := d.DiscoverDevices(ctx, api.DiscoveryOptions{}, store)
+	if err != nil {
+		t.Fatalf("DiscoverDevices() error = %v", err)
+	}
+	if len(result.DiscoveredTags) != 1 || result.DiscoveredTags[0] != "device.shelly:shellyplus1pm-a" {
+		t.Errorf("Expected namespaced device tag, got %v", result.DiscoveredTags)
+	}
+	stored, err := store.GetDevice(ctx, "shelly:shellyplus1pm-a")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if stored.Actuators[0].DeviceID != "shelly:shellyplus1pm-a" {
+		t.Errorf("Expected actuator of the namespaced device, got %q", stored.Actuators[0].DeviceID)
+	}
+
+	if _, err := d.SetActuator(ctx, stored.Actuators[0], api.ActuatorCommand{Action: "on"}); err != nil {
+		t.Fatalf("SetActuator() error = %v", err)
+	}
+	if !dev.Output(0) {
+		t.Error("Expected command to reach the device by its native ID")
+	}
+
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{"id": 0, "apower": 12.5}})
+	select {
+	case ev := <-events:
+		if ev.DeviceID != "shelly:shellyplus1pm-a" {
+			t.Errorf("Expected event from the namespaced device, got %q", ev.DeviceID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+func TestHarness_SetActuator(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+	d := startHarnessDriver(t, b)
+
+	actuator := &api.Actuator{ID: "switch:0", DeviceID: "shellyplus1pm-a"}
+	state, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "on"})
+	if err != nil {
+		t.Fatalf("SetActuator() error = %v", err)
+	}
+	if !state.Active || !dev.Output(0) {
+		t.Errorf("Expected switch on, got state %v and output %v", state.Active, dev.Output(0))
+	}
+
+	state, err = d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "toggle"})
+	if err != nil {
+		t.Fatalf("SetActuator() error = %v", err)
+	}
+	if state.Active || dev.Output(0) {
+		t.Errorf("Expected toggle to switch off, got state %v and output %v", state.Active, dev.Output(0))
+	}
+
+	actuator.ID = "switch:3"
+	if _, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "on"}); err == nil {
+		t.Error("Expected error for unknown switch")
+	}
+}
+
+func TestHarness_Events(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+
+	events := make(chan api.ResourceEvent, 10)
+	startHarnessDriver(t, b, WithEventHandler(func(_ context.Context, ev *api.ResourceEvent) {
+		events <- *ev
+	}))
+
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{"id": 0, "apower": 12.5}})
+	select {
+	case ev := <-events:
+		if ev.DeviceID != "shellyplus1pm-a" || ev.ResourceID != "switch:0" || ev.Field != "apower" || ev.Reading.Value != 12.5 {
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+}
+
+func TestHarness_DeferredEvents(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+
+	events := make(chan api.ResourceEvent, 10)
+	d := startHarnessDriver(t, b, WithDeferredEvents(), WithEventHandler(func(_ context.Context, ev *api.ResourceEvent) {
+		events <- *ev
+	}))
+
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{"id": 0, "apower": 12.5}})
+	select {
+	case ev := <-events:
+		t.Fatalf("Expected no events before StartEvents, got %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ctx := context.Background()
+	if err := d.StartEvents(ctx); err != nil {
+		t.Fatalf("StartEvents() error = %v", err)
+	}
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{"id": 0, "apower": 13}})
+	select {
+	case ev := <-events:
+		if ev.Reading.Value != 13 {
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	if err := d.StopEvents(ctx); err != nil {
+		t.Fatalf("StopEvents() error = %v", err)
+	}
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{"id": 0, "apower": 14}})
+	select {
+	case ev := <-events:
+		t.Fatalf("Expected no events after StopEvents, got %+v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// batchRecorder passes readings through to a memory store, delivering the size of each
+// batch on a channel
+type batchRecorder struct {
+	*storer.Memory
+	batches chan int
+}
+
+func (b *batchRecorder) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	err := b.Memory.StoreSensorReadings(ctx, recs)
+	b.batches <- len(recs)
+	return err
+}
+
+func TestHarness_CompositeReadings(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+
+	ctx := context.Background()
+	store := &batchRecorder{Memory: storer.NewMemory(), batches: make(chan int, 10)}
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:     "shellyplus1pm-a",
+		Driver: api.DriverShelly,
+		Name:   "Return pump",
+		Sensors: []*api.Sensor{
+			{ID: ReadingSensorID("switch:0", "apower"), Name: "Power", SensorType: api.SensorTypePower},
+			{ID: ReadingSensorID("switch:0", "temperature.tC"), Name: "Temperature", SensorType: api.SensorTypeTemperature},
+			{ID: ReadingSensorID("switch:0", "aenergy.total"), Name: "Energy", SensorType: api.SensorTypePower},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	startHarnessDriver(t, b, WithReadingStore(store))
+
+	// voltage has no sensor and output is not a reading of interest here; both are skipped
+	dev.NotifyStatus(map[string]any{"switch:0": map[string]any{
+		"id":          0,
+		"output":      true,
+		"apower":      42.5,
+		"voltage":     230.1,
+		"temperature": map[string]any{"tC": 41.2, "tF": 106.2},
+		"aenergy":     map[string]any{"total": 1234.5},
+	}})
+	select {
+	case n := <-store.batches:
+		if n != 3 {
+			t.Errorf("Expected one batch of 3 readings, got %d", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for readings")
+	}
+
+	want := map[string]api.SensorReading{
+		"switch:0.apower":         {Value: 42.5, Unit: api.UnitWatts},
+		"switch:0.temperature.tC": {Value: 41.2, Unit: api.UnitCelsius},
+		"switch:0.aenergy.total":  {Value: 1234.5, Unit: api.UnitWattHours},
+	}
+	for sensorID, w := range want {
+		got, err := store.GetLatestSensorReading(ctx, "shellyplus1pm-a", sensorID)
+		if err != nil {
+			t.Errorf("GetLatestSensorReading(%s) error = %v", sensorID, err)
+			continue
+		}
+		if got.Value != w.Value || got.Unit != w.Unit || !got.Valid {
+			t.Errorf("Expected %s reading %v %s, got %+v", sensorID, w.Value, w.Unit, got)
+		}
+	}
+}
+
+func TestHarness_ErrorTaxonomy(t *testing.T) {
+	b := mqtttest.NewBroker(t)
+	dev := mqtttest.NewShelly(b, "shellyplus1pm-a", 1)
+	d := startHarnessDriver(t, b)
+	actuator := &api.Actuator{ID: "switch:0", DeviceID: "shellyplus1pm-a"}
+	on := api.ActuatorCommand{Action: "on"}
+
+	tests := []struct {
+		name   string
+		code   int
+		target error
+	}{
+		{"auth", 401, drivers.ErrAuth},
+		{"no handler", 404, drivers.ErrUnsupported},
+		{"invalid argument", -103, drivers.ErrRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev.Handle("Switch.Set", func(json.RawMessage) (any, error) {
+				return nil, &mqtttest.RPCError{Code: tt.code, Message: tt.name}
+			})
+			_, err := d.SetActuator(context.Background(), actuator, on)
+			if !errors.Is(err, tt.target) {
+				t.Errorf("Expected error to wrap %v, got %v", tt.target, err)
+			}
+		})
+	}
+
+	if _, err := d.SetActuator(context.Background(), actuator, api.ActuatorCommand{Action: "launch"}); !errors.Is(err, drivers.ErrUnsupported) {
+		t.Errorf("Expected unsupported action to wrap ErrUnsupported, got %v", err)
+	}
+
+	dev.Silence(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := d.SetActuator(ctx, actuator, on); !errors.Is(err, drivers.ErrTimeout) {
+		t.Errorf("Expected silent device to wrap ErrTimeout, got %v", err)
+	}
+
+	dev.SetOnline(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for !d.isOffline(dev.ID) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	start := time.Now()
+	if _, err := d.SetActuator(context.Background(), actuator, on); !errors.Is(err, drivers.ErrDeviceOffline) {
+		t.Errorf("Expected offline device to wrap ErrDeviceOffline, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected offline device to fail fast, took %v", elapsed)
+	}
+
+	dev.SetOnline(true)
+	for d.isOffline(dev.ID) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d.isOffline(dev.ID) {
+		t.Error("Expected device to come back online")
+	}
+}
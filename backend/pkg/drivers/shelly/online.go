@@ -0,0 +1,41 @@
+package shelly
+
+import (
+	"context"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// onlineTopic matches the retained connection state every Shelly publishes under its
+// topic prefix. Devices publish "true" on connect and the broker publishes their "false"
+// last will when they drop off.
+const onlineTopic = "+/online"
+
+func (d *Driver) handleOnline(_ mqtt.Client, m mqtt.Message) {
+	deviceID, _, ok := strings.Cut(m.Topic(), "/")
+	if !ok || deviceID == "" {
+		return
+	}
+	online := string(m.Payload()) == "true"
+	d.lock.Lock()
+	if d.offline == nil {
+		d.offline = make(map[string]bool)
+	}
+	if online {
+		delete(d.offline, deviceID)
+	} else {
+		d.offline[deviceID] = true
+	}
+	d.lock.Unlock()
+	ll := d.logCtx(context.Background(), "online")
+	ll.Debug().Str("device_id", deviceID).Bool("online", online).Msg("device connection state changed")
+}
+
+// isOffline reports whether deviceID last announced itself offline. Devices we have
+// not heard from are assumed online.
+func (d *Driver) isOffline(deviceID string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.offline[deviceID]
+}
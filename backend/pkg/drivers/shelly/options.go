@@ -3,6 +3,8 @@ package shelly
 import (
 	"time"
 
+	"lifesupport/backend/pkg/drivers"
+
 	"github.com/rs/zerolog"
 )
 
@@ -20,6 +22,14 @@ func WithClientName(name string) Option {
 	}
 }
 
+// WithIDNamespace stores devices as "<namespace>:<shelly ID>", keeping their IDs apart
+// from devices of other drivers which share a native ID
+func WithIDNamespace(namespace string) Option {
+	return func(d *Driver) {
+		d.idNamespace = namespace
+	}
+}
+
 func WithDiscoveryBufferSize(size int) Option {
 	return func(d *Driver) {
 		d.discoveryBufferSize = size
@@ -38,8 +48,50 @@ func WithDiscoveryWorkers(workers int) Option {
 	}
 }
 
+// WithDiscoveryDeviceTimeout sets each discovered device's budget to report its config
+// and be stored
+func WithDiscoveryDeviceTimeout(timeout time.Duration) Option {
+	return func(d *Driver) {
+		d.discoveryDeviceTimeout = timeout
+	}
+}
+
 func WithLogger(logger zerolog.Logger) Option {
 	return func(d *Driver) {
 		d.log = logger
 	}
 }
+
+// WithEventHandler subscribes to device event notifications and passes each resulting
+// resource event to h
+func WithEventHandler(h drivers.EventHandler) Option {
+	return func(d *Driver) {
+		d.eventHandler = h
+	}
+}
+
+// WithReadingStore stores the readings in each status notification for the device's
+// sensors, one batch per notification. A field is stored for the sensor whose ID is the
+// component and field joined by a dot, e.g. "switch:0.apower" or "temperature:0.tC".
+func WithReadingStore(store ReadingStore) Option {
+	return func(d *Driver) {
+		d.readingStore = store
+	}
+}
+
+// WithDeferredEvents leaves event notifications unsubscribed by Start, for StartEvents
+// and StopEvents to manage; redundant workers use it so only the broker's owner reacts
+// to events
+func WithDeferredEvents() Option {
+	return func(d *Driver) {
+		d.deferEvents = true
+	}
+}
+
+// WithCredentials authenticates to devices with authentication enabled using each
+// device's AuthCredential, its admin password
+func WithCredentials(creds Credentials) Option {
+	return func(d *Driver) {
+		d.credentials = creds
+	}
+}
@@ -2,11 +2,17 @@ package shelly
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand/v2"
 	"sync/atomic"
 	"time"
 
+	"lifesupport/backend/pkg/drivers"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -15,11 +21,76 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+// Shelly RPC error codes with a meaning beyond "rejected"
+const (
+	rpcCodeUnauthorized = 401
+	rpcCodeNoHandler    = 404
+)
+
+// Is makes device error responses match the drivers error taxonomy: authentication
+// failures match drivers.ErrAuth, unknown methods drivers.ErrUnsupported and everything
+// else drivers.ErrRejected
+func (e *ErrorResponse) Is(target error) bool {
+	switch e.Code {
+	case rpcCodeUnauthorized:
+		return target == drivers.ErrAuth
+	case rpcCodeNoHandler:
+		return target == drivers.ErrUnsupported
+	}
+	return target == drivers.ErrRejected
+}
+
 type RequestFrame struct {
-	ID     uint64 `json:"id"`
-	Method string `json:"method"`
-	Params any    `json:"params"`
-	Src    string `json:"src"`
+	ID     uint64       `json:"id"`
+	Method string       `json:"method"`
+	Params any          `json:"params"`
+	Src    string       `json:"src"`
+	Auth   *AuthRequest `json:"auth,omitempty"`
+}
+
+// AuthChallenge is the digest challenge carried in the message of the error response
+// to an unauthenticated request to a device with authentication enabled
+type AuthChallenge struct {
+	AuthType  string `json:"auth_type"`
+	Nonce     int64  `json:"nonce"`
+	NC        int    `json:"nc"`
+	Realm     string `json:"realm"`
+	Algorithm string `json:"algorithm"`
+}
+
+// AuthRequest answers an AuthChallenge
+type AuthRequest struct {
+	Realm     string `json:"realm"`
+	Username  string `json:"username"`
+	Nonce     int64  `json:"nonce"`
+	CNonce    int64  `json:"cnonce"`
+	Response  string `json:"response"`
+	Algorithm string `json:"algorithm"`
+}
+
+// authUser is the only user a Shelly device authenticates
+const authUser = "admin"
+
+// answer computes the digest response to the challenge for password
+func (c *AuthChallenge) answer(password []byte, cnonce int64) *AuthRequest {
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ha1 := hash(authUser + ":" + c.Realm + ":" + string(password))
+	ha2 := hash("dummy_method:dummy_uri")
+	return &AuthRequest{
+		Realm:     c.Realm,
+		Username:  authUser,
+		Nonce:     c.Nonce,
+		CNonce:    cnonce,
+		Response:  hash(fmt.Sprintf("%s:%d:%d:%d:auth:%s", ha1, c.Nonce, c.NC, cnonce, ha2)),
+		Algorithm: "SHA-256",
+	}
 }
 
 type ResponseFrame struct {
@@ -55,13 +126,53 @@ func (r *Driver) handleMessage(_ mqtt.Client, m mqtt.Message) {
 	respCh <- m.Payload()
 }
 
+// roundTrip calls method on the device dst, decoding the result into reply. A device
+// with authentication enabled rejects the first call with a digest challenge, which is
+// answered with its AuthCredential when the driver was built WithCredentials.
 func (r *Driver) roundTrip(ctx context.Context, dst string, method string, params any, reply any, timeout time.Duration) error {
-	id := atomic.AddUint64(&r.nextID, 1)
-	ll := r.logCtx(ctx, "mqtt").With().Uint64("request_id", id).Str("method", method).Str("dst", dst).Logger()
-	ll.Debug().Msg("Initiating round trip to device")
 	if params == nil {
 		params = json.RawMessage("{}")
 	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	respFrame, err := r.exchange(ctx, dst, method, params, nil)
+	if err != nil {
+		return err
+	}
+	if respFrame.Error != nil && respFrame.Error.Code == rpcCodeUnauthorized && r.credentials != nil {
+		var challenge AuthChallenge
+		if err := json.Unmarshal([]byte(respFrame.Error.Message), &challenge); err != nil {
+			return fmt.Errorf("%s: malformed auth challenge: %w", dst, err)
+		}
+		password, err := r.credentials.RevealNamed(ctx, r.deviceID(dst), AuthCredential)
+		if err != nil {
+			return fmt.Errorf("%s: failed to get %s credential: %w: %w", dst, AuthCredential, drivers.ErrAuth, err)
+		}
+		if respFrame, err = r.exchange(ctx, dst, method, params, challenge.answer(password, rand.Int64())); err != nil {
+			return err
+		}
+	}
+	if respFrame.Error != nil {
+		return respFrame.Error
+	}
+	if respFrame.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(*respFrame.Result, reply)
+}
+
+// exchange publishes one request to dst and waits for its response frame
+func (r *Driver) exchange(ctx context.Context, dst, method string, params any, auth *AuthRequest) (*ResponseFrame, error) {
+	id := atomic.AddUint64(&r.nextID, 1)
+	ll := r.logCtx(ctx, "mqtt").With().Uint64("request_id", id).Str("method", method).Str("dst", dst).Logger()
+	ll.Debug().Bool("auth", auth != nil).Msg("Initiating round trip to device")
+	if r.isOffline(dst) {
+		return nil, fmt.Errorf("%s: %w", dst, drivers.ErrDeviceOffline)
+	}
 
 	// Build and publish the request message here, including the ID and parameters.
 	req := RequestFrame{
@@ -69,11 +180,12 @@ func (r *Driver) roundTrip(ctx context.Context, dst string, method string, param
 		Method: method,
 		Params: params,
 		Src:    r.buildSrc(),
+		Auth:   auth,
 	}
 
 	b, err := json.Marshal(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer func() {
@@ -89,20 +201,14 @@ func (r *Driver) roundTrip(ctx context.Context, dst string, method string, param
 
 	dstTopic := dst + "/rpc"
 
-	if timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, timeout)
-		defer cancel()
-	}
-
 	t := r.mqttClient.Publish(dstTopic, 1, false, b)
 	select {
 	case <-t.Done():
 		if err := t.Error(); err != nil {
-			return err
+			return nil, err
 		}
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	}
 
 	select {
@@ -111,18 +217,21 @@ func (r *Driver) roundTrip(ctx context.Context, dst string, method string, param
 		var respFrame ResponseFrame
 		if err := json.Unmarshal(resp, &respFrame); err != nil {
 			ll.Err(err).Msg("Failed to unmarshal response frame")
-			return err
+			return nil, err
 		}
 		if respFrame.Error != nil {
 			ll.Error().Int("code", respFrame.Error.Code).Str("message", respFrame.Error.Message).Msg("Received error response from device")
-			return errors.New(respFrame.Error.Message)
-		}
-		if respFrame.Result == nil {
+		} else if respFrame.Result == nil {
 			ll.Error().Msg("Received response with no result")
-			return nil
 		}
-		return json.Unmarshal(*respFrame.Result, reply)
+		return &respFrame, nil
 	case <-ctx.Done():
-		return ctx.Err()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if r.isOffline(dst) {
+				return nil, fmt.Errorf("%s: %w", dst, drivers.ErrDeviceOffline)
+			}
+			return nil, fmt.Errorf("no response from %s: %w: %w", dst, drivers.ErrTimeout, ctx.Err())
+		}
+		return nil, ctx.Err()
 	}
 }
@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"lifesupport/backend/pkg/drivers"
+
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
@@ -497,3 +499,67 @@ func TestRoundTrip_ConcurrentRequests(t *testing.T) {
 		t.Errorf("Expected router to be empty, got %d entries", routerSize)
 	}
 }
+
+// fakeCredentials holds passwords by device ID and credential name
+type fakeCredentials map[string]string
+
+func (c fakeCredentials) RevealNamed(ctx context.Context, deviceID, name string) ([]byte, error) {
+	secret, ok := c[deviceID+"/"+name]
+	if !ok {
+		return nil, errors.New("no such credential")
+	}
+	return []byte(secret), nil
+}
+
+func TestRoundTrip_DigestAuth(t *testing.T) {
+	challenge := AuthChallenge{AuthType: "digest", Nonce: 1700000000, NC: 1, Realm: "shellypro1-test", Algorithm: "SHA-256"}
+	message, _ := json.Marshal(challenge)
+
+	driver := &Driver{
+		clientName:  "test-client",
+		baseName:    "lifesupport",
+		idNamespace: "shelly",
+		router:      make(map[uint64]chan []byte),
+		credentials: fakeCredentials{"shelly:test-device/" + AuthCredential: "hunter2"},
+	}
+	var requests []RequestFrame
+	mockClient := &MockMQTTClient{}
+	mockClient.publishFunc = func(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+		var req RequestFrame
+		json.Unmarshal(payload.([]byte), &req)
+		requests = append(requests, req)
+
+		resp := ResponseFrame{ID: req.ID, Src: "test-device"}
+		if req.Auth == nil || req.Auth.Response != challenge.answer([]byte("hunter2"), req.Auth.CNonce).Response {
+			resp.Error = &ErrorResponse{Code: rpcCodeUnauthorized, Message: string(message)}
+		} else {
+			result := json.RawMessage(`{"was_on":false}`)
+			resp.Result = &result
+		}
+		b, _ := json.Marshal(resp)
+		go driver.handleMessage(mockClient, &MockMessage{payload: b, topic: "lifesupport/test-client/rpc"})
+
+		token := NewMockToken(nil)
+		token.Complete()
+		return token
+	}
+	driver.mqttClient = mockClient
+
+	var reply map[string]any
+	if err := driver.roundTrip(context.Background(), "test-device", "Switch.Set", nil, &reply, 5*time.Second); err != nil {
+		t.Fatalf("roundTrip failed: %v", err)
+	}
+	if len(requests) != 2 || requests[0].Auth != nil || requests[1].Auth == nil {
+		t.Fatalf("Expected an unauthenticated request answered by an authenticated one, got %+v", requests)
+	}
+	if auth := requests[1].Auth; auth.Username != "admin" || auth.Realm != challenge.Realm || auth.Nonce != challenge.Nonce {
+		t.Errorf("Unexpected auth %+v", auth)
+	}
+
+	// Without the device's credential the challenge fails as an auth error
+	driver.credentials = fakeCredentials{}
+	requests = nil
+	if err := driver.roundTrip(context.Background(), "test-device", "Switch.Set", nil, &reply, 5*time.Second); !errors.Is(err, drivers.ErrAuth) {
+		t.Errorf("Expected drivers.ErrAuth, got %v", err)
+	}
+}
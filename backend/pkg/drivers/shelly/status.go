@@ -13,11 +13,15 @@ import (
 )
 
 func (d *Driver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource drivers.Statuser) (*api.SensorReading, error) {
+	if d.clickhouseConn == nil {
+		return nil, fmt.Errorf("shelly driver has no event store configured: %w", drivers.ErrNoData)
+	}
+
 	// Query to find the latest event for this resource
 	// We filter by src (device ID) and check that params contains the resource ID key
 	q := squirrel.Select("timestamp", "params").
 		From("rabbitmq.shelly_events").
-		Where(squirrel.Eq{"src": resource.GetDeviceID()}).
+		Where(squirrel.Eq{"src": d.nativeID(resource.GetDeviceID())}).
 		Where("JSONHas(params::String, ?, ?)", resource.GetID(), "output").
 		OrderBy("timestamp DESC").
 		Limit(1)
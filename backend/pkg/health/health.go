@@ -0,0 +1,160 @@
+// Package health rates how each subsystem is doing from its sensors' latest readings
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// DefaultStaleAfter is how long a sensor may go without a reading before it is stale
+const DefaultStaleAfter = 15 * time.Minute
+
+// UnassignedSubsystem groups sensors on devices without a subsystem type
+const UnassignedSubsystem = "unassigned"
+
+const (
+	deviationWeight = 0.6
+	staleWeight     = 0.4
+)
+
+// levelScores rates a reading by its target level
+var levelScores = map[api.TargetLevel]float64{
+	api.TargetLevelIdeal:    100,
+	api.TargetLevelOK:       80,
+	api.TargetLevelWarning:  40,
+	api.TargetLevelCritical: 0,
+}
+
+// Score rates each subsystem, grouping sensors by their device's subsystem type. A
+// sensor is stale when its latest valid reading is older than staleAfter, and only fresh
+// readings of sensors with a target range count towards deviation. The overall score
+// weights each subsystem by its number of sensors.
+func Score(ctx context.Context, store storer.Interface, now time.Time, staleAfter time.Duration) (*api.HealthScore, error) {
+	devices, err := store.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	subsystems := map[string]string{}
+	for _, dev := range devices {
+		subsystem := dev.Metadata[api.MetadataSubsystemType]
+		if subsystem == "" {
+			subsystem = UnassignedSubsystem
+		}
+		subsystems[dev.ID] = subsystem
+	}
+	sensors, err := store.ListSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	targets, err := store.ListSensorTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor targets: %w", err)
+	}
+	bySensor := make(map[[2]string]*api.TargetRange, len(targets))
+	for _, target := range targets {
+		bySensor[[2]string{target.DeviceID, target.SensorID}] = target
+	}
+
+	tallies := map[string]*tally{}
+	for _, sensor := range sensors {
+		subsystem := subsystems[sensor.DeviceID]
+		t, ok := tallies[subsystem]
+		if !ok {
+			t = &tally{}
+			tallies[subsystem] = t
+		}
+		t.sensors++
+
+		reading, err := store.GetLatestSensorReading(ctx, sensor.DeviceID, sensor.ID)
+		switch {
+		case errors.Is(err, storer.ErrNotFound):
+			reading = nil
+		case err != nil:
+			return nil, fmt.Errorf("failed to get latest reading: %w", err)
+		}
+		if reading == nil || !reading.Valid || now.Sub(reading.Timestamp) > staleAfter {
+			stale := api.HealthContributor{DeviceID: sensor.DeviceID, SensorID: sensor.ID}
+			if reading != nil {
+				stale.LastSeen = &reading.Timestamp
+			}
+			t.stale = append(t.stale, stale)
+			continue
+		}
+
+		target, ok := bySensor[[2]string{sensor.DeviceID, sensor.ID}]
+		if !ok {
+			continue
+		}
+		level := target.Level(reading.Value)
+		t.rated++
+		t.levelSum += levelScores[level]
+		if level != api.TargetLevelIdeal {
+			value := reading.Value
+			t.deviating = append(t.deviating, api.HealthContributor{DeviceID: sensor.DeviceID, SensorID: sensor.ID, Level: level, Value: &value})
+		}
+	}
+
+	score := &api.HealthScore{GeneratedAt: now, Subsystems: []api.SubsystemHealth{}}
+	var total float64
+	var totalSensors int
+	for subsystem, t := range tallies {
+		health := t.health(subsystem)
+		score.Subsystems = append(score.Subsystems, health)
+		total += health.Score * float64(health.Sensors)
+		totalSensors += health.Sensors
+	}
+	sort.Slice(score.Subsystems, func(i, j int) bool {
+		return score.Subsystems[i].Subsystem < score.Subsystems[j].Subsystem
+	})
+	if totalSensors > 0 {
+		overall := round(total / float64(totalSensors))
+		score.Score = &overall
+	}
+	return score, nil
+}
+
+// tally accumulates one subsystem's sensors
+type tally struct {
+	sensors   int
+	rated     int
+	levelSum  float64
+	deviating []api.HealthContributor
+	stale     []api.HealthContributor
+}
+
+func (t *tally) health(subsystem string) api.SubsystemHealth {
+	health := api.SubsystemHealth{Subsystem: subsystem, Sensors: t.sensors}
+	if t.rated > 0 {
+		health.Factors = append(health.Factors, api.HealthFactor{
+			Name:         api.HealthFactorDeviation,
+			Weight:       deviationWeight,
+			Score:        round(t.levelSum / float64(t.rated)),
+			Contributors: t.deviating,
+		})
+	}
+	health.Factors = append(health.Factors, api.HealthFactor{
+		Name:         api.HealthFactorStaleSensors,
+		Weight:       staleWeight,
+		Score:        round(100 * float64(t.sensors-len(t.stale)) / float64(t.sensors)),
+		Contributors: t.stale,
+	})
+
+	var sum, weights float64
+	for _, f := range health.Factors {
+		sum += f.Score * f.Weight
+		weights += f.Weight
+	}
+	health.Score = round(sum / weights)
+	return health
+}
+
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
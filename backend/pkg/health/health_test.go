@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer/storertest"
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func TestScore(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, dev := range []*api.Device{
+		{
+			ID:       "tank",
+			Driver:   api.DriverShelly,
+			Name:     "Tank",
+			Metadata: map[string]string{api.MetadataSubsystemType: "aquarium"},
+			Sensors: []*api.Sensor{
+				{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature},
+				{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+			},
+		},
+		{
+			ID:      "shed",
+			Driver:  api.DriverShelly,
+			Name:    "Shed",
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature}},
+		},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	err := store.SetSensorTarget(ctx, &api.TargetRange{
+		DeviceID: "tank",
+		SensorID: "temp",
+		Ideal:    api.Band{Min: bound(25), Max: bound(26)},
+		Warning:  api.Band{Min: bound(24), Max: bound(27)},
+		Critical: api.Band{Min: bound(22), Max: bound(29)},
+	})
+	if err != nil {
+		t.Fatalf("SetSensorTarget() error = %v", err)
+	}
+
+	for _, rec := range []*api.ReadingRecord{
+		// Fresh, and in the warning band
+		{DeviceID: "tank", SensorID: "temp", Reading: api.SensorReading{Value: 28, Valid: true, Timestamp: now.Add(-time.Minute)}},
+		// Stale
+		{DeviceID: "tank", SensorID: "ph", Reading: api.SensorReading{Value: 8.1, Valid: true, Timestamp: now.Add(-time.Hour)}},
+		{DeviceID: "shed", SensorID: "temp", Reading: api.SensorReading{Value: 12, Valid: true, Timestamp: now.Add(-time.Minute)}},
+	} {
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+
+	score, err := Score(ctx, store, now, DefaultStaleAfter)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+	if len(score.Subsystems) != 2 {
+		t.Fatalf("Expected 2 subsystems, got %d", len(score.Subsystems))
+	}
+
+	aquarium := score.Subsystems[0]
+	if aquarium.Subsystem != "aquarium" || aquarium.Sensors != 2 {
+		t.Fatalf("Expected aquarium with 2 sensors first, got %+v", aquarium)
+	}
+	if len(aquarium.Factors) != 2 {
+		t.Fatalf("Expected deviation and stale factors, got %+v", aquarium.Factors)
+	}
+	deviation, stale := aquarium.Factors[0], aquarium.Factors[1]
+	if deviation.Name != api.HealthFactorDeviation || deviation.Score != 40 {
+		t.Errorf("Expected deviation score 40, got %+v", deviation)
+	}
+	if len(deviation.Contributors) != 1 || deviation.Contributors[0].Level != api.TargetLevelWarning {
+		t.Errorf("Expected the warning reading to contribute, got %+v", deviation.Contributors)
+	}
+	if stale.Name != api.HealthFactorStaleSensors || stale.Score != 50 {
+		t.Errorf("Expected stale score 50, got %+v", stale)
+	}
+	if len(stale.Contributors) != 1 || stale.Contributors[0].SensorID != "ph" || stale.Contributors[0].LastSeen == nil {
+		t.Errorf("Expected the pH sensor to be stale, got %+v", stale.Contributors)
+	}
+	// 0.6*40 + 0.4*50
+	if aquarium.Score != 44 {
+		t.Errorf("Expected aquarium score 44, got %v", aquarium.Score)
+	}
+
+	// No targets, so only freshness counts
+	unassigned := score.Subsystems[1]
+	if unassigned.Subsystem != UnassignedSubsystem || unassigned.Score != 100 || len(unassigned.Factors) != 1 {
+		t.Errorf("Expected a healthy unassigned subsystem, got %+v", unassigned)
+	}
+
+	// (44*2 + 100*1) / 3
+	if score.Score == nil || *score.Score != 62.7 {
+		t.Errorf("Expected overall score 62.7, got %v", score.Score)
+	}
+}
+
+func TestScoreNoSensors(t *testing.T) {
+	score, err := Score(context.Background(), storertest.New(t), time.Now(), DefaultStaleAfter)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+	if score.Score != nil || len(score.Subsystems) != 0 {
+		t.Errorf("Expected no score without sensors, got %+v", score)
+	}
+}
@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/control"
+
+	"github.com/gorilla/mux"
+)
+
+// groupController returns the controller of the actuator group with name, creating it
+// on first use so the group's rotation carries over from one start to the next
+func (h *Handler) groupController(name string) (*control.GroupController, bool) {
+	h.groupsLock.Lock()
+	defer h.groupsLock.Unlock()
+	if g, ok := h.groups[name]; ok {
+		return g, true
+	}
+	for _, group := range h.ActuatorGroups {
+		if group.Name == name {
+			if h.groups == nil {
+				h.groups = make(map[string]*control.GroupController)
+			}
+			g := control.NewGroupController(group, h.Commander)
+			h.groups[name] = g
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+func groupStatus(g *control.GroupController) *api.ActuatorGroupStatus {
+	return &api.ActuatorGroupStatus{ActuatorGroup: g.Group(), NextOrder: g.Order()}
+}
+
+// ListActuatorGroups handles GET /api/actuator-groups
+func (h *Handler) ListActuatorGroups(w http.ResponseWriter, r *http.Request) {
+	statuses := []*api.ActuatorGroupStatus{}
+	for _, group := range h.ActuatorGroups {
+		g, _ := h.groupController(group.Name)
+		statuses = append(statuses, groupStatus(g))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// StartActuatorGroup handles POST /api/actuator-groups/{name}/start, switching the
+// group's members on in turn. The response waits out the group's stagger offsets.
+func (h *Handler) StartActuatorGroup(w http.ResponseWriter, r *http.Request) {
+	h.commandActuatorGroup(w, r, "start", (*control.GroupController).Start)
+}
+
+// StopActuatorGroup handles POST /api/actuator-groups/{name}/stop
+func (h *Handler) StopActuatorGroup(w http.ResponseWriter, r *http.Request) {
+	h.commandActuatorGroup(w, r, "stop", (*control.GroupController).Stop)
+}
+
+func (h *Handler) commandActuatorGroup(w http.ResponseWriter, r *http.Request, action string, fn func(*control.GroupController, context.Context) error) {
+	if h.Commander == nil {
+		http.Error(w, "Actuator commands are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	g, ok := h.groupController(name)
+	if !ok {
+		http.Error(w, "Actuator group not found: "+name, http.StatusNotFound)
+		return
+	}
+
+	ctx := api.WithCommandOrigin(r.Context(), api.CommandOrigin{Source: api.CommandSourceUser, Name: api.ActorFrom(r.Context())})
+	if err := fn(g, ctx); err != nil {
+		status := driverErrorStatus(err)
+		if errors.Is(err, control.ErrStopped) {
+			// A stop overtook the start; the group is now off
+			status = http.StatusConflict
+		}
+		// Otherwise every member was still tried; the error names those which failed
+		http.Error(w, "Failed to "+action+" actuator group: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groupStatus(g))
+}
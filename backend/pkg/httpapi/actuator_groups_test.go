@@ -0,0 +1,100 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// recordingCommander records the commands it is sent, failing those to tags in fail
+type recordingCommander struct {
+	fail map[string]bool
+
+	lock     sync.Mutex
+	commands []string
+	origins  []api.CommandOrigin
+}
+
+func (c *recordingCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.commands = append(c.commands, tag+" "+cmd.Action)
+	c.origins = append(c.origins, api.CommandOriginFrom(ctx))
+	if c.fail[tag] {
+		return nil, errors.New("device offline")
+	}
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+func (c *recordingCommander) take() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	commands := c.commands
+	c.commands = nil
+	return commands
+}
+
+func TestActuatorGroups(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	h.ActuatorGroups = []api.ActuatorGroup{{
+		Name:         "returns",
+		ActuatorTags: []string{"pump.a", "pump.b"},
+		Rotation:     api.RotationRoundRobin,
+	}}
+	router := h.SetupRouter()
+
+	if rec := doRequest(t, router, "POST", "/api/actuator-groups/returns/start", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a commander, got %d", rec.Code)
+	}
+
+	commander := &recordingCommander{}
+	h.Commander = commander
+	if rec := doRequest(t, router, "POST", "/api/actuator-groups/lights/start", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown group, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/api/actuator-groups/returns/start", nil)
+	req.Header.Set("X-User", "alice")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var status api.ActuatorGroupStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if want := []string{"pump.b", "pump.a"}; !slices.Equal(status.NextOrder, want) {
+		t.Errorf("Expected the rotation to lead with pump.b next, got %v", status.NextOrder)
+	}
+	if got, want := commander.take(), []string{"pump.a on", "pump.b on"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := commander.origins[0]; got.Source != api.CommandSourceUser || got.Name != "alice" {
+		t.Errorf("Expected commands attributed to alice, got %+v", got)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/actuator-groups", nil)
+	var statuses []api.ActuatorGroupStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Failed to decode statuses: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].NextOrder[0] != "pump.b" {
+		t.Errorf("Expected the listed group to keep its rotation, got %+v", statuses)
+	}
+
+	commander.fail = map[string]bool{"pump.a": true}
+	if rec := doRequest(t, router, "POST", "/api/actuator-groups/returns/stop", nil); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when a member fails, got %d", rec.Code)
+	}
+	if got, want := commander.take(), []string{"pump.a off", "pump.b off"}; !slices.Equal(got, want) {
+		t.Errorf("Expected every member to be stopped despite the failure, got %v", got)
+	}
+}
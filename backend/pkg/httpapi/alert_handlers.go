@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/changefeed"
+)
+
+// AcknowledgeAlert handles POST /api/alerts/{id}/ack, recording that the X-User
+// acknowledged an alert from the dashboard. Alerts are not stored, so any ID is accepted.
+func (h *Handler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	user := api.ActorFrom(r.Context())
+	if user == "" {
+		http.Error(w, "The "+actorHeader+" header is required to acknowledge an alert", http.StatusBadRequest)
+		return
+	}
+	ack := &api.AlertAck{
+		AlertID:   mux.Vars(r)["id"],
+		User:      user,
+		Channel:   "dashboard",
+		Timestamp: time.Now().UTC(),
+	}
+	if err := changefeed.NewAlertRecorder(h.Store).Ack(r.Context(), ack); err != nil {
+		http.Error(w, "Failed to acknowledge alert: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ack)
+}
+
+// SlackInteraction handles POST /api/slack/interactions, Slack's interactivity callback
+// for the acknowledge buttons on alerts. Slack signs its requests, so no X-User is needed.
+func (h *Handler) SlackInteraction(w http.ResponseWriter, r *http.Request) {
+	if h.SlackInteractions == nil {
+		http.Error(w, "Slack interactions not configured", http.StatusServiceUnavailable)
+		return
+	}
+	h.SlackInteractions.ServeHTTP(w, r)
+}
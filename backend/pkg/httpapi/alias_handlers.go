@@ -0,0 +1,134 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Tag alias handlers
+
+// ListTagAliases handles GET /api/tag-aliases
+func (h *Handler) ListTagAliases(w http.ResponseWriter, r *http.Request) {
+	aliases, err := h.Store.ListTagAliases(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list tag aliases: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if aliases == nil {
+		aliases = []*api.TagAlias{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aliases)
+}
+
+// GetTagAlias handles GET /api/tag-aliases/{alias}
+func (h *Handler) GetTagAlias(w http.ResponseWriter, r *http.Request) {
+	alias, err := h.Store.GetTagAlias(r.Context(), mux.Vars(r)["alias"])
+	if err != nil {
+		http.Error(w, "Tag alias not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alias)
+}
+
+// SetTagAlias handles PUT /api/tag-aliases/{alias}, creating the alias or retargeting
+// an existing one. Aliases resolve a single level, so the target may not itself be an
+// alias.
+func (h *Handler) SetTagAlias(w http.ResponseWriter, r *http.Request) {
+	var alias api.TagAlias
+	if err := json.NewDecoder(r.Body).Decode(&alias); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	alias.Alias = mux.Vars(r)["alias"]
+
+	switch {
+	case alias.Target == "":
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	case alias.Target == alias.Alias:
+		http.Error(w, "alias cannot target itself", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	_, err := h.Store.GetTagAlias(ctx, alias.Target)
+	switch {
+	case err == nil:
+		http.Error(w, "target "+alias.Target+" is itself an alias", http.StatusBadRequest)
+		return
+	case !errors.Is(err, storer.ErrNotFound):
+		http.Error(w, "Failed to set tag alias: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Store.SetTagAlias(ctx, &alias); err != nil {
+		http.Error(w, "Failed to set tag alias: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.resolveBrokenReferences(ctx, []string{alias.Alias})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alias)
+}
+
+// DeleteTagAlias handles DELETE /api/tag-aliases/{alias}
+func (h *Handler) DeleteTagAlias(w http.ResponseWriter, r *http.Request) {
+	if err := h.Store.DeleteTagAlias(r.Context(), mux.Vars(r)["alias"]); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Tag alias not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete tag alias: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// External ID handlers
+
+// GetDeviceByExternalID handles GET /api/devices/by-external-id/{external_id}
+func (h *Handler) GetDeviceByExternalID(w http.ResponseWriter, r *http.Request) {
+	dev, err := h.Store.GetDeviceByExternalID(r.Context(), mux.Vars(r)["external_id"])
+	if err != nil {
+		http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dev)
+}
+
+// GetSensorByExternalID handles GET /api/sensors/by-external-id/{external_id}
+func (h *Handler) GetSensorByExternalID(w http.ResponseWriter, r *http.Request) {
+	sensor, err := h.Store.GetSensorByExternalID(r.Context(), mux.Vars(r)["external_id"])
+	if err != nil {
+		http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
+// GetActuatorByExternalID handles GET /api/actuators/by-external-id/{external_id}
+func (h *Handler) GetActuatorByExternalID(w http.ResponseWriter, r *http.Request) {
+	actuator, err := h.Store.GetActuatorByExternalID(r.Context(), mux.Vars(r)["external_id"])
+	if err != nil {
+		http.Error(w, "Actuator not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actuator)
+}
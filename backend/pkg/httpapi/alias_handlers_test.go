@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestTagAliases(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{
+		ID:        "alias-dev",
+		Driver:    api.DriverShelly,
+		Name:      "Alias Device",
+		Actuators: []*api.Actuator{{ID: "pump", Name: "Pump", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"sump.pump"}}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := doRequest(t, router, "PUT", "/api/tag-aliases/return.pump", api.TagAlias{Target: "sump.pump"}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 setting alias, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := doRequest(t, router, "GET", "/api/actuators/by-tag/return.pump", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected alias to resolve, got %d", rec.Code)
+	}
+	var actuator api.Actuator
+	if err := json.NewDecoder(rec.Body).Decode(&actuator); err != nil {
+		t.Fatalf("Failed to decode actuator: %v", err)
+	}
+	if actuator.ID != "pump" || actuator.ExternalID == "" {
+		t.Errorf("Expected pump with an external id, got %+v", actuator)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/actuators/by-external-id/"+actuator.ExternalID, nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected actuator by external id, got %d", rec.Code)
+	}
+
+	// Aliases resolve a single level
+	if rec := doRequest(t, router, "PUT", "/api/tag-aliases/pump", api.TagAlias{Target: "return.pump"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for alias of an alias, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "PUT", "/api/tag-aliases/pump", api.TagAlias{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without target, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/tag-aliases", nil)
+	var aliases []api.TagAlias
+	if err := json.NewDecoder(rec.Body).Decode(&aliases); err != nil {
+		t.Fatalf("Failed to decode aliases: %v", err)
+	}
+	if len(aliases) != 1 || aliases[0] != (api.TagAlias{Alias: "return.pump", Target: "sump.pump"}) {
+		t.Errorf("Expected the return.pump alias, got %+v", aliases)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/tag-aliases/return.pump", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 on delete, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/actuators/by-tag/return.pump", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after alias delete, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,233 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/assets"
+	"lifesupport/backend/pkg/blob"
+	"lifesupport/backend/pkg/storer"
+)
+
+// maxAssetBytes bounds image uploads
+const maxAssetBytes = 10 << 20
+
+// Blob keys of an asset's image and thumbnail, under its ID
+const (
+	assetOriginalKey  = "original"
+	assetThumbnailKey = "thumbnail"
+)
+
+// ListDeviceAssets handles GET /api/devices/{id}/assets
+func (h *Handler) ListDeviceAssets(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.Store.GetDevice(r.Context(), id); err != nil {
+		http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	h.listAssets(w, r, storer.AssetFilters{DeviceID: id})
+}
+
+// ListSubsystemAssets handles GET /api/subsystems/{subsystem}/assets
+func (h *Handler) ListSubsystemAssets(w http.ResponseWriter, r *http.Request) {
+	h.listAssets(w, r, storer.AssetFilters{Subsystem: mux.Vars(r)["subsystem"]})
+}
+
+func (h *Handler) listAssets(w http.ResponseWriter, r *http.Request, filters storer.AssetFilters) {
+	list, err := h.Store.ListAssets(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to list assets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []*api.Asset{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// UploadDeviceAsset handles POST /api/devices/{id}/assets
+func (h *Handler) UploadDeviceAsset(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.Store.GetDevice(r.Context(), id); err != nil {
+		http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	h.uploadAsset(w, r, &api.Asset{DeviceID: id})
+}
+
+// UploadSubsystemAsset handles POST /api/subsystems/{subsystem}/assets
+func (h *Handler) UploadSubsystemAsset(w http.ResponseWriter, r *http.Request) {
+	h.uploadAsset(w, r, &api.Asset{Subsystem: mux.Vars(r)["subsystem"]})
+}
+
+// uploadAsset stores the image in the multipart form's "file" field, with its thumbnail,
+// as an asset of the kind named by the "kind" field
+func (h *Handler) uploadAsset(w http.ResponseWriter, r *http.Request, asset *api.Asset) {
+	if h.Assets == nil {
+		http.Error(w, "Blob storage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxAssetBytes+1<<20)
+	if err := r.ParseMultipartForm(maxAssetBytes); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	asset.Kind = api.AssetKind(r.FormValue("kind"))
+	if asset.Kind == "" {
+		asset.Kind = api.AssetKindPhoto
+	}
+	if asset.Kind != api.AssetKindPhoto && asset.Kind != api.AssetKindDiagram {
+		http.Error(w, "kind must be photo or diagram", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxAssetBytes+1))
+	if err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAssetBytes {
+		http.Error(w, "Image exceeds "+strconv.Itoa(maxAssetBytes>>20)+" MiB", http.StatusRequestEntityTooLarge)
+		return
+	}
+	img, err := assets.Process(data)
+	if err != nil {
+		http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	asset.ID = uuid.NewString()
+	asset.Filename = filepath.Base(header.Filename)
+	asset.ContentType = img.ContentType
+	asset.Size = int64(len(data))
+	asset.Width, asset.Height = img.Width, img.Height
+	asset.ThumbnailType = img.ThumbnailType
+
+	ctx := r.Context()
+	bucket := blob.WithPrefix(h.Assets, asset.ID+"/")
+	if err := bucket.Put(ctx, assetOriginalKey, bytes.NewReader(data), asset.ContentType); err != nil {
+		http.Error(w, "Failed to store image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := bucket.Put(ctx, assetThumbnailKey, bytes.NewReader(img.Thumbnail), asset.ThumbnailType); err != nil {
+		h.deleteAssetBlobs(ctx, asset.ID)
+		http.Error(w, "Failed to store thumbnail: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.Store.CreateAsset(ctx, asset); err != nil {
+		h.deleteAssetBlobs(ctx, asset.ID)
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create asset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(asset)
+}
+
+// GetAsset handles GET /api/assets/{id}
+func (h *Handler) GetAsset(w http.ResponseWriter, r *http.Request) {
+	asset, err := h.Store.GetAsset(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Asset not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(asset)
+}
+
+// GetAssetContent handles GET /api/assets/{id}/content
+func (h *Handler) GetAssetContent(w http.ResponseWriter, r *http.Request) {
+	h.serveAssetBlob(w, r, assetOriginalKey)
+}
+
+// GetAssetThumbnail handles GET /api/assets/{id}/thumbnail
+func (h *Handler) GetAssetThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveAssetBlob(w, r, assetThumbnailKey)
+}
+
+// serveAssetBlob streams one of an asset's images. Uploads get a new ID rather than
+// replacing an asset's images, so clients may cache them.
+func (h *Handler) serveAssetBlob(w http.ResponseWriter, r *http.Request, key string) {
+	if h.Assets == nil {
+		http.Error(w, "Blob storage not configured", http.StatusServiceUnavailable)
+		return
+	}
+	ctx := r.Context()
+	asset, err := h.Store.GetAsset(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Asset not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	body, err := h.Assets.Get(ctx, asset.ID+"/"+key)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			http.Error(w, "Asset image not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get asset image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	contentType := asset.ContentType
+	if key == assetThumbnailKey {
+		contentType = asset.ThumbnailType
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	io.Copy(w, body)
+}
+
+// DeleteAsset handles DELETE /api/assets/{id}
+func (h *Handler) DeleteAsset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	if err := h.Store.DeleteAsset(ctx, id); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Asset not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete asset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.deleteAssetBlobs(ctx, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteAssetBlobs removes an asset's images, logging rather than failing the request,
+// since an orphaned blob is harmless once its record is gone
+func (h *Handler) deleteAssetBlobs(ctx context.Context, id string) {
+	if h.Assets == nil {
+		return
+	}
+	for _, key := range []string{assetOriginalKey, assetThumbnailKey} {
+		if err := h.Assets.Delete(ctx, id+"/"+key); err != nil {
+			ll := logCtx(ctx, "assets")
+			ll.Warn().Err(err).Str("asset_id", id).Str("key", key).Msg("failed to delete asset image")
+		}
+	}
+}
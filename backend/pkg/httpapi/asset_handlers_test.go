@@ -0,0 +1,160 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/blob"
+)
+
+// doUpload posts data as the multipart "file" field, with an optional kind
+func doUpload(t *testing.T, router http.Handler, path, filename, kind string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if kind != "" {
+		if err := mw.WriteField("kind", kind); err != nil {
+			t.Fatalf("Failed to write kind: %v", err)
+		}
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestAssets(t *testing.T) {
+	store := setupTestDB(t)
+	handler := NewHandler(store, nil, nil)
+	router := handler.SetupRouter()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "sump-pump", Driver: api.DriverShelly, Name: "Sump pump"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	photo := testPNG(t, 640, 480)
+
+	rec := doUpload(t, router, "/api/devices/sump-pump/assets", "pump.png", "", photo)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without blob storage, got %d", rec.Code)
+	}
+
+	bucket := blob.NewFilesystem(t.TempDir())
+	handler.Assets = bucket
+
+	rec = doUpload(t, router, "/api/devices/sump-pump/assets", "notes.txt", "", []byte("not an image"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-image, got %d", rec.Code)
+	}
+	rec = doUpload(t, router, "/api/devices/sump-pump/assets", "pump.png", "video", photo)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown kind, got %d", rec.Code)
+	}
+	rec = doUpload(t, router, "/api/devices/nope/assets", "pump.png", "", photo)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", rec.Code)
+	}
+
+	rec = doUpload(t, router, "/api/devices/sump-pump/assets", "pump.png", "", photo)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var asset api.Asset
+	if err := json.NewDecoder(rec.Body).Decode(&asset); err != nil {
+		t.Fatalf("Failed to decode asset: %v", err)
+	}
+	if asset.DeviceID != "sump-pump" || asset.Kind != api.AssetKindPhoto || asset.Filename != "pump.png" {
+		t.Errorf("Unexpected asset %+v", asset)
+	}
+	if asset.Width != 640 || asset.Height != 480 || asset.ContentType != "image/png" {
+		t.Errorf("Expected a 640x480 image/png, got %dx%d %s", asset.Width, asset.Height, asset.ContentType)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices/sump-pump/assets", nil)
+	var list []api.Asset
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode assets: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != asset.ID {
+		t.Errorf("Expected the uploaded asset to be listed, got %+v", list)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/assets/"+asset.ID+"/content", nil)
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), photo) {
+		t.Errorf("Expected the original image, got status %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	rec = doRequest(t, router, "GET", "/api/assets/"+asset.ID+"/thumbnail", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected thumbnail content type image/png, got %s", ct)
+	}
+	thumb, err := png.DecodeConfig(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to decode thumbnail: %v", err)
+	}
+	if thumb.Width != 256 || thumb.Height != 192 {
+		t.Errorf("Expected a 256x192 thumbnail, got %dx%d", thumb.Width, thumb.Height)
+	}
+
+	rec = doUpload(t, router, "/api/subsystems/sump/assets", "plumbing.png", "diagram", testPNG(t, 100, 50))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var diagram api.Asset
+	json.NewDecoder(rec.Body).Decode(&diagram)
+	if diagram.Subsystem != "sump" || diagram.Kind != api.AssetKindDiagram {
+		t.Errorf("Unexpected asset %+v", diagram)
+	}
+	rec = doRequest(t, router, "GET", "/api/subsystems/sump/assets", nil)
+	list = nil
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list) != 1 || list[0].ID != diagram.ID {
+		t.Errorf("Expected the diagram to be listed, got %+v", list)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/assets/"+diagram.ID, nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/assets/"+diagram.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", rec.Code)
+	}
+	if _, err := bucket.Get(ctx, diagram.ID+"/original"); err == nil {
+		t.Error("Expected the diagram's image to be deleted")
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/devices/sump-pump", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/assets/"+asset.ID, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the device's asset to be deleted with it, got status %d", rec.Code)
+	}
+	if objects, err := bucket.List(ctx, ""); err != nil || len(objects) != 0 {
+		t.Errorf("Expected no images left, got %v, %v", objects, err)
+	}
+}
@@ -0,0 +1,72 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// actorHeader names the person making a request, for the audit log. It is taken on
+// trust, like the session's user parameter.
+const actorHeader = "X-User"
+
+// attributeActor attaches the request's X-User header to its context, so changes it
+// makes are attributed to that person in the audit log
+func attributeActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get(actorHeader); actor != "" {
+			r = r.WithContext(api.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListAuditEntries handles GET /api/audit, returning who changed devices, sensors and
+// actuators, oldest first
+func (h *Handler) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := storer.AuditFilters{
+		EntityType: api.AuditEntityType(q.Get("entity_type")),
+		EntityID:   q.Get("entity_id"),
+	}
+	switch filters.EntityType {
+	case "", api.AuditEntityDevice, api.AuditEntitySensor, api.AuditEntityActuator:
+	default:
+		http.Error(w, "Invalid entity_type parameter", http.StatusBadRequest)
+		return
+	}
+	for name, dst := range map[string]**time.Time{"start_time": &filters.StartTime, "end_time": &filters.EndTime} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = limit
+	}
+
+	entries, err := h.Store.ListAuditEntries(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to list audit entries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*api.AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,44 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestListAuditEntries(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	body, _ := json.Marshal(api.Device{ID: "audit-dev", Driver: api.DriverShelly, Name: "Audited"})
+	req := httptest.NewRequest("POST", "/api/devices", bytes.NewReader(body))
+	req.Header.Set("X-User", "alice")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/audit-dev", nil) })
+
+	rec = doRequest(t, router, "GET", "/api/audit?entity_type=device&entity_id=audit-dev", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var entries []*api.AuditEntry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode audit entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != api.AuditActionCreated || entries[0].Actor != "alice" {
+		t.Fatalf("Expected the creation attributed to alice, got %+v", entries)
+	}
+
+	for _, query := range []string{"entity_type=rule", "start_time=yesterday", "limit=-1"} {
+		if rec := doRequest(t, router, "GET", "/api/audit?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+}
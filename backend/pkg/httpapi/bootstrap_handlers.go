@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/health"
+)
+
+// GetBootstrap handles GET /api/bootstrap, returning everything the dashboard loads on
+// start in one response. It is cached per user and client, as the user and feature flags
+// depend on who is asking.
+func (h *Handler) GetBootstrap(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subsystems, err := h.subsystemDevices(ctx)
+	if err != nil {
+		http.Error(w, "Failed to list devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	boot := &api.Bootstrap{
+		User:           api.ActorFrom(ctx),
+		Subsystems:     subsystems,
+		LatestReadings: []*api.ReadingRecord{},
+	}
+	for _, sub := range subsystems {
+		for _, dev := range sub.Devices {
+			if len(dev.Sensors) == 0 {
+				continue
+			}
+			readings, err := h.Store.GetLatestReadingsForDevice(ctx, dev.ID)
+			if err != nil {
+				http.Error(w, "Failed to get latest readings: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			boot.LatestReadings = append(boot.LatestReadings, readings...)
+		}
+	}
+	if boot.Features, err = h.features(r); err != nil {
+		http.Error(w, "Failed to list feature flags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if boot.Maintenance, err = h.Store.GetMaintenanceMode(ctx); err != nil {
+		http.Error(w, "Failed to get maintenance mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(boot)
+}
+
+// bootstrapVary keys cached bootstraps by the user and the client feature flags are
+// rolled out by
+func bootstrapVary(r *http.Request) string {
+	return api.ActorFrom(r.Context()) + "\x00" + featureClient(r)
+}
+
+// subsystemDevices lists every device with its sensors and actuators, grouped by the
+// subsystem type in its metadata as the health score groups them
+func (h *Handler) subsystemDevices(ctx context.Context) ([]*api.SubsystemDevices, error) {
+	devices, err := h.Store.ListDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sensors, err := h.Store.ListSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	actuators, err := h.Store.ListActuators(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list actuators: %w", err)
+	}
+
+	byID := make(map[string]*api.Device, len(devices))
+	for _, dev := range devices {
+		dev.Sensors = []*api.Sensor{}
+		dev.Actuators = []*api.Actuator{}
+		byID[dev.ID] = dev
+	}
+	for _, sensor := range sensors {
+		if dev, ok := byID[sensor.DeviceID]; ok {
+			dev.Sensors = append(dev.Sensors, sensor)
+		}
+	}
+	for _, actuator := range actuators {
+		if dev, ok := byID[actuator.DeviceID]; ok {
+			dev.Actuators = append(dev.Actuators, actuator)
+		}
+	}
+
+	// Devices are listed by name, and keep that order within their subsystem
+	bySubsystem := map[string]*api.SubsystemDevices{}
+	subsystems := []*api.SubsystemDevices{}
+	for _, dev := range devices {
+		name := dev.Metadata[api.MetadataSubsystemType]
+		if name == "" {
+			name = health.UnassignedSubsystem
+		}
+		sub, ok := bySubsystem[name]
+		if !ok {
+			sub = &api.SubsystemDevices{Subsystem: name, Devices: []*api.Device{}}
+			bySubsystem[name] = sub
+			subsystems = append(subsystems, sub)
+		}
+		sub.Devices = append(sub.Devices, dev)
+	}
+	sort.Slice(subsystems, func(i, j int) bool { return subsystems[i].Subsystem < subsystems[j].Subsystem })
+	return subsystems, nil
+}
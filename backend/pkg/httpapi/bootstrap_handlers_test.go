@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/health"
+)
+
+func TestGetBootstrap(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "tank-1", Driver: api.DriverShelly, Name: "Tank", Metadata: map[string]string{api.MetadataSubsystemType: "water"},
+			Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+			Actuators: []*api.Actuator{{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay}}},
+		{ID: "fan-1", Driver: api.DriverShelly, Name: "Fan", Metadata: map[string]string{api.MetadataSubsystemType: "air"}},
+		{ID: "spare-1", Driver: api.DriverShelly, Name: "Spare"},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	if err := store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 24.5, Valid: true, Timestamp: time.Now()}}); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+	if err := store.SetFeatureFlag(ctx, &api.FeatureFlag{Name: "rules-engine", Percent: 100}); err != nil {
+		t.Fatalf("SetFeatureFlag() error = %v", err)
+	}
+	if err := store.SetMaintenanceMode(ctx, &api.MaintenanceMode{ReadOnly: true, Message: "Replacing the sump"}); err != nil {
+		t.Fatalf("SetMaintenanceMode() error = %v", err)
+	}
+
+	h := NewHandler(store, nil, nil)
+	h.ReadCacheTTL = time.Minute
+	router := h.SetupRouter()
+	get := func(user string) api.Bootstrap {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/bootstrap", nil)
+		req.Header.Set(actorHeader, user)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var boot api.Bootstrap
+		if err := json.NewDecoder(rec.Body).Decode(&boot); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return boot
+	}
+
+	boot := get("alice")
+	if boot.User != "alice" {
+		t.Errorf("Expected user alice, got %q", boot.User)
+	}
+	var names []string
+	for _, sub := range boot.Subsystems {
+		names = append(names, sub.Subsystem)
+	}
+	if len(names) != 3 || names[0] != "air" || names[1] != health.UnassignedSubsystem || names[2] != "water" {
+		t.Fatalf("Expected subsystems air, unassigned and water, got %v", names)
+	}
+	tank := boot.Subsystems[2].Devices
+	if len(tank) != 1 || len(tank[0].Sensors) != 1 || len(tank[0].Actuators) != 1 {
+		t.Errorf("Expected the tank with its sensor and actuator, got %+v", tank)
+	}
+	if len(boot.LatestReadings) != 1 || boot.LatestReadings[0].Reading.Value != 24.5 {
+		t.Errorf("Expected the tank's latest reading, got %+v", boot.LatestReadings)
+	}
+	if !boot.Features["rules-engine"] {
+		t.Errorf("Expected rules-engine on, got %v", boot.Features)
+	}
+	if boot.Maintenance == nil || !boot.Maintenance.ReadOnly {
+		t.Errorf("Expected read-only maintenance mode, got %+v", boot.Maintenance)
+	}
+
+	// Cached bootstraps are not shared between users
+	if boot := get("bob"); boot.User != "bob" {
+		t.Errorf("Expected user bob, got %q", boot.User)
+	}
+}
@@ -0,0 +1,109 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ListBrokenReferences handles GET /api/rules/broken, listing rules disabled because a
+// device, sensor or actuator they depend on was deleted
+func (h *Handler) ListBrokenReferences(w http.ResponseWriter, r *http.Request) {
+	refs, err := h.Store.ListBrokenReferences(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list broken references: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if refs == nil {
+		refs = []*api.BrokenReference{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refs)
+}
+
+// ClearBrokenReferences handles DELETE /api/rules/broken/{kind}/{name}, re-enabling the
+// rule once its configuration has been fixed
+func (h *Handler) ClearBrokenReferences(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	n, err := h.Store.DeleteBrokenReferences(r.Context(), params["kind"], params["name"])
+	if err != nil {
+		http.Error(w, "Failed to clear broken references: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "No broken references for "+params["kind"]+" "+params["name"], http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordBrokenReferences disables the configured rules depending on tags of a resource
+// which has just been deleted. Failures are logged rather than failing the delete, which
+// has already happened.
+func (h *Handler) recordBrokenReferences(ctx context.Context, resource string, tags []string) {
+	if len(h.Rules) == 0 {
+		return
+	}
+	ll := logCtx(ctx, "broken_references").With().Str("resource", resource).Logger()
+
+	_, rules, err := h.dependents(ctx, tags)
+	if err != nil {
+		ll.Warn().Err(err).Msg("unable to find rules depending on deleted resource")
+		return
+	}
+	now := time.Now()
+	var refs []*api.BrokenReference
+	for _, rule := range rules {
+		for _, tag := range rule.Tags {
+			refs = append(refs, &api.BrokenReference{Kind: rule.Kind, Name: rule.Name, Tag: tag, Resource: resource, DetectedAt: now})
+		}
+	}
+	if len(refs) == 0 {
+		return
+	}
+	if err := h.Store.AddBrokenReferences(ctx, refs); err != nil {
+		ll.Warn().Err(err).Msg("unable to record broken references")
+		return
+	}
+	ll.Warn().Int("rules", len(rules)).Msg("disabled rules depending on deleted resource")
+}
+
+// resolveBrokenReferences re-enables rules whose broken references name one of tags, or
+// an alias of one, now that a resource carries them again
+func (h *Handler) resolveBrokenReferences(ctx context.Context, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	aliases, _, err := h.dependents(ctx, tags)
+	if err != nil {
+		ll := logCtx(ctx, "broken_references")
+		ll.Warn().Err(err).Msg("unable to resolve broken references")
+		return
+	}
+	for _, alias := range aliases {
+		tags = append(tags, alias.Alias)
+	}
+	if _, err := h.Store.ResolveBrokenReferences(ctx, tags); err != nil {
+		ll := logCtx(ctx, "broken_references")
+		ll.Warn().Err(err).Msg("unable to resolve broken references")
+	}
+}
+
+// deviceTags returns the tags of dev and its sensors and actuators
+func deviceTags(dev *api.Device) []string {
+	tags := append([]string{}, dev.Tags...)
+	for _, sensor := range dev.Sensors {
+		tags = append(tags, sensor.Tags...)
+	}
+	for _, actuator := range dev.Actuators {
+		tags = append(tags, actuator.Tags...)
+	}
+	return tags
+}
@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestBrokenReferences(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	h.Rules = []api.RuleRef{
+		api.EventReaction{Name: "ato-off", Tag: "sump.float", ActuatorTags: []string{"ato.pump"}}.Ref(),
+		api.DryRunRule{Name: "return-dry", PumpTag: "sump.return", FlowTag: "sump.flow"}.Ref(),
+	}
+	router := h.SetupRouter()
+
+	dev := api.Device{
+		ID:        "sump-dev",
+		Driver:    api.DriverShelly,
+		Name:      "Sump",
+		Sensors:   []*api.Sensor{{ID: "float", Name: "Float", SensorType: api.SensorTypeTemperature, Tags: []string{"sump.float"}}},
+		Actuators: []*api.Actuator{{ID: "return", Name: "Return", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"sump.return"}}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	listBroken := func() []api.BrokenReference {
+		t.Helper()
+		rec := doRequest(t, router, "GET", "/api/rules/broken", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		var refs []api.BrokenReference
+		if err := json.NewDecoder(rec.Body).Decode(&refs); err != nil {
+			t.Fatalf("Failed to decode broken references: %v", err)
+		}
+		return refs
+	}
+	if refs := listBroken(); len(refs) != 0 {
+		t.Fatalf("Expected no broken references, got %+v", refs)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/sensors/sump-dev/float", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	refs := listBroken()
+	if len(refs) != 1 || refs[0].Name != "ato-off" || refs[0].Tag != "sump.float" || refs[0].Resource != "sensor sump-dev/float" {
+		t.Fatalf("Expected ato-off broken by the float sensor, got %+v", refs)
+	}
+
+	// Recreating a resource with the tag re-enables the rule
+	sensor := api.Sensor{ID: "float2", DeviceID: "sump-dev", Name: "Float", SensorType: api.SensorTypeTemperature, Tags: []string{"sump.float"}}
+	if rec := doRequest(t, router, "POST", "/api/sensors", sensor); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if refs := listBroken(); len(refs) != 0 {
+		t.Errorf("Expected recreation to resolve the reference, got %+v", refs)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/devices/sump-dev", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	if refs := listBroken(); len(refs) != 2 || refs[0].Kind != api.RuleKindDryRun || refs[1].Kind != api.RuleKindEventReaction {
+		t.Fatalf("Expected both rules broken by the device, got %+v", refs)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/rules/broken/dry_run/return-dry", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204 clearing the rule, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "DELETE", "/api/rules/broken/dry_run/return-dry", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 clearing again, got %d", rec.Code)
+	}
+	if refs := listBroken(); len(refs) != 1 {
+		t.Errorf("Expected 1 remaining broken reference, got %+v", refs)
+	}
+}
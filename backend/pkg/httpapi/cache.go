@@ -0,0 +1,155 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cachedResponse is a rendered GET response kept for ReadCacheTTL
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// readCache holds short-lived copies of read-heavy responses so several dashboards
+// polling the same endpoint share one database query. Any mutating request clears it.
+type readCache struct {
+	lock    sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func (c *readCache) get(key string, now time.Time) *cachedResponse {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	resp, ok := c.entries[key]
+	if !ok || now.After(resp.expires) {
+		return nil
+	}
+	return resp
+}
+
+func (c *readCache) put(key string, resp *cachedResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedResponse)
+	}
+	c.entries[key] = resp
+}
+
+func (c *readCache) clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = nil
+}
+
+// bufferedResponse captures a handler's output so it can be hashed and cached
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// cached wraps a GET handler with ETag/If-None-Match support and, when ReadCacheTTL is
+// set, serves repeated requests for the same URL from memory until the TTL expires
+func (h *Handler) cached(next http.HandlerFunc) http.HandlerFunc {
+	return h.cachedPer(nil, next)
+}
+
+// cachedPer is cached for handlers whose response depends on who is asking, keeping
+// responses per URL and per the value of vary for the request
+func (h *Handler) cachedPer(vary func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.RequestURI()
+		if vary != nil {
+			key += "\x00" + vary(r)
+		}
+		now := time.Now()
+
+		resp := h.readCache.get(key, now)
+		if resp == nil {
+			buf := &bufferedResponse{header: make(http.Header)}
+			next(buf, r)
+			if buf.status == 0 {
+				buf.status = http.StatusOK
+			}
+			resp = &cachedResponse{
+				status:  buf.status,
+				header:  buf.header,
+				body:    buf.body.Bytes(),
+				etag:    etag(buf.body.Bytes()),
+				expires: now.Add(h.ReadCacheTTL),
+			}
+			if resp.status == http.StatusOK && h.ReadCacheTTL > 0 {
+				h.readCache.put(key, resp)
+			}
+		}
+
+		for k, v := range resp.header {
+			w.Header()[k] = v
+		}
+		if resp.status != http.StatusOK {
+			w.WriteHeader(resp.status)
+			w.Write(resp.body)
+			return
+		}
+		w.Header().Set("ETag", resp.etag)
+		w.Header().Set("Cache-Control", "no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), resp.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(resp.body)
+	}
+}
+
+// invalidateReadCache clears cached responses after any request that may change state
+func (h *Handler) invalidateReadCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			h.readCache.clear()
+		}
+	})
+}
+
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison used by If-None-Match
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
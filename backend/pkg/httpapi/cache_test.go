@@ -0,0 +1,115 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func countingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":"dev-1"}]`))
+	}
+}
+
+func TestCached_ETag(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	var calls int
+	handler := h.cached(countingHandler(&calls))
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Expected 200 with ETag, got %d %q", rec.Code, tag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/devices", nil)
+	req.Header.Set("If-None-Match", tag)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("Expected empty body for 304, got %q", rec.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("Expected handler to run on every request without a TTL, got %d calls", calls)
+	}
+}
+
+func TestCached_ServerCache(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	h.ReadCacheTTL = time.Minute
+	var calls int
+	handler := h.cached(countingHandler(&calls))
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+		if rec.Body.String() != `[{"id":"dev-1"}]` {
+			t.Fatalf("Unexpected body %q", rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("Expected cached Content-Type header")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 handler call, got %d", calls)
+	}
+
+	// A different query string is cached separately.
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/devices?x=1", nil))
+	if calls != 2 {
+		t.Errorf("Expected 2 handler calls, got %d", calls)
+	}
+
+	// Mutations clear the cache.
+	h.invalidateReadCache(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/devices", nil))
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+	if calls != 3 {
+		t.Errorf("Expected cache to be cleared by POST, got %d calls", calls)
+	}
+}
+
+func TestCached_ErrorsNotCached(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	h.ReadCacheTTL = time.Minute
+	var calls int
+	handler := h.cached(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/devices", nil))
+		if rec.Code != http.StatusInternalServerError || rec.Header().Get("ETag") != "" {
+			t.Errorf("Expected uncached 500 without ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected errors not to be cached, got %d calls", calls)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tag := `"abc"`
+	for header, want := range map[string]bool{
+		`"abc"`:      true,
+		`W/"abc"`:    true,
+		`"x", "abc"`: true,
+		`*`:          true,
+		`"other"`:    false,
+		``:           false,
+	} {
+		if got := etagMatches(header, tag); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Page sizes of the change feed
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// ListChanges handles GET /api/changes, returning the changes after the after cursor,
+// oldest first, and the cursor to pass for the next page
+func (h *Handler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var after int64
+	if v := q.Get("after"); v != "" {
+		var err error
+		after, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || after < 0 {
+			http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultChangesLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxChangesLimit {
+			http.Error(w, "Invalid limit parameter: must be between 1 and "+strconv.Itoa(maxChangesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, err := h.Store.ListChanges(r.Context(), after, limit)
+	if err != nil {
+		http.Error(w, "Failed to list changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feed := api.ChangeFeed{Changes: changes, Cursor: after}
+	if feed.Changes == nil {
+		feed.Changes = []*api.ChangeEvent{}
+	}
+	if len(changes) > 0 {
+		feed.Cursor = changes[len(changes)-1].Seq
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feed)
+}
@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+func TestListChanges(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+	ctx := context.Background()
+
+	list := func(path string) api.ChangeFeed {
+		t.Helper()
+		rec := doRequest(t, router, "GET", path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var feed api.ChangeFeed
+		if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil {
+			t.Fatalf("Failed to decode change feed: %v", err)
+		}
+		return feed
+	}
+
+	// Skip past changes made by earlier tests sharing the database
+	var start int64
+	for {
+		feed := list("/api/changes?limit=1000&after=" + itoa(start))
+		if len(feed.Changes) == 0 {
+			break
+		}
+		start = feed.Cursor
+	}
+
+	for _, id := range []string{"feed-dev-1", "feed-dev-2"} {
+		if err := store.CreateDevice(ctx, &api.Device{ID: id, Driver: api.DriverShelly, Name: id}); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	if rec := doRequest(t, router, "DELETE", "/api/devices/feed-dev-1", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+
+	feed := list("/api/changes?limit=2&after=" + itoa(start))
+	if len(feed.Changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(feed.Changes))
+	}
+	if feed.Changes[0].Type != api.ChangeDeviceCreated || feed.Changes[0].EntityID != "feed-dev-1" {
+		t.Errorf("Expected feed-dev-1 to be created first, got %s %s", feed.Changes[0].Type, feed.Changes[0].EntityID)
+	}
+	if feed.Cursor != feed.Changes[1].Seq {
+		t.Errorf("Expected cursor %d, got %d", feed.Changes[1].Seq, feed.Cursor)
+	}
+
+	next := list("/api/changes?after=" + itoa(feed.Cursor))
+	if len(next.Changes) != 1 || next.Changes[0].Type != api.ChangeDeviceDeleted || next.Changes[0].Data != nil {
+		t.Errorf("Expected only the deletion of feed-dev-1, got %+v", next.Changes)
+	}
+	if empty := list("/api/changes?after=" + itoa(next.Cursor)); len(empty.Changes) != 0 || empty.Cursor != next.Cursor {
+		t.Errorf("Expected no changes and an unchanged cursor, got %+v", empty)
+	}
+
+	for _, path := range []string{"/api/changes?after=-1", "/api/changes?limit=0", "/api/changes?limit=5000"} {
+		if rec := doRequest(t, router, "GET", path, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// CloneDevice handles POST /api/devices/{id}/clone, creating a copy of a device with its
+// sensors and actuators under a new ID, its tags moved to a new prefix. The copy is a
+// placeholder until hardware with its ID is discovered.
+func (h *Handler) CloneDevice(w http.ResponseWriter, r *http.Request) {
+	var req api.DeviceClone
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if req.TagPrefix.From == "" || req.TagPrefix.To == "" || req.TagPrefix.From == req.TagPrefix.To {
+		http.Error(w, "tag_prefix needs distinct from and to prefixes", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	src, err := h.Store.GetDevice(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dev := src.Clone(req)
+	if err := h.Store.CreateDevice(ctx, dev); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, storer.ErrAlreadyExists) {
+			// The ID or one of the rewritten tags is taken
+			status = http.StatusConflict
+		}
+		http.Error(w, "Failed to clone device: "+err.Error(), status)
+		return
+	}
+	h.resolveBrokenReferences(ctx, deviceTags(dev))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dev)
+}
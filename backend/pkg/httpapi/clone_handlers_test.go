@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestCloneDevice(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	src := &api.Device{
+		ID:       "rack1-ctl",
+		Driver:   api.DriverShelly,
+		Name:     "Rack 1 controller",
+		Metadata: map[string]string{"room": "garage"},
+		Tags:     []string{"rack1.controller"},
+		Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"rack1.temp", "garage.temp"}},
+		},
+		Actuators: []*api.Actuator{
+			{ID: "light", Name: "Light", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"rack1.light"}},
+		},
+	}
+	if err := store.CreateDevice(ctx, src); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	req := api.DeviceClone{ID: "rack2-ctl", Name: "Rack 2 controller", TagPrefix: api.TagPrefixRemap{From: "rack1.", To: "rack2."}}
+	rec := doRequest(t, router, "POST", "/api/devices/rack1-ctl/clone", req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var clone api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&clone); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if clone.ID != "rack2-ctl" || clone.Name != "Rack 2 controller" || clone.Metadata["room"] != "garage" {
+		t.Errorf("Unexpected clone: %+v", clone)
+	}
+	// The source's default tag is replaced by the clone's own
+	if want := []string{"device.rack2-ctl", "rack2.controller"}; !reflect.DeepEqual(clone.Tags, want) {
+		t.Errorf("Expected device tags %v, got %v", want, clone.Tags)
+	}
+	if len(clone.Sensors) != 1 || !reflect.DeepEqual(clone.Sensors[0].Tags, []string{"rack2.temp"}) {
+		t.Errorf("Expected the sensor tagged rack2.temp only, got %+v", clone.Sensors)
+	}
+	if len(clone.Actuators) != 1 || !reflect.DeepEqual(clone.Actuators[0].Tags, []string{"rack2.light"}) {
+		t.Errorf("Expected the actuator tagged rack2.light, got %+v", clone.Actuators)
+	}
+	if clone.ExternalID == "" || clone.ExternalID == src.ExternalID {
+		t.Errorf("Expected a new external ID, got %q", clone.ExternalID)
+	}
+	if sensor, err := store.GetSensorByTag(ctx, "rack2.temp"); err != nil || sensor.DeviceID != "rack2-ctl" {
+		t.Errorf("Expected rack2.temp on the clone, got %+v, %v", sensor, err)
+	}
+
+	// The source is untouched
+	if sensor, err := store.GetSensorByTag(ctx, "garage.temp"); err != nil || sensor.DeviceID != "rack1-ctl" {
+		t.Errorf("Expected garage.temp left on the source, got %+v, %v", sensor, err)
+	}
+}
+
+func TestCloneDevice_Errors(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+	src := &api.Device{ID: "rack1-ctl", Driver: api.DriverShelly, Name: "Rack 1", Tags: []string{"rack1.controller"}}
+	if err := store.CreateDevice(context.Background(), src); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	remap := api.TagPrefixRemap{From: "rack1.", To: "rack2."}
+
+	tests := []struct {
+		name string
+		path string
+		req  api.DeviceClone
+		want int
+	}{
+		{"missing id", "/api/devices/rack1-ctl/clone", api.DeviceClone{TagPrefix: remap}, http.StatusBadRequest},
+		{"missing prefix", "/api/devices/rack1-ctl/clone", api.DeviceClone{ID: "rack2-ctl"}, http.StatusBadRequest},
+		{"same prefix", "/api/devices/rack1-ctl/clone", api.DeviceClone{ID: "rack2-ctl", TagPrefix: api.TagPrefixRemap{From: "rack1.", To: "rack1."}}, http.StatusBadRequest},
+		{"unknown device", "/api/devices/nope/clone", api.DeviceClone{ID: "rack2-ctl", TagPrefix: remap}, http.StatusNotFound},
+		{"id taken", "/api/devices/rack1-ctl/clone", api.DeviceClone{ID: "rack1-ctl", TagPrefix: remap}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, router, "POST", tt.path, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
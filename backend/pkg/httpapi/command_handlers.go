@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// GetDeviceCommands handles GET /api/devices/{id}/commands, returning the device's
+// actuator commands in the order they were issued
+func (h *Handler) GetDeviceCommands(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if _, err := h.Store.GetDevice(r.Context(), id); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	filters := storer.CommandFilters{
+		DeviceID:   id,
+		ActuatorID: q.Get("actuator_id"),
+	}
+	for name, dst := range map[string]**time.Time{"start_time": &filters.StartTime, "end_time": &filters.EndTime} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = limit
+	}
+
+	commands, err := h.Store.ListCommands(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to list commands: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if commands == nil {
+		commands = []*api.CommandRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commands)
+}
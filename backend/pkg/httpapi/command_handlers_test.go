@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+)
+
+// relayDriver switches actuators in memory, failing commands to actuators in fail
+type relayDriver struct {
+	fail map[string]bool
+}
+
+func (d *relayDriver) DiscoverDevices(ctx context.Context, opt api.DiscoveryOptions, s storer.Interface) (*api.DiscoveryResult, error) {
+	return &api.DiscoveryResult{}, nil
+}
+
+func (d *relayDriver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource drivers.Statuser) (*api.SensorReading, error) {
+	return nil, storer.ErrNotFound
+}
+
+func (d *relayDriver) SetActuator(ctx context.Context, actuator *api.Actuator, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	if d.fail[actuator.ID] {
+		return nil, errors.New("device offline")
+	}
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+func TestGetDeviceCommands(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := &api.Device{
+		ID:     "heater-dev",
+		Driver: api.DriverShelly,
+		Name:   "Heater",
+		Actuators: []*api.Actuator{
+			{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.heater"}},
+			{ID: "fan", Name: "Fan", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.fan"}},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	manager := drivers.NewManager()
+	manager.Register(api.DriverShelly, &relayDriver{fail: map[string]bool{"fan": true}})
+	dispatcher := drivers.NewDispatcher(store, manager)
+
+	ruleCtx := api.WithCommandOrigin(ctx, api.CommandOrigin{Source: api.CommandSourceRule, Name: "event_reaction/cold-night"})
+	state, err := dispatcher.Command(ruleCtx, "tank.heater", api.ActuatorCommand{Action: "on"})
+	if err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+	if state.Origin == nil || state.Origin.Name != "event_reaction/cold-night" {
+		t.Errorf("Expected the state attributed to the rule, got %+v", state.Origin)
+	}
+	userCtx := api.WithCommandOrigin(ctx, api.CommandOrigin{Source: api.CommandSourceUser, Name: "alice"})
+	if _, err := dispatcher.Command(userCtx, "tank.fan", api.ActuatorCommand{Action: "on"}); err == nil {
+		t.Fatalf("Expected the fan command to fail")
+	}
+	if _, err := dispatcher.Command(userCtx, "tank.heater", api.ActuatorCommand{Action: "off"}); err != nil {
+		t.Fatalf("Command() error = %v", err)
+	}
+
+	rec := doRequest(t, router, "GET", "/api/devices/heater-dev/commands", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var commands []*api.CommandRecord
+	if err := json.NewDecoder(rec.Body).Decode(&commands); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(commands))
+	}
+	first := commands[0]
+	if first.ActuatorID != "heater" || first.Command.Action != "on" || first.Origin.Source != api.CommandSourceRule || first.Origin.Name != "event_reaction/cold-night" {
+		t.Errorf("Expected the rule's heater command first, got %+v", first)
+	}
+	if first.State == nil || !first.State.Active || !first.Succeeded() {
+		t.Errorf("Expected the heater command to report the heater on, got %+v", first)
+	}
+	if first.State != nil && (first.State.Origin == nil || first.State.Origin.Source != api.CommandSourceRule) {
+		t.Errorf("Expected the stored state attributed to the rule, got %+v", first.State.Origin)
+	}
+	if failed := commands[1]; failed.ActuatorID != "fan" || failed.Succeeded() || failed.State != nil {
+		t.Errorf("Expected the failed fan command second, got %+v", failed)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices/heater-dev/commands?actuator_id=heater&limit=1", nil)
+	commands = nil
+	json.NewDecoder(rec.Body).Decode(&commands)
+	if len(commands) != 1 || commands[0].Command.Action != "off" {
+		t.Errorf("Expected only the latest heater command, got %+v", commands)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices/heater-dev/commands?limit=x", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad limit, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "GET", "/api/devices/missing/commands", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+
+	// Every command, failed or not, is in the audit log
+	rec = doRequest(t, router, "GET", "/api/audit?entity_type=actuator", nil)
+	var entries []*api.AuditEntry
+	if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode audit log: %v", err)
+	}
+	var audited []string
+	for _, e := range entries {
+		if e.Action == api.AuditActionCommanded {
+			audited = append(audited, e.EntityID+" by "+string(e.Source)+" "+e.Actor)
+		}
+	}
+	want := []string{"heater-dev/heater by rule event_reaction/cold-night", "heater-dev/fan by user alice", "heater-dev/heater by user alice"}
+	if !reflect.DeepEqual(audited, want) {
+		t.Errorf("Expected audited commands %v, got %v", want, audited)
+	}
+
+	// History goes with the device
+	if err := store.DeleteDevice(ctx, "heater-dev"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	if left, _ := store.ListCommands(ctx, storer.CommandFilters{DeviceID: "heater-dev"}); len(left) != 0 {
+		t.Errorf("Expected command history deleted with the device, got %d commands", len(left))
+	}
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/control"
+
+	"github.com/gorilla/mux"
+)
+
+// ListCompositeRules handles GET /api/rules/composite, evaluating each composite rule's
+// condition against the latest readings
+func (h *Handler) ListCompositeRules(w http.ResponseWriter, r *http.Request) {
+	if h.Readings == nil {
+		http.Error(w, "Live readings are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	now := time.Now()
+	statuses := []*api.CompositeRuleStatus{}
+	for _, rule := range h.CompositeRules {
+		statuses = append(statuses, h.compositeRuleStatus(r, rule, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// GetCompositeRule handles GET /api/rules/composite/{name}, the rule debug view: the
+// outcome of each part of the rule's condition against the latest readings
+func (h *Handler) GetCompositeRule(w http.ResponseWriter, r *http.Request) {
+	if h.Readings == nil {
+		http.Error(w, "Live readings are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	for _, rule := range h.CompositeRules {
+		if rule.Name == name {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(h.compositeRuleStatus(r, rule, time.Now()))
+			return
+		}
+	}
+	http.Error(w, "Composite rule not found: "+name, http.StatusNotFound)
+}
+
+func (h *Handler) compositeRuleStatus(r *http.Request, rule api.CompositeRule, now time.Time) *api.CompositeRuleStatus {
+	status := control.EvaluateCondition(r.Context(), h.Readings, rule.Condition)
+	return &api.CompositeRuleStatus{
+		Name:      rule.Name,
+		Result:    status.Result,
+		For:       rule.For,
+		Condition: status,
+		Timestamp: now,
+	}
+}
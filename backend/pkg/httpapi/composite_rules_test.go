@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type staticReadings map[string]*api.SensorReading
+
+func (s staticReadings) LatestReading(ctx context.Context, tag string) (*api.SensorReading, error) {
+	if r, ok := s[tag]; ok {
+		return r, nil
+	}
+	return nil, errors.New("no reading")
+}
+
+func TestCompositeRules(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	h.CompositeRules = []api.CompositeRule{{
+		Name: "heater-no-flow",
+		Condition: api.Condition{All: []api.Condition{
+			{Tag: "heater.main", Op: api.OpEqual, Value: 1},
+			{Tag: "flow.main", Op: api.OpEqual, Value: 0},
+		}},
+	}}
+	router := h.SetupRouter()
+
+	if rec := doRequest(t, router, "GET", "/api/rules/composite/heater-no-flow", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without live readings, got %d", rec.Code)
+	}
+
+	h.Readings = staticReadings{"heater.main": {Value: 0, Valid: true}}
+	rec := doRequest(t, router, "GET", "/api/rules/composite/heater-no-flow", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var status api.CompositeRuleStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if status.Result != api.ConditionFalse || len(status.Condition.Children) != 2 {
+		t.Fatalf("Expected false with a status per part, got %+v", status)
+	}
+	if got := status.Condition.Children[1].Result; got != api.ConditionSkipped {
+		t.Errorf("Expected the flow check to be skipped, got %s", got)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/rules/composite", nil)
+	var statuses []api.CompositeRuleStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Failed to decode statuses: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "heater-no-flow" {
+		t.Errorf("Expected the one rule, got %+v", statuses)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/rules/composite/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// CredentialVault seals device credentials; the server uses a secrets.Vault
+type CredentialVault interface {
+	Set(ctx context.Context, deviceID, name string, secret []byte) (*api.Credential, error)
+}
+
+// ListDeviceCredentials handles GET /api/devices/{id}/credentials. Secrets are never
+// returned, only the credentials' IDs for reference.
+func (h *Handler) ListDeviceCredentials(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	if _, err := h.Store.GetDevice(ctx, id); err != nil {
+		http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	creds, err := h.Store.ListCredentials(ctx, id)
+	if err != nil {
+		http.Error(w, "Failed to list credentials: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if creds == nil {
+		creds = []*api.Credential{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creds)
+}
+
+// SetDeviceCredential handles PUT /api/devices/{id}/credentials/{name}, sealing the
+// secret as the device's named credential or replacing the secret of an existing one
+func (h *Handler) SetDeviceCredential(w http.ResponseWriter, r *http.Request) {
+	if h.Credentials == nil {
+		http.Error(w, "Credential store not configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req api.SetCredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Secret == "" {
+		http.Error(w, "secret is required", http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(r)
+	cred, err := h.Credentials.Set(r.Context(), vars["id"], vars["name"], []byte(req.Secret))
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to set credential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cred)
+}
+
+// GetCredential handles GET /api/credentials/{id}, describing the credential without
+// its secret
+func (h *Handler) GetCredential(w http.ResponseWriter, r *http.Request) {
+	cred, err := h.Store.GetCredential(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Credential not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get credential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cred.Credential)
+}
+
+// DeleteCredential handles DELETE /api/credentials/{id}
+func (h *Handler) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	if err := h.Store.DeleteCredential(r.Context(), mux.Vars(r)["id"]); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Credential not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete credential: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
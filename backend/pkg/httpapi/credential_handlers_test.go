@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/secrets"
+)
+
+func TestDeviceCredentials(t *testing.T) {
+	store := setupTestDB(t)
+	handler := NewHandler(store, nil, nil)
+	router := handler.SetupRouter()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "camera-1", Driver: api.DriverShelly, Name: "Sump camera"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	rec := doRequest(t, router, "PUT", "/api/devices/camera-1/credentials/rtsp", api.SetCredentialRequest{Secret: "hunter2"})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a credential store, got %d", rec.Code)
+	}
+
+	vault, err := secrets.NewVault(store, make([]byte, secrets.KeySize))
+	if err != nil {
+		t.Fatalf("NewVault() error = %v", err)
+	}
+	handler.Credentials = vault
+
+	rec = doRequest(t, router, "PUT", "/api/devices/camera-1/credentials/rtsp", api.SetCredentialRequest{})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a secret, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "PUT", "/api/devices/camera-9/credentials/rtsp", api.SetCredentialRequest{Secret: "hunter2"})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, "PUT", "/api/devices/camera-1/credentials/rtsp", api.SetCredentialRequest{Secret: "hunter2"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "hunter2") {
+		t.Error("Expected response not to contain the secret")
+	}
+	var cred api.Credential
+	if err := json.NewDecoder(rec.Body).Decode(&cred); err != nil {
+		t.Fatalf("Failed to decode credential: %v", err)
+	}
+	if cred.ID == "" || cred.DeviceID != "camera-1" || cred.Name != "rtsp" {
+		t.Errorf("Unexpected credential %+v", cred)
+	}
+	if secret, err := vault.Reveal(ctx, cred.ID); err != nil || string(secret) != "hunter2" {
+		t.Errorf("Expected the vault to reveal hunter2, got %q, %v", secret, err)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices/camera-1/credentials", nil)
+	var creds []api.Credential
+	if err := json.NewDecoder(rec.Body).Decode(&creds); err != nil {
+		t.Fatalf("Failed to decode credentials: %v", err)
+	}
+	if len(creds) != 1 || creds[0].ID != cred.ID {
+		t.Errorf("Expected the rtsp credential, got %+v", creds)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/credentials/"+cred.ID, nil)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "hunter2") {
+		t.Errorf("Expected credential without its secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, router, "DELETE", "/api/credentials/"+cred.ID, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "GET", "/api/credentials/"+cred.ID, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", rec.Code)
+	}
+}
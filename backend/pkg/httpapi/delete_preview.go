@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// GetDeviceDeletePreview handles GET /api/devices/{id}/delete-preview, reporting what
+// DELETE /api/devices/{id} would cascade to without deleting anything
+func (h *Handler) GetDeviceDeletePreview(w http.ResponseWriter, r *http.Request) {
+	preview, err := h.deviceDeletePreview(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to preview device deletion: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+func (h *Handler) deviceDeletePreview(ctx context.Context, id string) (*api.DeletePreview, error) {
+	dev, err := h.Store.GetDevice(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := h.Store.CountSensorReadings(ctx, storer.SensorReadingFilters{DeviceID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &api.DeletePreview{
+		DeviceID:  id,
+		Sensors:   len(dev.Sensors),
+		Actuators: len(dev.Actuators),
+		Readings:  readings,
+		Tags:      deviceTags(dev),
+	}
+
+	preview.Aliases, preview.Rules, err = h.dependents(ctx, preview.Tags)
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// dependents returns the aliases pointing at any of tags and the configured rules using
+// one of tags, directly or through such an alias. Each rule is trimmed to the affected
+// tags.
+func (h *Handler) dependents(ctx context.Context, tags []string) ([]api.TagAlias, []api.RuleRef, error) {
+	removed := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		removed[tag] = true
+	}
+	aliases, err := h.Store.ListTagAliases(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var dangling []api.TagAlias
+	affected := maps.Clone(removed)
+	for _, alias := range aliases {
+		if removed[alias.Target] && !removed[alias.Alias] {
+			dangling = append(dangling, *alias)
+			affected[alias.Alias] = true
+		}
+	}
+
+	var rules []api.RuleRef
+	for _, rule := range h.Rules {
+		var ruleTags []string
+		for _, tag := range rule.Tags {
+			if affected[tag] {
+				ruleTags = append(ruleTags, tag)
+			}
+		}
+		if len(ruleTags) > 0 {
+			rules = append(rules, api.RuleRef{Kind: rule.Kind, Name: rule.Name, Tags: ruleTags})
+		}
+	}
+	return dangling, rules, nil
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGetDeviceDeletePreview(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	h := NewHandler(store, nil, nil)
+	h.Rules = []api.RuleRef{
+		api.EventReaction{Name: "ato-off", Tag: "sump.float", ActuatorTags: []string{"ato.pump"}}.Ref(),
+		api.LeakResponse{Subsystem: "sump", LeakTags: []string{"floor.leak"}, PumpTags: []string{"main.return"}}.Ref(),
+		api.DryRunRule{Name: "skimmer", PumpTag: "skimmer.pump", PowerTag: "skimmer.power"}.Ref(),
+	}
+	router := h.SetupRouter()
+
+	dev := &api.Device{
+		ID:     "sump-dev",
+		Driver: api.DriverShelly,
+		Name:   "Sump",
+		Sensors: []*api.Sensor{
+			{ID: "float", Name: "Float", SensorType: api.SensorTypeTemperature, Tags: []string{"sump.float"}},
+			{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature},
+		},
+		Actuators: []*api.Actuator{{ID: "return", Name: "Return", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"sump.return"}}},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		rec := &api.ReadingRecord{DeviceID: "sump-dev", SensorID: "temp", Reading: api.SensorReading{Value: 25, Valid: true, Timestamp: time.Now()}}
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "main.return", Target: "sump.return"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+
+	rec := doRequest(t, router, "GET", "/api/devices/sump-dev/delete-preview", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var preview api.DeletePreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+	if preview.Sensors != 2 || preview.Actuators != 1 || preview.Readings != 3 {
+		t.Errorf("Expected 2 sensors, 1 actuator and 3 readings, got %+v", preview)
+	}
+	if len(preview.Tags) != 4 {
+		t.Errorf("Expected device, sensor and actuator tags, got %v", preview.Tags)
+	}
+	if len(preview.Aliases) != 1 || preview.Aliases[0].Alias != "main.return" {
+		t.Errorf("Expected the main.return alias, got %+v", preview.Aliases)
+	}
+	if len(preview.Rules) != 2 {
+		t.Fatalf("Expected 2 affected rules, got %+v", preview.Rules)
+	}
+	if preview.Rules[0].Name != "ato-off" || len(preview.Rules[0].Tags) != 1 || preview.Rules[0].Tags[0] != "sump.float" {
+		t.Errorf("Expected ato-off trimmed to sump.float, got %+v", preview.Rules[0])
+	}
+	if preview.Rules[1].Kind != api.RuleKindLeakResponse || preview.Rules[1].Tags[0] != "main.return" {
+		t.Errorf("Expected leak response via alias, got %+v", preview.Rules[1])
+	}
+
+	// Nothing was deleted
+	if _, err := store.GetDevice(ctx, "sump-dev"); err != nil {
+		t.Errorf("Expected device to survive preview, got %v", err)
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices/missing/delete-preview", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown device, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// reportedBodyLimit bounds how much of an error response is kept for the report
+const reportedBodyLimit = 1024
+
+// ErrorReporter sends failures somewhere they can be seen, such as Sentry
+type ErrorReporter interface {
+	CaptureError(ctx context.Context, err error, tags map[string]string)
+	CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string)
+}
+
+// serverError is a 5xx response, reported with the message the handler sent
+type serverError struct {
+	method string
+	route  string
+	status int
+	msg    string
+}
+
+func (e *serverError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.method, e.route, e.status, e.msg)
+}
+
+// Fingerprint groups reports by endpoint and status rather than by message, which
+// usually embeds IDs, or by stack, which is always this middleware's
+func (e *serverError) Fingerprint() []string {
+	return []string{e.method, e.route, strconv.Itoa(e.status)}
+}
+
+// recoverPanics answers a panicking handler with a 500 rather than dropping the
+// connection, logging the panic and reporting it to h.Errors
+func (h *Handler) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			if h.Errors != nil {
+				h.Errors.CapturePanic(r.Context(), recovered, requestTags(r, http.StatusInternalServerError))
+			}
+			ll := logCtx(r.Context(), "recover")
+			ll.Error().Interface("panic", recovered).Bytes("stack", debug.Stack()).
+				Str("method", r.Method).Str("path", r.URL.Path).Msg("handler panicked")
+			if rec.status == 0 && !rec.hijacked {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// reportErrors reports 5xx responses to h.Errors. Panics are left to recoverPanics.
+func (h *Handler) reportErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Errors == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status >= http.StatusInternalServerError {
+			err := &serverError{
+				method: r.Method,
+				route:  routeTemplate(r),
+				status: rec.status,
+				msg:    strings.TrimSpace(rec.body.String()),
+			}
+			h.Errors.CaptureError(r.Context(), err, requestTags(r, rec.status))
+		}
+	})
+}
+
+func requestTags(r *http.Request, status int) map[string]string {
+	return map[string]string{
+		"method": r.Method,
+		"route":  routeTemplate(r),
+		"status": strconv.Itoa(status),
+	}
+}
+
+// routeTemplate names the matched route, like /api/devices/{id}, so reports for
+// different IDs group together
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
+
+// statusRecorder notes the status and the start of the body of a response. It passes
+// through hijacking for the session WebSocket, and flushing.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     strings.Builder
+	hijacked bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	if s.status >= http.StatusInternalServerError && s.body.Len() < reportedBodyLimit {
+		s.body.Write(p[:min(len(p), reportedBodyLimit-s.body.Len())])
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	s.hijacked = true
+	return hj.Hijack()
+}
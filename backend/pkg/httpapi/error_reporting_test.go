@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type fakeReporter struct {
+	errors []error
+	panics []interface{}
+	tags   []map[string]string
+}
+
+func (f *fakeReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	f.errors = append(f.errors, err)
+	f.tags = append(f.tags, tags)
+}
+
+func (f *fakeReporter) CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string) {
+	f.panics = append(f.panics, recovered)
+	f.tags = append(f.tags, tags)
+}
+
+func TestReportErrors(t *testing.T) {
+	reporter := &fakeReporter{}
+	h := &Handler{Errors: reporter}
+	r := mux.NewRouter()
+	r.HandleFunc("/fail/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Failed to get thing: database is down", http.StatusInternalServerError)
+	})
+	r.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not found", http.StatusNotFound)
+	})
+	r.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	r.Use(h.recoverPanics)
+	r.Use(h.reportErrors)
+
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec.Code
+	}
+
+	if code := serve("/missing"); code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", code)
+	}
+	if len(reporter.errors) != 0 {
+		t.Errorf("Expected a 404 not to be reported, got %v", reporter.errors)
+	}
+
+	if code := serve("/fail/abc"); code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", code)
+	}
+	if len(reporter.errors) != 1 {
+		t.Fatalf("Expected 1 reported error, got %d", len(reporter.errors))
+	}
+	if got := reporter.errors[0].Error(); got != "GET /fail/{id}: 500 Failed to get thing: database is down" {
+		t.Errorf("Expected the reported error to name the route and message, got %q", got)
+	}
+	if reporter.tags[0]["route"] != "/fail/{id}" || reporter.tags[0]["status"] != "500" {
+		t.Errorf("Expected route and status tags, got %v", reporter.tags[0])
+	}
+
+	if code := serve("/panic"); code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 after a panic, got %d", code)
+	}
+	if len(reporter.panics) != 1 || reporter.panics[0] != "boom" {
+		t.Errorf("Expected the panic to be reported, got %v", reporter.panics)
+	}
+	if len(reporter.errors) != 1 {
+		t.Errorf("Expected the panic not to be reported again as a 500, got %d errors", len(reporter.errors))
+	}
+}
+
+func TestRecoverPanics_WithoutReporter(t *testing.T) {
+	router := NewHandler(setupTestDB(t), nil, nil).SetupRouter()
+	router.HandleFunc("/api/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 after a panic, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+)
+
+// driverErrorStatus maps a driver failure to an HTTP status, so clients can tell a bad
+// request from a device that is unplugged or misbehaving
+func driverErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, drivers.ErrNoData):
+		return http.StatusNotFound
+	case errors.Is(err, drivers.ErrUnsupported), errors.Is(err, drivers.ErrRejected):
+		return http.StatusBadRequest
+	case errors.Is(err, drivers.ErrOverBudget):
+		// Nothing was sent; the command may fit once something else switches off
+		return http.StatusConflict
+	case errors.Is(err, drivers.ErrDeviceOffline):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, drivers.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, drivers.ErrAuth):
+		// The device refused our credentials, not the client's
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// updateErrorStatus maps a failure updating a device, sensor or actuator to an HTTP
+// status; a stale version is a conflict the client resolves by reloading
+func updateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storer.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storer.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,31 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"lifesupport/backend/pkg/drivers"
+)
+
+func TestDriverErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{drivers.ErrNoData, http.StatusNotFound},
+		{drivers.ErrUnsupported, http.StatusBadRequest},
+		{drivers.ErrRejected, http.StatusBadRequest},
+		{drivers.ErrDeviceOffline, http.StatusServiceUnavailable},
+		{drivers.ErrTimeout, http.StatusGatewayTimeout},
+		{drivers.ErrAuth, http.StatusBadGateway},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("sending Switch.Set to shellyplus1pm-a: %w", tt.err)
+		if got := driverErrorStatus(err); got != tt.want {
+			t.Errorf("Expected %d for %v, got %d", tt.want, tt.err, got)
+		}
+	}
+}
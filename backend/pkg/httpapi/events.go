@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Events handles GET /api/events, a Server-Sent Events stream of alerts as they fire and
+// are acknowledged, and of workflow status changes, so the dashboard can show toasts
+// without polling. Alert events carry their change feed position as the event ID, so a
+// browser which reconnects resumes after the last one it saw through Last-Event-ID.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ctx := r.Context()
+
+	var cursor int64
+	var err error
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		cursor, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || cursor < 0 {
+			http.Error(w, "Invalid Last-Event-ID header", http.StatusBadRequest)
+			return
+		}
+	} else if cursor, err = h.Store.LatestChangeSeq(ctx); err != nil {
+		http.Error(w, "Failed to read the change feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Workflows running or finished before the client connected are not announced
+	var workflows map[string]api.WorkflowStatus
+	if h.TemporalClient != nil {
+		workflows = map[string]api.WorkflowStatus{}
+		if list, err := h.listWorkflows(ctx); err == nil {
+			workflowTransitions(workflows, list)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	interval := h.StreamInterval
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+	tail := &changeTail{store: h.Store, cursor: cursor}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ll := logCtx(ctx, "events")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := writeAlertEvents(ctx, w, tail); err != nil {
+			ll.Warn().Err(err).Int64("cursor", tail.cursor).Msg("failed to send alert events")
+		}
+		if workflows != nil {
+			list, err := h.listWorkflows(ctx)
+			if err != nil {
+				ll.Warn().Err(err).Msg("failed to list workflows")
+			}
+			for _, info := range workflowTransitions(workflows, list) {
+				writeEvent(w, "", api.EventWorkflowStatus, info)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeAlertEvents writes the alerts and acknowledgements in the change feed after the
+// tail's cursor
+func writeAlertEvents(ctx context.Context, w http.ResponseWriter, tail *changeTail) error {
+	return tail.each(ctx, func(change *api.ChangeEvent) error {
+		if change.Type != api.ChangeAlertFired && change.Type != api.ChangeAlertAcknowledged {
+			return nil
+		}
+		return writeEvent(w, strconv.FormatInt(change.Seq, 10), string(change.Type), change.Data)
+	})
+}
+
+// workflowTransitions returns the workflows in list which are new or whose status
+// differs from known, and records their status in known
+func workflowTransitions(known map[string]api.WorkflowStatus, list []api.WorkflowInfo) []api.WorkflowInfo {
+	var changed []api.WorkflowInfo
+	for _, info := range list {
+		key := info.WorkflowID + "/" + info.RunID
+		if status, ok := known[key]; ok && status == info.Status {
+			continue
+		}
+		known[key] = info.Status
+		changed = append(changed, info)
+	}
+	return changed
+}
+
+// writeEvent writes one Server-Sent Event with data encoded as JSON. Events without an
+// id leave the browser's last event ID as it was.
+func writeEvent(w http.ResponseWriter, id, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event, err)
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
@@ -0,0 +1,193 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/changefeed"
+	"lifesupport/backend/pkg/notify"
+)
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// openEvents connects to the event stream, returning a channel of its events
+func openEvents(t *testing.T, server *httptest.Server, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+
+	events := make(chan sseEvent, 10)
+	go func() {
+		defer resp.Body.Close()
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				ev.id = value
+			case "event":
+				ev.event = value
+			case "data":
+				ev.data = value
+			case "":
+				events <- ev
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+		return sseEvent{}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	alerts := changefeed.NewAlertRecorder(store)
+	// Alerts fired before the client connects are not replayed
+	alerts.Notify(ctx, &api.Alert{ID: "old", Title: "Old"})
+
+	h := NewHandler(store, nil, nil)
+	h.StreamInterval = 10 * time.Millisecond
+	server := httptest.NewServer(h.SetupRouter())
+	t.Cleanup(server.Close)
+	events := openEvents(t, server, "")
+
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 20, Valid: true, Timestamp: time.Now()}})
+	alerts.Notify(ctx, &api.Alert{ID: "leak-1", Severity: api.AlertSeverityCritical, Title: "Leak detected"})
+
+	fired := nextEvent(t, events)
+	var alert api.Alert
+	if fired.event != string(api.ChangeAlertFired) || json.Unmarshal([]byte(fired.data), &alert) != nil || alert.ID != "leak-1" {
+		t.Fatalf("Expected the leak alert, got %+v", fired)
+	}
+
+	if rec := doRequest(t, h.SetupRouter(), "POST", "/api/alerts/leak-1/ack", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 acknowledging without a user, got %d", rec.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/alerts/leak-1/ack", nil)
+	req.Header.Set(actorHeader, "alice")
+	rec := httptest.NewRecorder()
+	h.SetupRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	acked := nextEvent(t, events)
+	var ack api.AlertAck
+	if acked.event != string(api.ChangeAlertAcknowledged) || json.Unmarshal([]byte(acked.data), &ack) != nil || ack.AlertID != "leak-1" || ack.User != "alice" {
+		t.Fatalf("Expected alice's acknowledgement, got %+v", acked)
+	}
+
+	// Reconnecting resumes after the last event seen
+	resumed := nextEvent(t, openEvents(t, server, fired.id))
+	if resumed.id != acked.id || resumed.event != string(api.ChangeAlertAcknowledged) {
+		t.Errorf("Expected the acknowledgement again after resuming, got %+v", resumed)
+	}
+
+	req = httptest.NewRequest("GET", "/api/events", nil)
+	req.Header.Set("Last-Event-ID", "soon")
+	rec = httptest.NewRecorder()
+	h.SetupRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid Last-Event-ID, got %d", rec.Code)
+	}
+}
+
+func TestSlackInteraction(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	h := NewHandler(store, nil, nil)
+	payload := `{"type":"block_actions","user":{"id":"U1","username":"alice"},"actions":[{"action_id":"ack_alert","value":"leak-1"}]}`
+	body := url.Values{"payload": {payload}}.Encode()
+	interact := func(secret string) *httptest.ResponseRecorder {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte("v0:" + ts + ":" + body))
+		req := httptest.NewRequest("POST", "/api/slack/interactions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+		rec := httptest.NewRecorder()
+		h.SetupRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := interact("secret"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without Slack configured, got %d", rec.Code)
+	}
+
+	h.SlackInteractions = notify.NewSlack("").InteractionHandler("secret", changefeed.NewAlertRecorder(store).Ack)
+	if rec := interact("wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a bad signature, got %d", rec.Code)
+	}
+	if rec := interact("secret"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	changes, err := store.ListChanges(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	var ack api.AlertAck
+	if len(changes) != 1 || changes[0].Type != api.ChangeAlertAcknowledged || json.Unmarshal(changes[0].Data, &ack) != nil {
+		t.Fatalf("Expected one acknowledgement, got %+v", changes)
+	}
+	if ack.AlertID != "leak-1" || ack.User != "alice" || ack.Channel != "slack" {
+		t.Errorf("Expected alice's acknowledgement from slack, got %+v", ack)
+	}
+}
+
+func TestWorkflowTransitions(t *testing.T) {
+	known := map[string]api.WorkflowStatus{}
+	running := []api.WorkflowInfo{{WorkflowID: "discovery-1", RunID: "a", Status: api.WorkflowStatusInProgress}}
+	if got := workflowTransitions(known, running); len(got) != 1 {
+		t.Errorf("Expected a new workflow to be announced, got %+v", got)
+	}
+	if got := workflowTransitions(known, running); len(got) != 0 {
+		t.Errorf("Expected no announcement while the status is unchanged, got %+v", got)
+	}
+	done := []api.WorkflowInfo{
+		{WorkflowID: "discovery-1", RunID: "a", Status: api.WorkflowStatusSuccess},
+		{WorkflowID: "discovery-2", RunID: "b", Status: api.WorkflowStatusInProgress},
+	}
+	got := workflowTransitions(known, done)
+	if len(got) != 2 || got[0].Status != api.WorkflowStatusSuccess || got[1].WorkflowID != "discovery-2" {
+		t.Errorf("Expected the completion and the new workflow, got %+v", got)
+	}
+}
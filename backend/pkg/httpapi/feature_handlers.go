@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// clientIDHeader identifies the dashboard installation a request comes from, so a
+// gradually rolled out feature stays on or off for it between requests
+const clientIDHeader = "X-Client-ID"
+
+// FeatureEnabled reports whether the named feature is on for the client making r. A
+// feature without a flag is off, as is every feature while flags can't be read.
+func (h *Handler) FeatureEnabled(r *http.Request, name string) bool {
+	flag, err := h.Store.GetFeatureFlag(r.Context(), name)
+	if err != nil {
+		if !errors.Is(err, storer.ErrNotFound) {
+			ll := logCtx(r.Context(), "features")
+			ll.Warn().Err(err).Str("flag", name).Msg("unable to read feature flag")
+		}
+		return false
+	}
+	return flag.EnabledFor(featureClient(r))
+}
+
+// featureClient identifies the client of r for feature rollouts by its X-Client-ID
+// header, falling back to its address
+func featureClient(r *http.Request) string {
+	if id := r.Header.Get(clientIDHeader); id != "" {
+		return id
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// GetFeatures handles GET /api/features, reporting which features are on for the
+// calling client so the dashboard can show or hide experimental pages
+func (h *Handler) GetFeatures(w http.ResponseWriter, r *http.Request) {
+	features, err := h.features(r)
+	if err != nil {
+		http.Error(w, "Failed to list feature flags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(features)
+}
+
+// features reports which feature flags are on for the client making r
+func (h *Handler) features(r *http.Request) (map[string]bool, error) {
+	flags, err := h.Store.ListFeatureFlags(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	client := featureClient(r)
+	features := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		features[flag.Name] = flag.EnabledFor(client)
+	}
+	return features, nil
+}
+
+// ListFeatureFlags handles GET /api/admin/features. It requires the AdminToken as a
+// bearer token.
+func (h *Handler) ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	flags, err := h.Store.ListFeatureFlags(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list feature flags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if flags == nil {
+		flags = []*api.FeatureFlag{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
+
+// SetFeatureFlag handles PUT /api/admin/features/{name}, creating the flag or changing
+// its rollout. It requires the AdminToken as a bearer token.
+func (h *Handler) SetFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var flag api.FeatureFlag
+	if err := json.NewDecoder(r.Body).Decode(&flag); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	flag.Name = mux.Vars(r)["name"]
+	if flag.Percent < 0 || flag.Percent > 100 {
+		http.Error(w, "percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetFeatureFlag(r.Context(), &flag); err != nil {
+		http.Error(w, "Failed to set feature flag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flag)
+}
+
+// DeleteFeatureFlag handles DELETE /api/admin/features/{name}, turning the feature off
+// for everyone. It requires the AdminToken as a bearer token.
+func (h *Handler) DeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	if err := h.Store.DeleteFeatureFlag(r.Context(), mux.Vars(r)["name"]); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Feature flag not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete feature flag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAdminConfig handles GET /api/admin/config, the server's effective configuration
+// with secrets redacted. It requires the AdminToken as a bearer token.
+func (h *Handler) GetAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if h.Config == nil {
+		http.Error(w, "Configuration not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Config)
+}
@@ -0,0 +1,136 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	admin := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := doRequest(t, router, "PUT", "/api/admin/features/rules-engine", api.FeatureFlag{Percent: 100}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	if rec := admin("PUT", "/api/admin/features/rules-engine", api.FeatureFlag{Percent: 101}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for percent over 100, got %d", rec.Code)
+	}
+	rec := admin("PUT", "/api/admin/features/rules-engine", api.FeatureFlag{Description: "Control rules", Percent: 100})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := admin("PUT", "/api/admin/features/new-dashboard", api.FeatureFlag{Percent: 0}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() {
+		admin("DELETE", "/api/admin/features/rules-engine", nil)
+		admin("DELETE", "/api/admin/features/new-dashboard", nil)
+	})
+
+	var flags []*api.FeatureFlag
+	rec = admin("GET", "/api/admin/features", nil)
+	if err := json.NewDecoder(rec.Body).Decode(&flags); err != nil {
+		t.Fatalf("Failed to decode flags: %v", err)
+	}
+	if len(flags) != 2 || flags[1].Name != "rules-engine" || flags[1].Description != "Control rules" || flags[1].UpdatedAt.IsZero() {
+		t.Errorf("Expected both flags ordered by name, got %+v", flags)
+	}
+
+	var features map[string]bool
+	rec = doRequest(t, router, "GET", "/api/features", nil)
+	if err := json.NewDecoder(rec.Body).Decode(&features); err != nil {
+		t.Fatalf("Failed to decode features: %v", err)
+	}
+	if !features["rules-engine"] || features["new-dashboard"] {
+		t.Errorf("Expected only rules-engine on, got %v", features)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if !h.FeatureEnabled(req, "rules-engine") || h.FeatureEnabled(req, "missing") {
+		t.Error("Expected rules-engine to be enabled and a feature without a flag not to be")
+	}
+
+	if rec := admin("DELETE", "/api/admin/features/rules-engine", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := admin("DELETE", "/api/admin/features/rules-engine", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting again, got %d", rec.Code)
+	}
+}
+
+func TestFeatureFlag_GradualRollout(t *testing.T) {
+	flag := api.FeatureFlag{Name: "rules-engine", Percent: 25}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if flag.EnabledFor(client) {
+			enabled++
+		}
+		if flag.EnabledFor(client) != flag.EnabledFor(client) {
+			t.Fatalf("Expected %s to get the same answer every time", client)
+		}
+	}
+	if enabled < 150 || enabled > 350 {
+		t.Errorf("Expected about a quarter of 1000 clients enabled, got %d", enabled)
+	}
+
+	wider := flag
+	wider.Percent = 50
+	for i := 0; i < 1000; i++ {
+		client := fmt.Sprintf("client-%d", i)
+		if flag.EnabledFor(client) && !wider.EnabledFor(client) {
+			t.Fatalf("Expected raising the rollout to keep %s enabled", client)
+		}
+	}
+}
+
+func TestGetAdminConfig(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/admin/config", nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := get(); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a config, got %d", rec.Code)
+	}
+
+	h.Config = &api.AdminConfig{Command: "http", Flags: map[string]string{"port": "8080"}}
+	rec := get()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var cfg api.AdminConfig
+	if err := json.NewDecoder(rec.Body).Decode(&cfg); err != nil {
+		t.Fatalf("Failed to decode config: %v", err)
+	}
+	if cfg.Command != "http" || cfg.Flags["port"] != "8080" {
+		t.Errorf("Expected the configured flags, got %+v", cfg)
+	}
+}
@@ -0,0 +1,240 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// groupErrorStatus maps a failure writing a group to an HTTP status
+func groupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storer.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storer.ErrAlreadyExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateGroup handles POST /api/groups
+func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var g api.Group
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := g.Validate(); err != nil {
+		http.Error(w, "Invalid group: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.CreateGroup(r.Context(), &g); err != nil {
+		http.Error(w, "Failed to create group: "+err.Error(), groupErrorStatus(err))
+		return
+	}
+	created, err := h.Store.GetGroup(r.Context(), g.ID)
+	if err != nil {
+		http.Error(w, "Failed to get group: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetGroup handles GET /api/groups/{id}
+func (h *Handler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.getGroup(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g)
+}
+
+// UpdateGroup handles PUT /api/groups/{id}, replacing the group's devices along with its
+// other fields
+func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	var g api.Group
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	g.ID = mux.Vars(r)["id"]
+	if err := g.Validate(); err != nil {
+		http.Error(w, "Invalid group: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.UpdateGroup(r.Context(), &g); err != nil {
+		http.Error(w, "Failed to update group: "+err.Error(), groupErrorStatus(err))
+		return
+	}
+	updated, err := h.Store.GetGroup(r.Context(), g.ID)
+	if err != nil {
+		http.Error(w, "Failed to get group: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteGroup handles DELETE /api/groups/{id}; the group's devices are unaffected
+func (h *Handler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := h.Store.DeleteGroup(r.Context(), mux.Vars(r)["id"]); err != nil {
+		http.Error(w, "Failed to delete group: "+err.Error(), groupErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListGroups handles GET /api/groups
+func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.Store.ListGroups(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list groups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if groups == nil {
+		groups = []*api.Group{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(groups)
+}
+
+// AddGroupDevice handles PUT /api/groups/{id}/devices/{device_id}
+func (h *Handler) AddGroupDevice(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if err := h.Store.AddGroupDevice(r.Context(), params["id"], params["device_id"]); err != nil {
+		http.Error(w, "Failed to add group device: "+err.Error(), groupErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveGroupDevice handles DELETE /api/groups/{id}/devices/{device_id}
+func (h *Handler) RemoveGroupDevice(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if err := h.Store.RemoveGroupDevice(r.Context(), params["id"], params["device_id"]); err != nil {
+		http.Error(w, "Failed to remove group device: "+err.Error(), groupErrorStatus(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupReadings handles GET /api/groups/{id}/readings, returning the readings of every
+// sensor on the group's devices, newest first
+func (h *Handler) GetGroupReadings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filters storer.SensorReadingFilters
+	for name, dst := range map[string]**time.Time{"start_time": &filters.StartTime, "end_time": &filters.EndTime} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = limit
+	}
+	g, ok := h.getGroup(w, r)
+	if !ok {
+		return
+	}
+
+	readings, err := h.groupReadings(r.Context(), g, filters)
+	if err != nil {
+		http.Error(w, "Failed to get sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.applyTargets(r.Context(), readings); err != nil {
+		http.Error(w, "Failed to get sensor targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(readings)
+}
+
+// groupReadings merges the readings of g's devices matching filters, newest first, up to
+// filters.Limit
+func (h *Handler) groupReadings(ctx context.Context, g *api.Group, filters storer.SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	readings := []*api.ReadingRecord{}
+	for _, deviceID := range g.DeviceIDs {
+		filters.DeviceID = deviceID
+		recs, err := h.Store.GetSensorReadings(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, recs...)
+	}
+	sort.SliceStable(readings, func(i, j int) bool {
+		a, b := storer.KeyOf(readings[i]), storer.KeyOf(readings[j])
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.ID > b.ID
+	})
+	if filters.Limit > 0 && len(readings) > filters.Limit {
+		readings = readings[:filters.Limit]
+	}
+	return readings, nil
+}
+
+// GetGroupActuators handles GET /api/groups/{id}/actuators, returning the actuators of
+// the group's devices
+func (h *Handler) GetGroupActuators(w http.ResponseWriter, r *http.Request) {
+	g, ok := h.getGroup(w, r)
+	if !ok {
+		return
+	}
+
+	actuators := []*api.Actuator{}
+	for _, deviceID := range g.DeviceIDs {
+		devActuators, err := h.Store.ListActuatorsByDeviceID(r.Context(), deviceID)
+		if err != nil {
+			http.Error(w, "Failed to list actuators: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		actuators = append(actuators, devActuators...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actuators)
+}
+
+// getGroup gets the group named by the request's id, writing the error response and
+// returning false if that fails
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request) (*api.Group, bool) {
+	g, err := h.Store.GetGroup(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Group not found: "+err.Error(), http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Failed to get group: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return g, true
+}
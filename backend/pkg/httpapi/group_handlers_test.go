@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGroups(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	for _, id := range []string{"bed-pump", "bed-probe", "qt-heater"} {
+		dev := api.Device{
+			ID:        id,
+			Driver:    api.DriverShelly,
+			Name:      id,
+			Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+			Actuators: []*api.Actuator{{ID: "relay", Name: "Relay", ActuatorType: api.ActuatorTypeRelay}},
+		}
+		if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	if rec := doRequest(t, router, "POST", "/api/groups", api.Group{ID: "grow-bed-1"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a name, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "POST", "/api/groups", api.Group{ID: "bad", Name: "Bad", DeviceIDs: []string{"missing"}}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing device, got %d", rec.Code)
+	}
+	bed := api.Group{ID: "grow-bed-1", Name: "Grow Bed 1", Metadata: map[string]string{"media": "clay"}, DeviceIDs: []string{"bed-pump"}}
+	rec := doRequest(t, router, "POST", "/api/groups", bed)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(t, router, "POST", "/api/groups", bed); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a duplicate group, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "PUT", "/api/groups/grow-bed-1/devices/bed-probe", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, router, "GET", "/api/groups/grow-bed-1", nil)
+	var got api.Group
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode group: %v", err)
+	}
+	if len(got.DeviceIDs) != 2 || got.DeviceIDs[0] != "bed-probe" || got.Metadata["media"] != "clay" {
+		t.Errorf("Expected both bed devices, got %+v", got)
+	}
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var recs []*api.ReadingRecord
+	for i, id := range []string{"bed-pump", "bed-probe", "qt-heater"} {
+		recs = append(recs, &api.ReadingRecord{DeviceID: id, SensorID: "temp",
+			Reading: api.SensorReading{Value: float64(20 + i), Valid: true, Timestamp: start.Add(time.Duration(i) * time.Minute)}})
+	}
+	store.StoreSensorReadings(t.Context(), recs)
+
+	rec = doRequest(t, router, "GET", "/api/groups/grow-bed-1/readings", nil)
+	var readings []*api.ReadingRecord
+	if err := json.NewDecoder(rec.Body).Decode(&readings); err != nil {
+		t.Fatalf("Failed to decode readings: %v", err)
+	}
+	if len(readings) != 2 || readings[0].DeviceID != "bed-probe" || readings[1].DeviceID != "bed-pump" {
+		t.Errorf("Expected the bed's readings newest first, got %+v", readings)
+	}
+	rec = doRequest(t, router, "GET", "/api/groups/grow-bed-1/readings?limit=1", nil)
+	readings = nil
+	json.NewDecoder(rec.Body).Decode(&readings)
+	if len(readings) != 1 || readings[0].DeviceID != "bed-probe" {
+		t.Errorf("Expected only the newest reading, got %+v", readings)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/groups/grow-bed-1/actuators", nil)
+	var actuators []*api.Actuator
+	if err := json.NewDecoder(rec.Body).Decode(&actuators); err != nil {
+		t.Fatalf("Failed to decode actuators: %v", err)
+	}
+	if len(actuators) != 2 {
+		t.Errorf("Expected the bed's 2 actuators, got %+v", actuators)
+	}
+
+	got.DeviceIDs = []string{"qt-heater"}
+	if rec := doRequest(t, router, "PUT", "/api/groups/grow-bed-1", got); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(t, router, "DELETE", "/api/groups/grow-bed-1/devices/bed-pump", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 removing a replaced member, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "DELETE", "/api/groups/grow-bed-1", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/groups/grow-bed-1/readings", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted group, got %d", rec.Code)
+	}
+}
@@ -2,26 +2,87 @@ package httpapi
 
 import (
 	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"go.temporal.io/sdk/client"
 
 	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/blob"
+	"lifesupport/backend/pkg/control"
 	"lifesupport/backend/pkg/drivers"
 	"lifesupport/backend/pkg/storer"
 )
 
 // Handler holds the dependencies for HTTP handlers
 type Handler struct {
-	Store          *storer.Storer
+	Store          storer.Interface
 	TemporalClient client.Client
 	Drivers        *drivers.Manager
+	StatusPage     *StatusPageConfig
+	// ReadCacheTTL is how long responses from read-heavy endpoints are reused; zero
+	// disables server-side caching but ETags are still served
+	ReadCacheTTL time.Duration
+	// Rules lists the configured control rules, so destructive changes can report what
+	// depends on the resources they remove
+	Rules []api.RuleRef
+	// CompositeRules are served with their conditions evaluated at /api/rules/composite
+	CompositeRules []api.CompositeRule
+	// ActuatorGroups are started and stopped together at /api/actuator-groups, through
+	// Commander
+	ActuatorGroups []api.ActuatorGroup
+	// Scenes are activated at /api/scenes through Commander, after checking each
+	// actuator's status through Readings
+	Scenes []api.Scene
+	// Readings supplies the latest readings the composite rule views evaluate against;
+	// the views are unavailable when it is nil
+	Readings control.ReadingSource
+	// Commander carries out actuator commands sent over the session WebSocket; sessions
+	// reject commands when it is nil
+	Commander Commander
+	// StreamInterval is how often the live stream WebSocket and the event stream read
+	// the change feed; defaults to a second
+	StreamInterval time.Duration
+	// Workers reports worker presence for /api/workers, which is unavailable when it is
+	// nil
+	Workers WorkerTracker
+	// Credentials seals device credentials; setting them is unavailable when it is nil
+	Credentials CredentialVault
+	// Assets keeps the images attached to devices and subsystems; uploads are
+	// unavailable when it is nil
+	Assets blob.Bucket
+	// AdminToken authorizes administrative changes such as maintenance mode, which are
+	// unavailable when it is empty
+	AdminToken string
+	// Config is the sanitized effective configuration served at /api/admin/config, which
+	// is unavailable when it is nil
+	Config *api.AdminConfig
+	// Errors receives 5xx responses and handler panics; nothing is reported when it is
+	// nil
+	Errors ErrorReporter
+	// SlackInteractions takes the acknowledge button clicks of Slack alerts; they are
+	// refused when it is nil
+	SlackInteractions http.Handler
+
+	statusPageCache statusPageCache
+	readCache       readCache
+
+	// groups holds each actuator group's controller, and so its rotation
+	groupsLock sync.Mutex
+	groups     map[string]*control.GroupController
+
+	// openAPI is the generated OpenAPI document, built on first request
+	openAPIOnce sync.Once
+	openAPI     []byte
+	openAPIErr  error
 }
 
 // NewHandler creates a new Handler instance
-func NewHandler(store *storer.Storer, temporalClient client.Client, drivers *drivers.Manager) *Handler {
+func NewHandler(store storer.Interface, temporalClient client.Client, drivers *drivers.Manager) *Handler {
 	return &Handler{
 		Store:          store,
 		TemporalClient: temporalClient,
@@ -43,20 +104,121 @@ func (h *Handler) CreateDevice(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create device: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.resolveBrokenReferences(ctx, deviceTags(&dev))
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(dev)
 }
 
+// maxDeviceBatch bounds how many devices one batch request may create
+const maxDeviceBatch = 1000
+
+// CreateDevices handles POST /api/devices:batch, creating a JSON array of devices in one
+// transaction and reporting which were created; one device failing does not stop the rest
+func (h *Handler) CreateDevices(w http.ResponseWriter, r *http.Request) {
+	var devs []*api.Device
+	if err := json.NewDecoder(r.Body).Decode(&devs); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(devs) > maxDeviceBatch {
+		http.Error(w, fmt.Sprintf("Too many devices: at most %d per batch", maxDeviceBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+	for i, dev := range devs {
+		if dev == nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: device %d is null", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	errs, err := h.Store.CreateDevices(ctx, devs)
+	if err != nil {
+		http.Error(w, "Failed to create devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	results := make([]api.DeviceBatchResult, len(devs))
+	var tags []string
+	for i, dev := range devs {
+		results[i].ID = dev.ID
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+			continue
+		}
+		results[i].Device = dev
+		tags = append(tags, deviceTags(dev)...)
+	}
+	h.resolveBrokenReferences(ctx, tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// PatchDevices handles PATCH /api/devices?selector=..., applying a JSON metadata and tag
+// patch to every device the selector matches in one transaction and reporting which
+// were patched; one device failing does not stop the rest
+func (h *Handler) PatchDevices(w http.ResponseWriter, r *http.Request) {
+	sel, err := api.ParseDeviceSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, "Invalid selector: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var patch api.DevicePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		http.Error(w, "Invalid patch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	devices, errs, err := h.Store.PatchDevices(ctx, sel, &patch)
+	if err != nil {
+		http.Error(w, "Failed to patch devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	results := make([]api.DeviceBatchResult, len(devices))
+	var tags []string
+	for i, dev := range devices {
+		results[i].ID = dev.ID
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+			continue
+		}
+		results[i].Device = dev
+		tags = append(tags, dev.Tags...)
+	}
+	h.resolveBrokenReferences(ctx, tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// GetDevice handles GET /api/devices/{id}. With as_of, the device is as it was then,
+// reconstructed from the audit log.
 func (h *Handler) GetDevice(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
-	dev, err := h.Store.GetDevice(ctx, id)
+	var dev *api.Device
+	if asOf != nil {
+		dev, err = h.deviceAt(ctx, id, *asOf)
+	} else {
+		dev, err = h.Store.GetDevice(ctx, id)
+	}
 	if err != nil {
-		http.Error(w, "Device not found: "+err.Error(), http.StatusNotFound)
+		http.Error(w, "Device not found: "+err.Error(), historyErrorStatus(err, asOf))
 		return
 	}
 
@@ -75,12 +237,62 @@ func (h *Handler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
 	}
 
 	dev.ID = id
+	if dev.Version == 0 {
+		http.Error(w, "Missing version: updates must carry the version they were based on", http.StatusPreconditionRequired)
+		return
+	}
 
 	ctx := r.Context()
 	if err := h.Store.UpdateDevice(ctx, &dev); err != nil {
-		http.Error(w, "Failed to update device: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to update device: "+err.Error(), updateErrorStatus(err))
+		return
+	}
+	h.resolveBrokenReferences(ctx, dev.Tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dev)
+}
+
+// mergePatchType is the media type of a JSON Merge Patch (RFC 7396). Its schema is that
+// of the entity it patches with every field optional.
+const mergePatchType = "application/merge-patch+json"
+
+// acceptMergePatch checks a PATCH of a single entity is a merge patch, answering 415
+// Unsupported Media Type if not. Plain JSON is accepted too, for clients which can't
+// set the type, but nothing else is: a JSON Patch (RFC 6902) must not be read as one.
+func acceptMergePatch(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == mergePatchType || mediaType == "application/json" {
+		return true
+	}
+	w.Header().Set("Accept-Patch", mergePatchType)
+	http.Error(w, "Unsupported Content-Type: patches must be "+mergePatchType+" or application/json", http.StatusUnsupportedMediaType)
+	return false
+}
+
+// PatchDevice handles PATCH /api/devices/{id}, applying a JSON Merge Patch (RFC 7396):
+// the fields the patch names are changed and the rest are left as they are. Unlike PUT,
+// the version is optional; when given, the patch is refused if the device has changed.
+func (h *Handler) PatchDevice(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	if !acceptMergePatch(w, r) {
+		return
+	}
+	var update api.DeviceMergePatch
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid merge patch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	dev, err := h.Store.PatchDevice(ctx, id, &update)
+	if err != nil {
+		http.Error(w, "Failed to patch device: "+err.Error(), updateErrorStatus(err))
 		return
 	}
+	h.resolveBrokenReferences(ctx, dev.Tags)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(dev)
@@ -91,17 +303,57 @@ func (h *Handler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
 	id := params["id"]
 
 	ctx := r.Context()
+	// Look the device up first so rules depending on its tags can be disabled once it is gone
+	dev, err := h.Store.GetDevice(ctx, id)
+	if err != nil {
+		http.Error(w, "Failed to delete device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Deleting the device deletes its asset records, so find their images first
+	attached, err := h.Store.ListAssets(ctx, storer.AssetFilters{DeviceID: id})
+	if err != nil {
+		http.Error(w, "Failed to delete device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err := h.Store.DeleteDevice(ctx, id); err != nil {
 		http.Error(w, "Failed to delete device: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.recordBrokenReferences(ctx, "device "+id, deviceTags(dev))
+	for _, asset := range attached {
+		h.deleteAssetBlobs(ctx, asset.ID)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *Handler) ListDevices(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	devices, err := h.Store.ListDevices(ctx)
+	page, paged, err := parsePage(r)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paged {
+		devices, next, err := h.Store.ListDevicesPage(ctx, page)
+		if err != nil {
+			http.Error(w, "Failed to list devices: "+err.Error(), pageErrorStatus(err))
+			return
+		}
+		if devices == nil {
+			devices = []*api.Device{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.DevicePage{Devices: devices, NextCursor: next})
+		return
+	}
+
+	var devices []*api.Device
+	if len(page.Metadata) > 0 {
+		devices, _, err = h.Store.ListDevicesPage(ctx, page)
+	} else {
+		devices, err = h.Store.ListDevices(ctx)
+	}
 	if err != nil {
 		http.Error(w, "Failed to list devices: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -125,21 +377,35 @@ func (h *Handler) CreateSensor(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create sensor: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.resolveBrokenReferences(ctx, sensor.Tags)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(sensor)
 }
 
+// GetSensor handles GET /api/sensors/{device_id}/{sensor_id}. With as_of, the sensor is
+// as it was then, reconstructed from the audit log.
 func (h *Handler) GetSensor(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	deviceID := params["device_id"]
 	sensorID := params["sensor_id"]
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
-	sensor, err := h.Store.GetSensor(ctx, deviceID, sensorID)
+	sensor := &api.Sensor{}
+	if asOf != nil {
+		err = h.entityAt(ctx, api.AuditEntitySensor, deviceID+"/"+sensorID, *asOf, sensor)
+	} else {
+		sensor, err = h.Store.GetSensor(ctx, deviceID, sensorID)
+	}
 	if err != nil {
-		http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+		http.Error(w, "Sensor not found: "+err.Error(), historyErrorStatus(err, asOf))
 		return
 	}
 
@@ -160,12 +426,45 @@ func (h *Handler) UpdateSensor(w http.ResponseWriter, r *http.Request) {
 
 	sensor.DeviceID = deviceID
 	sensor.ID = sensorID
+	if sensor.Version == 0 {
+		http.Error(w, "Missing version: updates must carry the version they were based on", http.StatusPreconditionRequired)
+		return
+	}
 
 	ctx := r.Context()
 	if err := h.Store.UpdateSensor(ctx, &sensor); err != nil {
-		http.Error(w, "Failed to update sensor: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to update sensor: "+err.Error(), updateErrorStatus(err))
 		return
 	}
+	h.resolveBrokenReferences(ctx, sensor.Tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
+// PatchSensor handles PATCH /api/sensors/{device_id}/{sensor_id}, applying a
+// JSON Merge Patch as PatchDevice does
+func (h *Handler) PatchSensor(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID := params["device_id"]
+	sensorID := params["sensor_id"]
+
+	if !acceptMergePatch(w, r) {
+		return
+	}
+	var update api.SensorMergePatch
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid merge patch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	sensor, err := h.Store.PatchSensor(ctx, deviceID, sensorID, &update)
+	if err != nil {
+		http.Error(w, "Failed to patch sensor: "+err.Error(), updateErrorStatus(err))
+		return
+	}
+	h.resolveBrokenReferences(ctx, sensor.Tags)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sensor)
@@ -177,10 +476,17 @@ func (h *Handler) DeleteSensor(w http.ResponseWriter, r *http.Request) {
 	sensorID := params["sensor_id"]
 
 	ctx := r.Context()
+	// Look the sensor up first so rules depending on its tags can be disabled once it is gone
+	sensor, err := h.Store.GetSensor(ctx, deviceID, sensorID)
+	if err != nil {
+		http.Error(w, "Failed to delete sensor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err := h.Store.DeleteSensor(ctx, deviceID, sensorID); err != nil {
 		http.Error(w, "Failed to delete sensor: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.recordBrokenReferences(ctx, "sensor "+deviceID+"/"+sensorID, sensor.Tags)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -191,12 +497,33 @@ func (h *Handler) ListSensors(w http.ResponseWriter, r *http.Request) {
 	// Check if device_id query parameter is provided
 	deviceID := r.URL.Query().Get("device_id")
 
+	page, paged, err := parsePage(r)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paged {
+		sensors, next, err := h.Store.ListSensorsPage(ctx, deviceID, page)
+		if err != nil {
+			http.Error(w, "Failed to list sensors: "+err.Error(), pageErrorStatus(err))
+			return
+		}
+		if sensors == nil {
+			sensors = []*api.Sensor{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.SensorPage{Sensors: sensors, NextCursor: next})
+		return
+	}
+
 	var sensors []*api.Sensor
-	var err error
 
-	if deviceID != "" {
+	switch {
+	case len(page.Metadata) > 0:
+		sensors, _, err = h.Store.ListSensorsPage(ctx, deviceID, page)
+	case deviceID != "":
 		sensors, err = h.Store.ListSensorsByDeviceID(ctx, deviceID)
-	} else {
+	default:
 		sensors, err = h.Store.ListSensors(ctx)
 	}
 
@@ -238,21 +565,35 @@ func (h *Handler) CreateActuator(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to create actuator: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.resolveBrokenReferences(ctx, actuator.Tags)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(actuator)
 }
 
+// GetActuator handles GET /api/actuators/{device_id}/{actuator_id}. With as_of, the
+// actuator is as it was then, reconstructed from the audit log.
 func (h *Handler) GetActuator(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	deviceID := params["device_id"]
 	actuatorID := params["actuator_id"]
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
-	actuator, err := h.Store.GetActuator(ctx, deviceID, actuatorID)
+	actuator := &api.Actuator{}
+	if asOf != nil {
+		err = h.entityAt(ctx, api.AuditEntityActuator, deviceID+"/"+actuatorID, *asOf, actuator)
+	} else {
+		actuator, err = h.Store.GetActuator(ctx, deviceID, actuatorID)
+	}
 	if err != nil {
-		http.Error(w, "Actuator not found: "+err.Error(), http.StatusNotFound)
+		http.Error(w, "Actuator not found: "+err.Error(), historyErrorStatus(err, asOf))
 		return
 	}
 
@@ -273,12 +614,45 @@ func (h *Handler) UpdateActuator(w http.ResponseWriter, r *http.Request) {
 
 	actuator.DeviceID = deviceID
 	actuator.ID = actuatorID
+	if actuator.Version == 0 {
+		http.Error(w, "Missing version: updates must carry the version they were based on", http.StatusPreconditionRequired)
+		return
+	}
 
 	ctx := r.Context()
 	if err := h.Store.UpdateActuator(ctx, &actuator); err != nil {
-		http.Error(w, "Failed to update actuator: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to update actuator: "+err.Error(), updateErrorStatus(err))
+		return
+	}
+	h.resolveBrokenReferences(ctx, actuator.Tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(actuator)
+}
+
+// PatchActuator handles PATCH /api/actuators/{device_id}/{actuator_id}, applying a
+// JSON Merge Patch as PatchDevice does
+func (h *Handler) PatchActuator(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID := params["device_id"]
+	actuatorID := params["actuator_id"]
+
+	if !acceptMergePatch(w, r) {
+		return
+	}
+	var update api.ActuatorMergePatch
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid merge patch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	actuator, err := h.Store.PatchActuator(ctx, deviceID, actuatorID, &update)
+	if err != nil {
+		http.Error(w, "Failed to patch actuator: "+err.Error(), updateErrorStatus(err))
 		return
 	}
+	h.resolveBrokenReferences(ctx, actuator.Tags)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(actuator)
@@ -290,10 +664,17 @@ func (h *Handler) DeleteActuator(w http.ResponseWriter, r *http.Request) {
 	actuatorID := params["actuator_id"]
 
 	ctx := r.Context()
+	// Look the actuator up first so rules depending on its tags can be disabled once it is gone
+	actuator, err := h.Store.GetActuator(ctx, deviceID, actuatorID)
+	if err != nil {
+		http.Error(w, "Failed to delete actuator: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if err := h.Store.DeleteActuator(ctx, deviceID, actuatorID); err != nil {
 		http.Error(w, "Failed to delete actuator: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.recordBrokenReferences(ctx, "actuator "+deviceID+"/"+actuatorID, actuator.Tags)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -304,12 +685,33 @@ func (h *Handler) ListActuators(w http.ResponseWriter, r *http.Request) {
 	// Check if device_id query parameter is provided
 	deviceID := r.URL.Query().Get("device_id")
 
+	page, paged, err := parsePage(r)
+	if err != nil {
+		http.Error(w, "Invalid limit parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if paged {
+		actuators, next, err := h.Store.ListActuatorsPage(ctx, deviceID, page)
+		if err != nil {
+			http.Error(w, "Failed to list actuators: "+err.Error(), pageErrorStatus(err))
+			return
+		}
+		if actuators == nil {
+			actuators = []*api.Actuator{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.ActuatorPage{Actuators: actuators, NextCursor: next})
+		return
+	}
+
 	var actuators []*api.Actuator
-	var err error
 
-	if deviceID != "" {
+	switch {
+	case len(page.Metadata) > 0:
+		actuators, _, err = h.Store.ListActuatorsPage(ctx, deviceID, page)
+	case deviceID != "":
 		actuators, err = h.Store.ListActuatorsByDeviceID(ctx, deviceID)
-	} else {
+	default:
 		actuators, err = h.Store.ListActuators(ctx)
 	}
 
@@ -375,7 +777,7 @@ func (h *Handler) GetActuatorLatestStatusByTag(w http.ResponseWriter, r *http.Re
 
 	status, err := driver.GetLastStatus(ctx, opt, actuator)
 	if err != nil {
-		http.Error(w, "Failed to get actuator status: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Failed to get actuator status: "+err.Error(), driverErrorStatus(err))
 		return
 	}
 
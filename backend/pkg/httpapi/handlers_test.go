@@ -1,50 +1,385 @@
 package httpapi
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
+	"lifesupport/backend/pkg/api"
 	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/storer/storertest"
 )
 
 // Test helpers
 
-func setupTestDB(t *testing.T) *storer.Storer {
+// setupTestDB returns an in-memory store, or PostgreSQL when TEST_DB_CONN is set
+func setupTestDB(t *testing.T) storer.Interface {
 	t.Helper()
+	return storertest.New(t)
+}
 
-	// Use a test database connection
-	connStr := "host=localhost port=5432 user=postgres password=postgres dbname=lifesupport_test sslmode=disable"
-	store, err := storer.New(connStr)
-	if err != nil {
-		t.Skipf("Skipping test: cannot connect to test database: %v", err)
-		return nil
+func doRequest(t *testing.T, router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("Failed to encode body: %v", err)
+		}
 	}
+	req := httptest.NewRequest(method, path, &buf)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
 
-	// Initialize schema
-	ctx := context.Background()
-	if err := store.InitSchema(ctx); err != nil {
-		t.Fatalf("Failed to initialize schema: %v", err)
+func TestDeviceCRUD(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{
+		ID:      "test-dev-001",
+		Driver:  api.DriverShelly,
+		Name:    "Test Device",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
 	}
 
-	// Clean up existing test data
-	cleanupTestData(t, store)
+	rec := doRequest(t, router, "GET", "/api/devices/test-dev-001", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var got api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if got.Name != "Test Device" || len(got.Sensors) != 1 {
+		t.Errorf("Expected device with 1 sensor, got %+v", got)
+	}
+
+	dev.Name = "Renamed"
+	dev.Version = got.Version
+	if rec := doRequest(t, router, "PUT", "/api/devices/test-dev-001", dev); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on update, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, router, "GET", "/api/sensors/by-tag/device.test-dev-001.sensor.temp", nil)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected default sensor tag to resolve, got %d", rec.Code)
+	}
 
-	return store
+	if rec := doRequest(t, router, "DELETE", "/api/devices/test-dev-001", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204 on delete, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices/test-dev-001", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", rec.Code)
+	}
 }
 
-func cleanupTestData(t *testing.T, store *storer.Storer) {
-	t.Helper()
-	ctx := context.Background()
+func TestUpdateDevice_Version(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{ID: "test-dev-003", Driver: api.DriverShelly, Name: "Test Device"}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rec.Code)
+	}
+
+	dev.Name = "Renamed"
+	if rec := doRequest(t, router, "PUT", "/api/devices/test-dev-003", dev); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected status 428 without a version, got %d", rec.Code)
+	}
+	dev.Version = 1
+	rec := doRequest(t, router, "PUT", "/api/devices/test-dev-003", dev)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on update, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", got.Version)
+	}
+
+	dev.Name = "Overwritten"
+	if rec := doRequest(t, router, "PUT", "/api/devices/test-dev-003", dev); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a stale version, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "PUT", "/api/devices/missing", dev); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", rec.Code)
+	}
+}
+
+func TestCreateDevice_Duplicate(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
 
-	// Delete test devices
-	_ = store.DeleteDevice(ctx, "test-dev-001")
-	_ = store.DeleteDevice(ctx, "test-dev-002")
+	dev := api.Device{ID: "test-dev-002", Driver: api.DriverShelly, Name: "Test Device"}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code == http.StatusCreated {
+		t.Error("Expected duplicate device to be rejected")
+	}
 }
 
-func teardownTestDB(t *testing.T, store *storer.Storer) {
+func TestCreateDevices_Batch(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	devs := []api.Device{
+		{ID: "relay-1", Driver: api.DriverShelly, Name: "Relay 1"},
+		{ID: "relay-1", Driver: api.DriverShelly, Name: "Relay 1 again"},
+		{ID: "relay-2", Driver: api.DriverShelly, Name: "Relay 2"},
+	}
+	rec := doRequest(t, router, "POST", "/api/devices:batch", devs)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []api.DeviceBatchResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %+v", results)
+	}
+	if results[0].Device == nil || results[0].Error != "" || results[2].Device == nil {
+		t.Errorf("Expected relay-1 and relay-2 to be created, got %+v", results)
+	}
+	if results[1].ID != "relay-1" || results[1].Device != nil || results[1].Error == "" {
+		t.Errorf("Expected the duplicate to fail, got %+v", results[1])
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices/relay-2", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected relay-2 to be stored, got %d", rec.Code)
+	}
+
+	if rec := doRequest(t, router, "POST", "/api/devices:batch", map[string]string{"id": "relay-3"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-array body, got %d", rec.Code)
+	}
+}
+
+func TestPatchDevices_BySelector(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	devs := []api.Device{
+		{ID: "shelly-1", Driver: api.DriverShelly, Name: "Shelly 1", Metadata: map[string]string{"mqtt_key": "old"}},
+		{ID: "shelly-2", Driver: api.DriverShelly, Name: "Shelly 2", Metadata: map[string]string{"mqtt_key": "old"}},
+		{ID: "station-1", Driver: api.DriverStation, Name: "Station 1", Metadata: map[string]string{"mqtt_key": "old"}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices:batch", devs); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	patch := map[string]any{"metadata": map[string]string{"mqtt_key": "new"}}
+	rec := doRequest(t, router, "PATCH", "/api/devices?selector=driver=shelly", patch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []api.DeviceBatchResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "shelly-1" || results[1].ID != "shelly-2" {
+		t.Fatalf("Expected both Shelly devices to be patched, got %+v", results)
+	}
+	if results[0].Error != "" || results[0].Device.Metadata["mqtt_key"] != "new" || results[0].Device.Version != 2 {
+		t.Errorf("Expected the patched device at version 2, got %+v", results[0])
+	}
+	rec = doRequest(t, router, "GET", "/api/devices/station-1", nil)
+	var station api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&station); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if station.Metadata["mqtt_key"] != "old" {
+		t.Errorf("Expected the station to be left alone, got %+v", station.Metadata)
+	}
+
+	for _, tc := range []struct {
+		path string
+		body any
+	}{
+		{"/api/devices", patch},
+		{"/api/devices?selector=colour=red", patch},
+		{"/api/devices?selector=driver=shelly", map[string]any{}},
+	} {
+		if rec := doRequest(t, router, "PATCH", tc.path, tc.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s with %v, got %d", tc.path, tc.body, rec.Code)
+		}
+	}
+}
+
+// doMergePatch sends body as a JSON Merge Patch
+func doMergePatch(t *testing.T, router http.Handler, path string, body any) *httptest.ResponseRecorder {
 	t.Helper()
-	if store != nil {
-		cleanupTestData(t, store)
-		store.Close()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode body: %v", err)
+	}
+	req := httptest.NewRequest("PATCH", path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", mergePatchType)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPatchDevice_MergePatch(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{
+		ID: "patch-dev", Driver: api.DriverShelly, Name: "Sump pump", Description: "Basement",
+		Metadata:  map[string]string{"site": "north", "room": "a"},
+		Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+		Actuators: []*api.Actuator{{ID: "relay", Name: "Relay", ActuatorType: api.ActuatorTypeRelay}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	patch := map[string]any{"name": "Main pump", "metadata": map[string]any{"room": nil, "rack": "2"}}
+	rec := doMergePatch(t, router, "/api/devices/patch-dev", patch)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var patched api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&patched); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if patched.Name != "Main pump" || patched.Description != "Basement" || patched.Version != 2 || len(patched.Sensors) != 1 {
+		t.Errorf("Expected only the name and metadata to change, got %+v", patched)
+	}
+	if len(patched.Metadata) != 2 || patched.Metadata["site"] != "north" || patched.Metadata["rack"] != "2" {
+		t.Errorf("Expected room removed and rack added, got %+v", patched.Metadata)
+	}
+
+	rec = doMergePatch(t, router, "/api/sensors/patch-dev/temp", map[string]any{"name": "Water temperature"})
+	var sensor api.Sensor
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&sensor) != nil || sensor.Name != "Water temperature" || sensor.SensorType != api.SensorTypeTemperature {
+		t.Errorf("Expected the sensor renamed, got %d: %+v", rec.Code, sensor)
+	}
+	rec = doMergePatch(t, router, "/api/actuators/patch-dev/relay", map[string]any{"metadata": map[string]string{"channel": "1"}})
+	var actuator api.Actuator
+	if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&actuator) != nil || actuator.Metadata["channel"] != "1" || actuator.Name != "Relay" {
+		t.Errorf("Expected the actuator's metadata set, got %d: %+v", rec.Code, actuator)
+	}
+
+	for _, tc := range []struct {
+		path   string
+		body   any
+		status int
+	}{
+		{"/api/devices/patch-dev", map[string]any{"name": nil}, http.StatusBadRequest},
+		{"/api/devices/patch-dev", map[string]any{"id": "other"}, http.StatusBadRequest},
+		{"/api/devices/patch-dev", []string{"name"}, http.StatusBadRequest},
+		{"/api/devices/patch-dev", map[string]any{"name": "Stale", "version": 1}, http.StatusConflict},
+		{"/api/devices/missing", map[string]any{"name": "Missing"}, http.StatusNotFound},
+		{"/api/sensors/patch-dev/missing", map[string]any{"name": "Missing"}, http.StatusNotFound},
+	} {
+		if rec := doMergePatch(t, router, tc.path, tc.body); rec.Code != tc.status {
+			t.Errorf("Expected status %d for %s with %v, got %d", tc.status, tc.path, tc.body, rec.Code)
+		}
+	}
+
+	// Plain JSON is a merge patch too, but a JSON Patch is not
+	req := httptest.NewRequest("PATCH", "/api/devices/patch-dev", strings.NewReader(`{"name": "Plain"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a plain JSON patch, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, contentType := range []string{"application/json-patch+json", ""} {
+		req := httptest.NewRequest("PATCH", "/api/devices/patch-dev", strings.NewReader(`[{"op": "remove", "path": "/name"}]`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Accept-Patch") != mergePatchType {
+			t.Errorf("Expected status 415 for Content-Type %q, got %d", contentType, rec.Code)
+		}
+	}
+}
+
+func TestListDevices_Paginated(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	for _, id := range []string{"page-dev-1", "page-dev-2", "page-dev-3"} {
+		dev := api.Device{ID: id, Driver: api.DriverShelly, Name: "Pump " + id}
+		if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// Without paging parameters the whole list is returned as before
+	rec := doRequest(t, router, "GET", "/api/devices", nil)
+	var all []*api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&all); err != nil {
+		t.Fatalf("Failed to decode devices: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected 3 devices, got %d", len(all))
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices?limit=2", nil)
+	var page api.DevicePage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if len(page.Devices) != 2 || page.Devices[0].ID != "page-dev-1" || page.NextCursor == "" {
+		t.Fatalf("Expected the first 2 devices and a next cursor, got %+v", page)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices?limit=2&cursor="+page.NextCursor, nil)
+	page = api.DevicePage{}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if len(page.Devices) != 1 || page.Devices[0].ID != "page-dev-3" || page.NextCursor != "" {
+		t.Errorf("Expected the last device and no next cursor, got %+v", page)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/devices?limit=0", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for limit=0, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/sensors?cursor=bogus", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad cursor, got %d", rec.Code)
+	}
+}
+
+func TestListDevices_MetadataFilter(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+	for i, location := range []string{"sump", "display", "sump"} {
+		dev := api.Device{ID: "meta-dev-" + strconv.Itoa(i+1), Driver: api.DriverShelly, Name: "Device " + strconv.Itoa(i+1),
+			Metadata: map[string]string{"location": location}}
+		if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, router, "GET", "/api/devices?metadata.location=sump", nil)
+	var devices []*api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&devices); err != nil {
+		t.Fatalf("Failed to decode devices: %v", err)
+	}
+	if len(devices) != 2 || devices[0].ID != "meta-dev-1" || devices[1].ID != "meta-dev-3" {
+		t.Errorf("Expected the 2 sump devices, got %+v", devices)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/devices?metadata.location=display&limit=10", nil)
+	var page api.DevicePage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if len(page.Devices) != 1 || page.Devices[0].ID != "meta-dev-2" {
+		t.Errorf("Expected the display device, got %+v", page)
 	}
 }
@@ -0,0 +1,31 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"lifesupport/backend/pkg/health"
+)
+
+// GetHealthScore handles GET /api/health-score
+func (h *Handler) GetHealthScore(w http.ResponseWriter, r *http.Request) {
+	staleAfter := health.DefaultStaleAfter
+	if s := r.URL.Query().Get("stale_after"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid stale_after, expected a positive duration such as 30m", http.StatusBadRequest)
+			return
+		}
+		staleAfter = d
+	}
+
+	score, err := health.Score(r.Context(), h.Store, time.Now(), staleAfter)
+	if err != nil {
+		http.Error(w, "Failed to compute health score: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(score)
+}
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// configActions are the audit actions which change an entity's configuration, leaving
+// out commands
+var configActions = []api.AuditAction{api.AuditActionCreated, api.AuditActionUpdated, api.AuditActionDeleted}
+
+// parseAsOf returns the time of the as_of query parameter, or nil without one
+func parseAsOf(r *http.Request) (*time.Time, error) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid as_of: %w", err)
+	}
+	return &t, nil
+}
+
+// historyErrorStatus is the status for failing to get an entity, as of asOf if set: not
+// found, unless its history could not be read
+func historyErrorStatus(err error, asOf *time.Time) int {
+	if asOf != nil && !errors.Is(err, storer.ErrNotFound) {
+		return http.StatusInternalServerError
+	}
+	return http.StatusNotFound
+}
+
+// historyAt lists the configuration changes to entities of typ made at or before asOf,
+// oldest first; entityID narrows them to one entity
+func (h *Handler) historyAt(ctx context.Context, typ api.AuditEntityType, entityID string, asOf time.Time) ([]*api.AuditEntry, error) {
+	// EndTime is exclusive; a change made at asOf is part of the state then
+	end := asOf.Add(time.Nanosecond)
+	return h.Store.ListAuditEntries(ctx, storer.AuditFilters{
+		EntityType: typ,
+		EntityID:   entityID,
+		EndTime:    &end,
+		Actions:    configActions,
+	})
+}
+
+// entityAt decodes into v the entity of typ identified by entityID as it was stored at
+// asOf, reconstructed from the audit log. It returns storer.ErrNotFound if the entity
+// did not exist then, or has no history that far back.
+func (h *Handler) entityAt(ctx context.Context, typ api.AuditEntityType, entityID string, asOf time.Time, v any) error {
+	entries, err := h.historyAt(ctx, typ, entityID, asOf)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 || entries[len(entries)-1].After == nil {
+		return fmt.Errorf("%w: %s %s as of %s", storer.ErrNotFound, typ, entityID, asOf.Format(time.RFC3339))
+	}
+	if err := json.Unmarshal(entries[len(entries)-1].After, v); err != nil {
+		return fmt.Errorf("failed to decode %s history: %w", typ, err)
+	}
+	return nil
+}
+
+// componentsAt decodes the sensors or actuators, by typ, of device deviceID as they were
+// stored at asOf, appending each with add
+func (h *Handler) componentsAt(ctx context.Context, typ api.AuditEntityType, deviceID string, asOf time.Time, add func(data json.RawMessage) error) error {
+	entries, err := h.historyAt(ctx, typ, "", asOf)
+	if err != nil {
+		return err
+	}
+	// The latest entry of each component is its state at asOf
+	latest := map[string]*api.AuditEntry{}
+	var order []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.EntityID, deviceID+"/") {
+			continue
+		}
+		if _, ok := latest[entry.EntityID]; !ok {
+			order = append(order, entry.EntityID)
+		}
+		latest[entry.EntityID] = entry
+	}
+	for _, id := range order {
+		if entry := latest[id]; entry.After != nil {
+			if err := add(entry.After); err != nil {
+				return fmt.Errorf("failed to decode %s history: %w", typ, err)
+			}
+		}
+	}
+	return nil
+}
+
+// deviceAt reconstructs device id, with its sensors and actuators, as it was at asOf
+func (h *Handler) deviceAt(ctx context.Context, id string, asOf time.Time) (*api.Device, error) {
+	var dev api.Device
+	if err := h.entityAt(ctx, api.AuditEntityDevice, id, asOf, &dev); err != nil {
+		return nil, err
+	}
+	dev.Sensors = []*api.Sensor{}
+	dev.Actuators = []*api.Actuator{}
+	err := h.componentsAt(ctx, api.AuditEntitySensor, id, asOf, func(data json.RawMessage) error {
+		var sensor api.Sensor
+		if err := json.Unmarshal(data, &sensor); err != nil {
+			return err
+		}
+		dev.Sensors = append(dev.Sensors, &sensor)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = h.componentsAt(ctx, api.AuditEntityActuator, id, asOf, func(data json.RawMessage) error {
+		var actuator api.Actuator
+		if err := json.Unmarshal(data, &actuator); err != nil {
+			return err
+		}
+		dev.Actuators = append(dev.Actuators, &actuator)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dev, nil
+}
+
+// GetDeviceHistory handles GET /api/devices/{id}/history, listing the changes to the
+// device's configuration from the audit log, oldest first, each with the fields it
+// changed. Deleted devices keep their history.
+func (h *Handler) GetDeviceHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	filters := storer.AuditFilters{
+		EntityType: api.AuditEntityDevice,
+		EntityID:   id,
+		Actions:    configActions,
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = limit
+	}
+
+	entries, err := h.Store.ListAuditEntries(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to list device history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(entries) == 0 {
+		http.Error(w, "Device not found: no history for "+id, http.StatusNotFound)
+		return
+	}
+
+	revisions := make([]*api.DeviceRevision, 0, len(entries))
+	for _, entry := range entries {
+		rev, err := deviceRevision(entry)
+		if err != nil {
+			http.Error(w, "Failed to decode device history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// deviceRevision describes the device change recorded by entry
+func deviceRevision(entry *api.AuditEntry) (*api.DeviceRevision, error) {
+	rev := &api.DeviceRevision{
+		AuditID:   entry.ID,
+		Action:    entry.Action,
+		Actor:     entry.Actor,
+		Source:    entry.Source,
+		Timestamp: entry.Timestamp,
+		Changes:   []api.FieldChange{},
+	}
+	if entry.After != nil {
+		rev.Device = &api.Device{}
+		if err := json.Unmarshal(entry.After, rev.Device); err != nil {
+			return nil, err
+		}
+	}
+	before, err := deviceFields(entry.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := deviceFields(entry.After)
+	if err != nil {
+		return nil, err
+	}
+	for name, b := range before {
+		if a := after[name]; string(a) != string(b) {
+			rev.Changes = append(rev.Changes, api.FieldChange{Field: name, Before: b, After: a})
+		}
+	}
+	for name, a := range after {
+		if _, ok := before[name]; !ok {
+			rev.Changes = append(rev.Changes, api.FieldChange{Field: name, After: a})
+		}
+	}
+	sort.Slice(rev.Changes, func(i, j int) bool { return rev.Changes[i].Field < rev.Changes[j].Field })
+	return rev, nil
+}
+
+// deviceFields flattens a device recorded in the audit log into its fields, with
+// metadata split out by key so a changed address shows as that key alone. Versions
+// count edits rather than describe configuration, so are left out.
+func deviceFields(data json.RawMessage) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if data == nil {
+		return fields, nil
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"version", "sensors", "actuators"} {
+		delete(fields, name)
+	}
+	if raw, ok := fields["metadata"]; ok {
+		delete(fields, "metadata")
+		var metadata map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &metadata); err != nil {
+			return nil, err
+		}
+		for key, v := range metadata {
+			fields["metadata."+key] = v
+		}
+	}
+	return fields, nil
+}
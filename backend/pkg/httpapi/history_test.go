@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGetAsOf(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+	ctx := context.Background()
+
+	dev := &api.Device{ID: "history-dev", Driver: api.DriverShelly, Name: "Sump",
+		Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+		Actuators: []*api.Actuator{{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay}}}
+	beforeCreate := time.Now().Add(-time.Second)
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	incident := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	// Reconfigured after the incident
+	sensor, err := store.GetSensor(ctx, dev.ID, "temp")
+	if err != nil {
+		t.Fatalf("GetSensor() error = %v", err)
+	}
+	sensor.Name = "Water temperature"
+	if err := store.UpdateSensor(ctx, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	if err := store.DeleteActuator(ctx, dev.ID, "heater"); err != nil {
+		t.Fatalf("DeleteActuator() error = %v", err)
+	}
+
+	asOf := "?as_of=" + incident.UTC().Format(time.RFC3339Nano)
+	rec := doRequest(t, router, "GET", "/api/devices/history-dev"+asOf, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var past api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&past); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if past.Name != "Sump" || len(past.Sensors) != 1 || len(past.Actuators) != 1 {
+		t.Errorf("Expected the device with its sensor and heater, got %+v", past)
+	}
+	if len(past.Sensors) == 1 && past.Sensors[0].Name != "Temperature" {
+		t.Errorf("Expected the sensor's name at the time, got %q", past.Sensors[0].Name)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensors/history-dev/temp"+asOf, nil)
+	var pastSensor api.Sensor
+	if err := json.NewDecoder(rec.Body).Decode(&pastSensor); err != nil || pastSensor.Name != "Temperature" {
+		t.Errorf("Expected the sensor as it was, got %d: %+v", rec.Code, pastSensor)
+	}
+	if rec := doRequest(t, router, "GET", "/api/actuators/history-dev/heater"+asOf, nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected the deleted heater as it was, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/actuators/history-dev/heater", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected the heater gone now, got %d", rec.Code)
+	}
+
+	early := "?as_of=" + beforeCreate.UTC().Format(time.RFC3339Nano)
+	if rec := doRequest(t, router, "GET", "/api/devices/history-dev"+early, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before the device existed, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices/history-dev?as_of=yesterday", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid as_of, got %d", rec.Code)
+	}
+}
+
+func TestGetDeviceHistory(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	body := &api.Device{ID: "shelly-sump", Driver: api.DriverShelly, Name: "Sump",
+		Metadata: map[string]string{"ip": "10.0.0.5", "model": "plus1"}, Tags: []string{"sump.pump"}}
+	if rec := doRequest(t, router, "POST", "/api/devices", body); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	dev, err := store.GetDevice(context.Background(), "shelly-sump")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	dev.Metadata["ip"] = "10.0.0.9"
+	dev.Tags = append(dev.Tags, "sump.return")
+	if err := store.UpdateDevice(api.WithActor(context.Background(), "alice"), dev); err != nil {
+		t.Fatalf("UpdateDevice() error = %v", err)
+	}
+
+	rec := doRequest(t, router, "GET", "/api/devices/shelly-sump/history", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var revisions []api.DeviceRevision
+	if err := json.NewDecoder(rec.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Failed to decode history: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Action != api.AuditActionCreated || revisions[1].Action != api.AuditActionUpdated {
+		t.Fatalf("Expected the creation then the update, got %+v", revisions)
+	}
+	update := revisions[1]
+	if update.Actor != "alice" || update.Device == nil || update.Device.Metadata["ip"] != "10.0.0.9" {
+		t.Errorf("Expected alice's update with the new address, got %+v", update)
+	}
+	fields := map[string]api.FieldChange{}
+	for _, change := range update.Changes {
+		fields[change.Field] = change
+	}
+	if len(fields) != 2 {
+		t.Errorf("Expected only the address and tags changed, got %+v", update.Changes)
+	}
+	if ip := fields["metadata.ip"]; string(ip.Before) != `"10.0.0.5"` || string(ip.After) != `"10.0.0.9"` {
+		t.Errorf("Expected the address change, got %+v", ip)
+	}
+	if _, ok := fields["tags"]; !ok {
+		t.Errorf("Expected the tags change, got %+v", update.Changes)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/devices/shelly-sump/history?limit=1", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with a limit, got %d", rec.Code)
+	} else if err := json.NewDecoder(rec.Body).Decode(&revisions); err != nil || len(revisions) != 1 || revisions[0].Action != api.AuditActionUpdated {
+		t.Errorf("Expected only the latest change, got %+v", revisions)
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices/missing/history", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown device, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifesupport/backend/pkg/i18n"
+)
+
+// GetI18n handles GET /api/i18n. It returns the display-name catalog best matching the
+// Accept-Language header, or the one named by the lang query parameter.
+func (h *Handler) GetI18n(w http.ResponseWriter, r *http.Request) {
+	catalog := i18n.Match(r.Header.Get("Accept-Language"))
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		c, ok := i18n.Get(lang)
+		if !ok {
+			http.Error(w, "Unsupported language: "+lang, http.StatusNotFound)
+			return
+		}
+		catalog = c
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", catalog.Language)
+	w.Header().Add("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(catalog)
+}
+
+// ListLanguages handles GET /api/i18n/languages
+func (h *Handler) ListLanguages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(i18n.Languages())
+}
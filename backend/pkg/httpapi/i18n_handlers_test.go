@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/i18n"
+)
+
+func TestGetI18n(t *testing.T) {
+	router := NewHandler(setupTestDB(t), nil, nil).SetupRouter()
+
+	req := httptest.NewRequest("GET", "/api/i18n", nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Expected Content-Language de, got %q", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Language" {
+		t.Errorf("Expected Vary: Accept-Language, got %q", got)
+	}
+	var catalog i18n.Catalog
+	if err := json.NewDecoder(rec.Body).Decode(&catalog); err != nil {
+		t.Fatalf("Failed to decode catalog: %v", err)
+	}
+	if catalog.SensorTypes["temperature"] != "Temperatur" {
+		t.Errorf("Expected German sensor type names, got %q", catalog.SensorTypes["temperature"])
+	}
+
+	rec = doRequest(t, router, "GET", "/api/i18n?lang=fr", nil)
+	if got := rec.Header().Get("Content-Language"); got != "fr" {
+		t.Errorf("Expected lang parameter to select fr, got %q", got)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/i18n?lang=xx", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unsupported language, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"lifesupport/backend/pkg/importer"
+	"lifesupport/backend/pkg/storer"
+)
+
+// maxImportBody bounds the size of an uploaded export
+const maxImportBody = 256 << 20
+
+// ImportReadings handles POST /api/import. The multipart form carries the export in the
+// "file" field, its importer.Format in "format" and an importer.Config JSON in "config".
+func (h *Handler) ImportReadings(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBody)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var cfg importer.Config
+	if err := json.Unmarshal([]byte(r.FormValue("config")), &cfg); err != nil {
+		http.Error(w, "Invalid config: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	samples, err := importer.Parse(importer.Format(r.FormValue("format")), file, cfg)
+	if err != nil {
+		http.Error(w, "Failed to parse export: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := importer.Import(r.Context(), h.Store, samples, cfg.Mappings)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, storer.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "Failed to import readings: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
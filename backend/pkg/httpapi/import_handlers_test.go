@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/importer"
+)
+
+func TestImportReadings(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:     "sump",
+		Driver: "gateway",
+		Name:   "Sump",
+		Sensors: []*api.Sensor{
+			{ID: "temp", DeviceID: "sump", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"sump-temp"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("format", string(importer.FormatHomeAssistant))
+	mw.WriteField("config", `{"mappings":[{"source":"sensor.sump_temperature","sensor":"sump-temp","unit":"°C"}]}`)
+	fw, _ := mw.CreateFormFile("file", "history.csv")
+	fw.Write([]byte("entity_id,state,last_changed\nsensor.sump_temperature,25.2,2026-01-31T10:00:00Z\n"))
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/api/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result importer.Result
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if result.Imported != 1 {
+		t.Errorf("Expected 1 imported reading, got %+v", result)
+	}
+}
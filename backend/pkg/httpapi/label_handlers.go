@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/trend"
+)
+
+// CreateReadingLabel handles POST /api/reading-labels
+func (h *Handler) CreateReadingLabel(w http.ResponseWriter, r *http.Request) {
+	var label api.ReadingLabel
+	if err := json.NewDecoder(r.Body).Decode(&label); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := label.Validate(); err != nil {
+		http.Error(w, "Invalid reading label: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.CreateReadingLabel(r.Context(), &label); err != nil {
+		http.Error(w, "Failed to create reading label: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(label)
+}
+
+// ListReadingLabels handles GET /api/reading-labels
+func (h *Handler) ListReadingLabels(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := storer.ReadingLabelFilters{
+		Key:      q.Get("key"),
+		DeviceID: q.Get("device_id"),
+		SensorID: q.Get("sensor_id"),
+	}
+	for name, dst := range map[string]**time.Time{"start_time": &filters.StartTime, "end_time": &filters.EndTime} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	labels, err := h.Store.ListReadingLabels(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to list reading labels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if labels == nil {
+		labels = []*api.ReadingLabel{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(labels)
+}
+
+// DeleteReadingLabel handles DELETE /api/reading-labels/{id}
+func (h *Handler) DeleteReadingLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid reading label id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.DeleteReadingLabel(r.Context(), id); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Reading label not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete reading label: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSensorLabelComparison handles GET /api/sensors/{device_id}/{sensor_id}/labels/{key},
+// summarising the sensor's readings under each value of the label
+func (h *Handler) GetSensorLabelComparison(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID, sensorID := params["device_id"], params["sensor_id"]
+
+	if _, err := h.Store.GetSensor(r.Context(), deviceID, sensorID); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get sensor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cmp, err := trend.CompareLabels(r.Context(), h.Store, deviceID, sensorID, params["key"])
+	if err != nil {
+		http.Error(w, "Failed to compare sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmp)
+}
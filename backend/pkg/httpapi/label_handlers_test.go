@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestReadingLabels(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{
+		ID:      "label-dev",
+		Driver:  api.DriverShelly,
+		Name:    "Tank",
+		Sensors: []*api.Sensor{{ID: "par", Name: "PAR", SensorType: api.SensorTypeTemperature}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	store.StoreSensorReadings(t.Context(), []*api.ReadingRecord{
+		{DeviceID: "label-dev", SensorID: "par", Reading: api.SensorReading{Value: 200, Valid: true, Timestamp: start}},
+		{DeviceID: "label-dev", SensorID: "par", Reading: api.SensorReading{Value: 100, Valid: true, Timestamp: start.Add(2 * time.Hour)}},
+	})
+
+	if rec := doRequest(t, router, "POST", "/api/reading-labels", api.ReadingLabel{Key: "experiment", Value: "x", Start: start, End: start}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty window, got %d", rec.Code)
+	}
+	var ids []int64
+	for i, value := range []string{"control", "reduced-photoperiod"} {
+		label := api.ReadingLabel{Key: "experiment", Value: value, DeviceID: "label-dev",
+			Start: start.Add(time.Duration(2*i) * time.Hour), End: start.Add(time.Duration(2*i+1) * time.Hour)}
+		rec := doRequest(t, router, "POST", "/api/reading-labels", label)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&label); err != nil {
+			t.Fatalf("Failed to decode label: %v", err)
+		}
+		ids = append(ids, label.ID)
+	}
+
+	rec := doRequest(t, router, "GET", "/api/reading-labels?key=experiment&device_id=label-dev", nil)
+	var labels []*api.ReadingLabel
+	if err := json.NewDecoder(rec.Body).Decode(&labels); err != nil {
+		t.Fatalf("Failed to decode labels: %v", err)
+	}
+	if len(labels) != 2 || labels[0].Value != "control" {
+		t.Errorf("Expected both labels by start, got %+v", labels)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensors/label-dev/par/labels/experiment", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var cmp api.LabelComparison
+	if err := json.NewDecoder(rec.Body).Decode(&cmp); err != nil {
+		t.Fatalf("Failed to decode comparison: %v", err)
+	}
+	if len(cmp.Groups) != 2 || *cmp.Groups[0].Stats.Mean != 200 || *cmp.Groups[1].Stats.Mean != 100 {
+		t.Errorf("Expected control at 200 and reduced-photoperiod at 100, got %+v", cmp.Groups)
+	}
+	if rec := doRequest(t, router, "GET", "/api/sensors/label-dev/missing/labels/experiment", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing sensor, got %d", rec.Code)
+	}
+
+	if rec := doRequest(t, router, "DELETE", "/api/reading-labels/"+strconv.FormatInt(ids[0], 10), nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "DELETE", "/api/reading-labels/"+strconv.FormatInt(ids[0], 10), nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", rec.Code)
+	}
+}
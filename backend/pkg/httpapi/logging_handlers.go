@@ -0,0 +1,80 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// loggingPath is exempt from read-only mode; log levels aren't kept in the database
+const loggingPath = "/api/logging"
+
+func logCtx(ctx context.Context, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = log.Logger.With()
+	}
+	ll = ll.Str("component", "httpapi")
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "httpapi")
+}
+
+func currentLogLevels() api.LogLevels {
+	levels := api.LogLevels{
+		Level:      logging.Level().String(),
+		Components: make(map[string]string),
+	}
+	for component, level := range logging.ComponentLevels() {
+		levels.Components[component] = level.String()
+	}
+	return levels
+}
+
+// GetLogLevels handles GET /api/logging
+func (h *Handler) GetLogLevels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentLogLevels())
+}
+
+// SetLogLevels handles PUT /api/logging, replacing the level of every component in this
+// server process. It requires the AdminToken as a bearer token.
+func (h *Handler) SetLogLevels(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var req api.LogLevels
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Level != "" && req.Level != logging.Level().String() {
+		http.Error(w, "The default level can't be changed at runtime; restart with --log-level", http.StatusBadRequest)
+		return
+	}
+	components := make(map[string]zerolog.Level, len(req.Components))
+	for component, name := range req.Components {
+		level, err := logging.ParseLevel(name)
+		if err != nil {
+			http.Error(w, "Invalid level for "+component+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		components[component] = level
+	}
+	logging.SetComponentLevels(components)
+	ll := logCtx(r.Context(), "logging")
+	ll.Info().Str("components", logging.FormatComponentLevels(components)).Msg("component log levels changed")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentLogLevels())
+}
@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+)
+
+func TestLogLevels(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+	t.Cleanup(func() { logging.SetComponentLevels(nil) })
+
+	setLevels := func(token string, levels api.LogLevels) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(levels)
+		req := httptest.NewRequest("PUT", "/api/logging", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	levels := api.LogLevels{Components: map[string]string{"storer": "debug", "httpapi": "warn"}}
+
+	if rec := setLevels("wrong", levels); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong token, got %d", rec.Code)
+	}
+	if rec := setLevels("s3cret", api.LogLevels{Components: map[string]string{"storer": "loud"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown level, got %d", rec.Code)
+	}
+	if rec := setLevels("s3cret", levels); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doRequest(t, router, "GET", "/api/logging", nil)
+	var got api.LogLevels
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode log levels: %v", err)
+	}
+	if got.Components["storer"] != "debug" || got.Components["httpapi"] != "warn" || len(got.Components) != 2 {
+		t.Errorf("Expected storer=debug and httpapi=warn, got %v", got.Components)
+	}
+	if lvl := logging.ComponentLevels()["storer"]; lvl != zerolog.DebugLevel {
+		t.Errorf("Expected storer to log at debug, got %s", lvl)
+	}
+}
@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// maintenancePath is exempt from read-only mode, so it can be turned off again
+const maintenancePath = "/api/maintenance"
+
+// GetMaintenanceMode handles GET /api/maintenance, which the dashboard polls to show
+// the maintenance banner
+func (h *Handler) GetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	mode, err := h.Store.GetMaintenanceMode(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to get maintenance mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mode)
+}
+
+// SetMaintenanceMode handles PUT /api/maintenance, turning read-only mode on or off. It
+// requires the AdminToken as a bearer token.
+func (h *Handler) SetMaintenanceMode(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var mode api.MaintenanceMode
+	if err := json.NewDecoder(r.Body).Decode(&mode); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetMaintenanceMode(r.Context(), &mode); err != nil {
+		http.Error(w, "Failed to set maintenance mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mode)
+}
+
+// authorizeAdmin checks the request carries the AdminToken as a bearer token, writing
+// the error response if it doesn't
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.AdminToken == "" {
+		http.Error(w, "Admin token not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+		http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// readOnlyGuard rejects mutating requests with 503 while read-only maintenance mode is
+// on. Reads, including the dashboard, keep working. Actuator commands over the session
+// WebSocket are also unaffected, so the system stays controllable during maintenance.
+func (h *Handler) readOnlyGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == maintenancePath || r.URL.Path == loggingPath || r.URL.Path == configDiffPath || h.Store == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// If the mode can't be read the request proceeds; it fails on its own if the
+		// database is down
+		mode, err := h.Store.GetMaintenanceMode(r.Context())
+		if err == nil && mode.ReadOnly {
+			msg := "The API is read-only for maintenance"
+			if mode.Message != "" {
+				msg += ": " + mode.Message
+			}
+			w.Header().Set("X-Maintenance-Mode", "read-only")
+			http.Error(w, msg, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
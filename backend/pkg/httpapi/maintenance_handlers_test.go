@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	router := h.SetupRouter()
+	ctx := context.Background()
+	t.Cleanup(func() { store.SetMaintenanceMode(ctx, &api.MaintenanceMode{}) })
+
+	setMode := func(token string, mode api.MaintenanceMode) *httptest.ResponseRecorder {
+		t.Helper()
+		body, _ := json.Marshal(mode)
+		req := httptest.NewRequest("PUT", "/api/maintenance", bytes.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	readOnly := api.MaintenanceMode{ReadOnly: true, Message: "Nightly backup until 02:30"}
+
+	if rec := setMode("s3cret", readOnly); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without an admin token configured, got %d", rec.Code)
+	}
+	h.AdminToken = "s3cret"
+	if rec := setMode("wrong", readOnly); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a wrong token, got %d", rec.Code)
+	}
+	if rec := setMode("s3cret", readOnly); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doRequest(t, router, "GET", "/api/maintenance", nil)
+	var mode api.MaintenanceMode
+	if err := json.NewDecoder(rec.Body).Decode(&mode); err != nil {
+		t.Fatalf("Failed to decode maintenance mode: %v", err)
+	}
+	if !mode.ReadOnly || mode.Message != readOnly.Message || mode.UpdatedAt.IsZero() {
+		t.Errorf("Expected read-only mode with the banner message, got %+v", mode)
+	}
+
+	dev := api.Device{ID: "maint-dev-1", Driver: api.DriverShelly, Name: "Sump pump"}
+	rec = doRequest(t, router, "POST", "/api/devices", dev)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 while read-only, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), readOnly.Message) || rec.Header().Get("X-Maintenance-Mode") != "read-only" {
+		t.Errorf("Expected the banner message and maintenance header, got %q", rec.Body.String())
+	}
+	if rec := doRequest(t, router, "GET", "/api/devices", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected reads to keep working, got %d", rec.Code)
+	}
+
+	if rec := setMode("s3cret", api.MaintenanceMode{}); rec.Code != http.StatusOK {
+		t.Fatalf("Expected read-only mode to be turned off, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 after maintenance, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
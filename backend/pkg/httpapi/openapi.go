@@ -0,0 +1,512 @@
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/i18n"
+	"lifesupport/backend/pkg/importer"
+)
+
+// openAPIOperation describes a route for the OpenAPI document. Paths and methods are
+// read from the router, so every route is listed; this adds what the router doesn't know.
+type openAPIOperation struct {
+	// ID is the operationId client generators name methods after: the handler's name
+	ID      string
+	Summary string
+	Tag     string
+	Query   []string
+	// Request and Response are values of the JSON body types, or nil when there is no
+	// JSON body. openAPIOneOf lists alternatives.
+	Request  any
+	Response any
+	// RequestType is the media type of the JSON request body; defaults to application/json
+	RequestType string
+	// Form lists the fields of a multipart/form-data request; "file" is an upload
+	Form []string
+	// ContentType is the type of a response which is not JSON
+	ContentType string
+	// Status is the success status; defaults to 200
+	Status int
+}
+
+// openAPIOneOf is a body which may take any of several types
+type openAPIOneOf []any
+
+// openAPIOperations describes each route, keyed by method and path template. Routes
+// missing here are still documented, but without bodies; TestOpenAPI fails for them.
+var openAPIOperations = map[string]openAPIOperation{
+	// Devices
+	"POST /api/devices":                             {ID: "CreateDevice", Summary: "Create a device with its sensors and actuators", Tag: "devices", Request: api.Device{}, Response: api.Device{}, Status: http.StatusCreated},
+	"POST /api/devices:batch":                       {ID: "CreateDevices", Summary: "Create devices in bulk", Tag: "devices", Request: []*api.Device{}, Response: []api.DeviceBatchResult{}},
+	"GET /api/devices":                              {ID: "ListDevices", Summary: "List devices, paged when limit or cursor is set", Tag: "devices", Query: []string{"limit", "cursor", "metadata.{key}"}, Response: openAPIOneOf{[]*api.Device{}, api.DevicePage{}}},
+	"PATCH /api/devices":                            {ID: "PatchDevices", Summary: "Update the devices matching a selector", Tag: "devices", Query: []string{"selector"}, Request: api.DevicePatch{}, Response: []api.DeviceBatchResult{}},
+	"GET /api/devices/by-external-id/{external_id}": {ID: "GetDeviceByExternalID", Summary: "Get a device by external ID", Tag: "devices", Response: api.Device{}},
+	"GET /api/devices/{id}":                         {ID: "GetDevice", Summary: "Get a device", Tag: "devices", Response: api.Device{}},
+	"PUT /api/devices/{id}":                         {ID: "UpdateDevice", Summary: "Update a device", Tag: "devices", Request: api.Device{}, Response: api.Device{}},
+	"PATCH /api/devices/{id}":                       {ID: "PatchDevice", Summary: "Change some fields of a device", Tag: "devices", Request: api.Device{}, RequestType: mergePatchType, Response: api.Device{}},
+	"DELETE /api/devices/{id}":                      {ID: "DeleteDevice", Summary: "Delete a device", Tag: "devices", Status: http.StatusNoContent},
+	"GET /api/devices/{id}/delete-preview":          {ID: "GetDeviceDeletePreview", Summary: "Preview what deleting a device removes", Tag: "devices", Response: api.DeletePreview{}},
+	"POST /api/devices/{id}/clone":                  {ID: "CloneDevice", Summary: "Copy a device under a new ID and tag prefix", Tag: "devices", Request: api.DeviceClone{}, Response: api.Device{}, Status: http.StatusCreated},
+	"GET /api/devices/{id}/commands":                {ID: "GetDeviceCommands", Summary: "List a device's actuator commands", Tag: "devices", Query: []string{"actuator_id", "limit", "start_time", "end_time"}, Response: []*api.CommandRecord{}},
+	"GET /api/devices/{id}/history":                 {ID: "GetDeviceHistory", Summary: "List a device's configuration revisions", Tag: "devices", Query: []string{"limit"}, Response: []*api.DeviceRevision{}},
+	"GET /api/devices/{id}/latest-readings":         {ID: "GetDeviceLatestReadings", Summary: "Get the latest reading of each of a device's sensors", Tag: "devices", Response: []*api.ReadingRecord{}},
+	"GET /api/devices/{id}/credentials":             {ID: "ListDeviceCredentials", Summary: "List a device's credentials", Tag: "devices", Response: []*api.Credential{}},
+	"PUT /api/devices/{id}/credentials/{name}":      {ID: "SetDeviceCredential", Summary: "Set a device credential", Tag: "devices", Request: api.SetCredentialRequest{}, Response: api.Credential{}},
+	"GET /api/credentials/{id}":                     {ID: "GetCredential", Summary: "Get a credential", Tag: "devices", Response: api.Credential{}},
+	"DELETE /api/credentials/{id}":                  {ID: "DeleteCredential", Summary: "Delete a credential", Tag: "devices", Status: http.StatusNoContent},
+
+	// Assets
+	"GET /api/devices/{id}/assets":            {ID: "ListDeviceAssets", Summary: "List a device's images", Tag: "assets", Response: []*api.Asset{}},
+	"POST /api/devices/{id}/assets":           {ID: "UploadDeviceAsset", Summary: "Upload an image of a device", Tag: "assets", Form: []string{"kind", "file"}, Response: api.Asset{}, Status: http.StatusCreated},
+	"GET /api/subsystems/{subsystem}/assets":  {ID: "ListSubsystemAssets", Summary: "List a subsystem's images", Tag: "assets", Response: []*api.Asset{}},
+	"POST /api/subsystems/{subsystem}/assets": {ID: "UploadSubsystemAsset", Summary: "Upload an image of a subsystem", Tag: "assets", Form: []string{"kind", "file"}, Response: api.Asset{}, Status: http.StatusCreated},
+	"GET /api/assets/{id}":                    {ID: "GetAsset", Summary: "Get an image's details", Tag: "assets", Response: api.Asset{}},
+	"DELETE /api/assets/{id}":                 {ID: "DeleteAsset", Summary: "Delete an image", Tag: "assets", Status: http.StatusNoContent},
+	"GET /api/assets/{id}/content":            {ID: "GetAssetContent", Summary: "Download an image", Tag: "assets", ContentType: "application/octet-stream"},
+	"GET /api/assets/{id}/thumbnail":          {ID: "GetAssetThumbnail", Summary: "Download an image's thumbnail", Tag: "assets", ContentType: "image/jpeg"},
+
+	// Sensors
+	"POST /api/sensors":                                     {ID: "CreateSensor", Summary: "Create a sensor on a device", Tag: "sensors", Request: api.Sensor{}, Response: api.Sensor{}, Status: http.StatusCreated},
+	"GET /api/sensors":                                      {ID: "ListSensors", Summary: "List sensors, paged when limit or cursor is set", Tag: "sensors", Query: []string{"device_id", "limit", "cursor", "metadata.{key}"}, Response: openAPIOneOf{[]*api.Sensor{}, api.SensorPage{}}},
+	"GET /api/sensors/by-tag/{tag}":                         {ID: "GetSensorByTag", Summary: "Get a sensor by tag", Tag: "sensors", Response: api.Sensor{}},
+	"GET /api/sensors/by-external-id/{external_id}":         {ID: "GetSensorByExternalID", Summary: "Get a sensor by external ID", Tag: "sensors", Response: api.Sensor{}},
+	"GET /api/sensors/{device_id}/{sensor_id}":              {ID: "GetSensor", Summary: "Get a sensor", Tag: "sensors", Response: api.Sensor{}},
+	"PUT /api/sensors/{device_id}/{sensor_id}":              {ID: "UpdateSensor", Summary: "Update a sensor", Tag: "sensors", Request: api.Sensor{}, Response: api.Sensor{}},
+	"PATCH /api/sensors/{device_id}/{sensor_id}":            {ID: "PatchSensor", Summary: "Change some fields of a sensor", Tag: "sensors", Request: api.Sensor{}, RequestType: mergePatchType, Response: api.Sensor{}},
+	"DELETE /api/sensors/{device_id}/{sensor_id}":           {ID: "DeleteSensor", Summary: "Delete a sensor", Tag: "sensors", Status: http.StatusNoContent},
+	"GET /api/sensors/{device_id}/{sensor_id}/latest":       {ID: "GetLatestSensorReading", Summary: "Get a sensor's latest reading", Tag: "readings", Response: api.ReadingRecord{}},
+	"GET /api/sensors/{device_id}/{sensor_id}/trend":        {ID: "GetSensorTrend", Summary: "Compare a sensor's readings with a baseline period", Tag: "readings", Query: []string{"period", "start", "end", "baseline_start", "baseline_end"}, Response: api.TrendComparison{}},
+	"GET /api/sensors/{device_id}/{sensor_id}/impact":       {ID: "GetSensorImpact", Summary: "List what depends on a sensor", Tag: "sensors", Response: api.SensorImpact{}},
+	"GET /api/sensors/{device_id}/{sensor_id}/target":       {ID: "GetSensorTarget", Summary: "Get a sensor's target range", Tag: "sensors", Response: api.TargetRange{}},
+	"PUT /api/sensors/{device_id}/{sensor_id}/target":       {ID: "SetSensorTarget", Summary: "Set a sensor's target range", Tag: "sensors", Request: api.TargetRange{}, Response: api.TargetRange{}},
+	"DELETE /api/sensors/{device_id}/{sensor_id}/target":    {ID: "DeleteSensorTarget", Summary: "Delete a sensor's target range", Tag: "sensors", Status: http.StatusNoContent},
+	"GET /api/sensor-targets":                               {ID: "ListSensorTargets", Summary: "List sensor target ranges", Tag: "sensors", Response: []*api.TargetRange{}},
+	"GET /api/sensors/{device_id}/{sensor_id}/labels/{key}": {ID: "GetSensorLabelComparison", Summary: "Compare a sensor's readings across the values of a label", Tag: "readings", Response: api.LabelComparison{}},
+
+	// Actuators
+	"POST /api/actuators":                             {ID: "CreateActuator", Summary: "Create an actuator on a device", Tag: "actuators", Request: api.Actuator{}, Response: api.Actuator{}, Status: http.StatusCreated},
+	"GET /api/actuators":                              {ID: "ListActuators", Summary: "List actuators, paged when limit or cursor is set", Tag: "actuators", Query: []string{"device_id", "limit", "cursor", "metadata.{key}"}, Response: openAPIOneOf{[]*api.Actuator{}, api.ActuatorPage{}}},
+	"GET /api/actuators/by-tag/{tag}":                 {ID: "GetActuatorByTag", Summary: "Get an actuator by tag", Tag: "actuators", Response: api.Actuator{}},
+	"GET /api/actuators/by-tag/{tag}/status":          {ID: "GetActuatorLatestStatusByTag", Summary: "Get an actuator's status from its driver", Tag: "actuators", Query: []string{"newer_than"}, Response: api.SensorReading{}},
+	"GET /api/actuators/by-external-id/{external_id}": {ID: "GetActuatorByExternalID", Summary: "Get an actuator by external ID", Tag: "actuators", Response: api.Actuator{}},
+	"GET /api/actuators/{device_id}/{actuator_id}":    {ID: "GetActuator", Summary: "Get an actuator", Tag: "actuators", Response: api.Actuator{}},
+	"PUT /api/actuators/{device_id}/{actuator_id}":    {ID: "UpdateActuator", Summary: "Update an actuator", Tag: "actuators", Request: api.Actuator{}, Response: api.Actuator{}},
+	"PATCH /api/actuators/{device_id}/{actuator_id}":  {ID: "PatchActuator", Summary: "Change some fields of an actuator", Tag: "actuators", Request: api.Actuator{}, RequestType: mergePatchType, Response: api.Actuator{}},
+	"DELETE /api/actuators/{device_id}/{actuator_id}": {ID: "DeleteActuator", Summary: "Delete an actuator", Tag: "actuators", Status: http.StatusNoContent},
+
+	// Groups and tags
+	"POST /api/groups":                            {ID: "CreateGroup", Summary: "Create a device group", Tag: "groups", Request: api.Group{}, Response: api.Group{}, Status: http.StatusCreated},
+	"GET /api/groups":                             {ID: "ListGroups", Summary: "List device groups", Tag: "groups", Response: []*api.Group{}},
+	"GET /api/groups/{id}":                        {ID: "GetGroup", Summary: "Get a device group", Tag: "groups", Response: api.Group{}},
+	"PUT /api/groups/{id}":                        {ID: "UpdateGroup", Summary: "Update a device group", Tag: "groups", Request: api.Group{}, Response: api.Group{}},
+	"DELETE /api/groups/{id}":                     {ID: "DeleteGroup", Summary: "Delete a device group", Tag: "groups", Status: http.StatusNoContent},
+	"PUT /api/groups/{id}/devices/{device_id}":    {ID: "AddGroupDevice", Summary: "Add a device to a group", Tag: "groups", Status: http.StatusNoContent},
+	"DELETE /api/groups/{id}/devices/{device_id}": {ID: "RemoveGroupDevice", Summary: "Remove a device from a group", Tag: "groups", Status: http.StatusNoContent},
+	"GET /api/groups/{id}/readings":               {ID: "GetGroupReadings", Summary: "List the readings of a group's devices", Tag: "groups", Query: []string{"limit", "start_time", "end_time"}, Response: []*api.ReadingRecord{}},
+	"GET /api/groups/{id}/actuators":              {ID: "GetGroupActuators", Summary: "List the actuators of a group's devices", Tag: "groups", Response: []*api.Actuator{}},
+	"GET /api/tags/tree":                          {ID: "GetTagTree", Summary: "Get the tag hierarchy", Tag: "tags", Query: []string{"prefix"}, Response: api.TagNode{}},
+	"GET /api/tag-aliases":                        {ID: "ListTagAliases", Summary: "List tag aliases", Tag: "tags", Response: []*api.TagAlias{}},
+	"GET /api/tag-aliases/{alias}":                {ID: "GetTagAlias", Summary: "Get a tag alias", Tag: "tags", Response: api.TagAlias{}},
+	"PUT /api/tag-aliases/{alias}":                {ID: "SetTagAlias", Summary: "Set a tag alias", Tag: "tags", Request: api.TagAlias{}, Response: api.TagAlias{}},
+	"DELETE /api/tag-aliases/{alias}":             {ID: "DeleteTagAlias", Summary: "Delete a tag alias", Tag: "tags", Status: http.StatusNoContent},
+
+	// Rules
+	"GET /api/rules/broken":                  {ID: "ListBrokenReferences", Summary: "List rule references to missing sensors and actuators", Tag: "rules", Response: []*api.BrokenReference{}},
+	"DELETE /api/rules/broken/{kind}/{name}": {ID: "ClearBrokenReferences", Summary: "Clear a rule's broken references", Tag: "rules", Status: http.StatusNoContent},
+	"GET /api/rules/composite":               {ID: "ListCompositeRules", Summary: "List composite rules with their conditions evaluated", Tag: "rules", Response: []*api.CompositeRuleStatus{}},
+	"GET /api/rules/composite/{name}":        {ID: "GetCompositeRule", Summary: "Get a composite rule with its conditions evaluated", Tag: "rules", Response: api.CompositeRuleStatus{}},
+	"GET /api/rules/{kind}/{name}/trace":     {ID: "GetRuleTrace", Summary: "List a rule's recent evaluations", Tag: "rules", Query: []string{"limit"}, Response: []*api.RuleTrace{}},
+
+	// Actuator groups
+	"GET /api/actuator-groups":               {ID: "ListActuatorGroups", Summary: "List actuator groups with their next start order", Tag: "rules", Response: []*api.ActuatorGroupStatus{}},
+	"POST /api/actuator-groups/{name}/start": {ID: "StartActuatorGroup", Summary: "Switch an actuator group's members on in turn", Tag: "rules", Response: api.ActuatorGroupStatus{}},
+	"POST /api/actuator-groups/{name}/stop":  {ID: "StopActuatorGroup", Summary: "Switch an actuator group's members off", Tag: "rules", Response: api.ActuatorGroupStatus{}},
+
+	// Scenes
+	"GET /api/scenes":                  {ID: "ListScenes", Summary: "List scenes", Tag: "rules", Response: []api.Scene{}},
+	"POST /api/scenes/{name}/activate": {ID: "ActivateScene", Summary: "Apply a scene's commands", Tag: "rules", Response: api.SceneResult{}},
+
+	// Readings
+	"POST /api/sensor-readings":          {ID: "CreateSensorReadings", Summary: "Store sensor readings", Tag: "readings", Request: openAPIOneOf{api.ReadingRecord{}, []*api.ReadingRecord{}}, Status: http.StatusCreated},
+	"POST /api/sensor-readings:batch":    {ID: "CreateSensorReadingsBatch", Summary: "Store sensor readings together", Tag: "readings", Request: []*api.ReadingRecord{}, Response: map[string]int{}, Status: http.StatusCreated},
+	"GET /api/sensor-readings":           {ID: "GetSensorReadings", Summary: "List sensor readings", Tag: "readings", Query: []string{"device_id", "sensor_id", "limit", "start_time", "end_time", "before", "after"}, Response: []*api.ReadingRecord{}},
+	"GET /api/sensor-readings/aggregate": {ID: "GetAggregatedReadings", Summary: "Aggregate a sensor's readings into time buckets", Tag: "readings", Query: []string{"device_id", "sensor_id", "interval", "fns", "start_time", "end_time"}, Response: []*api.ReadingBucket{}},
+	"POST /api/reading-labels":           {ID: "CreateReadingLabel", Summary: "Label a period of readings", Tag: "readings", Request: api.ReadingLabel{}, Response: api.ReadingLabel{}, Status: http.StatusCreated},
+	"GET /api/reading-labels":            {ID: "ListReadingLabels", Summary: "List reading labels", Tag: "readings", Query: []string{"key", "device_id", "sensor_id", "start_time", "end_time"}, Response: []*api.ReadingLabel{}},
+	"DELETE /api/reading-labels/{id}":    {ID: "DeleteReadingLabel", Summary: "Delete a reading label", Tag: "readings", Status: http.StatusNoContent},
+	"POST /api/import":                   {ID: "ImportReadings", Summary: "Import historical readings from a file", Tag: "readings", Form: []string{"format", "config", "file"}, Response: importer.Result{}},
+
+	// Realtime updates
+	"GET /api/changes":             {ID: "ListChanges", Summary: "List the change feed after a cursor", Tag: "realtime", Query: []string{"after", "limit"}, Response: api.ChangeFeed{}},
+	"GET /api/events":              {ID: "Events", Summary: "Stream alerts and workflow status as Server-Sent Events", Tag: "realtime", ContentType: "text/event-stream"},
+	"POST /api/alerts/{id}/ack":    {ID: "AcknowledgeAlert", Summary: "Acknowledge an alert as the X-User", Tag: "realtime", Response: api.AlertAck{}},
+	"POST /api/slack/interactions": {ID: "SlackInteraction", Summary: "Acknowledge an alert from its Slack message", Tag: "realtime"},
+	"GET /api/session":             {ID: "Session", Summary: "Open the actuator command WebSocket", Tag: "realtime", Query: []string{"user"}, Status: http.StatusSwitchingProtocols},
+	"GET /api/stream":              {ID: "Stream", Summary: "Open the live readings and actuator states WebSocket", Tag: "realtime", Status: http.StatusSwitchingProtocols},
+	"GET /api/poll":                {ID: "Poll", Summary: "Long poll for readings, actuator states and alerts", Tag: "realtime", Query: []string{"after", "tag_prefix", "device_id", "alerts", "timeout"}, Response: api.PollResponse{}},
+	"GET /api/bootstrap":           {ID: "GetBootstrap", Summary: "Get everything the dashboard loads on start", Tag: "dashboard", Response: api.Bootstrap{}},
+
+	// System state
+	"GET /api/audit":           {ID: "ListAuditEntries", Summary: "List configuration changes and who made them", Tag: "config", Query: []string{"entity_type", "entity_id", "limit", "start_time", "end_time"}, Response: []*api.AuditEntry{}},
+	"GET /api/config/snapshot": {ID: "GetConfigSnapshot", Summary: "Get a snapshot of the configuration", Tag: "config", Response: api.ConfigSnapshot{}},
+	"POST /api/config/diff":    {ID: "DiffConfig", Summary: "Compare two configuration snapshots", Tag: "config", Request: configDiffRequest{}, Response: api.ConfigDiff{}},
+	"POST /api/config/import":  {ID: "ImportConfig", Summary: "Import a configuration snapshot as JSON or YAML", Tag: "config", Request: api.ConfigSnapshot{}, Response: api.ConfigDiff{}},
+	"GET /api/export":          {ID: "ExportConfig", Summary: "Export the configuration as JSON or YAML", Tag: "config", Query: []string{"format"}, Response: api.ConfigSnapshot{}},
+	"GET /api/health-score":    {ID: "GetHealthScore", Summary: "Rate each subsystem's health", Tag: "dashboard", Query: []string{"stale_after"}, Response: api.HealthScore{}},
+	"GET /api/topology":        {ID: "GetTopology", Summary: "Get the graph of devices, sensors, actuators and rules", Tag: "dashboard", Response: api.Topology{}},
+	"GET /api/workers":         {ID: "ListWorkers", Summary: "List workers and what they own", Tag: "workers", Response: []api.WorkerPresence{}},
+	"DELETE /api/workers/{id}": {ID: "ForgetWorker", Summary: "Forget a worker which has gone away", Tag: "workers", Status: http.StatusNoContent},
+	"GET /api/leases":          {ID: "ListLeases", Summary: "List held leases", Tag: "workers", Response: []*api.Lease{}},
+	"GET /api/status-page":     {ID: "GetStatusPage", Summary: "Get the public status page", Tag: "dashboard", Response: api.StatusPage{}},
+	"GET /api/features":        {ID: "GetFeatures", Summary: "Get which features are on for the client", Tag: "dashboard", Response: map[string]bool{}},
+	"GET /api/i18n":            {ID: "GetI18n", Summary: "Get localized display names", Tag: "dashboard", Query: []string{"lang"}, Response: i18n.Catalog{}},
+	"GET /api/i18n/languages":  {ID: "ListLanguages", Summary: "List the languages display names are localized in", Tag: "dashboard", Response: []string{}},
+
+	// Administration
+	"GET /api/maintenance":                                     {ID: "GetMaintenanceMode", Summary: "Get maintenance mode", Tag: "admin", Response: api.MaintenanceMode{}},
+	"PUT /api/maintenance":                                     {ID: "SetMaintenanceMode", Summary: "Set maintenance mode", Tag: "admin", Request: api.MaintenanceMode{}, Response: api.MaintenanceMode{}},
+	"GET /api/admin/features":                                  {ID: "ListFeatureFlags", Summary: "List feature flags", Tag: "admin", Response: []*api.FeatureFlag{}},
+	"PUT /api/admin/features/{name}":                           {ID: "SetFeatureFlag", Summary: "Set a feature flag", Tag: "admin", Request: api.FeatureFlag{}, Response: api.FeatureFlag{}},
+	"DELETE /api/admin/features/{name}":                        {ID: "DeleteFeatureFlag", Summary: "Delete a feature flag", Tag: "admin", Status: http.StatusNoContent},
+	"GET /api/admin/retention":                                 {ID: "ListRetentionPolicies", Summary: "List reading retention policies", Tag: "admin", Response: []*api.RetentionPolicy{}},
+	"PUT /api/admin/retention/{sensor_type}":                   {ID: "SetRetentionPolicy", Summary: "Set a sensor type's retention policy", Tag: "admin", Request: api.RetentionPolicy{}, Response: api.RetentionPolicy{}},
+	"DELETE /api/admin/retention/{sensor_type}":                {ID: "DeleteRetentionPolicy", Summary: "Delete a sensor type's retention policy", Tag: "admin", Status: http.StatusNoContent},
+	"GET /api/admin/storage":                                   {ID: "GetStorageReport", Summary: "Report storage use", Tag: "admin", Query: []string{"window"}, Response: api.StorageReport{}},
+	"GET /api/admin/storage/quotas":                            {ID: "ListStorageQuotas", Summary: "List sensor storage quotas", Tag: "admin", Response: []*api.StorageQuota{}},
+	"PUT /api/admin/storage/quotas/{device_id}/{sensor_id}":    {ID: "SetStorageQuota", Summary: "Set a sensor's storage quota", Tag: "admin", Request: api.StorageQuota{}, Response: api.StorageQuota{}},
+	"DELETE /api/admin/storage/quotas/{device_id}/{sensor_id}": {ID: "DeleteStorageQuota", Summary: "Delete a sensor's storage quota", Tag: "admin", Status: http.StatusNoContent},
+	"GET /api/admin/slow-queries":                              {ID: "ListSlowQueries", Summary: "List slow storage queries", Tag: "admin", Query: []string{"limit"}, Response: []*api.SlowQuery{}},
+	"DELETE /api/admin/slow-queries":                           {ID: "ClearSlowQueries", Summary: "Clear the slow query log", Tag: "admin", Status: http.StatusNoContent},
+	"GET /api/admin/config":                                    {ID: "GetAdminConfig", Summary: "Get the effective configuration", Tag: "admin", Response: api.AdminConfig{}},
+	"POST /api/admin/tags/rename":                              {ID: "RenameTag", Summary: "Rename a tag everywhere it is used", Tag: "admin", Request: renameTagRequest{}, Response: retagResponse{}},
+	"POST /api/admin/tags/merge":                               {ID: "MergeTags", Summary: "Merge tags into one", Tag: "admin", Request: mergeTagsRequest{}, Response: retagResponse{}},
+	"GET /api/admin/tags/templates":                            {ID: "ListTagTemplates", Summary: "List tag templates", Tag: "admin", Response: []*api.TagTemplate{}},
+	"PUT /api/admin/tags/templates/{kind}":                     {ID: "SetTagTemplate", Summary: "Set a tag template", Tag: "admin", Request: api.TagTemplate{}, Response: api.TagTemplate{}},
+	"DELETE /api/admin/tags/templates/{kind}":                  {ID: "DeleteTagTemplate", Summary: "Delete a tag template", Tag: "admin", Status: http.StatusNoContent},
+	"GET /api/logging":                                         {ID: "GetLogLevels", Summary: "Get per-component log levels", Tag: "admin", Response: api.LogLevels{}},
+	"PUT /api/logging":                                         {ID: "SetLogLevels", Summary: "Set per-component log levels", Tag: "admin", Request: api.LogLevels{}, Response: api.LogLevels{}},
+
+	// Workflows
+	"POST /api/workflows/discovery":   {ID: "StartDiscoveryWorkflow", Summary: "Start a device discovery workflow", Tag: "workflows", Request: api.StartWorkflowRequest{}, Response: api.StartWorkflowResponse{}, Status: http.StatusCreated},
+	"GET /api/workflows/{workflowId}": {ID: "GetWorkflowStatus", Summary: "Get a workflow's status, and a discovery's result", Tag: "workflows", Response: api.DiscoveryWorkflowInfo{}},
+	"GET /api/workflows":              {ID: "ListWorkflows", Summary: "List recent discovery workflows", Tag: "workflows", Response: []api.WorkflowInfo{}},
+
+	// This document
+	"GET /api/openapi.json": {ID: "GetOpenAPI", Summary: "Get this OpenAPI document", Tag: "docs", Response: map[string]any{}},
+	"GET /api/docs":         {ID: "GetAPIDocs", Summary: "Browse this document in Swagger UI", Tag: "docs", ContentType: "text/html"},
+}
+
+// openAPIDocument is an OpenAPI 3 document, holding only what the generator produces
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]*openAPIPathItem `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*jsonSchema `json:"schemas"`
+}
+
+// openAPIPathItem is an operation on a path
+type openAPIPathItem struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *jsonSchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Content map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content,omitempty"`
+}
+
+type openAPIMedia struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+// jsonSchema is the subset of an OpenAPI schema object the generator produces
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// newOpenAPIDocument documents every route on router
+func newOpenAPIDocument(router *mux.Router) (*openAPIDocument, error) {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "Life Support API",
+			Description: "Errors are returned as plain text with a 4xx or 5xx status.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]map[string]*openAPIPathItem{},
+	}
+	schemas := newSchemaRegistry()
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			key := strings.ToLower(method)
+			if doc.Paths[tmpl] == nil {
+				doc.Paths[tmpl] = map[string]*openAPIPathItem{}
+			}
+			// Routes sharing a method and path, told apart by headers, are documented once
+			if _, ok := doc.Paths[tmpl][key]; ok {
+				continue
+			}
+			doc.Paths[tmpl][key] = openAPIOperations[method+" "+tmpl].pathItem(tmpl, schemas)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc.Components.Schemas = schemas.schemas
+	return doc, nil
+}
+
+func (op openAPIOperation) pathItem(tmpl string, schemas *schemaRegistry) *openAPIPathItem {
+	item := &openAPIPathItem{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Responses:   map[string]*openAPIResponse{},
+	}
+	if op.Tag != "" {
+		item.Tags = []string{op.Tag}
+	}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(tmpl, -1) {
+		item.Parameters = append(item.Parameters, &openAPIParameter{Name: m[1], In: "path", Required: true, Schema: &jsonSchema{Type: "string"}})
+	}
+	for _, name := range op.Query {
+		item.Parameters = append(item.Parameters, &openAPIParameter{Name: name, In: "query", Schema: &jsonSchema{Type: "string"}})
+	}
+
+	if op.Request != nil || len(op.Form) > 0 {
+		item.RequestBody = &openAPIBody{Content: map[string]openAPIMedia{}}
+		if op.Request != nil {
+			requestType := op.RequestType
+			if requestType == "" {
+				requestType = "application/json"
+			}
+			item.RequestBody.Content[requestType] = openAPIMedia{Schema: schemas.body(op.Request)}
+		}
+		if len(op.Form) > 0 {
+			form := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+			for _, field := range op.Form {
+				form.Properties[field] = &jsonSchema{Type: "string"}
+				if field == "file" {
+					form.Properties[field].Format = "binary"
+				}
+			}
+			item.RequestBody.Content["multipart/form-data"] = openAPIMedia{Schema: form}
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &openAPIResponse{Description: http.StatusText(status)}
+	switch {
+	case op.Response != nil:
+		resp.Content = map[string]openAPIMedia{"application/json": {Schema: schemas.body(op.Response)}}
+	case op.ContentType != "":
+		resp.Content = map[string]openAPIMedia{op.ContentType: {Schema: &jsonSchema{Type: "string"}}}
+	}
+	item.Responses[strconv.Itoa(status)] = resp
+	item.Responses["default"] = &openAPIResponse{
+		Description: "Error",
+		Content:     map[string]openAPIMedia{"text/plain": {Schema: &jsonSchema{Type: "string"}}},
+	}
+	return item
+}
+
+// schemaRegistry builds schemas from Go types by the same rules as encoding/json,
+// keeping named structs as components
+type schemaRegistry struct {
+	schemas map[string]*jsonSchema
+	names   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]*jsonSchema{}, names: map[reflect.Type]string{}}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// body is the schema of a request or response body value
+func (s *schemaRegistry) body(v any) *jsonSchema {
+	if alternatives, ok := v.(openAPIOneOf); ok {
+		schema := &jsonSchema{}
+		for _, alt := range alternatives {
+			schema.OneOf = append(schema.OneOf, s.schema(reflect.TypeOf(alt)))
+		}
+		return schema
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemaRegistry) schema(t reflect.Type) *jsonSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &jsonSchema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &jsonSchema{Type: "string", Format: "byte"}
+		}
+		return &jsonSchema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+	return &jsonSchema{}
+}
+
+// ref registers a named struct as a component, named after the type or, if another
+// package has a type of that name, the package and type
+func (s *schemaRegistry) ref(t reflect.Type) *jsonSchema {
+	name, ok := s.names[t]
+	if !ok {
+		// Request types private to this package are named as if exported
+		name = strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, taken := s.schemas[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		s.names[t] = name
+		// Registered before its fields, so types which contain themselves refer back
+		s.schemas[name] = &jsonSchema{}
+		*s.schemas[name] = *s.object(t)
+	}
+	return &jsonSchema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemaRegistry) object(t reflect.Type) *jsonSchema {
+	obj := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	s.fields(t, obj.Properties)
+	return obj
+}
+
+// fields adds t's JSON fields to props, including those of embedded structs
+func (s *schemaRegistry) fields(t reflect.Type, props map[string]*jsonSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, props)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+	}
+}
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// GetOpenAPI handles GET /api/openapi.json, serving an OpenAPI 3 document of every route
+// for generating client SDKs
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	h.openAPIOnce.Do(func() {
+		doc, err := newOpenAPIDocument(h.SetupRouter())
+		if err != nil {
+			h.openAPIErr = fmt.Errorf("failed to document routes: %w", err)
+			return
+		}
+		h.openAPI, h.openAPIErr = json.Marshal(doc)
+	})
+	if h.openAPIErr != nil {
+		http.Error(w, "Failed to generate OpenAPI document: "+h.openAPIErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.openAPI)
+}
+
+// GetAPIDocs handles GET /api/docs, serving Swagger UI for the OpenAPI document
+func (h *Handler) GetAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(swaggerHTML)
+}
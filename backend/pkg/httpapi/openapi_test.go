@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPI(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	router := h.SetupRouter()
+	rec := doRequest(t, router, "GET", "/api/openapi.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	var doc openAPIDocument
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	// Every route is described, and every description is of a route
+	ids := map[string]string{}
+	described := map[string]bool{}
+	for p, ops := range doc.Paths {
+		for method, op := range ops {
+			key := strings.ToUpper(method) + " " + p
+			described[key] = true
+			if op.OperationID == "" || op.Summary == "" {
+				t.Errorf("Expected %s to be described in openAPIOperations", key)
+				continue
+			}
+			if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("Expected unique operation IDs, got %s for %s and %s", op.OperationID, key, other)
+			}
+			ids[op.OperationID] = key
+		}
+	}
+	for key := range openAPIOperations {
+		if !described[key] {
+			t.Errorf("Expected %s in openAPIOperations to match a route", key)
+		}
+	}
+
+	// References resolve to components
+	for _, m := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(body, -1) {
+		if doc.Components.Schemas[m[1]] == nil {
+			t.Errorf("Expected schema %s to be a component", m[1])
+		}
+	}
+
+	getDevice := doc.Paths["/api/devices/{id}"]["get"]
+	if len(getDevice.Parameters) != 1 || getDevice.Parameters[0].Name != "id" || getDevice.Parameters[0].In != "path" {
+		t.Errorf("Expected the id path parameter, got %+v", getDevice.Parameters)
+	}
+	if ref := getDevice.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/Device" {
+		t.Errorf("Expected a Device response, got %q", ref)
+	}
+	device := doc.Components.Schemas["Device"]
+	if device == nil || device.Properties["sensors"] == nil || device.Properties["sensors"].Items.Ref != "#/components/schemas/Sensor" {
+		t.Errorf("Expected the Device schema to list its sensors, got %+v", device)
+	}
+	if created := doc.Paths["/api/devices"]["post"].Responses["201"]; created == nil {
+		t.Error("Expected device creation to answer 201")
+	}
+
+	rec = doRequest(t, router, "GET", "/api/docs", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/api/openapi.json") {
+		t.Errorf("Expected Swagger UI for the document, got %d", rec.Code)
+	}
+}
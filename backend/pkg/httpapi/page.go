@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"lifesupport/backend/pkg/storer"
+)
+
+// Page sizes of the device, sensor and actuator lists
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parsePage reads the limit and cursor parameters of a list endpoint. Lists without
+// either are returned whole, as a bare array, for existing clients; paged reports
+// whether the response is a page envelope instead. Metadata filters apply either way.
+func parsePage(r *http.Request) (page storer.Page, paged bool, err error) {
+	q := r.URL.Query()
+	page.Metadata = parseMetadataFilter(q)
+	if !q.Has("limit") && !q.Has("cursor") {
+		return page, false, nil
+	}
+	page.Cursor, page.Limit = q.Get("cursor"), defaultPageLimit
+	if v := q.Get("limit"); v != "" {
+		page.Limit, err = strconv.Atoi(v)
+		if err != nil || page.Limit < 1 || page.Limit > maxPageLimit {
+			return page, true, errors.New("must be between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+	}
+	return page, true, nil
+}
+
+// parseMetadataFilter reads metadata.<key>=<value> parameters, restricting a list to
+// items with every key set to the value
+func parseMetadataFilter(q url.Values) map[string]string {
+	var filter map[string]string
+	for k, v := range q {
+		key, ok := strings.CutPrefix(k, "metadata.")
+		if !ok || key == "" {
+			continue
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[key] = v[0]
+	}
+	return filter
+}
+
+// pageErrorStatus is the status for an error listing a page
+func pageErrorStatus(err error) int {
+	if errors.Is(err, storer.ErrInvalidCursor) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
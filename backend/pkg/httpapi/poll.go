@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const (
+	// defaultPollTimeout is how long a long poll waits for messages unless it sets timeout
+	defaultPollTimeout = 30 * time.Second
+	// maxPollTimeout bounds a long poll's timeout, below common proxy idle timeouts
+	maxPollTimeout = 60 * time.Second
+)
+
+// Poll handles GET /api/poll, a long poll for clients which cannot hold a WebSocket or
+// event stream open. It returns the readings and actuator states selected by each
+// tag_prefix and device_id parameter, and alerts and acknowledgements when alerts is
+// true, from the change feed after the after cursor. It waits up to timeout for the
+// first of them, returning none if there are none by then.
+func (h *Handler) Poll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	var cursor int64
+	var err error
+	if v := q.Get("after"); v != "" {
+		cursor, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 0 {
+			http.Error(w, "Invalid after parameter", http.StatusBadRequest)
+			return
+		}
+	} else if cursor, err = h.Store.LatestChangeSeq(ctx); err != nil {
+		http.Error(w, "Failed to read the change feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	timeout := defaultPollTimeout
+	if v := q.Get("timeout"); v != "" {
+		timeout, err = time.ParseDuration(v)
+		if err != nil || timeout < 0 || timeout > maxPollTimeout {
+			http.Error(w, "Invalid timeout parameter: must be a duration up to "+maxPollTimeout.String(), http.StatusBadRequest)
+			return
+		}
+	}
+	alerts := q.Get("alerts") == "true"
+
+	var subs []*streamSubscription
+	for _, prefix := range q["tag_prefix"] {
+		sub, err := resolveSubscription(ctx, h.Store, prefix, "")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		subs = append(subs, sub)
+	}
+	for _, deviceID := range q["device_id"] {
+		subs = append(subs, &streamSubscription{deviceID: deviceID})
+	}
+	if len(subs) == 0 && !alerts {
+		http.Error(w, "At least one of tag_prefix, device_id or alerts=true is required", http.StatusBadRequest)
+		return
+	}
+
+	interval := h.StreamInterval
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tail := &changeTail{store: h.Store, cursor: cursor}
+	messages, err := pollMessages(ctx, tail, interval, func(change *api.ChangeEvent) bool {
+		switch change.Type {
+		case api.ChangeAlertFired, api.ChangeAlertAcknowledged:
+			return alerts
+		case api.ChangeReadingStored, api.ChangeActuatorState:
+			for _, sub := range subs {
+				if sub.matches(change.Type, change.EntityID) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+	if err != nil {
+		http.Error(w, "Failed to read the change feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(api.PollResponse{Messages: messages, Cursor: tail.cursor})
+}
+
+// pollMessages reads the tail every interval until it finds changes selected by match or
+// ctx ends, returning the messages for those changes; the tail is left after the last
+// change read, whether or not it was selected
+func pollMessages(ctx context.Context, tail *changeTail, interval time.Duration, match func(*api.ChangeEvent) bool) ([]*api.StreamMessage, error) {
+	messages := []*api.StreamMessage{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		changes, err := tail.read(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The poll timed out or the client went away mid-read
+				return messages, nil
+			}
+			return nil, err
+		}
+		for _, change := range changes {
+			if !match(change) {
+				continue
+			}
+			msg, err := streamMessage(change)
+			if err != nil {
+				ll := logCtx(ctx, "poll")
+				ll.Warn().Err(err).Int64("seq", change.Seq).Msg("failed to decode change")
+				continue
+			}
+			messages = append(messages, msg)
+		}
+		if len(messages) > 0 {
+			return messages, nil
+		}
+		if len(changes) == streamBatchSize {
+			// Further unread changes may match, so read on without waiting
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return messages, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/changefeed"
+)
+
+func TestPoll(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "tank-1", Driver: api.DriverShelly, Name: "Tank",
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"tank.temp"}}}},
+		{ID: "sump-1", Driver: api.DriverShelly, Name: "Sump",
+			Sensors: []*api.Sensor{{ID: "level", Name: "Level", SensorType: api.SensorTypeWaterDepth}}},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	h := NewHandler(store, nil, nil)
+	h.StreamInterval = 10 * time.Millisecond
+	router := h.SetupRouter()
+	poll := func(query string) api.PollResponse {
+		t.Helper()
+		rec := doRequest(t, router, "GET", "/api/poll?"+query, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp api.PollResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	// Without a cursor the poll starts from now, and times out with nothing
+	start := poll("tag_prefix=tank.&timeout=20ms")
+	if len(start.Messages) != 0 {
+		t.Errorf("Expected no messages, got %+v", start.Messages)
+	}
+
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "sump-1", SensorID: "level", Reading: api.SensorReading{Value: 12, Valid: true, Timestamp: time.Now()}})
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Valid: true, Timestamp: time.Now()}})
+	changefeed.NewAlertRecorder(store).Notify(ctx, &api.Alert{ID: "leak-1", Title: "Leak detected"})
+
+	after := func(cursor int64) string { return "after=" + strconv.FormatInt(cursor, 10) }
+	got := poll("tag_prefix=tank.&" + after(start.Cursor))
+	if len(got.Messages) != 1 || got.Messages[0].Type != api.StreamReading || got.Messages[0].Reading.Reading.Value != 25.5 {
+		t.Fatalf("Expected the tank reading alone, got %+v", got.Messages)
+	}
+	if got.Cursor <= start.Cursor {
+		t.Errorf("Expected the cursor to move past the changes read, got %d", got.Cursor)
+	}
+	if got := poll("tag_prefix=tank.&timeout=20ms&" + after(got.Cursor)); len(got.Messages) != 0 {
+		t.Errorf("Expected nothing after the cursor, got %+v", got.Messages)
+	}
+
+	got = poll("device_id=sump-1&alerts=true&" + after(start.Cursor))
+	if len(got.Messages) != 2 || got.Messages[0].Reading == nil || got.Messages[0].Reading.DeviceID != "sump-1" ||
+		got.Messages[1].Type != api.StreamAlert || got.Messages[1].Alert.ID != "leak-1" {
+		t.Errorf("Expected the sump reading and the alert, got %+v", got.Messages)
+	}
+
+	for _, query := range []string{"", "tag_prefix=tank.&after=-1", "alerts=true&timeout=2m"} {
+		if rec := doRequest(t, router, "GET", "/api/poll?"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/compact"
+	"lifesupport/backend/pkg/ingest"
+	"lifesupport/backend/pkg/storer"
+)
+
+// maxReadingsBody bounds the size of an ingestion request body
+const maxReadingsBody = 4 << 20
+
+// CreateSensorReadings handles POST /api/sensor-readings. The body is either JSON (a single
+// reading record or an array of them) or, with Content-Type application/cbor, the
+// compact array form from pkg/compact.
+func (h *Handler) CreateSensorReadings(w http.ResponseWriter, r *http.Request) {
+	readings, err := decodeReadings(r)
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	for _, rec := range readings {
+		if rec.Reading.Timestamp.IsZero() {
+			rec.Reading.Timestamp = time.Now()
+		}
+		if err := h.Store.StoreSensorReading(ctx, rec); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, storer.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, "Failed to store sensor reading: "+err.Error(), status)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// CreateSensorReadingsBatch handles POST /api/sensor-readings:batch. The body is an array
+// of reading records, in either form CreateSensorReadings accepts, stored together with
+// one multi-row insert: if any names an unknown sensor, none are stored.
+func (h *Handler) CreateSensorReadingsBatch(w http.ResponseWriter, r *http.Request) {
+	readings, err := decodeReadings(r)
+	if err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(readings) == 0 {
+		http.Error(w, "At least one reading is required", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	for _, rec := range readings {
+		if rec.Reading.Timestamp.IsZero() {
+			rec.Reading.Timestamp = now
+		}
+	}
+	if err := h.Store.StoreSensorReadings(r.Context(), readings); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, storer.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "Failed to store sensor readings: "+err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"stored": len(readings)})
+}
+
+func decodeReadings(r *http.Request) ([]*api.ReadingRecord, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxReadingsBody))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == compact.ContentType {
+		return compact.Decode(body)
+	}
+	return ingest.DecodePayload(body)
+}
+
+// GetSensorReadings handles GET /api/sensor-readings
+func (h *Handler) GetSensorReadings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filters := storer.SensorReadingFilters{
+		DeviceID: q.Get("device_id"),
+		SensorID: q.Get("sensor_id"),
+	}
+	for name, dst := range map[string]**time.Time{"start_time": &filters.StartTime, "end_time": &filters.EndTime} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filters.Limit = limit
+	}
+	for name, dst := range map[string]**storer.ReadingKey{"before": &filters.Before, "after": &filters.After} {
+		if v := q.Get(name); v != "" {
+			k, err := parseReadingCursor(v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter", http.StatusBadRequest)
+				return
+			}
+			*dst = k
+		}
+	}
+
+	readings, err := h.Store.GetSensorReadings(r.Context(), filters)
+	if err != nil {
+		http.Error(w, "Failed to get sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.applyTargets(r.Context(), readings); err != nil {
+		http.Error(w, "Failed to get sensor targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if readings == nil {
+		readings = []*api.ReadingRecord{}
+	}
+	setReadingLinks(w, r, filters, readings)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(readings)
+}
+
+// Aggregation windows and their bounds
+const (
+	defaultAggregateWindow = 24 * time.Hour
+	maxAggregateBuckets    = 10000
+)
+
+// GetAggregatedReadings handles GET /api/sensor-readings/aggregate, bucketing the valid,
+// measured readings of the sensor named by device_id and sensor_id into rollups of
+// interval between start_time and end_time, the last day by default. fns is a comma
+// separated list of the statistics to report, all of them by default.
+func (h *Handler) GetAggregatedReadings(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	deviceID, sensorID := q.Get("device_id"), q.Get("sensor_id")
+	if deviceID == "" || sensorID == "" {
+		http.Error(w, "device_id and sensor_id are required", http.StatusBadRequest)
+		return
+	}
+	interval, err := time.ParseDuration(q.Get("interval"))
+	if err != nil || interval < time.Second {
+		http.Error(w, "Invalid interval parameter, expected a duration of at least a second such as 1m", http.StatusBadRequest)
+		return
+	}
+	fns := api.AggregateFuncs
+	if v := q.Get("fns"); v != "" {
+		fns = nil
+		for _, name := range strings.Split(v, ",") {
+			fn := api.AggregateFunc(strings.TrimSpace(name))
+			if !slices.Contains(api.AggregateFuncs, fn) {
+				http.Error(w, "Invalid fns parameter: unknown function "+string(fn), http.StatusBadRequest)
+				return
+			}
+			fns = append(fns, fn)
+		}
+	}
+	end := time.Now()
+	if v := q.Get("end_time"); v != "" {
+		if end, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Invalid end_time parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	start := end.Add(-defaultAggregateWindow)
+	if v := q.Get("start_time"); v != "" {
+		if start, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Invalid start_time parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !start.Before(end) {
+		http.Error(w, "start_time must be before end_time", http.StatusBadRequest)
+		return
+	}
+	if end.Sub(start)/interval > maxAggregateBuckets {
+		http.Error(w, "Too many buckets: widen the interval or narrow the window to at most "+strconv.Itoa(maxAggregateBuckets), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := h.Store.GetAggregatedReadings(r.Context(), deviceID, sensorID, interval, fns, start, end)
+	if err != nil {
+		http.Error(w, "Failed to aggregate sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+// readingCursor is the JSON form of a storer.ReadingKey in before and after parameters
+type readingCursor struct {
+	Timestamp time.Time `json:"t"`
+	ID        int64     `json:"i"`
+}
+
+func formatReadingCursor(rec *api.ReadingRecord) string {
+	b, _ := json.Marshal(readingCursor{Timestamp: rec.Reading.Timestamp, ID: rec.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseReadingCursor(v string) (*storer.ReadingKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	var c readingCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.Timestamp.IsZero() {
+		return nil, errors.New("missing timestamp")
+	}
+	return &storer.ReadingKey{Timestamp: c.Timestamp, ID: c.ID}, nil
+}
+
+// setReadingLinks adds a Link header (RFC 8288) to a page of readings: rel="next" pages
+// to older readings and rel="prev" to newer ones. Readings stay a bare array, so
+// existing clients are unaffected.
+func setReadingLinks(w http.ResponseWriter, r *http.Request, filters storer.SensorReadingFilters, readings []*api.ReadingRecord) {
+	if len(readings) == 0 {
+		return
+	}
+	link := func(rel, param string, rec *api.ReadingRecord) string {
+		q := r.URL.Query()
+		q.Del("before")
+		q.Del("after")
+		q.Set(param, formatReadingCursor(rec))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, q.Encode(), rel)
+	}
+	var links []string
+	// A full page may have older readings after it; paging forward from After always does
+	if (filters.Limit > 0 && len(readings) == filters.Limit) || filters.After != nil {
+		links = append(links, link("next", "before", readings[len(readings)-1]))
+	}
+	if filters.Before != nil || filters.After != nil {
+		links = append(links, link("prev", "after", readings[0]))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,222 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/compact"
+)
+
+func TestDecodeReadings(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cbor := compact.Encode([]*api.ReadingRecord{
+		{DeviceID: "gw-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Timestamp: ts, Valid: true}},
+		{DeviceID: "gw-1", SensorID: "ph", Reading: api.SensorReading{Value: 8.2, Timestamp: ts, Valid: true}},
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        int
+	}{
+		{"json object", "application/json", []byte(`{"device_id":"gw-1","sensor_id":"temp","reading":{"value":25.5,"valid":true}}`), 1},
+		{"json array", "application/json", []byte(` [{"device_id":"gw-1","sensor_id":"temp"},{"device_id":"gw-1","sensor_id":"ph"}]`), 2},
+		{"compact", compact.ContentType, cbor, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/sensor-readings", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			readings, err := decodeReadings(req)
+			if err != nil {
+				t.Fatalf("decodeReadings() error = %v", err)
+			}
+			if len(readings) != tt.want {
+				t.Fatalf("Expected %d readings, got %d", tt.want, len(readings))
+			}
+			if readings[0].DeviceID != "gw-1" || readings[0].SensorID != "temp" {
+				t.Errorf("Unexpected first reading: %+v", readings[0])
+			}
+		})
+	}
+}
+
+func TestCreateSensorReadings_InvalidBody(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/sensor-readings", strings.NewReader("\x01\x02"))
+	req.Header.Set("Content-Type", compact.ContentType)
+	rec := httptest.NewRecorder()
+	h.SetupRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestGetSensorReadings_Paging(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{ID: "page-gw", Driver: api.DriverShelly, Name: "Gateway",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	ts := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Two readings share a timestamp, so pages must be keyed on the ID too
+	for i, offset := range []int{0, 1, 1, 2, 3} {
+		body := api.ReadingRecord{DeviceID: "page-gw", SensorID: "temp",
+			Reading: api.SensorReading{Value: float64(i), Valid: true, Timestamp: ts.Add(time.Duration(offset) * time.Minute)}}
+		if rec := doRequest(t, router, "POST", "/api/sensor-readings", body); rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	getPage := func(path string) ([]float64, map[string]string) {
+		t.Helper()
+		rec := doRequest(t, router, "GET", path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var readings []*api.ReadingRecord
+		if err := json.NewDecoder(rec.Body).Decode(&readings); err != nil {
+			t.Fatalf("Failed to decode readings: %v", err)
+		}
+		var values []float64
+		for _, r := range readings {
+			values = append(values, r.Reading.Value)
+		}
+		links := map[string]string{}
+		for _, l := range strings.Split(rec.Header().Get("Link"), ", ") {
+			if url, rel, ok := strings.Cut(l, `>; rel="`); ok {
+				links[strings.TrimSuffix(rel, `"`)] = strings.TrimPrefix(url, "<")
+			}
+		}
+		return values, links
+	}
+
+	values, links := getPage("/api/sensor-readings?device_id=page-gw&limit=2")
+	if fmt.Sprint(values) != "[4 3]" || links["next"] == "" {
+		t.Fatalf("Expected the newest 2 readings and a next link, got %v %v", values, links)
+	}
+	values, links = getPage(links["next"])
+	if fmt.Sprint(values) != "[2 1]" {
+		t.Fatalf("Expected readings 2 and 1, got %v", values)
+	}
+	older := links["next"]
+	values, _ = getPage(older)
+	if fmt.Sprint(values) != "[0]" {
+		t.Errorf("Expected the oldest reading, got %v", values)
+	}
+	values, _ = getPage(links["prev"])
+	if fmt.Sprint(values) != "[4 3]" {
+		t.Errorf("Expected paging back to the newest readings, got %v", values)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/sensor-readings?before=bogus", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a bad cursor, got %d", rec.Code)
+	}
+}
+
+func TestCreateSensorReadingsBatch(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{ID: "batch-gw", Driver: api.DriverShelly, Name: "Gateway",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	ts := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	batch := make([]api.ReadingRecord, 50)
+	for i := range batch {
+		batch[i] = api.ReadingRecord{DeviceID: "batch-gw", SensorID: "temp",
+			Reading: api.SensorReading{Value: float64(i), Valid: true, Timestamp: ts.Add(time.Duration(i) * time.Second)}}
+	}
+	rec := doRequest(t, router, "POST", "/api/sensor-readings:batch", batch)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result map[string]int
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil || result["stored"] != 50 {
+		t.Errorf("Expected 50 readings stored, got %v (%v)", result, err)
+	}
+
+	// One unknown sensor fails the whole batch
+	bad := append(batch[:1:1], api.ReadingRecord{DeviceID: "batch-gw", SensorID: "missing", Reading: api.SensorReading{Timestamp: ts}})
+	if rec := doRequest(t, router, "POST", "/api/sensor-readings:batch", bad); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "POST", "/api/sensor-readings:batch", []api.ReadingRecord{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty batch, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensor-readings?device_id=batch-gw&limit=100", nil)
+	var readings []*api.ReadingRecord
+	if err := json.NewDecoder(rec.Body).Decode(&readings); err != nil {
+		t.Fatalf("Failed to decode readings: %v", err)
+	}
+	if len(readings) != 50 {
+		t.Errorf("Expected 50 readings, got %d", len(readings))
+	}
+}
+
+func TestGetAggregatedReadings(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{ID: "agg-gw", Driver: api.DriverShelly, Name: "Gateway",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	ts := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var readings []api.ReadingRecord
+	for i, v := range []float64{20, 22, 24, 30} {
+		readings = append(readings, api.ReadingRecord{DeviceID: "agg-gw", SensorID: "temp",
+			Reading: api.SensorReading{Value: v, Valid: true, Timestamp: ts.Add(time.Duration(i) * 30 * time.Second)}})
+	}
+	if rec := doRequest(t, router, "POST", "/api/sensor-readings:batch", readings); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	window := "&start_time=2026-03-01T00:00:00Z&end_time=2026-03-01T01:00:00Z"
+	for _, query := range []string{
+		"?sensor_id=temp&interval=1m",
+		"?device_id=agg-gw&sensor_id=temp&interval=soon",
+		"?device_id=agg-gw&sensor_id=temp&interval=1m&fns=median",
+		"?device_id=agg-gw&sensor_id=temp&interval=1s&start_time=2026-01-01T00:00:00Z&end_time=2026-03-01T00:00:00Z",
+		"?device_id=agg-gw&sensor_id=temp&interval=1m&start_time=2026-03-01T01:00:00Z&end_time=2026-03-01T00:00:00Z",
+	} {
+		if rec := doRequest(t, router, "GET", "/api/sensor-readings/aggregate"+query, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rec.Code)
+		}
+	}
+
+	rec := doRequest(t, router, "GET", "/api/sensor-readings/aggregate?device_id=agg-gw&sensor_id=temp&interval=1m&fns=avg,count"+window, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var buckets []api.ReadingBucket
+	if err := json.NewDecoder(rec.Body).Decode(&buckets); err != nil {
+		t.Fatalf("Failed to decode buckets: %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 one-minute buckets, got %+v", buckets)
+	}
+	if *buckets[0].Avg != 21 || *buckets[0].Count != 2 || *buckets[1].Avg != 27 || !buckets[1].Start.Equal(ts.Add(time.Minute)) {
+		t.Errorf("Expected averages 21 and 27 of 2 readings each, got %+v and %+v", buckets[0], buckets[1])
+	}
+	if buckets[0].Min != nil || buckets[0].Max != nil {
+		t.Errorf("Expected only avg and count, got %+v", buckets[0])
+	}
+}
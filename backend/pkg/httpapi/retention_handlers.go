@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// ListRetentionPolicies handles GET /api/admin/retention. It requires the AdminToken as
+// a bearer token.
+func (h *Handler) ListRetentionPolicies(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	policies, err := h.Store.ListRetentionPolicies(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list retention policies: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if policies == nil {
+		policies = []*api.RetentionPolicy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+// SetRetentionPolicy handles PUT /api/admin/retention/{sensor_type}, setting how long
+// readings of the type are kept. Workers delete older readings on their next retention
+// run. It requires the AdminToken as a bearer token.
+func (h *Handler) SetRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var policy api.RetentionPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	policy.SensorType = api.SensorType(mux.Vars(r)["sensor_type"])
+	if policy.Retention < time.Second {
+		http.Error(w, "retention must be at least a second", http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetRetentionPolicy(r.Context(), &policy); err != nil {
+		http.Error(w, "Failed to set retention policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// DeleteRetentionPolicy handles DELETE /api/admin/retention/{sensor_type}, keeping
+// readings of the type forever. It requires the AdminToken as a bearer token.
+func (h *Handler) DeleteRetentionPolicy(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	if err := h.Store.DeleteRetentionPolicy(r.Context(), api.SensorType(mux.Vars(r)["sensor_type"])); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Retention policy not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete retention policy: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestRetentionPolicies(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	admin := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/admin/retention", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	if rec := admin("PUT", "/api/admin/retention/temperature", api.RetentionPolicy{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a retention, got %d", rec.Code)
+	}
+	rec := admin("PUT", "/api/admin/retention/temperature", api.RetentionPolicy{Retention: 30 * 24 * time.Hour})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { admin("DELETE", "/api/admin/retention/temperature", nil) })
+	var policy api.RetentionPolicy
+	json.NewDecoder(rec.Body).Decode(&policy)
+	if policy.SensorType != api.SensorTypeTemperature || policy.UpdatedAt.IsZero() {
+		t.Errorf("Expected the temperature policy with an update time, got %+v", policy)
+	}
+
+	rec = admin("GET", "/api/admin/retention", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var policies []api.RetentionPolicy
+	json.NewDecoder(rec.Body).Decode(&policies)
+	if len(policies) != 1 || policies[0].Retention != 30*24*time.Hour {
+		t.Errorf("Expected the 30 day temperature policy, got %+v", policies)
+	}
+
+	if rec := admin("DELETE", "/api/admin/retention/temperature", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := admin("DELETE", "/api/admin/retention/temperature", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", rec.Code)
+	}
+}
@@ -12,35 +12,197 @@ func (h *Handler) SetupRouter() *mux.Router {
 
 	// Device endpoints
 	r.HandleFunc("/api/devices", h.CreateDevice).Methods("POST")
-	r.HandleFunc("/api/devices", h.ListDevices).Methods("GET")
-	r.HandleFunc("/api/devices/{id}", h.GetDevice).Methods("GET")
+	r.HandleFunc("/api/devices:batch", h.CreateDevices).Methods("POST")
+	r.HandleFunc("/api/devices", h.cached(h.ListDevices)).Methods("GET")
+	r.HandleFunc("/api/devices", h.PatchDevices).Methods("PATCH")
+	r.HandleFunc("/api/devices/by-external-id/{external_id}", h.GetDeviceByExternalID).Methods("GET")
+	r.HandleFunc("/api/devices/{id}", h.cached(h.GetDevice)).Methods("GET")
 	r.HandleFunc("/api/devices/{id}", h.UpdateDevice).Methods("PUT")
+	r.HandleFunc("/api/devices/{id}", h.PatchDevice).Methods("PATCH")
 	r.HandleFunc("/api/devices/{id}", h.DeleteDevice).Methods("DELETE")
+	r.HandleFunc("/api/devices/{id}/delete-preview", h.GetDeviceDeletePreview).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/clone", h.CloneDevice).Methods("POST")
+	r.HandleFunc("/api/devices/{id}/commands", h.GetDeviceCommands).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/history", h.GetDeviceHistory).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/latest-readings", h.GetDeviceLatestReadings).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/credentials", h.ListDeviceCredentials).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/credentials/{name}", h.SetDeviceCredential).Methods("PUT")
+	r.HandleFunc("/api/credentials/{id}", h.GetCredential).Methods("GET")
+	r.HandleFunc("/api/credentials/{id}", h.DeleteCredential).Methods("DELETE")
+
+	// Change feed, alert and event endpoints
+	r.HandleFunc("/api/changes", h.ListChanges).Methods("GET")
+	r.HandleFunc("/api/events", h.Events).Methods("GET")
+	r.HandleFunc("/api/alerts/{id}/ack", h.AcknowledgeAlert).Methods("POST")
+	r.HandleFunc("/api/slack/interactions", h.SlackInteraction).Methods("POST")
+
+	// Audit log endpoint
+	r.HandleFunc("/api/audit", h.ListAuditEntries).Methods("GET")
+
+	// Configuration snapshots
+	r.HandleFunc("/api/config/snapshot", h.GetConfigSnapshot).Methods("GET")
+	r.HandleFunc(configDiffPath, h.DiffConfig).Methods("POST")
+	r.HandleFunc("/api/export", h.ExportConfig).Methods("GET")
+	r.HandleFunc("/api/config/import", h.ImportConfig).Methods("POST")
+
+	// Asset endpoints
+	r.HandleFunc("/api/devices/{id}/assets", h.ListDeviceAssets).Methods("GET")
+	r.HandleFunc("/api/devices/{id}/assets", h.UploadDeviceAsset).Methods("POST")
+	r.HandleFunc("/api/subsystems/{subsystem}/assets", h.ListSubsystemAssets).Methods("GET")
+	r.HandleFunc("/api/subsystems/{subsystem}/assets", h.UploadSubsystemAsset).Methods("POST")
+	r.HandleFunc("/api/assets/{id}", h.GetAsset).Methods("GET")
+	r.HandleFunc("/api/assets/{id}", h.DeleteAsset).Methods("DELETE")
+	r.HandleFunc("/api/assets/{id}/content", h.GetAssetContent).Methods("GET")
+	r.HandleFunc("/api/assets/{id}/thumbnail", h.GetAssetThumbnail).Methods("GET")
 
 	// Sensor endpoints
 	r.HandleFunc("/api/sensors", h.CreateSensor).Methods("POST")
-	r.HandleFunc("/api/sensors", h.ListSensors).Methods("GET")
+	r.HandleFunc("/api/sensors", h.cached(h.ListSensors)).Methods("GET")
 	r.HandleFunc("/api/sensors/by-tag/{tag}", h.GetSensorByTag).Methods("GET")
+	r.HandleFunc("/api/sensors/by-external-id/{external_id}", h.GetSensorByExternalID).Methods("GET")
 	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}", h.GetSensor).Methods("GET")
 	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}", h.UpdateSensor).Methods("PUT")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}", h.PatchSensor).Methods("PATCH")
 	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}", h.DeleteSensor).Methods("DELETE")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/latest", h.GetLatestSensorReading).Methods("GET")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/trend", h.GetSensorTrend).Methods("GET")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/labels/{key}", h.GetSensorLabelComparison).Methods("GET")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/impact", h.GetSensorImpact).Methods("GET")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/target", h.GetSensorTarget).Methods("GET")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/target", h.SetSensorTarget).Methods("PUT")
+	r.HandleFunc("/api/sensors/{device_id}/{sensor_id}/target", h.DeleteSensorTarget).Methods("DELETE")
+	r.HandleFunc("/api/sensor-targets", h.ListSensorTargets).Methods("GET")
 
 	// Actuator endpoints
 	r.HandleFunc("/api/actuators", h.CreateActuator).Methods("POST")
-	r.HandleFunc("/api/actuators", h.ListActuators).Methods("GET")
+	r.HandleFunc("/api/actuators", h.cached(h.ListActuators)).Methods("GET")
 	r.HandleFunc("/api/actuators/by-tag/{tag}", h.GetActuatorByTag).Methods("GET")
-	r.HandleFunc("/api/actuators/by-tag/{tag}/status", h.GetActuatorLatestStatusByTag).Methods("GET")
+	r.HandleFunc("/api/actuators/by-tag/{tag}/status", h.cached(h.GetActuatorLatestStatusByTag)).Methods("GET")
+	r.HandleFunc("/api/actuators/by-external-id/{external_id}", h.GetActuatorByExternalID).Methods("GET")
 	r.HandleFunc("/api/actuators/{device_id}/{actuator_id}", h.GetActuator).Methods("GET")
 	r.HandleFunc("/api/actuators/{device_id}/{actuator_id}", h.UpdateActuator).Methods("PUT")
+	r.HandleFunc("/api/actuators/{device_id}/{actuator_id}", h.PatchActuator).Methods("PATCH")
 	r.HandleFunc("/api/actuators/{device_id}/{actuator_id}", h.DeleteActuator).Methods("DELETE")
 
+	// Device group endpoints
+	r.HandleFunc("/api/groups", h.CreateGroup).Methods("POST")
+	r.HandleFunc("/api/groups", h.ListGroups).Methods("GET")
+	r.HandleFunc("/api/groups/{id}", h.GetGroup).Methods("GET")
+	r.HandleFunc("/api/groups/{id}", h.UpdateGroup).Methods("PUT")
+	r.HandleFunc("/api/groups/{id}", h.DeleteGroup).Methods("DELETE")
+	r.HandleFunc("/api/groups/{id}/devices/{device_id}", h.AddGroupDevice).Methods("PUT")
+	r.HandleFunc("/api/groups/{id}/devices/{device_id}", h.RemoveGroupDevice).Methods("DELETE")
+	r.HandleFunc("/api/groups/{id}/readings", h.GetGroupReadings).Methods("GET")
+	r.HandleFunc("/api/groups/{id}/actuators", h.GetGroupActuators).Methods("GET")
+
+	// Tag endpoints
+	r.HandleFunc("/api/tags/tree", h.cached(h.GetTagTree)).Methods("GET")
+
+	// Tag alias endpoints
+	r.HandleFunc("/api/tag-aliases", h.ListTagAliases).Methods("GET")
+	r.HandleFunc("/api/tag-aliases/{alias}", h.GetTagAlias).Methods("GET")
+	r.HandleFunc("/api/tag-aliases/{alias}", h.SetTagAlias).Methods("PUT")
+	r.HandleFunc("/api/tag-aliases/{alias}", h.DeleteTagAlias).Methods("DELETE")
+
+	// Rule dependency endpoints
+	r.HandleFunc("/api/rules/broken", h.ListBrokenReferences).Methods("GET")
+	r.HandleFunc("/api/rules/broken/{kind}/{name}", h.ClearBrokenReferences).Methods("DELETE")
+	r.HandleFunc("/api/rules/composite", h.ListCompositeRules).Methods("GET")
+	r.HandleFunc("/api/rules/composite/{name}", h.GetCompositeRule).Methods("GET")
+	r.HandleFunc("/api/rules/{kind}/{name}/trace", h.GetRuleTrace).Methods("GET")
+
+	// Actuator group endpoints
+	r.HandleFunc("/api/actuator-groups", h.ListActuatorGroups).Methods("GET")
+	r.HandleFunc("/api/actuator-groups/{name}/start", h.StartActuatorGroup).Methods("POST")
+	r.HandleFunc("/api/actuator-groups/{name}/stop", h.StopActuatorGroup).Methods("POST")
+
+	// Scene endpoints
+	r.HandleFunc("/api/scenes", h.ListScenes).Methods("GET")
+	r.HandleFunc("/api/scenes/{name}/activate", h.ActivateScene).Methods("POST")
+
+	// Sensor reading endpoints
+	r.HandleFunc("/api/sensor-readings", h.CreateSensorReadings).Methods("POST")
+	r.HandleFunc("/api/sensor-readings:batch", h.CreateSensorReadingsBatch).Methods("POST")
+	r.HandleFunc("/api/sensor-readings", h.cached(h.GetSensorReadings)).Methods("GET")
+	r.HandleFunc("/api/sensor-readings/aggregate", h.cached(h.GetAggregatedReadings)).Methods("GET")
+	r.HandleFunc("/api/import", h.ImportReadings).Methods("POST")
+	r.HandleFunc("/api/reading-labels", h.CreateReadingLabel).Methods("POST")
+	r.HandleFunc("/api/reading-labels", h.ListReadingLabels).Methods("GET")
+	r.HandleFunc("/api/reading-labels/{id}", h.DeleteReadingLabel).Methods("DELETE")
+
+	// Aggregate system health
+	r.HandleFunc("/api/health-score", h.GetHealthScore).Methods("GET")
+	r.HandleFunc("/api/topology", h.cached(h.GetTopology)).Methods("GET")
+
+	// Worker fleet presence
+	r.HandleFunc("/api/workers", h.ListWorkers).Methods("GET")
+	r.HandleFunc("/api/workers/{id}", h.ForgetWorker).Methods("DELETE")
+	r.HandleFunc("/api/leases", h.ListLeases).Methods("GET")
+
+	// Frontend session WebSocket, carrying actuator commands and their progress
+	r.HandleFunc("/api/session", h.Session).Methods("GET")
+
+	// Live readings and actuator states, with a long poll where WebSockets are blocked
+	r.HandleFunc("/api/stream", h.Stream).Methods("GET")
+	r.HandleFunc("/api/poll", h.Poll).Methods("GET")
+
+	// Dashboard bootstrap
+	r.HandleFunc("/api/bootstrap", h.cachedPer(bootstrapVary, h.GetBootstrap)).Methods("GET")
+
+	// Public status page
+	r.HandleFunc("/api/status-page", h.GetStatusPage).Methods("GET")
+
+	// Maintenance mode
+	r.HandleFunc(maintenancePath, h.GetMaintenanceMode).Methods("GET")
+	r.HandleFunc(maintenancePath, h.SetMaintenanceMode).Methods("PUT")
+
+	// Feature flags and administration
+	r.HandleFunc("/api/features", h.GetFeatures).Methods("GET")
+	r.HandleFunc("/api/admin/features", h.ListFeatureFlags).Methods("GET")
+	r.HandleFunc("/api/admin/features/{name}", h.SetFeatureFlag).Methods("PUT")
+	r.HandleFunc("/api/admin/features/{name}", h.DeleteFeatureFlag).Methods("DELETE")
+	r.HandleFunc("/api/admin/retention", h.ListRetentionPolicies).Methods("GET")
+	r.HandleFunc("/api/admin/retention/{sensor_type}", h.SetRetentionPolicy).Methods("PUT")
+	r.HandleFunc("/api/admin/retention/{sensor_type}", h.DeleteRetentionPolicy).Methods("DELETE")
+	r.HandleFunc("/api/admin/storage", h.GetStorageReport).Methods("GET")
+	r.HandleFunc("/api/admin/storage/quotas", h.ListStorageQuotas).Methods("GET")
+	r.HandleFunc("/api/admin/storage/quotas/{device_id}/{sensor_id}", h.SetStorageQuota).Methods("PUT")
+	r.HandleFunc("/api/admin/storage/quotas/{device_id}/{sensor_id}", h.DeleteStorageQuota).Methods("DELETE")
+	r.HandleFunc("/api/admin/slow-queries", h.ListSlowQueries).Methods("GET")
+	r.HandleFunc("/api/admin/slow-queries", h.ClearSlowQueries).Methods("DELETE")
+	r.HandleFunc("/api/admin/config", h.GetAdminConfig).Methods("GET")
+	r.HandleFunc("/api/admin/tags/rename", h.RenameTag).Methods("POST")
+	r.HandleFunc("/api/admin/tags/merge", h.MergeTags).Methods("POST")
+	r.HandleFunc("/api/admin/tags/templates", h.ListTagTemplates).Methods("GET")
+	r.HandleFunc("/api/admin/tags/templates/{kind}", h.SetTagTemplate).Methods("PUT")
+	r.HandleFunc("/api/admin/tags/templates/{kind}", h.DeleteTagTemplate).Methods("DELETE")
+
+	// Per-component log levels
+	r.HandleFunc(loggingPath, h.GetLogLevels).Methods("GET")
+	r.HandleFunc(loggingPath, h.SetLogLevels).Methods("PUT")
+
+	// Localized display names
+	r.HandleFunc("/api/i18n", h.GetI18n).Methods("GET")
+	r.HandleFunc("/api/i18n/languages", h.ListLanguages).Methods("GET")
+
+	// OpenAPI document and Swagger UI
+	r.HandleFunc("/api/openapi.json", h.cached(h.GetOpenAPI)).Methods("GET")
+	r.HandleFunc("/api/docs", h.GetAPIDocs).Methods("GET")
+
 	// Workflow endpoints
 	r.HandleFunc("/api/workflows/discovery", h.StartDiscoveryWorkflow).Methods("POST")
 	r.HandleFunc("/api/workflows/{workflowId}", h.GetWorkflowStatus).Methods("GET")
 	r.HandleFunc("/api/workflows", h.ListWorkflows).Methods("GET")
 
+	// Recover and report failures before anything else can swallow them
+	r.Use(h.recoverPanics)
+	r.Use(h.reportErrors)
+
 	// Enable CORS
 	r.Use(CORSMiddleware)
+	r.Use(attributeActor)
+	r.Use(h.readOnlyGuard)
+	r.Use(h.invalidateReadCache)
 
 	return r
 }
@@ -49,8 +211,9 @@ func (h *Handler) SetupRouter() *mux.Router {
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-None-Match, Last-Event-ID, X-Client-ID, X-User")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Link, X-Maintenance-Mode")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Number of evaluations returned by a rule's trace
+const (
+	defaultTraceLimit = 20
+	maxTraceLimit     = 100
+)
+
+// GetRuleTrace handles GET /api/rules/{kind}/{name}/trace, returning the rule's latest
+// evaluations, newest first, with the readings each read, how its condition came out and
+// the actions it took or held back
+func (h *Handler) GetRuleTrace(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	limit := defaultTraceLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxTraceLimit {
+			http.Error(w, "Invalid limit parameter: must be between 1 and "+strconv.Itoa(maxTraceLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	traces, err := h.Store.ListRuleTraces(r.Context(), params["kind"], params["name"], limit)
+	if err != nil {
+		http.Error(w, "Failed to list rule traces: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if traces == nil {
+		traces = []*api.RuleTrace{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traces)
+}
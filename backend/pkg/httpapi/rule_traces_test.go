@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGetRuleTrace(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	router := h.SetupRouter()
+
+	rec := doRequest(t, router, "GET", "/api/rules/composite/heater-no-flow/trace", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty list before any evaluation, got %s", rec.Body.String())
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, outcome := range []api.TraceOutcome{api.TraceIdle, api.TraceSuppressed, api.TraceFired} {
+		trace := &api.RuleTrace{Kind: api.RuleKindComposite, Name: "heater-no-flow", Timestamp: start.Add(time.Duration(i) * time.Minute), Outcome: outcome}
+		if err := store.RecordRuleTrace(context.Background(), trace, 10); err != nil {
+			t.Fatalf("Failed to record trace: %v", err)
+		}
+	}
+
+	rec = doRequest(t, router, "GET", "/api/rules/composite/heater-no-flow/trace?limit=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var traces []api.RuleTrace
+	if err := json.NewDecoder(rec.Body).Decode(&traces); err != nil {
+		t.Fatalf("Failed to decode traces: %v", err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("Expected 2 traces, got %d", len(traces))
+	}
+	if traces[0].Outcome != api.TraceFired || traces[1].Outcome != api.TraceSuppressed {
+		t.Errorf("Expected the newest traces first, got %s then %s", traces[0].Outcome, traces[1].Outcome)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/rules/composite/heater-no-flow/trace?limit=0", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for limit 0, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/control"
+
+	"github.com/gorilla/mux"
+)
+
+// ListScenes handles GET /api/scenes
+func (h *Handler) ListScenes(w http.ResponseWriter, r *http.Request) {
+	scenes := h.Scenes
+	if scenes == nil {
+		scenes = []api.Scene{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scenes)
+}
+
+// ActivateScene handles POST /api/scenes/{name}/activate, applying the scene's commands
+// under its policy. The result says what happened to each actuator, and comes with
+// 502 Bad Gateway unless every one was applied.
+func (h *Handler) ActivateScene(w http.ResponseWriter, r *http.Request) {
+	if h.Commander == nil || h.Readings == nil {
+		http.Error(w, "Actuator commands are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	for _, scene := range h.Scenes {
+		if scene.Name != name {
+			continue
+		}
+		ctx := api.WithCommandOrigin(r.Context(), api.CommandOrigin{Source: api.CommandSourceUser, Name: api.ActorFrom(r.Context())})
+		res, err := control.NewSceneController(scene, h.Commander, h.Readings).Apply(ctx)
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	http.Error(w, "Scene not found: "+name, http.StatusNotFound)
+}
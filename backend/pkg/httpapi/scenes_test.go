@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestActivateScene(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	h.Scenes = []api.Scene{{
+		Name:   "night",
+		Policy: api.SceneContinue,
+		Commands: []api.SceneCommand{
+			{ActuatorTag: "light.main", Command: api.ActuatorCommand{Action: "off"}},
+			{ActuatorTag: "pump.wave", Command: api.ActuatorCommand{Action: "off"}},
+		},
+	}}
+	router := h.SetupRouter()
+
+	if rec := doRequest(t, router, "POST", "/api/scenes/night/activate", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a commander, got %d", rec.Code)
+	}
+
+	commander := &recordingCommander{}
+	h.Commander = commander
+	h.Readings = staticReadings{"light.main": {Value: 1, Valid: true, Timestamp: time.Now()}}
+	if rec := doRequest(t, router, "POST", "/api/scenes/day/activate", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown scene, got %d", rec.Code)
+	}
+
+	// The wave pump has no status, so it is skipped
+	rec := doRequest(t, router, "POST", "/api/scenes/night/activate", nil)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status 502 with an actuator skipped, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res api.SceneResult
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	if !slices.Equal(res.Applied, []string{"light.main"}) || !slices.Equal(res.Unreachable, []string{"pump.wave"}) {
+		t.Errorf("Expected light.main applied and pump.wave unreachable, got %+v", res)
+	}
+	if got, want := commander.take(), []string{"light.main off"}; !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	h.Readings = staticReadings{"light.main": {Value: 1, Valid: true}, "pump.wave": {Value: 1, Valid: true}}
+	if rec := doRequest(t, router, "POST", "/api/scenes/night/activate", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with every actuator applied, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, router, "GET", "/api/scenes", nil)
+	var scenes []api.Scene
+	if err := json.NewDecoder(rec.Body).Decode(&scenes); err != nil {
+		t.Fatalf("Failed to decode scenes: %v", err)
+	}
+	if len(scenes) != 1 || scenes[0].Name != "night" {
+		t.Errorf("Expected the one scene, got %+v", scenes)
+	}
+}
@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const (
+	sessionPongWait   = 60 * time.Second
+	sessionPingPeriod = sessionPongWait * 9 / 10
+	sessionWriteWait  = 10 * time.Second
+	// sessionQueueSize bounds the commands a session may have waiting behind the one
+	// being executed
+	sessionQueueSize = 16
+)
+
+// Commander sends actuator commands; the server uses a drivers.Dispatcher
+type Commander interface {
+	Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error)
+}
+
+var sessionUpgrader = websocket.Upgrader{
+	// The REST API allows any origin, and the session follows suit
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Session handles GET /api/session, upgrading to a WebSocket over which the frontend
+// sends actuator commands and receives each one's progress, so controls can show the
+// state the hardware reported rather than assuming the command worked. Commands from
+// one session run in the order they were sent; an optional user query parameter, or
+// else the X-User header, labels them in command history and the audit log.
+func (h *Handler) Session(w http.ResponseWriter, r *http.Request) {
+	conn, err := sessionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		user = api.ActorFrom(r.Context())
+	}
+	s := &session{
+		conn:      conn,
+		commander: h.Commander,
+		origin:    api.CommandOrigin{Source: api.CommandSourceUser, Name: user},
+		queue:     make(chan api.SessionRequest, sessionQueueSize),
+	}
+	s.run(r.Context())
+}
+
+type session struct {
+	conn      *websocket.Conn
+	commander Commander
+	origin    api.CommandOrigin
+	queue     chan api.SessionRequest
+
+	writeLock sync.Mutex
+}
+
+func (s *session) run(ctx context.Context) {
+	ll := logCtx(ctx, "session")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.conn.Close()
+
+	go s.execute(ctx)
+	go ping(ctx, s.conn)
+
+	s.conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	})
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				ll.Debug().Err(err).Msg("session closed")
+			}
+			return
+		}
+		var req api.SessionRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			s.fail(req, "Invalid message: "+err.Error())
+			continue
+		}
+		s.handle(req)
+	}
+}
+
+func (s *session) handle(req api.SessionRequest) {
+	switch {
+	case req.Type != api.SessionCommand:
+		s.fail(req, "Unsupported message type: "+string(req.Type))
+		return
+	case req.ID == "" || req.Tag == "" || req.Command.Action == "":
+		s.fail(req, "Commands require an id, tag and action")
+		return
+	case s.commander == nil:
+		s.fail(req, "Actuator commands are not available on this server")
+		return
+	}
+
+	// Report queued before handing the command over, so it cannot be overtaken by sent
+	s.send(api.CommandProgress{ID: req.ID, Tag: req.Tag, Stage: api.CommandStageQueued})
+	select {
+	case s.queue <- req:
+	default:
+		s.fail(req, "Too many commands pending")
+	}
+}
+
+// execute runs queued commands one at a time until the session ends
+func (s *session) execute(ctx context.Context) {
+	ctx = api.WithCommandOrigin(ctx, s.origin)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-s.queue:
+			s.send(api.CommandProgress{ID: req.ID, Tag: req.Tag, Stage: api.CommandStageSent})
+			state, err := s.commander.Command(ctx, req.Tag, req.Command)
+			if err != nil {
+				s.fail(req, err.Error())
+				continue
+			}
+			s.send(api.CommandProgress{ID: req.ID, Tag: req.Tag, Stage: api.CommandStageConfirmed, State: state})
+		}
+	}
+}
+
+// ping keeps conn alive until ctx ends, so the peer's pongs extend its read deadline
+func ping(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(sessionPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(sessionWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *session) fail(req api.SessionRequest, msg string) {
+	s.send(api.CommandProgress{ID: req.ID, Tag: req.Tag, Stage: api.CommandStageFailed, Error: msg})
+}
+
+// send writes a progress message; errors are ignored as the read loop notices a broken
+// connection and ends the session
+func (s *session) send(progress api.CommandProgress) {
+	progress.Type = api.SessionCommandProgress
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(sessionWriteWait))
+	s.conn.WriteJSON(progress)
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// gatedCommander holds each command until it is released, recording the origins
+type gatedCommander struct {
+	release chan struct{}
+
+	lock    sync.Mutex
+	origins []api.CommandOrigin
+}
+
+func (g *gatedCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	g.lock.Lock()
+	g.origins = append(g.origins, api.CommandOriginFrom(ctx))
+	g.lock.Unlock()
+	<-g.release
+	if tag == "tank.broken" {
+		return nil, errors.New("device offline")
+	}
+	return &api.ActuatorState{Active: cmd.Action == "on"}, nil
+}
+
+func dialSession(t *testing.T, h *Handler, query string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(h.SetupRouter())
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/session"+query, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial session: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readProgress(t *testing.T, conn *websocket.Conn) api.CommandProgress {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var progress api.CommandProgress
+	if err := conn.ReadJSON(&progress); err != nil {
+		t.Fatalf("Failed to read progress: %v", err)
+	}
+	return progress
+}
+
+func TestSession_CommandProgress(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	commander := &gatedCommander{release: make(chan struct{})}
+	h.Commander = commander
+	conn := dialSession(t, h, "?user=alice")
+
+	conn.WriteJSON(api.SessionRequest{Type: api.SessionCommand, ID: "1", Tag: "tank.heater", Command: api.ActuatorCommand{Action: "on"}})
+	if got := readProgress(t, conn); got.ID != "1" || got.Stage != api.CommandStageQueued {
+		t.Fatalf("Expected command 1 queued, got %+v", got)
+	}
+	if got := readProgress(t, conn); got.ID != "1" || got.Stage != api.CommandStageSent {
+		t.Fatalf("Expected command 1 sent, got %+v", got)
+	}
+
+	// The second command waits behind the first, which is held by the commander
+	conn.WriteJSON(api.SessionRequest{Type: api.SessionCommand, ID: "2", Tag: "tank.broken", Command: api.ActuatorCommand{Action: "on"}})
+	if got := readProgress(t, conn); got.ID != "2" || got.Stage != api.CommandStageQueued {
+		t.Fatalf("Expected command 2 queued, got %+v", got)
+	}
+
+	commander.release <- struct{}{}
+	confirmed := readProgress(t, conn)
+	if confirmed.ID != "1" || confirmed.Stage != api.CommandStageConfirmed || confirmed.State == nil || !confirmed.State.Active {
+		t.Errorf("Expected command 1 confirmed with the heater on, got %+v", confirmed)
+	}
+	if sent := readProgress(t, conn); sent.ID != "2" || sent.Stage != api.CommandStageSent {
+		t.Errorf("Expected command 2 sent, got %+v", sent)
+	}
+	commander.release <- struct{}{}
+	if failed := readProgress(t, conn); failed.ID != "2" || failed.Stage != api.CommandStageFailed || failed.Error != "device offline" {
+		t.Errorf("Expected command 2 failed, got %+v", failed)
+	}
+
+	commander.lock.Lock()
+	defer commander.lock.Unlock()
+	for _, origin := range commander.origins {
+		if origin != (api.CommandOrigin{Source: api.CommandSourceUser, Name: "alice"}) {
+			t.Errorf("Expected commands attributed to alice, got %+v", origin)
+		}
+	}
+}
+
+func TestSession_InvalidRequests(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	conn := dialSession(t, h, "")
+
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if got := readProgress(t, conn); got.Stage != api.CommandStageFailed {
+		t.Errorf("Expected invalid JSON to fail, got %+v", got)
+	}
+	conn.WriteJSON(api.SessionRequest{Type: api.SessionCommand, ID: "1", Command: api.ActuatorCommand{Action: "on"}})
+	if got := readProgress(t, conn); got.ID != "1" || got.Stage != api.CommandStageFailed {
+		t.Errorf("Expected a command without a tag to fail, got %+v", got)
+	}
+
+	// Without a commander the server cannot reach hardware
+	conn.WriteJSON(api.SessionRequest{Type: api.SessionCommand, ID: "2", Tag: "tank.heater", Command: api.ActuatorCommand{Action: "on"}})
+	if got := readProgress(t, conn); got.ID != "2" || got.Stage != api.CommandStageFailed {
+		t.Errorf("Expected commands to fail without a commander, got %+v", got)
+	}
+}
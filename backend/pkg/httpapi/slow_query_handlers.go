@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Numbers of slow queries summarized
+const (
+	defaultSlowQueriesLimit = 20
+	maxSlowQueriesLimit     = 100
+)
+
+// ListSlowQueries handles GET /api/admin/slow-queries, summarizing the slow queries the
+// store has captured, worst total time first, with the indexes which might serve them. It
+// requires the AdminToken as a bearer token.
+func (h *Handler) ListSlowQueries(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	limit := defaultSlowQueriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxSlowQueriesLimit {
+			http.Error(w, "Invalid limit parameter: must be between 1 and "+strconv.Itoa(maxSlowQueriesLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	queries, err := h.Store.ListSlowQueries(r.Context(), limit)
+	if err != nil {
+		http.Error(w, "Failed to list slow queries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if queries == nil {
+		queries = []*api.SlowQuery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queries)
+}
+
+// ClearSlowQueries handles DELETE /api/admin/slow-queries, forgetting the captured slow
+// queries, such as once indexes have been added for them. It requires the AdminToken as a
+// bearer token.
+func (h *Handler) ClearSlowQueries(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	if err := h.Store.ClearSlowQueries(r.Context()); err != nil {
+		http.Error(w, "Failed to clear slow queries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
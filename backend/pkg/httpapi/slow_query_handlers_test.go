@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestSlowQueries(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/admin/slow-queries", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	if rec := admin("GET", "/api/admin/slow-queries?limit=0"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a zero limit, got %d", rec.Code)
+	}
+	rec := admin("GET", "/api/admin/slow-queries?limit=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var queries []api.SlowQuery
+	if err := json.NewDecoder(rec.Body).Decode(&queries); err != nil || queries == nil {
+		t.Errorf("Expected a list of slow queries, got %q: %v", rec.Body.String(), err)
+	}
+	if rec := admin("DELETE", "/api/admin/slow-queries"); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/snapshot"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDiffPath only reads, so it is exempt from read-only mode and changes can be
+// reviewed during the maintenance window of a restore
+const configDiffPath = "/api/config/diff"
+
+// configDiffRequest is the body of POST /api/config/diff
+type configDiffRequest struct {
+	From *api.ConfigSnapshot `json:"from"`
+	// To defaults to the current configuration
+	To *api.ConfigSnapshot `json:"to"`
+}
+
+// GetConfigSnapshot handles GET /api/config/snapshot, exporting the current
+// configuration
+func (h *Handler) GetConfigSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := snapshot.Take(r.Context(), h.Store)
+	if err != nil {
+		http.Error(w, "Failed to take snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap)
+}
+
+// DiffConfig handles POST /api/config/diff, reporting what changes going from one
+// snapshot to another, or to the current configuration
+func (h *Handler) DiffConfig(w http.ResponseWriter, r *http.Request) {
+	var req configDiffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.From == nil {
+		http.Error(w, "A from snapshot is required", http.StatusBadRequest)
+		return
+	}
+	if req.To == nil {
+		snap, err := snapshot.Take(r.Context(), h.Store)
+		if err != nil {
+			http.Error(w, "Failed to take snapshot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.To = snap
+	}
+
+	diff, err := snapshot.Diff(req.From, req.To)
+	if err != nil {
+		http.Error(w, "Failed to diff snapshots: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+// ExportConfig handles GET /api/export, downloading the current configuration as JSON,
+// or as YAML with format=yaml, for keeping in version control or restoring with
+// POST /api/config/import
+func (h *Handler) ExportConfig(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "yaml" {
+		http.Error(w, "Invalid format: must be json or yaml", http.StatusBadRequest)
+		return
+	}
+	snap, err := snapshot.Take(r.Context(), h.Store)
+	if err != nil {
+		http.Error(w, "Failed to take snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "yaml" {
+		b, err := marshalYAML(snap)
+		if err != nil {
+			http.Error(w, "Failed to encode snapshot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Content-Disposition", `attachment; filename="lifesupport-config.yaml"`)
+		w.Write(b)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="lifesupport-config.json"`)
+	json.NewEncoder(w).Encode(snap)
+}
+
+// ImportConfig handles POST /api/config/import with a JSON or YAML body, creating or updating
+// every entity of the snapshot by ID and reporting the changes made. Entities the
+// snapshot leaves out are kept, so importing the same snapshot again changes nothing.
+func (h *Handler) ImportConfig(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBody)
+	var snap api.ConfigSnapshot
+	var err error
+	if isYAML(r.Header.Get("Content-Type")) {
+		err = unmarshalYAML(r.Body, &snap)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&snap)
+	}
+	if err != nil {
+		http.Error(w, "Invalid snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	applied, err := snapshot.Restore(ctx, h.Store, &snap)
+	if err != nil {
+		// The changes made before the failure stand, so re-importing resumes
+		http.Error(w, "Failed to import snapshot: "+err.Error(), updateErrorStatus(err))
+		return
+	}
+	var tags []string
+	for _, dev := range snap.Devices {
+		tags = append(tags, deviceTags(dev)...)
+	}
+	h.resolveBrokenReferences(ctx, tags)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applied)
+}
+
+func isYAML(contentType string) bool {
+	return strings.Contains(contentType, "yaml")
+}
+
+// marshalYAML encodes v as YAML using its JSON field names, by way of its JSON encoding
+func marshalYAML(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// unmarshalYAML decodes YAML into v by way of JSON, so v's JSON field names and types
+// apply
+func unmarshalYAML(r io.Reader, v any) error {
+	var doc any
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("snapshot cannot be represented as JSON: %w", err)
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestDiffConfig(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	rec := doRequest(t, router, "GET", "/api/config/snapshot", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var backup api.ConfigSnapshot
+	if err := json.NewDecoder(rec.Body).Decode(&backup); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+
+	if rec := doRequest(t, router, "POST", "/api/devices", api.Device{ID: "diff-dev", Driver: api.DriverShelly, Name: "New"}); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/diff-dev", nil) })
+
+	rec = doRequest(t, router, "POST", "/api/config/diff", map[string]any{"from": backup})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var diff api.ConfigDiff
+	if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode diff: %v", err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].ID != "diff-dev" || diff.Changes[0].Change != api.ConfigAdded {
+		t.Errorf("Expected only the new device to be added, got %+v", diff.Changes)
+	}
+
+	if rec := doRequest(t, router, "POST", "/api/config/diff", map[string]any{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a from snapshot, got %d", rec.Code)
+	}
+}
+
+func TestExportImportConfig(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{ID: "export-dev", Driver: api.DriverShelly, Name: "Exported", Metadata: map[string]string{"room": "fish"}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/export-dev", nil) })
+
+	rec := doRequest(t, router, "GET", "/api/export?format=yaml", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	exported := rec.Body.String()
+	if !strings.Contains(exported, "id: export-dev") || !strings.Contains(exported, "room: fish") {
+		t.Fatalf("Expected the device in the YAML export, got %s", exported)
+	}
+
+	importYAML := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/config/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/yaml")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	rec = importYAML(exported)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var applied api.ConfigDiff
+	if err := json.NewDecoder(rec.Body).Decode(&applied); err != nil {
+		t.Fatalf("Failed to decode import result: %v", err)
+	}
+	if len(applied.Changes) != 0 {
+		t.Errorf("Expected importing the current configuration to change nothing, got %+v", applied.Changes)
+	}
+
+	rec = importYAML(strings.Replace(exported, "room: fish", "room: sump", 1))
+	if err := json.NewDecoder(rec.Body).Decode(&applied); err != nil {
+		t.Fatalf("Failed to decode import result: %v", err)
+	}
+	if len(applied.Changes) != 1 || applied.Changes[0].ID != "export-dev" || applied.Changes[0].Change != api.ConfigChanged {
+		t.Fatalf("Expected only the device to change, got %+v", applied.Changes)
+	}
+	rec = doRequest(t, router, "GET", "/api/devices/export-dev", nil)
+	var got api.Device
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode device: %v", err)
+	}
+	if got.Metadata["room"] != "sump" {
+		t.Errorf("Expected the imported metadata, got %+v", got.Metadata)
+	}
+
+	if rec := importYAML("devices: [unclosed"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid YAML, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "GET", "/api/export?format=xml", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+	"lifesupport/backend/pkg/storer"
+)
+
+const (
+	defaultStatusPageCacheTTL = time.Minute
+	// statusPageBuildTimeout bounds building the page, which outlives the request that
+	// started it so a client hanging up can't cut short a page others are waiting for
+	statusPageBuildTimeout = 10 * time.Second
+)
+
+// StatusPageEntry selects one sensor or actuator, by tag, for the public status page
+type StatusPageEntry struct {
+	Label string
+	Tag   string
+}
+
+// StatusPageConfig curates what the unauthenticated status page exposes
+type StatusPageConfig struct {
+	Title    string
+	Entries  []StatusPageEntry
+	CacheTTL time.Duration
+}
+
+// statusPageCache holds the last rendered status page so public traffic never reaches
+// the database or drivers more than once per TTL
+type statusPageCache struct {
+	lock    sync.Mutex
+	body    []byte
+	expires time.Time
+}
+
+// GetStatusPage handles GET /api/status-page
+func (h *Handler) GetStatusPage(w http.ResponseWriter, r *http.Request) {
+	if h.StatusPage == nil || len(h.StatusPage.Entries) == 0 {
+		http.Error(w, "Status page not configured", http.StatusNotFound)
+		return
+	}
+
+	ttl := h.StatusPage.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultStatusPageCacheTTL
+	}
+
+	h.statusPageCache.lock.Lock()
+	defer h.statusPageCache.lock.Unlock()
+
+	now := time.Now()
+	if h.statusPageCache.body != nil && !now.After(h.statusPageCache.expires) {
+		maxAge := int(time.Until(h.statusPageCache.expires).Seconds())
+		if maxAge < 0 {
+			maxAge = 0
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+		w.Write(h.statusPageCache.body)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), statusPageBuildTimeout)
+	defer cancel()
+	page, complete := h.buildStatusPage(ctx, now)
+	body, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, "Failed to render status page: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !complete {
+		// A page missing readings is served but not cached, so the next request tries
+		// again
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(body)
+		return
+	}
+	h.statusPageCache.body = body
+	h.statusPageCache.expires = now.Add(ttl)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(ttl.Seconds())))
+	w.Write(body)
+}
+
+// buildStatusPage reads every entry of the status page, reporting whether every reading
+// was available
+func (h *Handler) buildStatusPage(ctx context.Context, now time.Time) (*api.StatusPage, bool) {
+	page := &api.StatusPage{
+		Title:       h.StatusPage.Title,
+		GeneratedAt: now,
+		Items:       make([]api.StatusPageItem, 0, len(h.StatusPage.Entries)),
+	}
+	complete := true
+	for _, entry := range h.StatusPage.Entries {
+		item := api.StatusPageItem{Label: entry.Label, Tag: entry.Tag}
+		reading, err := h.latestReadingByTag(ctx, entry.Tag)
+		if err != nil {
+			ll := logCtx(ctx, "status_page")
+			ll.Warn().Err(err).Str("tag", entry.Tag).Msg("status page reading unavailable")
+			complete = false
+		} else {
+			item.Reading = reading
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page, complete
+}
+
+// latestReadingByTag resolves tag to a sensor or actuator and asks its driver for the
+// most recent status
+func (h *Handler) latestReadingByTag(ctx context.Context, tag string) (*api.SensorReading, error) {
+	var resource drivers.Statuser
+	sensor, err := h.Store.GetSensorByTag(ctx, tag)
+	switch {
+	case err == nil:
+		resource = sensor
+	case errors.Is(err, storer.ErrNotFound):
+		actuator, err := h.Store.GetActuatorByTag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		resource = actuator
+	default:
+		return nil, err
+	}
+
+	device, err := h.Store.GetDevice(ctx, resource.GetDeviceID())
+	if err != nil {
+		return nil, err
+	}
+	driver, exists := h.Drivers.Get(device.Driver)
+	if !exists {
+		return nil, fmt.Errorf("driver not found: %s", device.Driver)
+	}
+	return driver.GetLastStatus(ctx, api.StatusOptions{}, resource)
+}
+
+// SetupStatusPageRouter creates a router exposing only the public status page, for
+// serving it on its own listener without the rest of the API
+func (h *Handler) SetupStatusPageRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/status-page", h.GetStatusPage).Methods("GET")
+	r.Use(h.recoverPanics)
+	r.Use(h.reportErrors)
+	r.Use(CORSMiddleware)
+	return r
+}
@@ -0,0 +1,149 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+)
+
+// statusDriver reports a fixed reading, failing once ctx is done or while offline
+type statusDriver struct {
+	relayDriver
+	offline bool
+}
+
+func (d *statusDriver) GetLastStatus(ctx context.Context, opt api.StatusOptions, resource drivers.Statuser) (*api.SensorReading, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if d.offline {
+		return nil, errors.New("device offline")
+	}
+	return &api.SensorReading{Value: 25.5, Unit: "C", Valid: true}, nil
+}
+
+func newStatusPageHandler(t *testing.T, driver *statusDriver) *Handler {
+	t.Helper()
+	store := setupTestDB(t)
+	dev := &api.Device{
+		ID:      "probe-dev",
+		Driver:  api.DriverShelly,
+		Name:    "Probe",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"reef.temp"}}},
+	}
+	if err := store.CreateDevice(context.Background(), dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	manager := drivers.NewManager()
+	manager.Register(api.DriverShelly, driver)
+	h := NewHandler(store, nil, manager)
+	h.StatusPage = &StatusPageConfig{
+		Title:    "Reef",
+		Entries:  []StatusPageEntry{{Label: "Temperature", Tag: "reef.temp"}},
+		CacheTTL: time.Minute,
+	}
+	return h
+}
+
+func TestGetStatusPage_NotConfigured(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+	router := h.SetupStatusPageRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/status-page", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
+
+func TestGetStatusPage_ServesFromCache(t *testing.T) {
+	// No store or drivers are configured, so any cache miss would panic.
+	h := NewHandler(nil, nil, nil)
+	h.StatusPage = &StatusPageConfig{
+		Title:    "Reef",
+		Entries:  []StatusPageEntry{{Label: "Temperature", Tag: "reef.temp"}},
+		CacheTTL: time.Minute,
+	}
+	h.statusPageCache.body = []byte(`{"title":"Reef"}`)
+	h.statusPageCache.expires = time.Now().Add(30 * time.Second)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/status-page", nil)
+	rec := httptest.NewRecorder()
+	h.SetupStatusPageRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != `{"title":"Reef"}` {
+		t.Errorf("Expected cached body, got %s", rec.Body.String())
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+		t.Errorf("Expected public Cache-Control header, got %q", cc)
+	}
+}
+
+func TestStatusPageRouter_OnlyExposesStatusPage(t *testing.T) {
+	h := NewHandler(nil, nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/devices", nil)
+	rec := httptest.NewRecorder()
+	h.SetupStatusPageRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for non status page route, got %d", rec.Code)
+	}
+}
+
+func TestGetStatusPage_OutlivesCancelledRequest(t *testing.T) {
+	h := newStatusPageHandler(t, &statusDriver{})
+
+	// The client has already hung up; the page is still built in full and cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/status-page", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.SetupStatusPageRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(string(h.statusPageCache.body), `"value":25.5`) {
+		t.Errorf("Expected the reading cached, got %s", h.statusPageCache.body)
+	}
+}
+
+func TestGetStatusPage_FailedBuildNotCached(t *testing.T) {
+	driver := &statusDriver{offline: true}
+	h := newStatusPageHandler(t, driver)
+	router := h.SetupStatusPageRouter()
+
+	rec := doRequest(t, router, "GET", "/api/status-page", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Expected no-store for a page missing readings, got %q", cc)
+	}
+	if h.statusPageCache.body != nil {
+		t.Errorf("Expected nothing cached, got %s", h.statusPageCache.body)
+	}
+
+	// Once the device is back the next request reads it
+	driver.offline = false
+	rec = doRequest(t, router, "GET", "/api/status-page", nil)
+	if !strings.Contains(rec.Body.String(), `"value":25.5`) {
+		t.Errorf("Expected the reading, got %s", rec.Body.String())
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") {
+		t.Errorf("Expected public Cache-Control header, got %q", cc)
+	}
+}
@@ -0,0 +1,194 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// defaultStorageWindow is the span of recent readings storage growth is projected from
+const defaultStorageWindow = 7 * 24 * time.Hour
+
+// GetStorageReport handles GET /api/admin/storage, reporting the rows and bytes of each
+// table and the readings of each sensor, with growth projected from the readings of the
+// last window (default 168h). It requires the AdminToken as a bearer token.
+func (h *Handler) GetStorageReport(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	window := defaultStorageWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "window must be a positive duration such as 24h", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+	tables, err := h.Store.TableUsage(ctx)
+	if err != nil {
+		http.Error(w, "Failed to get table usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	usage, err := h.Store.SensorReadingUsage(ctx, now.Add(-window))
+	if err != nil {
+		http.Error(w, "Failed to get sensor reading usage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	quotas, err := h.Store.ListStorageQuotas(ctx)
+	if err != nil {
+		http.Error(w, "Failed to list storage quotas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := storageReport(tables, usage, quotas, window)
+	report.GeneratedAt = now.UTC()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// storageReport totals tables, and projects the growth of each sensor's readings from
+// those taken in the last window. A reading's size is the readings table's average.
+func storageReport(tables []api.TableUsage, usage []api.SensorUsage, quotas []*api.StorageQuota, window time.Duration) *api.StorageReport {
+	report := &api.StorageReport{Tables: tables, Sensors: usage, Window: window}
+	if report.Tables == nil {
+		report.Tables = []api.TableUsage{}
+	}
+	var readingBytes float64
+	for _, t := range tables {
+		report.TotalBytes += t.Bytes
+		if t.Table == "sensor_readings" && t.Rows > 0 {
+			readingBytes = float64(t.Bytes) / float64(t.Rows)
+		}
+	}
+
+	// Sensors with a quota are reported even without readings
+	index := make(map[[2]string]int, len(usage))
+	for i, u := range usage {
+		index[[2]string{u.DeviceID, u.SensorID}] = i
+	}
+	for _, q := range quotas {
+		i, ok := index[[2]string{q.DeviceID, q.SensorID}]
+		if !ok {
+			i = len(report.Sensors)
+			report.Sensors = append(report.Sensors, api.SensorUsage{DeviceID: q.DeviceID, SensorID: q.SensorID})
+		}
+		report.Sensors[i].Quota = q
+	}
+	if report.Sensors == nil {
+		report.Sensors = []api.SensorUsage{}
+	}
+	sort.Slice(report.Sensors, func(i, j int) bool {
+		a, b := report.Sensors[i], report.Sensors[j]
+		if a.DeviceID != b.DeviceID {
+			return a.DeviceID < b.DeviceID
+		}
+		return a.SensorID < b.SensorID
+	})
+
+	days := window.Hours() / 24
+	for i := range report.Sensors {
+		s := &report.Sensors[i]
+		s.Bytes = int64(float64(s.Readings) * readingBytes)
+		s.PerDay = float64(s.Recent) / days
+		report.GrowthPerDay += s.PerDay * readingBytes
+		if s.Quota == nil {
+			continue
+		}
+		switch {
+		case s.Readings >= s.Quota.MaxReadings:
+			s.DaysToQuota = new(float64)
+		case s.PerDay > 0:
+			d := float64(s.Quota.MaxReadings-s.Readings) / s.PerDay
+			s.DaysToQuota = &d
+		}
+	}
+	return report
+}
+
+// ListStorageQuotas handles GET /api/admin/storage/quotas. It requires the AdminToken as
+// a bearer token.
+func (h *Handler) ListStorageQuotas(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	quotas, err := h.Store.ListStorageQuotas(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list storage quotas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if quotas == nil {
+		quotas = []*api.StorageQuota{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotas)
+}
+
+// SetStorageQuota handles PUT /api/admin/storage/quotas/{device_id}/{sensor_id}, capping
+// the readings kept of the sensor. Workers downsample or alert on it on their next quota
+// run. It requires the AdminToken as a bearer token.
+func (h *Handler) SetStorageQuota(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var quota api.StorageQuota
+	if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	vars := mux.Vars(r)
+	quota.DeviceID, quota.SensorID = vars["device_id"], vars["sensor_id"]
+	if quota.MaxReadings <= 0 {
+		http.Error(w, "max_readings must be positive", http.StatusBadRequest)
+		return
+	}
+	if quota.Downsample != 0 && quota.Downsample < time.Second {
+		http.Error(w, "downsample must be at least a second", http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetStorageQuota(r.Context(), &quota); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to set storage quota: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quota)
+}
+
+// DeleteStorageQuota handles DELETE /api/admin/storage/quotas/{device_id}/{sensor_id},
+// keeping all of the sensor's readings. It requires the AdminToken as a bearer token.
+func (h *Handler) DeleteStorageQuota(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	if err := h.Store.DeleteStorageQuota(r.Context(), vars["device_id"], vars["sensor_id"]); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Storage quota not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete storage quota: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestStorage(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	h := NewHandler(store, nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	admin := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	dev := &api.Device{
+		ID:     "storage-dev",
+		Driver: api.DriverShelly,
+		Name:   "Tank",
+		Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature},
+			{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	t.Cleanup(func() { store.DeleteDevice(ctx, dev.ID) })
+	// Ten temp readings over the last day
+	now := time.Now()
+	var recs []*api.ReadingRecord
+	for i := 0; i < 10; i++ {
+		recs = append(recs, &api.ReadingRecord{DeviceID: dev.ID, SensorID: "temp",
+			Reading: api.SensorReading{Value: 25, Valid: true, Timestamp: now.Add(-time.Duration(i) * time.Hour)}})
+	}
+	if err := store.StoreSensorReadings(ctx, recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/admin/storage", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	if rec := admin("PUT", "/api/admin/storage/quotas/storage-dev/temp", api.StorageQuota{}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without max_readings, got %d", rec.Code)
+	}
+	if rec := admin("PUT", "/api/admin/storage/quotas/storage-dev/missing", api.StorageQuota{MaxReadings: 5}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown sensor, got %d", rec.Code)
+	}
+	for sensorID, max := range map[string]int64{"temp": 30, "ph": 100} {
+		rec := admin("PUT", "/api/admin/storage/quotas/storage-dev/"+sensorID, api.StorageQuota{MaxReadings: max, Downsample: time.Hour})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	var quotas []api.StorageQuota
+	json.NewDecoder(admin("GET", "/api/admin/storage/quotas", nil).Body).Decode(&quotas)
+	if len(quotas) != 2 || quotas[0].SensorID != "ph" || quotas[1].Downsample != time.Hour {
+		t.Errorf("Expected the ph and temp quotas, got %+v", quotas)
+	}
+
+	if rec := admin("GET", "/api/admin/storage?window=soon", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid window, got %d", rec.Code)
+	}
+	rec := admin("GET", "/api/admin/storage?window=24h", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report api.StorageReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Tables) == 0 || report.Window != 24*time.Hour {
+		t.Errorf("Expected tables over a 24h window, got %+v", report)
+	}
+	sensors := map[string]api.SensorUsage{}
+	for _, s := range report.Sensors {
+		if s.DeviceID == dev.ID {
+			sensors[s.SensorID] = s
+		}
+	}
+	temp, ph := sensors["temp"], sensors["ph"]
+	if temp.Readings != 10 || temp.PerDay != 10 || temp.Quota == nil {
+		t.Errorf("Expected 10 temp readings a day under quota, got %+v", temp)
+	}
+	if temp.DaysToQuota == nil || *temp.DaysToQuota != 2 {
+		t.Errorf("Expected temp to reach its quota in 2 days, got %v", temp.DaysToQuota)
+	}
+	if ph.Quota == nil || ph.Readings != 0 || ph.DaysToQuota != nil {
+		t.Errorf("Expected ph reported by its quota without growth, got %+v", ph)
+	}
+
+	if rec := admin("DELETE", "/api/admin/storage/quotas/storage-dev/ph", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := admin("DELETE", "/api/admin/storage/quotas/storage-dev/ph", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", rec.Code)
+	}
+}
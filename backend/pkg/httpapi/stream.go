@@ -0,0 +1,298 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const (
+	// defaultStreamInterval is how often the live stream reads the change feed unless
+	// Handler.StreamInterval is set
+	defaultStreamInterval = time.Second
+	// streamBatchSize is how many changes the live stream reads at once
+	streamBatchSize = 500
+)
+
+// Stream handles GET /api/stream, upgrading to a WebSocket which pushes sensor readings
+// and actuator states as they are stored, for the tag prefixes and devices the client
+// subscribes to. It follows the change feed from when the client connected, so readings
+// stored by workers reach it as well as those posted to this server.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	// Find the start of the feed before upgrading, while a failure can be an HTTP error
+	cursor, err := h.Store.LatestChangeSeq(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to read the change feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	conn, err := sessionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error
+		return
+	}
+	interval := h.StreamInterval
+	if interval <= 0 {
+		interval = defaultStreamInterval
+	}
+	s := &stream{
+		conn:     conn,
+		store:    h.Store,
+		interval: interval,
+		subs:     map[string]*streamSubscription{},
+	}
+	s.run(r.Context(), cursor)
+}
+
+// streamStore is what the live stream and long poll read; storer.Interface satisfies it
+type streamStore interface {
+	ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error)
+	ListSensorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Sensor, error)
+	ListActuatorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Actuator, error)
+}
+
+// streamSubscription selects the readings and states of one device, or of the sensors
+// and actuators which had a tag under a prefix when it was made, by "{device_id}/{id}"
+type streamSubscription struct {
+	deviceID  string
+	sensors   map[string]bool
+	actuators map[string]bool
+}
+
+func (sub *streamSubscription) matches(typ api.ChangeType, entityID string) bool {
+	if sub.deviceID != "" {
+		deviceID, _, _ := strings.Cut(entityID, "/")
+		return deviceID == sub.deviceID
+	}
+	if typ == api.ChangeReadingStored {
+		return sub.sensors[entityID]
+	}
+	return sub.actuators[entityID]
+}
+
+type stream struct {
+	conn     *websocket.Conn
+	store    streamStore
+	interval time.Duration
+
+	subsLock sync.Mutex
+	subs     map[string]*streamSubscription
+
+	writeLock sync.Mutex
+}
+
+func (s *stream) run(ctx context.Context, cursor int64) {
+	ll := logCtx(ctx, "stream")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer s.conn.Close()
+
+	go s.follow(ctx, cursor)
+	go ping(ctx, s.conn)
+
+	s.conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(sessionPongWait))
+	})
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				ll.Debug().Err(err).Msg("stream closed")
+			}
+			return
+		}
+		var req api.StreamRequest
+		if err := json.Unmarshal(msg, &req); err != nil {
+			s.send(api.StreamMessage{Type: api.StreamError, ID: req.ID, Error: "Invalid message: " + err.Error()})
+			continue
+		}
+		s.handle(ctx, req)
+	}
+}
+
+func (s *stream) handle(ctx context.Context, req api.StreamRequest) {
+	switch req.Type {
+	case api.StreamSubscribe:
+		if req.ID == "" || (req.TagPrefix == "") == (req.DeviceID == "") {
+			s.send(api.StreamMessage{Type: api.StreamError, ID: req.ID, Error: "Subscriptions require an id and one of tag_prefix or device_id"})
+			return
+		}
+		sub, err := resolveSubscription(ctx, s.store, req.TagPrefix, req.DeviceID)
+		if err != nil {
+			s.send(api.StreamMessage{Type: api.StreamError, ID: req.ID, Error: err.Error()})
+			return
+		}
+		s.subsLock.Lock()
+		s.subs[req.ID] = sub
+		s.subsLock.Unlock()
+		s.send(api.StreamMessage{Type: api.StreamSubscribed, ID: req.ID})
+	case api.StreamUnsubscribe:
+		s.subsLock.Lock()
+		delete(s.subs, req.ID)
+		s.subsLock.Unlock()
+	default:
+		s.send(api.StreamMessage{Type: api.StreamError, ID: req.ID, Error: "Unsupported message type: " + string(req.Type)})
+	}
+}
+
+// resolveSubscription selects the readings and states of deviceID, or resolves tagPrefix
+// to the sensors and actuators it selects now
+func resolveSubscription(ctx context.Context, store streamStore, tagPrefix, deviceID string) (*streamSubscription, error) {
+	if deviceID != "" {
+		return &streamSubscription{deviceID: deviceID}, nil
+	}
+	sensors, err := store.ListSensorsByTagPrefix(ctx, tagPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors by tag prefix: %w", err)
+	}
+	actuators, err := store.ListActuatorsByTagPrefix(ctx, tagPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list actuators by tag prefix: %w", err)
+	}
+	sub := &streamSubscription{
+		sensors:   make(map[string]bool, len(sensors)),
+		actuators: make(map[string]bool, len(actuators)),
+	}
+	for _, sensor := range sensors {
+		sub.sensors[sensor.DeviceID+"/"+sensor.ID] = true
+	}
+	for _, actuator := range actuators {
+		sub.actuators[actuator.DeviceID+"/"+actuator.ID] = true
+	}
+	return sub, nil
+}
+
+// follow reads the change feed after cursor every interval until the stream ends,
+// pushing the readings and states selected by a subscription
+func (s *stream) follow(ctx context.Context, cursor int64) {
+	ll := logCtx(ctx, "stream")
+	tail := &changeTail{store: s.store, cursor: cursor}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := tail.each(ctx, func(change *api.ChangeEvent) error {
+			s.push(ctx, change)
+			return nil
+		})
+		if err != nil {
+			ll.Warn().Err(err).Int64("cursor", tail.cursor).Msg("failed to read change feed")
+		}
+	}
+}
+
+// push sends change if it is a reading or actuator state selected by a subscription
+func (s *stream) push(ctx context.Context, change *api.ChangeEvent) {
+	if change.Type != api.ChangeReadingStored && change.Type != api.ChangeActuatorState {
+		return
+	}
+	s.subsLock.Lock()
+	selected := false
+	for _, sub := range s.subs {
+		if sub.matches(change.Type, change.EntityID) {
+			selected = true
+			break
+		}
+	}
+	s.subsLock.Unlock()
+	if !selected {
+		return
+	}
+
+	msg, err := streamMessage(change)
+	if err != nil {
+		ll := logCtx(ctx, "stream")
+		ll.Warn().Err(err).Int64("seq", change.Seq).Msg("failed to decode change")
+		return
+	}
+	s.send(*msg)
+}
+
+// send writes a message; errors are ignored as the read loop notices a broken
+// connection and ends the stream
+func (s *stream) send(msg api.StreamMessage) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(sessionWriteWait))
+	s.conn.WriteJSON(msg)
+}
+
+// changeTail reads the change feed from a cursor for the live stream, event stream and
+// long poll
+type changeTail struct {
+	store  streamStore
+	cursor int64
+}
+
+// read returns up to streamBatchSize changes after the cursor, and moves it past them
+func (t *changeTail) read(ctx context.Context) ([]*api.ChangeEvent, error) {
+	changes, err := t.store.ListChanges(ctx, t.cursor, streamBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		t.cursor = changes[len(changes)-1].Seq
+	}
+	return changes, nil
+}
+
+// each calls fn for every change after the cursor, stopping at the first error
+func (t *changeTail) each(ctx context.Context, fn func(*api.ChangeEvent) error) error {
+	for {
+		changes, err := t.read(ctx)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+		if len(changes) < streamBatchSize {
+			return nil
+		}
+	}
+}
+
+// streamMessage decodes a reading, actuator state, alert or acknowledgement from the
+// change feed into the message sent for it, or returns nil for other changes
+func streamMessage(change *api.ChangeEvent) (*api.StreamMessage, error) {
+	msg := &api.StreamMessage{Seq: change.Seq}
+	var data any
+	switch change.Type {
+	case api.ChangeReadingStored:
+		msg.Type = api.StreamReading
+		msg.Reading = &api.ReadingRecord{}
+		data = msg.Reading
+	case api.ChangeActuatorState:
+		msg.Type = api.StreamActuatorState
+		msg.Command = &api.CommandRecord{}
+		data = msg.Command
+	case api.ChangeAlertFired:
+		msg.Type = api.StreamAlert
+		msg.Alert = &api.Alert{}
+		data = msg.Alert
+	case api.ChangeAlertAcknowledged:
+		msg.Type = api.StreamAlertAcknowledged
+		msg.Ack = &api.AlertAck{}
+		data = msg.Ack
+	default:
+		return nil, nil
+	}
+	if err := json.Unmarshal(change.Data, data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
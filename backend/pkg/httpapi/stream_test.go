@@ -0,0 +1,126 @@
+package httpapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func dialStream(t *testing.T, h *Handler) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(h.SetupRouter())
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/stream", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial stream: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readStream(t *testing.T, conn *websocket.Conn) api.StreamMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg api.StreamMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read stream message: %v", err)
+	}
+	return msg
+}
+
+func TestStream(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "tank-1", Driver: api.DriverShelly, Name: "Tank",
+			Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"tank.temp"}}},
+			Actuators: []*api.Actuator{{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.heater"}}}},
+		{ID: "sump-1", Driver: api.DriverShelly, Name: "Sump",
+			Sensors: []*api.Sensor{{ID: "level", Name: "Level", SensorType: api.SensorTypeWaterDepth, Tags: []string{"sump.level"}}}},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	// Readings stored before the client connects are not replayed
+	if err := store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 20, Valid: true, Timestamp: time.Now()}}); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+
+	h := NewHandler(store, nil, nil)
+	h.StreamInterval = 10 * time.Millisecond
+	conn := dialStream(t, h)
+
+	conn.WriteJSON(api.StreamRequest{Type: api.StreamSubscribe, ID: "tank", TagPrefix: "tank."})
+	if got := readStream(t, conn); got.Type != api.StreamSubscribed || got.ID != "tank" {
+		t.Fatalf("Expected the subscription acknowledged, got %+v", got)
+	}
+
+	for _, rec := range []*api.ReadingRecord{
+		{DeviceID: "sump-1", SensorID: "level", Reading: api.SensorReading{Value: 12, Valid: true, Timestamp: time.Now()}},
+		{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Valid: true, Timestamp: time.Now()}},
+	} {
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	err := store.RecordCommand(ctx, &api.CommandRecord{DeviceID: "tank-1", ActuatorID: "heater", Tag: "tank.heater",
+		Command: api.ActuatorCommand{Action: "on"}, State: &api.ActuatorState{Active: true}, IssuedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("RecordCommand() error = %v", err)
+	}
+
+	reading := readStream(t, conn)
+	if reading.Type != api.StreamReading || reading.Reading == nil || reading.Reading.SensorID != "temp" || reading.Reading.Reading.Value != 25.5 {
+		t.Fatalf("Expected the tank reading alone, got %+v", reading)
+	}
+	state := readStream(t, conn)
+	if state.Type != api.StreamActuatorState || state.Command == nil || state.Command.State == nil || !state.Command.State.Active {
+		t.Fatalf("Expected the heater's state, got %+v", state)
+	}
+	if state.Seq <= reading.Seq {
+		t.Errorf("Expected change feed positions in order, got %d then %d", reading.Seq, state.Seq)
+	}
+
+	// Swapping to the sump by device ID
+	conn.WriteJSON(api.StreamRequest{Type: api.StreamUnsubscribe, ID: "tank"})
+	conn.WriteJSON(api.StreamRequest{Type: api.StreamSubscribe, ID: "sump", DeviceID: "sump-1"})
+	if got := readStream(t, conn); got.Type != api.StreamSubscribed || got.ID != "sump" {
+		t.Fatalf("Expected the subscription acknowledged, got %+v", got)
+	}
+	for _, rec := range []*api.ReadingRecord{
+		{DeviceID: "tank-1", SensorID: "temp", Reading: api.SensorReading{Value: 26, Valid: true, Timestamp: time.Now()}},
+		{DeviceID: "sump-1", SensorID: "level", Reading: api.SensorReading{Value: 11, Valid: true, Timestamp: time.Now()}},
+	} {
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	if got := readStream(t, conn); got.Reading == nil || got.Reading.DeviceID != "sump-1" || got.Reading.Reading.Value != 11 {
+		t.Errorf("Expected the sump reading alone, got %+v", got)
+	}
+}
+
+func TestStream_InvalidRequests(t *testing.T) {
+	h := NewHandler(setupTestDB(t), nil, nil)
+	conn := dialStream(t, h)
+
+	conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if got := readStream(t, conn); got.Type != api.StreamError {
+		t.Errorf("Expected invalid JSON to fail, got %+v", got)
+	}
+	conn.WriteJSON(api.StreamRequest{Type: api.StreamSubscribe, ID: "both", TagPrefix: "tank.", DeviceID: "tank-1"})
+	if got := readStream(t, conn); got.Type != api.StreamError || got.ID != "both" {
+		t.Errorf("Expected a subscription by both tag and device to fail, got %+v", got)
+	}
+	conn.WriteJSON(api.StreamRequest{Type: "publish", ID: "1"})
+	if got := readStream(t, conn); got.Type != api.StreamError || got.ID != "1" {
+		t.Errorf("Expected an unsupported message to fail, got %+v", got)
+	}
+}
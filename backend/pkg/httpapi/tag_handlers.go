@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// GetTagTree handles GET /api/tags/tree, returning the tag hierarchy beneath the prefix
+// query parameter, or the whole hierarchy without one, with entity counts at each level
+// for the navigation sidebar
+func (h *Handler) GetTagTree(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimSuffix(r.URL.Query().Get("prefix"), ".")
+	tree, err := h.Store.ListTagTree(r.Context(), prefix)
+	if err != nil {
+		http.Error(w, "Failed to list tag tree: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tree)
+}
+
+// renameTagRequest is the body of POST /api/admin/tags/rename
+type renameTagRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// mergeTagsRequest is the body of POST /api/admin/tags/merge
+type mergeTagsRequest struct {
+	From []string `json:"from"`
+	Into string   `json:"into"`
+}
+
+// retagResponse reports how many devices, sensors and actuators a rename or merge changed
+type retagResponse struct {
+	Changed int64 `json:"changed"`
+}
+
+// retagErrorStatus maps a failure renaming or merging tags to an HTTP status
+func retagErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storer.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storer.ErrAlreadyExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RenameTag handles POST /api/admin/tags/rename, renaming a tag and the tags beneath it
+// on every device, sensor and actuator. Rules still using the old tags are disabled as
+// broken references until their configuration is updated.
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	var req renameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.From == "" || req.To == "" || req.From == req.To {
+		http.Error(w, "Invalid request body: from and to must be different tags", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	n, err := h.Store.RenameTag(ctx, req.From, req.To)
+	if err != nil {
+		http.Error(w, "Failed to rename tag: "+err.Error(), retagErrorStatus(err))
+		return
+	}
+	h.recordBrokenReferences(ctx, "tag "+req.From+" renamed to "+req.To, h.ruleTags(func(tag string) bool {
+		return tag == req.From || strings.HasPrefix(tag, req.From+".")
+	}))
+	h.resolveBrokenReferences(ctx, []string{req.To})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(retagResponse{Changed: n})
+}
+
+// MergeTags handles POST /api/admin/tags/merge, replacing several tags with one on every
+// device, sensor and actuator. Rules still using the merged tags are disabled as broken
+// references until their configuration is updated.
+func (h *Handler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	var req mergeTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.From) == 0 || req.Into == "" || slices.Contains(req.From, "") {
+		http.Error(w, "Invalid request body: from and into are required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	n, err := h.Store.MergeTags(ctx, req.From, req.Into)
+	if err != nil {
+		http.Error(w, "Failed to merge tags: "+err.Error(), retagErrorStatus(err))
+		return
+	}
+	h.recordBrokenReferences(ctx, "tags merged into "+req.Into, h.ruleTags(func(tag string) bool {
+		return tag != req.Into && slices.Contains(req.From, tag)
+	}))
+	h.resolveBrokenReferences(ctx, []string{req.Into})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(retagResponse{Changed: n})
+}
+
+// ruleTags returns the tags used by configured rules which match
+func (h *Handler) ruleTags(match func(tag string) bool) []string {
+	var tags []string
+	for _, rule := range h.Rules {
+		for _, tag := range rule.Tags {
+			if match(tag) && !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// ListTagTemplates handles GET /api/admin/tags/templates. It requires the AdminToken as a
+// bearer token.
+func (h *Handler) ListTagTemplates(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	templates, err := h.Store.ListTagTemplates(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list tag templates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []*api.TagTemplate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// SetTagTemplate handles PUT /api/admin/tags/templates/{kind}, setting the default tag
+// of devices, sensors or actuators created from then on. It requires the AdminToken as a
+// bearer token.
+func (h *Handler) SetTagTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var template api.TagTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	template.Kind = api.TagKind(mux.Vars(r)["kind"])
+	if err := template.Validate(); err != nil {
+		http.Error(w, "Invalid tag template: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.SetTagTemplate(r.Context(), &template); err != nil {
+		http.Error(w, "Failed to set tag template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(template)
+}
+
+// DeleteTagTemplate handles DELETE /api/admin/tags/templates/{kind}, restoring the
+// built-in default tag of the kind. It requires the AdminToken as a bearer token.
+func (h *Handler) DeleteTagTemplate(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	if err := h.Store.DeleteTagTemplate(r.Context(), api.TagKind(mux.Vars(r)["kind"])); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Tag template not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete tag template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,191 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestRetagHandlers(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	h.AdminToken = "s3cret"
+	h.Rules = []api.RuleRef{
+		api.EventReaction{Name: "ato-off", Tag: "sump.float", ActuatorTags: []string{"ato.pump"}}.Ref(),
+	}
+	router := h.SetupRouter()
+
+	admin := func(path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(body)
+		req := httptest.NewRequest("POST", path, &buf)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	dev := api.Device{
+		ID:        "sump-dev",
+		Driver:    api.DriverShelly,
+		Name:      "Sump",
+		Sensors:   []*api.Sensor{{ID: "float", Name: "Float", SensorType: api.SensorTypeTemperature, Tags: []string{"sump.float", "sump.level"}}},
+		Actuators: []*api.Actuator{{ID: "return", Name: "Return", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"sump.return"}}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := doRequest(t, router, "POST", "/api/admin/tags/rename", renameTagRequest{From: "sump", To: "fuge"}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	if rec := admin("/api/admin/tags/rename", renameTagRequest{From: "sump", To: "sump"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an identical rename, got %d", rec.Code)
+	}
+	if rec := admin("/api/admin/tags/rename", renameTagRequest{From: "nothing", To: "else"}); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unused tag, got %d", rec.Code)
+	}
+	if rec := admin("/api/admin/tags/merge", mergeTagsRequest{Into: "sump.level"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without tags to merge, got %d", rec.Code)
+	}
+
+	rec := admin("/api/admin/tags/rename", renameTagRequest{From: "sump", To: "fuge"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp retagResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Changed != 2 {
+		t.Errorf("Expected 2 changed resources, got %d", resp.Changed)
+	}
+	sensor, err := store.GetSensor(t.Context(), "sump-dev", "float")
+	if err != nil {
+		t.Fatalf("Failed to get sensor: %v", err)
+	}
+	if !slices.Contains(sensor.Tags, "fuge.float") || slices.Contains(sensor.Tags, "sump.float") {
+		t.Errorf("Expected the sensor tags to be renamed, got %v", sensor.Tags)
+	}
+
+	// The rule still references the old tag
+	refs, err := store.ListBrokenReferences(t.Context())
+	if err != nil {
+		t.Fatalf("Failed to list broken references: %v", err)
+	}
+	if len(refs) != 1 || refs[0].Name != "ato-off" || refs[0].Tag != "sump.float" {
+		t.Errorf("Expected ato-off broken by the rename, got %+v", refs)
+	}
+
+	rec = admin("/api/admin/tags/merge", mergeTagsRequest{From: []string{"fuge.float", "fuge.level"}, Into: "sump.float"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if sensor, _ = store.GetSensor(t.Context(), "sump-dev", "float"); !slices.Contains(sensor.Tags, "sump.float") || slices.Contains(sensor.Tags, "fuge.level") {
+		t.Errorf("Expected the sensor tags to be merged, got %v", sensor.Tags)
+	}
+	if refs, _ = store.ListBrokenReferences(t.Context()); len(refs) != 0 {
+		t.Errorf("Expected the merge to resolve the broken reference, got %+v", refs)
+	}
+}
+
+func TestGetTagTree(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := api.Device{
+		ID:      "tree-dev",
+		Driver:  api.DriverShelly,
+		Name:    "Sump",
+		Tags:    []string{"sump"},
+		Sensors: []*api.Sensor{{ID: "float", Name: "Float", SensorType: api.SensorTypeTemperature, Tags: []string{"sump.float"}}},
+	}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doRequest(t, router, "GET", "/api/tags/tree?prefix=sump.", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var tree api.TagNode
+	if err := json.NewDecoder(rec.Body).Decode(&tree); err != nil {
+		t.Fatalf("Failed to decode tag tree: %v", err)
+	}
+	if tree.Tag != "sump" || tree.Counts != (api.TagCounts{Devices: 1, Sensors: 1}) {
+		t.Errorf("Expected the sump node with one device and sensor, got %+v", tree)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].Tag != "sump.float" || tree.Children[0].Counts.Sensors != 1 {
+		t.Errorf("Expected the float sensor beneath the sump, got %+v", tree.Children)
+	}
+}
+
+func TestTagTemplateHandlers(t *testing.T) {
+	store := setupTestDB(t)
+	h := NewHandler(store, nil, nil)
+	h.AdminToken = "s3cret"
+	router := h.SetupRouter()
+
+	admin := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	template := api.TagTemplate{Template: "site.{site}.{device}"}
+	if rec := doRequest(t, router, "PUT", "/api/admin/tags/templates/device", template); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", rec.Code)
+	}
+	for _, bad := range []struct{ kind, template string }{
+		{"widget", "site.{site}.{id}"},
+		{"device", "site.{site}"},
+		{"sensor", "site.{site}.{id}"},
+		{"sensor", "site.{site.{device}.{id}"},
+	} {
+		if rec := admin("PUT", "/api/admin/tags/templates/"+bad.kind, api.TagTemplate{Template: bad.template}); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s template %q, got %d", bad.kind, bad.template, rec.Code)
+		}
+	}
+	if rec := admin("PUT", "/api/admin/tags/templates/device", template); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { admin("DELETE", "/api/admin/tags/templates/device", nil) })
+
+	rec := admin("GET", "/api/admin/tags/templates", nil)
+	var templates []api.TagTemplate
+	json.NewDecoder(rec.Body).Decode(&templates)
+	if len(templates) != 1 || templates[0].Kind != api.TagKindDevice || templates[0].Template != template.Template {
+		t.Errorf("Expected the device template, got %+v", templates)
+	}
+
+	dev := api.Device{ID: "tmpl-dev", Driver: api.DriverShelly, Name: "Templated", Metadata: map[string]string{"site": "reef"}}
+	rec = doRequest(t, router, "POST", "/api/devices", dev)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/tmpl-dev", nil) })
+	json.NewDecoder(rec.Body).Decode(&dev)
+	if !slices.Equal(dev.Tags, []string{"site.reef.tmpl-dev"}) {
+		t.Errorf("Expected the templated tag, got %v", dev.Tags)
+	}
+
+	if rec := admin("DELETE", "/api/admin/tags/templates/device", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := admin("DELETE", "/api/admin/tags/templates/device", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 deleting twice, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// ListSensorTargets handles GET /api/sensor-targets
+func (h *Handler) ListSensorTargets(w http.ResponseWriter, r *http.Request) {
+	targets, err := h.Store.ListSensorTargets(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list sensor targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if targets == nil {
+		targets = []*api.TargetRange{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(targets)
+}
+
+// GetSensorTarget handles GET /api/sensors/{device_id}/{sensor_id}/target
+func (h *Handler) GetSensorTarget(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	target, err := h.Store.GetSensorTarget(r.Context(), params["device_id"], params["sensor_id"])
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor target not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get sensor target: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// SetSensorTarget handles PUT /api/sensors/{device_id}/{sensor_id}/target
+func (h *Handler) SetSensorTarget(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var target api.TargetRange
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	target.DeviceID = params["device_id"]
+	target.SensorID = params["sensor_id"]
+	if err := target.Validate(); err != nil {
+		http.Error(w, "Invalid target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Store.SetSensorTarget(r.Context(), &target); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to set sensor target: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// DeleteSensorTarget handles DELETE /api/sensors/{device_id}/{sensor_id}/target
+func (h *Handler) DeleteSensorTarget(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	if err := h.Store.DeleteSensorTarget(r.Context(), params["device_id"], params["sensor_id"]); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor target not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete sensor target: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetLatestSensorReading handles GET /api/sensors/{device_id}/{sensor_id}/latest
+func (h *Handler) GetLatestSensorReading(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID, sensorID := params["device_id"], params["sensor_id"]
+	reading, err := h.Store.GetLatestSensorReading(r.Context(), deviceID, sensorID)
+	if err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "No reading found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get latest sensor reading: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rec := &api.ReadingRecord{DeviceID: deviceID, SensorID: sensorID, Reading: *reading}
+	if err := h.applyTargets(r.Context(), []*api.ReadingRecord{rec}); err != nil {
+		http.Error(w, "Failed to get sensor targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+// GetDeviceLatestReadings handles GET /api/devices/{id}/latest-readings, the latest
+// reading of each of the device's sensors in one request for dashboards
+func (h *Handler) GetDeviceLatestReadings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	readings, err := h.Store.GetLatestReadingsForDevice(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Failed to get latest sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.applyTargets(ctx, readings); err != nil {
+		http.Error(w, "Failed to get sensor targets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(readings)
+}
+
+// applyTargets attaches each reading's sensor target range, and classifies valid
+// readings against it
+func (h *Handler) applyTargets(ctx context.Context, readings []*api.ReadingRecord) error {
+	if len(readings) == 0 {
+		return nil
+	}
+	targets, err := h.Store.ListSensorTargets(ctx)
+	if err != nil {
+		return err
+	}
+	bySensor := make(map[[2]string]*api.TargetRange, len(targets))
+	for _, target := range targets {
+		bySensor[[2]string{target.DeviceID, target.SensorID}] = target
+	}
+	for _, rec := range readings {
+		target, ok := bySensor[[2]string{rec.DeviceID, rec.SensorID}]
+		if !ok {
+			continue
+		}
+		rec.Target = target
+		if rec.Reading.Valid {
+			rec.Level = target.Level(rec.Reading.Value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func TestSensorTargets(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := &api.Device{
+		ID:      "tank-dev",
+		Driver:  api.DriverShelly,
+		Name:    "Tank",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature}},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	rec := doRequest(t, router, "PUT", "/api/sensors/tank-dev/temp/target", api.TargetRange{
+		Ideal:   api.Band{Min: bound(25), Max: bound(26)},
+		Warning: api.Band{Min: bound(26.5), Max: bound(27)},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an ideal band outside the warning band, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "PUT", "/api/sensors/tank-dev/missing/target", api.TargetRange{})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown sensor, got %d", rec.Code)
+	}
+
+	target := api.TargetRange{
+		Ideal:    api.Band{Min: bound(25), Max: bound(26)},
+		Warning:  api.Band{Min: bound(24), Max: bound(27)},
+		Critical: api.Band{Min: bound(22), Max: bound(29)},
+	}
+	rec = doRequest(t, router, "PUT", "/api/sensors/tank-dev/temp/target", target)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i, v := range []float64{25.5, 26.5, 28, 30} {
+		reading := &api.ReadingRecord{DeviceID: "tank-dev", SensorID: "temp", Reading: api.SensorReading{Value: v, Valid: true, Timestamp: base.Add(time.Duration(i) * time.Minute)}}
+		if err := store.StoreSensorReading(ctx, reading); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensor-readings?device_id=tank-dev&sensor_id=temp", nil)
+	var readings []*api.ReadingRecord
+	if err := json.NewDecoder(rec.Body).Decode(&readings); err != nil {
+		t.Fatalf("Failed to decode readings: %v", err)
+	}
+	// Newest first
+	want := []api.TargetLevel{api.TargetLevelCritical, api.TargetLevelWarning, api.TargetLevelOK, api.TargetLevelIdeal}
+	if len(readings) != len(want) {
+		t.Fatalf("Expected %d readings, got %d", len(want), len(readings))
+	}
+	for i, reading := range readings {
+		if reading.Level != want[i] {
+			t.Errorf("Expected %v to be %s, got %s", reading.Reading.Value, want[i], reading.Level)
+		}
+		if reading.Target == nil || *reading.Target.Ideal.Max != 26 {
+			t.Errorf("Expected the target range with each reading, got %+v", reading.Target)
+		}
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensors/tank-dev/temp/latest", nil)
+	var latest api.ReadingRecord
+	json.NewDecoder(rec.Body).Decode(&latest)
+	if latest.Reading.Value != 30 || latest.Level != api.TargetLevelCritical {
+		t.Errorf("Expected the latest reading to be critical, got %+v", latest)
+	}
+	rec = doRequest(t, router, "GET", "/api/devices/tank-dev/latest-readings", nil)
+	readings = nil
+	if err := json.NewDecoder(rec.Body).Decode(&readings); err != nil {
+		t.Fatalf("Failed to decode latest readings: %v", err)
+	}
+	if len(readings) != 1 || readings[0].Reading.Value != 30 || readings[0].Level != api.TargetLevelCritical {
+		t.Errorf("Expected the device's latest reading to be critical, got %+v", readings)
+	}
+
+	rec = doRequest(t, router, "DELETE", "/api/sensors/tank-dev/temp/target", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "GET", "/api/sensors/tank-dev/temp/latest", nil)
+	latest = api.ReadingRecord{}
+	json.NewDecoder(rec.Body).Decode(&latest)
+	if latest.Level != "" || latest.Target != nil {
+		t.Errorf("Expected no level without a target, got %+v", latest)
+	}
+}
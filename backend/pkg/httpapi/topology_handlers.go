@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/topology"
+)
+
+// GetTopology handles GET /api/topology, returning the graph of subsystems, devices,
+// sensors, actuators and the configured rules reading and driving them
+func (h *Handler) GetTopology(w http.ResponseWriter, r *http.Request) {
+	topo, err := topology.Build(r.Context(), h.Store, h.Rules)
+	if err != nil {
+		http.Error(w, "Failed to build topology: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topo)
+}
+
+// GetSensorImpact handles GET /api/sensors/{device_id}/{sensor_id}/impact, listing the
+// control loops, rules and derived sensors affected if the sensor fails and how each
+// falls back
+func (h *Handler) GetSensorImpact(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID := params["device_id"]
+	sensorID := params["sensor_id"]
+
+	ctx := r.Context()
+	if _, err := h.Store.GetSensor(ctx, deviceID, sensorID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, storer.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, "Failed to get sensor: "+err.Error(), status)
+		return
+	}
+	topo, err := topology.Build(ctx, h.Store, h.Rules)
+	if err != nil {
+		http.Error(w, "Failed to build topology: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(topology.Impact(topo, deviceID, sensorID))
+}
@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGetTopology(t *testing.T) {
+	store := setupTestDB(t)
+	handler := NewHandler(store, nil, nil)
+	handler.Rules = []api.RuleRef{{Kind: api.RuleKindActuatorGroup, Name: "lights", Tags: []string{"topo.light"}}}
+	router := handler.SetupRouter()
+
+	dev := api.Device{ID: "topo-dev", Driver: api.DriverShelly, Name: "Lights",
+		Actuators: []*api.Actuator{{ID: "light", Name: "Light", Tags: []string{"topo.light"}}}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/topo-dev", nil) })
+
+	rec := doRequest(t, router, "GET", "/api/topology", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var topo api.Topology
+	if err := json.NewDecoder(rec.Body).Decode(&topo); err != nil {
+		t.Fatalf("Failed to decode topology: %v", err)
+	}
+	want := api.TopologyEdge{From: "rule:actuator_group/lights", To: "actuator:topo-dev/light", Kind: api.TopologyEdgeDrives, Tag: "topo.light"}
+	found := false
+	for _, e := range topo.Edges {
+		found = found || e == want
+	}
+	if !found {
+		t.Errorf("Expected the rule to drive the light, got %+v", topo.Edges)
+	}
+}
+
+func TestGetSensorImpact(t *testing.T) {
+	store := setupTestDB(t)
+	handler := NewHandler(store, nil, nil)
+	handler.Rules = []api.RuleRef{api.DryRunRule{Name: "dry", PumpTag: "impact.pump", FlowTag: "impact.flow"}.Ref()}
+	router := handler.SetupRouter()
+
+	dev := api.Device{ID: "impact-dev", Driver: api.DriverShelly, Name: "Sump",
+		Sensors:   []*api.Sensor{{ID: "flow", Name: "Flow", Tags: []string{"impact.flow"}}},
+		Actuators: []*api.Actuator{{ID: "pump", Name: "Pump", Tags: []string{"impact.pump"}}}}
+	if rec := doRequest(t, router, "POST", "/api/devices", dev); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	t.Cleanup(func() { doRequest(t, router, "DELETE", "/api/devices/impact-dev", nil) })
+
+	rec := doRequest(t, router, "GET", "/api/sensors/impact-dev/flow/impact", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var impact api.SensorImpact
+	if err := json.NewDecoder(rec.Body).Decode(&impact); err != nil {
+		t.Fatalf("Failed to decode impact: %v", err)
+	}
+	if len(impact.ControlLoops) != 1 || impact.ControlLoops[0].Name != "dry" {
+		t.Errorf("Expected the dry-run rule to be impacted, got %+v", impact)
+	}
+
+	if rec := doRequest(t, router, "GET", "/api/sensors/impact-dev/missing/impact", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown sensor, got %d", rec.Code)
+	}
+}
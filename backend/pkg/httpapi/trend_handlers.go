@@ -0,0 +1,84 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/trend"
+)
+
+const defaultTrendPeriod = 7 * 24 * time.Hour
+
+// GetSensorTrend handles GET /api/sensors/{device_id}/{sensor_id}/trend
+func (h *Handler) GetSensorTrend(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	deviceID, sensorID := params["device_id"], params["sensor_id"]
+	q := r.URL.Query()
+
+	period := defaultTrendPeriod
+	if v := q.Get("period"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid period parameter, expected a positive duration such as 168h", http.StatusBadRequest)
+			return
+		}
+		period = d
+	}
+	times := map[string]*time.Time{}
+	for _, name := range []string{"start", "end", "baseline_start", "baseline_end"} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			times[name] = &t
+		}
+	}
+
+	// The current window defaults to the last period, and the baseline to the window of
+	// the same length just before it
+	current := trend.Window{End: time.Now()}
+	if t := times["end"]; t != nil {
+		current.End = *t
+	}
+	current.Start = current.End.Add(-period)
+	if t := times["start"]; t != nil {
+		current.Start = *t
+	}
+	baseline := trend.Window{End: current.Start}
+	if t := times["baseline_end"]; t != nil {
+		baseline.End = *t
+	}
+	baseline.Start = baseline.End.Add(-current.End.Sub(current.Start))
+	if t := times["baseline_start"]; t != nil {
+		baseline.Start = *t
+	}
+	if !current.Start.Before(current.End) || !baseline.Start.Before(baseline.End) {
+		http.Error(w, "Each window must start before it ends", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Store.GetSensor(r.Context(), deviceID, sensorID); err != nil {
+		if errors.Is(err, storer.ErrNotFound) {
+			http.Error(w, "Sensor not found: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get sensor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cmp, err := trend.Compare(r.Context(), h.Store, deviceID, sensorID, baseline, current)
+	if err != nil {
+		http.Error(w, "Failed to compare sensor readings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cmp)
+}
@@ -0,0 +1,61 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestGetSensorTrend(t *testing.T) {
+	store := setupTestDB(t)
+	ctx := context.Background()
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	dev := &api.Device{
+		ID:      "tank-dev",
+		Driver:  api.DriverShelly,
+		Name:    "Tank",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature}},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	now := time.Now()
+	for _, ago := range []time.Duration{36 * time.Hour, 30 * time.Hour, 12 * time.Hour, 6 * time.Hour} {
+		reading := &api.ReadingRecord{DeviceID: "tank-dev", SensorID: "temp", Reading: api.SensorReading{Value: 25, Valid: true, Timestamp: now.Add(-ago)}}
+		if err := store.StoreSensorReading(ctx, reading); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+
+	rec := doRequest(t, router, "GET", "/api/sensors/tank-dev/temp/trend?period=24h", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var cmp api.TrendComparison
+	if err := json.NewDecoder(rec.Body).Decode(&cmp); err != nil {
+		t.Fatalf("Failed to decode trend: %v", err)
+	}
+	if cmp.Baseline.Count != 2 || cmp.Current.Count != 2 {
+		t.Errorf("Expected 2 readings in each day, got %d and %d", cmp.Baseline.Count, cmp.Current.Count)
+	}
+	if !cmp.Baseline.End.Equal(cmp.Current.Start) {
+		t.Errorf("Expected the baseline to end where the current window starts, got %v and %v", cmp.Baseline.End, cmp.Current.Start)
+	}
+	if cmp.MeanDelta == nil || *cmp.MeanDelta != 0 || cmp.Direction != api.TrendFlat {
+		t.Errorf("Expected no change, got %+v", cmp)
+	}
+
+	rec = doRequest(t, router, "GET", "/api/sensors/tank-dev/temp/trend?period=-1h", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative period, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "GET", "/api/sensors/tank-dev/missing/trend", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown sensor, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// WorkerTracker reports worker presence; the server uses a presence.Tracker
+type WorkerTracker interface {
+	Workers() []api.WorkerPresence
+	Forget(ctx context.Context, id string) error
+}
+
+// ListWorkers handles GET /api/workers
+func (h *Handler) ListWorkers(w http.ResponseWriter, r *http.Request) {
+	if h.Workers == nil {
+		http.Error(w, "Worker presence not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Workers.Workers())
+}
+
+// ForgetWorker handles DELETE /api/workers/{id}
+func (h *Handler) ForgetWorker(w http.ResponseWriter, r *http.Request) {
+	if h.Workers == nil {
+		http.Error(w, "Worker presence not configured", http.StatusServiceUnavailable)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	found := false
+	for _, worker := range h.Workers.Workers() {
+		found = found || worker.ID == id
+	}
+	if !found {
+		http.Error(w, "Worker not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.Workers.Forget(r.Context(), id); err != nil {
+		http.Error(w, "Failed to forget worker: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListLeases handles GET /api/leases
+func (h *Handler) ListLeases(w http.ResponseWriter, r *http.Request) {
+	leases, err := h.Store.ListLeases(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list leases: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if leases == nil {
+		leases = []*api.Lease{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leases)
+}
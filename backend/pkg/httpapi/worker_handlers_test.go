@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+type fakeTracker struct {
+	workers []api.WorkerPresence
+}
+
+func (f *fakeTracker) Workers() []api.WorkerPresence {
+	return f.workers
+}
+
+func (f *fakeTracker) Forget(ctx context.Context, id string) error {
+	for i, w := range f.workers {
+		if w.ID == id {
+			f.workers = append(f.workers[:i], f.workers[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestWorkers(t *testing.T) {
+	store := setupTestDB(t)
+	handler := NewHandler(store, nil, nil)
+	router := handler.SetupRouter()
+
+	rec := doRequest(t, router, "GET", "/api/workers", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without presence tracking, got %d", rec.Code)
+	}
+
+	handler.Workers = &fakeTracker{workers: []api.WorkerPresence{
+		{ID: "worker-1", Status: api.WorkerOnline, Broker: "tcp://mqtt-a:1883"},
+		{ID: "worker-2", Status: api.WorkerOffline, Broker: "tcp://mqtt-b:1883"},
+	}}
+	rec = doRequest(t, router, "GET", "/api/workers", nil)
+	var workers []api.WorkerPresence
+	if err := json.NewDecoder(rec.Body).Decode(&workers); err != nil {
+		t.Fatalf("Failed to decode workers: %v", err)
+	}
+	if len(workers) != 2 || workers[1].Status != api.WorkerOffline {
+		t.Errorf("Expected both workers, got %+v", workers)
+	}
+
+	rec = doRequest(t, router, "DELETE", "/api/workers/worker-3", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown worker, got %d", rec.Code)
+	}
+	rec = doRequest(t, router, "DELETE", "/api/workers/worker-2", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	if workers := handler.Workers.Workers(); len(workers) != 1 {
+		t.Errorf("Expected one worker left, got %+v", workers)
+	}
+}
+
+func TestListLeases(t *testing.T) {
+	store := setupTestDB(t)
+	router := NewHandler(store, nil, nil).SetupRouter()
+
+	if _, err := store.AcquireLease(context.Background(), "broker/tcp://mqtt-a:1883", "worker-1", time.Minute); err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	rec := doRequest(t, router, "GET", "/api/leases", nil)
+	var leases []api.Lease
+	if err := json.NewDecoder(rec.Body).Decode(&leases); err != nil {
+		t.Fatalf("Failed to decode leases: %v", err)
+	}
+	if len(leases) != 1 || leases[0].Holder != "worker-1" {
+		t.Errorf("Expected worker-1 to own the broker, got %+v", leases)
+	}
+}
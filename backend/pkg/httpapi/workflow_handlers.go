@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	workflowpb "go.temporal.io/api/workflow/v1"
 	"go.temporal.io/api/workflowservice/v1"
 	"go.temporal.io/sdk/client"
 
@@ -147,8 +148,18 @@ func (h *Handler) ListWorkflows(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
+	workflows, err := h.listWorkflows(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list workflows: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workflows)
+}
 
+// listWorkflows lists recent discovery workflows from Temporal
+func (h *Handler) listWorkflows(ctx context.Context) ([]api.WorkflowInfo, error) {
 	// Query for discovery workflows - list recent ones
 	query := "WorkflowType = 'DeviceDiscoveryWorkflow'"
 
@@ -162,52 +173,53 @@ func (h *Handler) ListWorkflows(w http.ResponseWriter, r *http.Request) {
 		PageSize: 100,
 	})
 	if err != nil {
-		http.Error(w, "Failed to list workflows: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	workflows := make([]api.WorkflowInfo, 0)
 	if resp != nil && resp.Executions != nil {
 		for _, exec := range resp.Executions {
-			workflowInfo := api.WorkflowInfo{
-				WorkflowID: exec.Execution.WorkflowId,
-				RunID:      exec.Execution.RunId,
-				StartTime:  exec.StartTime.AsTime(),
-			}
+			workflows = append(workflows, workflowInfo(exec))
+		}
+	}
+	return workflows, nil
+}
 
-			if exec.CloseTime != nil {
-				closeTime := exec.CloseTime.AsTime()
-				workflowInfo.CloseTime = &closeTime
-			}
+// workflowInfo summarizes a listed workflow execution
+func workflowInfo(exec *workflowpb.WorkflowExecutionInfo) api.WorkflowInfo {
+	workflowInfo := api.WorkflowInfo{
+		WorkflowID: exec.Execution.WorkflowId,
+		RunID:      exec.Execution.RunId,
+		StartTime:  exec.StartTime.AsTime(),
+	}
 
-			// Determine status
-			if exec.CloseTime != nil {
-				switch exec.Status {
-				case 1: // COMPLETED
-					workflowInfo.Status = api.WorkflowStatusSuccess
-				case 2: // FAILED
-					workflowInfo.Status = api.WorkflowStatusError
-					workflowInfo.Error = "Workflow failed"
-				case 3: // CANCELED
-					workflowInfo.Status = api.WorkflowStatusError
-					workflowInfo.Error = "Workflow canceled"
-				case 4: // TERMINATED
-					workflowInfo.Status = api.WorkflowStatusError
-					workflowInfo.Error = "Workflow terminated"
-				case 6: // TIMED_OUT
-					workflowInfo.Status = api.WorkflowStatusError
-					workflowInfo.Error = "Workflow timed out"
-				default:
-					workflowInfo.Status = api.WorkflowStatusError
-				}
-			} else {
-				workflowInfo.Status = api.WorkflowStatusInProgress
-			}
+	if exec.CloseTime != nil {
+		closeTime := exec.CloseTime.AsTime()
+		workflowInfo.CloseTime = &closeTime
+	}
 
-			workflows = append(workflows, workflowInfo)
+	// Determine status
+	if exec.CloseTime != nil {
+		switch exec.Status {
+		case 1: // COMPLETED
+			workflowInfo.Status = api.WorkflowStatusSuccess
+		case 2: // FAILED
+			workflowInfo.Status = api.WorkflowStatusError
+			workflowInfo.Error = "Workflow failed"
+		case 3: // CANCELED
+			workflowInfo.Status = api.WorkflowStatusError
+			workflowInfo.Error = "Workflow canceled"
+		case 4: // TERMINATED
+			workflowInfo.Status = api.WorkflowStatusError
+			workflowInfo.Error = "Workflow terminated"
+		case 6: // TIMED_OUT
+			workflowInfo.Status = api.WorkflowStatusError
+			workflowInfo.Error = "Workflow timed out"
+		default:
+			workflowInfo.Status = api.WorkflowStatusError
 		}
+	} else {
+		workflowInfo.Status = api.WorkflowStatusInProgress
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(workflows)
+	return workflowInfo
 }
@@ -0,0 +1,145 @@
+// Package i18n serves localized display names for the identifiers the API uses (sensor
+// types, units, subsystem types and alert keys), so clients can show translated text
+// without hard-coding backend strings.
+//
+// Catalogs live in locales/<language>.json. English is the fallback: any name missing
+// from another catalog is served in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Fallback is the language served when nothing better matches
+const Fallback = "en"
+
+// Catalog holds the display names for one language, keyed by API identifier
+type Catalog struct {
+	Language       string            `json:"language"`
+	SensorTypes    map[string]string `json:"sensor_types"`
+	Units          map[string]string `json:"units"`
+	SubsystemTypes map[string]string `json:"subsystem_types"`
+	// Alerts maps alert keys to title templates with {param} placeholders
+	Alerts map[string]string `json:"alerts"`
+}
+
+// AlertTitle renders the title template for key with params, or returns "" if the
+// catalog has none
+func (c *Catalog) AlertTitle(key string, params map[string]string) string {
+	tmpl, ok := c.Alerts[key]
+	if !ok {
+		return ""
+	}
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
+
+var (
+	catalogs map[string]*Catalog
+	tags     []language.Tag
+	matcher  language.Matcher
+)
+
+func init() {
+	var err error
+	catalogs, err = load()
+	if err != nil {
+		panic(err)
+	}
+	names := Languages()
+	// The fallback must come first; the matcher returns it when nothing matches.
+	tags = []language.Tag{language.Make(Fallback)}
+	for _, name := range names {
+		if name != Fallback {
+			tags = append(tags, language.Make(name))
+		}
+	}
+	matcher = language.NewMatcher(tags)
+}
+
+func load() (map[string]*Catalog, error) {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	loaded := make(map[string]*Catalog, len(files))
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), ".json")
+		b, err := locales.ReadFile(path.Join("locales", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		c := &Catalog{}
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("failed to parse catalog %s: %w", f.Name(), err)
+		}
+		c.Language = name
+		loaded[name] = c
+	}
+	fallback, ok := loaded[Fallback]
+	if !ok {
+		return nil, fmt.Errorf("missing %s catalog", Fallback)
+	}
+	for _, c := range loaded {
+		c.SensorTypes = withFallback(c.SensorTypes, fallback.SensorTypes)
+		c.Units = withFallback(c.Units, fallback.Units)
+		c.SubsystemTypes = withFallback(c.SubsystemTypes, fallback.SubsystemTypes)
+		c.Alerts = withFallback(c.Alerts, fallback.Alerts)
+	}
+	return loaded, nil
+}
+
+func withFallback(names, fallback map[string]string) map[string]string {
+	merged := make(map[string]string, len(fallback))
+	for k, v := range fallback {
+		merged[k] = v
+	}
+	for k, v := range names {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Languages returns the languages with a catalog, sorted
+func Languages() []string {
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the catalog for an exact language name
+func Get(lang string) (*Catalog, bool) {
+	c, ok := catalogs[lang]
+	return c, ok
+}
+
+// Match returns the catalog best matching an Accept-Language header value, falling
+// back to English
+func Match(acceptLanguage string) *Catalog {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return catalogs[Fallback]
+	}
+	_, i, _ := matcher.Match(prefs...)
+	base, _ := tags[i].Base()
+	if c, ok := catalogs[base.String()]; ok {
+		return c
+	}
+	return catalogs[Fallback]
+}
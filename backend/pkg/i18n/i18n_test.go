@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// TestCatalogsComplete fails when a catalog lacks a name the English one has, so a new
+// identifier can't ship without its translations
+func TestCatalogsComplete(t *testing.T) {
+	var en Catalog
+	b, err := locales.ReadFile("locales/en.json")
+	if err != nil {
+		t.Fatalf("Failed to read en catalog: %v", err)
+	}
+	if err := json.Unmarshal(b, &en); err != nil {
+		t.Fatalf("Failed to parse en catalog: %v", err)
+	}
+
+	for _, lang := range Languages() {
+		b, err := locales.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			t.Fatalf("Failed to read %s catalog: %v", lang, err)
+		}
+		var c Catalog
+		if err := json.Unmarshal(b, &c); err != nil {
+			t.Fatalf("Failed to parse %s catalog: %v", lang, err)
+		}
+		for section, names := range map[string][2]map[string]string{
+			"sensor_types":    {en.SensorTypes, c.SensorTypes},
+			"units":           {en.Units, c.Units},
+			"subsystem_types": {en.SubsystemTypes, c.SubsystemTypes},
+			"alerts":          {en.Alerts, c.Alerts},
+		} {
+			for key := range names[0] {
+				if _, ok := names[1][key]; !ok {
+					t.Errorf("Expected %s catalog to translate %s %q", lang, section, key)
+				}
+			}
+		}
+	}
+}
+
+func TestCatalogsCoverAPI(t *testing.T) {
+	en, _ := Get(Fallback)
+	for _, st := range []api.SensorType{api.SensorTypeTemperature, api.SensorTypePH, api.SensorTypeLeak} {
+		if en.SensorTypes[string(st)] == "" {
+			t.Errorf("Expected a display name for sensor type %s", st)
+		}
+	}
+	for _, u := range []api.Unit{api.UnitCelsius, api.UnitLitersPerMin, api.UnitMicroSiemens} {
+		if en.Units[string(u)] == "" {
+			t.Errorf("Expected a display name for unit %s", u)
+		}
+	}
+	for _, key := range []string{api.AlertKeyLeakDetected, api.AlertKeyPumpDryRun, api.AlertKeyPumpSwitchoverFailed, api.AlertKeySPCOutOfControl, api.AlertKeyRateOfChange, api.AlertKeyCompositeRule, api.AlertKeyStorageQuota} {
+		if en.Alerts[key] == "" {
+			t.Errorf("Expected a title for alert %s", key)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"de-CH, en;q=0.5", "de"},
+		{"fr-CA", "fr"},
+		{"ja, de;q=0.3", "de"},
+		{"ja", "en"},
+		{"not a header!", "en"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header).Language; got != tt.want {
+			t.Errorf("Match(%q) = %s, expected %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAlertTitle(t *testing.T) {
+	de, ok := Get("de")
+	if !ok {
+		t.Fatal("Expected a de catalog")
+	}
+	got := de.AlertTitle(api.AlertKeyLeakDetected, map[string]string{"subsystem": "sump"})
+	if got != "Leck erkannt: sump" {
+		t.Errorf("Expected localized title, got %q", got)
+	}
+	if got := de.AlertTitle("unknown", nil); got != "" {
+		t.Errorf("Expected empty title for unknown key, got %q", got)
+	}
+	if !strings.Contains(de.AlertTitle(api.AlertKeyPumpDryRun, nil), "{") {
+		t.Error("Expected placeholders without params to be left in place")
+	}
+}
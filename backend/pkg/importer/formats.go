@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apexTimeLayout is the datalog's record date format, in the controller's local time
+const apexTimeLayout = "01/02/2006 15:04:05"
+
+type apexDatalog struct {
+	Records []struct {
+		Date   string `xml:"date"`
+		Probes []struct {
+			Name  string `xml:"name"`
+			Value string `xml:"value"`
+		} `xml:"probe"`
+	} `xml:"record"`
+}
+
+// ParseApex reads an Apex datalog.xml export. Record dates carry no zone and are
+// interpreted in loc.
+func ParseApex(r io.Reader, loc *time.Location) ([]Sample, error) {
+	var log apexDatalog
+	if err := xml.NewDecoder(r).Decode(&log); err != nil {
+		return nil, fmt.Errorf("failed to parse apex datalog: %w", err)
+	}
+	var samples []Sample
+	for _, rec := range log.Records {
+		ts, err := time.ParseInLocation(apexTimeLayout, strings.TrimSpace(rec.Date), loc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse apex record date %q: %w", rec.Date, err)
+		}
+		for _, p := range rec.Probes {
+			v, err := strconv.ParseFloat(strings.TrimSpace(p.Value), 64)
+			if err != nil {
+				continue // outlets and switches log non-numeric states
+			}
+			samples = append(samples, Sample{Source: strings.TrimSpace(p.Name), Value: v, Timestamp: ts})
+		}
+	}
+	return samples, nil
+}
+
+// reefPiTimeLayout is the time format of reef-pi's reading history
+const reefPiTimeLayout = "Jan-02-15:04, 2006"
+
+type reefPiReading struct {
+	Value float64 `json:"value"`
+	Time  string  `json:"time"`
+}
+
+// ParseReefPi reads the reading history reef-pi serves for one sensor, such as
+// /api/tcs/<id>/usage, attributing every sample to source. Both the historical (hourly)
+// and current series are read; times are interpreted in loc.
+func ParseReefPi(r io.Reader, source string, loc *time.Location) ([]Sample, error) {
+	var usage struct {
+		Historical []reefPiReading `json:"historical"`
+		Current    []reefPiReading `json:"current"`
+	}
+	if err := json.NewDecoder(r).Decode(&usage); err != nil {
+		return nil, fmt.Errorf("failed to parse reef-pi history: %w", err)
+	}
+	var samples []Sample
+	for _, series := range [][]reefPiReading{usage.Historical, usage.Current} {
+		for _, reading := range series {
+			ts, err := time.ParseInLocation(reefPiTimeLayout, reading.Time, loc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse reef-pi time %q: %w", reading.Time, err)
+			}
+			samples = append(samples, Sample{Source: source, Value: reading.Value, Timestamp: ts})
+		}
+	}
+	return samples, nil
+}
+
+// ParseHomeAssistant reads the entity_id,state,last_changed CSV downloaded from the Home
+// Assistant history panel. Non-numeric states such as "unavailable" are skipped.
+func ParseHomeAssistant(r io.Reader) ([]Sample, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read home assistant header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"entity_id", "state", "last_changed"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("home assistant export is missing column %q", name)
+		}
+	}
+
+	var samples []Sample
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read home assistant row: %w", err)
+		}
+		v, err := strconv.ParseFloat(row[cols["state"]], 64)
+		if err != nil {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, row[cols["last_changed"]])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse home assistant time %q: %w", row[cols["last_changed"]], err)
+		}
+		samples = append(samples, Sample{Source: row[cols["entity_id"]], Value: v, Timestamp: ts})
+	}
+	return samples, nil
+}
@@ -0,0 +1,130 @@
+// Package importer loads historical readings exported by other aquarium and home
+// automation systems into the readings store, so history is not lost on migration.
+//
+// Exports are parsed into Samples named by the source system (an Apex probe name, a
+// reef-pi sensor or a Home Assistant entity ID), then mapped to sensors by tag.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Format identifies the system an export came from
+type Format string
+
+const (
+	// FormatApex is the XML datalog served by an Apex controller at /cgi-bin/datalog.xml
+	FormatApex Format = "apex"
+	// FormatReefPi is the JSON reading history of one reef-pi sensor
+	FormatReefPi Format = "reef-pi"
+	// FormatHomeAssistant is the CSV downloaded from the Home Assistant history panel
+	FormatHomeAssistant Format = "home-assistant"
+)
+
+// Sample is one value read from an export, named by the source system
+type Sample struct {
+	Source    string
+	Value     float64
+	Timestamp time.Time
+}
+
+// Mapping attributes a source's samples to the sensor with a tag
+type Mapping struct {
+	Source    string   `json:"source"`
+	SensorTag string   `json:"sensor"`
+	Unit      api.Unit `json:"unit,omitempty"`
+}
+
+// Config describes how to interpret an export
+type Config struct {
+	Mappings []Mapping `json:"mappings"`
+	// Timezone of timestamps without one, as an IANA name; defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+	// Source names the samples of single-sensor exports such as reef-pi's
+	Source string `json:"source,omitempty"`
+}
+
+// Result summarises an import
+type Result struct {
+	Imported int `json:"imported"`
+	// Skipped counts samples per source that no mapping covers
+	Skipped map[string]int `json:"skipped,omitempty"`
+}
+
+// ErrUnknownFormat is returned for a format without a parser
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// Parse reads the samples from an export
+func Parse(format Format, r io.Reader, cfg Config) ([]Sample, error) {
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("failed to load timezone: %w", err)
+		}
+	}
+	switch format {
+	case FormatApex:
+		return ParseApex(r, loc)
+	case FormatReefPi:
+		if cfg.Source == "" {
+			return nil, errors.New("reef-pi exports need a source name")
+		}
+		return ParseReefPi(r, cfg.Source, loc)
+	case FormatHomeAssistant:
+		return ParseHomeAssistant(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Import stores samples as readings of their mapped sensors. Every mapped sensor tag
+// must resolve before anything is stored.
+func Import(ctx context.Context, store storer.Interface, samples []Sample, mappings []Mapping) (*Result, error) {
+	type target struct {
+		sensor *api.Sensor
+		unit   api.Unit
+	}
+	targets := make(map[string]target, len(mappings))
+	for _, m := range mappings {
+		sensor, err := store.GetSensorByTag(ctx, m.SensorTag)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve sensor %q for %q: %w", m.SensorTag, m.Source, err)
+		}
+		targets[m.Source] = target{sensor: sensor, unit: m.Unit}
+	}
+
+	result := &Result{}
+	for _, s := range samples {
+		t, ok := targets[s.Source]
+		if !ok {
+			if result.Skipped == nil {
+				result.Skipped = make(map[string]int)
+			}
+			result.Skipped[s.Source]++
+			continue
+		}
+		rec := &api.ReadingRecord{
+			DeviceID: t.sensor.DeviceID,
+			SensorID: t.sensor.ID,
+			Reading: api.SensorReading{
+				Value:     s.Value,
+				Unit:      t.unit,
+				Timestamp: s.Timestamp,
+				Valid:     true,
+			},
+		}
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			return result, fmt.Errorf("failed to store reading for %q at %s: %w", s.Source, s.Timestamp, err)
+		}
+		result.Imported++
+	}
+	return result, nil
+}
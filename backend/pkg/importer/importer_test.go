@@ -0,0 +1,108 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/storer/storertest"
+)
+
+func parseFile(t *testing.T, format Format, file string, cfg Config) []Sample {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+	samples, err := Parse(format, f, cfg)
+	if err != nil {
+		t.Fatalf("Parse(%s) error = %v", format, err)
+	}
+	return samples
+}
+
+func TestParseApex(t *testing.T) {
+	samples := parseFile(t, FormatApex, "testdata/datalog.xml", Config{Timezone: "America/New_York"})
+	if len(samples) != 4 {
+		t.Fatalf("Expected 4 numeric samples, got %d: %+v", len(samples), samples)
+	}
+	want := time.Date(2026, 1, 31, 15, 30, 0, 0, time.UTC)
+	if samples[0].Source != "Tmp" || samples[0].Value != 25.5 || !samples[0].Timestamp.Equal(want) {
+		t.Errorf("Expected Tmp=25.5 at %s, got %+v", want, samples[0])
+	}
+}
+
+func TestParseReefPi(t *testing.T) {
+	if _, err := Parse(FormatReefPi, nil, Config{}); err == nil {
+		t.Error("Expected reef-pi export without a source name to fail")
+	}
+	samples := parseFile(t, FormatReefPi, "testdata/reefpi.json", Config{Source: "display-temp"})
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 samples, got %d", len(samples))
+	}
+	want := time.Date(2026, 1, 31, 0, 5, 0, 0, time.UTC)
+	last := samples[2]
+	if last.Source != "display-temp" || last.Value != 25.4 || !last.Timestamp.Equal(want) {
+		t.Errorf("Expected display-temp=25.4 at %s, got %+v", want, last)
+	}
+}
+
+func TestParseHomeAssistant(t *testing.T) {
+	samples := parseFile(t, FormatHomeAssistant, "testdata/history.csv", Config{})
+	if len(samples) != 3 {
+		t.Fatalf("Expected 3 numeric samples, got %d", len(samples))
+	}
+	if samples[1].Source != "sensor.sump_temperature" || samples[1].Value != 25.4 {
+		t.Errorf("Expected second sample to skip the unavailable state, got %+v", samples[1])
+	}
+}
+
+func TestParse_UnknownFormat(t *testing.T) {
+	if _, err := Parse("fusion-pdf", nil, Config{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:     "sump",
+		Driver: "gateway",
+		Name:   "Sump",
+		Sensors: []*api.Sensor{
+			{ID: "temp", DeviceID: "sump", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"sump-temp"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	samples := parseFile(t, FormatHomeAssistant, "testdata/history.csv", Config{})
+	mappings := []Mapping{{Source: "sensor.sump_temperature", SensorTag: "sump-temp", Unit: api.UnitCelsius}}
+	result, err := Import(ctx, store, samples, mappings)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if result.Imported != 2 || result.Skipped["sensor.living_room_humidity"] != 1 {
+		t.Errorf("Expected 2 imported and 1 skipped, got %+v", result)
+	}
+
+	readings, err := store.GetSensorReadings(ctx, storer.SensorReadingFilters{DeviceID: "sump", SensorID: "temp"})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(readings) != 2 || readings[0].Reading.Value != 25.4 || readings[0].Reading.Unit != api.UnitCelsius {
+		t.Errorf("Expected 2 readings, newest 25.4 °C, got %+v", readings)
+	}
+
+	mappings = append(mappings, Mapping{Source: "sensor.other", SensorTag: "missing"})
+	if _, err := Import(ctx, store, samples, mappings); !errors.Is(err, storer.ErrNotFound) {
+		t.Errorf("Expected unresolvable mapping to fail with ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/supervise"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultMaxBatch      = 500
+	defaultFlushInterval = time.Second
+	defaultStatsInterval = time.Minute
+)
+
+// BatchStore persists readings, either one at a time or as a multi-row batch
+type BatchStore interface {
+	StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error
+	StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error
+}
+
+// BatchConfig controls when a Batcher flushes. Zero values select the defaults.
+type BatchConfig struct {
+	// MaxBatch flushes as soon as this many readings are buffered
+	MaxBatch int `json:"max_batch,omitempty"`
+	// FlushInterval is the longest a reading waits in the buffer
+	FlushInterval time.Duration `json:"flush_interval,omitempty"`
+	// MaxPending is how many readings may be buffered before writers block; defaults to
+	// ten batches
+	MaxPending int `json:"max_pending,omitempty"`
+}
+
+// BatchStats are cumulative counters describing a Batcher's throughput and backpressure
+type BatchStats struct {
+	// Pending is the number of readings currently buffered
+	Pending  int    `json:"pending"`
+	Stored   uint64 `json:"stored"`
+	Failed   uint64 `json:"failed"`
+	Flushes  uint64 `json:"flushes"`
+	Fallback uint64 `json:"fallback"`
+	// Blocked counts writes which waited for buffer space, and BlockedTime the total wait
+	Blocked     uint64        `json:"blocked"`
+	BlockedTime time.Duration `json:"blocked_time"`
+	// LastFlush is how long the most recent flush took
+	LastFlush time.Duration `json:"last_flush"`
+}
+
+type BatcherOption func(*Batcher)
+
+func WithBatcherLogger(logger zerolog.Logger) BatcherOption {
+	return func(b *Batcher) {
+		b.log = logger
+	}
+}
+
+// WithStatsInterval sets how often buffer statistics are logged; zero disables logging
+func WithStatsInterval(interval time.Duration) BatcherOption {
+	return func(b *Batcher) {
+		b.statsInterval = interval
+	}
+}
+
+// Batcher buffers readings and writes them with multi-row inserts, flushing when a batch
+// fills or the flush interval passes. Once MaxPending readings are buffered, writers
+// block until a flush makes room, pushing back on the MQTT client rather than growing
+// without bound. Writes are acknowledged once buffered, so storage errors are logged and
+// counted rather than returned.
+type Batcher struct {
+	store         BatchStore
+	cfg           BatchConfig
+	statsInterval time.Duration
+	log           zerolog.Logger
+
+	queue chan *api.ReadingRecord
+	stop  chan struct{}
+	done  chan struct{}
+
+	lock  sync.Mutex
+	stats BatchStats
+}
+
+func NewBatcher(store BatchStore, cfg BatchConfig, opts ...BatcherOption) *Batcher {
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = defaultMaxBatch
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.MaxPending < cfg.MaxBatch {
+		cfg.MaxPending = 10 * cfg.MaxBatch
+	}
+	b := &Batcher{
+		store:         store,
+		cfg:           cfg,
+		statsInterval: defaultStatsInterval,
+		queue:         make(chan *api.ReadingRecord, cfg.MaxPending),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Batcher) logCtx(ctx context.Context, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = b.log.With()
+	}
+	ll = ll.Str("component", "ingest")
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "ingest")
+}
+
+// Start begins flushing in the background until Stop is called
+func (b *Batcher) Start(ctx context.Context) error {
+	ll := b.logCtx(ctx, "batch")
+	ll.Info().
+		Int("max_batch", b.cfg.MaxBatch).
+		Dur("flush_interval", b.cfg.FlushInterval).
+		Int("max_pending", b.cfg.MaxPending).
+		Msg("Starting reading write batching")
+	go b.run(context.WithoutCancel(ctx))
+	return nil
+}
+
+// Stop flushes buffered readings and stops the background flusher
+func (b *Batcher) Stop(ctx context.Context) error {
+	close(b.stop)
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StoreSensorReading buffers rec, blocking while the buffer is full
+func (b *Batcher) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	select {
+	case b.queue <- rec:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	defer func() {
+		b.lock.Lock()
+		b.stats.Blocked++
+		b.stats.BlockedTime += time.Since(start)
+		b.lock.Unlock()
+	}()
+	select {
+	case b.queue <- rec:
+		return nil
+	case <-b.stop:
+		return errors.New("reading batcher stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StoreSensorReadings buffers each of recs
+func (b *Batcher) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	for _, rec := range recs {
+		if err := b.StoreSensorReading(ctx, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the batcher's counters
+func (b *Batcher) Stats() BatchStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	stats := b.stats
+	stats.Pending = len(b.queue)
+	return stats
+}
+
+// run flushes until Stop, restarting the loop if it panics so ingestion carries on; the
+// readings buffered at the time are lost
+func (b *Batcher) run(ctx context.Context) {
+	defer close(b.done)
+	// Stopping also cuts short a restart's backoff
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stop:
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+	supervise.Run(runCtx, "ingest.batch", func(context.Context) error {
+		return b.loop(ctx)
+	}, supervise.WithLogger(b.log))
+}
+
+func (b *Batcher) loop(ctx context.Context) error {
+	ll := b.logCtx(ctx, "batch")
+
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+	var statsC <-chan time.Time
+	if b.statsInterval > 0 {
+		statsTicker := time.NewTicker(b.statsInterval)
+		defer statsTicker.Stop()
+		statsC = statsTicker.C
+	}
+
+	batch := make([]*api.ReadingRecord, 0, b.cfg.MaxBatch)
+	for {
+		select {
+		case rec := <-b.queue:
+			batch = append(batch, rec)
+			if len(batch) >= b.cfg.MaxBatch {
+				b.flush(ctx, batch)
+				batch = batch[:0]
+				ticker.Reset(b.cfg.FlushInterval)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-statsC:
+			stats := b.Stats()
+			ll.Info().
+				Int("pending", stats.Pending).
+				Uint64("stored", stats.Stored).
+				Uint64("failed", stats.Failed).
+				Uint64("flushes", stats.Flushes).
+				Uint64("blocked", stats.Blocked).
+				Dur("blocked_time", stats.BlockedTime).
+				Dur("last_flush", stats.LastFlush).
+				Msg("reading write batching stats")
+		case <-b.stop:
+			// Drain whatever writers managed to buffer before stopping
+			for {
+				select {
+				case rec := <-b.queue:
+					batch = append(batch, rec)
+					if len(batch) >= b.cfg.MaxBatch {
+						b.flush(ctx, batch)
+						batch = batch[:0]
+					}
+				default:
+					if len(batch) > 0 {
+						b.flush(ctx, batch)
+					}
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// flush writes batch with a single multi-row insert. If that fails because one of the
+// sensors does not exist, the readings are retried one at a time so a single unknown
+// sensor does not cost the rest of the batch.
+func (b *Batcher) flush(ctx context.Context, batch []*api.ReadingRecord) {
+	ll := b.logCtx(ctx, "batch")
+	start := time.Now()
+
+	stored, failed := len(batch), 0
+	fallback := false
+	err := b.store.StoreSensorReadings(ctx, batch)
+	switch {
+	case err == nil:
+	case errors.Is(err, storer.ErrNotFound):
+		fallback = true
+		stored = 0
+		for _, rec := range batch {
+			if err := b.store.StoreSensorReading(ctx, rec); err != nil {
+				ll.Debug().Err(err).Str("device_id", rec.DeviceID).Str("sensor_id", rec.SensorID).Msg("dropping reading")
+				failed++
+				continue
+			}
+			stored++
+		}
+	default:
+		ll.Error().Err(err).Int("count", len(batch)).Msg("failed to store reading batch")
+		stored, failed = 0, len(batch)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stats.Flushes++
+	b.stats.Stored += uint64(stored)
+	b.stats.Failed += uint64(failed)
+	if fallback {
+		b.stats.Fallback++
+	}
+	b.stats.LastFlush = time.Since(start)
+}
@@ -0,0 +1,161 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// batchStore records each multi-row write, optionally blocking them until release is
+// closed and rejecting readings for unknown sensors like the real stores
+type batchStore struct {
+	mu      sync.Mutex
+	batches [][]*api.ReadingRecord
+	single  []*api.ReadingRecord
+	unknown string
+	release chan struct{}
+}
+
+func (s *batchStore) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	if rec.SensorID == s.unknown {
+		return storer.ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.single = append(s.single, rec)
+	return nil
+}
+
+func (s *batchStore) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	if s.release != nil {
+		<-s.release
+	}
+	for _, rec := range recs {
+		if rec.SensorID == s.unknown {
+			return storer.ErrNotFound
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]*api.ReadingRecord(nil), recs...))
+	return nil
+}
+
+func (s *batchStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sizes []int
+	for _, b := range s.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func reading(sensorID string) *api.ReadingRecord {
+	return &api.ReadingRecord{DeviceID: "dev-1", SensorID: sensorID, Reading: api.SensorReading{Value: 1, Valid: true}}
+}
+
+func TestBatcher_FlushOnSize(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore{}
+	b := NewBatcher(store, BatchConfig{MaxBatch: 3, FlushInterval: time.Hour})
+	b.Start(ctx)
+
+	for range 7 {
+		if err := b.StoreSensorReading(ctx, reading("power")); err != nil {
+			t.Fatalf("Failed to buffer reading: %v", err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(store.batchSizes()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := store.batchSizes(); len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 3 {
+		t.Fatalf("Expected two full batches before stopping, got %v", sizes)
+	}
+
+	// Stopping flushes the remainder
+	if err := b.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop batcher: %v", err)
+	}
+	if sizes := store.batchSizes(); len(sizes) != 3 || sizes[2] != 1 {
+		t.Errorf("Expected the last reading flushed on stop, got %v", sizes)
+	}
+	if stats := b.Stats(); stats.Stored != 7 || stats.Flushes != 3 || stats.Pending != 0 {
+		t.Errorf("Expected 7 stored in 3 flushes, got %+v", stats)
+	}
+}
+
+func TestBatcher_FlushOnInterval(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore{}
+	b := NewBatcher(store, BatchConfig{MaxBatch: 100, FlushInterval: 10 * time.Millisecond})
+	b.Start(ctx)
+	defer b.Stop(ctx)
+
+	b.StoreSensorReadings(ctx, []*api.ReadingRecord{reading("power"), reading("voltage")})
+	deadline := time.Now().Add(time.Second)
+	for len(store.batchSizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := store.batchSizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Errorf("Expected one batch of 2 after the flush interval, got %v", sizes)
+	}
+}
+
+func TestBatcher_Backpressure(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore{release: make(chan struct{})}
+	b := NewBatcher(store, BatchConfig{MaxBatch: 2, FlushInterval: time.Hour, MaxPending: 2})
+	b.Start(ctx)
+
+	// The first batch is taken and stalls in the store; two more fill the buffer
+	for range 4 {
+		b.StoreSensorReading(ctx, reading("power"))
+	}
+	deadline := time.Now().Add(time.Second)
+	for b.Stats().Pending < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before := b.Stats().Blocked
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := b.StoreSensorReading(timeoutCtx, reading("power")); err == nil {
+		t.Fatalf("Expected a full buffer to block until the context expired")
+	}
+	if stats := b.Stats(); stats.Blocked != before+1 || stats.BlockedTime < 20*time.Millisecond {
+		t.Errorf("Expected one blocked write to be counted, got %+v", stats)
+	}
+
+	close(store.release)
+	if err := b.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop batcher: %v", err)
+	}
+	if stats := b.Stats(); stats.Stored != 4 {
+		t.Errorf("Expected 4 stored readings once the store recovered, got %+v", stats)
+	}
+}
+
+func TestBatcher_UnknownSensorFallback(t *testing.T) {
+	ctx := context.Background()
+	store := &batchStore{unknown: "missing"}
+	b := NewBatcher(store, BatchConfig{MaxBatch: 3, FlushInterval: time.Hour})
+	b.Start(ctx)
+
+	b.StoreSensorReadings(ctx, []*api.ReadingRecord{reading("power"), reading("missing"), reading("voltage")})
+	if err := b.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop batcher: %v", err)
+	}
+
+	if len(store.single) != 2 {
+		t.Errorf("Expected the 2 known readings stored individually, got %d", len(store.single))
+	}
+	if stats := b.Stats(); stats.Stored != 2 || stats.Failed != 1 || stats.Fallback != 1 {
+		t.Errorf("Expected 2 stored and 1 failed via fallback, got %+v", stats)
+	}
+}
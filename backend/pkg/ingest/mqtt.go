@@ -0,0 +1,158 @@
+// Package ingest accepts sensor readings pushed by edge gateways and stores them
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/compact"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/transport"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadingStore persists ingested readings
+type ReadingStore interface {
+	StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error
+}
+
+type Option func(*Subscriber)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(s *Subscriber) {
+		s.log = logger
+	}
+}
+
+// Subscriber subscribes to a subject on which gateways publish batches of readings,
+// either as JSON (an object or array of reading records) or in the compact CBOR form
+type Subscriber struct {
+	transport transport.Transport
+	subject   string
+	durable   string
+	store     ReadingStore
+	log       zerolog.Logger
+	sub       transport.Subscription
+}
+
+// NewSubscriber ingests readings published to subject on t. Workers subscribing with the
+// same durable name share the readings between them.
+func NewSubscriber(t transport.Transport, subject, durable string, store ReadingStore, opts ...Option) *Subscriber {
+	s := &Subscriber{
+		transport: t,
+		subject:   subject,
+		durable:   durable,
+		store:     store,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewMQTT ingests readings published to topic on an MQTT broker
+func NewMQTT(client mqtt.Client, topic string, store ReadingStore, opts ...Option) *Subscriber {
+	return NewSubscriber(transport.NewMQTT(client), topic, "", store, opts...)
+}
+
+func (s *Subscriber) logCtx(ctx context.Context, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = s.log.With()
+	}
+	ll = ll.Str("component", "ingest")
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "ingest")
+}
+
+func (s *Subscriber) Start(ctx context.Context) error {
+	ll := s.logCtx(ctx, "subscriber")
+	ll.Info().Str("subject", s.subject).Msg("Subscribing to reading ingestion subject")
+	sub, err := s.transport.Subscribe(ctx, s.subject, s.durable, s.handleMessage)
+	if err != nil {
+		return err
+	}
+	s.sub = sub
+	return nil
+}
+
+func (s *Subscriber) Stop(ctx context.Context) error {
+	if s.sub == nil {
+		return nil
+	}
+	err := s.sub.Unsubscribe(ctx)
+	s.sub = nil
+	return err
+}
+
+// handleMessage stores a batch of readings. It returns an error, asking for redelivery,
+// only when none could be stored; redelivering a partly stored batch would duplicate
+// the rest.
+func (s *Subscriber) handleMessage(ctx context.Context, subject string, payload []byte) error {
+	ctx = s.log.WithContext(ctx)
+	ll := s.logCtx(ctx, "subscriber").With().Str("subject", subject).Logger()
+
+	readings, err := DecodePayload(payload)
+	if err != nil {
+		ll.Warn().Err(err).Msg("dropping malformed reading batch")
+		return nil
+	}
+	stored, err := s.storeAll(ctx, readings)
+	if err != nil {
+		ll.Error().Err(err).Int("stored", stored).Msg("failed to store readings")
+		if stored == 0 && len(readings) > 0 {
+			return err
+		}
+		return nil
+	}
+	ll.Debug().Int("readings", len(readings)).Msg("ingested readings")
+	return nil
+}
+
+// storeAll stores each reading, returning how many were stored
+func (s *Subscriber) storeAll(ctx context.Context, readings []*api.ReadingRecord) (int, error) {
+	var errs []error
+	for _, rec := range readings {
+		if rec.Reading.Timestamp.IsZero() {
+			rec.Reading.Timestamp = time.Now()
+		}
+		if err := s.store.StoreSensorReading(ctx, rec); err != nil {
+			errs = append(errs, fmt.Errorf("%s/%s: %w", rec.DeviceID, rec.SensorID, err))
+		}
+	}
+	return len(readings) - len(errs), errors.Join(errs...)
+}
+
+// DecodePayload detects whether payload is JSON or compact CBOR and decodes it. A CBOR
+// array always starts with a byte in 0x80-0x9f, which is never valid leading JSON.
+func DecodePayload(payload []byte) ([]*api.ReadingRecord, error) {
+	if len(payload) > 0 && payload[0]>>5 == 4 {
+		return compact.Decode(payload)
+	}
+
+	payload = bytes.TrimSpace(payload)
+	if len(payload) > 0 && payload[0] == '[' {
+		var readings []*api.ReadingRecord
+		if err := json.Unmarshal(payload, &readings); err != nil {
+			return nil, err
+		}
+		return readings, nil
+	}
+	var rec api.ReadingRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return nil, err
+	}
+	return []*api.ReadingRecord{&rec}, nil
+}
@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/compact"
+)
+
+type memStore struct {
+	readings []*api.ReadingRecord
+}
+
+func (m *memStore) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	m.readings = append(m.readings, rec)
+	return nil
+}
+
+func TestMQTT_HandleMessage(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &memStore{}
+	m := NewMQTT(nil, "lifesupport/ingest/#", store)
+	ctx := context.Background()
+
+	m.handleMessage(ctx, "lifesupport/ingest/gw-1", compact.Encode([]*api.ReadingRecord{
+		{DeviceID: "gw-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Timestamp: ts, Valid: true}},
+		{DeviceID: "gw-1", SensorID: "ph", Reading: api.SensorReading{Value: 8.2, Timestamp: ts, Valid: true}},
+	}))
+	m.handleMessage(ctx, "lifesupport/ingest/gw-2", []byte(`{"device_id":"gw-2","sensor_id":"level","reading":{"value":12,"valid":true}}`))
+	m.handleMessage(ctx, "lifesupport/ingest/gw-3", []byte("garbage"))
+
+	if len(store.readings) != 3 {
+		t.Fatalf("Expected 3 stored readings, got %d", len(store.readings))
+	}
+	if got := store.readings[2]; got.DeviceID != "gw-2" || got.Reading.Timestamp.IsZero() {
+		t.Errorf("Expected JSON reading with a defaulted timestamp, got %+v", got)
+	}
+}
+
+type failingStore struct {
+	failures int
+	memStore
+}
+
+func (f *failingStore) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("database unavailable")
+	}
+	return f.memStore.StoreSensorReading(ctx, rec)
+}
+
+func TestSubscriber_HandleMessageRedelivery(t *testing.T) {
+	ctx := context.Background()
+	batch := []byte(`[{"device_id":"gw-1","sensor_id":"temp","reading":{"value":25,"valid":true}},{"device_id":"gw-1","sensor_id":"ph","reading":{"value":8,"valid":true}}]`)
+
+	// Nothing stored: ask for redelivery
+	store := &failingStore{failures: 2}
+	s := NewSubscriber(nil, "lifesupport.ingest.>", "ingest", store)
+	if err := s.handleMessage(ctx, "lifesupport.ingest.gw-1", batch); err == nil {
+		t.Error("Expected an error when no readings were stored")
+	}
+
+	// Partly stored: redelivery would duplicate the stored reading
+	store = &failingStore{failures: 1}
+	s = NewSubscriber(nil, "lifesupport.ingest.>", "ingest", store)
+	if err := s.handleMessage(ctx, "lifesupport.ingest.gw-1", batch); err != nil {
+		t.Errorf("Expected no error for a partly stored batch, got %v", err)
+	}
+	if len(store.readings) != 1 {
+		t.Errorf("Expected 1 stored reading, got %d", len(store.readings))
+	}
+
+	// Malformed batches never succeed, so are dropped
+	if err := s.handleMessage(ctx, "lifesupport.ingest.gw-1", []byte("garbage")); err != nil {
+		t.Errorf("Expected malformed batches to be dropped, got %v", err)
+	}
+}
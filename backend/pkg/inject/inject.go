@@ -0,0 +1,108 @@
+// Package inject posts synthetic sensor readings through the HTTP ingestion API, so
+// alert rules and automations can be exercised without touching real hardware
+package inject
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Reading describes a reading to inject
+type Reading struct {
+	// SensorTag identifies the sensor the reading is attributed to
+	SensorTag string
+	Value     float64
+	Unit      api.Unit
+	// Timestamp defaults to now
+	Timestamp time.Time
+}
+
+// Client injects readings into a running HTTP API server
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// New returns a client for the API server at baseURL. A nil client uses
+// http.DefaultClient.
+func New(baseURL string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// Inject resolves the reading's sensor tag and posts it as a synthetic reading through
+// the same endpoint gateways use, returning the stored record
+func (c *Client) Inject(ctx context.Context, r Reading) (*api.ReadingRecord, error) {
+	if r.SensorTag == "" {
+		return nil, fmt.Errorf("sensor tag is required")
+	}
+	var sensor api.Sensor
+	if err := c.do(ctx, http.MethodGet, "/api/sensors/by-tag/"+url.PathEscape(r.SensorTag), nil, &sensor); err != nil {
+		return nil, fmt.Errorf("failed to resolve sensor %q: %w", r.SensorTag, err)
+	}
+
+	ts := r.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	rec := &api.ReadingRecord{
+		DeviceID: sensor.DeviceID,
+		SensorID: sensor.ID,
+		Reading: api.SensorReading{
+			Value:     r.Value,
+			Unit:      r.Unit,
+			Timestamp: ts,
+			Valid:     true,
+			Synthetic: true,
+		},
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/sensor-readings", rec, nil); err != nil {
+		return nil, fmt.Errorf("failed to post reading: %w", err)
+	}
+	return rec, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
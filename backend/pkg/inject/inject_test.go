@@ -0,0 +1,61 @@
+package inject
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/httpapi"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/storer/storertest"
+)
+
+func TestInject(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	dev := &api.Device{
+		ID:     "ph-probe",
+		Driver: "gateway",
+		Name:   "pH Probe",
+		Sensors: []*api.Sensor{
+			{ID: "ph", DeviceID: "ph-probe", Name: "pH", SensorType: api.SensorTypePH, Tags: []string{"sump-ph"}},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	srv := httptest.NewServer(httpapi.NewHandler(store, nil, nil).SetupRouter())
+	defer srv.Close()
+
+	c := New(srv.URL, srv.Client())
+	rec, err := c.Inject(ctx, Reading{SensorTag: "sump-ph", Value: 6.2, Unit: api.UnitPH})
+	if err != nil {
+		t.Fatalf("Inject() error = %v", err)
+	}
+	if rec.DeviceID != "ph-probe" || rec.SensorID != "ph" {
+		t.Errorf("Expected reading for ph-probe/ph, got %s/%s", rec.DeviceID, rec.SensorID)
+	}
+
+	readings, err := store.GetSensorReadings(ctx, storer.SensorReadingFilters{DeviceID: "ph-probe", SensorID: "ph"})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(readings) != 1 {
+		t.Fatalf("Expected 1 stored reading, got %d", len(readings))
+	}
+	got := readings[0].Reading
+	if got.Value != 6.2 || got.Unit != api.UnitPH || !got.Valid || !got.Synthetic {
+		t.Errorf("Expected valid synthetic reading of 6.2 pH, got %+v", got)
+	}
+}
+
+func TestInject_UnknownTag(t *testing.T) {
+	srv := httptest.NewServer(httpapi.NewHandler(storer.NewMemory(), nil, nil).SetupRouter())
+	defer srv.Close()
+
+	c := New(srv.URL, srv.Client())
+	if _, err := c.Inject(context.Background(), Reading{SensorTag: "missing", Value: 1}); err == nil {
+		t.Error("Expected error for unknown sensor tag")
+	}
+}
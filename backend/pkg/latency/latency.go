@@ -0,0 +1,336 @@
+// Package latency measures how long the system takes to react, from a device reporting
+// a reading to it being stored and from a command being requested to the hardware
+// confirming it, and reports those against a safety budget
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const (
+	// ReadingStored is the time from a device taking a reading to it being stored
+	ReadingStored = "reading_stored"
+	// CommandConfirmed is the time from a command being requested to the device
+	// confirming the actuator's new state
+	CommandConfirmed = "command_confirmed"
+
+	// DefaultBudget is the reaction time the SLO report holds latencies to
+	DefaultBudget = 5 * time.Second
+	// DefaultObjective is the share of events the SLO report expects within the budget
+	DefaultObjective = 0.99
+)
+
+// bounds are the upper bounds of the histogram buckets; a last bucket holds the rest
+var bounds = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// Histogram counts latencies into fixed buckets. It is safe for concurrent use.
+type Histogram struct {
+	lock     sync.Mutex
+	counts   []uint64
+	failures uint64
+	sum      time.Duration
+	max      time.Duration
+	// observations are the most recent latencies, so the report can check any budget
+	// exactly rather than to the nearest bucket bound
+	observations []time.Duration
+}
+
+// maxObservations bounds the recent observations kept for exact budget checks
+const maxObservations = 10000
+
+func newHistogram() *Histogram {
+	return &Histogram{counts: make([]uint64, len(bounds)+1)}
+}
+
+// Observe counts one latency. Negative latencies, from clocks out of step, count as
+// zero. A nil Histogram counts nothing.
+func (h *Histogram) Observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+	if d < 0 {
+		d = 0
+	}
+	i := sort.Search(len(bounds), func(i int) bool { return d <= bounds[i] })
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.counts[i]++
+	h.sum += d
+	h.max = max(h.max, d)
+	if len(h.observations) == maxObservations {
+		h.observations = h.observations[1:]
+	}
+	h.observations = append(h.observations, d)
+}
+
+// Fail counts an event which never completed, such as a command the device didn't
+// confirm; it counts against the budget
+func (h *Histogram) Fail() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.failures++
+}
+
+// Bucket is the count of latencies no greater than LE; the last bucket has no bound
+type Bucket struct {
+	LE    time.Duration `json:"le,omitempty"`
+	Count uint64        `json:"count"`
+}
+
+// Snapshot is the state of a histogram
+type Snapshot struct {
+	Count    uint64        `json:"count"`
+	Failures uint64        `json:"failures"`
+	Sum      time.Duration `json:"sum"`
+	Max      time.Duration `json:"max"`
+	Buckets  []Bucket      `json:"buckets"`
+}
+
+// Snapshot returns the histogram's counts
+func (h *Histogram) Snapshot() Snapshot {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	snap := Snapshot{Failures: h.failures, Sum: h.sum, Max: h.max, Buckets: make([]Bucket, len(h.counts))}
+	for i, n := range h.counts {
+		snap.Count += n
+		snap.Buckets[i].Count = n
+		if i < len(bounds) {
+			snap.Buckets[i].LE = bounds[i]
+		}
+	}
+	return snap
+}
+
+// Quantile estimates the latency below which q of the observations fall, as the upper
+// bound of its bucket; the last bucket reports the maximum seen
+func (s Snapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(q*float64(s.Count))), 1)
+	var seen uint64
+	for _, b := range s.Buckets {
+		seen += b.Count
+		if seen >= rank {
+			if b.LE == 0 {
+				return s.Max
+			}
+			return min(b.LE, s.Max)
+		}
+	}
+	return s.Max
+}
+
+// within counts the recent observations no greater than budget, and how many were kept
+func (h *Histogram) within(budget time.Duration) (within, kept int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, d := range h.observations {
+		if d <= budget {
+			within++
+		}
+	}
+	return within, len(h.observations)
+}
+
+// Recorder holds the named histograms of a process
+type Recorder struct {
+	lock       sync.Mutex
+	histograms map[string]*Histogram
+	started    time.Time
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{histograms: make(map[string]*Histogram), started: time.Now()}
+}
+
+// Histogram returns the histogram named name, creating it on first use. A nil Recorder
+// returns nil, which records nothing.
+func (r *Recorder) Histogram(name string) *Histogram {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	h, ok := r.histograms[name]
+	if !ok {
+		h = newHistogram()
+		r.histograms[name] = h
+	}
+	return h
+}
+
+// Snapshots returns every histogram's counts by name
+func (r *Recorder) Snapshots() map[string]Snapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	snaps := make(map[string]Snapshot, len(r.histograms))
+	for name, h := range r.histograms {
+		snaps[name] = h.Snapshot()
+	}
+	return snaps
+}
+
+// MetricReport is how one histogram measures up to the budget
+type MetricReport struct {
+	Name     string        `json:"name"`
+	Count    uint64        `json:"count"`
+	Failures uint64        `json:"failures"`
+	P50      time.Duration `json:"p50"`
+	P90      time.Duration `json:"p90"`
+	P99      time.Duration `json:"p99"`
+	Max      time.Duration `json:"max"`
+	// WithinBudget is the share of recent events, failures included, completed within
+	// the budget
+	WithinBudget float64 `json:"within_budget"`
+	Met          bool    `json:"met"`
+}
+
+// Report is the SLO report: whether each measured latency stays within the budget for
+// the objective's share of events
+type Report struct {
+	Budget    time.Duration  `json:"budget"`
+	Objective float64        `json:"objective"`
+	Since     time.Time      `json:"since"`
+	Met       bool           `json:"met"`
+	Metrics   []MetricReport `json:"metrics"`
+}
+
+// Report measures every histogram against budget and objective. Metrics without events
+// meet any objective.
+func (r *Recorder) Report(budget time.Duration, objective float64) Report {
+	r.lock.Lock()
+	names := make([]string, 0, len(r.histograms))
+	histograms := make(map[string]*Histogram, len(r.histograms))
+	for name, h := range r.histograms {
+		names = append(names, name)
+		histograms[name] = h
+	}
+	r.lock.Unlock()
+	sort.Strings(names)
+
+	report := Report{Budget: budget, Objective: objective, Since: r.started, Met: true, Metrics: []MetricReport{}}
+	for _, name := range names {
+		h := histograms[name]
+		snap := h.Snapshot()
+		within, kept := h.within(budget)
+		m := MetricReport{
+			Name:         name,
+			Count:        snap.Count,
+			Failures:     snap.Failures,
+			P50:          snap.Quantile(0.5),
+			P90:          snap.Quantile(0.9),
+			P99:          snap.Quantile(0.99),
+			Max:          snap.Max,
+			WithinBudget: 1,
+		}
+		if total := uint64(kept) + snap.Failures; total > 0 {
+			m.WithinBudget = float64(within) / float64(total)
+		}
+		m.Met = m.WithinBudget >= objective
+		report.Met = report.Met && m.Met
+		report.Metrics = append(report.Metrics, m)
+	}
+	return report
+}
+
+// ServeHistograms serves the histograms' counts as JSON
+func (r *Recorder) ServeHistograms(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Snapshots())
+}
+
+// ServeReport serves the SLO report as JSON. The budget and objective query parameters,
+// such as budget=2s and objective=0.999, override the defaults.
+func (r *Recorder) ServeReport(w http.ResponseWriter, req *http.Request) {
+	budget, objective := DefaultBudget, DefaultObjective
+	if v := req.URL.Query().Get("budget"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "budget must be a positive duration such as 5s", http.StatusBadRequest)
+			return
+		}
+		budget = d
+	}
+	if v := req.URL.Query().Get("objective"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			http.Error(w, "objective must be a fraction between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		objective = f
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.Report(budget, objective))
+}
+
+// ReadingStore stores readings in batches or one at a time
+type ReadingStore interface {
+	StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error
+	StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error
+}
+
+// Readings wraps store so each measured reading it stores is timed from when the device
+// took it. Synthetic readings are left out. A nil histogram returns store unwrapped.
+func Readings(store ReadingStore, h *Histogram) ReadingStore {
+	if h == nil {
+		return store
+	}
+	return timedReadings{next: store, hist: h}
+}
+
+type timedReadings struct {
+	next ReadingStore
+	hist *Histogram
+}
+
+func (t timedReadings) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	if err := t.next.StoreSensorReading(ctx, rec); err != nil {
+		return err
+	}
+	t.observe(time.Now(), rec)
+	return nil
+}
+
+func (t timedReadings) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	if err := t.next.StoreSensorReadings(ctx, recs); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, rec := range recs {
+		t.observe(now, rec)
+	}
+	return nil
+}
+
+func (t timedReadings) observe(now time.Time, rec *api.ReadingRecord) {
+	if rec.Reading.Synthetic || rec.Reading.Timestamp.IsZero() {
+		return
+	}
+	t.hist.Observe(now.Sub(rec.Reading.Timestamp))
+}
@@ -0,0 +1,137 @@
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	for _, d := range []time.Duration{5 * time.Millisecond, 40 * time.Millisecond, 300 * time.Millisecond, 2 * time.Minute} {
+		h.Observe(d)
+	}
+	h.Fail()
+
+	snap := h.Snapshot()
+	if snap.Count != 4 || snap.Failures != 1 {
+		t.Errorf("Expected 4 observations and 1 failure, got %+v", snap)
+	}
+	if snap.Max != 2*time.Minute {
+		t.Errorf("Expected max 2m, got %v", snap.Max)
+	}
+	if snap.Buckets[0].Count != 1 || snap.Buckets[len(snap.Buckets)-1].Count != 1 {
+		t.Errorf("Expected the first and overflow buckets counted, got %+v", snap.Buckets)
+	}
+	if got := snap.Quantile(0.5); got != 50*time.Millisecond {
+		t.Errorf("Expected p50 of 50ms, got %v", got)
+	}
+	if got := snap.Quantile(0.99); got != 2*time.Minute {
+		t.Errorf("Expected p99 to report the max, got %v", got)
+	}
+
+	var none *Histogram
+	none.Observe(time.Second)
+	none.Fail()
+}
+
+func TestRecorder_Report(t *testing.T) {
+	r := NewRecorder()
+	readings := r.Histogram(ReadingStored)
+	for i := 0; i < 99; i++ {
+		readings.Observe(100 * time.Millisecond)
+	}
+	readings.Observe(6 * time.Second)
+	commands := r.Histogram(CommandConfirmed)
+	commands.Observe(time.Second)
+	commands.Fail()
+
+	report := r.Report(DefaultBudget, DefaultObjective)
+	if report.Met {
+		t.Error("Expected the report to fail with a failed command")
+	}
+	if len(report.Metrics) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(report.Metrics))
+	}
+	byName := map[string]MetricReport{}
+	for _, m := range report.Metrics {
+		byName[m.Name] = m
+	}
+	if m := byName[ReadingStored]; !m.Met || m.WithinBudget != 0.99 {
+		t.Errorf("Expected readings to meet the objective at 0.99, got %+v", m)
+	}
+	if m := byName[CommandConfirmed]; m.Met || m.WithinBudget != 0.5 {
+		t.Errorf("Expected commands to miss the objective at 0.5, got %+v", m)
+	}
+
+	if report = r.Report(10*time.Second, 0.5); !report.Met {
+		t.Errorf("Expected a looser objective to be met, got %+v", report)
+	}
+}
+
+func TestRecorder_ServeReport(t *testing.T) {
+	r := NewRecorder()
+	r.Histogram(ReadingStored).Observe(3 * time.Second)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+		met   bool
+	}{
+		{"", http.StatusOK, true},
+		{"?budget=2s", http.StatusOK, false},
+		{"?budget=soon", http.StatusBadRequest, false},
+		{"?objective=1.5", http.StatusBadRequest, false},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeReport(rr, httptest.NewRequest("GET", "/debug/slo"+tc.query, nil))
+		if rr.Code != tc.code {
+			t.Errorf("Expected status %d for %q, got %d", tc.code, tc.query, rr.Code)
+			continue
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var report Report
+		if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+			t.Fatalf("Failed to decode report: %v", err)
+		}
+		if report.Met != tc.met {
+			t.Errorf("Expected met %v for %q, got %+v", tc.met, tc.query, report)
+		}
+	}
+}
+
+type discardReadings struct{}
+
+func (discardReadings) StoreSensorReading(context.Context, *api.ReadingRecord) error { return nil }
+
+func (discardReadings) StoreSensorReadings(context.Context, []*api.ReadingRecord) error {
+	return nil
+}
+
+func TestReadings(t *testing.T) {
+	h := newHistogram()
+	store := Readings(discardReadings{}, h)
+	taken := time.Now().Add(-2 * time.Second)
+	recs := []*api.ReadingRecord{
+		{DeviceID: "tank", SensorID: "temp", Reading: api.SensorReading{Value: 25, Valid: true, Timestamp: taken}},
+		{DeviceID: "tank", SensorID: "temp", Reading: api.SensorReading{Value: 99, Valid: true, Timestamp: taken, Synthetic: true}},
+	}
+	if err := store.StoreSensorReadings(context.Background(), recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+
+	snap := h.Snapshot()
+	if snap.Count != 1 {
+		t.Fatalf("Expected only the measured reading timed, got %d", snap.Count)
+	}
+	if snap.Max < 2*time.Second || snap.Max > 3*time.Second {
+		t.Errorf("Expected about 2s from reading to storage, got %v", snap.Max)
+	}
+}
@@ -0,0 +1,216 @@
+// Package lease coordinates redundant workers through leases in the shared store, so
+// work that must happen once happens on exactly one worker, with failover when it dies
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/supervise"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultTTL is how long a lease lasts without renewal, and so roughly how long a dead
+// owner's work goes undone before another worker takes over
+const DefaultTTL = 15 * time.Second
+
+// Store holds leases; storer.Interface satisfies it
+type Store interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// Role is work which only the lease owner may do, started when the lease is won and
+// stopped when it is lost
+type Role interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+type Option func(*Elector)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(e *Elector) {
+		e.log = logger
+	}
+}
+
+// WithTTL sets how long the lease lasts without renewal. The elector renews it every
+// third of the TTL.
+func WithTTL(ttl time.Duration) Option {
+	return func(e *Elector) {
+		e.ttl = ttl
+	}
+}
+
+// Elector campaigns for a named lease and runs a role while it holds it
+type Elector struct {
+	store  Store
+	name   string
+	holder string
+	role   Role
+	ttl    time.Duration
+	log    zerolog.Logger
+
+	mu      sync.Mutex
+	leading bool
+	// renewed is when the lease was last acquired or renewed
+	renewed time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewElector(store Store, name, holder string, role Role, opts ...Option) *Elector {
+	e := &Elector{
+		store:  store,
+		name:   name,
+		holder: holder,
+		role:   role,
+		ttl:    DefaultTTL,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Name returns the name of the lease the elector campaigns for
+func (e *Elector) Name() string {
+	return e.name
+}
+
+func (e *Elector) logCtx(ctx context.Context, sub string) zerolog.Logger {
+	return logCtx(ctx, e.log, e.name, sub)
+}
+
+func logCtx(ctx context.Context, fallback zerolog.Logger, name, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = fallback.With()
+	}
+	ll = ll.Str("component", "lease").Str("lease", name)
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "lease")
+}
+
+// Start campaigns in the background until Stop
+func (e *Elector) Start(ctx context.Context) error {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			// A panic costs one campaign step rather than the campaign
+			supervise.Call(ctx, "lease."+e.name, func() error {
+				e.campaign(ctx)
+				return nil
+			})
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the campaign, stopping the role and releasing the lease if this worker
+// leads, so another worker can take over without waiting for it to expire
+func (e *Elector) Stop(ctx context.Context) error {
+	close(e.stop)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !e.Leading() {
+		return nil
+	}
+	err := e.stepDown(ctx)
+	if releaseErr := e.store.ReleaseLease(ctx, e.name, e.holder); releaseErr != nil && !errors.Is(releaseErr, storer.ErrNotFound) {
+		err = errors.Join(err, releaseErr)
+	}
+	return err
+}
+
+// Leading reports whether this worker holds the lease and runs the role
+func (e *Elector) Leading() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading
+}
+
+// campaign acquires or renews the lease and starts or stops the role to match
+func (e *Elector) campaign(ctx context.Context) {
+	ll := e.logCtx(ctx, "campaign")
+	_, err := e.store.AcquireLease(ctx, e.name, e.holder, e.ttl)
+	leading := e.Leading()
+	switch {
+	case err == nil && !leading:
+		e.mu.Lock()
+		e.renewed = time.Now()
+		e.mu.Unlock()
+		ll.Info().Str("holder", e.holder).Msg("Won lease; starting role")
+		if err := e.role.Start(ctx); err != nil {
+			// Undo any partial start and give up the lease so a healthier worker can
+			// take over
+			ll.Error().Err(err).Msg("failed to start role; releasing lease")
+			if err := e.role.Stop(ctx); err != nil {
+				ll.Warn().Err(err).Msg("failed to stop partly started role")
+			}
+			if err := e.store.ReleaseLease(ctx, e.name, e.holder); err != nil {
+				ll.Warn().Err(err).Msg("failed to release lease")
+			}
+			return
+		}
+		e.mu.Lock()
+		e.leading = true
+		e.mu.Unlock()
+	case err == nil:
+		e.mu.Lock()
+		e.renewed = time.Now()
+		e.mu.Unlock()
+	case errors.Is(err, storer.ErrLeaseHeld):
+		if leading {
+			ll.Warn().Msg("Lost lease to another worker; stopping role")
+			if err := e.stepDown(ctx); err != nil {
+				ll.Error().Err(err).Msg("failed to stop role")
+			}
+		}
+	default:
+		ll.Warn().Err(err).Msg("failed to renew lease")
+		// Without the store this worker can't tell whether another has taken over; once
+		// the lease could have expired, assume one has
+		e.mu.Lock()
+		expired := leading && time.Since(e.renewed) >= e.ttl
+		e.mu.Unlock()
+		if expired {
+			ll.Warn().Msg("Lease expired without renewal; stopping role")
+			if err := e.stepDown(ctx); err != nil {
+				ll.Error().Err(err).Msg("failed to stop role")
+			}
+		}
+	}
+}
+
+func (e *Elector) stepDown(ctx context.Context) error {
+	e.mu.Lock()
+	e.leading = false
+	e.mu.Unlock()
+	return e.role.Stop(ctx)
+}
@@ -0,0 +1,121 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+type fakeRole struct {
+	mu      sync.Mutex
+	running bool
+}
+
+func (f *fakeRole) Start(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = true
+	return nil
+}
+
+func (f *fakeRole) Stop(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = false
+	return nil
+}
+
+func (f *fakeRole) Running() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.running
+}
+
+// flakyStore fails every call while down, as if the database were unreachable
+type flakyStore struct {
+	Store
+	down atomic.Bool
+}
+
+func (f *flakyStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error) {
+	if f.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return f.Store.AcquireLease(ctx, name, holder, ttl)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestElector(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	ttl := 60 * time.Millisecond
+
+	role1, role2 := &fakeRole{}, &fakeRole{}
+	e1 := NewElector(store, "driver/shelly", "worker-1", role1, WithTTL(ttl))
+	e1.Start(ctx)
+	waitFor(t, "worker-1 to lead", e1.Leading)
+
+	e2 := NewElector(store, "driver/shelly", "worker-2", role2, WithTTL(ttl))
+	e2.Start(ctx)
+	time.Sleep(2 * ttl)
+	if e2.Leading() || role2.Running() {
+		t.Fatal("Expected worker-2 to stand by while worker-1 renews the lease")
+	}
+	if !role1.Running() {
+		t.Fatal("Expected worker-1 to run the role")
+	}
+
+	// A clean shutdown hands over at once
+	if err := e1.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if role1.Running() {
+		t.Error("Expected worker-1 to stop the role on shutdown")
+	}
+	waitFor(t, "worker-2 to take over", e2.Leading)
+	if !role2.Running() {
+		t.Error("Expected worker-2 to run the role")
+	}
+	if err := e2.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+}
+
+func TestElectorFailover(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	ttl := 60 * time.Millisecond
+
+	// worker-1 loses its database connection; it must stop once the lease may have
+	// expired, and worker-2 must take over
+	cut := &flakyStore{Store: store}
+	role1, role2 := &fakeRole{}, &fakeRole{}
+	e1 := NewElector(cut, "driver/shelly", "worker-1", role1, WithTTL(ttl))
+	e1.Start(ctx)
+	defer e1.Stop(ctx)
+	waitFor(t, "worker-1 to lead", e1.Leading)
+
+	e2 := NewElector(store, "driver/shelly", "worker-2", role2, WithTTL(ttl))
+	e2.Start(ctx)
+	defer e2.Stop(ctx)
+
+	cut.down.Store(true)
+	waitFor(t, "worker-1 to step down", func() bool { return !role1.Running() })
+	waitFor(t, "worker-2 to take over", e2.Leading)
+}
@@ -0,0 +1,162 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/storer"
+	"lifesupport/backend/pkg/supervise"
+
+	"github.com/rs/zerolog"
+)
+
+type LockerOption func(*Locker)
+
+func WithLockerLogger(logger zerolog.Logger) LockerOption {
+	return func(l *Locker) {
+		l.log = logger
+	}
+}
+
+// WithLockTTL sets how long a lock outlives a worker which stops renewing it
+func WithLockTTL(ttl time.Duration) LockerOption {
+	return func(l *Locker) {
+		l.ttl = ttl
+	}
+}
+
+// Locker hands out named locks, so a control loop or scheduled job runs on only one
+// worker at a time. Locks are leases renewed while held, and are taken over by another
+// worker once their holder stops renewing them.
+type Locker struct {
+	store  Store
+	holder string
+	ttl    time.Duration
+	log    zerolog.Logger
+}
+
+func NewLocker(store Store, holder string, opts ...LockerOption) *Locker {
+	l := &Locker{
+		store:  store,
+		holder: holder,
+		ttl:    DefaultTTL,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Do runs fn while holding lock name, renewing it every third of the TTL. It returns
+// storer.ErrLeaseHeld without running fn while another worker holds the lock. fn's
+// context is cancelled if the lock is lost, or can't be renewed before it expires.
+func (l *Locker) Do(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	if _, err := l.store.AcquireLease(ctx, name, l.holder, l.ttl); err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ll := logCtx(ctx, l.log, name, "locker")
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			_, err := l.store.AcquireLease(ctx, name, l.holder, l.ttl)
+			switch {
+			case err == nil:
+				last = time.Now()
+			case errors.Is(err, storer.ErrLeaseHeld):
+				ll.Warn().Msg("Lost lock to another worker; cancelling")
+				cancel(err)
+				return
+			case time.Since(last) >= l.ttl:
+				ll.Warn().Err(err).Msg("Lock expired without renewal; cancelling")
+				cancel(fmt.Errorf("lock %s expired: %w", name, err))
+				return
+			default:
+				ll.Warn().Err(err).Msg("failed to renew lock")
+			}
+		}
+	}()
+
+	err := fn(fnCtx)
+	close(stop)
+	<-renewed
+	if context.Cause(fnCtx) != nil && ctx.Err() == nil {
+		err = errors.Join(err, context.Cause(fnCtx))
+	}
+	if releaseErr := l.store.ReleaseLease(context.WithoutCancel(ctx), name, l.holder); releaseErr != nil && !errors.Is(releaseErr, storer.ErrNotFound) {
+		err = errors.Join(err, fmt.Errorf("failed to release lock: %w", releaseErr))
+	}
+	return err
+}
+
+// Loop returns an elector which runs fn every interval, starting at once, on whichever
+// worker holds lock name. Unlike Do, the lock is kept between runs so the loop keeps its
+// pace and state on one worker; a standby takes over when the holder stops renewing.
+func (l *Locker) Loop(name string, interval time.Duration, fn func(ctx context.Context, now time.Time) error) *Elector {
+	role := &loop{name: name, interval: interval, fn: fn, log: l.log}
+	return NewElector(l.store, name, l.holder, role, WithTTL(l.ttl), WithLogger(l.log))
+}
+
+// loop is the role of a locked control loop
+type loop struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context, now time.Time) error
+	log      zerolog.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (l *loop) Start(ctx context.Context) error {
+	// The loop outlives the campaign step which starts it
+	ctx, l.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ll := logCtx(ctx, l.log, l.name, "loop")
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+		now := time.Now()
+		for {
+			// A panic costs one run rather than the loop
+			err := supervise.Call(ctx, "lease."+l.name, func() error { return l.fn(ctx, now) })
+			if err != nil && ctx.Err() == nil {
+				ll.Error().Err(err).Msg("control loop failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func (l *loop) Stop(ctx context.Context) error {
+	if l.cancel == nil {
+		return nil
+	}
+	l.cancel()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
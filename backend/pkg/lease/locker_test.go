@@ -0,0 +1,96 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/storer"
+)
+
+func TestLocker_Do(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	l1 := NewLocker(store, "worker-1", WithLockTTL(60*time.Millisecond))
+	l2 := NewLocker(store, "worker-2", WithLockTTL(60*time.Millisecond))
+
+	ran := false
+	err := l1.Do(ctx, "schedule/water-change", func(ctx context.Context) error {
+		// Held past several renewals
+		time.Sleep(100 * time.Millisecond)
+		err := l2.Do(ctx, "schedule/water-change", func(context.Context) error {
+			t.Error("Expected worker-2 not to run while worker-1 holds the lock")
+			return nil
+		})
+		if !errors.Is(err, storer.ErrLeaseHeld) {
+			t.Errorf("Expected ErrLeaseHeld for worker-2, got %v", err)
+		}
+		ran = true
+		return nil
+	})
+	if err != nil || !ran {
+		t.Fatalf("Do() error = %v, ran = %v", err, ran)
+	}
+
+	// Released on return, so worker-2 needn't wait for expiry
+	if err := l2.Do(ctx, "schedule/water-change", func(context.Context) error { return nil }); err != nil {
+		t.Errorf("Expected worker-2 to get the released lock, got %v", err)
+	}
+}
+
+func TestLocker_DoCancelledOnLoss(t *testing.T) {
+	store := storer.NewMemory()
+	cut := &flakyStore{Store: store}
+	ttl := 60 * time.Millisecond
+	l := NewLocker(cut, "worker-1", WithLockTTL(ttl))
+
+	err := l.Do(context.Background(), "loop/pump_rotation/main", func(ctx context.Context) error {
+		cut.down.Store(true)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+			t.Error("Expected the context to be cancelled once the lock expired")
+			return nil
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled run, got %v", err)
+	}
+}
+
+func TestLocker_Loop(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	ttl := 60 * time.Millisecond
+
+	var runs1, runs2 atomic.Int32
+	loop1 := NewLocker(store, "worker-1", WithLockTTL(ttl)).Loop("loop/pump_rotation/main", 10*time.Millisecond, func(context.Context, time.Time) error {
+		runs1.Add(1)
+		return nil
+	})
+	loop1.Start(ctx)
+	waitFor(t, "worker-1 to run the loop", func() bool { return runs1.Load() > 0 })
+
+	loop2 := NewLocker(store, "worker-2", WithLockTTL(ttl)).Loop("loop/pump_rotation/main", 10*time.Millisecond, func(context.Context, time.Time) error {
+		runs2.Add(1)
+		return nil
+	})
+	loop2.Start(ctx)
+	defer loop2.Stop(ctx)
+	time.Sleep(2 * ttl)
+	if runs2.Load() != 0 {
+		t.Fatalf("Expected only worker-1 to run the loop, worker-2 ran %d times", runs2.Load())
+	}
+
+	if err := loop1.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	stopped := runs1.Load()
+	waitFor(t, "worker-2 to take over the loop", func() bool { return runs2.Load() > 0 })
+	if runs1.Load() != stopped {
+		t.Error("Expected worker-1's loop to stop")
+	}
+}
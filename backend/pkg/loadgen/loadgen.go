@@ -0,0 +1,337 @@
+// Package loadgen simulates a fleet of devices pushing readings to the HTTP API while
+// dashboards query it, reporting throughput and latency
+package loadgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/compact"
+)
+
+// Config describes the simulated load
+type Config struct {
+	BaseURL          string
+	Devices          int
+	SensorsPerDevice int
+	// Interval is how often each device publishes a batch of all its sensors
+	Interval time.Duration
+	Duration time.Duration
+	// Compact sends batches in the CBOR compact form instead of JSON
+	Compact bool
+	// Readers is the number of simulated dashboards repeatedly querying readings
+	Readers      int
+	ReadInterval time.Duration
+	DevicePrefix string
+	Cleanup      bool
+}
+
+// Stats summarises one kind of request
+type Stats struct {
+	Requests   int           `json:"requests"`
+	Errors     int           `json:"errors"`
+	Throughput float64       `json:"throughput_per_sec"`
+	P50        time.Duration `json:"p50"`
+	P95        time.Duration `json:"p95"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+}
+
+// Report is the result of a load run
+type Report struct {
+	Duration time.Duration `json:"duration"`
+	Readings int           `json:"readings"`
+	Ingest   Stats         `json:"ingest"`
+	Query    Stats         `json:"query"`
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "duration: %s, readings ingested: %d (%.1f/s)\n", r.Duration.Round(time.Millisecond), r.Readings,
+		float64(r.Readings)/r.Duration.Seconds())
+	for _, s := range []struct {
+		name string
+		*Stats
+	}{{"ingest", &r.Ingest}, {"query", &r.Query}} {
+		fmt.Fprintf(&b, "%-6s requests=%d errors=%d rate=%.1f/s p50=%s p95=%s p99=%s max=%s\n",
+			s.name, s.Requests, s.Errors, s.Throughput, s.P50, s.P95, s.P99, s.Max)
+	}
+	return b.String()
+}
+
+type recorder struct {
+	lock      sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func (r *recorder) record(d time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err != nil {
+		r.errors++
+		return
+	}
+	r.latencies = append(r.latencies, d)
+}
+
+func (r *recorder) stats(elapsed time.Duration) Stats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	s := Stats{Requests: len(r.latencies) + r.errors, Errors: r.errors}
+	if elapsed > 0 {
+		s.Throughput = float64(s.Requests) / elapsed.Seconds()
+	}
+	if len(r.latencies) == 0 {
+		return s
+	}
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	pct := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	s.P50, s.P95, s.P99, s.Max = pct(0.50), pct(0.95), pct(0.99), sorted[len(sorted)-1]
+	return s
+}
+
+// Runner executes a load test against the API
+type Runner struct {
+	cfg    Config
+	client *http.Client
+}
+
+func New(cfg Config, client *http.Client) *Runner {
+	if cfg.DevicePrefix == "" {
+		cfg.DevicePrefix = "loadgen"
+	}
+	if cfg.SensorsPerDevice <= 0 {
+		cfg.SensorsPerDevice = 1
+	}
+	if cfg.ReadInterval <= 0 {
+		cfg.ReadInterval = time.Second
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &Runner{cfg: cfg, client: client}
+}
+
+func (r *Runner) deviceID(i int) string {
+	return fmt.Sprintf("%s-%04d", r.cfg.DevicePrefix, i)
+}
+
+// Setup registers the simulated devices and their sensors. Devices which already exist
+// from a previous run are reused.
+func (r *Runner) Setup(ctx context.Context) error {
+	for i := 0; i < r.cfg.Devices; i++ {
+		dev := api.Device{
+			ID:     r.deviceID(i),
+			Driver: "loadgen",
+			Name:   r.deviceID(i),
+		}
+		for s := 0; s < r.cfg.SensorsPerDevice; s++ {
+			dev.Sensors = append(dev.Sensors, &api.Sensor{
+				ID:         fmt.Sprintf("sensor-%d", s),
+				Name:       fmt.Sprintf("Sensor %d", s),
+				SensorType: api.SensorTypeTemperature,
+			})
+		}
+		body, _ := json.Marshal(dev)
+		status, err := r.do(ctx, http.MethodPost, "/api/devices", "application/json", body)
+		if err != nil {
+			return fmt.Errorf("failed to create device %s: %w", dev.ID, err)
+		}
+		if status == http.StatusCreated {
+			continue
+		}
+		if existing, err := r.do(ctx, http.MethodGet, "/api/devices/"+dev.ID, "", nil); err != nil || existing != http.StatusOK {
+			return fmt.Errorf("failed to create device %s: status %d", dev.ID, status)
+		}
+	}
+	return nil
+}
+
+// Teardown deletes the simulated devices and with them their readings
+func (r *Runner) Teardown(ctx context.Context) error {
+	for i := 0; i < r.cfg.Devices; i++ {
+		if _, err := r.do(ctx, http.MethodDelete, "/api/devices/"+r.deviceID(i), "", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run generates load for the configured duration or until ctx is done
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	if err := r.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := r.Setup(ctx); err != nil {
+		return nil, err
+	}
+	if r.cfg.Cleanup {
+		defer r.Teardown(context.WithoutCancel(ctx))
+	}
+
+	// The deadline only stops new requests; those in flight finish so the report counts
+	// every batch the server ingested.
+	runCtx, cancel := context.WithTimeout(ctx, r.cfg.Duration)
+	defer cancel()
+
+	var ingest, query recorder
+	var readings int
+	var readingsLock sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := 0; i < r.cfg.Devices; i++ {
+		wg.Add(1)
+		go func(device int) {
+			defer wg.Done()
+			// Spread devices across the interval rather than publishing in lockstep.
+			if !sleep(runCtx, time.Duration(rand.Int64N(int64(r.cfg.Interval)+1))) {
+				return
+			}
+			ticker := time.NewTicker(r.cfg.Interval)
+			defer ticker.Stop()
+			for {
+				n, d, err := r.publish(ctx, device)
+				if ctx.Err() != nil {
+					return
+				}
+				ingest.record(d, err)
+				if err == nil {
+					readingsLock.Lock()
+					readings += n
+					readingsLock.Unlock()
+				}
+				select {
+				case <-ticker.C:
+				case <-runCtx.Done():
+					return
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < r.cfg.Readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sleep(runCtx, r.cfg.ReadInterval) {
+				device := r.deviceID(rand.IntN(r.cfg.Devices))
+				t0 := time.Now()
+				status, err := r.do(ctx, http.MethodGet, "/api/sensor-readings?limit=100&device_id="+url.QueryEscape(device), "", nil)
+				if ctx.Err() != nil {
+					return
+				}
+				if err == nil && status != http.StatusOK {
+					err = fmt.Errorf("status %d", status)
+				}
+				query.record(time.Since(t0), err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+	return &Report{
+		Duration: elapsed,
+		Readings: readings,
+		Ingest:   ingest.stats(elapsed),
+		Query:    query.stats(elapsed),
+	}, nil
+}
+
+// Validate checks the configuration is usable
+func (c Config) Validate() error {
+	switch {
+	case c.BaseURL == "":
+		return fmt.Errorf("base URL is required")
+	case c.Devices <= 0:
+		return fmt.Errorf("at least one device is required")
+	case c.Interval <= 0:
+		return fmt.Errorf("interval must be positive")
+	case c.Duration <= 0:
+		return fmt.Errorf("duration must be positive")
+	}
+	return nil
+}
+
+func (r *Runner) publish(ctx context.Context, device int) (int, time.Duration, error) {
+	now := time.Now()
+	batch := make([]*api.ReadingRecord, 0, r.cfg.SensorsPerDevice)
+	for s := 0; s < r.cfg.SensorsPerDevice; s++ {
+		batch = append(batch, &api.ReadingRecord{
+			DeviceID: r.deviceID(device),
+			SensorID: fmt.Sprintf("sensor-%d", s),
+			Reading: api.SensorReading{
+				Value:     24 + rand.Float64()*2,
+				Unit:      api.UnitCelsius,
+				Timestamp: now,
+				Valid:     true,
+			},
+		})
+	}
+
+	var body []byte
+	contentType := "application/json"
+	if r.cfg.Compact {
+		body = compact.Encode(batch)
+		contentType = compact.ContentType
+	} else {
+		body, _ = json.Marshal(batch)
+	}
+
+	t0 := time.Now()
+	status, err := r.do(ctx, http.MethodPost, "/api/sensor-readings", contentType, body)
+	d := time.Since(t0)
+	if err == nil && status != http.StatusCreated {
+		err = fmt.Errorf("status %d", status)
+	}
+	return len(batch), d, err
+}
+
+func (r *Runner) do(ctx context.Context, method, path, contentType string, body []byte) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.BaseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,90 @@
+package loadgen
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/compact"
+	"lifesupport/backend/pkg/ingest"
+)
+
+func TestRunner_Run(t *testing.T) {
+	var lock sync.Mutex
+	var created, ingested, deleted int
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/devices", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		created++
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("DELETE /api/devices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		deleted++
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/sensor-readings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != compact.ContentType {
+			t.Errorf("Expected compact content type, got %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		readings, err := ingest.DecodePayload(body)
+		if err != nil {
+			t.Errorf("Failed to decode batch: %v", err)
+		}
+		lock.Lock()
+		ingested += len(readings)
+		lock.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET /api/sensor-readings", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	runner := New(Config{
+		BaseURL:          srv.URL,
+		Devices:          3,
+		SensorsPerDevice: 4,
+		Interval:         20 * time.Millisecond,
+		Duration:         200 * time.Millisecond,
+		Compact:          true,
+		Readers:          1,
+		ReadInterval:     20 * time.Millisecond,
+		Cleanup:          true,
+	}, srv.Client())
+
+	report, err := runner.Run(t.Context())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if report.Ingest.Requests == 0 || report.Ingest.Errors != 0 {
+		t.Errorf("Unexpected ingest stats %+v", report.Ingest)
+	}
+	if report.Query.Requests == 0 || report.Query.Errors != 0 {
+		t.Errorf("Unexpected query stats %+v", report.Query)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if report.Readings != ingested || ingested%4 != 0 {
+		t.Errorf("Expected reported readings %d to match ingested %d in batches of 4", report.Readings, ingested)
+	}
+	if created != 3 || deleted != 3 {
+		t.Errorf("Expected 3 devices created and cleaned up, got %d and %d", created, deleted)
+	}
+	if report.Ingest.P50 > report.Ingest.Max {
+		t.Errorf("p50 %s exceeds max %s", report.Ingest.P50, report.Ingest.Max)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (Config{BaseURL: "http://x", Devices: 1, Interval: time.Second}).Validate(); err == nil {
+		t.Error("Expected error for missing duration")
+	}
+}
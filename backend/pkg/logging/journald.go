@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// JournaldSocket is where systemd-journald receives entries in its native protocol
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// Journald writes JSON log events to systemd-journald as structured entries: the
+// message becomes MESSAGE, the level PRIORITY, and every other field an upper-cased
+// journal field, so `journalctl COMPONENT=shelly` works.
+type Journald struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+// NewJournald connects to journald's socket, usually JournaldSocket. Entries are tagged
+// with tag as their SYSLOG_IDENTIFIER.
+func NewJournald(socket, tag string) (*Journald, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+	return &Journald{conn: conn, tag: tag}, nil
+}
+
+// Write sends an event without a level at the info priority
+func (j *Journald) Write(p []byte) (int, error) {
+	return j.WriteLevel(zerolog.NoLevel, p)
+}
+
+// WriteLevel sends one event as a journal entry
+func (j *Journald) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var entry bytes.Buffer
+	writeJournalField(&entry, "PRIORITY", fmt.Sprint(journaldPriority(level)))
+	if j.tag != "" {
+		writeJournalField(&entry, "SYSLOG_IDENTIFIER", j.tag)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(p, &fields); err != nil {
+		// Not a JSON event; journal it as is
+		writeJournalField(&entry, "MESSAGE", strings.TrimRight(string(p), "\n"))
+	} else {
+		msg, _ := fields[zerolog.MessageFieldName].(string)
+		writeJournalField(&entry, "MESSAGE", msg)
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			switch k {
+			case zerolog.MessageFieldName, zerolog.LevelFieldName, zerolog.TimestampFieldName:
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v, ok := fields[k].(string)
+			if !ok {
+				b, _ := json.Marshal(fields[k])
+				v = string(b)
+			}
+			writeJournalField(&entry, journalFieldName(k), v)
+		}
+	}
+
+	if _, err := j.conn.Write(entry.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to write to journald: %w", err)
+	}
+	return len(p), nil
+}
+
+// Close closes the connection to journald
+func (j *Journald) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField encodes a field in journald's native protocol. Values containing a
+// newline are length-prefixed instead of newline-terminated.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName makes a valid journal field name: upper case letters, digits and
+// underscores, not starting with an underscore, which is reserved for trusted fields
+func journalFieldName(k string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, k)
+	name = strings.TrimLeft(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	return name
+}
+
+// journaldPriority maps a level to a syslog priority
+func journaldPriority(level zerolog.Level) int {
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		return 7
+	case zerolog.WarnLevel:
+		return 4
+	case zerolog.ErrorLevel:
+		return 3
+	case zerolog.FatalLevel:
+		return 2
+	case zerolog.PanicLevel:
+		return 0
+	}
+	return 6
+}
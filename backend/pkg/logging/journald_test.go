@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestJournald(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer server.Close()
+
+	j, err := NewJournald(socket, "lifesupport")
+	if err != nil {
+		t.Fatalf("Failed to connect to journald: %v", err)
+	}
+	defer j.Close()
+
+	logger := zerolog.New(j)
+	logger.Warn().Str("component", "shelly").Str("device-id", "abc").Int("attempt", 2).Msg("device offline\nretrying")
+
+	buf := make([]byte, 4096)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+	entry := buf[:n]
+
+	for _, field := range []string{"PRIORITY=4\n", "SYSLOG_IDENTIFIER=lifesupport\n", "COMPONENT=shelly\n", "DEVICE_ID=abc\n", "ATTEMPT=2\n"} {
+		if !bytes.Contains(entry, []byte(field)) {
+			t.Errorf("Expected entry to contain %q, got %q", field, entry)
+		}
+	}
+	if bytes.Contains(entry, []byte("LEVEL")) {
+		t.Errorf("Expected the level to be sent only as PRIORITY, got %q", entry)
+	}
+
+	msg := "device offline\nretrying"
+	var want bytes.Buffer
+	want.WriteString("MESSAGE\n")
+	binary.Write(&want, binary.LittleEndian, uint64(len(msg)))
+	want.WriteString(msg + "\n")
+	if !bytes.Contains(entry, want.Bytes()) {
+		t.Errorf("Expected a length-prefixed multi-line MESSAGE, got %q", entry)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"component":   "COMPONENT",
+		"device-id":   "DEVICE_ID",
+		"_internal":   "INTERNAL",
+		"2fa":         "F_2FA",
+		"resource.id": "RESOURCE_ID",
+	}
+	for k, want := range tests {
+		if got := journalFieldName(k); got != want {
+			t.Errorf("Expected %q to become %q, got %q", k, want, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package logging sets log levels per component, so one driver or subsystem can log at
+// debug while everything else stays at the default level.
+//
+// Components tag their loggers with a "component" field in their logCtx helpers; those
+// helpers pass the logger through Component, which applies the component's level.
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	lock       sync.RWMutex
+	base       = zerolog.InfoLevel
+	components = map[string]zerolog.Level{}
+)
+
+// Component returns l at the level set for component, or l unchanged if the component
+// has no level of its own
+func Component(l zerolog.Logger, component string) zerolog.Logger {
+	lock.RLock()
+	level, ok := components[component]
+	lock.RUnlock()
+	if !ok {
+		return l
+	}
+	return l.Level(level)
+}
+
+// SetLevel sets the default level. The root logger must be created at this level; it is
+// the level of every component without one of its own.
+func SetLevel(level zerolog.Level) {
+	lock.Lock()
+	defer lock.Unlock()
+	base = level
+	updateGlobalLevel()
+}
+
+// Level returns the default level
+func Level() zerolog.Level {
+	lock.RLock()
+	defer lock.RUnlock()
+	return base
+}
+
+// SetComponentLevels replaces the level of every component. Components left out return
+// to the default level.
+func SetComponentLevels(levels map[string]zerolog.Level) {
+	lock.Lock()
+	defer lock.Unlock()
+	components = make(map[string]zerolog.Level, len(levels))
+	for component, level := range levels {
+		components[component] = level
+	}
+	updateGlobalLevel()
+}
+
+// ComponentLevels returns the components with a level of their own
+func ComponentLevels() map[string]zerolog.Level {
+	lock.RLock()
+	defer lock.RUnlock()
+	levels := make(map[string]zerolog.Level, len(components))
+	for component, level := range components {
+		levels[component] = level
+	}
+	return levels
+}
+
+// updateGlobalLevel lowers zerolog's global level to the most verbose level in use;
+// events below it are dropped before any logger's own level is consulted
+func updateGlobalLevel() {
+	global := base
+	for _, level := range components {
+		if level < global {
+			global = level
+		}
+	}
+	zerolog.SetGlobalLevel(global)
+}
+
+// ParseComponentLevels parses a comma separated list of component=level pairs, like
+// "storer=debug,shelly=info,httpapi=warn"
+func ParseComponentLevels(spec string) (map[string]zerolog.Level, error) {
+	levels := make(map[string]zerolog.Level)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, name, ok := strings.Cut(pair, "=")
+		if !ok || component == "" {
+			return nil, fmt.Errorf("invalid component level %q: expected component=level", pair)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level for %s: %w", component, err)
+		}
+		levels[strings.TrimSpace(component)] = level
+	}
+	return levels, nil
+}
+
+// FormatComponentLevels is the inverse of ParseComponentLevels
+func FormatComponentLevels(levels map[string]zerolog.Level) string {
+	pairs := make([]string, 0, len(levels))
+	for component, level := range levels {
+		pairs = append(pairs, component+"="+level.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseLevel parses a level name: trace, debug, info, warn (or warning), error or disabled
+func ParseLevel(name string) (zerolog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "trace":
+		return zerolog.TraceLevel, nil
+	case "debug":
+		return zerolog.DebugLevel, nil
+	case "info":
+		return zerolog.InfoLevel, nil
+	case "warn", "warning":
+		return zerolog.WarnLevel, nil
+	case "error":
+		return zerolog.ErrorLevel, nil
+	case "disabled", "off":
+		return zerolog.Disabled, nil
+	}
+	return zerolog.NoLevel, fmt.Errorf("unknown log level %q", name)
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels("storer=debug, shelly=info,httpapi=WARN,")
+	if err != nil {
+		t.Fatalf("Failed to parse component levels: %v", err)
+	}
+	want := map[string]zerolog.Level{"storer": zerolog.DebugLevel, "shelly": zerolog.InfoLevel, "httpapi": zerolog.WarnLevel}
+	if len(levels) != len(want) {
+		t.Fatalf("Expected %d levels, got %v", len(want), levels)
+	}
+	for component, level := range want {
+		if levels[component] != level {
+			t.Errorf("Expected %s=%s, got %s", component, level, levels[component])
+		}
+	}
+	if got := FormatComponentLevels(levels); got != "httpapi=warn,shelly=info,storer=debug" {
+		t.Errorf("Expected levels to format sorted, got %q", got)
+	}
+
+	for _, spec := range []string{"storer", "=debug", "storer=loud"} {
+		if _, err := ParseComponentLevels(spec); err == nil {
+			t.Errorf("Expected an error parsing %q", spec)
+		}
+	}
+}
+
+func TestComponent(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	defer SetComponentLevels(nil)
+	defer SetLevel(Level())
+
+	var buf bytes.Buffer
+	root := zerolog.New(&buf).Level(zerolog.InfoLevel)
+	SetLevel(zerolog.InfoLevel)
+	SetComponentLevels(map[string]zerolog.Level{"storer": zerolog.DebugLevel, "httpapi": zerolog.WarnLevel})
+
+	if zerolog.GlobalLevel() != zerolog.DebugLevel {
+		t.Errorf("Expected the global level lowered to debug, got %s", zerolog.GlobalLevel())
+	}
+
+	tests := []struct {
+		component string
+		level     zerolog.Level
+		logged    bool
+	}{
+		{"storer", zerolog.DebugLevel, true},
+		{"shelly", zerolog.DebugLevel, false},
+		{"shelly", zerolog.InfoLevel, true},
+		{"httpapi", zerolog.InfoLevel, false},
+		{"httpapi", zerolog.WarnLevel, true},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		ll := Component(root, tt.component)
+		ll.WithLevel(tt.level).Msg("hello")
+		if logged := buf.Len() > 0; logged != tt.logged {
+			t.Errorf("Expected %s at %s logged = %v, got %v", tt.component, tt.level, tt.logged, logged)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names rotated files; it sorts in time order
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is a log file which is renamed aside once it reaches a size limit, with
+// old files removed by age and count, so logs survive a reboot without filling the disk
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+// RotateOption configures a RotatingFile
+type RotateOption func(*RotatingFile)
+
+// WithMaxSize rotates the file once it would grow beyond bytes. Zero never rotates.
+func WithMaxSize(bytes int64) RotateOption {
+	return func(f *RotatingFile) {
+		f.maxSize = bytes
+	}
+}
+
+// WithMaxAge removes rotated files older than age. Zero keeps them regardless of age.
+func WithMaxAge(age time.Duration) RotateOption {
+	return func(f *RotatingFile) {
+		f.maxAge = age
+	}
+}
+
+// WithMaxBackups keeps at most n rotated files. Zero keeps them regardless of count.
+func WithMaxBackups(n int) RotateOption {
+	return func(f *RotatingFile) {
+		f.maxBackups = n
+	}
+}
+
+// OpenRotatingFile opens, or creates, the log file at path for appending
+func OpenRotatingFile(path string, opts ...RotateOption) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path: path,
+		now:  time.Now,
+	}
+	for _, o := range opts {
+		o(f)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if err := f.prune(); err != nil {
+		f.file.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its size limit
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the current file aside and starts a new one, as on reaching the size
+// limit
+func (f *RotatingFile) Rotate() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		f.file = nil
+	}
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + f.now().UTC().Format(backupTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// backups lists rotated files, oldest first
+func (f *RotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	matches, err := filepath.Glob(strings.TrimSuffix(f.path, ext) + "-*" + ext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated log files: %w", err)
+	}
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+	var backups []string
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (f *RotatingFile) prune() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	var remove []string
+	if f.maxBackups > 0 && len(backups) > f.maxBackups {
+		remove = backups[:len(backups)-f.maxBackups]
+		backups = backups[len(backups)-f.maxBackups:]
+	}
+	if f.maxAge > 0 {
+		cutoff := f.now().Add(-f.maxAge)
+		for _, b := range backups {
+			if info, err := os.Stat(b); err == nil && info.ModTime().Before(cutoff) {
+				remove = append(remove, b)
+			}
+		}
+	}
+	for _, b := range remove {
+		if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove rotated log file: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file; later writes fail
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
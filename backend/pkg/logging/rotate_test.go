@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lifesupport.log")
+	now := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	f, err := OpenRotatingFile(path, WithMaxSize(10), WithMaxBackups(2))
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer f.Close()
+	f.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if _, err := f.Write([]byte("line one\n")); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if string(data) != "line one\n" {
+		t.Errorf("Expected the current file to hold only the last line, got %q", data)
+	}
+	backups, err := f.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "lifesupport-20260301T020002.000.log"),
+		filepath.Join(dir, "lifesupport-20260301T020003.000.log"),
+	}
+	if len(backups) != len(want) || backups[0] != want[0] || backups[1] != want[1] {
+		t.Errorf("Expected the newest 2 backups %v, got %v", want, backups)
+	}
+}
+
+func TestRotatingFile_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lifesupport.log")
+	old := filepath.Join(dir, "lifesupport-20260101T000000.000.log")
+	unrelated := filepath.Join(dir, "lifesupport-notes.log")
+	for _, p := range []string{old, unrelated} {
+		if err := os.WriteFile(p, []byte("old\n"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", p, err)
+		}
+		stale := time.Now().Add(-48 * time.Hour)
+		os.Chtimes(p, stale, stale)
+	}
+
+	f, err := OpenRotatingFile(path, WithMaxAge(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale backup to be removed, got %v", err)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("Expected files which aren't backups to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+
+	"github.com/rs/zerolog"
+)
+
+// NewSyslog writes JSON log events to syslog, at the syslog severity matching each
+// event's level. An empty addr uses the local syslog daemon; otherwise addr is a URL
+// like udp://loghost:514 or tcp://loghost:514.
+func NewSyslog(addr, tag string) (zerolog.LevelWriter, error) {
+	const priority = syslog.LOG_INFO | syslog.LOG_DAEMON
+	var (
+		w   *syslog.Writer
+		err error
+	)
+	if addr == "" {
+		w, err = syslog.New(priority, tag)
+	} else {
+		u, perr := url.Parse(addr)
+		if perr != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address %q: expected udp://host:port or tcp://host:port", addr)
+		}
+		w, err = syslog.Dial(u.Scheme, u.Host, priority, tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return zerolog.SyslogLevelWriter(w), nil
+}
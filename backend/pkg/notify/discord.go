@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/rs/zerolog"
+)
+
+var discordSeverityColor = map[api.AlertSeverity]int{
+	api.AlertSeverityInfo:     0x3498db,
+	api.AlertSeverityWarning:  0xf1c40f,
+	api.AlertSeverityCritical: 0xe74c3c,
+}
+
+type DiscordOption func(*Discord)
+
+func WithDiscordHTTPClient(client *http.Client) DiscordOption {
+	return func(d *Discord) {
+		d.client = client
+	}
+}
+
+func WithDiscordLogger(logger zerolog.Logger) DiscordOption {
+	return func(d *Discord) {
+		d.log = logger
+	}
+}
+
+// WithDiscordUsername overrides the webhook's default display name
+func WithDiscordUsername(username string) DiscordOption {
+	return func(d *Discord) {
+		d.username = username
+	}
+}
+
+// Discord delivers alerts to a Discord channel webhook. Channel webhooks cannot carry
+// interactive components, so alerts sent here must be acknowledged elsewhere.
+type Discord struct {
+	webhookURL string
+	username   string
+	client     *http.Client
+	log        zerolog.Logger
+}
+
+// NewDiscord creates a Discord notifier posting to the given channel webhook URL
+func NewDiscord(webhookURL string, opts ...DiscordOption) *Discord {
+	d := &Discord{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordMessage struct {
+	Username string         `json:"username,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Embeds   []discordEmbed `json:"embeds"`
+}
+
+func (d *Discord) Notify(ctx context.Context, alert *api.Alert) error {
+	ll := logCtx(ctx, d.log, "discord")
+	ll.Debug().Str("alert_id", alert.ID).Str("severity", string(alert.Severity)).Msg("sending alert to discord")
+
+	if err := postJSON(ctx, d.client, d.webhookURL, d.buildMessage(alert)); err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	return nil
+}
+
+func (d *Discord) buildMessage(alert *api.Alert) *discordMessage {
+	embed := discordEmbed{
+		Title:       fmt.Sprintf("[%s] %s", alert.Severity, alert.Title),
+		Description: alert.Message,
+		Color:       discordSeverityColor[alert.Severity],
+	}
+	if !alert.Timestamp.IsZero() {
+		embed.Timestamp = alert.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if alert.Source != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "Source", Value: alert.Source, Inline: true})
+	}
+	if alert.ID != "" {
+		embed.Fields = append(embed.Fields, discordField{Name: "Alert ID", Value: alert.ID, Inline: true})
+	}
+	return &discordMessage{
+		Username: d.username,
+		Embeds:   []discordEmbed{embed},
+	}
+}
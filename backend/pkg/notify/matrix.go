@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type MatrixOption func(*Matrix)
+
+func WithMatrixHTTPClient(client *http.Client) MatrixOption {
+	return func(m *Matrix) {
+		m.client = client
+	}
+}
+
+func WithMatrixLogger(logger zerolog.Logger) MatrixOption {
+	return func(m *Matrix) {
+		m.log = logger
+	}
+}
+
+// Matrix posts alerts as messages in a Matrix room using the client-server API
+type Matrix struct {
+	homeserver  string
+	roomID      string
+	accessToken string
+	client      *http.Client
+	log         zerolog.Logger
+}
+
+// NewMatrix creates a notifier posting to roomID on homeserver as the user owning accessToken.
+// The user must already have joined the room.
+func NewMatrix(homeserver, roomID, accessToken string, opts ...MatrixOption) *Matrix {
+	m := &Matrix{
+		homeserver:  strings.TrimSuffix(homeserver, "/"),
+		roomID:      roomID,
+		accessToken: accessToken,
+		client:      &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+func (m *Matrix) Notify(ctx context.Context, alert *api.Alert) error {
+	ll := logCtx(ctx, m.log, "matrix")
+	ll.Debug().Str("alert_id", alert.ID).Str("severity", string(alert.Severity)).Msg("sending alert to matrix")
+
+	// Each send needs a client-generated transaction ID so retries are de-duplicated
+	// by the homeserver.
+	txnID := uuid.New().String()
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		m.homeserver, url.PathEscape(m.roomID), txnID)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.accessToken)
+
+	if err := sendJSON(ctx, m.client, http.MethodPut, endpoint, header, m.buildMessage(alert)); err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	return nil
+}
+
+func (m *Matrix) buildMessage(alert *api.Alert) *matrixMessage {
+	plain := fmt.Sprintf("[%s] %s\n%s", alert.Severity, alert.Title, alert.Message)
+	formatted := fmt.Sprintf("<strong>[%s] %s</strong><br/>%s",
+		html.EscapeString(string(alert.Severity)), html.EscapeString(alert.Title), html.EscapeString(alert.Message))
+	if alert.Source != "" {
+		plain += "\nSource: " + alert.Source
+		formatted += "<br/>Source: <code>" + html.EscapeString(alert.Source) + "</code>"
+	}
+	return &matrixMessage{
+		MsgType:       "m.text",
+		Body:          plain,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted,
+	}
+}
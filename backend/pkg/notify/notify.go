@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Notifier delivers alerts to a human-facing channel
+type Notifier interface {
+	Notify(ctx context.Context, alert *api.Alert) error
+}
+
+// AckFunc is called when a user acknowledges an alert from a notification channel
+type AckFunc func(ctx context.Context, ack *api.AlertAck) error
+
+// Multi fans an alert out to every wrapped notifier. Delivery is attempted on all
+// notifiers even if some fail; the returned error joins every failure.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, alert *api.Alert) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func logCtx(ctx context.Context, base zerolog.Logger, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = base.With()
+	}
+	ll = ll.Str("component", "notify")
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "notify")
+}
+
+// postJSON posts body as JSON to url and treats any non-2xx response as an error
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	return sendJSON(ctx, client, http.MethodPost, url, nil, body)
+}
+
+// sendJSON sends body as JSON with the given method and extra headers, treating any
+// non-2xx response as an error
+func sendJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	testmqtt "lifesupport/backend/pkg/testutil/mqtt"
+	"lifesupport/backend/pkg/transport"
+)
+
+func testAlert() *api.Alert {
+	return &api.Alert{
+		ID:        "42",
+		Severity:  api.AlertSeverityCritical,
+		Title:     "Sump temperature high",
+		Message:   "Temperature is 31.2°C",
+		Source:    "device.sump.sensor.temp",
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// captureServer records the last JSON body posted to it
+func captureServer(t *testing.T, status int) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected Content-Type application/json, got %s", ct)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode body: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &body
+}
+
+func TestSlack_Notify(t *testing.T) {
+	srv, body := captureServer(t, http.StatusOK)
+
+	s := NewSlack(srv.URL, WithSlackAckButton())
+	if err := s.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	b, _ := json.Marshal(*body)
+	if !strings.Contains(string(b), `"action_id":"ack_alert"`) {
+		t.Errorf("Expected ack button in payload, got %s", b)
+	}
+	if !strings.Contains(string(b), `"value":"42"`) {
+		t.Errorf("Expected alert ID as button value, got %s", b)
+	}
+	if text, _ := (*body)["text"].(string); !strings.Contains(text, "Sump temperature high") {
+		t.Errorf("Expected fallback text to contain title, got %q", text)
+	}
+}
+
+func TestSlack_NotifyErrorStatus(t *testing.T) {
+	srv, _ := captureServer(t, http.StatusForbidden)
+
+	s := NewSlack(srv.URL)
+	if err := s.Notify(context.Background(), testAlert()); err == nil {
+		t.Fatal("Expected error for non-2xx response, got nil")
+	}
+}
+
+func TestDiscord_Notify(t *testing.T) {
+	srv, body := captureServer(t, http.StatusNoContent)
+
+	d := NewDiscord(srv.URL, WithDiscordUsername("lifesupport"))
+	if err := d.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if (*body)["username"] != "lifesupport" {
+		t.Errorf("Expected username lifesupport, got %v", (*body)["username"])
+	}
+	embeds, _ := (*body)["embeds"].([]any)
+	if len(embeds) != 1 {
+		t.Fatalf("Expected 1 embed, got %d", len(embeds))
+	}
+	embed := embeds[0].(map[string]any)
+	if embed["color"] != float64(discordSeverityColor[api.AlertSeverityCritical]) {
+		t.Errorf("Expected critical color, got %v", embed["color"])
+	}
+	if embed["timestamp"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("Expected timestamp 2024-01-02T03:04:05Z, got %v", embed["timestamp"])
+	}
+}
+
+type fakeNotifier struct {
+	err   error
+	calls int
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, alert *api.Alert) error {
+	f.calls++
+	return f.err
+}
+
+func TestMulti_Notify(t *testing.T) {
+	failErr := errors.New("boom")
+	a := &fakeNotifier{err: failErr}
+	b := &fakeNotifier{}
+
+	err := Multi{a, b}.Notify(context.Background(), testAlert())
+	if !errors.Is(err, failErr) {
+		t.Errorf("Expected error %v, got %v", failErr, err)
+	}
+	if a.calls != 1 || b.calls != 1 {
+		t.Errorf("Expected every notifier to be called once, got %d and %d", a.calls, b.calls)
+	}
+}
+
+func TestTransport_Notify(t *testing.T) {
+	broker := testmqtt.NewBroker(t)
+	got := make(chan api.Alert, 1)
+	broker.Subscribe("lifesupport/alerts/#", func(topic string, payload []byte) {
+		var alert api.Alert
+		json.Unmarshal(payload, &alert)
+		if topic != "lifesupport/alerts/critical" {
+			t.Errorf("Expected topic lifesupport/alerts/critical, got %s", topic)
+		}
+		got <- alert
+	})
+
+	n := NewTransport(transport.NewMQTT(broker.Client(t, "notify-test")), "lifesupport/alerts")
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	select {
+	case alert := <-got:
+		if alert.ID != testAlert().ID || alert.Title != testAlert().Title {
+			t.Errorf("Expected the alert as JSON, got %+v", alert)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for alert")
+	}
+}
+
+func signSlack(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:%s", ts, body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSlack_InteractionHandler(t *testing.T) {
+	var responseBody map[string]any
+	responseSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &responseBody)
+	}))
+	defer responseSrv.Close()
+
+	payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U1","username":"cody"},"actions":[{"action_id":"ack_alert","value":"42"}],"response_url":%q}`, responseSrv.URL)
+	body := url.Values{"payload": {payload}}.Encode()
+
+	var got *api.AlertAck
+	s := NewSlack("http://unused")
+	handler := s.InteractionHandler("secret", func(ctx context.Context, ack *api.AlertAck) error {
+		got = ack
+		return nil
+	})
+
+	ts := time.Now().Unix()
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Slack-Signature", signSlack("secret", ts, body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got == nil {
+		t.Fatal("Expected ack callback to be called")
+	}
+	if got.AlertID != "42" || got.User != "cody" || got.Channel != "slack" {
+		t.Errorf("Unexpected ack: %+v", got)
+	}
+	if responseBody["replace_original"] != true {
+		t.Errorf("Expected original message to be replaced, got %v", responseBody)
+	}
+}
+
+func TestSlack_InteractionHandlerBadSignature(t *testing.T) {
+	s := NewSlack("http://unused")
+	handler := s.InteractionHandler("secret", func(ctx context.Context, ack *api.AlertAck) error {
+		t.Error("ack callback should not be called")
+		return nil
+	})
+
+	body := url.Values{"payload": {`{"actions":[{"action_id":"ack_alert","value":"42"}]}`}}.Encode()
+	ts := time.Now().Unix()
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Slack-Signature", signSlack("wrong-secret", ts, body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", rec.Code)
+	}
+}
+
+func TestVerifySlackSignature_Stale(t *testing.T) {
+	ts := time.Now().Add(-10 * time.Minute).Unix()
+	header := http.Header{}
+	header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	header.Set("X-Slack-Signature", signSlack("secret", ts, "body"))
+
+	if err := verifySlackSignature("secret", header, []byte("body"), time.Now()); err == nil {
+		t.Error("Expected error for stale timestamp, got nil")
+	}
+}
+
+func TestNtfy_Notify(t *testing.T) {
+	var gotPath, gotBody string
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotPath, gotBody, gotHeader = r.URL.Path, string(b), r.Header
+	}))
+	defer srv.Close()
+
+	n := NewNtfy("aquarium", WithNtfyServer(srv.URL+"/"), WithNtfyToken("tk_123"))
+	if err := n.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if gotPath != "/aquarium" {
+		t.Errorf("Expected path /aquarium, got %s", gotPath)
+	}
+	if gotHeader.Get("Priority") != "5" {
+		t.Errorf("Expected priority 5 for critical alert, got %s", gotHeader.Get("Priority"))
+	}
+	if gotHeader.Get("Title") != "Sump temperature high" {
+		t.Errorf("Expected title header, got %s", gotHeader.Get("Title"))
+	}
+	if gotHeader.Get("Authorization") != "Bearer tk_123" {
+		t.Errorf("Expected bearer token, got %s", gotHeader.Get("Authorization"))
+	}
+	if !strings.HasPrefix(gotBody, "Temperature is 31.2°C") {
+		t.Errorf("Expected message body, got %q", gotBody)
+	}
+}
+
+func TestMatrix_Notify(t *testing.T) {
+	var gotMethod, gotPath, gotAuth string
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth = r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"event_id":"$abc"}`))
+	}))
+	defer srv.Close()
+
+	m := NewMatrix(srv.URL, "!room:example.org", "syt_token")
+	if err := m.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if gotMethod != http.MethodPut {
+		t.Errorf("Expected PUT, got %s", gotMethod)
+	}
+	if !strings.HasPrefix(gotPath, "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/") {
+		t.Errorf("Unexpected path %s", gotPath)
+	}
+	if gotAuth != "Bearer syt_token" {
+		t.Errorf("Expected bearer token, got %s", gotAuth)
+	}
+	if body["msgtype"] != "m.text" {
+		t.Errorf("Expected msgtype m.text, got %v", body["msgtype"])
+	}
+}
+
+func TestSeverityRouter_Notify(t *testing.T) {
+	all := &fakeNotifier{}
+	criticalOnly := &fakeNotifier{}
+	router := NewSeverityRouter().
+		Route(api.AlertSeverityInfo, all).
+		Route(api.AlertSeverityCritical, criticalOnly)
+
+	warning := testAlert()
+	warning.Severity = api.AlertSeverityWarning
+	if err := router.Notify(context.Background(), warning); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if err := router.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if all.calls != 2 {
+		t.Errorf("Expected info route to receive 2 alerts, got %d", all.calls)
+	}
+	if criticalOnly.calls != 1 {
+		t.Errorf("Expected critical route to receive 1 alert, got %d", criticalOnly.calls)
+	}
+}
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/rs/zerolog"
+)
+
+const defaultNtfyServer = "https://ntfy.sh"
+
+// ntfy priorities range from 1 (min) to 5 (max/urgent)
+var ntfySeverityPriority = map[api.AlertSeverity]string{
+	api.AlertSeverityInfo:     "3",
+	api.AlertSeverityWarning:  "4",
+	api.AlertSeverityCritical: "5",
+}
+
+var ntfySeverityTags = map[api.AlertSeverity]string{
+	api.AlertSeverityInfo:     "information_source",
+	api.AlertSeverityWarning:  "warning",
+	api.AlertSeverityCritical: "rotating_light",
+}
+
+type NtfyOption func(*Ntfy)
+
+func WithNtfyHTTPClient(client *http.Client) NtfyOption {
+	return func(n *Ntfy) {
+		n.client = client
+	}
+}
+
+func WithNtfyLogger(logger zerolog.Logger) NtfyOption {
+	return func(n *Ntfy) {
+		n.log = logger
+	}
+}
+
+// WithNtfyServer points the notifier at a self-hosted ntfy server instead of ntfy.sh
+func WithNtfyServer(server string) NtfyOption {
+	return func(n *Ntfy) {
+		n.server = strings.TrimSuffix(server, "/")
+	}
+}
+
+// WithNtfyToken authenticates publishes with an ntfy access token
+func WithNtfyToken(token string) NtfyOption {
+	return func(n *Ntfy) {
+		n.token = token
+	}
+}
+
+// Ntfy publishes alerts to an ntfy topic
+type Ntfy struct {
+	server string
+	topic  string
+	token  string
+	client *http.Client
+	log    zerolog.Logger
+}
+
+// NewNtfy creates a notifier publishing to topic
+func NewNtfy(topic string, opts ...NtfyOption) *Ntfy {
+	n := &Ntfy{
+		server: defaultNtfyServer,
+		topic:  topic,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+func (n *Ntfy) Notify(ctx context.Context, alert *api.Alert) error {
+	ll := logCtx(ctx, n.log, "ntfy")
+	ll.Debug().Str("alert_id", alert.ID).Str("severity", string(alert.Severity)).Msg("sending alert to ntfy")
+
+	body := alert.Message
+	if alert.Source != "" {
+		body += "\nSource: " + alert.Source
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.server+"/"+n.topic, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return fmt.Errorf("ntfy: failed to build request: %w", err)
+	}
+	req.Header.Set("Title", alert.Title)
+	if p, ok := ntfySeverityPriority[alert.Severity]; ok {
+		req.Header.Set("Priority", p)
+	}
+	if tag, ok := ntfySeverityTags[alert.Severity]; ok {
+		req.Header.Set("Tags", tag)
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy: failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ntfy: unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
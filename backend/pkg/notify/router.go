@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"lifesupport/backend/pkg/api"
+)
+
+var severityRank = map[api.AlertSeverity]int{
+	api.AlertSeverityInfo:     1,
+	api.AlertSeverityWarning:  2,
+	api.AlertSeverityCritical: 3,
+}
+
+type severityRoute struct {
+	min      api.AlertSeverity
+	notifier Notifier
+}
+
+// SeverityRouter delivers each alert to the notifiers whose minimum severity the alert
+// meets, e.g. critical alerts to ntfy and everything to Matrix.
+type SeverityRouter struct {
+	routes []severityRoute
+}
+
+func NewSeverityRouter() *SeverityRouter {
+	return &SeverityRouter{}
+}
+
+// Route sends alerts of severity min or higher to n
+func (r *SeverityRouter) Route(min api.AlertSeverity, n Notifier) *SeverityRouter {
+	r.routes = append(r.routes, severityRoute{min: min, notifier: n})
+	return r
+}
+
+func (r *SeverityRouter) Notify(ctx context.Context, alert *api.Alert) error {
+	var errs []error
+	for _, route := range r.routes {
+		if severityRank[alert.Severity] < severityRank[route.min] {
+			continue
+		}
+		if err := route.notifier.Notify(ctx, alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
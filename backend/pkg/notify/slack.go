@@ -0,0 +1,241 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	slackAckActionID       = "ack_alert"
+	slackMaxRequestAge     = 5 * time.Minute
+	slackMaxInteractionLen = 1 << 20
+)
+
+var slackSeverityEmoji = map[api.AlertSeverity]string{
+	api.AlertSeverityInfo:     ":information_source:",
+	api.AlertSeverityWarning:  ":warning:",
+	api.AlertSeverityCritical: ":rotating_light:",
+}
+
+type SlackOption func(*Slack)
+
+func WithSlackHTTPClient(client *http.Client) SlackOption {
+	return func(s *Slack) {
+		s.client = client
+	}
+}
+
+func WithSlackLogger(logger zerolog.Logger) SlackOption {
+	return func(s *Slack) {
+		s.log = logger
+	}
+}
+
+// WithSlackAckButton adds an "Acknowledge" button to every alert message. Clicks are
+// delivered to the app's interactivity URL, which should be served by InteractionHandler.
+func WithSlackAckButton() SlackOption {
+	return func(s *Slack) {
+		s.ackButton = true
+	}
+}
+
+// Slack delivers alerts to a Slack incoming webhook
+type Slack struct {
+	webhookURL string
+	ackButton  bool
+	client     *http.Client
+	log        zerolog.Logger
+}
+
+// NewSlack creates a Slack notifier posting to the given incoming webhook URL
+func NewSlack(webhookURL string, opts ...SlackOption) *Slack {
+	s := &Slack{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackElement struct {
+	Type     string     `json:"type"`
+	Text     *slackText `json:"text,omitempty"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	Style    string     `json:"style,omitempty"`
+}
+
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+type slackMessage struct {
+	Text            string       `json:"text"`
+	Blocks          []slackBlock `json:"blocks,omitempty"`
+	ReplaceOriginal bool         `json:"replace_original,omitempty"`
+}
+
+func (s *Slack) Notify(ctx context.Context, alert *api.Alert) error {
+	ll := logCtx(ctx, s.log, "slack")
+	ll.Debug().Str("alert_id", alert.ID).Str("severity", string(alert.Severity)).Msg("sending alert to slack")
+
+	if err := postJSON(ctx, s.client, s.webhookURL, s.buildMessage(alert)); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	return nil
+}
+
+func (s *Slack) buildMessage(alert *api.Alert) *slackMessage {
+	summary := fmt.Sprintf("%s *%s*", slackSeverityEmoji[alert.Severity], alert.Title)
+	msg := &slackMessage{
+		Text: fmt.Sprintf("[%s] %s: %s", alert.Severity, alert.Title, alert.Message),
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: summary + "\n" + alert.Message}},
+		},
+	}
+	if alert.Source != "" {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackElement{{Type: "mrkdwn", Text: &slackText{Type: "mrkdwn", Text: "Source: `" + alert.Source + "`"}}},
+		})
+	}
+	if s.ackButton && alert.ID != "" {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type: "actions",
+			Elements: []slackElement{{
+				Type:     "button",
+				Text:     &slackText{Type: "plain_text", Text: "Acknowledge"},
+				ActionID: slackAckActionID,
+				Value:    alert.ID,
+				Style:    "primary",
+			}},
+		})
+	}
+	return msg
+}
+
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// InteractionHandler returns an http.Handler for Slack's interactivity callbacks. Requests
+// are authenticated with the app's signing secret; acknowledge button clicks are passed
+// to ack and the original message is replaced with an acknowledgement notice.
+func (s *Slack) InteractionHandler(signingSecret string, ack AckFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		ll := logCtx(ctx, s.log, "slack")
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, slackMaxInteractionLen))
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := verifySlackSignature(signingSecret, r.Header, body, time.Now()); err != nil {
+			ll.Warn().Err(err).Msg("rejecting slack interaction")
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var interaction slackInteraction
+		if err := json.Unmarshal([]byte(form.Get("payload")), &interaction); err != nil {
+			http.Error(w, "Invalid payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, action := range interaction.Actions {
+			if action.ActionID != slackAckActionID {
+				continue
+			}
+			user := interaction.User.Username
+			if user == "" {
+				user = interaction.User.ID
+			}
+			alertAck := &api.AlertAck{
+				AlertID:   action.Value,
+				User:      user,
+				Channel:   "slack",
+				Timestamp: time.Now(),
+			}
+			if err := ack(ctx, alertAck); err != nil {
+				ll.Err(err).Str("alert_id", action.Value).Msg("acknowledging alert")
+				http.Error(w, "Failed to acknowledge alert: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ll.Info().Str("alert_id", action.Value).Str("user", user).Msg("alert acknowledged from slack")
+
+			if interaction.ResponseURL != "" {
+				reply := &slackMessage{
+					Text:            fmt.Sprintf(":white_check_mark: Alert %s acknowledged by %s", action.Value, user),
+					ReplaceOriginal: true,
+				}
+				if err := postJSON(ctx, s.client, interaction.ResponseURL, reply); err != nil {
+					ll.Warn().Err(err).Msg("updating slack message after acknowledgement")
+				}
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// verifySlackSignature checks the X-Slack-Signature header as described in
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	tsHeader := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request timestamp %q", tsHeader)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return fmt.Errorf("request timestamp too far from current time: %s", age)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", tsHeader)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultTelegramAPIURL = "https://api.telegram.org"
+	telegramPollTimeout   = 30 * time.Second
+	telegramRetryDelay    = 5 * time.Second
+)
+
+var telegramSeverityPrefix = map[api.AlertSeverity]string{
+	api.AlertSeverityInfo:     "ℹ️",
+	api.AlertSeverityWarning:  "⚠️",
+	api.AlertSeverityCritical: "🚨",
+}
+
+// TelegramUser identifies who sent a bot command
+type TelegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// CommandFunc handles a bot command. args holds the whitespace separated words following
+// the command. The returned string is sent back to the chat the command came from.
+type CommandFunc func(ctx context.Context, from TelegramUser, args []string) (string, error)
+
+// AuthorizeFunc decides whether a user may run a command from a chat
+type AuthorizeFunc func(ctx context.Context, chatID int64, from TelegramUser, command string) bool
+
+// Commander sends actuator commands; drivers.Dispatcher satisfies it
+type Commander interface {
+	Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error)
+}
+
+type TelegramOption func(*Telegram)
+
+func WithTelegramHTTPClient(client *http.Client) TelegramOption {
+	return func(t *Telegram) {
+		t.client = client
+	}
+}
+
+func WithTelegramLogger(logger zerolog.Logger) TelegramOption {
+	return func(t *Telegram) {
+		t.log = logger
+	}
+}
+
+// WithTelegramAPIURL overrides the Bot API base URL, e.g. for a self-hosted Bot API server
+func WithTelegramAPIURL(apiURL string) TelegramOption {
+	return func(t *Telegram) {
+		t.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// WithTelegramAuthorizer sets the check applied to every command from a chat the bot
+// answers in. Without one, anyone in those chats may run every command.
+func WithTelegramAuthorizer(authorize AuthorizeFunc) TelegramOption {
+	return func(t *Telegram) {
+		t.authorize = authorize
+	}
+}
+
+// WithTelegramChats adds chats the bot answers commands in, besides the chat alerts are
+// delivered to. Messages from any other chat are ignored without a reply.
+func WithTelegramChats(chatIDs ...int64) TelegramOption {
+	return func(t *Telegram) {
+		for _, id := range chatIDs {
+			t.chats[id] = true
+		}
+	}
+}
+
+// Telegram delivers alerts to a Telegram chat and, when Run is called, answers bot
+// commands registered with Handle.
+type Telegram struct {
+	token     string
+	chatID    int64
+	chats     map[int64]bool // the chats commands are answered in
+	apiURL    string
+	authorize AuthorizeFunc
+	client    *http.Client
+	log       zerolog.Logger
+
+	lock     sync.RWMutex
+	commands map[string]CommandFunc
+}
+
+// NewTelegram creates a Telegram notifier for the bot token, sending alerts to chatID
+func NewTelegram(token string, chatID int64, opts ...TelegramOption) *Telegram {
+	t := &Telegram{
+		token:    token,
+		chatID:   chatID,
+		chats:    map[int64]bool{chatID: true},
+		apiURL:   defaultTelegramAPIURL,
+		client:   &http.Client{Timeout: telegramPollTimeout + defaultTimeout},
+		commands: make(map[string]CommandFunc),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Handle registers fn for a command such as "/status"
+func (t *Telegram) Handle(command string, fn CommandFunc) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.commands[strings.TrimPrefix(command, "/")] = fn
+}
+
+func (t *Telegram) Notify(ctx context.Context, alert *api.Alert) error {
+	ll := logCtx(ctx, t.log, "telegram")
+	ll.Debug().Str("alert_id", alert.ID).Str("severity", string(alert.Severity)).Msg("sending alert to telegram")
+
+	text := fmt.Sprintf("%s %s\n%s", telegramSeverityPrefix[alert.Severity], alert.Title, alert.Message)
+	if alert.Source != "" {
+		text += "\nSource: " + alert.Source
+	}
+	if alert.ID != "" {
+		text += "\nReply /ack " + alert.ID + " to acknowledge"
+	}
+	if err := t.sendMessage(ctx, t.chatID, text); err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	return nil
+}
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Text string       `json:"text"`
+		From TelegramUser `json:"from"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+func (t *Telegram) sendMessage(ctx context.Context, chatID int64, text string) error {
+	return t.call(ctx, "sendMessage", map[string]any{"chat_id": chatID, "text": text}, nil)
+}
+
+func (t *Telegram) call(ctx context.Context, method string, params any, result any) error {
+	var resp telegramResponse
+	if err := t.postBotAPI(ctx, method, params, &resp); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s: %s", method, resp.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+func (t *Telegram) postBotAPI(ctx context.Context, method string, params any, resp *telegramResponse) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL+"/bot"+t.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := t.client.Do(req)
+	if err != nil {
+		// The request URL embeds the bot token; don't leak it into logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if err := json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+		return fmt.Errorf("failed to decode response (status %d): %w", httpResp.StatusCode, err)
+	}
+	return nil
+}
+
+// Run long-polls the Bot API for commands until ctx is cancelled
+func (t *Telegram) Run(ctx context.Context) error {
+	ll := logCtx(ctx, t.log, "telegram")
+	ll.Info().Msg("Starting Telegram bot command polling")
+
+	var offset int64
+	for {
+		var updates []telegramUpdate
+		err := t.call(ctx, "getUpdates", map[string]any{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ll.Warn().Err(err).Msg("polling telegram for updates")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(telegramRetryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil {
+				continue
+			}
+			t.dispatch(ctx, u.Message.Chat.ID, u.Message.From, u.Message.Text)
+		}
+	}
+}
+
+func (t *Telegram) dispatch(ctx context.Context, chatID int64, from TelegramUser, text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return
+	}
+	// Commands in group chats may be addressed as /status@my_bot
+	command, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+
+	ll := logCtx(ctx, t.log, "telegram").With().
+		Str("command", command).
+		Int64("chat_id", chatID).
+		Int64("user_id", from.ID).
+		Logger()
+
+	if !t.chats[chatID] {
+		// Anyone can add the bot to a chat; answering there would reveal it
+		ll.Debug().Msg("ignoring telegram command from an unknown chat")
+		return
+	}
+
+	t.lock.RLock()
+	fn, ok := t.commands[command]
+	t.lock.RUnlock()
+
+	var reply string
+	switch {
+	case !ok:
+		reply = "Unknown command /" + command
+	case !t.authorized(ctx, chatID, from, command):
+		ll.Warn().Msg("rejecting unauthorized telegram command")
+		reply = "You are not allowed to run /" + command
+	default:
+		ll.Info().Strs("args", fields[1:]).Msg("running telegram command")
+		var err error
+		reply, err = fn(ctx, from, fields[1:])
+		if err != nil {
+			ll.Err(err).Msg("telegram command failed")
+			reply = "Error: " + err.Error()
+		}
+	}
+
+	if reply == "" {
+		return
+	}
+	if err := t.sendMessage(ctx, chatID, reply); err != nil {
+		ll.Err(err).Msg("replying to telegram command")
+	}
+}
+
+func (t *Telegram) authorized(ctx context.Context, chatID int64, from TelegramUser, command string) bool {
+	if t.authorize != nil {
+		return t.authorize(ctx, chatID, from, command)
+	}
+	return true
+}
+
+// RoleAuthorizer allows commands by the role of the user sending them, in whichever of
+// the bot's chats: a user in users may run each command in required whose role their
+// own allows. Commands missing from required are refused.
+func RoleAuthorizer(users map[int64]api.Role, required map[string]api.Role) AuthorizeFunc {
+	return func(ctx context.Context, chatID int64, from TelegramUser, command string) bool {
+		need, ok := required[command]
+		return ok && users[from.ID].Allows(need)
+	}
+}
+
+// userName is who a command is attributed to: the sender's username, or their ID
+func (u TelegramUser) userName() string {
+	if u.Username != "" {
+		return u.Username
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
+
+// AckCommand adapts an AckFunc into a "/ack <alert id>" command handler
+func AckCommand(ack AckFunc) CommandFunc {
+	return func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+		if len(args) != 1 {
+			return "Usage: /ack <alert id>", nil
+		}
+		user := from.userName()
+		err := ack(ctx, &api.AlertAck{
+			AlertID:   args[0],
+			User:      user,
+			Channel:   "telegram",
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			return "", err
+		}
+		return "Alert " + args[0] + " acknowledged", nil
+	}
+}
+
+// StatusCommand adapts a health score source into a "/status" command handler, replying
+// with the overall score and each subsystem's
+func StatusCommand(score func(ctx context.Context) (*api.HealthScore, error)) CommandFunc {
+	return func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+		health, err := score(ctx)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		if health.Score == nil {
+			b.WriteString("Health: no sensors with targets")
+		} else {
+			fmt.Fprintf(&b, "Health: %.0f/100", *health.Score)
+		}
+		for _, sub := range health.Subsystems {
+			fmt.Fprintf(&b, "\n%s: %.0f (%d sensors)", sub.Subsystem, sub.Score, sub.Sensors)
+		}
+		return b.String(), nil
+	}
+}
+
+// SwitchCommand returns a "/<name> on|off" command handler switching every actuator in
+// tags, such as the lights. Commands are attributed to the sender.
+func SwitchCommand(name string, commander Commander, tags []string) CommandFunc {
+	return func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			return "Usage: /" + name + " on|off", nil
+		}
+		ctx = api.WithCommandOrigin(ctx, api.CommandOrigin{Source: api.CommandSourceUser, Name: "telegram/" + from.userName()})
+		var errs []error
+		for _, tag := range tags {
+			if _, err := commander.Command(ctx, tag, api.ActuatorCommand{Action: args[0]}); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", tag, err))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Switched %d %s %s", len(tags), name, args[0]), nil
+	}
+}
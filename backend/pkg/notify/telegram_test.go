@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// fakeBotAPI records sendMessage calls made against a fake Telegram Bot API
+type fakeBotAPI struct {
+	mu   sync.Mutex
+	sent []map[string]any
+}
+
+func (f *fakeBotAPI) server(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/bottoken/") {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var params map[string]any
+		json.NewDecoder(r.Body).Decode(&params)
+		if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			f.mu.Lock()
+			f.sent = append(f.sent, params)
+			f.mu.Unlock()
+		}
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeBotAPI) lastText() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) == 0 {
+		return ""
+	}
+	text, _ := f.sent[len(f.sent)-1]["text"].(string)
+	return text
+}
+
+func TestTelegram_Notify(t *testing.T) {
+	fake := &fakeBotAPI{}
+	srv := fake.server(t)
+
+	tg := NewTelegram("token", 1234, WithTelegramAPIURL(srv.URL))
+	if err := tg.Notify(context.Background(), testAlert()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if len(fake.sent) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(fake.sent))
+	}
+	if fake.sent[0]["chat_id"] != float64(1234) {
+		t.Errorf("Expected chat_id 1234, got %v", fake.sent[0]["chat_id"])
+	}
+	if text := fake.lastText(); !strings.Contains(text, "/ack 42") {
+		t.Errorf("Expected ack hint in message, got %q", text)
+	}
+}
+
+func TestTelegram_NotifyAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+	}))
+	defer srv.Close()
+
+	tg := NewTelegram("token", 1234, WithTelegramAPIURL(srv.URL))
+	err := tg.Notify(context.Background(), testAlert())
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("Expected chat not found error, got %v", err)
+	}
+}
+
+func TestTelegram_DispatchAck(t *testing.T) {
+	fake := &fakeBotAPI{}
+	srv := fake.server(t)
+
+	var got *api.AlertAck
+	tg := NewTelegram("token", 1234, WithTelegramAPIURL(srv.URL))
+	tg.Handle("/ack", AckCommand(func(ctx context.Context, ack *api.AlertAck) error {
+		got = ack
+		return nil
+	}))
+
+	tg.dispatch(context.Background(), 1234, TelegramUser{ID: 7, Username: "cody"}, "/ack@lifesupport_bot 42")
+
+	if got == nil {
+		t.Fatal("Expected ack callback to be called")
+	}
+	if got.AlertID != "42" || got.User != "cody" || got.Channel != "telegram" {
+		t.Errorf("Unexpected ack: %+v", got)
+	}
+	if text := fake.lastText(); text != "Alert 42 acknowledged" {
+		t.Errorf("Expected acknowledgement reply, got %q", text)
+	}
+}
+
+func TestTelegram_DispatchUnknownChat(t *testing.T) {
+	fake := &fakeBotAPI{}
+	srv := fake.server(t)
+
+	tg := NewTelegram("token", 1234, WithTelegramAPIURL(srv.URL))
+	tg.Handle("/status", func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+		t.Error("command should not run for an unknown chat")
+		return "", nil
+	})
+
+	tg.dispatch(context.Background(), 9999, TelegramUser{ID: 7}, "/status")
+	tg.dispatch(context.Background(), 9999, TelegramUser{ID: 7}, "/nope")
+
+	if text := fake.lastText(); text != "" {
+		t.Errorf("Expected no reply to an unknown chat, got %q", text)
+	}
+}
+
+func TestTelegram_DispatchAuthorizer(t *testing.T) {
+	fake := &fakeBotAPI{}
+	srv := fake.server(t)
+
+	tg := NewTelegram("token", 1234,
+		WithTelegramAPIURL(srv.URL),
+		WithTelegramChats(9999),
+		WithTelegramAuthorizer(func(ctx context.Context, chatID int64, from TelegramUser, command string) bool {
+			return from.ID == 7 && command == "lights"
+		}),
+	)
+	var gotArgs []string
+	tg.Handle("lights", func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+		gotArgs = args
+		return "ok", nil
+	})
+
+	tg.dispatch(context.Background(), 9999, TelegramUser{ID: 7}, "/lights off")
+
+	if len(gotArgs) != 1 || gotArgs[0] != "off" {
+		t.Errorf("Expected args [off], got %v", gotArgs)
+	}
+}
+
+func TestTelegram_RoleAuthorizer(t *testing.T) {
+	fake := &fakeBotAPI{}
+	srv := fake.server(t)
+
+	tg := NewTelegram("token", 1234,
+		WithTelegramAPIURL(srv.URL),
+		WithTelegramChats(5678),
+		WithTelegramAuthorizer(RoleAuthorizer(
+			map[int64]api.Role{7: api.RoleOperator, 8: api.RoleViewer},
+			map[string]api.Role{"status": api.RoleViewer, "lights": api.RoleOperator},
+		)),
+	)
+	var ran []string
+	for _, command := range []string{"status", "lights"} {
+		tg.Handle(command, func(ctx context.Context, from TelegramUser, args []string) (string, error) {
+			ran = append(ran, command)
+			return "ok", nil
+		})
+	}
+
+	// Roles follow the sender, not the chat: a viewer in the operator's chat is still a
+	// viewer
+	tg.dispatch(context.Background(), 1234, TelegramUser{ID: 8}, "/status")
+	tg.dispatch(context.Background(), 1234, TelegramUser{ID: 8}, "/lights off")
+	if text := fake.lastText(); !strings.Contains(text, "not allowed") {
+		t.Errorf("Expected a viewer to be refused /lights, got %q", text)
+	}
+	tg.dispatch(context.Background(), 5678, TelegramUser{ID: 7}, "/lights off")
+	tg.dispatch(context.Background(), 1234, TelegramUser{ID: 9}, "/status")
+	if text := fake.lastText(); !strings.Contains(text, "not allowed") {
+		t.Errorf("Expected a user without a role to be refused, got %q", text)
+	}
+
+	if strings.Join(ran, ",") != "status,lights" {
+		t.Errorf("Expected the viewer's /status and the operator's /lights to run, got %v", ran)
+	}
+}
+
+func TestStatusCommand(t *testing.T) {
+	score := 87.5
+	status := StatusCommand(func(ctx context.Context) (*api.HealthScore, error) {
+		return &api.HealthScore{Score: &score, Subsystems: []api.SubsystemHealth{{Subsystem: "sump", Score: 75, Sensors: 2}}}, nil
+	})
+	reply, err := status(context.Background(), TelegramUser{ID: 7}, nil)
+	if err != nil {
+		t.Fatalf("status() error = %v", err)
+	}
+	if reply != "Health: 88/100\nsump: 75 (2 sensors)" {
+		t.Errorf("Unexpected status reply %q", reply)
+	}
+}
+
+// fakeCommander records the commands sent to each tag
+type fakeCommander struct {
+	sent []string
+}
+
+func (f *fakeCommander) Command(ctx context.Context, tag string, cmd api.ActuatorCommand) (*api.ActuatorState, error) {
+	origin := api.CommandOriginFrom(ctx)
+	f.sent = append(f.sent, tag+"="+cmd.Action+"@"+origin.Name)
+	return &api.ActuatorState{}, nil
+}
+
+func TestSwitchCommand(t *testing.T) {
+	commander := &fakeCommander{}
+	lights := SwitchCommand("lights", commander, []string{"light.main", "light.refugium"})
+
+	reply, err := lights(context.Background(), TelegramUser{ID: 7, Username: "cody"}, []string{"off"})
+	if err != nil {
+		t.Fatalf("lights() error = %v", err)
+	}
+	if reply != "Switched 2 lights off" {
+		t.Errorf("Unexpected reply %q", reply)
+	}
+	if strings.Join(commander.sent, ",") != "light.main=off@telegram/cody,light.refugium=off@telegram/cody" {
+		t.Errorf("Unexpected commands %v", commander.sent)
+	}
+
+	if reply, _ := lights(context.Background(), TelegramUser{ID: 7}, []string{"dim"}); !strings.HasPrefix(reply, "Usage") {
+		t.Errorf("Expected usage for an unknown action, got %q", reply)
+	}
+}
@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/transport"
+)
+
+// Transport publishes alerts as JSON on the internal transport, on the subject prefix
+// followed by the severity, e.g. lifesupport.alerts.critical, for other services to act
+// on. On JetStream alerts are kept, so a consumer that was down still sees them.
+type Transport struct {
+	transport transport.Transport
+	subject   string
+}
+
+func NewTransport(t transport.Transport, subject string) *Transport {
+	return &Transport{transport: t, subject: subject}
+}
+
+func (t *Transport) Notify(ctx context.Context, alert *api.Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	subject := t.transport.Subject(t.subject, string(alert.Severity))
+	if err := t.transport.Publish(ctx, subject, payload); err != nil {
+		return fmt.Errorf("failed to publish alert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Package presence announces workers on MQTT and tracks which of them are alive.
+//
+// Each worker keeps a retained summary on <prefix>/<id>: "online" while it runs,
+// refreshed every heartbeat, and "offline" when it stops. The same summary marked
+// offline is registered as the connection's last will, so the broker publishes it if
+// the worker dies without saying goodbye.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DefaultPrefix is the topic prefix under which workers publish their presence
+	DefaultPrefix = "lifesupport/workers"
+	// DefaultInterval is how often workers refresh their presence
+	DefaultInterval = 30 * time.Second
+	// missedHeartbeats is how many heartbeats an online worker may miss before it is
+	// reported unresponsive
+	missedHeartbeats = 3
+)
+
+// Topic returns the retained presence topic of worker id
+func Topic(prefix, id string) string {
+	return prefix + "/" + id
+}
+
+// RedactBroker strips any credentials from a broker URL, so it can be published
+func RedactBroker(broker string) string {
+	u, err := url.Parse(broker)
+	if err != nil || u.User == nil {
+		return broker
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Will registers presence, marked offline, as the last will of a client built from
+// opts. It must be called before connecting.
+func Will(opts *mqtt.ClientOptions, prefix string, presence api.WorkerPresence) error {
+	presence.Status = api.WorkerOffline
+	presence.UpdatedAt = time.Now()
+	payload, err := json.Marshal(presence)
+	if err != nil {
+		return fmt.Errorf("failed to encode last will: %w", err)
+	}
+	opts.SetBinaryWill(Topic(prefix, presence.ID), payload, 1, true)
+	return nil
+}
+
+type Option func(*Publisher)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(p *Publisher) {
+		p.log = logger
+	}
+}
+
+// WithInterval sets how often the worker refreshes its presence
+func WithInterval(interval time.Duration) Option {
+	return func(p *Publisher) {
+		p.interval = interval
+	}
+}
+
+// Publisher keeps a worker's presence up to date
+type Publisher struct {
+	client   mqtt.Client
+	prefix   string
+	presence api.WorkerPresence
+	interval time.Duration
+	log      zerolog.Logger
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewPublisher(client mqtt.Client, prefix string, presence api.WorkerPresence, opts ...Option) *Publisher {
+	p := &Publisher{
+		client:   client,
+		prefix:   prefix,
+		presence: presence,
+		interval: DefaultInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.presence.HeartbeatSeconds = int(p.interval.Round(time.Second) / time.Second)
+	if p.presence.StartedAt.IsZero() {
+		p.presence.StartedAt = time.Now()
+	}
+	return p
+}
+
+func logCtx(ctx context.Context, fallback zerolog.Logger, sub string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = fallback.With()
+	}
+	ll = ll.Str("component", "presence")
+	if sub != "" {
+		ll = ll.Str("subcomponent", sub)
+	}
+	return logging.Component(ll.Logger(), "presence")
+}
+
+// Start announces the worker online and refreshes its presence every interval until
+// Stop. Refreshing also restores the online status after the broker has published the
+// last will for a dropped connection.
+func (p *Publisher) Start(ctx context.Context) error {
+	ll := logCtx(ctx, p.log, "publisher")
+	if err := p.publish(ctx, api.WorkerOnline); err != nil {
+		return err
+	}
+	ll.Info().Str("topic", Topic(p.prefix, p.presence.ID)).Dur("interval", p.interval).Msg("Announced worker presence")
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if err := p.publish(ctx, api.WorkerOnline); err != nil {
+					ll.Warn().Err(err).Msg("failed to refresh worker presence")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the heartbeat and announces the worker offline
+func (p *Publisher) Stop(ctx context.Context) error {
+	close(p.stop)
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.publish(ctx, api.WorkerOffline)
+}
+
+func (p *Publisher) publish(ctx context.Context, status api.WorkerStatus) error {
+	presence := p.presence
+	presence.Status = status
+	presence.UpdatedAt = time.Now()
+	payload, err := json.Marshal(presence)
+	if err != nil {
+		return fmt.Errorf("failed to encode presence: %w", err)
+	}
+	t := p.client.Publish(Topic(p.prefix, presence.ID), 1, true, payload)
+	select {
+	case <-t.Done():
+		if err := t.Error(); err != nil {
+			return fmt.Errorf("failed to publish presence: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/supervise"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+)
+
+type TrackerOption func(*Tracker)
+
+func WithTrackerLogger(logger zerolog.Logger) TrackerOption {
+	return func(t *Tracker) {
+		t.log = logger
+	}
+}
+
+// Tracker follows the presence of every worker publishing under a prefix
+type Tracker struct {
+	client mqtt.Client
+	prefix string
+	log    zerolog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	workers map[string]*seenWorker
+}
+
+// seenWorker is a worker's last presence, and when it arrived by the tracker's clock so
+// workers with skewed clocks are judged fairly
+type seenWorker struct {
+	presence api.WorkerPresence
+	received time.Time
+}
+
+func NewTracker(client mqtt.Client, prefix string, opts ...TrackerOption) *Tracker {
+	t := &Tracker{
+		client:  client,
+		prefix:  prefix,
+		now:     time.Now,
+		workers: make(map[string]*seenWorker),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Start subscribes to presence updates; the broker replays every retained summary
+func (t *Tracker) Start(ctx context.Context) error {
+	ll := logCtx(ctx, t.log, "tracker")
+	ll.Info().Str("topic", Topic(t.prefix, "+")).Msg("Tracking worker presence")
+	tok := t.client.Subscribe(Topic(t.prefix, "+"), 1, supervise.MQTTHandler("presence.tracker", t.handleMessage))
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracker) Stop(ctx context.Context) error {
+	tok := t.client.Unsubscribe(Topic(t.prefix, "+"))
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Workers returns every known worker ordered by ID. Online workers which have missed
+// several heartbeats are reported unresponsive.
+func (t *Tracker) Workers() []api.WorkerPresence {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	workers := make([]api.WorkerPresence, 0, len(t.workers))
+	for _, w := range t.workers {
+		presence := w.presence
+		presence.Drivers = append([]api.DriverName(nil), presence.Drivers...)
+		interval := time.Duration(presence.HeartbeatSeconds) * time.Second
+		if interval <= 0 {
+			interval = DefaultInterval
+		}
+		if presence.Status == api.WorkerOnline && now.Sub(w.received) > missedHeartbeats*interval {
+			presence.Status = api.WorkerUnresponsive
+		}
+		workers = append(workers, presence)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers
+}
+
+// Forget clears a decommissioned worker's retained presence. A worker which is still
+// running reappears at its next heartbeat.
+func (t *Tracker) Forget(ctx context.Context, id string) error {
+	tok := t.client.Publish(Topic(t.prefix, id), 1, true, []byte{})
+	select {
+	case <-tok.Done():
+		if err := tok.Error(); err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	t.mu.Lock()
+	delete(t.workers, id)
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *Tracker) handleMessage(_ mqtt.Client, msg mqtt.Message) {
+	ll := logCtx(context.Background(), t.log, "tracker").With().Str("topic", msg.Topic()).Logger()
+	id := strings.TrimPrefix(msg.Topic(), t.prefix+"/")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// An empty retained message clears the topic
+	if len(msg.Payload()) == 0 {
+		delete(t.workers, id)
+		return
+	}
+	var presence api.WorkerPresence
+	if err := json.Unmarshal(msg.Payload(), &presence); err != nil {
+		ll.Warn().Err(err).Msg("dropping malformed worker presence")
+		return
+	}
+	presence.ID = id
+	t.workers[id] = &seenWorker{presence: presence, received: t.now()}
+}
@@ -0,0 +1,224 @@
+// Package quota holds sensors to their storage quotas, downsampling the older readings
+// of a sensor over its quota and alerting when that isn't enough, so readings can't
+// silently fill the disk
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+	"lifesupport/backend/pkg/notify"
+	"lifesupport/backend/pkg/storer"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultRawWindow is how recent readings are kept at full resolution by default
+const DefaultRawWindow = 24 * time.Hour
+
+// Store holds the quotas and the readings they cap; storer.Interface satisfies it
+type Store interface {
+	ListStorageQuotas(ctx context.Context) ([]*api.StorageQuota, error)
+	CountSensorReadings(ctx context.Context, filters storer.SensorReadingFilters) (int64, error)
+	ThinSensorReadings(ctx context.Context, deviceID, sensorID string, before time.Time, interval time.Duration) (int64, error)
+}
+
+// Stats are cumulative counters describing an Enforcer's work
+type Stats struct {
+	Runs   uint64 `json:"runs"`
+	Failed uint64 `json:"failed"`
+	// Thinned counts the readings deleted by downsampling
+	Thinned int64 `json:"thinned"`
+	// OverQuota is how many sensors were still over quota after the most recent run
+	OverQuota int `json:"over_quota"`
+	// LastRun is how long the most recent run took
+	LastRun time.Duration `json:"last_run"`
+}
+
+type Option func(*Enforcer)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(e *Enforcer) {
+		e.log = logger
+	}
+}
+
+// WithNotifier raises an alert when a sensor goes over its quota
+func WithNotifier(n notify.Notifier) Option {
+	return func(e *Enforcer) {
+		e.notifier = n
+	}
+}
+
+// WithRawWindow keeps readings newer than d at full resolution when downsampling
+func WithRawWindow(d time.Duration) Option {
+	return func(e *Enforcer) {
+		e.rawWindow = d
+	}
+}
+
+// Enforcer holds sensors to their storage quotas. A sensor over its quota with
+// downsampling set has its readings older than the raw window thinned; one still over
+// raises an alert, once until it is back under. Quotas are read afresh on every run.
+type Enforcer struct {
+	store     Store
+	notifier  notify.Notifier
+	rawWindow time.Duration
+	log       zerolog.Logger
+
+	lock  sync.Mutex
+	stats Stats
+	// over holds the sensors alerted on as over quota, by device and sensor ID
+	over map[string]bool
+}
+
+func NewEnforcer(store Store, opts ...Option) *Enforcer {
+	e := &Enforcer{
+		store:     store,
+		rawWindow: DefaultRawWindow,
+		over:      make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Tick enforces every quota as of now; it is a lease.Locker loop. A quota failing is
+// reported once the others have been enforced.
+func (e *Enforcer) Tick(ctx context.Context, now time.Time) error {
+	start := time.Now()
+	quotas, err := e.store.ListStorageQuotas(ctx)
+	if err != nil {
+		e.record(0, nil, time.Since(start), true)
+		return fmt.Errorf("failed to list storage quotas: %w", err)
+	}
+
+	var errs []error
+	var thinned int64
+	over := make(map[string]bool)
+	for _, q := range quotas {
+		n, isOver, err := e.enforce(ctx, q, now)
+		thinned += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to enforce storage quota of %s/%s: %w", q.DeviceID, q.SensorID, err))
+			// Keep alerting state as it was until the sensor can be counted again
+			if e.isOver(q) {
+				over[sensorKey(q)] = true
+			}
+			continue
+		}
+		if isOver {
+			over[sensorKey(q)] = true
+		}
+	}
+	e.record(thinned, over, time.Since(start), len(errs) > 0)
+	return errors.Join(errs...)
+}
+
+// enforce downsamples the sensor of q if it is over quota, alerting if it still is, and
+// returns how many readings were thinned and whether it is still over
+func (e *Enforcer) enforce(ctx context.Context, q *api.StorageQuota, now time.Time) (int64, bool, error) {
+	ll := e.logCtx(ctx)
+	filters := storer.SensorReadingFilters{DeviceID: q.DeviceID, SensorID: q.SensorID}
+	count, err := e.store.CountSensorReadings(ctx, filters)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var thinned int64
+	if count > q.MaxReadings && q.Downsample > 0 {
+		thinned, err = e.store.ThinSensorReadings(ctx, q.DeviceID, q.SensorID, now.Add(-e.rawWindow), q.Downsample)
+		if err != nil {
+			return 0, false, err
+		}
+		count -= thinned
+		ll.Info().
+			Str("device_id", q.DeviceID).
+			Str("sensor_id", q.SensorID).
+			Dur("downsample", q.Downsample).
+			Int64("thinned", thinned).
+			Int64("readings", count).
+			Msg("downsampled readings over quota")
+	}
+	if count <= q.MaxReadings {
+		return thinned, false, nil
+	}
+
+	if !e.isOver(q) {
+		ll.Warn().
+			Str("device_id", q.DeviceID).
+			Str("sensor_id", q.SensorID).
+			Int64("readings", count).
+			Int64("max_readings", q.MaxReadings).
+			Msg("sensor over storage quota")
+		if e.notifier != nil {
+			sensor := sensorKey(q)
+			err := e.notifier.Notify(ctx, &api.Alert{
+				ID:       uuid.New().String(),
+				Severity: api.AlertSeverityWarning,
+				Title:    "Storage quota exceeded: " + sensor,
+				Message: fmt.Sprintf("Sensor %s keeps %d readings, over its quota of %d.",
+					sensor, count, q.MaxReadings),
+				Key:       api.AlertKeyStorageQuota,
+				Params:    map[string]string{"sensor": sensor},
+				Labels:    map[string]string{"device_id": q.DeviceID, "sensor_id": q.SensorID},
+				Timestamp: now,
+			})
+			if err != nil {
+				// Alert again on the next run
+				return thinned, false, fmt.Errorf("failed to send storage quota alert: %w", err)
+			}
+		}
+	}
+	return thinned, true, nil
+}
+
+func sensorKey(q *api.StorageQuota) string {
+	return q.DeviceID + "/" + q.SensorID
+}
+
+func (e *Enforcer) isOver(q *api.StorageQuota) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.over[sensorKey(q)]
+}
+
+func (e *Enforcer) record(thinned int64, over map[string]bool, took time.Duration, failed bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.stats.Runs++
+	if failed {
+		e.stats.Failed++
+	}
+	e.stats.Thinned += thinned
+	if over != nil {
+		e.over = over
+		e.stats.OverQuota = len(over)
+	}
+	e.stats.LastRun = took
+}
+
+// Stats returns a snapshot of the enforcer's counters
+func (e *Enforcer) Stats() Stats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.stats
+}
+
+func (e *Enforcer) logCtx(ctx context.Context) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = e.log.With()
+	}
+	return logging.Component(ll.Str("component", "quota").Logger(), "quota")
+}
@@ -0,0 +1,125 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// recordingNotifier keeps the alerts it is sent
+type recordingNotifier struct {
+	alerts []*api.Alert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert *api.Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+// newStore stores a reading every 10 minutes over the two days before now for the
+// temp and ph sensors, and caps them both
+func newStore(t *testing.T, now time.Time) *storer.Memory {
+	t.Helper()
+	store := storer.NewMemory()
+	ctx := context.Background()
+	dev := &api.Device{
+		ID:     "tank",
+		Driver: api.DriverShelly,
+		Name:   "Tank",
+		Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+			{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	var recs []*api.ReadingRecord
+	for _, sensor := range dev.Sensors {
+		for age := 48 * time.Hour; age > 0; age -= 10 * time.Minute {
+			recs = append(recs, &api.ReadingRecord{
+				DeviceID: "tank",
+				SensorID: sensor.ID,
+				Reading:  api.SensorReading{Value: 1, Valid: true, Timestamp: now.Add(-age)},
+			})
+		}
+	}
+	if err := store.StoreSensorReadings(ctx, recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+	for _, quota := range []*api.StorageQuota{
+		{DeviceID: "tank", SensorID: "temp", MaxReadings: 200, Downsample: time.Hour},
+		{DeviceID: "tank", SensorID: "ph", MaxReadings: 200},
+	} {
+		if err := store.SetStorageQuota(ctx, quota); err != nil {
+			t.Fatalf("SetStorageQuota() error = %v", err)
+		}
+	}
+	return store
+}
+
+func count(t *testing.T, store *storer.Memory, sensorID string) int64 {
+	t.Helper()
+	n, err := store.CountSensorReadings(context.Background(), storer.SensorReadingFilters{SensorID: sensorID})
+	if err != nil {
+		t.Fatalf("CountSensorReadings() error = %v", err)
+	}
+	return n
+}
+
+func TestEnforcer(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newStore(t, now)
+	notifier := &recordingNotifier{}
+	e := NewEnforcer(store, WithNotifier(notifier))
+
+	if err := e.Tick(context.Background(), now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	// The day outside the raw window thins to one reading an hour
+	if got := count(t, store, "temp"); got != 144+24 {
+		t.Errorf("Expected temp downsampled to 168 readings, got %d", got)
+	}
+	if got := count(t, store, "ph"); got != 288 {
+		t.Errorf("Expected ph readings kept without downsampling, got %d", got)
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Key != api.AlertKeyStorageQuota || notifier.alerts[0].Params["sensor"] != "tank/ph" {
+		t.Fatalf("Expected one alert for ph, got %+v", notifier.alerts)
+	}
+	stats := e.Stats()
+	if stats.Runs != 1 || stats.Failed != 0 || stats.Thinned != 120 || stats.OverQuota != 1 {
+		t.Errorf("Expected 1 run thinning 120 readings with 1 sensor over, got %+v", stats)
+	}
+
+	// Still over, but already alerted
+	if err := e.Tick(context.Background(), now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if len(notifier.alerts) != 1 {
+		t.Errorf("Expected no repeat alert, got %d", len(notifier.alerts))
+	}
+
+	// Back under once the quota is raised, so going over again alerts again
+	ctx := context.Background()
+	if err := store.SetStorageQuota(ctx, &api.StorageQuota{DeviceID: "tank", SensorID: "ph", MaxReadings: 1000}); err != nil {
+		t.Fatalf("SetStorageQuota() error = %v", err)
+	}
+	if err := e.Tick(ctx, now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if stats = e.Stats(); stats.OverQuota != 0 {
+		t.Errorf("Expected no sensors over quota, got %+v", stats)
+	}
+	if err := store.SetStorageQuota(ctx, &api.StorageQuota{DeviceID: "tank", SensorID: "ph", MaxReadings: 100}); err != nil {
+		t.Fatalf("SetStorageQuota() error = %v", err)
+	}
+	if err := e.Tick(ctx, now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if len(notifier.alerts) != 2 {
+		t.Errorf("Expected a second alert, got %d", len(notifier.alerts))
+	}
+}
@@ -0,0 +1,130 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Store holds the retention policies and deletes the readings they expire;
+// storer.Interface satisfies it
+type Store interface {
+	ListRetentionPolicies(ctx context.Context) ([]*api.RetentionPolicy, error)
+	DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error)
+}
+
+// Stats are cumulative counters describing an Enforcer's work
+type Stats struct {
+	Runs   uint64 `json:"runs"`
+	Failed uint64 `json:"failed"`
+	// Deleted counts the readings deleted, and DeletedByType those of each sensor type
+	Deleted       int64                    `json:"deleted"`
+	DeletedByType map[api.SensorType]int64 `json:"deleted_by_type"`
+	// LastRun is how long the most recent run took
+	LastRun time.Duration `json:"last_run"`
+}
+
+type Option func(*Enforcer)
+
+func WithLogger(logger zerolog.Logger) Option {
+	return func(e *Enforcer) {
+		e.log = logger
+	}
+}
+
+// Enforcer deletes readings once they outlive the retention policy of their sensor's
+// type. Policies are read afresh on every run, so changes take effect without a restart.
+type Enforcer struct {
+	store Store
+	log   zerolog.Logger
+
+	lock  sync.Mutex
+	stats Stats
+}
+
+func NewEnforcer(store Store, opts ...Option) *Enforcer {
+	e := &Enforcer{
+		store: store,
+		stats: Stats{DeletedByType: make(map[api.SensorType]int64)},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Tick enforces every policy as of now; it is a lease.Locker loop. A policy failing is
+// reported once the others have been enforced.
+func (e *Enforcer) Tick(ctx context.Context, now time.Time) error {
+	ll := e.logCtx(ctx)
+	start := time.Now()
+	policies, err := e.store.ListRetentionPolicies(ctx)
+	if err != nil {
+		e.record(nil, time.Since(start), true)
+		return fmt.Errorf("failed to list retention policies: %w", err)
+	}
+
+	var errs []error
+	deleted := make(map[api.SensorType]int64, len(policies))
+	for _, p := range policies {
+		n, err := e.store.DeleteSensorReadingsByType(ctx, p.SensorType, now.Add(-p.Retention))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to enforce retention of %s readings: %w", p.SensorType, err))
+			continue
+		}
+		deleted[p.SensorType] = n
+		if n > 0 {
+			ll.Info().
+				Str("sensor_type", string(p.SensorType)).
+				Dur("retention", p.Retention).
+				Int64("deleted", n).
+				Msg("deleted expired readings")
+		}
+	}
+	e.record(deleted, time.Since(start), len(errs) > 0)
+	return errors.Join(errs...)
+}
+
+func (e *Enforcer) record(deleted map[api.SensorType]int64, took time.Duration, failed bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.stats.Runs++
+	if failed {
+		e.stats.Failed++
+	}
+	for sensorType, n := range deleted {
+		e.stats.Deleted += n
+		e.stats.DeletedByType[sensorType] += n
+	}
+	e.stats.LastRun = took
+}
+
+// Stats returns a snapshot of the enforcer's counters
+func (e *Enforcer) Stats() Stats {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	stats := e.stats
+	stats.DeletedByType = make(map[api.SensorType]int64, len(e.stats.DeletedByType))
+	for sensorType, n := range e.stats.DeletedByType {
+		stats.DeletedByType[sensorType] = n
+	}
+	return stats
+}
+
+func (e *Enforcer) logCtx(ctx context.Context) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = e.log.With()
+	}
+	return logging.Component(ll.Str("component", "retention").Logger(), "retention")
+}
@@ -0,0 +1,119 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// failingStore fails to delete readings of one sensor type
+type failingStore struct {
+	Store
+	fail api.SensorType
+}
+
+func (s failingStore) DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error) {
+	if sensorType == s.fail {
+		return 0, errors.New("database unavailable")
+	}
+	return s.Store.DeleteSensorReadingsByType(ctx, sensorType, before)
+}
+
+func newStore(t *testing.T, now time.Time) *storer.Memory {
+	t.Helper()
+	store := storer.NewMemory()
+	ctx := context.Background()
+	dev := &api.Device{
+		ID:     "tank",
+		Driver: api.DriverShelly,
+		Name:   "Tank",
+		Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+			{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+			{ID: "flow", Name: "Flow", SensorType: api.SensorTypeFlowRate},
+		},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	for _, sensor := range dev.Sensors {
+		for _, age := range []time.Duration{72 * time.Hour, 36 * time.Hour, time.Hour} {
+			rec := &api.ReadingRecord{
+				DeviceID: "tank",
+				SensorID: sensor.ID,
+				Reading:  api.SensorReading{Value: 1, Valid: true, Timestamp: now.Add(-age)},
+			}
+			if err := store.StoreSensorReading(ctx, rec); err != nil {
+				t.Fatalf("StoreSensorReading() error = %v", err)
+			}
+		}
+	}
+	for _, policy := range []*api.RetentionPolicy{
+		{SensorType: api.SensorTypeTemperature, Retention: 24 * time.Hour},
+		{SensorType: api.SensorTypePH, Retention: 48 * time.Hour},
+	} {
+		if err := store.SetRetentionPolicy(ctx, policy); err != nil {
+			t.Fatalf("SetRetentionPolicy() error = %v", err)
+		}
+	}
+	return store
+}
+
+func count(t *testing.T, store *storer.Memory, sensorID string) int64 {
+	t.Helper()
+	n, err := store.CountSensorReadings(context.Background(), storer.SensorReadingFilters{SensorID: sensorID})
+	if err != nil {
+		t.Fatalf("CountSensorReadings() error = %v", err)
+	}
+	return n
+}
+
+func TestEnforcer(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newStore(t, now)
+	e := NewEnforcer(store)
+
+	if err := e.Tick(context.Background(), now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	for sensorID, want := range map[string]int64{"temp": 1, "ph": 2, "flow": 3} {
+		if got := count(t, store, sensorID); got != want {
+			t.Errorf("Expected %d %s readings kept, got %d", want, sensorID, got)
+		}
+	}
+	stats := e.Stats()
+	if stats.Runs != 1 || stats.Failed != 0 || stats.Deleted != 3 {
+		t.Errorf("Expected 1 run deleting 3 readings, got %+v", stats)
+	}
+	if stats.DeletedByType[api.SensorTypeTemperature] != 2 || stats.DeletedByType[api.SensorTypePH] != 1 {
+		t.Errorf("Expected deletions counted by type, got %v", stats.DeletedByType)
+	}
+
+	// Nothing more has expired
+	if err := e.Tick(context.Background(), now); err != nil {
+		t.Fatalf("Tick() error = %v", err)
+	}
+	if stats = e.Stats(); stats.Runs != 2 || stats.Deleted != 3 {
+		t.Errorf("Expected a second run deleting nothing, got %+v", stats)
+	}
+}
+
+func TestEnforcer_PolicyFailure(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newStore(t, now)
+	e := NewEnforcer(failingStore{Store: store, fail: api.SensorTypePH})
+
+	if err := e.Tick(context.Background(), now); err == nil {
+		t.Error("Expected Tick to report the failed policy")
+	}
+	if got := count(t, store, "temp"); got != 1 {
+		t.Errorf("Expected the other policies still enforced, got %d temp readings", got)
+	}
+	if stats := e.Stats(); stats.Failed != 1 || stats.Deleted != 2 {
+		t.Errorf("Expected a failed run deleting 2 readings, got %+v", stats)
+	}
+}
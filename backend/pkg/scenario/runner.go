@@ -0,0 +1,186 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ErrNotSandbox is returned when the target server has devices besides sandbox ones
+var ErrNotSandbox = errors.New("server is not a sandbox")
+
+// Config describes where and how fast a scenario is replayed
+type Config struct {
+	// BaseURL is the sandbox HTTP API server
+	BaseURL string
+	// Speed compresses scenario time: 60 plays an hour of the incident in a minute
+	Speed   float64
+	Cleanup bool
+}
+
+// Validate checks the configuration is usable
+func (c Config) Validate() error {
+	switch {
+	case c.BaseURL == "":
+		return fmt.Errorf("base URL is required")
+	case c.Speed <= 0:
+		return fmt.Errorf("speed must be positive")
+	}
+	return nil
+}
+
+// Runner replays scenarios into a sandbox server through its HTTP API
+type Runner struct {
+	cfg    Config
+	client *http.Client
+}
+
+// New returns a runner for cfg. A nil client uses one with a 30s timeout.
+func New(cfg Config, client *http.Client) *Runner {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	return &Runner{cfg: cfg, client: client}
+}
+
+// Run sets up the scenario's device and replays its readings as synthetic readings at
+// the configured speed, calling onStep, if set, after each one. It refuses to run
+// against a server with devices besides sandbox ones, so live equipment never sees the
+// incident.
+func (r *Runner) Run(ctx context.Context, s *Scenario, onStep func(Step)) error {
+	if err := r.cfg.Validate(); err != nil {
+		return err
+	}
+	if err := r.checkSandbox(ctx); err != nil {
+		return err
+	}
+	if err := r.Setup(ctx, s); err != nil {
+		return err
+	}
+	if r.cfg.Cleanup {
+		defer r.Teardown(context.WithoutCancel(ctx), s)
+	}
+
+	start := time.Now()
+	for _, step := range s.Steps {
+		due := start.Add(time.Duration(float64(step.After) / r.cfg.Speed))
+		if !sleep(ctx, time.Until(due)) {
+			return ctx.Err()
+		}
+		rec := &api.ReadingRecord{
+			DeviceID: s.Device.ID,
+			SensorID: step.SensorID,
+			Reading: api.SensorReading{
+				Value:     step.Value,
+				Unit:      step.Unit,
+				Timestamp: time.Now(),
+				Valid:     true,
+				Synthetic: true,
+			},
+		}
+		if err := r.do(ctx, http.MethodPost, "/api/sensor-readings", rec, nil); err != nil {
+			return fmt.Errorf("failed to post reading of %s: %w", step.SensorID, err)
+		}
+		if onStep != nil {
+			onStep(step)
+		}
+	}
+	return nil
+}
+
+// checkSandbox fails with ErrNotSandbox if the server has any device not created by a
+// scenario
+func (r *Runner) checkSandbox(ctx context.Context) error {
+	var devices []*api.Device
+	if err := r.do(ctx, http.MethodGet, "/api/devices", nil, &devices); err != nil {
+		return fmt.Errorf("failed to list devices: %w", err)
+	}
+	for _, dev := range devices {
+		if dev.Metadata[MetadataScenario] == "" {
+			return fmt.Errorf("%w: device %s is not part of a scenario", ErrNotSandbox, dev.ID)
+		}
+	}
+	return nil
+}
+
+// Setup creates the scenario's device and sets its sensors' targets. A device left by
+// a previous run is reused.
+func (r *Runner) Setup(ctx context.Context, s *Scenario) error {
+	dev := s.Device
+	dev.Metadata = map[string]string{MetadataScenario: s.Name}
+	for k, v := range s.Device.Metadata {
+		dev.Metadata[k] = v
+	}
+	if err := r.do(ctx, http.MethodPost, "/api/devices", dev, nil); err != nil {
+		if existing := r.do(ctx, http.MethodGet, "/api/devices/"+dev.ID, nil, nil); existing != nil {
+			return fmt.Errorf("failed to create device %s: %w", dev.ID, err)
+		}
+	}
+	for _, target := range s.Targets {
+		path := "/api/sensors/" + target.DeviceID + "/" + target.SensorID + "/target"
+		if err := r.do(ctx, http.MethodPut, path, target, nil); err != nil {
+			return fmt.Errorf("failed to set target of %s: %w", target.SensorID, err)
+		}
+	}
+	return nil
+}
+
+// Teardown deletes the scenario's device and with it its readings
+func (r *Runner) Teardown(ctx context.Context, s *Scenario) error {
+	return r.do(ctx, http.MethodDelete, "/api/devices/"+s.Device.ID, nil, nil)
+}
+
+func (r *Runner) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// sleep waits for d, returning false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
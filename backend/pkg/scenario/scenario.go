@@ -0,0 +1,179 @@
+// Package scenario replays canned incidents, such as an ammonia spike or a failed
+// heater, into a sandbox system, so household members can practise responding to alerts
+// without touching live equipment
+package scenario
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// MetadataScenario is the device metadata key marking a sandbox device, naming the
+// scenario which created it
+const MetadataScenario = "scenario"
+
+// Scenario is a canned incident played out on a sandbox device
+type Scenario struct {
+	Name        string
+	Description string
+	// Device is created on the sandbox server before the replay; its sensors receive the
+	// readings of Steps
+	Device api.Device
+	// Targets color-code the device's readings, so the incident shows as warnings and
+	// then critical readings
+	Targets []api.TargetRange
+	// Steps are the readings replayed, ordered by their offset from the start
+	Steps []Step
+}
+
+// Step is one reading of a scenario
+type Step struct {
+	// After is the offset of the reading from the start of the incident
+	After    time.Duration
+	SensorID string
+	Value    float64
+	Unit     api.Unit
+	// Note narrates what is happening, e.g. "Heater relay stuck open"
+	Note string
+}
+
+// Duration is how long the incident lasts in scenario time
+func (s *Scenario) Duration() time.Duration {
+	if len(s.Steps) == 0 {
+		return 0
+	}
+	return s.Steps[len(s.Steps)-1].After
+}
+
+// builtin holds the canned scenarios by name
+var builtin = map[string]func() *Scenario{
+	"ammonia-spike":  ammoniaSpike,
+	"heater-failure": heaterFailure,
+}
+
+// Names lists the canned scenarios
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named canned scenario
+func Get(name string) (*Scenario, error) {
+	build, ok := builtin[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q", name)
+	}
+	return build(), nil
+}
+
+// ramp returns readings of a sensor every interval from start to end, moving linearly
+// from one value to another
+func ramp(sensorID string, unit api.Unit, from, to float64, start, end, every time.Duration) []Step {
+	var steps []Step
+	for at := start; at <= end; at += every {
+		frac := float64(at-start) / float64(end-start)
+		steps = append(steps, Step{After: at, SensorID: sensorID, Value: from + (to-from)*frac, Unit: unit})
+	}
+	return steps
+}
+
+// ordered sorts steps by offset, keeping the order of steps sharing one
+func ordered(steps ...[]Step) []Step {
+	var all []Step
+	for _, s := range steps {
+		all = append(all, s...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].After < all[j].After })
+	return all
+}
+
+// narrate attaches a note to the first step at or after offset
+func narrate(steps []Step, at time.Duration, note string) {
+	for i := range steps {
+		if steps[i].After >= at {
+			steps[i].Note = note
+			return
+		}
+	}
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+// ammoniaSpike is a tank whose ammonia climbs after an overfeed, while pH drifts up and
+// makes it more toxic
+func ammoniaSpike() *Scenario {
+	const device = "sandbox-tank"
+	steps := ordered(
+		ramp("ammonia", api.UnitMgPerL, 0.01, 0.01, 0, 20*time.Minute, 5*time.Minute),
+		ramp("ammonia", api.UnitMgPerL, 0.02, 0.6, 25*time.Minute, 2*time.Hour, 5*time.Minute),
+		ramp("ph", api.UnitPH, 7.2, 7.2, 0, 20*time.Minute, 10*time.Minute),
+		ramp("ph", api.UnitPH, 7.3, 7.9, 30*time.Minute, 2*time.Hour, 10*time.Minute),
+	)
+	narrate(steps, 0, "Tank is stable after the evening feed")
+	narrate(steps, 25*time.Minute, "Uneaten food is decaying and ammonia starts climbing")
+	narrate(steps, time.Hour, "Ammonia passes the warning level; rising pH makes it more toxic")
+	narrate(steps, 90*time.Minute, "Ammonia is critical: fish are at risk")
+	return &Scenario{
+		Name:        "ammonia-spike",
+		Description: "Ammonia climbs over two hours after an overfeed, with pH drifting up",
+		Device: api.Device{
+			ID:     device,
+			Driver: "sandbox",
+			Name:   "Sandbox tank",
+			Sensors: []*api.Sensor{
+				{ID: "ammonia", Name: "Ammonia", SensorType: api.SensorTypeAmmonia},
+				{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+			},
+		},
+		Targets: []api.TargetRange{
+			{DeviceID: device, SensorID: "ammonia",
+				Ideal: api.Band{Max: ptr(0.02)}, Warning: api.Band{Max: ptr(0.1)}, Critical: api.Band{Max: ptr(0.5)}},
+			{DeviceID: device, SensorID: "ph",
+				Ideal: api.Band{Min: ptr(6.8), Max: ptr(7.4)}, Warning: api.Band{Min: ptr(6.5), Max: ptr(7.8)}, Critical: api.Band{Min: ptr(6), Max: ptr(8.2)}},
+		},
+		Steps: steps,
+	}
+}
+
+// heaterFailure is a heater whose relay sticks open overnight, so its power draw drops
+// to nothing and the water slowly cools
+func heaterFailure() *Scenario {
+	const device = "sandbox-heater"
+	steps := ordered(
+		ramp("temperature", api.UnitCelsius, 25, 25, 0, 30*time.Minute, 10*time.Minute),
+		ramp("temperature", api.UnitCelsius, 24.8, 18.5, 40*time.Minute, 6*time.Hour, 20*time.Minute),
+		ramp("power", api.UnitWatts, 150, 150, 0, 30*time.Minute, 10*time.Minute),
+		ramp("power", api.UnitWatts, 0, 0, 40*time.Minute, 6*time.Hour, 20*time.Minute),
+	)
+	narrate(steps, 0, "Heater is cycling normally")
+	narrate(steps, 40*time.Minute, "Heater relay sticks open: power draw drops to zero")
+	narrate(steps, 2*time.Hour, "Water has cooled below the ideal range")
+	narrate(steps, 5*time.Hour, "Temperature is critical for tropical fish")
+	return &Scenario{
+		Name:        "heater-failure",
+		Description: "A heater stops drawing power and the tank cools over six hours",
+		Device: api.Device{
+			ID:     device,
+			Driver: "sandbox",
+			Name:   "Sandbox heater",
+			Sensors: []*api.Sensor{
+				{ID: "temperature", Name: "Water temperature", SensorType: api.SensorTypeTemperature},
+				{ID: "power", Name: "Heater power", SensorType: api.SensorTypePower},
+			},
+		},
+		Targets: []api.TargetRange{
+			{DeviceID: device, SensorID: "temperature",
+				Ideal: api.Band{Min: ptr(24), Max: ptr(26)}, Warning: api.Band{Min: ptr(22), Max: ptr(28)}, Critical: api.Band{Min: ptr(20), Max: ptr(30)}},
+		},
+		Steps: steps,
+	}
+}
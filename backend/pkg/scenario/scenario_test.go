@@ -0,0 +1,117 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/httpapi"
+	"lifesupport/backend/pkg/storer"
+)
+
+func TestBuiltinScenarios(t *testing.T) {
+	names := Names()
+	if len(names) != 2 {
+		t.Fatalf("Expected 2 scenarios, got %v", names)
+	}
+	for _, name := range names {
+		s, err := Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", name, err)
+		}
+		sensors := map[string]bool{}
+		for _, sensor := range s.Device.Sensors {
+			sensors[sensor.ID] = true
+		}
+		for i, step := range s.Steps {
+			if !sensors[step.SensorID] {
+				t.Errorf("%s: step %d reads unknown sensor %s", name, i, step.SensorID)
+			}
+			if i > 0 && step.After < s.Steps[i-1].After {
+				t.Errorf("%s: step %d is out of order", name, i)
+			}
+		}
+		for _, target := range s.Targets {
+			if err := target.Validate(); err != nil {
+				t.Errorf("%s: invalid target for %s: %v", name, target.SensorID, err)
+			}
+		}
+		if s.Steps[0].Note == "" {
+			t.Errorf("%s: expected the first step to be narrated", name)
+		}
+	}
+	if _, err := Get("volcano"); err == nil {
+		t.Error("Expected an error for an unknown scenario")
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	store := storer.NewMemory()
+	srv := httptest.NewServer(httpapi.NewHandler(store, nil, nil).SetupRouter())
+	defer srv.Close()
+	ctx := context.Background()
+
+	s, _ := Get("heater-failure")
+	var notes []string
+	runner := New(Config{BaseURL: srv.URL, Speed: 1e6}, nil)
+	err := runner.Run(ctx, s, func(step Step) {
+		if step.Note != "" {
+			notes = append(notes, step.Note)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(notes) != 4 {
+		t.Errorf("Expected 4 narrated steps, got %v", notes)
+	}
+
+	dev, err := store.GetDevice(ctx, "sandbox-heater")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if dev.Metadata[MetadataScenario] != "heater-failure" {
+		t.Errorf("Expected the device to be marked as a sandbox device, got %v", dev.Metadata)
+	}
+	readings, err := store.GetSensorReadings(ctx, storer.SensorReadingFilters{DeviceID: "sandbox-heater"})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(readings) != len(s.Steps) {
+		t.Fatalf("Expected %d readings, got %d", len(s.Steps), len(readings))
+	}
+	for _, rec := range readings {
+		if !rec.Reading.Synthetic {
+			t.Fatalf("Expected replayed readings to be synthetic, got %+v", rec.Reading)
+		}
+	}
+	if _, err := store.GetSensorTarget(ctx, "sandbox-heater", "temperature"); err != nil {
+		t.Errorf("Expected the temperature target to be set, got %v", err)
+	}
+
+	// A second run reuses the device
+	if err := runner.Run(ctx, s, nil); err != nil {
+		t.Errorf("Expected a second run to reuse the device, got %v", err)
+	}
+}
+
+func TestRunner_RefusesLiveServer(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "sump-pump", Driver: api.DriverShelly, Name: "Sump pump"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	srv := httptest.NewServer(httpapi.NewHandler(store, nil, nil).SetupRouter())
+	defer srv.Close()
+
+	s, _ := Get("ammonia-spike")
+	err := New(Config{BaseURL: srv.URL, Speed: 1e6}, nil).Run(ctx, s, nil)
+	if !errors.Is(err, ErrNotSandbox) {
+		t.Fatalf("Expected ErrNotSandbox, got %v", err)
+	}
+	if _, err := store.GetDevice(ctx, "sandbox-tank"); !errors.Is(err, storer.ErrNotFound) {
+		t.Errorf("Expected nothing to be created on a live server, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package secrets keeps device credentials encrypted at rest with envelope encryption.
+// Each secret is sealed with a random data key of its own, and only the data key is
+// sealed with the master key, so the master key never encrypts stored secrets directly.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// KeySize is the length in bytes of the master key and of each data key (AES-256)
+const KeySize = 32
+
+// ErrWrongKey is returned when revealing a secret sealed with a different master key
+var ErrWrongKey = errors.New("credential was sealed with a different master key")
+
+// ErrNoCredential is returned when revealing a named credential a device doesn't have
+var ErrNoCredential = errors.New("no such credential")
+
+// Store persists sealed credentials; storer.Interface satisfies it
+type Store interface {
+	SetCredential(ctx context.Context, cred *api.SealedCredential) error
+	GetCredential(ctx context.Context, id string) (*api.SealedCredential, error)
+	ListCredentials(ctx context.Context, deviceID string) ([]*api.Credential, error)
+}
+
+// Vault seals credentials on their way into the store and reveals them to the drivers
+// which need them. Nothing it returns to API callers contains a secret.
+type Vault struct {
+	store  Store
+	master cipher.AEAD
+	keyID  string
+}
+
+// NewVault creates a vault sealing secrets with masterKey, which must be KeySize bytes
+func NewVault(store Store, masterKey []byte) (*Vault, error) {
+	if len(masterKey) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(masterKey))
+	}
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(masterKey)
+	return &Vault{store: store, master: master, keyID: hex.EncodeToString(sum[:8])}, nil
+}
+
+// LoadMasterKey reads a base64-encoded master key from path, as written by
+// `openssl rand -base64 32`
+func LoadMasterKey(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode master key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// KeyID identifies the vault's master key without revealing it
+func (v *Vault) KeyID() string {
+	return v.keyID
+}
+
+// Set seals secret as the device's named credential, replacing any existing secret of
+// that name, and returns the credential's description
+func (v *Vault) Set(ctx context.Context, deviceID, name string, secret []byte) (*api.Credential, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	cred := &api.SealedCredential{
+		Credential: api.Credential{DeviceID: deviceID, Name: name, KeyID: v.keyID},
+	}
+	if cred.Ciphertext, err = seal(data, secret, secretAAD(deviceID, name)); err != nil {
+		return nil, err
+	}
+	if cred.WrappedKey, err = seal(v.master, dataKey, []byte(v.keyID)); err != nil {
+		return nil, err
+	}
+	if err := v.store.SetCredential(ctx, cred); err != nil {
+		return nil, fmt.Errorf("failed to store credential: %w", err)
+	}
+	return &cred.Credential, nil
+}
+
+// Reveal returns the secret of the credential with the given ID. It is for drivers
+// authenticating to devices; never return its result to an API caller.
+func (v *Vault) Reveal(ctx context.Context, id string) ([]byte, error) {
+	cred, err := v.store.GetCredential(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	if cred.KeyID != v.keyID {
+		return nil, fmt.Errorf("%w: %s", ErrWrongKey, cred.KeyID)
+	}
+	dataKey, err := open(v.master, cred.WrappedKey, []byte(v.keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := open(data, cred.Ciphertext, secretAAD(cred.DeviceID, cred.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential: %w", err)
+	}
+	return secret, nil
+}
+
+// RevealNamed returns the secret of the device's credential called name, for drivers
+// which know a device's credentials by name rather than ID
+func (v *Vault) RevealNamed(ctx context.Context, deviceID, name string) ([]byte, error) {
+	creds, err := v.store.ListCredentials(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list credentials: %w", err)
+	}
+	for _, cred := range creds {
+		if cred.Name == name {
+			return v.Reveal(ctx, cred.ID)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s of device %s", ErrNoCredential, name, deviceID)
+}
+
+// secretAAD binds a ciphertext to its credential, so a sealed secret copied to another
+// device or name fails to decrypt
+func secretAAD(deviceID, name string) []byte {
+	return []byte(deviceID + "\x00" + name)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext, prefixing the result with its random nonce
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
@@ -0,0 +1,165 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newStore(t *testing.T) *storer.Memory {
+	t.Helper()
+	store := storer.NewMemory()
+	for _, id := range []string{"camera-1", "camera-2"} {
+		if err := store.CreateDevice(context.Background(), &api.Device{ID: id, Name: id}); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	return store
+}
+
+func TestVault_SetAndReveal(t *testing.T) {
+	store := newStore(t)
+	vault, err := NewVault(store, newKey(t))
+	if err != nil {
+		t.Fatalf("NewVault() error = %v", err)
+	}
+	ctx := context.Background()
+
+	cred, err := vault.Set(ctx, "camera-1", "rtsp", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if cred.ID == "" || cred.KeyID != vault.KeyID() {
+		t.Errorf("Expected an ID and the vault's key ID, got %+v", cred)
+	}
+	sealed, err := store.GetCredential(ctx, cred.ID)
+	if err != nil {
+		t.Fatalf("GetCredential() error = %v", err)
+	}
+	if bytes.Contains(sealed.Ciphertext, []byte("hunter2")) || bytes.Contains(sealed.WrappedKey, []byte("hunter2")) {
+		t.Error("Expected secret to be encrypted at rest")
+	}
+
+	secret, err := vault.Reveal(ctx, cred.ID)
+	if err != nil {
+		t.Fatalf("Reveal() error = %v", err)
+	}
+	if string(secret) != "hunter2" {
+		t.Errorf("Expected hunter2, got %q", secret)
+	}
+
+	rotated, err := vault.Set(ctx, "camera-1", "rtsp", []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if rotated.ID != cred.ID {
+		t.Errorf("Expected replacing a secret to keep its ID %s, got %s", cred.ID, rotated.ID)
+	}
+	if secret, _ := vault.Reveal(ctx, cred.ID); string(secret) != "correct horse" {
+		t.Errorf("Expected the replaced secret, got %q", secret)
+	}
+}
+
+func TestVault_RevealNamed(t *testing.T) {
+	store := newStore(t)
+	vault, err := NewVault(store, newKey(t))
+	if err != nil {
+		t.Fatalf("NewVault() error = %v", err)
+	}
+	ctx := context.Background()
+	for _, deviceID := range []string{"camera-1", "camera-2"} {
+		if _, err := vault.Set(ctx, deviceID, "auth", []byte("pw-"+deviceID)); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+	}
+
+	secret, err := vault.RevealNamed(ctx, "camera-2", "auth")
+	if err != nil {
+		t.Fatalf("RevealNamed() error = %v", err)
+	}
+	if string(secret) != "pw-camera-2" {
+		t.Errorf("Expected pw-camera-2, got %q", secret)
+	}
+	if _, err := vault.RevealNamed(ctx, "camera-2", "rtsp"); !errors.Is(err, ErrNoCredential) {
+		t.Errorf("Expected ErrNoCredential, got %v", err)
+	}
+}
+
+func TestVault_WrongKey(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+	vault, _ := NewVault(store, newKey(t))
+	cred, err := vault.Set(ctx, "camera-1", "rtsp", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	other, _ := NewVault(store, newKey(t))
+	if _, err := other.Reveal(ctx, cred.ID); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Expected ErrWrongKey, got %v", err)
+	}
+}
+
+func TestVault_SwappedCiphertext(t *testing.T) {
+	store := newStore(t)
+	ctx := context.Background()
+	vault, _ := NewVault(store, newKey(t))
+	first, _ := vault.Set(ctx, "camera-1", "rtsp", []byte("hunter2"))
+	second, _ := vault.Set(ctx, "camera-2", "rtsp", []byte("letmein"))
+
+	// Copy camera-1's sealed secret onto camera-2's credential
+	a, _ := store.GetCredential(ctx, first.ID)
+	b, _ := store.GetCredential(ctx, second.ID)
+	b.WrappedKey, b.Ciphertext = a.WrappedKey, a.Ciphertext
+	if err := store.SetCredential(ctx, b); err != nil {
+		t.Fatalf("SetCredential() error = %v", err)
+	}
+	if _, err := vault.Reveal(ctx, second.ID); err == nil {
+		t.Error("Expected a secret moved to another credential not to decrypt")
+	}
+}
+
+func TestNewVault_KeySize(t *testing.T) {
+	if _, err := NewVault(storer.NewMemory(), []byte("short")); err == nil {
+		t.Error("Expected error for a short master key")
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	key := newKey(t)
+	path := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMasterKey(path)
+	if err != nil {
+		t.Fatalf("LoadMasterKey() error = %v", err)
+	}
+	if !bytes.Equal(loaded, key) {
+		t.Error("Expected loaded key to match")
+	}
+
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key[:16])), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMasterKey(path); err == nil {
+		t.Error("Expected error for a 16 byte key")
+	}
+}
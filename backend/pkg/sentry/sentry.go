@@ -0,0 +1,365 @@
+// Package sentry reports errors and panics to Sentry, or any server speaking its
+// envelope protocol such as GlitchTip, so failures on remote installations surface in
+// one place.
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/logging"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// queueSize bounds the events waiting to be sent; more are dropped rather than blocking
+// the code reporting them
+const queueSize = 100
+
+// Client sends events in the background. A nil *Client discards everything, so callers
+// need not check whether reporting is configured.
+type Client struct {
+	dsn         string
+	endpoint    string
+	key         string
+	release     string
+	environment string
+	serverName  string
+	httpClient  *http.Client
+	log         zerolog.Logger
+
+	queue   chan *Event
+	pending sync.WaitGroup
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithRelease tags every event with the release, so errors can be tied to a deploy
+func WithRelease(release string) Option {
+	return func(c *Client) {
+		c.release = release
+	}
+}
+
+// WithEnvironment tags every event with an environment, such as production or staging
+func WithEnvironment(env string) Option {
+	return func(c *Client) {
+		c.environment = env
+	}
+}
+
+// WithServerName overrides the hostname events are attributed to
+func WithServerName(name string) Option {
+	return func(c *Client) {
+		c.serverName = name
+	}
+}
+
+// WithHTTPClient sets the HTTP client events are sent with
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithLogger sets the logger used for failures to send events
+func WithLogger(l zerolog.Logger) Option {
+	return func(c *Client) {
+		c.log = l
+	}
+}
+
+// New creates a client for a DSN like https://<key>@sentry.example.com/<project>
+func New(dsn string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN: %w", err)
+	}
+	project := strings.Trim(u.Path, "/")
+	if u.User == nil || u.User.Username() == "" || u.Host == "" || project == "" {
+		return nil, errors.New("invalid DSN: expected https://<key>@host/<project>")
+	}
+	prefix := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+
+	hostname, _ := os.Hostname()
+	c := &Client{
+		dsn:        dsn,
+		endpoint:   fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		key:        u.User.Username(),
+		serverName: hostname,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		log:        log.Logger,
+		queue:      make(chan *Event, queueSize),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *Client) logCtx(ctx context.Context) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = c.log.With()
+	}
+	return logging.Component(ll.Str("component", "sentry").Logger(), "sentry")
+}
+
+// Event is the part of a Sentry event this package fills in
+type Event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Exception   *exceptions       `json:"exception,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+}
+
+// Fingerprinter is implemented by errors which should be grouped by something other
+// than their stack, such as errors all reported from one place
+type Fingerprinter interface {
+	Fingerprint() []string
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// CaptureError reports err, tagged with tags, along with the stack of the caller
+func (c *Client) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	if c == nil || err == nil {
+		return
+	}
+	// Report the innermost error's type; wrapping types say little about the failure
+	typ := err
+	for next := errors.Unwrap(typ); next != nil; next = errors.Unwrap(typ) {
+		typ = next
+	}
+	ev := c.newEvent("error", reflect.TypeOf(typ).String(), err.Error(), tags, 3)
+	var fp Fingerprinter
+	if errors.As(err, &fp) {
+		ev.Fingerprint = fp.Fingerprint()
+	}
+	c.enqueue(ctx, ev)
+}
+
+// CapturePanic reports a value recovered from a panic. It must be called from the
+// deferred function which recovered it, so the stack is the panicking one.
+func (c *Client) CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string) {
+	if c == nil || recovered == nil {
+		return
+	}
+	value := fmt.Sprint(recovered)
+	if err, ok := recovered.(error); ok {
+		value = err.Error()
+	}
+	c.enqueue(ctx, c.newEvent("fatal", "panic", value, tags, 3))
+}
+
+// Recover reports a panic in the calling goroutine, waits for it to be sent, and
+// panics again. Defer it at the top of long-running goroutines.
+func (c *Client) Recover(tags map[string]string) {
+	if c == nil {
+		return
+	}
+	if recovered := recover(); recovered != nil {
+		c.CapturePanic(context.Background(), recovered, tags)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		c.Flush(ctx)
+		cancel()
+		panic(recovered)
+	}
+}
+
+func (c *Client) newEvent(level, typ, value string, tags map[string]string, skip int) *Event {
+	return &Event{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC(),
+		Level:       level,
+		Platform:    "go",
+		Release:     c.release,
+		Environment: c.environment,
+		ServerName:  c.serverName,
+		Exception: &exceptions{Values: []exception{{
+			Type:       typ,
+			Value:      value,
+			Stacktrace: callers(skip),
+		}}},
+		Tags: tags,
+	}
+}
+
+func (c *Client) enqueue(ctx context.Context, ev *Event) {
+	c.pending.Add(1)
+	select {
+	case c.queue <- ev:
+	default:
+		c.pending.Done()
+		ll := c.logCtx(ctx)
+		ll.Warn().Str("event_id", ev.EventID).Msg("error report queue full; dropping event")
+	}
+}
+
+// Flush waits until every captured event has been sent or ctx is done
+func (c *Client) Flush(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	for ev := range c.queue {
+		if err := c.send(ev); err != nil {
+			ll := c.logCtx(context.Background())
+			ll.Warn().Err(err).Str("event_id", ev.EventID).Msg("unable to send error report")
+		}
+		c.pending.Done()
+	}
+}
+
+// send posts ev as an envelope: a header, an item header and the event, one per line
+func (c *Client) send(ev *Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	var envelope bytes.Buffer
+	json.NewEncoder(&envelope).Encode(map[string]interface{}{
+		"event_id": ev.EventID,
+		"sent_at":  time.Now().UTC(),
+		"dsn":      c.dsn,
+	})
+	json.NewEncoder(&envelope).Encode(map[string]interface{}{
+		"type":   "event",
+		"length": len(body),
+	})
+	envelope.Write(body)
+	envelope.WriteByte('\n')
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, &envelope)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=lifesupport/1.0, sentry_key="+c.key)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status sending event: %s", resp.Status)
+	}
+	return nil
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// callers returns the stack of the caller skip frames above it, outermost first as
+// Sentry expects.
+// Runtime frames, including the panic machinery, are left out.
+func callers(skip int) *stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var st []frame
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !strings.HasPrefix(f.Function, "runtime.") {
+			module, function := splitFunction(f.Function)
+			st = append(st, frame{
+				Function: function,
+				Module:   module,
+				Filename: shortFile(f.File),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+				InApp:    strings.HasPrefix(module, "lifesupport/"),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(st)-1; i < j; i, j = i+1, j-1 {
+		st[i], st[j] = st[j], st[i]
+	}
+	return &stacktrace{Frames: st}
+}
+
+// splitFunction splits a qualified function name like lifesupport/backend/pkg/x.(*T).F
+// into its package and the rest
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+func shortFile(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		return strings.Join(parts[len(parts)-2:], "/")
+	}
+	return path
+}
@@ -0,0 +1,209 @@
+package sentry
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	auth   string
+	header map[string]interface{}
+	item   map[string]interface{}
+	event  Event
+}
+
+func newServer(t *testing.T) (*httptest.Server, func() []received) {
+	t.Helper()
+	var lock sync.Mutex
+	var events []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/42/envelope/" {
+			t.Errorf("Expected envelope path /api/42/envelope/, got %s", r.URL.Path)
+		}
+		var rec received
+		rec.auth = r.Header.Get("X-Sentry-Auth")
+		scanner := bufio.NewScanner(r.Body)
+		for i := 0; scanner.Scan(); i++ {
+			var err error
+			switch i {
+			case 0:
+				err = json.Unmarshal(scanner.Bytes(), &rec.header)
+			case 1:
+				err = json.Unmarshal(scanner.Bytes(), &rec.item)
+			case 2:
+				err = json.Unmarshal(scanner.Bytes(), &rec.event)
+			}
+			if err != nil {
+				t.Errorf("Expected envelope line %d to be JSON, got %v", i, err)
+			}
+		}
+		lock.Lock()
+		events = append(events, rec)
+		lock.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]received(nil), events...)
+	}
+}
+
+func newClient(t *testing.T, srv *httptest.Server) *Client {
+	t.Helper()
+	dsn := strings.Replace(srv.URL, "http://", "http://abc123@", 1) + "/42"
+	c, err := New(dsn, WithRelease("v1.2.3"), WithEnvironment("test"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return c
+}
+
+func flush(t *testing.T, c *Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Flush(ctx); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+}
+
+func TestNew_DSN(t *testing.T) {
+	c, err := New("https://key@sentry.example.com/prefix/7")
+	if err != nil {
+		t.Fatalf("Failed to parse DSN: %v", err)
+	}
+	if c.endpoint != "https://sentry.example.com/prefix/api/7/envelope/" {
+		t.Errorf("Expected endpoint with path prefix, got %s", c.endpoint)
+	}
+	if c.key != "key" {
+		t.Errorf("Expected key 'key', got %s", c.key)
+	}
+
+	for _, dsn := range []string{"https://sentry.example.com/7", "https://key@sentry.example.com/", "::"} {
+		if _, err := New(dsn); err == nil {
+			t.Errorf("Expected an error for DSN %q", dsn)
+		}
+	}
+}
+
+type notFoundError struct{ id string }
+
+func (e *notFoundError) Error() string { return "not found: " + e.id }
+
+func TestCaptureError(t *testing.T) {
+	srv, events := newServer(t)
+	c := newClient(t, srv)
+
+	err := fmt.Errorf("failed to load device: %w", &notFoundError{id: "d1"})
+	c.CaptureError(context.Background(), err, map[string]string{"route": "/api/devices/{id}"})
+	flush(t, c)
+
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+	rec := got[0]
+	if !strings.Contains(rec.auth, "sentry_key=abc123") {
+		t.Errorf("Expected auth header with the DSN key, got %q", rec.auth)
+	}
+	if rec.item["type"] != "event" {
+		t.Errorf("Expected an event item, got %v", rec.item["type"])
+	}
+	if rec.header["event_id"] != rec.event.EventID {
+		t.Errorf("Expected envelope and event IDs to match, got %v and %s", rec.header["event_id"], rec.event.EventID)
+	}
+	ev := rec.event
+	if ev.Release != "v1.2.3" || ev.Environment != "test" {
+		t.Errorf("Expected release v1.2.3 in test, got %s in %s", ev.Release, ev.Environment)
+	}
+	if ev.Level != "error" || ev.Tags["route"] != "/api/devices/{id}" {
+		t.Errorf("Expected an error level event with tags, got %s %v", ev.Level, ev.Tags)
+	}
+	exc := ev.Exception.Values[0]
+	if exc.Type != "*sentry.notFoundError" {
+		t.Errorf("Expected the innermost error type, got %s", exc.Type)
+	}
+	if exc.Value != "failed to load device: not found: d1" {
+		t.Errorf("Expected the full error message, got %s", exc.Value)
+	}
+	frames := exc.Stacktrace.Frames
+	if len(frames) == 0 || frames[len(frames)-1].Function != "TestCaptureError" {
+		t.Errorf("Expected the stack to end at the caller, got %+v", frames)
+	}
+}
+
+type groupedError struct{}
+
+func (groupedError) Error() string         { return "grouped" }
+func (groupedError) Fingerprint() []string { return []string{"a", "b"} }
+
+func TestCaptureError_Fingerprint(t *testing.T) {
+	srv, events := newServer(t)
+	c := newClient(t, srv)
+
+	c.CaptureError(context.Background(), fmt.Errorf("wrapped: %w", groupedError{}), nil)
+	flush(t, c)
+
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(got))
+	}
+	if fp := got[0].event.Fingerprint; len(fp) != 2 || fp[0] != "a" || fp[1] != "b" {
+		t.Errorf("Expected the error's fingerprint, got %v", fp)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	srv, events := newServer(t)
+	c := newClient(t, srv)
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "boom" {
+				t.Errorf("Expected the panic to continue, got %v", recovered)
+			}
+		}()
+		defer c.Recover(map[string]string{"command": "test"})
+		panic("boom")
+	}()
+
+	got := events()
+	if len(got) != 1 {
+		t.Fatalf("Expected the panic to be sent before re-panicking, got %d events", len(got))
+	}
+	ev := got[0].event
+	if ev.Level != "fatal" || ev.Exception.Values[0].Type != "panic" || ev.Exception.Values[0].Value != "boom" {
+		t.Errorf("Expected a fatal panic event, got %s %+v", ev.Level, ev.Exception.Values[0])
+	}
+	if ev.Tags["command"] != "test" {
+		t.Errorf("Expected command tag, got %v", ev.Tags)
+	}
+}
+
+func TestNilClient(t *testing.T) {
+	var c *Client
+	c.CaptureError(context.Background(), errors.New("ignored"), nil)
+	c.CapturePanic(context.Background(), "ignored", nil)
+	if err := c.Flush(context.Background()); err != nil {
+		t.Errorf("Expected nil client to flush, got %v", err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected nil client to leave panics alone")
+			}
+		}()
+		defer c.Recover(nil)
+		panic("boom")
+	}()
+}
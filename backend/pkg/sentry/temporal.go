@@ -0,0 +1,61 @@
+package sentry
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// WorkerInterceptor reports failed and panicking activities. Every failed attempt is
+// reported, tagged with its attempt number, since retries can hide a persistent fault.
+func (c *Client) WorkerInterceptor() interceptor.WorkerInterceptor {
+	return &workerInterceptor{client: c}
+}
+
+type workerInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	client *Client
+}
+
+func (w *workerInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := &activityInterceptor{client: w.client}
+	i.Next = next
+	return i
+}
+
+type activityInterceptor struct {
+	interceptor.ActivityInboundInterceptorBase
+	client *Client
+}
+
+func (a *activityInterceptor) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (result interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			// Temporal turns the panic into a failed attempt once it's reported
+			a.client.CapturePanic(ctx, recovered, activityTags(ctx))
+			panic(recovered)
+		}
+	}()
+	result, err = a.Next.ExecuteActivity(ctx, in)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		a.client.CaptureError(ctx, err, activityTags(ctx))
+	}
+	return result, err
+}
+
+func activityTags(ctx context.Context) map[string]string {
+	info := activity.GetInfo(ctx)
+	tags := map[string]string{
+		"activity":    info.ActivityType.Name,
+		"workflow_id": info.WorkflowExecution.ID,
+		"attempt":     strconv.Itoa(int(info.Attempt)),
+		"task_queue":  info.TaskQueue,
+	}
+	if info.WorkflowType != nil {
+		tags["workflow"] = info.WorkflowType.Name
+	}
+	return tags
+}
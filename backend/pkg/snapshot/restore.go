@@ -0,0 +1,156 @@
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Restore applies snap to store, creating the entities it lacks and updating those that
+// differ, matched by ID. Entities missing from snap are left alone, so restoring the
+// same snapshot twice changes nothing the second time. Changes are applied in the order
+// Diff reports them and stop at the first failure; restoring again picks up where it
+// left off. The returned diff lists the changes applied.
+func Restore(ctx context.Context, store storer.Interface, snap *api.ConfigSnapshot) (*api.ConfigDiff, error) {
+	current, err := Take(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	inherit(current, snap)
+	diff, err := Diff(current, snap)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := &api.ConfigDiff{Changes: []api.ConfigChange{}}
+	for _, change := range diff.Changes {
+		if change.Change == api.ConfigRemoved {
+			continue
+		}
+		if err := apply(ctx, store, change); err != nil {
+			return applied, fmt.Errorf("failed to restore %s %s: %w", change.Kind, change.ID, err)
+		}
+		applied.Changes = append(applied.Changes, change)
+	}
+	return applied, nil
+}
+
+// inherit fills in what a hand-written snapshot may leave out of the entities it shares
+// with current: sensors' and actuators' device IDs, and the tags and external IDs the
+// store assigned them, which would otherwise be reported as changed on every restore
+func inherit(current, snap *api.ConfigSnapshot) {
+	devices := make(map[string]*api.Device, len(current.Devices))
+	for _, dev := range current.Devices {
+		devices[dev.ID] = dev
+	}
+	for _, dev := range snap.Devices {
+		for _, sensor := range dev.Sensors {
+			sensor.DeviceID = dev.ID
+		}
+		for _, actuator := range dev.Actuators {
+			actuator.DeviceID = dev.ID
+		}
+		was, ok := devices[dev.ID]
+		if !ok {
+			continue
+		}
+		inheritIdentity(&dev.Tags, &dev.ExternalID, was.Tags, was.ExternalID)
+
+		sensors := make(map[string]*api.Sensor, len(was.Sensors))
+		for _, sensor := range was.Sensors {
+			sensors[sensor.ID] = sensor
+		}
+		for _, sensor := range dev.Sensors {
+			if s, ok := sensors[sensor.ID]; ok {
+				inheritIdentity(&sensor.Tags, &sensor.ExternalID, s.Tags, s.ExternalID)
+			}
+		}
+		actuators := make(map[string]*api.Actuator, len(was.Actuators))
+		for _, actuator := range was.Actuators {
+			actuators[actuator.ID] = actuator
+		}
+		for _, actuator := range dev.Actuators {
+			if a, ok := actuators[actuator.ID]; ok {
+				inheritIdentity(&actuator.Tags, &actuator.ExternalID, a.Tags, a.ExternalID)
+			}
+		}
+	}
+}
+
+func inheritIdentity(tags *[]string, externalID *string, wasTags []string, wasExternalID string) {
+	if len(*tags) == 0 {
+		*tags = wasTags
+	}
+	if *externalID == "" {
+		*externalID = wasExternalID
+	}
+}
+
+// apply makes one added or changed entity of a diff so in store. Entities are decoded
+// from the change, which carries them without their versions, so updates are based on
+// the stored version.
+func apply(ctx context.Context, store storer.Interface, change api.ConfigChange) error {
+	// Sensors and actuators are identified as {device_id}/{id}
+	deviceID, id, _ := strings.Cut(change.ID, "/")
+	switch change.Kind {
+	case api.ConfigKindDevice:
+		var dev api.Device
+		if err := json.Unmarshal(change.After, &dev); err != nil {
+			return err
+		}
+		if change.Change == api.ConfigAdded {
+			return store.CreateDevice(ctx, &dev)
+		}
+		was, err := store.GetDevice(ctx, dev.ID)
+		if err != nil {
+			return err
+		}
+		dev.Version = was.Version
+		return store.UpdateDevice(ctx, &dev)
+	case api.ConfigKindSensor:
+		var sensor api.Sensor
+		if err := json.Unmarshal(change.After, &sensor); err != nil {
+			return err
+		}
+		if change.Change == api.ConfigAdded {
+			return store.CreateSensor(ctx, &sensor)
+		}
+		was, err := store.GetSensor(ctx, deviceID, id)
+		if err != nil {
+			return err
+		}
+		sensor.Version = was.Version
+		return store.UpdateSensor(ctx, &sensor)
+	case api.ConfigKindActuator:
+		var actuator api.Actuator
+		if err := json.Unmarshal(change.After, &actuator); err != nil {
+			return err
+		}
+		if change.Change == api.ConfigAdded {
+			return store.CreateActuator(ctx, &actuator)
+		}
+		was, err := store.GetActuator(ctx, deviceID, id)
+		if err != nil {
+			return err
+		}
+		actuator.Version = was.Version
+		return store.UpdateActuator(ctx, &actuator)
+	case api.ConfigKindTagAlias:
+		var alias api.TagAlias
+		if err := json.Unmarshal(change.After, &alias); err != nil {
+			return err
+		}
+		return store.SetTagAlias(ctx, &alias)
+	case api.ConfigKindTarget:
+		var target api.TargetRange
+		if err := json.Unmarshal(change.After, &target); err != nil {
+			return err
+		}
+		return store.SetSensorTarget(ctx, &target)
+	}
+	return fmt.Errorf("unknown kind %q", change.Kind)
+}
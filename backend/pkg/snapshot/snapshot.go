@@ -0,0 +1,224 @@
+// Package snapshot captures the system's configuration and compares snapshots, so
+// changes can be reviewed before restoring a backup
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Take captures the configuration in store, ordered by ID so snapshots of the same
+// configuration are identical
+func Take(ctx context.Context, store storer.Interface) (*api.ConfigSnapshot, error) {
+	devices, err := store.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	sensors, err := store.ListSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	actuators, err := store.ListActuators(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list actuators: %w", err)
+	}
+	aliases, err := store.ListTagAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag aliases: %w", err)
+	}
+	targets, err := store.ListSensorTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensor targets: %w", err)
+	}
+
+	byID := make(map[string]*api.Device, len(devices))
+	for _, dev := range devices {
+		dev.Sensors, dev.Actuators = []*api.Sensor{}, []*api.Actuator{}
+		byID[dev.ID] = dev
+	}
+	for _, sensor := range sensors {
+		if dev, ok := byID[sensor.DeviceID]; ok {
+			dev.Sensors = append(dev.Sensors, sensor)
+		}
+	}
+	for _, actuator := range actuators {
+		if dev, ok := byID[actuator.DeviceID]; ok {
+			dev.Actuators = append(dev.Actuators, actuator)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	for _, dev := range devices {
+		sort.Slice(dev.Sensors, func(i, j int) bool { return dev.Sensors[i].ID < dev.Sensors[j].ID })
+		sort.Slice(dev.Actuators, func(i, j int) bool { return dev.Actuators[i].ID < dev.Actuators[j].ID })
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+	sort.Slice(targets, func(i, j int) bool { return targetID(targets[i]) < targetID(targets[j]) })
+
+	snap := &api.ConfigSnapshot{
+		TakenAt:    time.Now().UTC(),
+		Devices:    devices,
+		TagAliases: aliases,
+		Targets:    targets,
+	}
+	if snap.Devices == nil {
+		snap.Devices = []*api.Device{}
+	}
+	if snap.TagAliases == nil {
+		snap.TagAliases = []*api.TagAlias{}
+	}
+	if snap.Targets == nil {
+		snap.Targets = []*api.TargetRange{}
+	}
+	return snap, nil
+}
+
+// kinds is the order changes are reported in
+var kinds = []api.ConfigKind{
+	api.ConfigKindDevice,
+	api.ConfigKindSensor,
+	api.ConfigKindActuator,
+	api.ConfigKindTagAlias,
+	api.ConfigKindTarget,
+}
+
+// Diff reports the entities added, removed or changed going from one snapshot to
+// another
+func Diff(from, to *api.ConfigSnapshot) (*api.ConfigDiff, error) {
+	before, err := entities(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := entities(to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &api.ConfigDiff{Changes: []api.ConfigChange{}}
+	for _, kind := range kinds {
+		ids := make([]string, 0, len(before[kind])+len(after[kind]))
+		for id := range before[kind] {
+			ids = append(ids, id)
+		}
+		for id := range after[kind] {
+			if _, ok := before[kind][id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			b, inBefore := before[kind][id]
+			a, inAfter := after[kind][id]
+			change := api.ConfigChange{Kind: kind, ID: id}
+			switch {
+			case !inBefore:
+				change.Change = api.ConfigAdded
+				change.After = a.raw
+			case !inAfter:
+				change.Change = api.ConfigRemoved
+				change.Before = b.raw
+			default:
+				change.Fields = changedFields(b.fields, a.fields)
+				if len(change.Fields) == 0 {
+					continue
+				}
+				change.Change = api.ConfigChanged
+				change.Before, change.After = b.raw, a.raw
+			}
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+	return diff, nil
+}
+
+// entity is one entity of a snapshot as JSON, whole and by field
+type entity struct {
+	raw    json.RawMessage
+	fields map[string]json.RawMessage
+}
+
+// entities flattens a snapshot into its entities by kind and ID
+func entities(snap *api.ConfigSnapshot) (map[api.ConfigKind]map[string]entity, error) {
+	out := make(map[api.ConfigKind]map[string]entity, len(kinds))
+	for _, kind := range kinds {
+		out[kind] = map[string]entity{}
+	}
+	if snap == nil {
+		return out, nil
+	}
+	add := func(kind api.ConfigKind, id string, v any, drop ...string) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s %s: %w", kind, id, err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return fmt.Errorf("failed to unmarshal %s %s: %w", kind, id, err)
+		}
+		if len(drop) > 0 {
+			for _, name := range drop {
+				delete(fields, name)
+			}
+			if b, err = json.Marshal(fields); err != nil {
+				return fmt.Errorf("failed to marshal %s %s: %w", kind, id, err)
+			}
+		}
+		out[kind][id] = entity{raw: b, fields: fields}
+		return nil
+	}
+
+	// Versions count edits rather than describe configuration, so are not compared
+	for _, dev := range snap.Devices {
+		if err := add(api.ConfigKindDevice, dev.ID, dev, "sensors", "actuators", "version"); err != nil {
+			return nil, err
+		}
+		for _, sensor := range dev.Sensors {
+			if err := add(api.ConfigKindSensor, dev.ID+"/"+sensor.ID, sensor, "version"); err != nil {
+				return nil, err
+			}
+		}
+		for _, actuator := range dev.Actuators {
+			if err := add(api.ConfigKindActuator, dev.ID+"/"+actuator.ID, actuator, "version"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, alias := range snap.TagAliases {
+		if err := add(api.ConfigKindTagAlias, alias.Alias, alias); err != nil {
+			return nil, err
+		}
+	}
+	for _, target := range snap.Targets {
+		if err := add(api.ConfigKindTarget, targetID(target), target); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// changedFields lists, in order, the fields set differently in before and after
+func changedFields(before, after map[string]json.RawMessage) []string {
+	var fields []string
+	for name, b := range before {
+		if a, ok := after[name]; !ok || string(a) != string(b) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func targetID(target *api.TargetRange) string {
+	return target.DeviceID + "/" + target.SensorID
+}
@@ -0,0 +1,164 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+func ptr(v float64) *float64 {
+	return &v
+}
+
+func TestTake(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "tank", Driver: api.DriverShelly, Name: "Tank", Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+			{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+		}},
+		{ID: "heater", Driver: api.DriverShelly, Name: "Heater"},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "water", Target: "sensor.tank.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+
+	snap, err := Take(ctx, store)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if len(snap.Devices) != 2 || snap.Devices[0].ID != "heater" || snap.Devices[1].ID != "tank" {
+		t.Fatalf("Expected devices ordered by ID, got %+v", snap.Devices)
+	}
+	if sensors := snap.Devices[1].Sensors; len(sensors) != 2 || sensors[0].ID != "ph" {
+		t.Errorf("Expected the tank's sensors ordered by ID, got %+v", sensors)
+	}
+	if snap.Devices[0].Sensors == nil || len(snap.TagAliases) != 1 || snap.Targets == nil {
+		t.Errorf("Expected empty lists rather than nulls, got %+v", snap)
+	}
+
+	diff, err := Diff(snap, snap)
+	if err != nil || len(diff.Changes) != 0 {
+		t.Errorf("Expected no changes between a snapshot and itself, got %+v, %v", diff, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := &api.ConfigSnapshot{
+		Devices: []*api.Device{
+			{ID: "tank", Name: "Tank", Sensors: []*api.Sensor{
+				{ID: "temp", DeviceID: "tank", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+			}},
+			{ID: "old-pump", Name: "Old pump"},
+		},
+		Targets: []*api.TargetRange{{DeviceID: "tank", SensorID: "temp", Ideal: api.Band{Min: ptr(24), Max: ptr(26)}}},
+	}
+	to := &api.ConfigSnapshot{
+		Devices: []*api.Device{
+			{ID: "tank", Name: "Tank", Description: "Display tank", Version: 2, Sensors: []*api.Sensor{
+				{ID: "temp", DeviceID: "tank", Name: "Water temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"water"}, Version: 3},
+				{ID: "ph", DeviceID: "tank", Name: "pH", SensorType: api.SensorTypePH},
+			}},
+		},
+		TagAliases: []*api.TagAlias{{Alias: "water", Target: "sensor.tank.temp"}},
+		Targets:    []*api.TargetRange{{DeviceID: "tank", SensorID: "temp", Ideal: api.Band{Min: ptr(24), Max: ptr(26)}}},
+	}
+
+	diff, err := Diff(from, to)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	want := []struct {
+		kind   api.ConfigKind
+		id     string
+		change api.ConfigChangeType
+	}{
+		{api.ConfigKindDevice, "old-pump", api.ConfigRemoved},
+		{api.ConfigKindDevice, "tank", api.ConfigChanged},
+		{api.ConfigKindSensor, "tank/ph", api.ConfigAdded},
+		{api.ConfigKindSensor, "tank/temp", api.ConfigChanged},
+		{api.ConfigKindTagAlias, "water", api.ConfigAdded},
+	}
+	if len(diff.Changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), diff.Changes)
+	}
+	for i, w := range want {
+		c := diff.Changes[i]
+		if c.Kind != w.kind || c.ID != w.id || c.Change != w.change {
+			t.Errorf("Expected change %d to be %s %s %s, got %s %s %s", i+1, w.kind, w.id, w.change, c.Kind, c.ID, c.Change)
+		}
+	}
+	if fields := diff.Changes[1].Fields; len(fields) != 1 || fields[0] != "description" {
+		t.Errorf("Expected only the device description to change, got %v", fields)
+	}
+	if fields := diff.Changes[3].Fields; len(fields) != 2 || fields[0] != "name" || fields[1] != "tags" {
+		t.Errorf("Expected the sensor name and tags to change, got %v", fields)
+	}
+	if diff.Changes[0].Before == nil || diff.Changes[0].After != nil {
+		t.Errorf("Expected a removal to show only the entity before, got %+v", diff.Changes[0])
+	}
+}
+
+func TestRestore(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "tank", Driver: api.DriverShelly, Name: "Tank", Sensors: []*api.Sensor{
+		{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+	}}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if err := store.CreateDevice(ctx, &api.Device{ID: "spare", Driver: api.DriverShelly, Name: "Spare"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	// Written by hand: no versions, tags, external IDs or nested device IDs
+	snap := &api.ConfigSnapshot{
+		Devices: []*api.Device{
+			{ID: "tank", Driver: api.DriverShelly, Name: "Display tank", Sensors: []*api.Sensor{
+				{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature},
+				{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+			}},
+			{ID: "heater", Driver: api.DriverShelly, Name: "Heater", Actuators: []*api.Actuator{
+				{ID: "relay", Name: "Relay", ActuatorType: api.ActuatorTypeRelay},
+			}},
+		},
+		TagAliases: []*api.TagAlias{{Alias: "water", Target: "sensor.tank.temp"}},
+		Targets:    []*api.TargetRange{{DeviceID: "tank", SensorID: "temp", Ideal: api.Band{Min: ptr(24), Max: ptr(26)}}},
+	}
+	applied, err := Restore(ctx, store, snap)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if len(applied.Changes) != 6 {
+		t.Fatalf("Expected 6 changes, got %+v", applied.Changes)
+	}
+
+	dev, err := store.GetDevice(ctx, "tank")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if dev.Name != "Display tank" || dev.Version != 2 || len(dev.Sensors) != 2 {
+		t.Errorf("Expected the tank to be updated with a new sensor, got %+v", dev)
+	}
+	if _, err := store.GetActuator(ctx, "heater", "relay"); err != nil {
+		t.Errorf("Expected the heater's relay to be created, got %v", err)
+	}
+	if _, err := store.GetDevice(ctx, "spare"); err != nil {
+		t.Errorf("Expected a device missing from the snapshot to be kept, got %v", err)
+	}
+
+	applied, err = Restore(ctx, store, snap)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if len(applied.Changes) != 0 {
+		t.Errorf("Expected restoring again to change nothing, got %+v", applied.Changes)
+	}
+}
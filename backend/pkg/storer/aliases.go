@@ -0,0 +1,81 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// SetTagAlias creates the alias or points an existing alias at a new target
+func (s *Storer) SetTagAlias(ctx context.Context, alias *api.TagAlias) error {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias.Alias).Str("target", alias.Target).Msg("setting tag alias")
+	query := `
+		INSERT INTO tag_aliases (alias, target, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (alias) DO UPDATE SET target = EXCLUDED.target, updated_at = NOW()
+	`
+	if _, err := s.db.ExecContext(ctx, query, alias.Alias, alias.Target); err != nil {
+		return fmt.Errorf("failed to set tag alias: %w", err)
+	}
+	return nil
+}
+
+// GetTagAlias retrieves an alias by name
+func (s *Storer) GetTagAlias(ctx context.Context, alias string) (*api.TagAlias, error) {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias).Msg("getting tag alias")
+	query := `SELECT alias, target FROM tag_aliases WHERE alias = $1`
+
+	var a api.TagAlias
+	err := s.db.QueryRowContext(ctx, query, alias).Scan(&a.Alias, &a.Target)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: tag alias %s", ErrNotFound, alias)
+		}
+		return nil, fmt.Errorf("failed to get tag alias: %w", err)
+	}
+	return &a, nil
+}
+
+// DeleteTagAlias deletes an alias; the tag it pointed at is unaffected
+func (s *Storer) DeleteTagAlias(ctx context.Context, alias string) error {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias).Msg("deleting tag alias")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM tag_aliases WHERE alias = $1`, alias)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag alias: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: tag alias %s", ErrNotFound, alias)
+	}
+	return nil
+}
+
+// ListTagAliases retrieves all aliases, ordered by alias
+func (s *Storer) ListTagAliases(ctx context.Context) ([]*api.TagAlias, error) {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Msg("listing tag aliases")
+	rows, err := s.db.QueryContext(ctx, `SELECT alias, target FROM tag_aliases ORDER BY alias`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []*api.TagAlias
+	for rows.Next() {
+		var a api.TagAlias
+		if err := rows.Scan(&a.Alias, &a.Target); err != nil {
+			return nil, fmt.Errorf("failed to scan tag alias: %w", err)
+		}
+		aliases = append(aliases, &a)
+	}
+	return aliases, rows.Err()
+}
@@ -0,0 +1,129 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AssetFilters narrows ListAssets; zero values are ignored
+type AssetFilters struct {
+	DeviceID  string
+	Subsystem string
+}
+
+const assetColumns = `id, device_id, subsystem, kind, filename, content_type, size, width, height, thumbnail_type, created_at`
+
+// assetsQuery selects the assets matching filters, oldest first
+func assetsQuery(filters AssetFilters) squirrel.SelectBuilder {
+	q := builder.Select(assetColumns).From("assets")
+	if filters.DeviceID != "" {
+		q = q.Where(squirrel.Eq{"device_id": filters.DeviceID})
+	}
+	if filters.Subsystem != "" {
+		q = q.Where(squirrel.Eq{"subsystem": filters.Subsystem})
+	}
+	return q.OrderBy("created_at", "id")
+}
+
+// CreateAsset records an attached image whose blobs have been stored under its ID
+func (s *Storer) CreateAsset(ctx context.Context, asset *api.Asset) error {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", asset.ID).Str("device_id", asset.DeviceID).Str("subsystem", asset.Subsystem).Msg("creating asset")
+	query := `
+		INSERT INTO assets (` + assetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		RETURNING created_at
+	`
+	err := s.db.QueryRowContext(ctx, query, asset.ID, nullString(asset.DeviceID), nullString(asset.Subsystem),
+		asset.Kind, asset.Filename, asset.ContentType, asset.Size, asset.Width, asset.Height, asset.ThumbnailType).
+		Scan(&asset.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: device %s", ErrNotFound, asset.DeviceID)
+			}
+		}
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	return nil
+}
+
+// GetAsset retrieves an asset by ID
+func (s *Storer) GetAsset(ctx context.Context, id string) (*api.Asset, error) {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", id).Msg("getting asset")
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+assetColumns+` FROM assets WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+	assets, err := scanAssets(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	return assets[0], nil
+}
+
+// ListAssets retrieves the assets matching filters, oldest first
+func (s *Storer) ListAssets(ctx context.Context, filters AssetFilters) ([]*api.Asset, error) {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("subsystem", filters.Subsystem).Msg("listing assets")
+	query, args, err := assetsQuery(filters).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build assets query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assets: %w", err)
+	}
+	return scanAssets(rows)
+}
+
+// DeleteAsset deletes an asset's record; its blobs are the caller's to remove
+func (s *Storer) DeleteAsset(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", id).Msg("deleting asset")
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM assets WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+func scanAssets(rows *sql.Rows) ([]*api.Asset, error) {
+	defer rows.Close()
+	var assets []*api.Asset
+	for rows.Next() {
+		var a api.Asset
+		var deviceID, subsystem sql.NullString
+		if err := rows.Scan(&a.ID, &deviceID, &subsystem, &a.Kind, &a.Filename, &a.ContentType,
+			&a.Size, &a.Width, &a.Height, &a.ThumbnailType, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan asset: %w", err)
+		}
+		a.DeviceID, a.Subsystem = deviceID.String, subsystem.String
+		assets = append(assets, &a)
+	}
+	return assets, rows.Err()
+}
@@ -0,0 +1,217 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// AuditFilters narrows ListAuditEntries; zero values are ignored
+type AuditFilters struct {
+	EntityType api.AuditEntityType
+	EntityID   string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	// Actions keeps only entries with one of these actions
+	Actions []api.AuditAction
+	// Limit keeps only the most recent entries
+	Limit int
+}
+
+// auditQuery selects the most recent audit entries matching filters, latest first
+func auditQuery(filters AuditFilters, toArg func(time.Time) any) squirrel.SelectBuilder {
+	q := builder.Select("id", "entity_type", "entity_id", "action", "actor", "source", "before", "after", "timestamp").
+		From("audit_log")
+	if filters.EntityType != "" {
+		q = q.Where(squirrel.Eq{"entity_type": filters.EntityType})
+	}
+	if filters.EntityID != "" {
+		q = q.Where(squirrel.Eq{"entity_id": filters.EntityID})
+	}
+	if filters.StartTime != nil {
+		q = q.Where(squirrel.GtOrEq{"timestamp": toArg(*filters.StartTime)})
+	}
+	if filters.EndTime != nil {
+		q = q.Where(squirrel.Lt{"timestamp": toArg(*filters.EndTime)})
+	}
+	if len(filters.Actions) > 0 {
+		q = q.Where(squirrel.Eq{"action": filters.Actions})
+	}
+	q = q.OrderBy("id DESC")
+	if filters.Limit > 0 {
+		q = q.Limit(uint64(filters.Limit))
+	}
+	return q
+}
+
+// newAuditEntry builds the audit entry for a change to an entity identified by keys,
+// the device ID and, for sensors and actuators, their ID. The action follows from which
+// of before and after are nil.
+func newAuditEntry(ctx context.Context, typ api.AuditEntityType, keys []string, before, after json.RawMessage) *api.AuditEntry {
+	entry := &api.AuditEntry{
+		EntityType: typ,
+		EntityID:   strings.Join(keys, "/"),
+		Action:     api.AuditActionUpdated,
+		Actor:      api.ActorFrom(ctx),
+		Before:     before,
+		After:      after,
+	}
+	if entry.Actor != "" {
+		entry.Source = api.CommandSourceUser
+	}
+	switch {
+	case before == nil:
+		entry.Action = api.AuditActionCreated
+	case after == nil:
+		entry.Action = api.AuditActionDeleted
+	}
+	return entry
+}
+
+// commandAuditEntry builds the audit entry for a recorded actuator command, attributed
+// to the command's origin
+func commandAuditEntry(rec *api.CommandRecord) (*api.AuditEntry, error) {
+	after, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal command: %w", err)
+	}
+	return &api.AuditEntry{
+		EntityType: api.AuditEntityActuator,
+		EntityID:   rec.DeviceID + "/" + rec.ActuatorID,
+		Action:     api.AuditActionCommanded,
+		Actor:      rec.Origin.Name,
+		Source:     rec.Origin.Source,
+		After:      after,
+	}, nil
+}
+
+// auditSnapshots select an audited entity as JSON, in the shape of its API type; $1 is
+// the device ID and $2 the sensor or actuator ID
+var auditSnapshots = map[api.AuditEntityType]string{
+	api.AuditEntityDevice:   `SELECT to_jsonb(t) - 'created_at' - 'updated_at' FROM devices t WHERE id = $1`,
+	api.AuditEntitySensor:   `SELECT to_jsonb(t) - 'created_at' - 'updated_at' FROM sensors t WHERE device_id = $1 AND id = $2`,
+	api.AuditEntityActuator: `SELECT to_jsonb(t) - 'created_at' - 'updated_at' FROM actuators t WHERE device_id = $1 AND id = $2`,
+}
+
+// snapshot returns an audited entity as stored within tx, or nil if it does not exist
+func (s *Storer) snapshot(ctx context.Context, tx *sql.Tx, typ api.AuditEntityType, keys []string) (json.RawMessage, error) {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	var data []byte
+	err := tx.QueryRowContext(ctx, auditSnapshots[typ], args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %w", typ, err)
+	}
+	return data, nil
+}
+
+// audited runs write within tx and records its effect on the entity identified by keys
+// in the audit log
+func (s *Storer) audited(ctx context.Context, tx *sql.Tx, typ api.AuditEntityType, keys []string, write func() error) error {
+	before, err := s.snapshot(ctx, tx, typ, keys)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := s.snapshot(ctx, tx, typ, keys)
+	if err != nil {
+		return err
+	}
+	return s.recordAudit(ctx, tx, newAuditEntry(ctx, typ, keys, before, after))
+}
+
+// recordAudit appends entry to the audit log within tx
+func (s *Storer) recordAudit(ctx context.Context, tx *sql.Tx, entry *api.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (entity_type, entity_id, action, actor, source, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := tx.ExecContext(ctx, query, entry.EntityType, entry.EntityID, entry.Action, entry.Actor, entry.Source,
+		nullJSON(entry.Before), nullJSON(entry.After)); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditedTx runs write in a transaction of its own, recording its effect on the entity
+// identified by keys in the audit log
+func (s *Storer) auditedTx(ctx context.Context, typ api.AuditEntityType, keys []string, write func(tx *sql.Tx) error) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.audited(ctx, tx.Tx, typ, keys, func() error { return write(tx.Tx) }); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// nullJSON is data as a column value, NULL if there is none
+func nullJSON(data json.RawMessage) interface{} {
+	if data == nil {
+		return nil
+	}
+	return []byte(data)
+}
+
+// ListAuditEntries returns audit entries matching filters, oldest first
+func (s *Storer) ListAuditEntries(ctx context.Context, filters AuditFilters) ([]*api.AuditEntry, error) {
+	ll := s.logCtx(ctx, "audit")
+	ll.Debug().Str("entity_type", string(filters.EntityType)).Str("entity_id", filters.EntityID).Msg("listing audit entries")
+
+	// Select the most recent entries, then put them back in chronological order
+	query, args, err := builder.Select("*").
+		FromSelect(auditQuery(filters, pgTime), "recent").
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit log query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*api.AuditEntry
+	for rows.Next() {
+		var entry api.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.EntityType, &entry.EntityID, &entry.Action, &entry.Actor, &entry.Source,
+			&before, &after, &entry.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if before != nil {
+			entry.Before = before
+		}
+		if after != nil {
+			entry.After = after
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+	return entries, nil
+}
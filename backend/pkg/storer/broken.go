@@ -0,0 +1,96 @@
+package storer
+
+import (
+	"context"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/lib/pq"
+)
+
+// AddBrokenReferences records rules left depending on deleted resources; a reference
+// already recorded is refreshed
+func (s *Storer) AddBrokenReferences(ctx context.Context, refs []*api.BrokenReference) error {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Int("count", len(refs)).Msg("adding broken references")
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO broken_references (kind, name, tag, resource, detected_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, name, tag) DO UPDATE SET resource = EXCLUDED.resource, detected_at = EXCLUDED.detected_at
+	`
+	for _, ref := range refs {
+		if _, err := tx.ExecContext(ctx, query, ref.Kind, ref.Name, ref.Tag, ref.Resource, ref.DetectedAt); err != nil {
+			return fmt.Errorf("failed to add broken reference: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListBrokenReferences retrieves all broken references, ordered by rule
+func (s *Storer) ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Msg("listing broken references")
+	query := `
+		SELECT kind, name, tag, resource, detected_at
+		FROM broken_references
+		ORDER BY kind, name, tag
+	`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query broken references: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []*api.BrokenReference
+	for rows.Next() {
+		var ref api.BrokenReference
+		if err := rows.Scan(&ref.Kind, &ref.Name, &ref.Tag, &ref.Resource, &ref.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan broken reference: %w", err)
+		}
+		refs = append(refs, &ref)
+	}
+	return refs, rows.Err()
+}
+
+// DeleteBrokenReferences clears the broken references of one rule, re-enabling it, and
+// returns how many were cleared
+func (s *Storer) DeleteBrokenReferences(ctx context.Context, kind, name string) (int64, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Str("kind", kind).Str("name", name).Msg("deleting broken references")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM broken_references WHERE kind = $1 AND name = $2`, kind, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete broken references: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+// ResolveBrokenReferences clears broken references to any of tags, e.g. once a resource
+// carrying them has been recreated, and returns how many were cleared
+func (s *Storer) ResolveBrokenReferences(ctx context.Context, tags []string) (int64, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Strs("tags", tags).Msg("resolving broken references")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM broken_references WHERE tag = ANY($1)`, pq.Array(tags))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve broken references: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,244 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// changeLockKey is the advisory lock serializing sequenceChanges across every process
+// sharing the database. Writers insert events without a sequence number, and only
+// committed events are numbered, so a consumer never reads past an event still to commit.
+const changeLockKey = 0x6c73636867 // "lschg"
+
+// changeSequenceInterval is how often relayChanges looks for changes to number without
+// being woken by a commit, catching those whose commit didn't wake it
+const changeSequenceInterval = time.Second
+
+// newChange builds the change feed event for an entity, with data as its payload
+func newChange(typ api.ChangeType, entityID string, data any) (*api.ChangeEvent, error) {
+	change := &api.ChangeEvent{Type: typ, EntityID: entityID}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal change data: %w", err)
+		}
+		change.Data = b
+	}
+	return change, nil
+}
+
+// readingEntityID identifies a reading's sensor in the change feed
+func readingEntityID(rec *api.ReadingRecord) string {
+	return rec.DeviceID + "/" + rec.SensorID
+}
+
+// commandChanges are the change feed events for a recorded command: the state the
+// actuator reported, if the command succeeded
+func commandChanges(rec *api.CommandRecord) ([]*api.ChangeEvent, error) {
+	if rec.State == nil {
+		return nil, nil
+	}
+	change, err := newChange(api.ChangeActuatorState, rec.DeviceID+"/"+rec.ActuatorID, rec)
+	if err != nil {
+		return nil, err
+	}
+	return []*api.ChangeEvent{change}, nil
+}
+
+// recordChanges appends changes to the outbox within tx. They are numbered by
+// relayChanges once tx commits, so writers take no lock.
+func (s *Storer) recordChanges(ctx context.Context, tx *sql.Tx, changes ...*api.ChangeEvent) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	const columns = 3
+	for start := 0; start < len(changes); start += maxInsertParams / columns {
+		chunk := changes[start:min(start+maxInsertParams/columns, len(changes))]
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*columns)
+		for i, change := range chunk {
+			n := i * columns
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+			var data interface{}
+			if change.Data != nil {
+				data = []byte(change.Data)
+			}
+			args = append(args, change.Type, change.EntityID, data)
+		}
+		query := `INSERT INTO change_events (type, entity_id, data) VALUES ` + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to record changes: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordChange appends a change to the feed for entities, such as alerts, that are not
+// otherwise stored. Seq and Timestamp are assigned by the store.
+func (s *Storer) RecordChange(ctx context.Context, change *api.ChangeEvent) error {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Str("type", string(change.Type)).Str("entity_id", change.EntityID).Msg("recording change")
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.recordChanges(ctx, tx.Tx, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// relayChanges numbers committed changes until the store is closed, woken by every
+// commit and at least every changeSequenceInterval. Consumers only read numbered changes,
+// so reading the feed never writes.
+func (s *Storer) relayChanges() {
+	ll := s.logCtx(context.Background(), "changes")
+	ticker := time.NewTicker(changeSequenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.committed:
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), changeSequenceInterval)
+		if err := s.sequenceChanges(ctx); err != nil {
+			// The changes are numbered on a later pass
+			ll.Warn().Err(err).Msg("failed to sequence changes")
+		}
+		cancel()
+	}
+}
+
+// sequenceChanges numbers the committed changes still without a Seq, in the order they
+// were recorded. Numbering runs one transaction at a time across every process, so the
+// numbered changes are always a prefix of the feed.
+func (s *Storer) sequenceChanges(ctx context.Context) error {
+	var pending bool
+	if err := s.dbPool.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM change_events WHERE seq IS NULL)`).Scan(&pending); err != nil {
+		return fmt.Errorf("failed to check for unsequenced changes: %w", err)
+	}
+	if !pending {
+		return nil
+	}
+
+	tx, err := s.dbPool.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, changeLockKey); err != nil {
+		return fmt.Errorf("failed to lock change feed: %w", err)
+	}
+	query := `
+		WITH numbered AS (
+			SELECT id, nextval(pg_get_serial_sequence('change_events', 'seq')) AS seq
+			FROM (SELECT id FROM change_events WHERE seq IS NULL ORDER BY id) pending
+		)
+		UPDATE change_events SET seq = numbered.seq
+		FROM numbered
+		WHERE change_events.id = numbered.id
+	`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to sequence changes: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListChanges returns up to limit changes with a Seq greater than after, oldest first
+func (s *Storer) ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error) {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Int64("after", after).Int("limit", limit).Msg("listing changes")
+	query := `
+		SELECT seq, type, entity_id, data, timestamp
+		FROM change_events
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*api.ChangeEvent
+	for rows.Next() {
+		var change api.ChangeEvent
+		var data []byte
+		if err := rows.Scan(&change.Seq, &change.Type, &change.EntityID, &data, &change.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		if data != nil {
+			change.Data = data
+		}
+		changes = append(changes, &change)
+	}
+	return changes, rows.Err()
+}
+
+// LatestChangeSeq returns the Seq of the most recent change, or 0 if the feed is empty,
+// for consumers starting from now rather than the oldest change kept
+func (s *Storer) LatestChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM change_events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to get latest change: %w", err)
+	}
+	return seq, nil
+}
+
+// DeleteOldChanges prunes changes recorded before the cutoff
+func (s *Storer) DeleteOldChanges(ctx context.Context, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Time("before", before).Msg("deleting old changes")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM change_events WHERE timestamp < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old changes: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetChangeCursor returns the Seq of the last change a named consumer handled, or 0 if
+// it has handled none
+func (s *Storer) GetChangeCursor(ctx context.Context, consumer string) (int64, error) {
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT seq FROM change_cursors WHERE consumer = $1`, consumer).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get change cursor: %w", err)
+	}
+	return seq, nil
+}
+
+// SetChangeCursor records that a named consumer has handled every change up to seq
+func (s *Storer) SetChangeCursor(ctx context.Context, consumer string, seq int64) error {
+	query := `
+		INSERT INTO change_cursors (consumer, seq, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (consumer) DO UPDATE SET seq = EXCLUDED.seq, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, consumer, seq); err != nil {
+		return fmt.Errorf("failed to set change cursor: %w", err)
+	}
+	return nil
+}
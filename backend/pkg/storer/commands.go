@@ -0,0 +1,154 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// CommandFilters narrows ListCommands; zero values are ignored
+type CommandFilters struct {
+	DeviceID   string
+	ActuatorID string
+	StartTime  *time.Time
+	EndTime    *time.Time
+	// Limit keeps only the most recent commands
+	Limit int
+}
+
+// commandsQuery selects the most recent commands matching filters, latest first
+func commandsQuery(filters CommandFilters, toArg func(time.Time) any) squirrel.SelectBuilder {
+	q := builder.Select("id", "device_id", "actuator_id", "tag", "source", "source_name", "action", "parameters", "state", "error", "issued_at", "latency_ms").
+		From("command_history")
+	if filters.DeviceID != "" {
+		q = q.Where(squirrel.Eq{"device_id": filters.DeviceID})
+	}
+	if filters.ActuatorID != "" {
+		q = q.Where(squirrel.Eq{"actuator_id": filters.ActuatorID})
+	}
+	if filters.StartTime != nil {
+		q = q.Where(squirrel.GtOrEq{"issued_at": toArg(*filters.StartTime)})
+	}
+	if filters.EndTime != nil {
+		q = q.Where(squirrel.Lt{"issued_at": toArg(*filters.EndTime)})
+	}
+	q = q.OrderBy("issued_at DESC", "id DESC")
+	if filters.Limit > 0 {
+		q = q.Limit(uint64(filters.Limit))
+	}
+	return q
+}
+
+// RecordCommand appends a command to its device's history and sets its ID. The command
+// is also entered in the audit log, attributed to its origin, and the state it reported
+// in the change feed.
+func (s *Storer) RecordCommand(ctx context.Context, rec *api.CommandRecord) error {
+	ll := s.logCtx(ctx, "commands")
+	ll.Debug().Str("device_id", rec.DeviceID).Str("actuator_id", rec.ActuatorID).Msg("recording command")
+
+	params, err := json.Marshal(rec.Command.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command parameters: %w", err)
+	}
+	var state []byte
+	if rec.State != nil {
+		if state, err = json.Marshal(rec.State); err != nil {
+			return fmt.Errorf("failed to marshal actuator state: %w", err)
+		}
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO command_history (device_id, actuator_id, tag, source, source_name, action, parameters, state, error, issued_at, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+	err = tx.QueryRowContext(ctx, query, rec.DeviceID, rec.ActuatorID, rec.Tag, rec.Origin.Source, rec.Origin.Name,
+		rec.Command.Action, params, state, nullString(rec.Error), rec.IssuedAt, rec.LatencyMS).Scan(&rec.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: device %s", ErrNotFound, rec.DeviceID)
+			}
+		}
+		return fmt.Errorf("failed to record command: %w", err)
+	}
+	entry, err := commandAuditEntry(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx.Tx, entry); err != nil {
+		return err
+	}
+	changes, err := commandChanges(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.recordChanges(ctx, tx.Tx, changes...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListCommands returns commands matching filters in the order they were issued
+func (s *Storer) ListCommands(ctx context.Context, filters CommandFilters) ([]*api.CommandRecord, error) {
+	ll := s.logCtx(ctx, "commands")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("actuator_id", filters.ActuatorID).Msg("listing commands")
+
+	// Select the most recent commands, then put them back in chronological order
+	query, args, err := builder.Select("*").
+		FromSelect(commandsQuery(filters, pgTime), "recent").
+		OrderBy("issued_at", "id").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build commands query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commands: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []*api.CommandRecord
+	for rows.Next() {
+		var rec api.CommandRecord
+		var params, state []byte
+		var errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.ActuatorID, &rec.Tag, &rec.Origin.Source, &rec.Origin.Name,
+			&rec.Command.Action, &params, &state, &errMsg, &rec.IssuedAt, &rec.LatencyMS); err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &rec.Command.Parameters); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal command parameters: %w", err)
+			}
+		}
+		if len(state) > 0 {
+			rec.State = &api.ActuatorState{}
+			if err := json.Unmarshal(state, rec.State); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal actuator state: %w", err)
+			}
+		}
+		rec.Error = errMsg.String
+		commands = append(commands, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate commands: %w", err)
+	}
+	return commands, nil
+}
@@ -0,0 +1,110 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SetCredential stores the device's named credential, replacing the secret of an existing
+// one while keeping its ID. It fills in the credential's ID and timestamps.
+func (s *Storer) SetCredential(ctx context.Context, cred *api.SealedCredential) error {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("device_id", cred.DeviceID).Str("name", cred.Name).Msg("setting credential")
+	query := `
+		INSERT INTO credentials (device_id, name, key_id, wrapped_key, ciphertext, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (device_id, name) DO UPDATE SET
+			key_id = EXCLUDED.key_id,
+			wrapped_key = EXCLUDED.wrapped_key,
+			ciphertext = EXCLUDED.ciphertext,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+	err := s.db.QueryRowContext(ctx, query, cred.DeviceID, cred.Name, cred.KeyID, cred.WrappedKey, cred.Ciphertext).
+		Scan(&cred.ID, &cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: device %s", ErrNotFound, cred.DeviceID)
+			}
+		}
+		return fmt.Errorf("failed to set credential: %w", err)
+	}
+	return nil
+}
+
+// GetCredential retrieves a credential, with its sealed secret, by ID
+func (s *Storer) GetCredential(ctx context.Context, id string) (*api.SealedCredential, error) {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("id", id).Msg("getting credential")
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: credential %s", ErrNotFound, id)
+	}
+	query := `
+		SELECT id, device_id, name, key_id, wrapped_key, ciphertext, created_at, updated_at
+		FROM credentials WHERE id = $1
+	`
+	var cred api.SealedCredential
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&cred.ID, &cred.DeviceID, &cred.Name, &cred.KeyID,
+		&cred.WrappedKey, &cred.Ciphertext, &cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: credential %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return &cred, nil
+}
+
+// ListCredentials describes the device's credentials, ordered by name, without their
+// secrets
+func (s *Storer) ListCredentials(ctx context.Context, deviceID string) ([]*api.Credential, error) {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("device_id", deviceID).Msg("listing credentials")
+	query := `
+		SELECT id, device_id, name, key_id, created_at, updated_at
+		FROM credentials WHERE device_id = $1 ORDER BY name
+	`
+	rows, err := s.db.QueryContext(ctx, query, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []*api.Credential
+	for rows.Next() {
+		var cred api.Credential
+		if err := rows.Scan(&cred.ID, &cred.DeviceID, &cred.Name, &cred.KeyID, &cred.CreatedAt, &cred.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan credential: %w", err)
+		}
+		creds = append(creds, &cred)
+	}
+	return creds, rows.Err()
+}
+
+// DeleteCredential deletes a credential and its secret
+func (s *Storer) DeleteCredential(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("id", id).Msg("deleting credential")
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%w: credential %s", ErrNotFound, id)
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM credentials WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: credential %s", ErrNotFound, id)
+	}
+	return nil
+}
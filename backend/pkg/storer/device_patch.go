@@ -0,0 +1,61 @@
+package storer
+
+import (
+	"context"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// PatchDevices applies patch to every device sel matches in a single transaction,
+// returning the matched devices ordered by name. The matches are locked until the
+// transaction ends, so none can change between being matched and patched. A device the
+// patch leaves as it was isn't updated; one that cannot be is left as it was, with its
+// error at its index in the returned slice. The returned error is for the batch as a
+// whole.
+func (s *Storer) PatchDevices(ctx context.Context, sel api.DeviceSelector, patch *api.DevicePatch) ([]*api.Device, []error, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("driver", string(sel.Driver)).Strs("tags", sel.Tags).Msg("patching devices")
+
+	var devices []*api.Device
+	var errs []error
+	err := s.WithTx(ctx, func(tx *Storer) error {
+		// Narrow the devices locked by driver and metadata; tags are matched below
+		query := `
+			SELECT id, driver, name, description, metadata, tags, external_id, version
+			FROM devices
+			WHERE ($1 = '' OR driver = $1)
+				AND ($2::jsonb = '{}' OR metadata @> $2::jsonb)
+			ORDER BY name, id
+			FOR UPDATE
+		`
+		rows, err := tx.db.QueryContext(ctx, query, sel.Driver, metadataFilter(Page{Metadata: sel.Metadata}))
+		if err != nil {
+			return fmt.Errorf("failed to query devices: %w", err)
+		}
+		candidates, err := scanDevices(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, dev := range candidates {
+			if !sel.Matches(dev) {
+				continue
+			}
+			var err error
+			if patch.Apply(dev) {
+				// UpdateDevice is a savepoint within the transaction, so one device
+				// failing leaves the others patched
+				err = tx.UpdateDevice(ctx, dev)
+			}
+			devices = append(devices, dev)
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return devices, errs, nil
+}
@@ -0,0 +1,255 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Merge patches of single devices, sensors and actuators read the entity, apply the
+// patch to it, and write back only the columns whose values it changed, so a client
+// patching one field cannot overwrite another changed concurrently. The write still
+// bumps the version, and is audited and recorded as UpdateX's is.
+
+// deviceColumnsChanged names the columns of the devices table which differ between
+// before and after
+func deviceColumnsChanged(before, after *api.Device) []string {
+	var cols []string
+	if before.Driver != after.Driver {
+		cols = append(cols, "driver")
+	}
+	if before.Name != after.Name {
+		cols = append(cols, "name")
+	}
+	if before.Description != after.Description {
+		cols = append(cols, "description")
+	}
+	return append(cols, entityColumnsChanged(before.Metadata, after.Metadata, before.Tags, after.Tags)...)
+}
+
+// sensorColumnsChanged names the columns of the sensors table which differ between
+// before and after
+func sensorColumnsChanged(before, after *api.Sensor) []string {
+	var cols []string
+	if before.Name != after.Name {
+		cols = append(cols, "name")
+	}
+	if before.SensorType != after.SensorType {
+		cols = append(cols, "sensor_type")
+	}
+	return append(cols, entityColumnsChanged(before.Metadata, after.Metadata, before.Tags, after.Tags)...)
+}
+
+// actuatorColumnsChanged names the columns of the actuators table which differ between
+// before and after
+func actuatorColumnsChanged(before, after *api.Actuator) []string {
+	var cols []string
+	if before.Name != after.Name {
+		cols = append(cols, "name")
+	}
+	if before.ActuatorType != after.ActuatorType {
+		cols = append(cols, "actuator_type")
+	}
+	return append(cols, entityColumnsChanged(before.Metadata, after.Metadata, before.Tags, after.Tags)...)
+}
+
+func entityColumnsChanged(beforeMetadata, afterMetadata map[string]string, beforeTags, afterTags []string) []string {
+	var cols []string
+	if !maps.Equal(beforeMetadata, afterMetadata) {
+		cols = append(cols, "metadata")
+	}
+	if !slices.Equal(beforeTags, afterTags) {
+		cols = append(cols, "tags")
+	}
+	return cols
+}
+
+// patchQuery starts an UPDATE of table setting cols to their values and bumping the
+// version, which it returns; the caller adds updated_at and the WHERE clause
+func patchQuery(table string, cols []string, values map[string]any) squirrel.UpdateBuilder {
+	q := builder.Update(table).Set("version", squirrel.Expr("version + 1")).Suffix("RETURNING version")
+	for _, col := range cols {
+		q = q.Set(col, values[col])
+	}
+	return q
+}
+
+// patchVersion checks an update based on version may apply to an entity now at current
+func patchVersion(what string, version, current int64) error {
+	if version != 0 && version != current {
+		return fmt.Errorf("%w: %s is at version %d", ErrConflict, what, current)
+	}
+	return nil
+}
+
+// patchRow runs an UPDATE built by patchQuery within tx, scanning the new version into
+// version. When no row is updated, versionQuery tells whether the entity is missing or
+// stale.
+func patchRow(ctx context.Context, tx *sql.Tx, q squirrel.UpdateBuilder, version *int64, versionQuery, what string, keys ...any) error {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build %s update: %w", what, err)
+	}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return staleOrMissing(ctx, tx, versionQuery, what, keys...)
+	}
+	if err != nil {
+		// A unique violation from either database is a tag conflict
+		if pqErr, ok := err.(*pq.Error); (ok && pqErr.Code == "23505") || isConflict(err) {
+			return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+		}
+		return fmt.Errorf("failed to update %s: %w", what, err)
+	}
+	return nil
+}
+
+// PatchDevice applies update to the device with id, writing only the columns it
+// changes, and returns the device with its sensors and actuators. The device is locked
+// while it is patched. A device the update leaves as it was isn't written.
+func (s *Storer) PatchDevice(ctx context.Context, id string, update *api.DeviceMergePatch) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", id).Msg("patching device")
+	var dev *api.Device
+	err := s.WithTx(ctx, func(tx *Storer) error {
+		if _, err := tx.db.ExecContext(ctx, `SELECT 1 FROM devices WHERE id = $1 FOR UPDATE`, id); err != nil {
+			return fmt.Errorf("failed to lock device: %w", err)
+		}
+		current, err := tx.GetDevice(ctx, id)
+		if err != nil {
+			return err
+		}
+		what := fmt.Sprintf("device %s", id)
+		if err := patchVersion(what, update.Version, current.Version); err != nil {
+			return err
+		}
+		templates, err := loadTagTemplates(ctx, tx.tx)
+		if err != nil {
+			return err
+		}
+		dev = copyDevice(current)
+		update.Apply(dev)
+		dev.EnsureDefaultTag(templates)
+		cols := deviceColumnsChanged(current, dev)
+		dev.Sensors, dev.Actuators = current.Sensors, current.Actuators
+		if len(cols) == 0 {
+			return nil
+		}
+
+		metadata, err := json.Marshal(dev.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		q := patchQuery("devices", cols, map[string]any{
+			"driver": dev.Driver, "name": dev.Name, "description": dev.Description, "metadata": metadata, "tags": pq.Array(dev.Tags),
+		}).Set("updated_at", squirrel.Expr("NOW()")).Where(squirrel.Eq{"id": id, "version": current.Version})
+		err = s.audited(ctx, tx.tx, api.AuditEntityDevice, []string{id}, func() error {
+			return patchRow(ctx, tx.tx, q, &dev.Version, `SELECT version FROM devices WHERE id = $1`, what, id)
+		})
+		if err != nil {
+			return err
+		}
+		change, err := newChange(api.ChangeDeviceUpdated, id, copyDevice(dev))
+		if err != nil {
+			return err
+		}
+		return s.recordChanges(ctx, tx.tx, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// PatchSensor applies update to a sensor, writing only the columns it changes, and
+// returns the sensor. The sensor is locked while it is patched.
+func (s *Storer) PatchSensor(ctx context.Context, deviceID, sensorID string, update *api.SensorMergePatch) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("patching sensor")
+	var sensor *api.Sensor
+	err := s.WithTx(ctx, func(tx *Storer) error {
+		if _, err := tx.db.ExecContext(ctx, `SELECT 1 FROM sensors WHERE device_id = $1 AND id = $2 FOR UPDATE`, deviceID, sensorID); err != nil {
+			return fmt.Errorf("failed to lock sensor: %w", err)
+		}
+		current, err := tx.GetSensor(ctx, deviceID, sensorID)
+		if err != nil {
+			return err
+		}
+		what := fmt.Sprintf("sensor %s/%s", deviceID, sensorID)
+		if err := patchVersion(what, update.Version, current.Version); err != nil {
+			return err
+		}
+		sensor = copySensor(current)
+		update.Apply(sensor)
+		cols := sensorColumnsChanged(current, sensor)
+		if len(cols) == 0 {
+			return nil
+		}
+
+		metadata, err := json.Marshal(sensor.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		q := patchQuery("sensors", cols, map[string]any{
+			"name": sensor.Name, "sensor_type": sensor.SensorType, "metadata": metadata, "tags": pq.Array(sensor.Tags),
+		}).Set("updated_at", squirrel.Expr("NOW()")).Where(squirrel.Eq{"device_id": deviceID, "id": sensorID, "version": current.Version})
+		return s.audited(ctx, tx.tx, api.AuditEntitySensor, []string{deviceID, sensorID}, func() error {
+			return patchRow(ctx, tx.tx, q, &sensor.Version, `SELECT version FROM sensors WHERE device_id = $1 AND id = $2`, what, deviceID, sensorID)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sensor, nil
+}
+
+// PatchActuator applies update to an actuator, writing only the columns it changes,
+// and returns the actuator. The actuator is locked while it is patched.
+func (s *Storer) PatchActuator(ctx context.Context, deviceID, actuatorID string, update *api.ActuatorMergePatch) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("patching actuator")
+	var actuator *api.Actuator
+	err := s.WithTx(ctx, func(tx *Storer) error {
+		if _, err := tx.db.ExecContext(ctx, `SELECT 1 FROM actuators WHERE device_id = $1 AND id = $2 FOR UPDATE`, deviceID, actuatorID); err != nil {
+			return fmt.Errorf("failed to lock actuator: %w", err)
+		}
+		current, err := tx.GetActuator(ctx, deviceID, actuatorID)
+		if err != nil {
+			return err
+		}
+		what := fmt.Sprintf("actuator %s/%s", deviceID, actuatorID)
+		if err := patchVersion(what, update.Version, current.Version); err != nil {
+			return err
+		}
+		actuator = copyActuator(current)
+		update.Apply(actuator)
+		cols := actuatorColumnsChanged(current, actuator)
+		if len(cols) == 0 {
+			return nil
+		}
+
+		metadata, err := json.Marshal(actuator.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		q := patchQuery("actuators", cols, map[string]any{
+			"name": actuator.Name, "actuator_type": actuator.ActuatorType, "metadata": metadata, "tags": pq.Array(actuator.Tags),
+		}).Set("updated_at", squirrel.Expr("NOW()")).Where(squirrel.Eq{"device_id": deviceID, "id": actuatorID, "version": current.Version})
+		return s.audited(ctx, tx.tx, api.AuditEntityActuator, []string{deviceID, actuatorID}, func() error {
+			return patchRow(ctx, tx.tx, q, &actuator.Version, `SELECT version FROM actuators WHERE device_id = $1 AND id = $2`, what, deviceID, actuatorID)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return actuator, nil
+}
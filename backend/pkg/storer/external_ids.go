@@ -0,0 +1,120 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ensureExternalID assigns a random UUID to an empty external ID and validates one
+// supplied by the caller, e.g. when restoring a resource from another installation
+func ensureExternalID(id *string) error {
+	if *id == "" {
+		*id = uuid.NewString()
+		return nil
+	}
+	if _, err := uuid.Parse(*id); err != nil {
+		return fmt.Errorf("invalid external id %q: %w", *id, err)
+	}
+	return nil
+}
+
+// GetDeviceByExternalID retrieves a device, including its sensors and actuators, by its
+// immutable external ID
+func (s *Storer) GetDeviceByExternalID(ctx context.Context, externalID string) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("external_id", externalID).Msg("getting device by external id")
+	if _, err := uuid.Parse(externalID); err != nil {
+		return nil, fmt.Errorf("%w: device with external id %s", ErrNotFound, externalID)
+	}
+
+	var id string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM devices WHERE external_id = $1`, externalID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: device with external id %s", ErrNotFound, externalID)
+		}
+		return nil, fmt.Errorf("failed to get device by external id: %w", err)
+	}
+	return s.GetDevice(ctx, id)
+}
+
+// GetSensorByExternalID retrieves a sensor by its immutable external ID
+func (s *Storer) GetSensorByExternalID(ctx context.Context, externalID string) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("external_id", externalID).Msg("getting sensor by external id")
+	if _, err := uuid.Parse(externalID); err != nil {
+		return nil, fmt.Errorf("%w: sensor with external id %s", ErrNotFound, externalID)
+	}
+	query := `
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
+		FROM sensors
+		WHERE external_id = $1
+	`
+
+	var sensor api.Sensor
+	var metadataJSON []byte
+	var tags []string
+
+	err := s.db.QueryRowContext(ctx, query, externalID).Scan(
+		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags), &sensor.ExternalID, &sensor.Version,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: sensor with external id %s", ErrNotFound, externalID)
+		}
+		return nil, fmt.Errorf("failed to get sensor by external id: %w", err)
+	}
+
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &sensor.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	sensor.Tags = tags
+	return &sensor, nil
+}
+
+// GetActuatorByExternalID retrieves an actuator by its immutable external ID
+func (s *Storer) GetActuatorByExternalID(ctx context.Context, externalID string) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("external_id", externalID).Msg("getting actuator by external id")
+	if _, err := uuid.Parse(externalID); err != nil {
+		return nil, fmt.Errorf("%w: actuator with external id %s", ErrNotFound, externalID)
+	}
+	query := `
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
+		FROM actuators
+		WHERE external_id = $1
+	`
+
+	var actuator api.Actuator
+	var metadataJSON []byte
+	var tags []string
+
+	err := s.db.QueryRowContext(ctx, query, externalID).Scan(
+		&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags), &actuator.ExternalID, &actuator.Version,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: actuator with external id %s", ErrNotFound, externalID)
+		}
+		return nil, fmt.Errorf("failed to get actuator by external id: %w", err)
+	}
+
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &actuator.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	actuator.Tags = tags
+	return &actuator, nil
+}
@@ -0,0 +1,82 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// SetFeatureFlag creates the flag or replaces an existing one, setting flag.UpdatedAt
+func (s *Storer) SetFeatureFlag(ctx context.Context, flag *api.FeatureFlag) error {
+	ll := s.logCtx(ctx, "feature")
+	ll.Info().Str("flag", flag.Name).Int("percent", flag.Percent).Msg("setting feature flag")
+	query := `
+		INSERT INTO feature_flags (name, description, percent, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (name) DO UPDATE SET
+			description = EXCLUDED.description,
+			percent = EXCLUDED.percent,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	err := s.db.QueryRowContext(ctx, query, flag.Name, flag.Description, flag.Percent).Scan(&flag.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	return nil
+}
+
+// GetFeatureFlag retrieves a flag by name
+func (s *Storer) GetFeatureFlag(ctx context.Context, name string) (*api.FeatureFlag, error) {
+	query := `SELECT name, description, percent, updated_at FROM feature_flags WHERE name = $1`
+
+	var f api.FeatureFlag
+	err := s.db.QueryRowContext(ctx, query, name).Scan(&f.Name, &f.Description, &f.Percent, &f.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: feature flag %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	return &f, nil
+}
+
+// DeleteFeatureFlag deletes a flag, turning its feature off for everyone
+func (s *Storer) DeleteFeatureFlag(ctx context.Context, name string) error {
+	ll := s.logCtx(ctx, "feature")
+	ll.Info().Str("flag", name).Msg("deleting feature flag")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: feature flag %s", ErrNotFound, name)
+	}
+	return nil
+}
+
+// ListFeatureFlags retrieves all flags, ordered by name
+func (s *Storer) ListFeatureFlags(ctx context.Context) ([]*api.FeatureFlag, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name, description, percent, updated_at FROM feature_flags ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []*api.FeatureFlag
+	for rows.Next() {
+		var f api.FeatureFlag
+		if err := rows.Scan(&f.Name, &f.Description, &f.Percent, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		flags = append(flags, &f)
+	}
+	return flags, rows.Err()
+}
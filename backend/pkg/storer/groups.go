@@ -0,0 +1,255 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/lib/pq"
+)
+
+// setGroupDevices replaces the members of group g with g.DeviceIDs. isForeignKey reports
+// whether an error is the database's foreign key violation, from a missing device.
+func setGroupDevices(ctx context.Context, tx *sql.Tx, g *api.Group, isForeignKey func(error) bool) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM device_group_members WHERE group_id = $1`, g.ID); err != nil {
+		return fmt.Errorf("failed to clear group devices: %w", err)
+	}
+	for _, deviceID := range g.DeviceIDs {
+		query := `INSERT INTO device_group_members (group_id, device_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, g.ID, deviceID); err != nil {
+			if isForeignKey(err) {
+				return fmt.Errorf("%w: device %s", ErrNotFound, deviceID)
+			}
+			return fmt.Errorf("failed to add group device: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadGroupDevices fills in the DeviceIDs of groups, or of every group when groups has
+// them all
+func loadGroupDevices(ctx context.Context, db queryer, groups []*api.Group) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	byID := make(map[string]*api.Group, len(groups))
+	for _, g := range groups {
+		g.DeviceIDs = []string{}
+		byID[g.ID] = g
+	}
+	query := `SELECT group_id, device_id FROM device_group_members ORDER BY group_id, device_id`
+	args := []interface{}{}
+	if len(groups) == 1 {
+		query = `SELECT group_id, device_id FROM device_group_members WHERE group_id = $1 ORDER BY device_id`
+		args = append(args, groups[0].ID)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query group devices: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var groupID, deviceID string
+		if err := rows.Scan(&groupID, &deviceID); err != nil {
+			return fmt.Errorf("failed to scan group device: %w", err)
+		}
+		if g, ok := byID[groupID]; ok {
+			g.DeviceIDs = append(g.DeviceIDs, deviceID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate group devices: %w", err)
+	}
+	return nil
+}
+
+func isPQForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503" // foreign_key_violation
+}
+
+// CreateGroup creates a group with its devices in a transaction
+func (s *Storer) CreateGroup(ctx context.Context, g *api.Group) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", g.ID).Msg("creating group")
+	metadata, err := json.Marshal(g.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO device_groups (id, name, description, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+	`
+	if _, err := tx.ExecContext(ctx, query, g.ID, g.Name, g.Description, metadata); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // unique_violation
+			return fmt.Errorf("%w: group with id %s", ErrAlreadyExists, g.ID)
+		}
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	if err := setGroupDevices(ctx, tx.Tx, g, isPQForeignKeyViolation); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetGroup retrieves a group with its devices
+func (s *Storer) GetGroup(ctx context.Context, id string) (*api.Group, error) {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", id).Msg("getting group")
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, description, metadata FROM device_groups WHERE id = $1`, id)
+	g, err := scanGroup(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if err := loadGroupDevices(ctx, s.db, []*api.Group{g}); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// UpdateGroup replaces a group's name, description, metadata and devices
+func (s *Storer) UpdateGroup(ctx context.Context, g *api.Group) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", g.ID).Msg("updating group")
+	metadata, err := json.Marshal(g.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE device_groups
+		SET name = $2, description = $3, metadata = $4, updated_at = NOW()
+		WHERE id = $1
+	`
+	result, err := tx.ExecContext(ctx, query, g.ID, g.Name, g.Description, metadata)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: group %s", ErrNotFound, g.ID)
+	}
+	if err := setGroupDevices(ctx, tx.Tx, g, isPQForeignKeyViolation); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group; its devices are unaffected
+func (s *Storer) DeleteGroup(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", id).Msg("deleting group")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM device_groups WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// ListGroups retrieves all groups with their devices, ordered by name
+func (s *Storer) ListGroups(ctx context.Context) ([]*api.Group, error) {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Msg("listing groups")
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, description, metadata FROM device_groups ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*api.Group
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate groups: %w", err)
+	}
+	if err := loadGroupDevices(ctx, s.db, groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// AddGroupDevice adds a device to a group; adding a member again does nothing
+func (s *Storer) AddGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", groupID).Str("device_id", deviceID).Msg("adding group device")
+	query := `INSERT INTO device_group_members (group_id, device_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, query, groupID, deviceID); err != nil {
+		if isPQForeignKeyViolation(err) {
+			return fmt.Errorf("%w: group %s or device %s", ErrNotFound, groupID, deviceID)
+		}
+		return fmt.Errorf("failed to add group device: %w", err)
+	}
+	return nil
+}
+
+// RemoveGroupDevice removes a device from a group
+func (s *Storer) RemoveGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", groupID).Str("device_id", deviceID).Msg("removing group device")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM device_group_members WHERE group_id = $1 AND device_id = $2`, groupID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to remove group device: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: device %s in group %s", ErrNotFound, deviceID, groupID)
+	}
+	return nil
+}
+
+// scanGroup scans a group's id, name, description and metadata, stored as JSON by
+// PostgreSQL and SQLite alike
+func scanGroup(row rowScanner) (*api.Group, error) {
+	var g api.Group
+	var metadata []byte
+	if err := row.Scan(&g.ID, &g.Name, &g.Description, &metadata); err != nil {
+		return nil, err
+	}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &g.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	return &g, nil
+}
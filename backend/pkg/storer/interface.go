@@ -0,0 +1,158 @@
+package storer
+
+import (
+	"context"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// Interface is the set of storage operations used by the rest of the backend. It is
+// satisfied by the PostgreSQL-backed Storer, by the SQLite store for single-board
+// deployments, and by the in-memory store returned by NewMemory, which lets handler and
+// workflow tests run without a database.
+type Interface interface {
+	Close() error
+	Migrate(ctx context.Context) error
+
+	CreateDevice(ctx context.Context, dev *api.Device) error
+	CreateDevices(ctx context.Context, devs []*api.Device) ([]error, error)
+	GetDevice(ctx context.Context, id string) (*api.Device, error)
+	UpdateDevice(ctx context.Context, dev *api.Device) error
+	PatchDevice(ctx context.Context, id string, update *api.DeviceMergePatch) (*api.Device, error)
+	DeleteDevice(ctx context.Context, id string) error
+	ListDevices(ctx context.Context) ([]*api.Device, error)
+	ListDevicesPage(ctx context.Context, page Page) ([]*api.Device, string, error)
+	GetDeviceByTag(ctx context.Context, tag string) (*api.Device, error)
+	ListDevicesByTagPrefix(ctx context.Context, prefix string) ([]*api.Device, error)
+	GetDeviceByExternalID(ctx context.Context, externalID string) (*api.Device, error)
+	PatchDevices(ctx context.Context, sel api.DeviceSelector, patch *api.DevicePatch) ([]*api.Device, []error, error)
+
+	CreateSensor(ctx context.Context, sensor *api.Sensor) error
+	GetSensor(ctx context.Context, deviceID, sensorID string) (*api.Sensor, error)
+	UpdateSensor(ctx context.Context, sensor *api.Sensor) error
+	PatchSensor(ctx context.Context, deviceID, sensorID string, update *api.SensorMergePatch) (*api.Sensor, error)
+	DeleteSensor(ctx context.Context, deviceID, sensorID string) error
+	ListSensors(ctx context.Context) ([]*api.Sensor, error)
+	ListSensorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Sensor, string, error)
+	ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Sensor, error)
+	GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error)
+	ListSensorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Sensor, error)
+	GetSensorByExternalID(ctx context.Context, externalID string) (*api.Sensor, error)
+
+	CreateActuator(ctx context.Context, actuator *api.Actuator) error
+	GetActuator(ctx context.Context, deviceID, actuatorID string) (*api.Actuator, error)
+	UpdateActuator(ctx context.Context, actuator *api.Actuator) error
+	PatchActuator(ctx context.Context, deviceID, actuatorID string, update *api.ActuatorMergePatch) (*api.Actuator, error)
+	DeleteActuator(ctx context.Context, deviceID, actuatorID string) error
+	ListActuators(ctx context.Context) ([]*api.Actuator, error)
+	ListActuatorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Actuator, string, error)
+	ListActuatorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Actuator, error)
+	GetActuatorByTag(ctx context.Context, tag string) (*api.Actuator, error)
+	ListActuatorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Actuator, error)
+	GetActuatorByExternalID(ctx context.Context, externalID string) (*api.Actuator, error)
+
+	SetTagAlias(ctx context.Context, alias *api.TagAlias) error
+	GetTagAlias(ctx context.Context, alias string) (*api.TagAlias, error)
+	DeleteTagAlias(ctx context.Context, alias string) error
+	ListTagAliases(ctx context.Context) ([]*api.TagAlias, error)
+	RenameTag(ctx context.Context, from, to string) (int64, error)
+	MergeTags(ctx context.Context, from []string, into string) (int64, error)
+	ListTagTree(ctx context.Context, prefix string) (*api.TagNode, error)
+
+	CreateGroup(ctx context.Context, g *api.Group) error
+	GetGroup(ctx context.Context, id string) (*api.Group, error)
+	UpdateGroup(ctx context.Context, g *api.Group) error
+	DeleteGroup(ctx context.Context, id string) error
+	ListGroups(ctx context.Context) ([]*api.Group, error)
+	AddGroupDevice(ctx context.Context, groupID, deviceID string) error
+	RemoveGroupDevice(ctx context.Context, groupID, deviceID string) error
+
+	AddBrokenReferences(ctx context.Context, refs []*api.BrokenReference) error
+	ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error)
+	DeleteBrokenReferences(ctx context.Context, kind, name string) (int64, error)
+	ResolveBrokenReferences(ctx context.Context, tags []string) (int64, error)
+
+	SetSensorTarget(ctx context.Context, target *api.TargetRange) error
+	GetSensorTarget(ctx context.Context, deviceID, sensorID string) (*api.TargetRange, error)
+	DeleteSensorTarget(ctx context.Context, deviceID, sensorID string) error
+	ListSensorTargets(ctx context.Context) ([]*api.TargetRange, error)
+
+	StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error
+	StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error
+	GetSensorReadings(ctx context.Context, filters SensorReadingFilters) ([]*api.ReadingRecord, error)
+	CountSensorReadings(ctx context.Context, filters SensorReadingFilters) (int64, error)
+	GetAggregatedReadings(ctx context.Context, deviceID, sensorID string, interval time.Duration, fns []api.AggregateFunc, start, end time.Time) ([]*api.ReadingBucket, error)
+	GetLatestSensorReading(ctx context.Context, deviceID, sensorID string) (*api.SensorReading, error)
+	GetLatestReadingsForDevice(ctx context.Context, deviceID string) ([]*api.ReadingRecord, error)
+	DeleteOldSensorReadings(ctx context.Context, before time.Time) (int64, error)
+	DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error)
+
+	SetTagTemplate(ctx context.Context, template *api.TagTemplate) error
+	DeleteTagTemplate(ctx context.Context, kind api.TagKind) error
+	ListTagTemplates(ctx context.Context) ([]*api.TagTemplate, error)
+
+	SetRetentionPolicy(ctx context.Context, policy *api.RetentionPolicy) error
+	DeleteRetentionPolicy(ctx context.Context, sensorType api.SensorType) error
+	ListRetentionPolicies(ctx context.Context) ([]*api.RetentionPolicy, error)
+
+	SetStorageQuota(ctx context.Context, quota *api.StorageQuota) error
+	DeleteStorageQuota(ctx context.Context, deviceID, sensorID string) error
+	ListStorageQuotas(ctx context.Context) ([]*api.StorageQuota, error)
+	TableUsage(ctx context.Context) ([]api.TableUsage, error)
+	SensorReadingUsage(ctx context.Context, since time.Time) ([]api.SensorUsage, error)
+	ThinSensorReadings(ctx context.Context, deviceID, sensorID string, before time.Time, interval time.Duration) (int64, error)
+
+	ListSlowQueries(ctx context.Context, limit int) ([]*api.SlowQuery, error)
+	ClearSlowQueries(ctx context.Context) error
+
+	RecordCommand(ctx context.Context, rec *api.CommandRecord) error
+	ListCommands(ctx context.Context, filters CommandFilters) ([]*api.CommandRecord, error)
+
+	CreateReadingLabel(ctx context.Context, label *api.ReadingLabel) error
+	ListReadingLabels(ctx context.Context, filters ReadingLabelFilters) ([]*api.ReadingLabel, error)
+	DeleteReadingLabel(ctx context.Context, id int64) error
+
+	RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error
+	ListRuleTraces(ctx context.Context, kind, name string, limit int) ([]*api.RuleTrace, error)
+
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+	ListLeases(ctx context.Context) ([]*api.Lease, error)
+
+	SetCredential(ctx context.Context, cred *api.SealedCredential) error
+	GetCredential(ctx context.Context, id string) (*api.SealedCredential, error)
+	ListCredentials(ctx context.Context, deviceID string) ([]*api.Credential, error)
+	DeleteCredential(ctx context.Context, id string) error
+
+	CreateAsset(ctx context.Context, asset *api.Asset) error
+	GetAsset(ctx context.Context, id string) (*api.Asset, error)
+	ListAssets(ctx context.Context, filters AssetFilters) ([]*api.Asset, error)
+	DeleteAsset(ctx context.Context, id string) error
+
+	RecordChange(ctx context.Context, change *api.ChangeEvent) error
+	ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error)
+	LatestChangeSeq(ctx context.Context) (int64, error)
+	DeleteOldChanges(ctx context.Context, before time.Time) (int64, error)
+	GetChangeCursor(ctx context.Context, consumer string) (int64, error)
+	SetChangeCursor(ctx context.Context, consumer string, seq int64) error
+
+	GetMaintenanceMode(ctx context.Context) (*api.MaintenanceMode, error)
+	SetMaintenanceMode(ctx context.Context, mode *api.MaintenanceMode) error
+
+	SetFeatureFlag(ctx context.Context, flag *api.FeatureFlag) error
+	GetFeatureFlag(ctx context.Context, name string) (*api.FeatureFlag, error)
+	DeleteFeatureFlag(ctx context.Context, name string) error
+	ListFeatureFlags(ctx context.Context) ([]*api.FeatureFlag, error)
+
+	ListAuditEntries(ctx context.Context, filters AuditFilters) ([]*api.AuditEntry, error)
+
+	GetPumpRuntimes(ctx context.Context, rotation string) (map[string]time.Duration, string, error)
+	SetPumpRuntimes(ctx context.Context, rotation string, runtimes map[string]time.Duration, active string) error
+}
+
+var (
+	_ Interface = (*Storer)(nil)
+	_ Interface = (*SQLite)(nil)
+	_ Interface = (*Memory)(nil)
+)
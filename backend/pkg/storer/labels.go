@@ -0,0 +1,109 @@
+package storer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// ReadingLabelFilters narrows ListReadingLabels; zero values are ignored
+type ReadingLabelFilters struct {
+	Key string
+	// DeviceID and SensorID select the labels covering a device's or sensor's readings,
+	// including labels covering every sensor
+	DeviceID string
+	SensorID string
+	// StartTime and EndTime select labels whose window overlaps [StartTime, EndTime)
+	StartTime *time.Time
+	EndTime   *time.Time
+}
+
+const readingLabelColumns = `id, key, value, device_id, sensor_id, start_time, end_time, note, created_at`
+
+// readingLabelsQuery selects the labels matching filters, ordered by start time
+func readingLabelsQuery(filters ReadingLabelFilters, toArg func(time.Time) any) squirrel.SelectBuilder {
+	q := builder.Select(readingLabelColumns).From("reading_labels")
+	if filters.Key != "" {
+		q = q.Where(squirrel.Eq{"key": filters.Key})
+	}
+	if filters.DeviceID != "" {
+		q = q.Where("(device_id = '' OR device_id = ?)", filters.DeviceID)
+	}
+	if filters.SensorID != "" {
+		q = q.Where("(sensor_id = '' OR sensor_id = ?)", filters.SensorID)
+	}
+	if filters.StartTime != nil {
+		q = q.Where(squirrel.Gt{"end_time": toArg(*filters.StartTime)})
+	}
+	if filters.EndTime != nil {
+		q = q.Where(squirrel.Lt{"start_time": toArg(*filters.EndTime)})
+	}
+	return q.OrderBy("start_time", "id")
+}
+
+// CreateReadingLabel stores a label, setting its ID and CreatedAt
+func (s *Storer) CreateReadingLabel(ctx context.Context, label *api.ReadingLabel) error {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Str("key", label.Key).Str("value", label.Value).Msg("creating reading label")
+	query := `
+		INSERT INTO reading_labels (key, value, device_id, sensor_id, start_time, end_time, note)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRowContext(ctx, query, label.Key, label.Value, label.DeviceID, label.SensorID,
+		label.Start, label.End, label.Note).Scan(&label.ID, &label.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reading label: %w", err)
+	}
+	return nil
+}
+
+// ListReadingLabels returns labels matching filters, ordered by start time
+func (s *Storer) ListReadingLabels(ctx context.Context, filters ReadingLabelFilters) ([]*api.ReadingLabel, error) {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Str("key", filters.Key).Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("listing reading labels")
+	query, args, err := readingLabelsQuery(filters, pgTime).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build reading labels query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading labels: %w", err)
+	}
+	defer rows.Close()
+
+	var labels []*api.ReadingLabel
+	for rows.Next() {
+		var l api.ReadingLabel
+		if err := rows.Scan(&l.ID, &l.Key, &l.Value, &l.DeviceID, &l.SensorID, &l.Start, &l.End, &l.Note, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reading label: %w", err)
+		}
+		labels = append(labels, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reading labels: %w", err)
+	}
+	return labels, nil
+}
+
+// DeleteReadingLabel removes a label; the readings it covered are unaffected
+func (s *Storer) DeleteReadingLabel(ctx context.Context, id int64) error {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Int64("id", id).Msg("deleting reading label")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM reading_labels WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete reading label: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: reading label %d", ErrNotFound, id)
+	}
+	return nil
+}
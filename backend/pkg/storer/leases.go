@@ -0,0 +1,78 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// AcquireLease takes the named lease for holder until ttl from now, or renews it if
+// holder already has it. It returns ErrLeaseHeld while another holder's lease is
+// unexpired. Expiry is judged by the database clock, so workers' clocks needn't agree.
+func (s *Storer) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error) {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Str("name", name).Str("holder", holder).Msg("acquiring lease")
+	query := `
+		INSERT INTO leases (name, holder, acquired_at, expires_at)
+		VALUES ($1, $2, NOW(), NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET
+			holder = EXCLUDED.holder,
+			acquired_at = CASE WHEN leases.holder = EXCLUDED.holder THEN leases.acquired_at ELSE EXCLUDED.acquired_at END,
+			expires_at = EXCLUDED.expires_at
+		WHERE leases.holder = EXCLUDED.holder OR leases.expires_at < NOW()
+		RETURNING name, holder, acquired_at, expires_at
+	`
+	var lease api.Lease
+	err := s.db.QueryRowContext(ctx, query, name, holder, ttl.Milliseconds()).
+		Scan(&lease.Name, &lease.Holder, &lease.AcquiredAt, &lease.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrLeaseHeld, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return &lease, nil
+}
+
+// ReleaseLease gives up holder's lease so another worker can take over at once
+func (s *Storer) ReleaseLease(ctx context.Context, name, holder string) error {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Str("name", name).Str("holder", holder).Msg("releasing lease")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: lease %s held by %s", ErrNotFound, name, holder)
+	}
+	return nil
+}
+
+// ListLeases returns every lease, including expired ones not yet taken over, by name
+func (s *Storer) ListLeases(ctx context.Context) ([]*api.Lease, error) {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Msg("listing leases")
+	rows, err := s.db.QueryContext(ctx, `SELECT name, holder, acquired_at, expires_at FROM leases ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	defer rows.Close()
+
+	var leases []*api.Lease
+	for rows.Next() {
+		var lease api.Lease
+		if err := rows.Scan(&lease.Name, &lease.Holder, &lease.AcquiredAt, &lease.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lease: %w", err)
+		}
+		leases = append(leases, &lease)
+	}
+	return leases, rows.Err()
+}
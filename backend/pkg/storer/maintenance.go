@@ -0,0 +1,43 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// GetMaintenanceMode returns the current maintenance mode, which is off until first set
+func (s *Storer) GetMaintenanceMode(ctx context.Context) (*api.MaintenanceMode, error) {
+	var mode api.MaintenanceMode
+	err := s.db.QueryRowContext(ctx, `SELECT read_only, message, updated_at FROM maintenance_mode WHERE id`).
+		Scan(&mode.ReadOnly, &mode.Message, &mode.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &api.MaintenanceMode{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+	return &mode, nil
+}
+
+// SetMaintenanceMode replaces the maintenance mode, setting mode.UpdatedAt
+func (s *Storer) SetMaintenanceMode(ctx context.Context, mode *api.MaintenanceMode) error {
+	ll := s.logCtx(ctx, "maintenance")
+	ll.Info().Bool("read_only", mode.ReadOnly).Str("message", mode.Message).Msg("setting maintenance mode")
+	query := `
+		INSERT INTO maintenance_mode (id, read_only, message, updated_at)
+		VALUES (TRUE, $1, $2, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			read_only = EXCLUDED.read_only,
+			message = EXCLUDED.message,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	if err := s.db.QueryRowContext(ctx, query, mode.ReadOnly, mode.Message).Scan(&mode.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to set maintenance mode: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,2467 @@
+package storer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/google/uuid"
+)
+
+// componentKey identifies a sensor or actuator within its device
+type componentKey struct {
+	deviceID string
+	id       string
+}
+
+// brokenKey identifies a broken reference by rule and tag
+type brokenKey struct {
+	kind, name, tag string
+}
+
+// memoryReading is a stored reading with its insertion sequence, used to order readings
+// sharing a timestamp the same way the sensor_readings serial id does
+type memoryReading struct {
+	seq int64
+	rec api.ReadingRecord
+}
+
+type memoryTrace struct {
+	kind, name string
+	id         int64
+	data       []byte
+}
+
+// Memory is an in-memory implementation of Interface for tests. It mirrors the
+// PostgreSQL Storer's semantics: default tags, tag uniqueness per entity type, cascading
+// deletes and the ErrNotFound / ErrAlreadyExists errors. Values are copied on the way
+// in and out, so callers cannot mutate stored state.
+type Memory struct {
+	mu        sync.Mutex
+	devices   map[string]*api.Device
+	sensors   map[componentKey]*api.Sensor
+	actuators map[componentKey]*api.Actuator
+	aliases   map[string]string
+	broken    map[brokenKey]api.BrokenReference
+	targets   map[componentKey]*api.TargetRange
+	readings  []memoryReading
+	seq       int64
+	commands  []*api.CommandRecord
+	commandID int64
+	labels    []api.ReadingLabel
+	labelID   int64
+	leases    map[string]api.Lease
+	// credentials are keyed by ID
+	credentials map[string]*api.SealedCredential
+	// assets are keyed by ID
+	assets map[string]api.Asset
+	// changes is the change feed, oldest first
+	changes       []api.ChangeEvent
+	changeSeq     int64
+	changeCursors map[string]int64
+	// audit is the audit log, oldest first
+	audit        []api.AuditEntry
+	auditID      int64
+	maintenance  api.MaintenanceMode
+	features     map[string]api.FeatureFlag
+	groups       map[string]*api.Group
+	retention    map[api.SensorType]api.RetentionPolicy
+	quotas       map[componentKey]api.StorageQuota
+	tagTemplates map[api.TagKind]api.TagTemplate
+	// traces are the rule traces, oldest first, kept encoded so they can't be mutated
+	traces  []memoryTrace
+	traceID int64
+	// now is the store's clock for lease expiry
+	now func() time.Time
+	// runtimes are the pump runtimes of each pump rotation, by rotation and then tag
+	runtimes map[string]map[string]time.Duration
+	// activePumps is the running pump of each pump rotation, by rotation
+	activePumps map[string]string
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		devices:       make(map[string]*api.Device),
+		sensors:       make(map[componentKey]*api.Sensor),
+		actuators:     make(map[componentKey]*api.Actuator),
+		aliases:       make(map[string]string),
+		broken:        make(map[brokenKey]api.BrokenReference),
+		targets:       make(map[componentKey]*api.TargetRange),
+		leases:        make(map[string]api.Lease),
+		credentials:   make(map[string]*api.SealedCredential),
+		assets:        make(map[string]api.Asset),
+		changeCursors: make(map[string]int64),
+		features:      make(map[string]api.FeatureFlag),
+		groups:        make(map[string]*api.Group),
+		retention:     make(map[api.SensorType]api.RetentionPolicy),
+		quotas:        make(map[componentKey]api.StorageQuota),
+		tagTemplates:  make(map[api.TagKind]api.TagTemplate),
+		now:           time.Now,
+		runtimes:      make(map[string]map[string]time.Duration),
+		activePumps:   make(map[string]string),
+	}
+}
+
+// Close is a no-op
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Migrate is a no-op
+func (m *Memory) Migrate(ctx context.Context) error {
+	return nil
+}
+
+// Device operations
+
+// CreateDevice creates a new device with its nested sensors and actuators; nothing is
+// stored if any of them fails
+func (m *Memory) CreateDevice(ctx context.Context, dev *api.Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devices[dev.ID]; ok {
+		return fmt.Errorf("%w: device with id %s", ErrAlreadyExists, dev.ID)
+	}
+	dev.EnsureDefaultTag(m.templates())
+	if m.deviceTagsTaken(dev.ID, dev.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	if err := ensureExternalID(&dev.ExternalID); err != nil {
+		return err
+	}
+	dev.Version = 1
+
+	// Validate nested components before storing anything
+	sensorTags := map[string]bool{}
+	for _, sensor := range dev.Sensors {
+		sensor.DeviceID = dev.ID
+		if len(sensor.Tags) == 0 {
+			sensor.Tags = []string{m.templates().SensorTag(sensor, dev.Metadata)}
+		}
+		if err := ensureExternalID(&sensor.ExternalID); err != nil {
+			return err
+		}
+		sensor.Version = 1
+		if m.sensorTagsTaken(componentKey{dev.ID, sensor.ID}, sensor.Tags) || anyTag(sensorTags, sensor.Tags) {
+			return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+		}
+		for _, tag := range sensor.Tags {
+			sensorTags[tag] = true
+		}
+	}
+	actuatorTags := map[string]bool{}
+	for _, actuator := range dev.Actuators {
+		actuator.DeviceID = dev.ID
+		if len(actuator.Tags) == 0 {
+			actuator.Tags = []string{m.templates().ActuatorTag(actuator, dev.Metadata)}
+		}
+		if err := ensureExternalID(&actuator.ExternalID); err != nil {
+			return err
+		}
+		actuator.Version = 1
+		if m.actuatorTagsTaken(componentKey{dev.ID, actuator.ID}, actuator.Tags) || anyTag(actuatorTags, actuator.Tags) {
+			return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+		}
+		for _, tag := range actuator.Tags {
+			actuatorTags[tag] = true
+		}
+	}
+
+	sensors := make(map[componentKey]*api.Sensor, len(dev.Sensors))
+	for _, sensor := range dev.Sensors {
+		key := componentKey{dev.ID, sensor.ID}
+		if _, ok := sensors[key]; ok {
+			return fmt.Errorf("%w: sensor %s/%s", ErrAlreadyExists, sensor.DeviceID, sensor.ID)
+		}
+		sensors[key] = copySensor(sensor)
+	}
+	actuators := make(map[componentKey]*api.Actuator, len(dev.Actuators))
+	for _, actuator := range dev.Actuators {
+		key := componentKey{dev.ID, actuator.ID}
+		if _, ok := actuators[key]; ok {
+			return fmt.Errorf("%w: actuator %s/%s", ErrAlreadyExists, actuator.DeviceID, actuator.ID)
+		}
+		actuators[key] = copyActuator(actuator)
+	}
+	change, err := newChange(api.ChangeDeviceCreated, dev.ID, dev)
+	if err != nil {
+		return err
+	}
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityDevice, []string{dev.ID}, nil, copyDevice(dev))
+	if err != nil {
+		return err
+	}
+	entries := []*api.AuditEntry{entry}
+	for _, sensor := range dev.Sensors {
+		entry, err := memoryAuditEntry(ctx, api.AuditEntitySensor, []string{dev.ID, sensor.ID}, nil, sensors[componentKey{dev.ID, sensor.ID}])
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	for _, actuator := range dev.Actuators {
+		entry, err := memoryAuditEntry(ctx, api.AuditEntityActuator, []string{dev.ID, actuator.ID}, nil, actuators[componentKey{dev.ID, actuator.ID}])
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	m.devices[dev.ID] = copyDevice(dev)
+	m.appendChanges(change)
+	m.appendAudit(entries...)
+	for key, sensor := range sensors {
+		m.sensors[key] = sensor
+	}
+	for key, actuator := range actuators {
+		m.actuators[key] = actuator
+	}
+	return nil
+}
+
+// CreateDevices creates each of devs as CreateDevice does. A device that cannot be
+// created is skipped, with its error at its index in the returned slice.
+func (m *Memory) CreateDevices(ctx context.Context, devs []*api.Device) ([]error, error) {
+	errs := make([]error, len(devs))
+	for i, dev := range devs {
+		errs[i] = m.CreateDevice(ctx, dev)
+	}
+	return errs, nil
+}
+
+// PatchDevices applies patch to every device sel matches, updating each as UpdateDevice
+// does, and returns the matched devices ordered by name. The lock is held throughout, so
+// none can change between being matched and patched. A device the patch leaves as it
+// was isn't updated; one that cannot be is left as it was, with its error at its index
+// in the returned slice.
+func (m *Memory) PatchDevices(ctx context.Context, sel api.DeviceSelector, patch *api.DevicePatch) ([]*api.Device, []error, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := m.devicesWhere(sel.Matches)
+	errs := make([]error, len(devices))
+	for i, dev := range devices {
+		if patch.Apply(dev) {
+			errs[i] = m.updateDevice(ctx, dev)
+		}
+	}
+	return devices, errs, nil
+}
+
+// PatchDevice applies update to the device with id, updating it as UpdateDevice does
+// unless the update leaves it as it was, and returns it with its sensors and actuators
+func (m *Memory) PatchDevice(ctx context.Context, id string, update *api.DeviceMergePatch) (*api.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.devices[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: device %s", ErrNotFound, id)
+	}
+	if err := patchVersion(fmt.Sprintf("device %s", id), update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	dev := copyDevice(current)
+	update.Apply(dev)
+	dev.EnsureDefaultTag(m.templates())
+	if len(deviceColumnsChanged(current, dev)) > 0 {
+		if err := m.updateDevice(ctx, dev); err != nil {
+			return nil, err
+		}
+	}
+	dev.Sensors = m.sensorsWhere(func(s *api.Sensor) bool { return s.DeviceID == id })
+	dev.Actuators = m.actuatorsWhere(func(a *api.Actuator) bool { return a.DeviceID == id })
+	return dev, nil
+}
+
+// GetDevice retrieves a device by ID, including its sensors and actuators
+func (m *Memory) GetDevice(ctx context.Context, id string) (*api.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dev, ok := m.devices[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: device %s", ErrNotFound, id)
+	}
+	out := copyDevice(dev)
+	out.Sensors = m.sensorsWhere(func(s *api.Sensor) bool { return s.DeviceID == id })
+	out.Actuators = m.actuatorsWhere(func(a *api.Actuator) bool { return a.DeviceID == id })
+	return out, nil
+}
+
+// UpdateDevice updates an existing device; nested sensors and actuators are ignored
+func (m *Memory) UpdateDevice(ctx context.Context, dev *api.Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateDevice(ctx, dev)
+}
+
+// updateDevice is UpdateDevice; the caller must hold m.mu
+func (m *Memory) updateDevice(ctx context.Context, dev *api.Device) error {
+	current, ok := m.devices[dev.ID]
+	if !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, dev.ID)
+	}
+	if current.Version != dev.Version {
+		return fmt.Errorf("%w: device %s is at version %d", ErrConflict, dev.ID, current.Version)
+	}
+	dev.EnsureDefaultTag(m.templates())
+	if m.deviceTagsTaken(dev.ID, dev.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	stored := copyDevice(dev)
+	stored.ExternalID = current.ExternalID
+	stored.Version++
+	change, err := newChange(api.ChangeDeviceUpdated, dev.ID, stored)
+	if err != nil {
+		return err
+	}
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityDevice, []string{dev.ID}, m.devices[dev.ID], stored)
+	if err != nil {
+		return err
+	}
+	m.devices[dev.ID] = stored
+	dev.Version = stored.Version
+	m.appendChanges(change)
+	m.appendAudit(entry)
+	return nil
+}
+
+// DeleteDevice deletes a device along with its sensors, actuators and readings
+func (m *Memory) DeleteDevice(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devices[id]; !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, id)
+	}
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityDevice, []string{id}, m.devices[id], nil)
+	if err != nil {
+		return err
+	}
+	delete(m.devices, id)
+	for key := range m.sensors {
+		if key.deviceID == id {
+			delete(m.sensors, key)
+			delete(m.targets, key)
+			delete(m.quotas, key)
+		}
+	}
+	for key := range m.actuators {
+		if key.deviceID == id {
+			delete(m.actuators, key)
+		}
+	}
+	m.deleteReadingsWhere(func(rec *api.ReadingRecord) bool { return rec.DeviceID == id })
+	kept := m.commands[:0]
+	for _, rec := range m.commands {
+		if rec.DeviceID != id {
+			kept = append(kept, rec)
+		}
+	}
+	m.commands = kept
+	for credID, cred := range m.credentials {
+		if cred.DeviceID == id {
+			delete(m.credentials, credID)
+		}
+	}
+	for assetID, asset := range m.assets {
+		if asset.DeviceID == id {
+			delete(m.assets, assetID)
+		}
+	}
+	for _, g := range m.groups {
+		g.DeviceIDs = slices.DeleteFunc(g.DeviceIDs, func(deviceID string) bool { return deviceID == id })
+	}
+	m.appendChanges(&api.ChangeEvent{Type: api.ChangeDeviceDeleted, EntityID: id})
+	m.appendAudit(entry)
+	return nil
+}
+
+// ListDevices retrieves all devices, ordered by name
+func (m *Memory) ListDevices(ctx context.Context) ([]*api.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.devicesWhere(func(*api.Device) bool { return true }), nil
+}
+
+// ListDevicesPage retrieves a page of devices, ordered by name, and the cursor of the
+// next page
+func (m *Memory) ListDevicesPage(ctx context.Context, page Page) ([]*api.Device, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	devices := m.devicesWhere(func(d *api.Device) bool { return matchesMetadata(d.Metadata, page.Metadata) })
+	return paginate(devices, page, devicePageKey)
+}
+
+// GetDeviceByTag retrieves a device with a specific tag, or the tag an alias points at
+func (m *Memory) GetDeviceByTag(ctx context.Context, tag string) (*api.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := m.devicesWhere(func(d *api.Device) bool { return hasTag(d.Tags, tag) })
+	if target, ok := m.aliases[tag]; ok && len(devices) == 0 {
+		devices = m.devicesWhere(func(d *api.Device) bool { return hasTag(d.Tags, target) })
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("%w: device with tag %s", ErrNotFound, tag)
+	}
+	return devices[0], nil
+}
+
+// ListDevicesByTagPrefix retrieves devices with tags matching a prefix
+func (m *Memory) ListDevicesByTagPrefix(ctx context.Context, prefix string) ([]*api.Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.devicesWhere(func(d *api.Device) bool { return hasTagPrefix(d.Tags, prefix) }), nil
+}
+
+// Sensor operations
+
+// CreateSensor creates a new sensor on an existing device
+func (m *Memory) CreateSensor(ctx context.Context, sensor *api.Sensor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dev, ok := m.devices[sensor.DeviceID]
+	if !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, sensor.DeviceID)
+	}
+	key := componentKey{sensor.DeviceID, sensor.ID}
+	if _, ok := m.sensors[key]; ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrAlreadyExists, sensor.DeviceID, sensor.ID)
+	}
+	if len(sensor.Tags) == 0 {
+		sensor.Tags = []string{m.templates().SensorTag(sensor, dev.Metadata)}
+	}
+	if m.sensorTagsTaken(key, sensor.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	if err := ensureExternalID(&sensor.ExternalID); err != nil {
+		return err
+	}
+	sensor.Version = 1
+	stored := copySensor(sensor)
+	entry, err := memoryAuditEntry(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, nil, stored)
+	if err != nil {
+		return err
+	}
+	m.sensors[key] = stored
+	m.appendAudit(entry)
+	return nil
+}
+
+// GetSensor retrieves a sensor by device ID and sensor ID
+func (m *Memory) GetSensor(ctx context.Context, deviceID, sensorID string) (*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sensor, ok := m.sensors[componentKey{deviceID, sensorID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return copySensor(sensor), nil
+}
+
+// UpdateSensor updates an existing sensor
+func (m *Memory) UpdateSensor(ctx context.Context, sensor *api.Sensor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateSensor(ctx, sensor)
+}
+
+// updateSensor is UpdateSensor; the caller must hold m.mu
+func (m *Memory) updateSensor(ctx context.Context, sensor *api.Sensor) error {
+	key := componentKey{sensor.DeviceID, sensor.ID}
+	current, ok := m.sensors[key]
+	if !ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, sensor.DeviceID, sensor.ID)
+	}
+	if current.Version != sensor.Version {
+		return fmt.Errorf("%w: sensor %s/%s is at version %d", ErrConflict, sensor.DeviceID, sensor.ID, current.Version)
+	}
+	if m.sensorTagsTaken(key, sensor.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	stored := copySensor(sensor)
+	stored.ExternalID = current.ExternalID
+	stored.Version++
+	entry, err := memoryAuditEntry(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, current, stored)
+	if err != nil {
+		return err
+	}
+	m.sensors[key] = stored
+	sensor.Version = stored.Version
+	m.appendAudit(entry)
+	return nil
+}
+
+// PatchSensor applies update to a sensor, updating it as UpdateSensor does unless the
+// update leaves it as it was, and returns it
+func (m *Memory) PatchSensor(ctx context.Context, deviceID, sensorID string, update *api.SensorMergePatch) (*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.sensors[componentKey{deviceID, sensorID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	if err := patchVersion(fmt.Sprintf("sensor %s/%s", deviceID, sensorID), update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	sensor := copySensor(current)
+	update.Apply(sensor)
+	if len(sensorColumnsChanged(current, sensor)) > 0 {
+		if err := m.updateSensor(ctx, sensor); err != nil {
+			return nil, err
+		}
+	}
+	return sensor, nil
+}
+
+// DeleteSensor deletes a sensor and its readings
+func (m *Memory) DeleteSensor(ctx context.Context, deviceID, sensorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{deviceID, sensorID}
+	if _, ok := m.sensors[key]; !ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	entry, err := memoryAuditEntry(ctx, api.AuditEntitySensor, []string{deviceID, sensorID}, m.sensors[key], nil)
+	if err != nil {
+		return err
+	}
+	m.appendAudit(entry)
+	delete(m.sensors, key)
+	delete(m.targets, key)
+	delete(m.quotas, key)
+	m.deleteReadingsWhere(func(rec *api.ReadingRecord) bool {
+		return rec.DeviceID == deviceID && rec.SensorID == sensorID
+	})
+	return nil
+}
+
+// ListSensors retrieves all sensors, ordered by name
+func (m *Memory) ListSensors(ctx context.Context) ([]*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sensorsWhere(func(*api.Sensor) bool { return true }), nil
+}
+
+// ListSensorsPage retrieves a page of sensors, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the sensors of every device.
+func (m *Memory) ListSensorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Sensor, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sensors := m.sensorsWhere(func(s *api.Sensor) bool {
+		return (deviceID == "" || s.DeviceID == deviceID) && matchesMetadata(s.Metadata, page.Metadata)
+	})
+	return paginate(sensors, page, sensorPageKey)
+}
+
+// ListSensorsByDeviceID retrieves all sensors for a device
+func (m *Memory) ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sensorsWhere(func(s *api.Sensor) bool { return s.DeviceID == deviceID }), nil
+}
+
+// GetSensorByTag retrieves a sensor with a specific tag, or the tag an alias points at
+func (m *Memory) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sensors := m.sensorsWhere(func(s *api.Sensor) bool { return hasTag(s.Tags, tag) })
+	if target, ok := m.aliases[tag]; ok && len(sensors) == 0 {
+		sensors = m.sensorsWhere(func(s *api.Sensor) bool { return hasTag(s.Tags, target) })
+	}
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("%w: sensor with tag %s", ErrNotFound, tag)
+	}
+	return sensors[0], nil
+}
+
+// ListSensorsByTagPrefix retrieves sensors with tags matching a prefix
+func (m *Memory) ListSensorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sensorsWhere(func(s *api.Sensor) bool { return hasTagPrefix(s.Tags, prefix) }), nil
+}
+
+// Actuator operations
+
+// CreateActuator creates a new actuator on an existing device
+func (m *Memory) CreateActuator(ctx context.Context, actuator *api.Actuator) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dev, ok := m.devices[actuator.DeviceID]
+	if !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, actuator.DeviceID)
+	}
+	key := componentKey{actuator.DeviceID, actuator.ID}
+	if _, ok := m.actuators[key]; ok {
+		return fmt.Errorf("%w: actuator %s/%s", ErrAlreadyExists, actuator.DeviceID, actuator.ID)
+	}
+	if len(actuator.Tags) == 0 {
+		actuator.Tags = []string{m.templates().ActuatorTag(actuator, dev.Metadata)}
+	}
+	if m.actuatorTagsTaken(key, actuator.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	if err := ensureExternalID(&actuator.ExternalID); err != nil {
+		return err
+	}
+	actuator.Version = 1
+	stored := copyActuator(actuator)
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, nil, stored)
+	if err != nil {
+		return err
+	}
+	m.actuators[key] = stored
+	m.appendAudit(entry)
+	return nil
+}
+
+// GetActuator retrieves an actuator by device ID and actuator ID
+func (m *Memory) GetActuator(ctx context.Context, deviceID, actuatorID string) (*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actuator, ok := m.actuators[componentKey{deviceID, actuatorID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: actuator %s/%s", ErrNotFound, deviceID, actuatorID)
+	}
+	return copyActuator(actuator), nil
+}
+
+// UpdateActuator updates an existing actuator
+func (m *Memory) UpdateActuator(ctx context.Context, actuator *api.Actuator) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateActuator(ctx, actuator)
+}
+
+// updateActuator is UpdateActuator; the caller must hold m.mu
+func (m *Memory) updateActuator(ctx context.Context, actuator *api.Actuator) error {
+	key := componentKey{actuator.DeviceID, actuator.ID}
+	current, ok := m.actuators[key]
+	if !ok {
+		return fmt.Errorf("%w: actuator %s/%s", ErrNotFound, actuator.DeviceID, actuator.ID)
+	}
+	if current.Version != actuator.Version {
+		return fmt.Errorf("%w: actuator %s/%s is at version %d", ErrConflict, actuator.DeviceID, actuator.ID, current.Version)
+	}
+	if m.actuatorTagsTaken(key, actuator.Tags) {
+		return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	}
+	stored := copyActuator(actuator)
+	stored.ExternalID = current.ExternalID
+	stored.Version++
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, current, stored)
+	if err != nil {
+		return err
+	}
+	m.actuators[key] = stored
+	actuator.Version = stored.Version
+	m.appendAudit(entry)
+	return nil
+}
+
+// PatchActuator applies update to an actuator, updating it as UpdateActuator does unless the
+// update leaves it as it was, and returns it
+func (m *Memory) PatchActuator(ctx context.Context, deviceID, actuatorID string, update *api.ActuatorMergePatch) (*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.actuators[componentKey{deviceID, actuatorID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: actuator %s/%s", ErrNotFound, deviceID, actuatorID)
+	}
+	if err := patchVersion(fmt.Sprintf("actuator %s/%s", deviceID, actuatorID), update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	actuator := copyActuator(current)
+	update.Apply(actuator)
+	if len(actuatorColumnsChanged(current, actuator)) > 0 {
+		if err := m.updateActuator(ctx, actuator); err != nil {
+			return nil, err
+		}
+	}
+	return actuator, nil
+}
+
+// DeleteActuator deletes an actuator
+func (m *Memory) DeleteActuator(ctx context.Context, deviceID, actuatorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{deviceID, actuatorID}
+	if _, ok := m.actuators[key]; !ok {
+		return fmt.Errorf("%w: actuator %s/%s", ErrNotFound, deviceID, actuatorID)
+	}
+	entry, err := memoryAuditEntry(ctx, api.AuditEntityActuator, []string{deviceID, actuatorID}, m.actuators[key], nil)
+	if err != nil {
+		return err
+	}
+	m.appendAudit(entry)
+	delete(m.actuators, key)
+	return nil
+}
+
+// ListActuators retrieves all actuators, ordered by name
+func (m *Memory) ListActuators(ctx context.Context) ([]*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.actuatorsWhere(func(*api.Actuator) bool { return true }), nil
+}
+
+// ListActuatorsPage retrieves a page of actuators, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the actuators of every device.
+func (m *Memory) ListActuatorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Actuator, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	actuators := m.actuatorsWhere(func(a *api.Actuator) bool {
+		return (deviceID == "" || a.DeviceID == deviceID) && matchesMetadata(a.Metadata, page.Metadata)
+	})
+	return paginate(actuators, page, actuatorPageKey)
+}
+
+// ListActuatorsByDeviceID retrieves all actuators for a device
+func (m *Memory) ListActuatorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.actuatorsWhere(func(a *api.Actuator) bool { return a.DeviceID == deviceID }), nil
+}
+
+// GetActuatorByTag retrieves an actuator with a specific tag, or the tag an alias points at
+func (m *Memory) GetActuatorByTag(ctx context.Context, tag string) (*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actuators := m.actuatorsWhere(func(a *api.Actuator) bool { return hasTag(a.Tags, tag) })
+	if target, ok := m.aliases[tag]; ok && len(actuators) == 0 {
+		actuators = m.actuatorsWhere(func(a *api.Actuator) bool { return hasTag(a.Tags, target) })
+	}
+	if len(actuators) == 0 {
+		return nil, fmt.Errorf("%w: actuator with tag %s", ErrNotFound, tag)
+	}
+	return actuators[0], nil
+}
+
+// ListActuatorsByTagPrefix retrieves actuators with tags matching a prefix
+func (m *Memory) ListActuatorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.actuatorsWhere(func(a *api.Actuator) bool { return hasTagPrefix(a.Tags, prefix) }), nil
+}
+
+// GetDeviceByExternalID retrieves a device, including its sensors and actuators, by its
+// external ID
+func (m *Memory) GetDeviceByExternalID(ctx context.Context, externalID string) (*api.Device, error) {
+	m.mu.Lock()
+	devices := m.devicesWhere(func(d *api.Device) bool { return d.ExternalID == externalID })
+	m.mu.Unlock()
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("%w: device with external id %s", ErrNotFound, externalID)
+	}
+	return m.GetDevice(ctx, devices[0].ID)
+}
+
+// GetSensorByExternalID retrieves a sensor by its external ID
+func (m *Memory) GetSensorByExternalID(ctx context.Context, externalID string) (*api.Sensor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sensors := m.sensorsWhere(func(s *api.Sensor) bool { return s.ExternalID == externalID })
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("%w: sensor with external id %s", ErrNotFound, externalID)
+	}
+	return sensors[0], nil
+}
+
+// GetActuatorByExternalID retrieves an actuator by its external ID
+func (m *Memory) GetActuatorByExternalID(ctx context.Context, externalID string) (*api.Actuator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	actuators := m.actuatorsWhere(func(a *api.Actuator) bool { return a.ExternalID == externalID })
+	if len(actuators) == 0 {
+		return nil, fmt.Errorf("%w: actuator with external id %s", ErrNotFound, externalID)
+	}
+	return actuators[0], nil
+}
+
+// Tag alias operations
+
+// SetTagAlias creates the alias or points an existing alias at a new target
+func (m *Memory) SetTagAlias(ctx context.Context, alias *api.TagAlias) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.aliases[alias.Alias] = alias.Target
+	return nil
+}
+
+// GetTagAlias retrieves an alias by name
+func (m *Memory) GetTagAlias(ctx context.Context, alias string) (*api.TagAlias, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.aliases[alias]
+	if !ok {
+		return nil, fmt.Errorf("%w: tag alias %s", ErrNotFound, alias)
+	}
+	return &api.TagAlias{Alias: alias, Target: target}, nil
+}
+
+// DeleteTagAlias deletes an alias; the tag it pointed at is unaffected
+func (m *Memory) DeleteTagAlias(ctx context.Context, alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.aliases[alias]; !ok {
+		return fmt.Errorf("%w: tag alias %s", ErrNotFound, alias)
+	}
+	delete(m.aliases, alias)
+	return nil
+}
+
+// ListTagAliases retrieves all aliases, ordered by alias
+func (m *Memory) ListTagAliases(ctx context.Context) ([]*api.TagAlias, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var aliases []*api.TagAlias
+	for alias, target := range m.aliases {
+		aliases = append(aliases, &api.TagAlias{Alias: alias, Target: target})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+	return aliases, nil
+}
+
+// Retagging operations
+
+// RenameTag renames from to to on devices, sensors and actuators, along with the tags
+// beneath it. Tag aliases pointing at a renamed tag follow it. It returns how many
+// entities changed, or ErrNotFound if none carry the tag.
+func (m *Memory) RenameTag(ctx context.Context, from, to string) (int64, error) {
+	return m.retag(ctx, renameRewrite(from, to), from)
+}
+
+// MergeTags replaces each of from with into on devices, sensors and actuators; entities
+// carrying several of them keep into once. Tag aliases pointing at a merged tag follow
+// it. It returns how many entities changed, or ErrNotFound if none carry any of from.
+func (m *Memory) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	return m.retag(ctx, mergeRewrite(from, into), strings.Join(from, ", "))
+}
+
+// ListTagTree returns the tag hierarchy at and beneath prefix, or the whole hierarchy if
+// prefix is empty, with how many devices, sensors and actuators are tagged at or beneath
+// each level
+func (m *Memory) ListTagTree(ctx context.Context, prefix string) (*api.TagNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entities := map[api.AuditEntityType][]taggedEntity{}
+	for id, dev := range m.devices {
+		entities[api.AuditEntityDevice] = append(entities[api.AuditEntityDevice], taggedEntity{keys: []string{id}, tags: dev.Tags})
+	}
+	for key, sensor := range m.sensors {
+		entities[api.AuditEntitySensor] = append(entities[api.AuditEntitySensor], taggedEntity{keys: []string{key.deviceID, key.id}, tags: sensor.Tags})
+	}
+	for key, actuator := range m.actuators {
+		entities[api.AuditEntityActuator] = append(entities[api.AuditEntityActuator], taggedEntity{keys: []string{key.deviceID, key.id}, tags: actuator.Tags})
+	}
+	return tagTree(prefix, entities), nil
+}
+
+// retag applies rewrite to every device, sensor and actuator and to tag alias targets,
+// changing nothing if any entity conflicts; what names the tags rewritten, for errors
+func (m *Memory) retag(ctx context.Context, rewrite tagRewrite, what string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var devices, sensors, actuators []taggedEntity
+	for id, dev := range m.devices {
+		devices = append(devices, taggedEntity{keys: []string{id}, tags: dev.Tags})
+	}
+	for key, sensor := range m.sensors {
+		sensors = append(sensors, taggedEntity{keys: []string{key.deviceID, key.id}, tags: sensor.Tags})
+	}
+	for key, actuator := range m.actuators {
+		actuators = append(actuators, taggedEntity{keys: []string{key.deviceID, key.id}, tags: actuator.Tags})
+	}
+	for _, entities := range [][]taggedEntity{devices, sensors, actuators} {
+		sort.Slice(entities, func(i, j int) bool { return slices.Compare(entities[i].keys, entities[j].keys) < 0 })
+	}
+	changedDevices, err := retag(api.AuditEntityDevice, devices, rewrite)
+	if err != nil {
+		return 0, err
+	}
+	changedSensors, err := retag(api.AuditEntitySensor, sensors, rewrite)
+	if err != nil {
+		return 0, err
+	}
+	changedActuators, err := retag(api.AuditEntityActuator, actuators, rewrite)
+	if err != nil {
+		return 0, err
+	}
+	n := len(changedDevices) + len(changedSensors) + len(changedActuators)
+	if n == 0 {
+		return 0, fmt.Errorf("%w: tag %s", ErrNotFound, what)
+	}
+
+	// Prepare every change before storing any, so a failure leaves nothing changed
+	var changes []*api.ChangeEvent
+	var entries []*api.AuditEntry
+	var apply []func()
+	for _, e := range changedDevices {
+		id := e.keys[0]
+		stored := copyDevice(m.devices[id])
+		stored.Tags, stored.Version = e.tags, stored.Version+1
+		change, err := newChange(api.ChangeDeviceUpdated, id, stored)
+		if err != nil {
+			return 0, err
+		}
+		entry, err := memoryAuditEntry(ctx, api.AuditEntityDevice, e.keys, m.devices[id], stored)
+		if err != nil {
+			return 0, err
+		}
+		changes, entries = append(changes, change), append(entries, entry)
+		apply = append(apply, func() { m.devices[id] = stored })
+	}
+	for _, e := range changedSensors {
+		key := componentKey{e.keys[0], e.keys[1]}
+		stored := copySensor(m.sensors[key])
+		stored.Tags, stored.Version = e.tags, stored.Version+1
+		entry, err := memoryAuditEntry(ctx, api.AuditEntitySensor, e.keys, m.sensors[key], stored)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, entry)
+		apply = append(apply, func() { m.sensors[key] = stored })
+	}
+	for _, e := range changedActuators {
+		key := componentKey{e.keys[0], e.keys[1]}
+		stored := copyActuator(m.actuators[key])
+		stored.Tags, stored.Version = e.tags, stored.Version+1
+		entry, err := memoryAuditEntry(ctx, api.AuditEntityActuator, e.keys, m.actuators[key], stored)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, entry)
+		apply = append(apply, func() { m.actuators[key] = stored })
+	}
+
+	for _, fn := range apply {
+		fn()
+	}
+	for alias, target := range m.aliases {
+		if target, ok := rewrite(target); ok {
+			m.aliases[alias] = target
+		}
+	}
+	m.appendChanges(changes...)
+	m.appendAudit(entries...)
+	return int64(n), nil
+}
+
+// Group operations
+
+// CreateGroup creates a group with its devices
+func (m *Memory) CreateGroup(ctx context.Context, g *api.Group) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[g.ID]; ok {
+		return fmt.Errorf("%w: group with id %s", ErrAlreadyExists, g.ID)
+	}
+	stored, err := m.newGroup(g)
+	if err != nil {
+		return err
+	}
+	m.groups[g.ID] = stored
+	return nil
+}
+
+// GetGroup retrieves a group with its devices
+func (m *Memory) GetGroup(ctx context.Context, id string) (*api.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	return copyGroup(g), nil
+}
+
+// UpdateGroup replaces a group's name, description, metadata and devices
+func (m *Memory) UpdateGroup(ctx context.Context, g *api.Group) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[g.ID]; !ok {
+		return fmt.Errorf("%w: group %s", ErrNotFound, g.ID)
+	}
+	stored, err := m.newGroup(g)
+	if err != nil {
+		return err
+	}
+	m.groups[g.ID] = stored
+	return nil
+}
+
+// DeleteGroup deletes a group; its devices are unaffected
+func (m *Memory) DeleteGroup(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.groups[id]; !ok {
+		return fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	delete(m.groups, id)
+	return nil
+}
+
+// ListGroups retrieves all groups with their devices, ordered by name
+func (m *Memory) ListGroups(ctx context.Context) ([]*api.Group, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var groups []*api.Group
+	for _, g := range m.groups {
+		groups = append(groups, copyGroup(g))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+// AddGroupDevice adds a device to a group; adding a member again does nothing
+func (m *Memory) AddGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[groupID]
+	if _, exists := m.devices[deviceID]; !ok || !exists {
+		return fmt.Errorf("%w: group %s or device %s", ErrNotFound, groupID, deviceID)
+	}
+	if i, found := slices.BinarySearch(g.DeviceIDs, deviceID); !found {
+		g.DeviceIDs = slices.Insert(g.DeviceIDs, i, deviceID)
+	}
+	return nil
+}
+
+// RemoveGroupDevice removes a device from a group
+func (m *Memory) RemoveGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.groups[groupID]
+	if !ok || !slices.Contains(g.DeviceIDs, deviceID) {
+		return fmt.Errorf("%w: device %s in group %s", ErrNotFound, deviceID, groupID)
+	}
+	g.DeviceIDs = slices.DeleteFunc(g.DeviceIDs, func(id string) bool { return id == deviceID })
+	return nil
+}
+
+// newGroup copies g for storing, with its devices sorted and deduplicated, checking each
+// device exists
+func (m *Memory) newGroup(g *api.Group) (*api.Group, error) {
+	stored := copyGroup(g)
+	for _, deviceID := range stored.DeviceIDs {
+		if _, ok := m.devices[deviceID]; !ok {
+			return nil, fmt.Errorf("%w: device %s", ErrNotFound, deviceID)
+		}
+	}
+	slices.Sort(stored.DeviceIDs)
+	stored.DeviceIDs = slices.Compact(stored.DeviceIDs)
+	return stored, nil
+}
+
+// Broken reference operations
+
+// AddBrokenReferences records rules left depending on deleted resources; a reference
+// already recorded is refreshed
+func (m *Memory) AddBrokenReferences(ctx context.Context, refs []*api.BrokenReference) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ref := range refs {
+		m.broken[brokenKey{ref.Kind, ref.Name, ref.Tag}] = *ref
+	}
+	return nil
+}
+
+// ListBrokenReferences retrieves all broken references, ordered by rule
+func (m *Memory) ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var refs []*api.BrokenReference
+	for _, ref := range m.broken {
+		refs = append(refs, &ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i], refs[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Tag < b.Tag
+	})
+	return refs, nil
+}
+
+// DeleteBrokenReferences clears the broken references of one rule, re-enabling it, and
+// returns how many were cleared
+func (m *Memory) DeleteBrokenReferences(ctx context.Context, kind, name string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteBrokenWhere(func(key brokenKey) bool { return key.kind == kind && key.name == name }), nil
+}
+
+// ResolveBrokenReferences clears broken references to any of tags and returns how many
+// were cleared
+func (m *Memory) ResolveBrokenReferences(ctx context.Context, tags []string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteBrokenWhere(func(key brokenKey) bool { return hasTag(tags, key.tag) }), nil
+}
+
+// Reading operations
+
+// StoreSensorReading records a single sensor reading; the sensor must exist
+func (m *Memory) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sensors[componentKey{rec.DeviceID, rec.SensorID}]; !ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, rec.DeviceID, rec.SensorID)
+	}
+	change, err := newChange(api.ChangeReadingStored, readingEntityID(rec), rec)
+	if err != nil {
+		return err
+	}
+	m.seq++
+	stored := *rec
+	stored.ID = m.seq
+	m.readings = append(m.readings, memoryReading{seq: m.seq, rec: stored})
+	m.appendChanges(change)
+	return nil
+}
+
+// StoreSensorReadings records several readings; if any sensor does not exist nothing is
+// stored
+func (m *Memory) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := make([]*api.ChangeEvent, 0, len(recs))
+	for _, rec := range recs {
+		if _, ok := m.sensors[componentKey{rec.DeviceID, rec.SensorID}]; !ok {
+			return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, rec.DeviceID, rec.SensorID)
+		}
+		change, err := newChange(api.ChangeReadingStored, readingEntityID(rec), rec)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	for _, rec := range recs {
+		m.seq++
+		stored := *rec
+		stored.ID = m.seq
+		m.readings = append(m.readings, memoryReading{seq: m.seq, rec: stored})
+	}
+	m.appendChanges(changes...)
+	return nil
+}
+
+// GetSensorReadings returns readings matching filters, newest first
+func (m *Memory) GetSensorReadings(ctx context.Context, filters SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matched []memoryReading
+	for _, r := range m.readings {
+		if readingMatches(&r.rec, filters) {
+			matched = append(matched, r)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		ti, tj := matched[i].rec.Reading.Timestamp, matched[j].rec.Reading.Timestamp
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return matched[i].seq > matched[j].seq
+	})
+	if filters.Limit > 0 && len(matched) > filters.Limit {
+		if filters.After != nil && filters.Before == nil {
+			// Paging forward from After takes the readings nearest it
+			matched = matched[len(matched)-filters.Limit:]
+		} else {
+			matched = matched[:filters.Limit]
+		}
+	}
+
+	var readings []*api.ReadingRecord
+	for _, r := range matched {
+		rec := r.rec
+		readings = append(readings, &rec)
+	}
+	return readings, nil
+}
+
+// CountSensorReadings returns how many readings match filters; Limit is ignored
+func (m *Memory) CountSensorReadings(ctx context.Context, filters SensorReadingFilters) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, r := range m.readings {
+		if readingMatches(&r.rec, filters) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetAggregatedReadings aggregates a sensor's valid, measured readings taken in
+// [start, end) into buckets of interval, counted in whole seconds from the Unix epoch,
+// oldest first. Buckets without readings are left out, and only fns are set in each.
+func (m *Memory) GetAggregatedReadings(ctx context.Context, deviceID, sensorID string, interval time.Duration, fns []api.AggregateFunc, start, end time.Time) ([]*api.ReadingBucket, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: interval %s is too short", interval)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type stats struct {
+		sum, min, max float64
+		count         int64
+	}
+	seconds := int64(interval / time.Second)
+	byBucket := map[int64]*stats{}
+	var order []int64
+	for _, r := range m.readings {
+		reading := r.rec.Reading
+		if r.rec.DeviceID != deviceID || r.rec.SensorID != sensorID || !reading.Valid || reading.Synthetic ||
+			reading.Timestamp.Before(start) || !reading.Timestamp.Before(end) {
+			continue
+		}
+		n := reading.Timestamp.Unix() / seconds
+		st, ok := byBucket[n]
+		if !ok {
+			st = &stats{min: reading.Value, max: reading.Value}
+			byBucket[n] = st
+			order = append(order, n)
+		}
+		st.sum += reading.Value
+		st.min = min(st.min, reading.Value)
+		st.max = max(st.max, reading.Value)
+		st.count++
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	buckets := []*api.ReadingBucket{}
+	for _, n := range order {
+		st := byBucket[n]
+		buckets = append(buckets, newReadingBucket(time.Unix(n*seconds, 0), fns, st.sum/float64(st.count), st.min, st.max, st.count))
+	}
+	return buckets, nil
+}
+
+// GetLatestSensorReading returns the most recent reading of a sensor
+func (m *Memory) GetLatestSensorReading(ctx context.Context, deviceID, sensorID string) (*api.SensorReading, error) {
+	readings, err := m.GetSensorReadings(ctx, SensorReadingFilters{DeviceID: deviceID, SensorID: sensorID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("%w: no readings for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return &readings[0].Reading, nil
+}
+
+// GetLatestReadingsForDevice returns the most recent reading of each sensor of a device
+// with any, ordered by sensor ID
+func (m *Memory) GetLatestReadingsForDevice(ctx context.Context, deviceID string) ([]*api.ReadingRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest := map[string]memoryReading{}
+	for _, r := range m.readings {
+		if r.rec.DeviceID != deviceID {
+			continue
+		}
+		cur, ok := latest[r.rec.SensorID]
+		if !ok || r.rec.Reading.Timestamp.After(cur.rec.Reading.Timestamp) ||
+			(r.rec.Reading.Timestamp.Equal(cur.rec.Reading.Timestamp) && r.seq > cur.seq) {
+			latest[r.rec.SensorID] = r
+		}
+	}
+	readings := make([]*api.ReadingRecord, 0, len(latest))
+	for _, r := range latest {
+		rec := r.rec
+		readings = append(readings, &rec)
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].SensorID < readings[j].SensorID })
+	return readings, nil
+}
+
+// DeleteOldSensorReadings removes readings taken before the given time and returns how
+// many were deleted
+func (m *Memory) DeleteOldSensorReadings(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteReadingsWhere(func(rec *api.ReadingRecord) bool { return rec.Reading.Timestamp.Before(before) }), nil
+}
+
+// RecordCommand appends a command to its device's history and sets its ID. The command
+// is also entered in the audit log, attributed to its origin, and the state it reported
+// in the change feed.
+func (m *Memory) RecordCommand(ctx context.Context, rec *api.CommandRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devices[rec.DeviceID]; !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, rec.DeviceID)
+	}
+	rec.ID = m.commandID + 1
+	entry, err := commandAuditEntry(rec)
+	if err != nil {
+		return err
+	}
+	changes, err := commandChanges(rec)
+	if err != nil {
+		return err
+	}
+	m.commandID = rec.ID
+	m.commands = append(m.commands, copyCommand(rec))
+	m.appendAudit(entry)
+	m.appendChanges(changes...)
+	return nil
+}
+
+// ListCommands returns commands matching filters in the order they were issued
+func (m *Memory) ListCommands(ctx context.Context, filters CommandFilters) ([]*api.CommandRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var commands []*api.CommandRecord
+	for _, rec := range m.commands {
+		switch {
+		case filters.DeviceID != "" && rec.DeviceID != filters.DeviceID:
+		case filters.ActuatorID != "" && rec.ActuatorID != filters.ActuatorID:
+		case filters.StartTime != nil && rec.IssuedAt.Before(*filters.StartTime):
+		case filters.EndTime != nil && !rec.IssuedAt.Before(*filters.EndTime):
+		default:
+			commands = append(commands, copyCommand(rec))
+		}
+	}
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].IssuedAt.Before(commands[j].IssuedAt)
+	})
+	if filters.Limit > 0 && len(commands) > filters.Limit {
+		commands = commands[len(commands)-filters.Limit:]
+	}
+	return commands, nil
+}
+
+// CreateReadingLabel stores a label, setting its ID and CreatedAt
+func (m *Memory) CreateReadingLabel(ctx context.Context, label *api.ReadingLabel) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.labelID++
+	label.ID = m.labelID
+	label.CreatedAt = m.now().UTC()
+	m.labels = append(m.labels, *label)
+	return nil
+}
+
+// ListReadingLabels returns labels matching filters, ordered by start time
+func (m *Memory) ListReadingLabels(ctx context.Context, filters ReadingLabelFilters) ([]*api.ReadingLabel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var labels []*api.ReadingLabel
+	for _, l := range m.labels {
+		switch {
+		case filters.Key != "" && l.Key != filters.Key:
+		case filters.DeviceID != "" && l.DeviceID != "" && l.DeviceID != filters.DeviceID:
+		case filters.SensorID != "" && l.SensorID != "" && l.SensorID != filters.SensorID:
+		case filters.StartTime != nil && !l.End.After(*filters.StartTime):
+		case filters.EndTime != nil && !l.Start.Before(*filters.EndTime):
+		default:
+			label := l
+			labels = append(labels, &label)
+		}
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Start.Before(labels[j].Start)
+	})
+	return labels, nil
+}
+
+// DeleteReadingLabel removes a label; the readings it covered are unaffected
+func (m *Memory) DeleteReadingLabel(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, l := range m.labels {
+		if l.ID == id {
+			m.labels = slices.Delete(m.labels, i, i+1)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: reading label %d", ErrNotFound, id)
+}
+
+// RecordRuleTrace stores an evaluation of a rule, setting its ID, and keeps only the
+// rule's newest keep traces
+func (m *Memory) RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.traceID++
+	trace.ID = m.traceID
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule trace: %w", err)
+	}
+	m.traces = append(m.traces, memoryTrace{kind: trace.Kind, name: trace.Name, id: trace.ID, data: data})
+
+	kept := 0
+	for i := len(m.traces) - 1; i >= 0; i-- {
+		t := m.traces[i]
+		if t.kind != trace.Kind || t.name != trace.Name {
+			continue
+		}
+		if kept++; kept > keep {
+			m.traces = slices.Delete(m.traces, i, i+1)
+		}
+	}
+	return nil
+}
+
+// ListRuleTraces returns a rule's latest limit traces, newest first
+func (m *Memory) ListRuleTraces(ctx context.Context, kind, name string, limit int) ([]*api.RuleTrace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var traces []*api.RuleTrace
+	for i := len(m.traces) - 1; i >= 0 && len(traces) < limit; i-- {
+		t := m.traces[i]
+		if t.kind != kind || t.name != name {
+			continue
+		}
+		var trace api.RuleTrace
+		if err := json.Unmarshal(t.data, &trace); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rule trace: %w", err)
+		}
+		traces = append(traces, &trace)
+	}
+	return traces, nil
+}
+
+// SetSensorTarget creates or replaces a sensor's target range
+func (m *Memory) SetSensorTarget(ctx context.Context, target *api.TargetRange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{target.DeviceID, target.SensorID}
+	if _, ok := m.sensors[key]; !ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, target.DeviceID, target.SensorID)
+	}
+	m.targets[key] = copyTarget(target)
+	return nil
+}
+
+// GetSensorTarget retrieves a sensor's target range
+func (m *Memory) GetSensorTarget(ctx context.Context, deviceID, sensorID string) (*api.TargetRange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	target, ok := m.targets[componentKey{deviceID, sensorID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: target for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return copyTarget(target), nil
+}
+
+// DeleteSensorTarget removes a sensor's target range
+func (m *Memory) DeleteSensorTarget(ctx context.Context, deviceID, sensorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{deviceID, sensorID}
+	if _, ok := m.targets[key]; !ok {
+		return fmt.Errorf("%w: target for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	delete(m.targets, key)
+	return nil
+}
+
+// ListSensorTargets retrieves all target ranges, ordered by sensor
+func (m *Memory) ListSensorTargets(ctx context.Context) ([]*api.TargetRange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var targets []*api.TargetRange
+	for _, target := range m.targets {
+		targets = append(targets, copyTarget(target))
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].DeviceID != targets[j].DeviceID {
+			return targets[i].DeviceID < targets[j].DeviceID
+		}
+		return targets[i].SensorID < targets[j].SensorID
+	})
+	return targets, nil
+}
+
+// Lease operations
+
+// AcquireLease takes the named lease for holder until ttl from now, or renews it if
+// holder already has it. It returns ErrLeaseHeld while another holder's lease is
+// unexpired.
+func (m *Memory) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	lease, ok := m.leases[name]
+	switch {
+	case !ok, lease.Holder != holder && lease.ExpiresAt.Before(now):
+		lease = api.Lease{Name: name, Holder: holder, AcquiredAt: now}
+	case lease.Holder != holder:
+		return nil, fmt.Errorf("%w: %s", ErrLeaseHeld, name)
+	}
+	lease.ExpiresAt = now.Add(ttl)
+	m.leases[name] = lease
+	return &lease, nil
+}
+
+// ReleaseLease gives up holder's lease so another worker can take over at once
+func (m *Memory) ReleaseLease(ctx context.Context, name, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lease, ok := m.leases[name]
+	if !ok || lease.Holder != holder {
+		return fmt.Errorf("%w: lease %s held by %s", ErrNotFound, name, holder)
+	}
+	delete(m.leases, name)
+	return nil
+}
+
+// ListLeases returns every lease, including expired ones not yet taken over, by name
+func (m *Memory) ListLeases(ctx context.Context) ([]*api.Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var leases []*api.Lease
+	for _, lease := range m.leases {
+		leases = append(leases, &lease)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].Name < leases[j].Name })
+	return leases, nil
+}
+
+// Pump runtime operations
+
+// GetPumpRuntimes returns the saved runtime of each pump of a pump rotation, by tag,
+// truncated to whole seconds as the databases store them, and the tag of the pump saved
+// as running
+func (m *Memory) GetPumpRuntimes(ctx context.Context, rotation string) (map[string]time.Duration, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return maps.Clone(m.runtimes[rotation]), m.activePumps[rotation], nil
+}
+
+// SetPumpRuntimes saves the runtime of each pump of a pump rotation, leaving pumps not
+// in runtimes as they were, and records active as the only running pump
+func (m *Memory) SetPumpRuntimes(ctx context.Context, rotation string, runtimes map[string]time.Duration, active string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.runtimes[rotation] == nil {
+		m.runtimes[rotation] = make(map[string]time.Duration)
+	}
+	for tag, runtime := range runtimes {
+		m.runtimes[rotation][tag] = runtime.Truncate(time.Second)
+	}
+	// As in the databases, only a pump with a saved runtime can be the running one
+	if _, ok := runtimes[active]; ok {
+		m.activePumps[rotation] = active
+	} else {
+		delete(m.activePumps, rotation)
+	}
+	return nil
+}
+
+// Helpers; callers must hold m.mu
+
+func (m *Memory) deleteBrokenWhere(match func(brokenKey) bool) int64 {
+	var n int64
+	for key := range m.broken {
+		if match(key) {
+			delete(m.broken, key)
+			n++
+		}
+	}
+	return n
+}
+
+func readingMatches(rec *api.ReadingRecord, filters SensorReadingFilters) bool {
+	switch {
+	case filters.DeviceID != "" && rec.DeviceID != filters.DeviceID,
+		filters.SensorID != "" && rec.SensorID != filters.SensorID,
+		filters.StartTime != nil && rec.Reading.Timestamp.Before(*filters.StartTime),
+		filters.EndTime != nil && !rec.Reading.Timestamp.Before(*filters.EndTime),
+		filters.Before != nil && !KeyOf(rec).Less(*filters.Before),
+		filters.After != nil && !filters.After.Less(KeyOf(rec)):
+		return false
+	}
+	return true
+}
+
+func (m *Memory) deviceTagsTaken(id string, tags []string) bool {
+	for otherID, other := range m.devices {
+		if otherID != id && overlaps(other.Tags, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) sensorTagsTaken(key componentKey, tags []string) bool {
+	for otherKey, other := range m.sensors {
+		if otherKey != key && overlaps(other.Tags, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) actuatorTagsTaken(key componentKey, tags []string) bool {
+	for otherKey, other := range m.actuators {
+		if otherKey != key && overlaps(other.Tags, tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) devicesWhere(match func(*api.Device) bool) []*api.Device {
+	var devices []*api.Device
+	for _, dev := range m.devices {
+		if match(dev) {
+			devices = append(devices, copyDevice(dev))
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Name != devices[j].Name {
+			return devices[i].Name < devices[j].Name
+		}
+		return devices[i].ID < devices[j].ID
+	})
+	return devices
+}
+
+func (m *Memory) sensorsWhere(match func(*api.Sensor) bool) []*api.Sensor {
+	var sensors []*api.Sensor
+	for _, sensor := range m.sensors {
+		if match(sensor) {
+			sensors = append(sensors, copySensor(sensor))
+		}
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		if sensors[i].Name != sensors[j].Name {
+			return sensors[i].Name < sensors[j].Name
+		}
+		if sensors[i].DeviceID != sensors[j].DeviceID {
+			return sensors[i].DeviceID < sensors[j].DeviceID
+		}
+		return sensors[i].ID < sensors[j].ID
+	})
+	return sensors
+}
+
+func (m *Memory) actuatorsWhere(match func(*api.Actuator) bool) []*api.Actuator {
+	var actuators []*api.Actuator
+	for _, actuator := range m.actuators {
+		if match(actuator) {
+			actuators = append(actuators, copyActuator(actuator))
+		}
+	}
+	sort.Slice(actuators, func(i, j int) bool {
+		if actuators[i].Name != actuators[j].Name {
+			return actuators[i].Name < actuators[j].Name
+		}
+		if actuators[i].DeviceID != actuators[j].DeviceID {
+			return actuators[i].DeviceID < actuators[j].DeviceID
+		}
+		return actuators[i].ID < actuators[j].ID
+	})
+	return actuators
+}
+
+func (m *Memory) deleteReadingsWhere(match func(*api.ReadingRecord) bool) int64 {
+	kept := m.readings[:0]
+	var deleted int64
+	for _, r := range m.readings {
+		if match(&r.rec) {
+			deleted++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.readings = kept
+	return deleted
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTagPrefix(tags []string, prefix string) bool {
+	for _, t := range tags {
+		if strings.HasPrefix(t, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b []string) bool {
+	for _, t := range b {
+		if hasTag(a, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyTag(seen map[string]bool, tags []string) bool {
+	for _, t := range tags {
+		if seen[t] {
+			return true
+		}
+	}
+	return false
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+func copyMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// copyDevice copies a device without its nested sensors and actuators
+func copyDevice(dev *api.Device) *api.Device {
+	out := *dev
+	out.Sensors = nil
+	out.Actuators = nil
+	out.Metadata = copyMetadata(dev.Metadata)
+	out.Tags = copyStrings(dev.Tags)
+	return &out
+}
+
+func copySensor(sensor *api.Sensor) *api.Sensor {
+	out := *sensor
+	out.Metadata = copyMetadata(sensor.Metadata)
+	out.Tags = copyStrings(sensor.Tags)
+	return &out
+}
+
+func copyActuator(actuator *api.Actuator) *api.Actuator {
+	out := *actuator
+	out.Metadata = copyMetadata(actuator.Metadata)
+	out.Tags = copyStrings(actuator.Tags)
+	return &out
+}
+
+func copyCommand(rec *api.CommandRecord) *api.CommandRecord {
+	out := *rec
+	out.Command.Parameters = maps.Clone(rec.Command.Parameters)
+	if rec.State != nil {
+		state := *rec.State
+		state.Parameters = maps.Clone(rec.State.Parameters)
+		if rec.State.Origin != nil {
+			origin := *rec.State.Origin
+			state.Origin = &origin
+		}
+		out.State = &state
+	}
+	return &out
+}
+
+func copyGroup(g *api.Group) *api.Group {
+	out := *g
+	out.Metadata = copyMetadata(g.Metadata)
+	out.DeviceIDs = copyStrings(g.DeviceIDs)
+	if out.DeviceIDs == nil {
+		out.DeviceIDs = []string{}
+	}
+	return &out
+}
+
+func copyTarget(target *api.TargetRange) *api.TargetRange {
+	out := *target
+	for _, bound := range []**float64{
+		&out.Ideal.Min, &out.Ideal.Max,
+		&out.Warning.Min, &out.Warning.Max,
+		&out.Critical.Min, &out.Critical.Max,
+	} {
+		if *bound != nil {
+			v := **bound
+			*bound = &v
+		}
+	}
+	return &out
+}
+
+// Credential operations
+
+// SetCredential stores the device's named credential, replacing the secret of an existing
+// one while keeping its ID. It fills in the credential's ID and timestamps.
+func (m *Memory) SetCredential(ctx context.Context, cred *api.SealedCredential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devices[cred.DeviceID]; !ok {
+		return fmt.Errorf("%w: device %s", ErrNotFound, cred.DeviceID)
+	}
+	now := m.now()
+	cred.ID, cred.CreatedAt = uuid.NewString(), now
+	for _, existing := range m.credentials {
+		if existing.DeviceID == cred.DeviceID && existing.Name == cred.Name {
+			cred.ID, cred.CreatedAt = existing.ID, existing.CreatedAt
+			break
+		}
+	}
+	cred.UpdatedAt = now
+	m.credentials[cred.ID] = copySealedCredential(cred)
+	return nil
+}
+
+// GetCredential retrieves a credential, with its sealed secret, by ID
+func (m *Memory) GetCredential(ctx context.Context, id string) (*api.SealedCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cred, ok := m.credentials[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: credential %s", ErrNotFound, id)
+	}
+	return copySealedCredential(cred), nil
+}
+
+// ListCredentials describes the device's credentials, ordered by name, without their
+// secrets
+func (m *Memory) ListCredentials(ctx context.Context, deviceID string) ([]*api.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var creds []*api.Credential
+	for _, cred := range m.credentials {
+		if cred.DeviceID == deviceID {
+			c := cred.Credential
+			creds = append(creds, &c)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].Name < creds[j].Name })
+	return creds, nil
+}
+
+// DeleteCredential deletes a credential and its secret
+func (m *Memory) DeleteCredential(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.credentials[id]; !ok {
+		return fmt.Errorf("%w: credential %s", ErrNotFound, id)
+	}
+	delete(m.credentials, id)
+	return nil
+}
+
+func copySealedCredential(cred *api.SealedCredential) *api.SealedCredential {
+	c := *cred
+	c.WrappedKey = slices.Clone(cred.WrappedKey)
+	c.Ciphertext = slices.Clone(cred.Ciphertext)
+	return &c
+}
+
+// Asset operations
+
+// CreateAsset records an attached image whose blobs have been stored under its ID
+func (m *Memory) CreateAsset(ctx context.Context, asset *api.Asset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if asset.DeviceID != "" {
+		if _, ok := m.devices[asset.DeviceID]; !ok {
+			return fmt.Errorf("%w: device %s", ErrNotFound, asset.DeviceID)
+		}
+	}
+	if _, ok := m.assets[asset.ID]; ok {
+		return fmt.Errorf("%w: asset %s", ErrAlreadyExists, asset.ID)
+	}
+	asset.CreatedAt = m.now()
+	m.assets[asset.ID] = *asset
+	return nil
+}
+
+// GetAsset retrieves an asset by ID
+func (m *Memory) GetAsset(ctx context.Context, id string) (*api.Asset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	asset, ok := m.assets[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	return &asset, nil
+}
+
+// ListAssets retrieves the assets matching filters, oldest first
+func (m *Memory) ListAssets(ctx context.Context, filters AssetFilters) ([]*api.Asset, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var assets []*api.Asset
+	for _, asset := range m.assets {
+		if filters.DeviceID != "" && asset.DeviceID != filters.DeviceID ||
+			filters.Subsystem != "" && asset.Subsystem != filters.Subsystem {
+			continue
+		}
+		assets = append(assets, &asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		if !assets[i].CreatedAt.Equal(assets[j].CreatedAt) {
+			return assets[i].CreatedAt.Before(assets[j].CreatedAt)
+		}
+		return assets[i].ID < assets[j].ID
+	})
+	return assets, nil
+}
+
+// DeleteAsset deletes an asset's record; its blobs are the caller's to remove
+func (m *Memory) DeleteAsset(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.assets[id]; !ok {
+		return fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	delete(m.assets, id)
+	return nil
+}
+
+// Change feed operations
+
+// appendChanges numbers and timestamps changes onto the feed; callers hold m.mu
+func (m *Memory) appendChanges(changes ...*api.ChangeEvent) {
+	now := m.now()
+	for _, change := range changes {
+		m.changeSeq++
+		c := *change
+		c.Seq = m.changeSeq
+		c.Timestamp = now
+		m.changes = append(m.changes, c)
+	}
+}
+
+// RecordChange appends a change for an entity the store doesn't otherwise keep
+func (m *Memory) RecordChange(ctx context.Context, change *api.ChangeEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.appendChanges(change)
+	return nil
+}
+
+// ListChanges returns up to limit changes with a Seq greater than after, oldest first
+func (m *Memory) ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	start := sort.Search(len(m.changes), func(i int) bool { return m.changes[i].Seq > after })
+	var changes []*api.ChangeEvent
+	for _, change := range m.changes[start:] {
+		if len(changes) == limit {
+			break
+		}
+		c := change
+		changes = append(changes, &c)
+	}
+	return changes, nil
+}
+
+// LatestChangeSeq returns the Seq of the most recent change, or 0 if the feed is empty
+func (m *Memory) LatestChangeSeq(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.changes) == 0 {
+		return 0, nil
+	}
+	return m.changes[len(m.changes)-1].Seq, nil
+}
+
+// DeleteOldChanges prunes changes recorded before the cutoff
+func (m *Memory) DeleteOldChanges(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.changes[:0]
+	for _, change := range m.changes {
+		if !change.Timestamp.Before(before) {
+			kept = append(kept, change)
+		}
+	}
+	deleted := int64(len(m.changes) - len(kept))
+	m.changes = kept
+	return deleted, nil
+}
+
+// GetChangeCursor returns the Seq of the last change a named consumer handled, or 0
+func (m *Memory) GetChangeCursor(ctx context.Context, consumer string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.changeCursors[consumer], nil
+}
+
+// SetChangeCursor records that a named consumer has handled every change up to seq
+func (m *Memory) SetChangeCursor(ctx context.Context, consumer string, seq int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.changeCursors[consumer] = seq
+	return nil
+}
+
+// Maintenance mode operations
+
+// GetMaintenanceMode returns the current maintenance mode, which is off until first set
+func (m *Memory) GetMaintenanceMode(ctx context.Context) (*api.MaintenanceMode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mode := m.maintenance
+	return &mode, nil
+}
+
+// SetMaintenanceMode replaces the maintenance mode, setting mode.UpdatedAt
+func (m *Memory) SetMaintenanceMode(ctx context.Context, mode *api.MaintenanceMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mode.UpdatedAt = m.now()
+	m.maintenance = *mode
+	return nil
+}
+
+// Feature flag operations
+
+// SetFeatureFlag creates the flag or replaces an existing one, setting flag.UpdatedAt
+func (m *Memory) SetFeatureFlag(ctx context.Context, flag *api.FeatureFlag) error {
+	if flag.Percent < 0 || flag.Percent > 100 {
+		return fmt.Errorf("failed to set feature flag: percent %d is out of range", flag.Percent)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flag.UpdatedAt = m.now()
+	m.features[flag.Name] = *flag
+	return nil
+}
+
+// GetFeatureFlag retrieves a flag by name
+func (m *Memory) GetFeatureFlag(ctx context.Context, name string) (*api.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	flag, ok := m.features[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: feature flag %s", ErrNotFound, name)
+	}
+	return &flag, nil
+}
+
+// DeleteFeatureFlag deletes a flag, turning its feature off for everyone
+func (m *Memory) DeleteFeatureFlag(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.features[name]; !ok {
+		return fmt.Errorf("%w: feature flag %s", ErrNotFound, name)
+	}
+	delete(m.features, name)
+	return nil
+}
+
+// ListFeatureFlags retrieves all flags, ordered by name
+func (m *Memory) ListFeatureFlags(ctx context.Context) ([]*api.FeatureFlag, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var flags []*api.FeatureFlag
+	for _, flag := range m.features {
+		flags = append(flags, &flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+// Tag template operations
+
+// SetTagTemplate creates the template of its kind or replaces an existing one, setting
+// template.UpdatedAt. Entities created from then on are tagged by it; existing tags stay.
+func (m *Memory) SetTagTemplate(ctx context.Context, template *api.TagTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	template.UpdatedAt = m.now()
+	m.tagTemplates[template.Kind] = *template
+	return nil
+}
+
+// DeleteTagTemplate deletes the template of a kind, restoring the built-in default tags
+func (m *Memory) DeleteTagTemplate(ctx context.Context, kind api.TagKind) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tagTemplates[kind]; !ok {
+		return fmt.Errorf("%w: tag template %s", ErrNotFound, kind)
+	}
+	delete(m.tagTemplates, kind)
+	return nil
+}
+
+// ListTagTemplates retrieves all tag templates, ordered by kind
+func (m *Memory) ListTagTemplates(ctx context.Context) ([]*api.TagTemplate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var templates []*api.TagTemplate
+	for _, template := range m.tagTemplates {
+		templates = append(templates, &template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Kind < templates[j].Kind })
+	return templates, nil
+}
+
+// templates returns the tag templates by kind; the caller must hold m.mu
+func (m *Memory) templates() api.TagTemplates {
+	templates := make(api.TagTemplates, len(m.tagTemplates))
+	for kind, t := range m.tagTemplates {
+		templates[kind] = t.Template
+	}
+	return templates
+}
+
+// Retention policy operations
+
+// SetRetentionPolicy creates the policy for its sensor type or replaces an existing one,
+// setting policy.UpdatedAt. Retention is kept in whole seconds, as the databases store it.
+func (m *Memory) SetRetentionPolicy(ctx context.Context, policy *api.RetentionPolicy) error {
+	if policy.Retention < time.Second {
+		return fmt.Errorf("failed to set retention policy: retention %s is too short", policy.Retention)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	policy.UpdatedAt = m.now()
+	stored := *policy
+	stored.Retention = policy.Retention.Truncate(time.Second)
+	m.retention[policy.SensorType] = stored
+	return nil
+}
+
+// DeleteRetentionPolicy deletes the policy of a sensor type, keeping its readings forever
+func (m *Memory) DeleteRetentionPolicy(ctx context.Context, sensorType api.SensorType) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.retention[sensorType]; !ok {
+		return fmt.Errorf("%w: retention policy %s", ErrNotFound, sensorType)
+	}
+	delete(m.retention, sensorType)
+	return nil
+}
+
+// ListRetentionPolicies retrieves all retention policies, ordered by sensor type
+func (m *Memory) ListRetentionPolicies(ctx context.Context) ([]*api.RetentionPolicy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var policies []*api.RetentionPolicy
+	for _, policy := range m.retention {
+		policies = append(policies, &policy)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].SensorType < policies[j].SensorType })
+	return policies, nil
+}
+
+// DeleteSensorReadingsByType removes readings of sensors of the given type taken before
+// the given time and returns how many were deleted
+func (m *Memory) DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.deleteReadingsWhere(func(rec *api.ReadingRecord) bool {
+		sensor, ok := m.sensors[componentKey{rec.DeviceID, rec.SensorID}]
+		return ok && sensor.SensorType == sensorType && rec.Reading.Timestamp.Before(before)
+	}), nil
+}
+
+// Storage quota operations
+
+// SetStorageQuota creates the quota of its sensor or replaces an existing one, setting
+// quota.UpdatedAt. Downsampling is kept in whole seconds, as the databases store it.
+func (m *Memory) SetStorageQuota(ctx context.Context, quota *api.StorageQuota) error {
+	if quota.MaxReadings <= 0 {
+		return fmt.Errorf("failed to set storage quota: max readings %d must be positive", quota.MaxReadings)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{quota.DeviceID, quota.SensorID}
+	if _, ok := m.sensors[key]; !ok {
+		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, quota.DeviceID, quota.SensorID)
+	}
+	quota.UpdatedAt = m.now()
+	stored := *quota
+	stored.Downsample = quota.Downsample.Truncate(time.Second)
+	m.quotas[key] = stored
+	return nil
+}
+
+// DeleteStorageQuota deletes the quota of a sensor, keeping all of its readings
+func (m *Memory) DeleteStorageQuota(ctx context.Context, deviceID, sensorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := componentKey{deviceID, sensorID}
+	if _, ok := m.quotas[key]; !ok {
+		return fmt.Errorf("%w: storage quota %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	delete(m.quotas, key)
+	return nil
+}
+
+// ListStorageQuotas retrieves all storage quotas, ordered by device and sensor
+func (m *Memory) ListStorageQuotas(ctx context.Context) ([]*api.StorageQuota, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var quotas []*api.StorageQuota
+	for _, quota := range m.quotas {
+		quotas = append(quotas, &quota)
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].DeviceID != quotas[j].DeviceID {
+			return quotas[i].DeviceID < quotas[j].DeviceID
+		}
+		return quotas[i].SensorID < quotas[j].SensorID
+	})
+	return quotas, nil
+}
+
+// TableUsage reports the rows of the readings, audit log and change feed. Nothing is on
+// disk, so bytes are always zero.
+func (m *Memory) TableUsage(ctx context.Context) ([]api.TableUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return []api.TableUsage{
+		{Table: "audit_log", Rows: int64(len(m.audit))},
+		{Table: "change_feed", Rows: int64(len(m.changes))},
+		{Table: "sensor_readings", Rows: int64(len(m.readings))},
+	}, nil
+}
+
+// SensorReadingUsage counts the readings kept of each sensor with any, and how many of
+// them were taken at or after since, ordered by device and sensor
+func (m *Memory) SensorReadingUsage(ctx context.Context, since time.Time) ([]api.SensorUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[componentKey]*api.SensorUsage{}
+	for _, r := range m.readings {
+		key := componentKey{r.rec.DeviceID, r.rec.SensorID}
+		u, ok := counts[key]
+		if !ok {
+			u = &api.SensorUsage{DeviceID: key.deviceID, SensorID: key.id}
+			counts[key] = u
+		}
+		u.Readings++
+		if !r.rec.Reading.Timestamp.Before(since) {
+			u.Recent++
+		}
+	}
+	var usage []api.SensorUsage
+	for _, u := range counts {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].DeviceID != usage[j].DeviceID {
+			return usage[i].DeviceID < usage[j].DeviceID
+		}
+		return usage[i].SensorID < usage[j].SensorID
+	})
+	return usage, nil
+}
+
+// ThinSensorReadings downsamples a sensor's readings taken before the given time to the
+// last one of each interval, counted in whole seconds from the Unix epoch, and returns
+// how many were deleted. The sensor's latest reading is always kept.
+func (m *Memory) ThinSensorReadings(ctx context.Context, deviceID, sensorID string, before time.Time, interval time.Duration) (int64, error) {
+	if interval < time.Second {
+		return 0, fmt.Errorf("failed to thin sensor readings: interval %s is too short", interval)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := int64(interval / time.Second)
+	thinned := func(r *memoryReading) bool {
+		return r.rec.DeviceID == deviceID && r.rec.SensorID == sensorID && r.rec.Reading.Timestamp.Before(before)
+	}
+	// The last reading of each interval, by timestamp and then the order stored
+	last := map[int64]*memoryReading{}
+	for i := range m.readings {
+		r := &m.readings[i]
+		if !thinned(r) {
+			continue
+		}
+		bucket := r.rec.Reading.Timestamp.Unix() / seconds
+		kept, ok := last[bucket]
+		if !ok || kept.rec.Reading.Timestamp.Before(r.rec.Reading.Timestamp) ||
+			kept.rec.Reading.Timestamp.Equal(r.rec.Reading.Timestamp) && kept.seq < r.seq {
+			last[bucket] = r
+		}
+	}
+	keep := make(map[int64]bool, len(last))
+	for _, r := range last {
+		keep[r.seq] = true
+	}
+
+	kept := m.readings[:0]
+	var deleted int64
+	for _, r := range m.readings {
+		if thinned(&r) && !keep[r.seq] {
+			deleted++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.readings = kept
+	return deleted, nil
+}
+
+// Slow query operations
+
+// ListSlowQueries returns nothing; the in-memory store has no queries to capture
+func (m *Memory) ListSlowQueries(ctx context.Context, limit int) ([]*api.SlowQuery, error) {
+	return nil, nil
+}
+
+// ClearSlowQueries is a no-op
+func (m *Memory) ClearSlowQueries(ctx context.Context) error {
+	return nil
+}
+
+// Audit log operations
+
+// memoryAuditEntry builds the audit entry for a change to an entity, given as it was
+// stored before and after the change; nil for neither
+func memoryAuditEntry(ctx context.Context, typ api.AuditEntityType, keys []string, before, after any) (*api.AuditEntry, error) {
+	var data [2]json.RawMessage
+	for i, entity := range []any{before, after} {
+		if entity == nil {
+			continue
+		}
+		b, err := json.Marshal(entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", typ, err)
+		}
+		data[i] = b
+	}
+	return newAuditEntry(ctx, typ, keys, data[0], data[1]), nil
+}
+
+// appendAudit numbers and timestamps entries onto the audit log; callers hold m.mu
+func (m *Memory) appendAudit(entries ...*api.AuditEntry) {
+	now := m.now()
+	for _, entry := range entries {
+		m.auditID++
+		e := *entry
+		e.ID = m.auditID
+		e.Timestamp = now
+		m.audit = append(m.audit, e)
+	}
+}
+
+// ListAuditEntries returns audit entries matching filters, oldest first
+func (m *Memory) ListAuditEntries(ctx context.Context, filters AuditFilters) ([]*api.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []*api.AuditEntry
+	for _, entry := range m.audit {
+		switch {
+		case filters.EntityType != "" && entry.EntityType != filters.EntityType:
+		case filters.EntityID != "" && entry.EntityID != filters.EntityID:
+		case filters.StartTime != nil && entry.Timestamp.Before(*filters.StartTime):
+		case filters.EndTime != nil && !entry.Timestamp.Before(*filters.EndTime):
+		case len(filters.Actions) > 0 && !slices.Contains(filters.Actions, entry.Action):
+		default:
+			e := entry
+			entries = append(entries, &e)
+		}
+	}
+	if filters.Limit > 0 && len(entries) > filters.Limit {
+		entries = entries[len(entries)-filters.Limit:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,1811 @@
+package storer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func newMemoryDevice() *api.Device {
+	return &api.Device{
+		ID:     "dev-1",
+		Driver: api.DriverShelly,
+		Name:   "Device",
+		Sensors: []*api.Sensor{
+			{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"tank.temp"}},
+			{ID: "ph", Name: "pH", SensorType: api.SensorTypePH},
+		},
+		Actuators: []*api.Actuator{
+			{ID: "pump", Name: "Pump", ActuatorType: api.ActuatorTypePeristalticPump},
+		},
+	}
+}
+
+func TestMemory_CreateAndGetDevice(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if err := store.CreateDevice(ctx, newMemoryDevice()); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for duplicate device, got %v", err)
+	}
+
+	dev, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if len(dev.Tags) != 1 || dev.Tags[0] != "device.dev-1" {
+		t.Errorf("Expected default device tag, got %v", dev.Tags)
+	}
+	if len(dev.Sensors) != 2 || dev.Sensors[0].ID != "temp" {
+		t.Fatalf("Expected 2 sensors ordered by name, got %+v", dev.Sensors)
+	}
+	if dev.Sensors[1].Tags[0] != "device.dev-1.sensor.ph" {
+		t.Errorf("Expected default sensor tag, got %v", dev.Sensors[1].Tags)
+	}
+	if len(dev.Actuators) != 1 || dev.Actuators[0].DeviceID != "dev-1" {
+		t.Errorf("Expected 1 actuator on dev-1, got %+v", dev.Actuators)
+	}
+
+	// Results are copies
+	dev.Sensors[0].Tags[0] = "changed"
+	sensor, err := store.GetSensorByTag(ctx, "tank.temp")
+	if err != nil {
+		t.Fatalf("GetSensorByTag() error = %v", err)
+	}
+	if sensor.ID != "temp" {
+		t.Errorf("Expected sensor temp, got %s", sensor.ID)
+	}
+
+	if _, err := store.GetDevice(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemory_CreateDevices(t *testing.T) {
+	checkCreateDevices(t, NewMemory())
+}
+
+// checkCreateDevices checks store creates a batch of devices, skipping those that fail
+// without leaving any of their sensors behind
+func checkCreateDevices(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	devs := []*api.Device{
+		{ID: "dev-a", Driver: api.DriverShelly, Name: "A", Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature"}}},
+		{ID: "dev-a", Driver: api.DriverShelly, Name: "A again"},
+		{ID: "dev-b", Driver: api.DriverShelly, Name: "B", Sensors: []*api.Sensor{{ID: "temp", Name: "One"}, {ID: "temp", Name: "Two"}}},
+		{ID: "dev-c", Driver: api.DriverShelly, Name: "C"},
+	}
+	errs, err := store.CreateDevices(ctx, devs)
+	if err != nil {
+		t.Fatalf("CreateDevices() error = %v", err)
+	}
+	if len(errs) != len(devs) {
+		t.Fatalf("Expected %d results, got %d", len(devs), len(errs))
+	}
+	if errs[0] != nil || errs[3] != nil {
+		t.Errorf("Expected dev-a and dev-c to be created, got %v and %v", errs[0], errs[3])
+	}
+	if !errors.Is(errs[1], ErrAlreadyExists) || !errors.Is(errs[2], ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for the duplicates, got %v and %v", errs[1], errs[2])
+	}
+	if devs[0].Version != 1 || devs[0].ExternalID == "" {
+		t.Errorf("Expected created device to be given a version and external id, got %+v", devs[0])
+	}
+
+	devices, err := store.ListDevices(ctx)
+	if err != nil {
+		t.Fatalf("ListDevices() error = %v", err)
+	}
+	if len(devices) != 2 {
+		t.Errorf("Expected 2 devices, got %d", len(devices))
+	}
+	if _, err := store.GetDevice(ctx, "dev-b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the failed device not to be stored, got %v", err)
+	}
+	sensors, err := store.ListSensors(ctx)
+	if err != nil {
+		t.Fatalf("ListSensors() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].DeviceID != "dev-a" {
+		t.Errorf("Expected only dev-a's sensor, got %+v", sensors)
+	}
+}
+
+func TestMemory_PatchDevices(t *testing.T) {
+	checkPatchDevices(t, NewMemory())
+}
+
+func TestMemory_PatchDevicesConcurrently(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "plug-1", Driver: api.DriverShelly, Name: "Plug 1"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	// Each patch sets its own key, so none conflicts with another or loses its key
+	sel := api.DeviceSelector{Driver: api.DriverShelly}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			value := "set"
+			_, errs, err := store.PatchDevices(ctx, sel, &api.DevicePatch{Metadata: map[string]*string{key: &value}})
+			if err != nil || len(errs) != 1 || errs[0] != nil {
+				t.Errorf("Expected the patch of %s to apply, got %v, %v", key, errs, err)
+			}
+		}(fmt.Sprintf("key-%d", i))
+	}
+	wg.Wait()
+
+	dev, err := store.GetDevice(ctx, "plug-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if len(dev.Metadata) != 20 || dev.Version != 21 {
+		t.Errorf("Expected every key at version 21, got %d keys at version %d", len(dev.Metadata), dev.Version)
+	}
+}
+
+// checkPatchDevices checks store patches every device a selector matches, leaving those
+// that fail as they were
+func checkPatchDevices(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	devs := []*api.Device{
+		{ID: "plug-1", Driver: api.DriverShelly, Name: "Plug 1", Metadata: map[string]string{"site": "north", "mqtt_key": "old"}},
+		{ID: "plug-2", Driver: api.DriverShelly, Name: "Plug 2", Metadata: map[string]string{"site": "north", "mqtt_key": "old"}},
+		{ID: "plug-3", Driver: api.DriverShelly, Name: "Plug 3", Metadata: map[string]string{"site": "south", "mqtt_key": "old"}},
+		{ID: "station", Driver: api.DriverStation, Name: "Station", Metadata: map[string]string{"site": "north"}},
+	}
+	if _, err := store.CreateDevices(ctx, devs); err != nil {
+		t.Fatalf("CreateDevices() error = %v", err)
+	}
+
+	key := "new"
+	sel := api.DeviceSelector{Driver: api.DriverShelly, Metadata: map[string]string{"site": "north"}}
+	devices, errs, err := store.PatchDevices(ctx, sel, &api.DevicePatch{Metadata: map[string]*string{"mqtt_key": &key}})
+	if err != nil {
+		t.Fatalf("PatchDevices() error = %v", err)
+	}
+	if len(devices) != 2 || devices[0].ID != "plug-1" || devices[1].ID != "plug-2" || len(errs) != 2 {
+		t.Fatalf("Expected plug-1 and plug-2 to match, got %+v", devices)
+	}
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("Expected both to be patched, got %v and %v", errs[0], errs[1])
+	}
+	for _, id := range []string{"plug-1", "plug-2"} {
+		dev, err := store.GetDevice(ctx, id)
+		if err != nil {
+			t.Fatalf("GetDevice() error = %v", err)
+		}
+		if dev.Metadata["mqtt_key"] != "new" || dev.Metadata["site"] != "north" || dev.Version != 2 {
+			t.Errorf("Expected %s's mqtt_key to be replaced at version 2, got %+v", id, dev)
+		}
+	}
+	if dev, _ := store.GetDevice(ctx, "plug-3"); dev.Metadata["mqtt_key"] != "old" || dev.Version != 1 {
+		t.Errorf("Expected plug-3 to be left alone, got %+v", dev)
+	}
+
+	// Patching again changes nothing, so nothing is updated
+	devices, _, err = store.PatchDevices(ctx, sel, &api.DevicePatch{Metadata: map[string]*string{"mqtt_key": &key}})
+	if err != nil {
+		t.Fatalf("PatchDevices() error = %v", err)
+	}
+	if len(devices) != 2 || devices[0].Version != 2 {
+		t.Errorf("Expected an unchanged device not to be updated, got %+v", devices)
+	}
+
+	// Tags are unique, so only the first device can take one; the other is left as it was
+	sel = api.DeviceSelector{Tags: []string{"device.plug-*"}, Metadata: map[string]string{"site": "north"}}
+	devices, errs, err = store.PatchDevices(ctx, sel, &api.DevicePatch{
+		Metadata: map[string]*string{"mqtt_key": nil},
+		AddTags:  []string{"north.plug"},
+	})
+	if err != nil {
+		t.Fatalf("PatchDevices() error = %v", err)
+	}
+	if len(errs) != 2 || errs[0] != nil || !errors.Is(errs[1], ErrAlreadyExists) {
+		t.Fatalf("Expected plug-2 to fail on the tag conflict, got %v", errs)
+	}
+	if dev, _ := store.GetDevice(ctx, "plug-1"); !slices.Contains(dev.Tags, "north.plug") || dev.Metadata["mqtt_key"] != "" {
+		t.Errorf("Expected plug-1 to be patched, got %+v", dev)
+	}
+	if dev, _ := store.GetDevice(ctx, "plug-2"); slices.Contains(dev.Tags, "north.plug") || dev.Metadata["mqtt_key"] != "new" || dev.Version != 2 {
+		t.Errorf("Expected plug-2 to be left as it was, got %+v", dev)
+	}
+}
+
+func TestMemory_PatchEntities(t *testing.T) {
+	checkPatchEntities(t, NewMemory())
+}
+
+// mergePatch decodes a JSON merge patch into update
+func mergePatch(t *testing.T, patch string, update any) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(patch), update); err != nil {
+		t.Fatalf("Failed to decode %s: %v", patch, err)
+	}
+}
+
+// checkPatchEntities checks store applies merge patches to a device, sensor and
+// actuator, changing only the fields they name
+func checkPatchEntities(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	dev := newMemoryDevice()
+	dev.Description = "Sump"
+	dev.Metadata = map[string]string{"site": "north", "room": "a"}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	var update api.DeviceMergePatch
+	mergePatch(t, `{"name": "Renamed", "metadata": {"room": null, "rack": "2"}}`, &update)
+	patched, err := store.PatchDevice(ctx, "dev-1", &update)
+	if err != nil {
+		t.Fatalf("PatchDevice() error = %v", err)
+	}
+	if patched.Name != "Renamed" || patched.Description != "Sump" || patched.Version != 2 || len(patched.Sensors) != 2 || len(patched.Actuators) != 1 {
+		t.Errorf("Expected only the name and metadata to change, got %+v", patched)
+	}
+	got, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if got.Name != "Renamed" || got.Description != "Sump" || got.Metadata["site"] != "north" || got.Metadata["rack"] != "2" || len(got.Metadata) != 2 {
+		t.Errorf("Expected the patch to be stored, got %+v", got)
+	}
+
+	// Nulls clear, but the default tag stays
+	mergePatch(t, `{"description": null, "tags": null}`, &update)
+	if patched, err = store.PatchDevice(ctx, "dev-1", &update); err != nil {
+		t.Fatalf("PatchDevice() error = %v", err)
+	}
+	if patched.Description != "" || !slices.Equal(patched.Tags, []string{got.DefaultTag()}) || patched.Version != 3 {
+		t.Errorf("Expected the description cleared and only the default tag, got %+v", patched)
+	}
+
+	// A patch leaving the device as it was isn't written
+	mergePatch(t, `{"name": "Renamed"}`, &update)
+	if patched, err = store.PatchDevice(ctx, "dev-1", &update); err != nil || patched.Version != 3 {
+		t.Errorf("Expected an unchanged device not to be updated, got %+v, %v", patched, err)
+	}
+	mergePatch(t, `{"name": "Stale", "version": 2}`, &update)
+	if _, err := store.PatchDevice(ctx, "dev-1", &update); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for a stale version, got %v", err)
+	}
+	if _, err := store.PatchDevice(ctx, "missing", &update); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	var sensorUpdate api.SensorMergePatch
+	mergePatch(t, `{"name": "Water temperature", "version": 1}`, &sensorUpdate)
+	sensor, err := store.PatchSensor(ctx, "dev-1", "temp", &sensorUpdate)
+	if err != nil {
+		t.Fatalf("PatchSensor() error = %v", err)
+	}
+	if sensor.Name != "Water temperature" || sensor.SensorType != api.SensorTypeTemperature || !slices.Contains(sensor.Tags, "tank.temp") || sensor.Version != 2 {
+		t.Errorf("Expected only the sensor's name to change, got %+v", sensor)
+	}
+	mergePatch(t, `{"tags": ["tank.temp"]}`, &sensorUpdate)
+	if _, err := store.PatchSensor(ctx, "dev-1", "ph", &sensorUpdate); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for a tag conflict, got %v", err)
+	}
+	if sensor, _ := store.GetSensor(ctx, "dev-1", "ph"); slices.Contains(sensor.Tags, "tank.temp") || sensor.Version != 1 {
+		t.Errorf("Expected the conflicting sensor to be left as it was, got %+v", sensor)
+	}
+
+	var actuatorUpdate api.ActuatorMergePatch
+	mergePatch(t, `{"metadata": {"channel": "1"}}`, &actuatorUpdate)
+	actuator, err := store.PatchActuator(ctx, "dev-1", "pump", &actuatorUpdate)
+	if err != nil {
+		t.Fatalf("PatchActuator() error = %v", err)
+	}
+	if actuator.Name != "Pump" || actuator.Metadata["channel"] != "1" || actuator.Version != 2 {
+		t.Errorf("Expected only the actuator's metadata to change, got %+v", actuator)
+	}
+	if got, _ := store.GetActuator(ctx, "dev-1", "pump"); got.Metadata["channel"] != "1" || got.Version != 2 {
+		t.Errorf("Expected the patch to be stored, got %+v", got)
+	}
+	if _, err := store.PatchActuator(ctx, "dev-1", "missing", &actuatorUpdate); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemory_TagConflicts(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	other := &api.Device{
+		ID:      "dev-2",
+		Name:    "Other",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", Tags: []string{"tank.temp"}}},
+	}
+	if err := store.CreateDevice(ctx, other); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for sensor tag conflict, got %v", err)
+	}
+	if _, err := store.GetDevice(ctx, "dev-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected failed create to store nothing, got %v", err)
+	}
+
+	other.Sensors = nil
+	if err := store.CreateDevice(ctx, other); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	other.Tags = []string{"device.dev-1"}
+	if err := store.UpdateDevice(ctx, other); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for device tag conflict, got %v", err)
+	}
+
+	sensor := &api.Sensor{ID: "temp", DeviceID: "dev-2", Name: "Temperature", Tags: []string{"tank.temp"}}
+	if err := store.CreateSensor(ctx, sensor); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for sensor tag conflict, got %v", err)
+	}
+	sensor.DeviceID = "missing"
+	if err := store.CreateSensor(ctx, sensor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing device, got %v", err)
+	}
+}
+
+func TestMemory_TagPrefixAndUpdates(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	sensors, err := store.ListSensorsByTagPrefix(ctx, "tank.")
+	if err != nil {
+		t.Fatalf("ListSensorsByTagPrefix() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].ID != "temp" {
+		t.Errorf("Expected only temp under tank., got %+v", sensors)
+	}
+
+	actuator := &api.Actuator{ID: "pump", DeviceID: "dev-1", Name: "Main Pump", Tags: []string{"tank.pump"}, Version: 1}
+	if err := store.UpdateActuator(ctx, actuator); err != nil {
+		t.Fatalf("UpdateActuator() error = %v", err)
+	}
+	got, err := store.GetActuatorByTag(ctx, "tank.pump")
+	if err != nil {
+		t.Fatalf("GetActuatorByTag() error = %v", err)
+	}
+	if got.Name != "Main Pump" {
+		t.Errorf("Expected updated name, got %s", got.Name)
+	}
+
+	actuator.ID = "missing"
+	if err := store.UpdateActuator(ctx, actuator); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemory_ListTagTree(t *testing.T) {
+	checkListTagTree(t, NewMemory())
+}
+
+// checkListTagTree checks store counts each entity once at every level of the tag
+// hierarchy its tags are at or beneath
+func checkListTagTree(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "display", Driver: api.DriverShelly, Name: "Display", Tags: []string{"tank.display"},
+			Sensors: []*api.Sensor{
+				{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Tags: []string{"tank.display.temp"}},
+				{ID: "ph", Name: "pH", SensorType: api.SensorTypePH, Tags: []string{"tank.display.ph"}},
+			},
+			Actuators: []*api.Actuator{
+				{ID: "heater", Name: "Heater", ActuatorType: api.ActuatorTypeRelay, Tags: []string{"tank.display.heater", "heaters.main"}},
+			}},
+		{ID: "sump", Driver: api.DriverShelly, Name: "Sump", Tags: []string{"tank.sump", "tank"}},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+
+	tree, err := store.ListTagTree(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTagTree() error = %v", err)
+	}
+	// Devices also carry their default tags, beneath "device"
+	var roots []string
+	for _, c := range tree.Children {
+		roots = append(roots, c.Tag)
+	}
+	if want := []string{"device", "heaters", "tank"}; !slices.Equal(roots, want) {
+		t.Fatalf("Expected the trees %v, got %v", want, roots)
+	}
+	if want := (api.TagCounts{Devices: 2, Sensors: 2, Actuators: 1}); tree.Children[2].Counts != want {
+		t.Errorf("Expected tank counts %+v, got %+v", want, tree.Children[2].Counts)
+	}
+
+	tree, err = store.ListTagTree(ctx, "tank.display")
+	if err != nil {
+		t.Fatalf("ListTagTree() error = %v", err)
+	}
+	if tree.Name != "display" || tree.Counts != (api.TagCounts{Devices: 1, Sensors: 2, Actuators: 1}) {
+		t.Errorf("Expected the display node with its counts, got %+v", tree)
+	}
+	var tags []string
+	for _, c := range tree.Children {
+		tags = append(tags, c.Tag)
+	}
+	if want := []string{"tank.display.heater", "tank.display.ph", "tank.display.temp"}; !slices.Equal(tags, want) {
+		t.Errorf("Expected children %v, got %v", want, tags)
+	}
+
+	if tree, err := store.ListTagTree(ctx, "pond"); err != nil || len(tree.Children) != 0 || tree.Counts != (api.TagCounts{}) {
+		t.Errorf("Expected an empty tree for an unused prefix, got %+v, %v", tree, err)
+	}
+}
+
+func TestMemory_TagAliases(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "main.temp", Target: "tank.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+	sensor, err := store.GetSensorByTag(ctx, "main.temp")
+	if err != nil {
+		t.Fatalf("GetSensorByTag() via alias error = %v", err)
+	}
+	if sensor.ID != "temp" {
+		t.Errorf("Expected alias to resolve to temp, got %s", sensor.ID)
+	}
+
+	// Renaming the underlying tag and retargeting the alias keeps the alias working
+	sensor.Tags = []string{"sump.temp"}
+	if err := store.UpdateSensor(ctx, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	if _, err := store.GetSensorByTag(ctx, "main.temp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for stale alias, got %v", err)
+	}
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "main.temp", Target: "sump.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() retarget error = %v", err)
+	}
+	if sensor, err := store.GetSensorByTag(ctx, "main.temp"); err != nil || sensor.ID != "temp" {
+		t.Errorf("Expected retargeted alias to resolve to temp, got %+v, %v", sensor, err)
+	}
+
+	// Real tags take precedence over aliases
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "device.dev-1.sensor.ph", Target: "sump.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+	if sensor, err := store.GetSensorByTag(ctx, "device.dev-1.sensor.ph"); err != nil || sensor.ID != "ph" {
+		t.Errorf("Expected real tag to win over alias, got %+v, %v", sensor, err)
+	}
+
+	aliases, err := store.ListTagAliases(ctx)
+	if err != nil {
+		t.Fatalf("ListTagAliases() error = %v", err)
+	}
+	if len(aliases) != 2 || aliases[0].Alias != "device.dev-1.sensor.ph" {
+		t.Errorf("Expected 2 aliases ordered by name, got %+v", aliases)
+	}
+	if err := store.DeleteTagAlias(ctx, "main.temp"); err != nil {
+		t.Fatalf("DeleteTagAlias() error = %v", err)
+	}
+	if err := store.DeleteTagAlias(ctx, "main.temp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting missing alias, got %v", err)
+	}
+}
+
+func TestMemory_MetadataFilter(t *testing.T) {
+	checkMetadataFilter(t, NewMemory())
+}
+
+// checkMetadataFilter checks store lists only items whose metadata contains the filter
+func checkMetadataFilter(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "dev-a", Driver: api.DriverShelly, Name: "A", Metadata: map[string]string{"location": "sump", "room": "garage"},
+			Sensors:   []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature, Metadata: map[string]string{"probe": "titanium"}}},
+			Actuators: []*api.Actuator{{ID: "pump", Name: "Pump", ActuatorType: api.ActuatorTypeRelay, Metadata: map[string]string{"location": "sump"}}}},
+		{ID: "dev-b", Driver: api.DriverShelly, Name: "B", Metadata: map[string]string{"location": "display"},
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}},
+		{ID: "dev-c", Driver: api.DriverShelly, Name: "C"},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+
+	devices, _, err := store.ListDevicesPage(ctx, Page{Metadata: map[string]string{"location": "sump"}})
+	if err != nil {
+		t.Fatalf("ListDevicesPage() error = %v", err)
+	}
+	if len(devices) != 1 || devices[0].ID != "dev-a" {
+		t.Errorf("Expected only dev-a in the sump, got %+v", devices)
+	}
+	devices, _, err = store.ListDevicesPage(ctx, Page{Metadata: map[string]string{"location": "sump", "room": "lounge"}})
+	if err != nil {
+		t.Fatalf("ListDevicesPage() error = %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("Expected every filter to match, got %+v", devices)
+	}
+	if devices, _, _ = store.ListDevicesPage(ctx, Page{}); len(devices) != 3 {
+		t.Errorf("Expected every device without a filter, got %d", len(devices))
+	}
+
+	sensors, _, err := store.ListSensorsPage(ctx, "", Page{Limit: 10, Metadata: map[string]string{"probe": "titanium"}})
+	if err != nil {
+		t.Fatalf("ListSensorsPage() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].DeviceID != "dev-a" {
+		t.Errorf("Expected only dev-a's sensor, got %+v", sensors)
+	}
+	actuators, _, err := store.ListActuatorsPage(ctx, "dev-b", Page{Metadata: map[string]string{"location": "sump"}})
+	if err != nil {
+		t.Fatalf("ListActuatorsPage() error = %v", err)
+	}
+	if len(actuators) != 0 {
+		t.Errorf("Expected no dev-b actuators in the sump, got %+v", actuators)
+	}
+}
+
+func TestMemory_ReadingLabels(t *testing.T) {
+	checkReadingLabels(t, NewMemory())
+}
+
+// checkReadingLabels checks store creates, filters and deletes reading labels
+func checkReadingLabels(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	labels := []*api.ReadingLabel{
+		{Key: "experiment", Value: "reduced-photoperiod", DeviceID: "dev-1", SensorID: "temp", Start: start.Add(48 * time.Hour), End: start.Add(72 * time.Hour)},
+		{Key: "experiment", Value: "control", Start: start, End: start.Add(24 * time.Hour), Note: "baseline"},
+		{Key: "experiment", Value: "dosing", DeviceID: "dev-2", Start: start, End: start.Add(24 * time.Hour)},
+	}
+	for _, l := range labels {
+		if err := store.CreateReadingLabel(ctx, l); err != nil {
+			t.Fatalf("CreateReadingLabel() error = %v", err)
+		}
+		if l.ID == 0 || l.CreatedAt.IsZero() {
+			t.Fatalf("Expected an ID and creation time, got %+v", l)
+		}
+	}
+
+	got, err := store.ListReadingLabels(ctx, ReadingLabelFilters{Key: "experiment", DeviceID: "dev-1", SensorID: "temp"})
+	if err != nil {
+		t.Fatalf("ListReadingLabels() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Value != "control" || got[0].Note != "baseline" || got[1].Value != "reduced-photoperiod" {
+		t.Fatalf("Expected the global and dev-1 labels by start, got %+v", got)
+	}
+	if !got[1].Start.Equal(labels[0].Start) || !got[1].End.Equal(labels[0].End) {
+		t.Errorf("Expected window %v-%v, got %v-%v", labels[0].Start, labels[0].End, got[1].Start, got[1].End)
+	}
+
+	from, to := start.Add(24*time.Hour), start.Add(96*time.Hour)
+	if got, _ = store.ListReadingLabels(ctx, ReadingLabelFilters{StartTime: &from, EndTime: &to}); len(got) != 1 || got[0].ID != labels[0].ID {
+		t.Errorf("Expected only the label overlapping the window, got %+v", got)
+	}
+
+	if err := store.DeleteReadingLabel(ctx, labels[1].ID); err != nil {
+		t.Fatalf("DeleteReadingLabel() error = %v", err)
+	}
+	if err := store.DeleteReadingLabel(ctx, labels[1].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if got, _ = store.ListReadingLabels(ctx, ReadingLabelFilters{}); len(got) != 2 {
+		t.Errorf("Expected 2 labels left, got %d", len(got))
+	}
+}
+
+func TestMemory_RuleTraces(t *testing.T) {
+	checkRuleTraces(t, NewMemory())
+}
+
+// checkRuleTraces checks store keeps only each rule's newest traces
+func checkRuleTraces(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	value := 0.0
+	for i := 0; i < 5; i++ {
+		trace := &api.RuleTrace{
+			Kind:      api.RuleKindComposite,
+			Name:      "heater-no-flow",
+			Timestamp: start.Add(time.Duration(i) * 10 * time.Second),
+			Outcome:   api.TraceIdle,
+			Inputs:    []api.TraceInput{{Tag: "flow.main", Value: &value, Valid: true}},
+		}
+		if err := store.RecordRuleTrace(ctx, trace, 3); err != nil {
+			t.Fatalf("RecordRuleTrace() error = %v", err)
+		}
+		if trace.ID == 0 {
+			t.Error("Expected the trace to be assigned an ID")
+		}
+	}
+	other := &api.RuleTrace{Kind: api.RuleKindDryRun, Name: "return", Timestamp: start, Outcome: api.TraceFired}
+	if err := store.RecordRuleTrace(ctx, other, 3); err != nil {
+		t.Fatalf("RecordRuleTrace() error = %v", err)
+	}
+
+	traces, err := store.ListRuleTraces(ctx, api.RuleKindComposite, "heater-no-flow", 10)
+	if err != nil {
+		t.Fatalf("ListRuleTraces() error = %v", err)
+	}
+	if len(traces) != 3 {
+		t.Fatalf("Expected the newest 3 traces, got %d", len(traces))
+	}
+	if want := start.Add(40 * time.Second); !traces[0].Timestamp.Equal(want) {
+		t.Errorf("Expected newest trace at %s first, got %s", want, traces[0].Timestamp)
+	}
+	if len(traces[0].Inputs) != 1 || traces[0].Inputs[0].Value == nil || traces[0].Inputs[0].Tag != "flow.main" {
+		t.Errorf("Expected the trace's inputs to round-trip, got %+v", traces[0].Inputs)
+	}
+
+	traces, err = store.ListRuleTraces(ctx, api.RuleKindComposite, "heater-no-flow", 1)
+	if err != nil {
+		t.Fatalf("ListRuleTraces() error = %v", err)
+	}
+	if len(traces) != 1 {
+		t.Errorf("Expected limit 1 to return 1 trace, got %d", len(traces))
+	}
+	traces, err = store.ListRuleTraces(ctx, api.RuleKindDryRun, "return", 10)
+	if err != nil {
+		t.Fatalf("ListRuleTraces() error = %v", err)
+	}
+	if len(traces) != 1 || traces[0].Outcome != api.TraceFired {
+		t.Errorf("Expected the other rule's trace kept, got %+v", traces)
+	}
+}
+
+func TestMemory_LatestReadings(t *testing.T) {
+	checkLatestReadings(t, NewMemory())
+}
+
+// checkLatestReadings checks store tracks the latest reading of each sensor through
+// out-of-order writes and deletions
+func checkLatestReadings(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if readings, err := store.GetLatestReadingsForDevice(ctx, "dev-1"); err != nil || len(readings) != 0 {
+		t.Fatalf("Expected no latest readings yet, got %v, %v", readings, err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	reading := func(sensorID string, value float64, at time.Time) *api.ReadingRecord {
+		return &api.ReadingRecord{DeviceID: "dev-1", SensorID: sensorID,
+			Reading: api.SensorReading{Value: value, Valid: true, Timestamp: at}}
+	}
+	if err := store.StoreSensorReadings(ctx, []*api.ReadingRecord{
+		reading("temp", 24, now.Add(-time.Hour)),
+		reading("temp", 25, now),
+		// Arrives late, so isn't the latest
+		reading("temp", 23, now.Add(-2*time.Hour)),
+		reading("ph", 8.1, now.Add(-3*time.Hour)),
+	}); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+	// Of two readings at the same time the last stored is the latest
+	if err := store.StoreSensorReading(ctx, reading("ph", 8.2, now.Add(-3*time.Hour))); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+
+	readings, err := store.GetLatestReadingsForDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetLatestReadingsForDevice() error = %v", err)
+	}
+	if len(readings) != 2 || readings[0].SensorID != "ph" || readings[1].SensorID != "temp" {
+		t.Fatalf("Expected the latest ph and temp readings, got %v", readings)
+	}
+	if readings[0].Reading.Value != 8.2 || readings[1].Reading.Value != 25 || !readings[1].Reading.Timestamp.Equal(now) {
+		t.Errorf("Expected ph 8.2 and temp 25, got %+v and %+v", readings[0].Reading, readings[1].Reading)
+	}
+	latest, err := store.GetLatestSensorReading(ctx, "dev-1", "temp")
+	if err != nil || latest.Value != 25 {
+		t.Errorf("Expected the latest temp reading 25, got %v, %v", latest, err)
+	}
+
+	// Pruning every ph reading drops its latest reading too; temp keeps its newest
+	if _, err := store.DeleteOldSensorReadings(ctx, now.Add(-30*time.Minute)); err != nil {
+		t.Fatalf("DeleteOldSensorReadings() error = %v", err)
+	}
+	if _, err := store.GetLatestSensorReading(ctx, "dev-1", "ph"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for the pruned sensor, got %v", err)
+	}
+	if readings, err = store.GetLatestReadingsForDevice(ctx, "dev-1"); err != nil || len(readings) != 1 || readings[0].Reading.Value != 25 {
+		t.Errorf("Expected only the temp reading left, got %v, %v", readings, err)
+	}
+	if err := store.DeleteSensor(ctx, "dev-1", "temp"); err != nil {
+		t.Fatalf("DeleteSensor() error = %v", err)
+	}
+	if readings, err = store.GetLatestReadingsForDevice(ctx, "dev-1"); err != nil || len(readings) != 0 {
+		t.Errorf("Expected no latest readings once the sensor is deleted, got %v, %v", readings, err)
+	}
+}
+
+func TestMemory_AggregatedReadings(t *testing.T) {
+	checkAggregatedReadings(t, NewMemory())
+}
+
+// checkAggregatedReadings checks store buckets a sensor's valid, measured readings within
+// a window, reporting only the statistics asked for
+func checkAggregatedReadings(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	// Three readings a minute for three minutes, then some which don't count
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var recs []*api.ReadingRecord
+	for i := 0; i < 9; i++ {
+		recs = append(recs, &api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp",
+			Reading: api.SensorReading{Value: float64(i), Valid: true, Timestamp: base.Add(time.Duration(i) * 20 * time.Second)}})
+	}
+	recs = append(recs,
+		&api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp",
+			Reading: api.SensorReading{Value: 100, Valid: false, Error: "timeout", Timestamp: base.Add(10 * time.Second)}},
+		&api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp",
+			Reading: api.SensorReading{Value: 100, Valid: true, Synthetic: true, Timestamp: base.Add(30 * time.Second)}},
+		&api.ReadingRecord{DeviceID: "dev-1", SensorID: "ph",
+			Reading: api.SensorReading{Value: 100, Valid: true, Timestamp: base.Add(30 * time.Second)}},
+	)
+	if err := store.StoreSensorReadings(ctx, recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+
+	// The window leaves out the first reading and the last minute
+	buckets, err := store.GetAggregatedReadings(ctx, "dev-1", "temp", time.Minute,
+		[]api.AggregateFunc{api.AggregateAvg, api.AggregateMax, api.AggregateCount}, base.Add(20*time.Second), base.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings() error = %v", err)
+	}
+	if len(buckets) != 2 {
+		t.Fatalf("Expected 2 buckets, got %d", len(buckets))
+	}
+	for i, want := range []struct {
+		start    time.Time
+		avg, max float64
+		count    int64
+	}{
+		{base, 1.5, 2, 2},
+		{base.Add(time.Minute), 4, 5, 3},
+	} {
+		b := buckets[i]
+		if !b.Start.Equal(want.start) || b.Avg == nil || *b.Avg != want.avg || b.Max == nil || *b.Max != want.max || b.Count == nil || *b.Count != want.count {
+			t.Errorf("Expected bucket %d at %s with avg %v, max %v and count %d, got %+v", i, want.start, want.avg, want.max, want.count, b)
+		}
+		if b.Min != nil {
+			t.Errorf("Expected no min in bucket %d, got %v", i, *b.Min)
+		}
+	}
+
+	buckets, err = store.GetAggregatedReadings(ctx, "dev-1", "temp", time.Hour, api.AggregateFuncs, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings() error = %v", err)
+	}
+	if len(buckets) != 1 || *buckets[0].Min != 0 || *buckets[0].Max != 8 || *buckets[0].Count != 9 {
+		t.Errorf("Expected one hourly bucket of 9 readings, got %+v", buckets)
+	}
+	if _, err := store.GetAggregatedReadings(ctx, "dev-1", "temp", time.Millisecond, api.AggregateFuncs, base, base.Add(time.Hour)); err == nil {
+		t.Error("Expected an error for a sub-second interval")
+	}
+}
+
+func TestMemory_Retention(t *testing.T) {
+	checkRetention(t, NewMemory())
+}
+
+// checkRetention checks store manages retention policies and deletes only the old
+// readings of a sensor type
+func checkRetention(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	for _, policy := range []*api.RetentionPolicy{
+		{SensorType: api.SensorTypeTemperature, Retention: time.Hour},
+		{SensorType: api.SensorTypePH, Retention: 24 * time.Hour},
+		{SensorType: api.SensorTypeTemperature, Retention: 90 * time.Minute},
+	} {
+		if err := store.SetRetentionPolicy(ctx, policy); err != nil {
+			t.Fatalf("SetRetentionPolicy() error = %v", err)
+		}
+		if policy.UpdatedAt.IsZero() {
+			t.Errorf("Expected an update time, got %+v", policy)
+		}
+	}
+	policies, err := store.ListRetentionPolicies(ctx)
+	if err != nil {
+		t.Fatalf("ListRetentionPolicies() error = %v", err)
+	}
+	if len(policies) != 2 || policies[0].SensorType != api.SensorTypePH || policies[1].Retention != 90*time.Minute {
+		t.Errorf("Expected the pH and replaced temperature policies, got %+v", policies)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, sensorID := range []string{"temp", "ph"} {
+		for i := 0; i < 3; i++ {
+			rec := &api.ReadingRecord{
+				DeviceID: "dev-1",
+				SensorID: sensorID,
+				Reading:  api.SensorReading{Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Hour), Valid: true},
+			}
+			if err := store.StoreSensorReading(ctx, rec); err != nil {
+				t.Fatalf("StoreSensorReading() error = %v", err)
+			}
+		}
+	}
+	deleted, err := store.DeleteSensorReadingsByType(ctx, api.SensorTypeTemperature, base.Add(90*time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteSensorReadingsByType() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 temperature readings deleted, got %d", deleted)
+	}
+	if n, _ := store.CountSensorReadings(ctx, SensorReadingFilters{SensorID: "ph"}); n != 3 {
+		t.Errorf("Expected the pH readings kept, got %d", n)
+	}
+
+	if err := store.DeleteRetentionPolicy(ctx, api.SensorTypePH); err != nil {
+		t.Fatalf("DeleteRetentionPolicy() error = %v", err)
+	}
+	if err := store.DeleteRetentionPolicy(ctx, api.SensorTypePH); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if policies, _ = store.ListRetentionPolicies(ctx); len(policies) != 1 {
+		t.Errorf("Expected 1 policy left, got %d", len(policies))
+	}
+}
+
+func TestMemory_StorageQuotas(t *testing.T) {
+	checkStorageQuotas(t, NewMemory())
+}
+
+// checkStorageQuotas checks store manages storage quotas, reports reading usage and
+// thins old readings to the last of each interval
+func checkStorageQuotas(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	for _, quota := range []*api.StorageQuota{
+		{DeviceID: "dev-1", SensorID: "temp", MaxReadings: 100},
+		{DeviceID: "dev-1", SensorID: "ph", MaxReadings: 10},
+		{DeviceID: "dev-1", SensorID: "temp", MaxReadings: 5, Downsample: time.Hour},
+	} {
+		if err := store.SetStorageQuota(ctx, quota); err != nil {
+			t.Fatalf("SetStorageQuota() error = %v", err)
+		}
+		if quota.UpdatedAt.IsZero() {
+			t.Errorf("Expected an update time, got %+v", quota)
+		}
+	}
+	if err := store.SetStorageQuota(ctx, &api.StorageQuota{DeviceID: "dev-1", SensorID: "missing", MaxReadings: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown sensor, got %v", err)
+	}
+	quotas, err := store.ListStorageQuotas(ctx)
+	if err != nil {
+		t.Fatalf("ListStorageQuotas() error = %v", err)
+	}
+	if len(quotas) != 2 || quotas[0].SensorID != "ph" || quotas[1].MaxReadings != 5 || quotas[1].Downsample != time.Hour {
+		t.Errorf("Expected the ph and replaced temp quotas, got %+v", quotas)
+	}
+
+	// Four temp readings in each of three hours, and one recent ph reading
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var recs []*api.ReadingRecord
+	for i := 0; i < 12; i++ {
+		recs = append(recs, &api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp",
+			Reading: api.SensorReading{Value: float64(i), Timestamp: base.Add(time.Duration(i) * 15 * time.Minute), Valid: true}})
+	}
+	recs = append(recs, &api.ReadingRecord{DeviceID: "dev-1", SensorID: "ph",
+		Reading: api.SensorReading{Value: 8, Timestamp: base.Add(150 * time.Minute), Valid: true}})
+	if err := store.StoreSensorReadings(ctx, recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+
+	usage, err := store.SensorReadingUsage(ctx, base.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("SensorReadingUsage() error = %v", err)
+	}
+	if len(usage) != 2 || usage[0].SensorID != "ph" || usage[1].Readings != 12 || usage[1].Recent != 4 {
+		t.Errorf("Expected 1 ph and 12 temp readings, 4 recent, got %+v", usage)
+	}
+	tables, err := store.TableUsage(ctx)
+	if err != nil {
+		t.Fatalf("TableUsage() error = %v", err)
+	}
+	var readingRows int64 = -1
+	for _, table := range tables {
+		if table.Table == "sensor_readings" {
+			readingRows = table.Rows
+		}
+	}
+	if readingRows != 13 {
+		t.Errorf("Expected 13 rows in sensor_readings, got %d in %+v", readingRows, tables)
+	}
+
+	// The first two hours thin to their last reading; the third is left alone
+	thinned, err := store.ThinSensorReadings(ctx, "dev-1", "temp", base.Add(2*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatalf("ThinSensorReadings() error = %v", err)
+	}
+	if thinned != 6 {
+		t.Errorf("Expected 6 readings thinned, got %d", thinned)
+	}
+	readings, err := store.GetSensorReadings(ctx, SensorReadingFilters{DeviceID: "dev-1", SensorID: "temp"})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	var values []float64
+	for _, r := range readings {
+		values = append(values, r.Reading.Value)
+	}
+	if !slices.Equal(values, []float64{11, 10, 9, 8, 7, 3}) {
+		t.Errorf("Expected the last reading of each thinned hour kept, got %v", values)
+	}
+	if latest, err := store.GetLatestSensorReading(ctx, "dev-1", "temp"); err != nil || latest.Value != 11 {
+		t.Errorf("Expected the latest reading kept, got %v, %v", latest, err)
+	}
+	if n, _ := store.CountSensorReadings(ctx, SensorReadingFilters{SensorID: "ph"}); n != 1 {
+		t.Errorf("Expected the ph reading kept, got %d", n)
+	}
+
+	if err := store.DeleteStorageQuota(ctx, "dev-1", "ph"); err != nil {
+		t.Fatalf("DeleteStorageQuota() error = %v", err)
+	}
+	if err := store.DeleteStorageQuota(ctx, "dev-1", "ph"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+	if err := store.DeleteSensor(ctx, "dev-1", "temp"); err != nil {
+		t.Fatalf("DeleteSensor() error = %v", err)
+	}
+	if quotas, _ = store.ListStorageQuotas(ctx); len(quotas) != 0 {
+		t.Errorf("Expected the deleted sensor's quota gone, got %+v", quotas)
+	}
+}
+
+func TestMemory_TagTemplates(t *testing.T) {
+	checkTagTemplates(t, NewMemory())
+}
+
+// checkTagTemplates checks store tags new entities by the tag templates, falling back to
+// the built-in tags when a template's values are missing
+func checkTagTemplates(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	for _, template := range []*api.TagTemplate{
+		{Kind: api.TagKindDevice, Template: "site.{site}.{subsystem}.{device}"},
+		{Kind: api.TagKindSensor, Template: "site.{site}.{subsystem}.{device}.{kind}.{id}"},
+	} {
+		if err := store.SetTagTemplate(ctx, template); err != nil {
+			t.Fatalf("SetTagTemplate() error = %v", err)
+		}
+	}
+	if templates, _ := store.ListTagTemplates(ctx); len(templates) != 2 || templates[0].Kind != api.TagKindDevice {
+		t.Errorf("Expected the device and sensor templates, got %+v", templates)
+	}
+
+	dev := newMemoryDevice()
+	dev.Metadata = map[string]string{"site": "reef", "subsystem": "sump"}
+	dev.Sensors[0].Tags = nil
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	got, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "site.reef.sump.dev-1" {
+		t.Errorf("Expected the templated device tag, got %v", got.Tags)
+	}
+	if sensor := got.GetSensorByID("ph"); sensor.Tags[0] != "site.reef.sump.dev-1.sensor.ph" {
+		t.Errorf("Expected the templated sensor tag, got %v", sensor.Tags)
+	}
+	if actuator := got.GetActuatorByID("pump"); actuator.Tags[0] != "device.dev-1.actuator.pump" {
+		t.Errorf("Expected the built-in actuator tag without a template, got %v", actuator.Tags)
+	}
+
+	// Sensors added later take values from their own metadata, then their device's
+	sensor := &api.Sensor{ID: "orp", DeviceID: "dev-1", Name: "ORP", Metadata: map[string]string{"subsystem": "display"}}
+	if err := store.CreateSensor(ctx, sensor); err != nil {
+		t.Fatalf("CreateSensor() error = %v", err)
+	}
+	if sensor.Tags[0] != "site.reef.display.dev-1.sensor.orp" {
+		t.Errorf("Expected the sensor's own subsystem, got %v", sensor.Tags)
+	}
+
+	bare := &api.Device{ID: "dev-2", Driver: api.DriverShelly, Name: "Bare"}
+	if err := store.CreateDevice(ctx, bare); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if len(bare.Tags) != 1 || bare.Tags[0] != "device.dev-2" {
+		t.Errorf("Expected the built-in tag without a site, got %v", bare.Tags)
+	}
+
+	if err := store.DeleteTagTemplate(ctx, api.TagKindSensor); err != nil {
+		t.Fatalf("DeleteTagTemplate() error = %v", err)
+	}
+	if err := store.DeleteTagTemplate(ctx, api.TagKindSensor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestMemory_Groups(t *testing.T) {
+	checkGroups(t, NewMemory())
+}
+
+// checkGroups checks store manages groups and their membership, dropping deleted devices
+func checkGroups(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	for _, id := range []string{"bed-pump", "bed-light", "qt-heater"} {
+		if err := store.CreateDevice(ctx, &api.Device{ID: id, Driver: api.DriverShelly, Name: id}); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+
+	bed := &api.Group{ID: "grow-bed-1", Name: "Grow Bed 1", Metadata: map[string]string{"media": "clay"}, DeviceIDs: []string{"bed-pump", "bed-light"}}
+	if err := store.CreateGroup(ctx, bed); err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if err := store.CreateGroup(ctx, bed); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists creating twice, got %v", err)
+	}
+	if err := store.CreateGroup(ctx, &api.Group{ID: "bad", Name: "Bad", DeviceIDs: []string{"missing"}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing device, got %v", err)
+	}
+	if _, err := store.GetGroup(ctx, "bad"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the failed group not to be created, got %v", err)
+	}
+	if err := store.CreateGroup(ctx, &api.Group{ID: "quarantine", Name: "Quarantine"}); err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	got, err := store.GetGroup(ctx, "grow-bed-1")
+	if err != nil {
+		t.Fatalf("GetGroup() error = %v", err)
+	}
+	if got.Name != "Grow Bed 1" || got.Metadata["media"] != "clay" || len(got.DeviceIDs) != 2 || got.DeviceIDs[0] != "bed-light" {
+		t.Errorf("Expected the group with devices ordered by ID, got %+v", got)
+	}
+
+	if err := store.AddGroupDevice(ctx, "quarantine", "qt-heater"); err != nil {
+		t.Fatalf("AddGroupDevice() error = %v", err)
+	}
+	if err := store.AddGroupDevice(ctx, "quarantine", "qt-heater"); err != nil {
+		t.Errorf("Expected adding a member again to succeed, got %v", err)
+	}
+	if err := store.AddGroupDevice(ctx, "quarantine", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound adding a missing device, got %v", err)
+	}
+	if err := store.RemoveGroupDevice(ctx, "grow-bed-1", "qt-heater"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound removing a non-member, got %v", err)
+	}
+
+	got.Description = "North window"
+	got.DeviceIDs = []string{"bed-pump"}
+	if err := store.UpdateGroup(ctx, got); err != nil {
+		t.Fatalf("UpdateGroup() error = %v", err)
+	}
+	if err := store.UpdateGroup(ctx, &api.Group{ID: "missing", Name: "Missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating a missing group, got %v", err)
+	}
+
+	// Deleting a device drops it from its groups
+	if err := store.DeleteDevice(ctx, "qt-heater"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	groups, err := store.ListGroups(ctx)
+	if err != nil {
+		t.Fatalf("ListGroups() error = %v", err)
+	}
+	if len(groups) != 2 || groups[0].ID != "grow-bed-1" || groups[0].Description != "North window" || len(groups[0].DeviceIDs) != 1 {
+		t.Fatalf("Expected the updated bed first, got %+v", groups)
+	}
+	if groups[1].ID != "quarantine" || len(groups[1].DeviceIDs) != 0 {
+		t.Errorf("Expected an empty quarantine group, got %+v", groups[1])
+	}
+
+	if err := store.DeleteGroup(ctx, "grow-bed-1"); err != nil {
+		t.Fatalf("DeleteGroup() error = %v", err)
+	}
+	if _, err := store.GetDevice(ctx, "bed-pump"); err != nil {
+		t.Errorf("Expected the group's devices to remain, got %v", err)
+	}
+	if err := store.DeleteGroup(ctx, "grow-bed-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestMemory_Retag(t *testing.T) {
+	checkRetag(t, NewMemory())
+}
+
+// checkRetag checks store renames and merges tags across devices, sensors and actuators
+func checkRetag(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "water", Target: "tank.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+
+	if n, err := store.RenameTag(ctx, "tank", "display"); err != nil || n != 1 {
+		t.Fatalf("RenameTag() = %d, %v, expected 1 entity renamed", n, err)
+	}
+	sensor, err := store.GetSensorByTag(ctx, "display.temp")
+	if err != nil {
+		t.Fatalf("GetSensorByTag() error = %v", err)
+	}
+	if sensor.ID != "temp" || sensor.Version != 2 {
+		t.Errorf("Expected the temp sensor at version 2, got %+v", sensor)
+	}
+	if alias, err := store.GetTagAlias(ctx, "water"); err != nil || alias.Target != "display.temp" {
+		t.Errorf("Expected the alias to follow the rename, got %+v, %v", alias, err)
+	}
+
+	// The device's default tag and those beneath it are renamed together
+	if n, err := store.RenameTag(ctx, "device.dev-1", "device.main"); err != nil || n != 3 {
+		t.Fatalf("RenameTag() = %d, %v, expected 3 entities renamed", n, err)
+	}
+	if _, err := store.GetActuatorByTag(ctx, "device.main.actuator.pump"); err != nil {
+		t.Errorf("Expected the pump under its renamed tag, got %v", err)
+	}
+	if _, err := store.RenameTag(ctx, "missing", "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound renaming an unused tag, got %v", err)
+	}
+
+	other := &api.Device{ID: "dev-2", Driver: api.DriverShelly, Name: "Other",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", Tags: []string{"sump.temp"}}}}
+	if err := store.CreateDevice(ctx, other); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if _, err := store.MergeTags(ctx, []string{"display.temp", "sump.temp"}, "temps"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists merging two sensors' tags, got %v", err)
+	}
+	if _, err := store.GetSensorByTag(ctx, "sump.temp"); err != nil {
+		t.Errorf("Expected a failed merge to change nothing, got %v", err)
+	}
+
+	sensor.Tags = []string{"display.temp", "display.t"}
+	if err := store.UpdateSensor(ctx, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	if n, err := store.MergeTags(ctx, []string{"display.temp", "display.t"}, "display.water"); err != nil || n != 1 {
+		t.Fatalf("MergeTags() = %d, %v, expected 1 entity changed", n, err)
+	}
+	got, err := store.GetSensor(ctx, "dev-1", "temp")
+	if err != nil {
+		t.Fatalf("GetSensor() error = %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "display.water" {
+		t.Errorf("Expected the merged tags once, got %v", got.Tags)
+	}
+}
+
+func TestMemory_ExternalIDs(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	dev := newMemoryDevice()
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if dev.ExternalID == "" || dev.Sensors[0].ExternalID == "" || dev.Actuators[0].ExternalID == "" {
+		t.Fatalf("Expected external ids to be assigned, got %+v", dev)
+	}
+
+	// Updates cannot change the external id
+	externalID := dev.Sensors[0].ExternalID
+	sensor := &api.Sensor{ID: "temp", DeviceID: "dev-1", Name: "Renamed", SensorType: api.SensorTypeTemperature,
+		Tags: []string{"tank.temp"}, ExternalID: "8f1d3c1e-0000-4000-8000-000000000000", Version: 1}
+	if err := store.UpdateSensor(ctx, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	got, err := store.GetSensorByExternalID(ctx, externalID)
+	if err != nil {
+		t.Fatalf("GetSensorByExternalID() error = %v", err)
+	}
+	if got.Name != "Renamed" || got.ExternalID != externalID {
+		t.Errorf("Expected renamed sensor with unchanged external id, got %+v", got)
+	}
+
+	gotDev, err := store.GetDeviceByExternalID(ctx, dev.ExternalID)
+	if err != nil {
+		t.Fatalf("GetDeviceByExternalID() error = %v", err)
+	}
+	if len(gotDev.Sensors) != 2 {
+		t.Errorf("Expected device with its sensors, got %+v", gotDev)
+	}
+	if _, err := store.GetActuatorByExternalID(ctx, dev.Actuators[0].ExternalID); err != nil {
+		t.Errorf("GetActuatorByExternalID() error = %v", err)
+	}
+	if _, err := store.GetDeviceByExternalID(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown external id, got %v", err)
+	}
+
+	bad := &api.Device{ID: "dev-2", Driver: api.DriverShelly, Name: "Bad", ExternalID: "not-a-uuid"}
+	if err := store.CreateDevice(ctx, bad); err == nil {
+		t.Error("Expected error for malformed external id")
+	}
+}
+
+func TestMemory_Readings(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		rec := &api.ReadingRecord{
+			DeviceID: "dev-1",
+			SensorID: "temp",
+			Reading:  api.SensorReading{Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Valid: true},
+		}
+		if err := store.StoreSensorReading(ctx, rec); err != nil {
+			t.Fatalf("StoreSensorReading() error = %v", err)
+		}
+	}
+	missing := &api.ReadingRecord{DeviceID: "dev-1", SensorID: "missing"}
+	if err := store.StoreSensorReading(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown sensor, got %v", err)
+	}
+
+	start := base.Add(time.Minute)
+	readings, err := store.GetSensorReadings(ctx, SensorReadingFilters{DeviceID: "dev-1", StartTime: &start})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(readings) != 2 || readings[0].Reading.Value != 2 {
+		t.Errorf("Expected 2 readings newest first, got %+v", readings)
+	}
+
+	latest, err := store.GetLatestSensorReading(ctx, "dev-1", "temp")
+	if err != nil {
+		t.Fatalf("GetLatestSensorReading() error = %v", err)
+	}
+	if latest.Value != 2 {
+		t.Errorf("Expected latest value 2, got %v", latest.Value)
+	}
+
+	deleted, err := store.DeleteOldSensorReadings(ctx, start)
+	if err != nil {
+		t.Fatalf("DeleteOldSensorReadings() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 reading deleted, got %d", deleted)
+	}
+
+	if err := store.DeleteDevice(ctx, "dev-1"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	readings, _ = store.GetSensorReadings(ctx, SensorReadingFilters{})
+	if len(readings) != 0 {
+		t.Errorf("Expected readings removed with device, got %d", len(readings))
+	}
+	if sensors, _ := store.ListSensors(ctx); len(sensors) != 0 {
+		t.Errorf("Expected sensors removed with device, got %d", len(sensors))
+	}
+}
+
+func TestMemory_Leases(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	lease, err := store.AcquireLease(ctx, "driver/shelly", "worker-1", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	if lease.Holder != "worker-1" || !lease.ExpiresAt.Equal(now.Add(15*time.Second)) {
+		t.Errorf("Expected worker-1 to hold the lease for 15s, got %+v", lease)
+	}
+	if _, err := store.AcquireLease(ctx, "driver/shelly", "worker-2", 15*time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("Expected ErrLeaseHeld for a second holder, got %v", err)
+	}
+
+	now = now.Add(10 * time.Second)
+	renewed, err := store.AcquireLease(ctx, "driver/shelly", "worker-1", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() renewal error = %v", err)
+	}
+	if !renewed.AcquiredAt.Equal(lease.AcquiredAt) || !renewed.ExpiresAt.Equal(now.Add(15*time.Second)) {
+		t.Errorf("Expected renewal to extend the lease only, got %+v", renewed)
+	}
+
+	// worker-1 stops renewing
+	now = now.Add(16 * time.Second)
+	taken, err := store.AcquireLease(ctx, "driver/shelly", "worker-2", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() takeover error = %v", err)
+	}
+	if taken.Holder != "worker-2" || !taken.AcquiredAt.Equal(now) {
+		t.Errorf("Expected worker-2 to take over the expired lease, got %+v", taken)
+	}
+
+	if err := store.ReleaseLease(ctx, "driver/shelly", "worker-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound releasing a lease held by someone else, got %v", err)
+	}
+	if err := store.ReleaseLease(ctx, "driver/shelly", "worker-2"); err != nil {
+		t.Fatalf("ReleaseLease() error = %v", err)
+	}
+	if leases, _ := store.ListLeases(ctx); len(leases) != 0 {
+		t.Errorf("Expected no leases after release, got %+v", leases)
+	}
+}
+
+func TestMemory_Credentials(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, &api.Device{ID: "camera-1", Name: "Camera"}); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	cred := &api.SealedCredential{
+		Credential: api.Credential{DeviceID: "camera-1", Name: "rtsp", KeyID: "k1"},
+		WrappedKey: []byte("wrapped"),
+		Ciphertext: []byte("sealed"),
+	}
+	if err := store.SetCredential(ctx, cred); err != nil {
+		t.Fatalf("SetCredential() error = %v", err)
+	}
+	missing := &api.SealedCredential{Credential: api.Credential{DeviceID: "camera-9", Name: "rtsp"}}
+	if err := store.SetCredential(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown device, got %v", err)
+	}
+
+	got, err := store.GetCredential(ctx, cred.ID)
+	if err != nil {
+		t.Fatalf("GetCredential() error = %v", err)
+	}
+	if string(got.Ciphertext) != "sealed" || got.KeyID != "k1" {
+		t.Errorf("Expected the sealed secret back, got %+v", got)
+	}
+
+	if err := store.DeleteDevice(ctx, "camera-1"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	if _, err := store.GetCredential(ctx, cred.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected credentials removed with device, got %v", err)
+	}
+}
+
+func TestMemory_Changes(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+	now := time.Date(2026, 2, 16, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	reading := &api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Valid: true, Timestamp: now}}
+	if err := store.StoreSensorReadings(ctx, []*api.ReadingRecord{reading, {DeviceID: "dev-1", SensorID: "nope"}}); err == nil {
+		t.Fatal("Expected storing a reading of an unknown sensor to fail")
+	}
+	if err := store.StoreSensorReading(ctx, reading); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+	if err := store.RecordChange(ctx, &api.ChangeEvent{Type: api.ChangeAlertFired, EntityID: "alert-1"}); err != nil {
+		t.Fatalf("RecordChange() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := store.DeleteDevice(ctx, "dev-1"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+
+	changes, err := store.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	want := []struct {
+		typ      api.ChangeType
+		entityID string
+	}{
+		{api.ChangeDeviceCreated, "dev-1"},
+		{api.ChangeReadingStored, "dev-1/temp"},
+		{api.ChangeAlertFired, "alert-1"},
+		{api.ChangeDeviceDeleted, "dev-1"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %d", len(want), len(changes))
+	}
+	for i, w := range want {
+		if changes[i].Seq != int64(i+1) || changes[i].Type != w.typ || changes[i].EntityID != w.entityID {
+			t.Errorf("Expected change %d to be %s %s, got %d %s %s", i+1, w.typ, w.entityID, changes[i].Seq, changes[i].Type, changes[i].EntityID)
+		}
+	}
+	var stored api.ReadingRecord
+	if err := json.Unmarshal(changes[1].Data, &stored); err != nil || stored.Reading.Value != 25.5 {
+		t.Errorf("Expected the reading as change data, got %s", changes[1].Data)
+	}
+
+	if page, _ := store.ListChanges(ctx, 2, 1); len(page) != 1 || page[0].Seq != 3 {
+		t.Errorf("Expected one change after seq 2, got %+v", page)
+	}
+	if seq, err := store.LatestChangeSeq(ctx); err != nil || seq != 4 {
+		t.Errorf("Expected the latest change to be 4, got %d, %v", seq, err)
+	}
+	if deleted, err := store.DeleteOldChanges(ctx, now); err != nil || deleted != 3 {
+		t.Errorf("Expected 3 old changes deleted, got %d, %v", deleted, err)
+	}
+	if page, _ := store.ListChanges(ctx, 0, 100); len(page) != 1 || page[0].Seq != 4 {
+		t.Errorf("Expected only the deletion left, got %+v", page)
+	}
+
+	if seq, err := store.GetChangeCursor(ctx, "nats"); err != nil || seq != 0 {
+		t.Errorf("Expected a new consumer's cursor to be 0, got %d, %v", seq, err)
+	}
+	if err := store.SetChangeCursor(ctx, "nats", 3); err != nil {
+		t.Fatalf("SetChangeCursor() error = %v", err)
+	}
+	if seq, _ := store.GetChangeCursor(ctx, "nats"); seq != 3 {
+		t.Errorf("Expected cursor 3, got %d", seq)
+	}
+}
+
+func TestMemory_ListPages(t *testing.T) {
+	store := NewMemory()
+	ctx := context.Background()
+
+	for _, id := range []string{"dev-c", "dev-a", "dev-b"} {
+		dev := &api.Device{ID: id, Driver: api.DriverShelly, Name: "Shelly " + id,
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}}
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+
+	var ids []string
+	page := Page{Limit: 2}
+	for i := 0; ; i++ {
+		devices, next, err := store.ListDevicesPage(ctx, page)
+		if err != nil {
+			t.Fatalf("ListDevicesPage() error = %v", err)
+		}
+		for _, dev := range devices {
+			ids = append(ids, dev.ID)
+		}
+		if next == "" {
+			break
+		}
+		if i > 2 {
+			t.Fatalf("Expected paging to end, still going after %v", ids)
+		}
+		page.Cursor = next
+	}
+	if len(ids) != 3 || ids[0] != "dev-a" || ids[1] != "dev-b" || ids[2] != "dev-c" {
+		t.Errorf("Expected every device once in name order, got %v", ids)
+	}
+
+	// Sensors sharing a name are ordered by device
+	sensors, next, err := store.ListSensorsPage(ctx, "", Page{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListSensorsPage() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].DeviceID != "dev-a" || next == "" {
+		t.Fatalf("Expected dev-a's sensor and a next cursor, got %+v %q", sensors, next)
+	}
+	sensors, next, err = store.ListSensorsPage(ctx, "", Page{Cursor: next})
+	if err != nil {
+		t.Fatalf("ListSensorsPage() error = %v", err)
+	}
+	if len(sensors) != 2 || sensors[0].DeviceID != "dev-b" || next != "" {
+		t.Errorf("Expected the remaining 2 sensors and no next cursor, got %+v %q", sensors, next)
+	}
+	sensors, _, err = store.ListSensorsPage(ctx, "dev-c", Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSensorsPage() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].DeviceID != "dev-c" {
+		t.Errorf("Expected only dev-c's sensor, got %+v", sensors)
+	}
+
+	if _, _, err := store.ListActuatorsPage(ctx, "", Page{Cursor: "not-a-cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestMemory_VersionConflicts(t *testing.T) {
+	checkVersionConflicts(t, NewMemory())
+}
+
+// checkVersionConflicts checks store refuses updates based on a stale version
+func checkVersionConflicts(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	dev := newMemoryDevice()
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if dev.Version != 1 || dev.Sensors[0].Version != 1 || dev.Actuators[0].Version != 1 {
+		t.Fatalf("Expected created entities at version 1, got %+v", dev)
+	}
+
+	// Two editors load the device; the first to save wins
+	first, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	second := *first
+	first.Name = "First"
+	if err := store.UpdateDevice(ctx, first); err != nil {
+		t.Fatalf("UpdateDevice() error = %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", first.Version)
+	}
+	second.Name = "Second"
+	if err := store.UpdateDevice(ctx, &second); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for stale device, got %v", err)
+	}
+	got, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if got.Name != "First" || got.Version != 2 {
+		t.Errorf("Expected first update to stand at version 2, got %s at %d", got.Name, got.Version)
+	}
+
+	sensor, err := store.GetSensor(ctx, "dev-1", "temp")
+	if err != nil {
+		t.Fatalf("GetSensor() error = %v", err)
+	}
+	stale := *sensor
+	if err := store.UpdateSensor(ctx, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	if err := store.UpdateSensor(ctx, &stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for stale sensor, got %v", err)
+	}
+
+	actuator, err := store.GetActuator(ctx, "dev-1", "pump")
+	if err != nil {
+		t.Fatalf("GetActuator() error = %v", err)
+	}
+	actuator.Version = 5
+	if err := store.UpdateActuator(ctx, actuator); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict for future actuator version, got %v", err)
+	}
+	if err := store.UpdateActuator(ctx, &api.Actuator{DeviceID: "dev-1", ID: "nope", Version: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating an unknown actuator, got %v", err)
+	}
+}
+
+func TestMemory_AuditLog(t *testing.T) {
+	store := NewMemory()
+	now := time.Date(2026, 2, 16, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	checkAuditLog(t, store, func(d time.Duration) { now = now.Add(d) })
+}
+
+// checkAuditLog checks store records who created, updated and deleted entities; advance
+// moves the store's clock on
+func checkAuditLog(t *testing.T, store Interface, advance func(time.Duration)) {
+	t.Helper()
+	start := time.Date(2026, 2, 16, 10, 0, 0, 0, time.UTC)
+	alice := api.WithActor(context.Background(), "alice")
+	if err := store.CreateDevice(alice, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	advance(time.Hour)
+	bob := api.WithActor(context.Background(), "bob")
+	sensor, err := store.GetSensor(bob, "dev-1", "temp")
+	if err != nil {
+		t.Fatalf("GetSensor() error = %v", err)
+	}
+	sensor.Name = "Water temperature"
+	if err := store.UpdateSensor(bob, sensor); err != nil {
+		t.Fatalf("UpdateSensor() error = %v", err)
+	}
+	if err := store.UpdateSensor(bob, &api.Sensor{DeviceID: "dev-1", ID: "nope"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound updating an unknown sensor, got %v", err)
+	}
+	if err := store.DeleteActuator(context.Background(), "dev-1", "pump"); err != nil {
+		t.Fatalf("DeleteActuator() error = %v", err)
+	}
+
+	entries, err := store.ListAuditEntries(context.Background(), AuditFilters{})
+	if err != nil {
+		t.Fatalf("ListAuditEntries() error = %v", err)
+	}
+	want := []struct {
+		typ      api.AuditEntityType
+		entityID string
+		action   api.AuditAction
+		actor    string
+	}{
+		{api.AuditEntityDevice, "dev-1", api.AuditActionCreated, "alice"},
+		{api.AuditEntitySensor, "dev-1/temp", api.AuditActionCreated, "alice"},
+		{api.AuditEntitySensor, "dev-1/ph", api.AuditActionCreated, "alice"},
+		{api.AuditEntityActuator, "dev-1/pump", api.AuditActionCreated, "alice"},
+		{api.AuditEntitySensor, "dev-1/temp", api.AuditActionUpdated, "bob"},
+		{api.AuditEntityActuator, "dev-1/pump", api.AuditActionDeleted, ""},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d audit entries, got %d", len(want), len(entries))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.EntityType != w.typ || e.EntityID != w.entityID || e.Action != w.action || e.Actor != w.actor {
+			t.Errorf("Expected entry %d to be %s %s %s by %q, got %s %s %s by %q",
+				i+1, w.typ, w.entityID, w.action, w.actor, e.EntityType, e.EntityID, e.Action, e.Actor)
+		}
+	}
+
+	var before, after api.Sensor
+	update := entries[4]
+	if err := json.Unmarshal(update.Before, &before); err != nil || before.Name != "Temperature" {
+		t.Errorf("Expected the sensor before the update, got %s", update.Before)
+	}
+	if err := json.Unmarshal(update.After, &after); err != nil || after.Name != "Water temperature" {
+		t.Errorf("Expected the sensor after the update, got %s", update.After)
+	}
+	if entries[0].Before != nil || entries[5].After != nil {
+		t.Error("Expected no before state for a creation and no after state for a deletion")
+	}
+
+	entries, err = store.ListAuditEntries(context.Background(), AuditFilters{EntityType: api.AuditEntitySensor, EntityID: "dev-1/temp"})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("Expected 2 entries for the sensor, got %d, %v", len(entries), err)
+	}
+	since := start.Add(time.Hour)
+	entries, err = store.ListAuditEntries(context.Background(), AuditFilters{StartTime: &since})
+	if err != nil || len(entries) != 2 {
+		t.Errorf("Expected 2 entries in the last hour, got %d, %v", len(entries), err)
+	}
+	entries, err = store.ListAuditEntries(context.Background(), AuditFilters{Limit: 1})
+	if err != nil || len(entries) != 1 || entries[0].Action != api.AuditActionDeleted {
+		t.Errorf("Expected the most recent entry, got %v, %v", entries, err)
+	}
+
+	// Commands are audited against their actuator, attributed to their origin
+	rec := &api.CommandRecord{
+		DeviceID:   "dev-1",
+		ActuatorID: "pump",
+		Origin:     api.CommandOrigin{Source: api.CommandSourceRule, Name: "dry_run/return-dry"},
+		Command:    api.ActuatorCommand{Action: "off"},
+		IssuedAt:   start.Add(time.Hour),
+	}
+	if err := store.RecordCommand(context.Background(), rec); err != nil {
+		t.Fatalf("RecordCommand() error = %v", err)
+	}
+	entries, err = store.ListAuditEntries(context.Background(), AuditFilters{EntityType: api.AuditEntityActuator, EntityID: "dev-1/pump"})
+	if err != nil {
+		t.Fatalf("ListAuditEntries() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries for the actuator, got %d", len(entries))
+	}
+	if e := entries[0]; e.Source != api.CommandSourceUser {
+		t.Errorf("Expected changes with an actor to come from a user, got %q", e.Source)
+	}
+	if e := entries[1]; e.Source != "" {
+		t.Errorf("Expected no source for a change without an actor, got %q", e.Source)
+	}
+	cmd := entries[2]
+	if cmd.Action != api.AuditActionCommanded || cmd.Source != api.CommandSourceRule || cmd.Actor != "dry_run/return-dry" {
+		t.Errorf("Expected a command by rule dry_run/return-dry, got %s by %s %q", cmd.Action, cmd.Source, cmd.Actor)
+	}
+	var audited api.CommandRecord
+	if err := json.Unmarshal(cmd.After, &audited); err != nil || audited.ID != rec.ID || audited.Command.Action != "off" {
+		t.Errorf("Expected the command record, got %s", cmd.After)
+	}
+
+	entries, err = store.ListAuditEntries(context.Background(), AuditFilters{
+		EntityID: "dev-1/pump",
+		Actions:  []api.AuditAction{api.AuditActionCreated, api.AuditActionDeleted},
+	})
+	if err != nil || len(entries) != 2 || entries[1].Action != api.AuditActionDeleted {
+		t.Errorf("Expected the actuator's creation and deletion without its command, got %v, %v", entries, err)
+	}
+	// Reported states are entered in the change feed; the failed command above was not
+	rec = &api.CommandRecord{
+		DeviceID:   "dev-1",
+		ActuatorID: "pump",
+		Origin:     api.CommandOrigin{Source: api.CommandSourceUser, Name: "alice"},
+		Command:    api.ActuatorCommand{Action: "on"},
+		State:      &api.ActuatorState{Active: true, Timestamp: start.Add(2 * time.Hour)},
+		IssuedAt:   start.Add(2 * time.Hour),
+	}
+	if err := store.RecordCommand(context.Background(), rec); err != nil {
+		t.Fatalf("RecordCommand() error = %v", err)
+	}
+	changes, err := store.ListChanges(context.Background(), 0, 1000)
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	var states []*api.ChangeEvent
+	for _, change := range changes {
+		if change.Type == api.ChangeActuatorState {
+			states = append(states, change)
+		}
+	}
+	if len(states) != 1 || states[0].EntityID != "dev-1/pump" {
+		t.Fatalf("Expected one actuator state change for dev-1/pump, got %v", states)
+	}
+	var reported api.CommandRecord
+	if err := json.Unmarshal(states[0].Data, &reported); err != nil || reported.State == nil || !reported.State.Active {
+		t.Errorf("Expected the command with its reported state, got %s", states[0].Data)
+	}
+}
+
+func TestMemory_PumpRuntimes(t *testing.T) {
+	checkPumpRuntimes(t, NewMemory())
+}
+
+// checkPumpRuntimes checks store saves pump runtimes per rotation in whole seconds, with
+// the running pump
+func checkPumpRuntimes(t *testing.T, store Interface) {
+	t.Helper()
+	ctx := context.Background()
+	if runtimes, active, err := store.GetPumpRuntimes(ctx, "return"); err != nil || len(runtimes) != 0 || active != "" {
+		t.Fatalf("Expected no runtimes before any are saved, got %v, %q, %v", runtimes, active, err)
+	}
+	if err := store.SetPumpRuntimes(ctx, "return", map[string]time.Duration{"pump.a": 90*time.Minute + time.Millisecond, "pump.b": time.Hour}, "pump.a"); err != nil {
+		t.Fatalf("SetPumpRuntimes() error = %v", err)
+	}
+	if err := store.SetPumpRuntimes(ctx, "return", map[string]time.Duration{"pump.b": 2 * time.Hour}, "pump.b"); err != nil {
+		t.Fatalf("SetPumpRuntimes() error = %v", err)
+	}
+	if err := store.SetPumpRuntimes(ctx, "skimmer", map[string]time.Duration{"pump.a": time.Minute}, "pump.a"); err != nil {
+		t.Fatalf("SetPumpRuntimes() error = %v", err)
+	}
+	runtimes, active, err := store.GetPumpRuntimes(ctx, "return")
+	if err != nil {
+		t.Fatalf("GetPumpRuntimes() error = %v", err)
+	}
+	want := map[string]time.Duration{"pump.a": 90 * time.Minute, "pump.b": 2 * time.Hour}
+	if !maps.Equal(runtimes, want) {
+		t.Errorf("Expected %v, got %v", want, runtimes)
+	}
+	if active != "pump.b" {
+		t.Errorf("Expected pump.b running, got %q", active)
+	}
+
+	// With no pump running none is saved as running
+	if err := store.SetPumpRuntimes(ctx, "return", want, ""); err != nil {
+		t.Fatalf("SetPumpRuntimes() error = %v", err)
+	}
+	if _, active, _ := store.GetPumpRuntimes(ctx, "return"); active != "" {
+		t.Errorf("Expected no pump running, got %q", active)
+	}
+}
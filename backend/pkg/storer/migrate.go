@@ -0,0 +1,161 @@
+package storer
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles are the PostgreSQL schema changes, applied in version order. Each is
+// named <version>_<name>.sql and runs exactly once per database, so an applied migration
+// must never be edited: change the schema by adding the next one.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID keys the advisory lock serializing migrations between processes
+// starting together, such as the HTTP server and worker
+const migrationLockID = 0x6c69666573757070 // "lifesupp"
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// migrations are the embedded migrations in version order
+var migrations = mustLoadMigrations(loadMigrations)
+
+// SchemaVersion is the version of the newest migration; a database is up to date once
+// it has been applied
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+func mustLoadMigrations(load func() ([]Migration, error)) []Migration {
+	m, err := load()
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// loadMigrations reads the embedded PostgreSQL migrations
+func loadMigrations() ([]Migration, error) {
+	return readMigrations(migrationFiles, "migrations")
+}
+
+// readMigrations reads the migrations in dir, which must be numbered from 1 without gaps
+func readMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	var out []Migration
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		num, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || name == "" {
+			return nil, fmt.Errorf("invalid migration file name %s: expected <version>_<name>.sql", e.Name())
+		}
+		sql, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+		out = append(out, Migration{Version: version, Name: name, SQL: string(sql)})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no migrations found")
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	for i, m := range out {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d_%s is out of sequence: expected version %d", m.Version, m.Name, i+1)
+		}
+	}
+	return out, nil
+}
+
+// Migrate applies each migration the database hasn't recorded in schema_migrations, in
+// order. Each runs in its own transaction with its record, under an advisory lock, so a
+// failed migration leaves the database on the previous version and processes starting
+// together apply each migration once. With WithTimescale, sensor_readings then becomes
+// a hypertable.
+func (s *Storer) Migrate(ctx context.Context) error {
+	ll := s.logCtx(ctx, "schema")
+	if _, err := s.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	for _, m := range migrations {
+		applied, err := s.applyMigration(ctx, m)
+		if err != nil {
+			return err
+		}
+		if applied {
+			ll.Info().Int("version", m.Version).Str("name", m.Name).Msg("applied schema migration")
+		}
+	}
+	if s.timescale != nil {
+		return s.enableTimescale(ctx)
+	}
+	return nil
+}
+
+// applyMigration applies m unless it has been already, reporting whether it was
+func (s *Storer) applyMigration(ctx context.Context, m Migration) (bool, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+	var applied bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// applyMigrations applies every migration to db, for building the expected schema
+func applyMigrations(ctx context.Context, db execer) error {
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	for _, m := range migrations {
+		if _, err := db.ExecContext(ctx, m.SQL); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+		}
+	}
+	return nil
+}
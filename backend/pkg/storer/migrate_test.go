@@ -0,0 +1,27 @@
+package storer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d", i, i+1, m.Version)
+		}
+		if strings.TrimSpace(m.SQL) == "" {
+			t.Errorf("Expected migration %d_%s to have SQL", m.Version, m.Name)
+		}
+	}
+	if SchemaVersion != migrations[len(migrations)-1].Version {
+		t.Errorf("Expected SchemaVersion %d, got %d", migrations[len(migrations)-1].Version, SchemaVersion)
+	}
+	if migrations[0].Name != "initial" {
+		t.Errorf("Expected the first migration to be initial, got %s", migrations[0].Name)
+	}
+}
@@ -0,0 +1,260 @@
+-- The schema as it stood before versioned migrations. Every statement is idempotent,
+-- so this also adopts a database created by the old start-up schema blob, adding
+-- whatever columns and indexes it was missing.
+
+CREATE TABLE IF NOT EXISTS devices (
+    id VARCHAR(255) PRIMARY KEY,
+    driver VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    metadata JSONB,
+    tags TEXT[],
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_devices_tags ON devices USING GIN(tags);
+
+CREATE TABLE IF NOT EXISTS sensors (
+    id VARCHAR(255) NOT NULL,
+    device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    sensor_type VARCHAR(50) NOT NULL,
+    metadata JSONB,
+    tags TEXT[],
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sensors_device_id ON sensors(device_id);
+CREATE INDEX IF NOT EXISTS idx_sensors_tags ON sensors USING GIN(tags);
+CREATE INDEX IF NOT EXISTS idx_sensors_type ON sensors(sensor_type);
+
+CREATE TABLE IF NOT EXISTS actuators (
+    id VARCHAR(255) NOT NULL,
+    device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    actuator_type VARCHAR(50) NOT NULL,
+    metadata JSONB,
+    tags TEXT[],
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_actuators_device_id ON actuators(device_id);
+CREATE INDEX IF NOT EXISTS idx_actuators_tags ON actuators USING GIN(tags);
+CREATE INDEX IF NOT EXISTS idx_actuators_type ON actuators(actuator_type);
+
+CREATE TABLE IF NOT EXISTS sensor_readings (
+    id BIGSERIAL PRIMARY KEY,
+    device_id VARCHAR(255) NOT NULL,
+    sensor_id VARCHAR(255) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    unit VARCHAR(20) NOT NULL DEFAULT '',
+    valid BOOLEAN NOT NULL DEFAULT TRUE,
+    error TEXT,
+    synthetic BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+ALTER TABLE sensor_readings ADD COLUMN IF NOT EXISTS synthetic BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_sensor_readings_sensor_time ON sensor_readings(device_id, sensor_id, timestamp DESC);
+
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS external_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS external_id UUID NOT NULL DEFAULT gen_random_uuid();
+ALTER TABLE actuators ADD COLUMN IF NOT EXISTS external_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_devices_external_id ON devices(external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sensors_external_id ON sensors(external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_actuators_external_id ON actuators(external_id);
+
+CREATE TABLE IF NOT EXISTS tag_aliases (
+    alias TEXT PRIMARY KEY,
+    target TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS broken_references (
+    kind VARCHAR(50) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    tag TEXT NOT NULL,
+    resource TEXT NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (kind, name, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_broken_references_tag ON broken_references(tag);
+
+CREATE TABLE IF NOT EXISTS command_history (
+    id BIGSERIAL PRIMARY KEY,
+    device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    actuator_id VARCHAR(255) NOT NULL,
+    tag TEXT NOT NULL DEFAULT '',
+    source VARCHAR(50) NOT NULL DEFAULT '',
+    source_name TEXT NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    parameters JSONB,
+    state JSONB,
+    error TEXT,
+    issued_at TIMESTAMPTZ NOT NULL,
+    latency_ms BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_command_history_device_time ON command_history(device_id, issued_at DESC);
+
+CREATE TABLE IF NOT EXISTS sensor_targets (
+    device_id VARCHAR(255) NOT NULL,
+    sensor_id VARCHAR(255) NOT NULL,
+    ideal_min DOUBLE PRECISION,
+    ideal_max DOUBLE PRECISION,
+    warning_min DOUBLE PRECISION,
+    warning_max DOUBLE PRECISION,
+    critical_min DOUBLE PRECISION,
+    critical_max DOUBLE PRECISION,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS leases (
+    name VARCHAR(255) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    ciphertext BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (device_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS assets (
+    id UUID PRIMARY KEY,
+    device_id VARCHAR(255) REFERENCES devices(id) ON DELETE CASCADE,
+    subsystem VARCHAR(255),
+    kind VARCHAR(50) NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    thumbnail_type VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((device_id IS NULL) <> (subsystem IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_assets_device_id ON assets(device_id);
+CREATE INDEX IF NOT EXISTS idx_assets_subsystem ON assets(subsystem);
+
+-- Changes are numbered once committed rather than as they are inserted: id orders
+-- those still awaiting a number, and seq stays NULL until relayChanges assigns it
+CREATE SEQUENCE IF NOT EXISTS change_events_seq_seq;
+
+CREATE TABLE IF NOT EXISTS change_events (
+    id BIGSERIAL PRIMARY KEY,
+    seq BIGINT,
+    type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(511) NOT NULL,
+    data JSONB,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER SEQUENCE change_events_seq_seq OWNED BY change_events.seq;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_change_events_seq ON change_events(seq);
+CREATE INDEX IF NOT EXISTS idx_change_events_unsequenced ON change_events(id) WHERE seq IS NULL;
+CREATE INDEX IF NOT EXISTS idx_change_events_timestamp ON change_events(timestamp);
+
+CREATE TABLE IF NOT EXISTS change_cursors (
+    consumer VARCHAR(255) PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A single row, present once maintenance mode has been set
+CREATE TABLE IF NOT EXISTS maintenance_mode (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    read_only BOOLEAN NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Runtime of each pump of a pump rotation and which one is running, so a worker taking
+-- over the rotation keeps its wear levelling and the running pump
+CREATE TABLE IF NOT EXISTS pump_runtimes (
+    rotation VARCHAR(255) NOT NULL,
+    pump_tag VARCHAR(255) NOT NULL,
+    runtime_seconds BIGINT NOT NULL CHECK (runtime_seconds >= 0),
+    active BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (rotation, pump_tag)
+);
+
+-- Function to check unique tags for devices
+CREATE OR REPLACE FUNCTION check_device_tags_unique()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM devices
+        WHERE id != NEW.id
+        AND tags && NEW.tags
+    ) THEN
+        RAISE EXCEPTION 'Tag already exists in another device';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER device_tags_unique_trigger
+    BEFORE INSERT OR UPDATE ON devices
+    FOR EACH ROW EXECUTE FUNCTION check_device_tags_unique();
+
+-- Function to check unique tags for sensors
+CREATE OR REPLACE FUNCTION check_sensor_tags_unique()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM sensors
+        WHERE (device_id != NEW.device_id OR id != NEW.id)
+        AND tags && NEW.tags
+    ) THEN
+        RAISE EXCEPTION 'Tag already exists in another sensor';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER sensor_tags_unique_trigger
+    BEFORE INSERT OR UPDATE ON sensors
+    FOR EACH ROW EXECUTE FUNCTION check_sensor_tags_unique();
+
+-- Function to check unique tags for actuators
+CREATE OR REPLACE FUNCTION check_actuator_tags_unique()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM actuators
+        WHERE (device_id != NEW.device_id OR id != NEW.id)
+        AND tags && NEW.tags
+    ) THEN
+        RAISE EXCEPTION 'Tag already exists in another actuator';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER actuator_tags_unique_trigger
+    BEFORE INSERT OR UPDATE ON actuators
+    FOR EACH ROW EXECUTE FUNCTION check_actuator_tags_unique();
@@ -0,0 +1,2 @@
+-- Applied migrations are recorded in schema_migrations instead
+DROP TABLE IF EXISTS schema_version;
@@ -0,0 +1,7 @@
+-- Flags rolling experimental features out to a share of clients
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    percent INTEGER NOT NULL CHECK (percent BETWEEN 0 AND 100),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,14 @@
+-- Who changed which device, sensor or actuator, and how
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(511) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);
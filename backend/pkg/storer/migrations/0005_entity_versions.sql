@@ -0,0 +1,4 @@
+-- Versions for optimistic concurrency on updates
+ALTER TABLE devices ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE sensors ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE actuators ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- GIN indexes for metadata containment filters on list endpoints
+CREATE INDEX IF NOT EXISTS idx_devices_metadata ON devices USING GIN (metadata jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_sensors_metadata ON sensors USING GIN (metadata jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_actuators_metadata ON actuators USING GIN (metadata jsonb_path_ops);
@@ -0,0 +1,14 @@
+-- Labels over windows of readings, for grouping and comparing experiments
+CREATE TABLE IF NOT EXISTS reading_labels (
+    id BIGSERIAL PRIMARY KEY,
+    key VARCHAR(255) NOT NULL,
+    value TEXT NOT NULL,
+    device_id VARCHAR(255) NOT NULL DEFAULT '',
+    sensor_id VARCHAR(255) NOT NULL DEFAULT '',
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL CHECK (end_time > start_time),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reading_labels_key_time ON reading_labels(key, start_time);
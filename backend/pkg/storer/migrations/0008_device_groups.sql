@@ -0,0 +1,17 @@
+-- Named groups of devices, such as grow beds or quarantine tanks
+CREATE TABLE IF NOT EXISTS device_groups (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    metadata JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS device_group_members (
+    group_id VARCHAR(255) NOT NULL REFERENCES device_groups(id) ON DELETE CASCADE,
+    device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, device_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_group_members_device ON device_group_members(device_id);
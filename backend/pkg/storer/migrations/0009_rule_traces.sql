@@ -0,0 +1,11 @@
+-- The latest evaluations of each control rule, for debugging why a rule did or didn't fire
+CREATE TABLE IF NOT EXISTS rule_traces (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    outcome VARCHAR(32) NOT NULL,
+    trace JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rule_traces_rule ON rule_traces(kind, name, id);
@@ -0,0 +1,3 @@
+-- What kind of actor made each change, so actuator commands are attributed to the user,
+-- rule, schedule or workflow which issued them
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- How long readings of each sensor type are kept; types without a policy are kept forever
+CREATE TABLE IF NOT EXISTS retention_policies (
+    sensor_type VARCHAR(50) PRIMARY KEY,
+    retention_seconds BIGINT NOT NULL CHECK (retention_seconds > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+-- Templates of the default tags given to new devices, sensors and actuators
+CREATE TABLE IF NOT EXISTS tag_templates (
+    kind VARCHAR(20) PRIMARY KEY,
+    template TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,43 @@
+-- The most recent reading of each sensor, kept by a trigger on sensor_readings so the
+-- latest readings of a device are one indexed lookup rather than a scan per sensor
+CREATE TABLE IF NOT EXISTS sensor_latest (
+    device_id VARCHAR(255) NOT NULL,
+    sensor_id VARCHAR(255) NOT NULL,
+    reading_id BIGINT NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    unit VARCHAR(20) NOT NULL DEFAULT '',
+    valid BOOLEAN NOT NULL DEFAULT TRUE,
+    error TEXT,
+    synthetic BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+-- Readings arriving out of order only replace a later one; ties go to the last stored
+CREATE OR REPLACE FUNCTION sensor_latest_update() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO sensor_latest (device_id, sensor_id, reading_id, value, unit, valid, error, synthetic, timestamp)
+    VALUES (NEW.device_id, NEW.sensor_id, NEW.id, NEW.value, NEW.unit, NEW.valid, NEW.error, NEW.synthetic, NEW.timestamp)
+    ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+        reading_id = EXCLUDED.reading_id,
+        value = EXCLUDED.value,
+        unit = EXCLUDED.unit,
+        valid = EXCLUDED.valid,
+        error = EXCLUDED.error,
+        synthetic = EXCLUDED.synthetic,
+        timestamp = EXCLUDED.timestamp
+    WHERE (sensor_latest.timestamp, sensor_latest.reading_id) < (EXCLUDED.timestamp, EXCLUDED.reading_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS sensor_latest_update ON sensor_readings;
+CREATE TRIGGER sensor_latest_update AFTER INSERT ON sensor_readings
+    FOR EACH ROW EXECUTE FUNCTION sensor_latest_update();
+
+INSERT INTO sensor_latest (device_id, sensor_id, reading_id, value, unit, valid, error, synthetic, timestamp)
+SELECT DISTINCT ON (device_id, sensor_id) device_id, sensor_id, id, value, unit, valid, error, synthetic, timestamp
+FROM sensor_readings
+ORDER BY device_id, sensor_id, timestamp DESC, id DESC
+ON CONFLICT (device_id, sensor_id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- How many readings of each sensor are kept before it is downsampled or alerted on
+CREATE TABLE IF NOT EXISTS storage_quotas (
+    device_id VARCHAR(255) NOT NULL,
+    sensor_id VARCHAR(255) NOT NULL,
+    max_readings BIGINT NOT NULL CHECK (max_readings > 0),
+    downsample_seconds BIGINT NOT NULL DEFAULT 0 CHECK (downsample_seconds >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
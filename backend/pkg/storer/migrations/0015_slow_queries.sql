@@ -0,0 +1,9 @@
+-- Queries which took at least the slow query threshold, with their plans, for the index
+-- advisor; only the newest are kept
+CREATE TABLE IF NOT EXISTS slow_queries (
+    id BIGSERIAL PRIMARY KEY,
+    query TEXT NOT NULL,
+    duration_us BIGINT NOT NULL,
+    plan TEXT NOT NULL DEFAULT '',
+    captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,240 @@
+-- The SQLite schema mirrors the PostgreSQL one. Tags are JSON arrays of strings, JSON
+-- columns are text, and timestamps are fixed-width UTC text, so they sort in time order.
+
+CREATE TABLE devices (
+    id TEXT PRIMARY KEY,
+    driver TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    metadata TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    external_id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE sensors (
+    id TEXT NOT NULL,
+    device_id TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    sensor_type TEXT NOT NULL,
+    metadata TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    external_id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, id)
+);
+
+CREATE INDEX idx_sensors_type ON sensors(sensor_type);
+
+CREATE TABLE actuators (
+    id TEXT NOT NULL,
+    device_id TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    actuator_type TEXT NOT NULL,
+    metadata TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    external_id TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, id)
+);
+
+CREATE INDEX idx_actuators_type ON actuators(actuator_type);
+
+CREATE TABLE sensor_readings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_id TEXT NOT NULL,
+    sensor_id TEXT NOT NULL,
+    value REAL NOT NULL,
+    unit TEXT NOT NULL DEFAULT '',
+    valid BOOLEAN NOT NULL DEFAULT TRUE,
+    error TEXT,
+    synthetic BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp TIMESTAMP NOT NULL,
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sensor_readings_sensor_time ON sensor_readings(device_id, sensor_id, timestamp DESC);
+CREATE INDEX idx_sensor_readings_time ON sensor_readings(timestamp);
+
+CREATE TABLE tag_aliases (
+    alias TEXT PRIMARY KEY,
+    target TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE broken_references (
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    resource TEXT NOT NULL,
+    detected_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (kind, name, tag)
+);
+
+CREATE INDEX idx_broken_references_tag ON broken_references(tag);
+
+CREATE TABLE command_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_id TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    actuator_id TEXT NOT NULL,
+    tag TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    source_name TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    parameters TEXT,
+    state TEXT,
+    error TEXT,
+    issued_at TIMESTAMP NOT NULL,
+    latency_ms INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_command_history_device_time ON command_history(device_id, issued_at DESC);
+
+CREATE TABLE sensor_targets (
+    device_id TEXT NOT NULL,
+    sensor_id TEXT NOT NULL,
+    ideal_min REAL,
+    ideal_max REAL,
+    warning_min REAL,
+    warning_max REAL,
+    critical_min REAL,
+    critical_max REAL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    acquired_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE credentials (
+    id TEXT PRIMARY KEY,
+    device_id TEXT NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    key_id TEXT NOT NULL,
+    wrapped_key BLOB NOT NULL,
+    ciphertext BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (device_id, name)
+);
+
+CREATE TABLE assets (
+    id TEXT PRIMARY KEY,
+    device_id TEXT REFERENCES devices(id) ON DELETE CASCADE,
+    subsystem TEXT,
+    kind TEXT NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    thumbnail_type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    CHECK ((device_id IS NULL) <> (subsystem IS NULL))
+);
+
+CREATE INDEX idx_assets_device_id ON assets(device_id);
+CREATE INDEX idx_assets_subsystem ON assets(subsystem);
+
+CREATE TABLE change_events (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    data TEXT,
+    timestamp TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_change_events_timestamp ON change_events(timestamp);
+
+CREATE TABLE change_cursors (
+    consumer TEXT PRIMARY KEY,
+    seq INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- A single row, present once maintenance mode has been set
+CREATE TABLE maintenance_mode (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    read_only BOOLEAN NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Runtime of each pump of a pump rotation and which one is running, so a worker taking
+-- over the rotation keeps its wear levelling and the running pump
+CREATE TABLE pump_runtimes (
+    rotation TEXT NOT NULL,
+    pump_tag TEXT NOT NULL,
+    runtime_seconds INTEGER NOT NULL CHECK (runtime_seconds >= 0),
+    active BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (rotation, pump_tag)
+);
+
+-- Tags are unique among devices, among sensors and among actuators
+CREATE TRIGGER device_tags_unique_insert BEFORE INSERT ON devices
+WHEN EXISTS (
+    SELECT 1 FROM devices, json_each(devices.tags) AS tag
+    WHERE devices.id != NEW.id AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another device');
+END;
+
+CREATE TRIGGER device_tags_unique_update BEFORE UPDATE OF tags ON devices
+WHEN EXISTS (
+    SELECT 1 FROM devices, json_each(devices.tags) AS tag
+    WHERE devices.id != NEW.id AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another device');
+END;
+
+CREATE TRIGGER sensor_tags_unique_insert BEFORE INSERT ON sensors
+WHEN EXISTS (
+    SELECT 1 FROM sensors, json_each(sensors.tags) AS tag
+    WHERE (sensors.device_id != NEW.device_id OR sensors.id != NEW.id)
+        AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another sensor');
+END;
+
+CREATE TRIGGER sensor_tags_unique_update BEFORE UPDATE OF tags ON sensors
+WHEN EXISTS (
+    SELECT 1 FROM sensors, json_each(sensors.tags) AS tag
+    WHERE (sensors.device_id != NEW.device_id OR sensors.id != NEW.id)
+        AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another sensor');
+END;
+
+CREATE TRIGGER actuator_tags_unique_insert BEFORE INSERT ON actuators
+WHEN EXISTS (
+    SELECT 1 FROM actuators, json_each(actuators.tags) AS tag
+    WHERE (actuators.device_id != NEW.device_id OR actuators.id != NEW.id)
+        AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another actuator');
+END;
+
+CREATE TRIGGER actuator_tags_unique_update BEFORE UPDATE OF tags ON actuators
+WHEN EXISTS (
+    SELECT 1 FROM actuators, json_each(actuators.tags) AS tag
+    WHERE (actuators.device_id != NEW.device_id OR actuators.id != NEW.id)
+        AND tag.value IN (SELECT value FROM json_each(NEW.tags))
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Tag already exists in another actuator');
+END;
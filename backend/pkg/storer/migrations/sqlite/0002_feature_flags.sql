@@ -0,0 +1,7 @@
+-- Flags rolling experimental features out to a share of clients
+CREATE TABLE feature_flags (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    percent INTEGER NOT NULL CHECK (percent BETWEEN 0 AND 100),
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,14 @@
+-- Who changed which device, sensor or actuator, and how
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    before TEXT,
+    after TEXT,
+    timestamp TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, timestamp);
+CREATE INDEX idx_audit_log_timestamp ON audit_log(timestamp);
@@ -0,0 +1,4 @@
+-- Versions for optimistic concurrency on updates
+ALTER TABLE devices ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE sensors ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE actuators ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
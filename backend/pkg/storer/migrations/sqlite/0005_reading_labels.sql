@@ -0,0 +1,14 @@
+-- Labels over windows of readings, for grouping and comparing experiments
+CREATE TABLE reading_labels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    device_id TEXT NOT NULL DEFAULT '',
+    sensor_id TEXT NOT NULL DEFAULT '',
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL CHECK (end_time > start_time),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_reading_labels_key_time ON reading_labels(key, start_time);
@@ -0,0 +1,11 @@
+-- The latest evaluations of each control rule, for debugging why a rule did or didn't fire
+CREATE TABLE rule_traces (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    outcome TEXT NOT NULL,
+    trace TEXT NOT NULL
+);
+
+CREATE INDEX idx_rule_traces_rule ON rule_traces(kind, name, id);
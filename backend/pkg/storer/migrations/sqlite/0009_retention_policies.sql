@@ -0,0 +1,6 @@
+-- How long readings of each sensor type are kept; types without a policy are kept forever
+CREATE TABLE retention_policies (
+    sensor_type TEXT PRIMARY KEY,
+    retention_seconds INTEGER NOT NULL CHECK (retention_seconds > 0),
+    updated_at TIMESTAMP NOT NULL
+);
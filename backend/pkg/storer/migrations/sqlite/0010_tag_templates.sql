@@ -0,0 +1,6 @@
+-- Templates of the default tags given to new devices, sensors and actuators
+CREATE TABLE tag_templates (
+    kind TEXT PRIMARY KEY,
+    template TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,39 @@
+-- The most recent reading of each sensor, kept by a trigger on sensor_readings so the
+-- latest readings of a device are one indexed lookup rather than a scan per sensor
+CREATE TABLE sensor_latest (
+    device_id TEXT NOT NULL,
+    sensor_id TEXT NOT NULL,
+    reading_id INTEGER NOT NULL,
+    value REAL NOT NULL,
+    unit TEXT NOT NULL DEFAULT '',
+    valid BOOLEAN NOT NULL DEFAULT TRUE,
+    error TEXT,
+    synthetic BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
+
+-- Readings arriving out of order only replace a later one; ties go to the last stored
+CREATE TRIGGER sensor_latest_update AFTER INSERT ON sensor_readings
+BEGIN
+    INSERT INTO sensor_latest (device_id, sensor_id, reading_id, value, unit, valid, error, synthetic, timestamp)
+    VALUES (NEW.device_id, NEW.sensor_id, NEW.id, NEW.value, NEW.unit, NEW.valid, NEW.error, NEW.synthetic, NEW.timestamp)
+    ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+        reading_id = excluded.reading_id,
+        value = excluded.value,
+        unit = excluded.unit,
+        valid = excluded.valid,
+        error = excluded.error,
+        synthetic = excluded.synthetic,
+        timestamp = excluded.timestamp
+    WHERE (sensor_latest.timestamp, sensor_latest.reading_id) < (excluded.timestamp, excluded.reading_id);
+END;
+
+INSERT INTO sensor_latest (device_id, sensor_id, reading_id, value, unit, valid, error, synthetic, timestamp)
+SELECT device_id, sensor_id, id, value, unit, valid, error, synthetic, timestamp
+FROM (
+    SELECT *, ROW_NUMBER() OVER (PARTITION BY device_id, sensor_id ORDER BY timestamp DESC, id DESC) AS n
+    FROM sensor_readings
+)
+WHERE n = 1;
@@ -0,0 +1,10 @@
+-- How many readings of each sensor are kept before it is downsampled or alerted on
+CREATE TABLE storage_quotas (
+    device_id TEXT NOT NULL,
+    sensor_id TEXT NOT NULL,
+    max_readings INTEGER NOT NULL CHECK (max_readings > 0),
+    downsample_seconds INTEGER NOT NULL DEFAULT 0 CHECK (downsample_seconds >= 0),
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (device_id, sensor_id),
+    FOREIGN KEY (device_id, sensor_id) REFERENCES sensors(device_id, id) ON DELETE CASCADE
+);
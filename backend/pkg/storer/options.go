@@ -1,6 +1,11 @@
 package storer
 
-import "github.com/rs/zerolog"
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog"
+)
 
 type Option func(*Storer)
 
@@ -9,3 +14,62 @@ func WithLogger(logger zerolog.Logger) Option {
 		s.log = logger
 	}
 }
+
+// WithMaxOpenConns limits the connections open to the database; zero or less means no
+// limit
+func WithMaxOpenConns(n int) Option {
+	return func(s *Storer) {
+		s.pool = append(s.pool, func(db *sql.DB) { db.SetMaxOpenConns(n) })
+	}
+}
+
+// WithMaxIdleConns sets how many idle connections are kept for reuse; zero or less keeps
+// none
+func WithMaxIdleConns(n int) Option {
+	return func(s *Storer) {
+		s.pool = append(s.pool, func(db *sql.DB) { db.SetMaxIdleConns(n) })
+	}
+}
+
+// WithConnMaxLifetime closes connections once they have been open for d, so the pool
+// follows database failovers and load balancers; zero or less keeps them forever
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(s *Storer) {
+		s.pool = append(s.pool, func(db *sql.DB) { db.SetConnMaxLifetime(d) })
+	}
+}
+
+// WithPoolStatsInterval logs the connection pool's statistics every d until the store is
+// closed; zero or less never logs them
+func WithPoolStatsInterval(d time.Duration) Option {
+	return func(s *Storer) {
+		s.statsInterval = d
+	}
+}
+
+// WithTimescale makes sensor_readings a TimescaleDB hypertable when migrating, with
+// compression once readings are cfg.CompressAfter old. Migrating fails if the server
+// doesn't have the extension; without this option plain PostgreSQL is used.
+func WithTimescale(cfg TimescaleConfig) Option {
+	return func(s *Storer) {
+		s.timescale = &cfg
+	}
+}
+
+// WithSlowQueryThreshold captures queries taking at least d, with their EXPLAIN plans, for
+// ListSlowQueries to summarize; zero or less captures none
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(s *Storer) {
+		s.slowThreshold = d
+	}
+}
+
+// WithPreparedStatements controls whether hot queries, such as GetDevice and
+// GetLatestSensorReading, are prepared once and reused; it defaults to true. Turn it off
+// behind a pooler that can't keep prepared statements, such as PgBouncer in transaction
+// mode.
+func WithPreparedStatements(enabled bool) Option {
+	return func(s *Storer) {
+		s.stmts.disabled = !enabled
+	}
+}
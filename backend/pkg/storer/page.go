@@ -0,0 +1,134 @@
+package storer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// ErrInvalidCursor is returned when a page cursor wasn't returned by a previous page
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects part of a list ordered by name. Pages are keyed on the last item of the
+// previous page rather than an offset, so items added or removed before a page don't
+// shift it.
+type Page struct {
+	// Cursor continues after the previous page; empty for the first page
+	Cursor string
+	// Limit is the most items returned; zero returns every remaining item
+	Limit int
+	// Metadata restricts the list to items whose metadata has every key set to the value
+	Metadata map[string]string
+}
+
+// pageKey is the sort key of the last item on a page. DeviceID is empty for devices.
+type pageKey struct {
+	Name     string `json:"n"`
+	DeviceID string `json:"d,omitempty"`
+	ID       string `json:"i"`
+}
+
+func (k pageKey) cursor() string {
+	b, _ := json.Marshal(k)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// after reports whether an item with key o sorts after k
+func (k pageKey) after(o pageKey) bool {
+	if o.Name != k.Name {
+		return o.Name > k.Name
+	}
+	if o.DeviceID != k.DeviceID {
+		return o.DeviceID > k.DeviceID
+	}
+	return o.ID > k.ID
+}
+
+// parseCursor decodes page.Cursor, returning nil for the first page
+func parseCursor(page Page) (*pageKey, error) {
+	if page.Cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var k pageKey
+	if err := json.Unmarshal(b, &k); err != nil || k.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &k, nil
+}
+
+// paginate trims a list sorted by key to the page, returning the cursor of the next
+// page, or an empty cursor on the last page
+func paginate[T any](items []T, page Page, key func(T) pageKey) ([]T, string, error) {
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != nil {
+		start := len(items)
+		for i, item := range items {
+			if after.after(key(item)) {
+				start = i
+				break
+			}
+		}
+		items = items[start:]
+	}
+	items, next := nextCursor(items, page, key)
+	return items, next, nil
+}
+
+// pageLimit is the LIMIT of a page query, fetching one extra row to tell whether there's
+// another page; nil (LIMIT NULL) fetches every row
+func pageLimit(page Page) interface{} {
+	if page.Limit <= 0 {
+		return nil
+	}
+	return page.Limit + 1
+}
+
+// nextCursor trims a page fetched with pageLimit to page.Limit, returning the cursor of
+// the next page if there's another
+func nextCursor[T any](items []T, page Page, key func(T) pageKey) ([]T, string) {
+	if page.Limit <= 0 || len(items) <= page.Limit {
+		return items, ""
+	}
+	items = items[:page.Limit]
+	return items, key(items[len(items)-1]).cursor()
+}
+
+// metadataFilter is page.Metadata as a JSON object, for containment queries
+func metadataFilter(page Page) string {
+	if len(page.Metadata) == 0 {
+		return "{}"
+	}
+	b, _ := json.Marshal(page.Metadata)
+	return string(b)
+}
+
+// matchesMetadata reports whether metadata has every key in filter set to its value
+func matchesMetadata(metadata map[string]string, filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func devicePageKey(d *api.Device) pageKey {
+	return pageKey{Name: d.Name, ID: d.ID}
+}
+
+func sensorPageKey(s *api.Sensor) pageKey {
+	return pageKey{Name: s.Name, DeviceID: s.DeviceID, ID: s.ID}
+}
+
+func actuatorPageKey(a *api.Actuator) pageKey {
+	return pageKey{Name: a.Name, DeviceID: a.DeviceID, ID: a.ID}
+}
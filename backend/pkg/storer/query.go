@@ -0,0 +1,25 @@
+package storer
+
+import (
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// Filtered listings are built with squirrel, one query per kind of filters shared by the
+// PostgreSQL and SQLite stores, so a new filter is added in one place. Both stores take
+// $n placeholders. Times are passed through toArg, pgTime or sqliteArg, since SQLite
+// compares them in their stored form.
+
+// builder starts the queries built here
+var builder = squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+
+// pgTime passes a time to PostgreSQL as it is
+func pgTime(t time.Time) any {
+	return t
+}
+
+// sqliteArg passes a time to SQLite in its stored form
+func sqliteArg(t time.Time) any {
+	return sqliteTime(t)
+}
@@ -0,0 +1,53 @@
+package storer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+func TestAuditQuery_Postgres(t *testing.T) {
+	start := time.Date(2026, 2, 16, 0, 0, 0, 0, time.UTC)
+	query, args, err := builder.Select("*").
+		FromSelect(auditQuery(AuditFilters{
+			EntityType: api.AuditEntityDevice,
+			StartTime:  &start,
+			Actions:    []api.AuditAction{api.AuditActionCreated, api.AuditActionUpdated},
+			Limit:      5,
+		}, pgTime), "recent").
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() error = %v", err)
+	}
+	want := "SELECT * FROM (SELECT id, entity_type, entity_id, action, actor, source, before, after, timestamp FROM audit_log " +
+		"WHERE entity_type = $1 AND timestamp >= $2 AND action IN ($3,$4) ORDER BY id DESC LIMIT 5) AS recent ORDER BY id"
+	if query != want {
+		t.Errorf("Expected query\n%s\ngot\n%s", want, query)
+	}
+	wantArgs := []any{api.AuditEntityDevice, start, api.AuditActionCreated, api.AuditActionUpdated}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Expected args %v, got %v", wantArgs, args)
+	}
+}
+
+func TestReadingsQuery_SQLite(t *testing.T) {
+	after := ReadingKey{Timestamp: time.Date(2026, 2, 16, 0, 0, 0, 0, time.UTC), ID: 7}
+	q, forward := readingsQuery(SensorReadingFilters{DeviceID: "dev-1", After: &after, Limit: 10}, sqliteArg)
+	if !forward {
+		t.Error("Expected paging forward from After alone")
+	}
+	query, args, err := q.ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() error = %v", err)
+	}
+	if !strings.HasSuffix(query, "WHERE device_id = $1 AND (timestamp, id) > ($2, $3) ORDER BY timestamp ASC, id ASC LIMIT 10") {
+		t.Errorf("Unexpected query %s", query)
+	}
+	if want := []any{"dev-1", sqliteTime(after.Timestamp), int64(7)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected args %v, got %v", want, args)
+	}
+}
@@ -0,0 +1,380 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+// SensorReadingFilters narrows GetSensorReadings; zero values are ignored
+type SensorReadingFilters struct {
+	DeviceID  string
+	SensorID  string
+	StartTime *time.Time
+	EndTime   *time.Time
+	// Before and After page through readings by key rather than offset. Before selects
+	// older readings, After newer ones; with only After the Limit readings nearest to it
+	// are returned, still newest first.
+	Before *ReadingKey
+	After  *ReadingKey
+	Limit  int
+}
+
+// ReadingKey orders readings: by timestamp, then by ID among readings taken at once
+type ReadingKey struct {
+	Timestamp time.Time
+	ID        int64
+}
+
+// KeyOf returns the key of rec
+func KeyOf(rec *api.ReadingRecord) ReadingKey {
+	return ReadingKey{Timestamp: rec.Reading.Timestamp, ID: rec.ID}
+}
+
+// Less reports whether k sorts before o, that is k is older
+func (k ReadingKey) Less(o ReadingKey) bool {
+	if !k.Timestamp.Equal(o.Timestamp) {
+		return k.Timestamp.Before(o.Timestamp)
+	}
+	return k.ID < o.ID
+}
+
+// StoreSensorReading records a single sensor reading
+func (s *Storer) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("device_id", rec.DeviceID).Str("sensor_id", rec.SensorID).Msg("storing sensor reading")
+	change, err := newChange(api.ChangeReadingStored, readingEntityID(rec), rec)
+	if err != nil {
+		return err
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sensor_readings (device_id, sensor_id, value, unit, valid, error, synthetic, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err = tx.ExecContext(ctx, query, rec.DeviceID, rec.SensorID, rec.Reading.Value, rec.Reading.Unit, rec.Reading.Valid,
+		nullString(rec.Reading.Error), rec.Reading.Synthetic, rec.Reading.Timestamp)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, rec.DeviceID, rec.SensorID)
+			}
+		}
+		return fmt.Errorf("failed to store sensor reading: %w", err)
+	}
+	if err := s.recordChanges(ctx, tx.Tx, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// maxInsertParams is the most parameters Postgres accepts in one statement
+const maxInsertParams = 65535
+
+// StoreSensorReadings records several readings with multi-row inserts in one transaction,
+// so either all of them are stored or none are
+func (s *Storer) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Int("count", len(recs)).Msg("storing sensor readings")
+
+	const columns = 8
+	changes := make([]*api.ChangeEvent, 0, len(recs))
+	for _, rec := range recs {
+		change, err := newChange(api.ChangeReadingStored, readingEntityID(rec), rec)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	// Large batches are split across several inserts to stay within maxInsertParams
+	for start := 0; start < len(recs); start += maxInsertParams / columns {
+		chunk := recs[start:min(start+maxInsertParams/columns, len(recs))]
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*columns)
+		for i, rec := range chunk {
+			n := i * columns
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+			args = append(args, rec.DeviceID, rec.SensorID, rec.Reading.Value, rec.Reading.Unit, rec.Reading.Valid,
+				nullString(rec.Reading.Error), rec.Reading.Synthetic, rec.Reading.Timestamp)
+		}
+		query := `
+			INSERT INTO sensor_readings (device_id, sensor_id, value, unit, valid, error, synthetic, timestamp)
+			VALUES ` + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == "23503" { // foreign_key_violation
+					return fmt.Errorf("%w: %s", ErrNotFound, pqErr.Detail)
+				}
+			}
+			return fmt.Errorf("failed to store sensor readings: %w", err)
+		}
+	}
+	if err := s.recordChanges(ctx, tx.Tx, changes...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetSensorReadings returns readings matching filters, newest first
+func (s *Storer) GetSensorReadings(ctx context.Context, filters SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("getting sensor readings")
+
+	q, forward := readingsQuery(filters, pgTime)
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sensor readings query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []*api.ReadingRecord
+	for rows.Next() {
+		var rec api.ReadingRecord
+		var errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.SensorID, &rec.Reading.Value, &rec.Reading.Unit, &rec.Reading.Valid, &errMsg, &rec.Reading.Synthetic, &rec.Reading.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		rec.Reading.Error = errMsg.String
+		readings = append(readings, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sensor readings: %w", err)
+	}
+	if forward {
+		slices.Reverse(readings)
+	}
+	return readings, nil
+}
+
+// CountSensorReadings returns how many readings match filters; Limit is ignored
+func (s *Storer) CountSensorReadings(ctx context.Context, filters SensorReadingFilters) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("counting sensor readings")
+
+	query, args, err := filterReadings(builder.Select("COUNT(*)").From("sensor_readings"), filters, pgTime).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build sensor readings query: %w", err)
+	}
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sensor readings: %w", err)
+	}
+	return count, nil
+}
+
+// readingsQuery selects the readings matching filters, newest first and at most Limit of
+// them. Paging forward from After alone takes the readings nearest it, oldest first, and
+// reports forward so they can be reversed.
+func readingsQuery(filters SensorReadingFilters, toArg func(time.Time) any) (squirrel.SelectBuilder, bool) {
+	q := filterReadings(builder.Select("id", "device_id", "sensor_id", "value", "unit", "valid", "error", "synthetic", "timestamp").
+		From("sensor_readings"), filters, toArg)
+	forward := filters.After != nil && filters.Before == nil
+	if forward {
+		q = q.OrderBy("timestamp ASC", "id ASC")
+	} else {
+		q = q.OrderBy("timestamp DESC", "id DESC")
+	}
+	if filters.Limit > 0 {
+		q = q.Limit(uint64(filters.Limit))
+	}
+	return q, forward
+}
+
+// filterReadings narrows q to the readings matching filters; Limit is left to the caller
+func filterReadings(q squirrel.SelectBuilder, filters SensorReadingFilters, toArg func(time.Time) any) squirrel.SelectBuilder {
+	if filters.DeviceID != "" {
+		q = q.Where(squirrel.Eq{"device_id": filters.DeviceID})
+	}
+	if filters.SensorID != "" {
+		q = q.Where(squirrel.Eq{"sensor_id": filters.SensorID})
+	}
+	if filters.StartTime != nil {
+		q = q.Where(squirrel.GtOrEq{"timestamp": toArg(*filters.StartTime)})
+	}
+	if filters.EndTime != nil {
+		q = q.Where(squirrel.Lt{"timestamp": toArg(*filters.EndTime)})
+	}
+	if k := filters.Before; k != nil {
+		q = q.Where("(timestamp, id) < (?, ?)", toArg(k.Timestamp), k.ID)
+	}
+	if k := filters.After; k != nil {
+		q = q.Where("(timestamp, id) > (?, ?)", toArg(k.Timestamp), k.ID)
+	}
+	return q
+}
+
+// GetLatestSensorReading returns the most recent reading of a sensor
+func (s *Storer) GetLatestSensorReading(ctx context.Context, deviceID, sensorID string) (*api.SensorReading, error) {
+	stmt, err := s.hot(ctx, `
+		SELECT value, unit, valid, error, synthetic, timestamp
+		FROM sensor_latest
+		WHERE device_id = $1 AND sensor_id = $2
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+	var r api.SensorReading
+	var errMsg sql.NullString
+	err = stmt.QueryRowContext(ctx, deviceID, sensorID).Scan(&r.Value, &r.Unit, &r.Valid, &errMsg, &r.Synthetic, &r.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no readings for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+	r.Error = errMsg.String
+	return &r, nil
+}
+
+// GetLatestReadingsForDevice returns the most recent reading of each sensor of a device
+// with any, ordered by sensor ID
+func (s *Storer) GetLatestReadingsForDevice(ctx context.Context, deviceID string) ([]*api.ReadingRecord, error) {
+	stmt, err := s.hot(ctx, `
+		SELECT reading_id, device_id, sensor_id, value, unit, valid, error, synthetic, timestamp
+		FROM sensor_latest
+		WHERE device_id = $1
+		ORDER BY sensor_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest readings: %w", err)
+	}
+	rows, err := stmt.QueryContext(ctx, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*api.ReadingRecord{}
+	for rows.Next() {
+		var rec api.ReadingRecord
+		var errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.SensorID, &rec.Reading.Value, &rec.Reading.Unit, &rec.Reading.Valid,
+			&errMsg, &rec.Reading.Synthetic, &rec.Reading.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan latest reading: %w", err)
+		}
+		rec.Reading.Error = errMsg.String
+		readings = append(readings, &rec)
+	}
+	return readings, rows.Err()
+}
+
+// DeleteOldSensorReadings removes readings taken before the given time and returns how
+// many were deleted
+func (s *Storer) DeleteOldSensorReadings(ctx context.Context, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Time("before", before).Msg("deleting old sensor readings")
+	// A sensor's latest reading older than before means all of its readings are going
+	result, err := s.db.ExecContext(ctx, `
+		WITH latest AS (DELETE FROM sensor_latest WHERE timestamp < $1)
+		DELETE FROM sensor_readings WHERE timestamp < $1
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old sensor readings: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+// GetAggregatedReadings aggregates a sensor's valid, measured readings taken in
+// [start, end) into buckets of interval, counted in whole seconds from the Unix epoch,
+// oldest first. Buckets without readings are left out, and only fns are set in each.
+func (s *Storer) GetAggregatedReadings(ctx context.Context, deviceID, sensorID string, interval time.Duration, fns []api.AggregateFunc, start, end time.Time) ([]*api.ReadingBucket, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: interval %s is too short", interval)
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().
+		Str("device_id", deviceID).
+		Str("sensor_id", sensorID).
+		Dur("interval", interval).
+		Time("start", start).
+		Time("end", end).
+		Msg("aggregating sensor readings")
+	seconds := int64(interval / time.Second)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT FLOOR(EXTRACT(EPOCH FROM timestamp) / $3)::BIGINT AS bucket,
+			AVG(value), MIN(value), MAX(value), COUNT(*)
+		FROM sensor_readings
+		WHERE device_id = $1 AND sensor_id = $2 AND valid AND NOT synthetic
+			AND timestamp >= $4 AND timestamp < $5
+		GROUP BY bucket
+		ORDER BY bucket
+	`, deviceID, sensorID, seconds, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: %w", err)
+	}
+	defer rows.Close()
+	return scanReadingBuckets(rows, seconds, fns)
+}
+
+// scanReadingBuckets scans rows of bucket number, average, minimum, maximum and count
+// into buckets of seconds, keeping the statistics named by fns
+func scanReadingBuckets(rows *sql.Rows, seconds int64, fns []api.AggregateFunc) ([]*api.ReadingBucket, error) {
+	buckets := []*api.ReadingBucket{}
+	for rows.Next() {
+		var n, count int64
+		var avg, min, max float64
+		if err := rows.Scan(&n, &avg, &min, &max, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reading bucket: %w", err)
+		}
+		buckets = append(buckets, newReadingBucket(time.Unix(n*seconds, 0), fns, avg, min, max, count))
+	}
+	return buckets, rows.Err()
+}
+
+// newReadingBucket returns the bucket starting at start with the statistics named by fns
+func newReadingBucket(start time.Time, fns []api.AggregateFunc, avg, min, max float64, count int64) *api.ReadingBucket {
+	b := &api.ReadingBucket{Start: start.UTC()}
+	for _, fn := range fns {
+		switch fn {
+		case api.AggregateAvg:
+			b.Avg = &avg
+		case api.AggregateMin:
+			b.Min = &min
+		case api.AggregateMax:
+			b.Max = &max
+		case api.AggregateCount:
+			b.Count = &count
+		}
+	}
+	return b
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
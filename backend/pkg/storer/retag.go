@@ -0,0 +1,241 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// tagRewrite returns the replacement for tag, reporting whether it is replaced
+type tagRewrite func(tag string) (string, bool)
+
+// renameRewrite replaces from with to, along with the tags beneath it: renaming "tank1"
+// to "tank-a" makes "tank1.temp" "tank-a.temp"
+func renameRewrite(from, to string) tagRewrite {
+	return func(tag string) (string, bool) {
+		if tag == from {
+			return to, true
+		}
+		if rest, ok := strings.CutPrefix(tag, from+"."); ok {
+			return to + "." + rest, true
+		}
+		return tag, false
+	}
+}
+
+// mergeRewrite replaces each of from with into
+func mergeRewrite(from []string, into string) tagRewrite {
+	return func(tag string) (string, bool) {
+		if slices.Contains(from, tag) {
+			return into, true
+		}
+		return tag, false
+	}
+}
+
+// taggedEntity is a device, sensor or actuator's tags, with the keys identifying it
+type taggedEntity struct {
+	keys []string
+	tags []string
+}
+
+// retag applies rewrite to the tags of entities of one kind, dropping duplicates, and
+// returns those whose tags change. Tags are unique among entities of a kind, so it fails
+// with ErrAlreadyExists if two would end up sharing a tag.
+func retag(typ api.AuditEntityType, entities []taggedEntity, rewrite tagRewrite) ([]taggedEntity, error) {
+	var changed []taggedEntity
+	owners := map[string]string{}
+	for _, e := range entities {
+		tags := make([]string, 0, len(e.tags))
+		replaced := false
+		for _, tag := range e.tags {
+			tag, ok := rewrite(tag)
+			replaced = replaced || ok
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		owner := strings.Join(e.keys, "/")
+		for _, tag := range tags {
+			if other, ok := owners[tag]; ok {
+				return nil, fmt.Errorf("%w: tag %s would be on both %s %s and %s", ErrAlreadyExists, tag, typ, other, owner)
+			}
+			owners[tag] = owner
+		}
+		if replaced {
+			changed = append(changed, taggedEntity{keys: e.keys, tags: tags})
+		}
+	}
+	return changed, nil
+}
+
+// retagTables are the tables whose tags are rewritten, with the columns keying their rows
+var retagTables = []struct {
+	typ   api.AuditEntityType
+	table string
+	keys  []string
+}{
+	{api.AuditEntityDevice, "devices", []string{"id"}},
+	{api.AuditEntitySensor, "sensors", []string{"device_id", "id"}},
+	{api.AuditEntityActuator, "actuators", []string{"device_id", "id"}},
+}
+
+// keyCondition matches a row of a retag table by its keys, as $1, $2...
+func keyCondition(keys []string) string {
+	conds := make([]string, len(keys))
+	for i, key := range keys {
+		conds[i] = fmt.Sprintf("%s = $%d", key, i+1)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// RenameTag renames from to to on devices, sensors and actuators, along with the tags
+// beneath it, in one transaction. Tag aliases pointing at a renamed tag follow it. It
+// returns how many entities changed, or ErrNotFound if none carry the tag.
+func (s *Storer) RenameTag(ctx context.Context, from, to string) (int64, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Str("from", from).Str("to", to).Msg("renaming tag")
+	return s.retag(ctx, renameRewrite(from, to), from)
+}
+
+// MergeTags replaces each of from with into on devices, sensors and actuators in one
+// transaction; entities carrying several of them keep into once. Tag aliases pointing at
+// a merged tag follow it. It returns how many entities changed, or ErrNotFound if none
+// carry any of from.
+func (s *Storer) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Strs("from", from).Str("into", into).Msg("merging tags")
+	return s.retag(ctx, mergeRewrite(from, into), strings.Join(from, ", "))
+}
+
+// retag applies rewrite to every device, sensor and actuator and to tag alias targets;
+// what names the tags rewritten, for errors
+func (s *Storer) retag(ctx context.Context, rewrite tagRewrite, what string) (int64, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var n int64
+	for _, t := range retagTables {
+		entities, err := s.taggedEntities(ctx, tx.Tx, t.table, t.keys)
+		if err != nil {
+			return 0, err
+		}
+		changed, err := retag(t.typ, entities, rewrite)
+		if err != nil {
+			return 0, err
+		}
+		query := fmt.Sprintf(`UPDATE %s SET tags = $%d, updated_at = NOW(), version = version + 1 WHERE %s`,
+			t.table, len(t.keys)+1, keyCondition(t.keys))
+		for _, e := range changed {
+			args := make([]interface{}, 0, len(e.keys)+1)
+			for _, key := range e.keys {
+				args = append(args, key)
+			}
+			args = append(args, pq.Array(e.tags))
+			err := s.audited(ctx, tx.Tx, t.typ, e.keys, func() error {
+				if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+					return fmt.Errorf("failed to retag %s %s: %w", t.typ, strings.Join(e.keys, "/"), err)
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+			if t.typ != api.AuditEntityDevice {
+				continue
+			}
+			dev, err := s.snapshot(ctx, tx.Tx, t.typ, e.keys)
+			if err != nil {
+				return 0, err
+			}
+			change, err := newChange(api.ChangeDeviceUpdated, e.keys[0], dev)
+			if err != nil {
+				return 0, err
+			}
+			if err := s.recordChanges(ctx, tx.Tx, change); err != nil {
+				return 0, err
+			}
+		}
+		n += int64(len(changed))
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("%w: tag %s", ErrNotFound, what)
+	}
+
+	aliases, err := s.aliasTargets(ctx, tx.Tx)
+	if err != nil {
+		return 0, err
+	}
+	for alias, target := range aliases {
+		if target, ok := rewrite(target); ok {
+			query := `UPDATE tag_aliases SET target = $2, updated_at = NOW() WHERE alias = $1`
+			if _, err := tx.ExecContext(ctx, query, alias, target); err != nil {
+				return 0, fmt.Errorf("failed to retarget tag alias %s: %w", alias, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return n, nil
+}
+
+// taggedEntities returns the keys and tags of every row of table within tx
+func (s *Storer) taggedEntities(ctx context.Context, tx *sql.Tx, table string, keys []string) ([]taggedEntity, error) {
+	cols := strings.Join(keys, ", ")
+	rows, err := tx.QueryContext(ctx, `SELECT `+cols+`, tags FROM `+table+` ORDER BY `+cols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s tags: %w", table, err)
+	}
+	defer rows.Close()
+
+	var entities []taggedEntity
+	for rows.Next() {
+		e := taggedEntity{keys: make([]string, len(keys))}
+		dest := make([]interface{}, 0, len(keys)+1)
+		for i := range e.keys {
+			dest = append(dest, &e.keys[i])
+		}
+		dest = append(dest, pq.Array(&e.tags))
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s tags: %w", table, err)
+		}
+		entities = append(entities, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate %s tags: %w", table, err)
+	}
+	return entities, nil
+}
+
+// aliasTargets returns the target of every tag alias within tx, by alias
+func (s *Storer) aliasTargets(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT alias, target FROM tag_aliases`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag aliases: %w", err)
+	}
+	defer rows.Close()
+
+	targets := map[string]string{}
+	for rows.Next() {
+		var alias, target string
+		if err := rows.Scan(&alias, &target); err != nil {
+			return nil, fmt.Errorf("failed to scan tag alias: %w", err)
+		}
+		targets[alias] = target
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tag aliases: %w", err)
+	}
+	return targets, nil
+}
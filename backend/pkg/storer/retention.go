@@ -0,0 +1,100 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// SetRetentionPolicy creates the policy for its sensor type or replaces an existing one,
+// setting policy.UpdatedAt. Retention is stored in whole seconds.
+func (s *Storer) SetRetentionPolicy(ctx context.Context, policy *api.RetentionPolicy) error {
+	ll := s.logCtx(ctx, "retention")
+	ll.Info().Str("sensor_type", string(policy.SensorType)).Dur("retention", policy.Retention).Msg("setting retention policy")
+	query := `
+		INSERT INTO retention_policies (sensor_type, retention_seconds, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (sensor_type) DO UPDATE SET
+			retention_seconds = EXCLUDED.retention_seconds,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	err := s.db.QueryRowContext(ctx, query, policy.SensorType, int64(policy.Retention/time.Second)).Scan(&policy.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set retention policy: %w", err)
+	}
+	return nil
+}
+
+// DeleteRetentionPolicy deletes the policy of a sensor type, keeping its readings forever
+func (s *Storer) DeleteRetentionPolicy(ctx context.Context, sensorType api.SensorType) error {
+	ll := s.logCtx(ctx, "retention")
+	ll.Info().Str("sensor_type", string(sensorType)).Msg("deleting retention policy")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM retention_policies WHERE sensor_type = $1`, sensorType)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: retention policy %s", ErrNotFound, sensorType)
+	}
+	return nil
+}
+
+// ListRetentionPolicies retrieves all retention policies, ordered by sensor type
+func (s *Storer) ListRetentionPolicies(ctx context.Context) ([]*api.RetentionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sensor_type, retention_seconds, updated_at FROM retention_policies ORDER BY sensor_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+	return scanRetentionPolicies(rows)
+}
+
+func scanRetentionPolicies(rows *sql.Rows) ([]*api.RetentionPolicy, error) {
+	var policies []*api.RetentionPolicy
+	for rows.Next() {
+		var p api.RetentionPolicy
+		var seconds int64
+		if err := rows.Scan(&p.SensorType, &seconds, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		p.Retention = time.Duration(seconds) * time.Second
+		policies = append(policies, &p)
+	}
+	return policies, rows.Err()
+}
+
+// DeleteSensorReadingsByType removes readings of sensors of the given type taken before
+// the given time and returns how many were deleted
+func (s *Storer) DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("sensor_type", string(sensorType)).Time("before", before).Msg("deleting old sensor readings")
+	result, err := s.db.ExecContext(ctx, `
+		WITH latest AS (
+			DELETE FROM sensor_latest l USING sensors s
+			WHERE s.device_id = l.device_id AND s.id = l.sensor_id
+				AND s.sensor_type = $1 AND l.timestamp < $2
+		)
+		DELETE FROM sensor_readings r USING sensors s
+		WHERE s.device_id = r.device_id AND s.id = r.sensor_id
+			AND s.sensor_type = $1 AND r.timestamp < $2
+	`, sensorType, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old sensor readings: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,67 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetPumpRuntimes returns the saved runtime of each pump of a pump rotation, by tag, and
+// the tag of the pump saved as running, or "" if none is. Runtimes are stored in whole
+// seconds.
+func (s *Storer) GetPumpRuntimes(ctx context.Context, rotation string) (map[string]time.Duration, string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT pump_tag, runtime_seconds, active FROM pump_runtimes WHERE rotation = $1`, rotation)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get pump runtimes: %w", err)
+	}
+	defer rows.Close()
+	return scanPumpRuntimes(rows)
+}
+
+// SetPumpRuntimes saves the runtime of each pump of a pump rotation, leaving pumps not
+// in runtimes as they were, and records active as the only running pump
+func (s *Storer) SetPumpRuntimes(ctx context.Context, rotation string, runtimes map[string]time.Duration, active string) error {
+	ll := s.logCtx(ctx, "rotation")
+	ll.Debug().Str("rotation", rotation).Int("pumps", len(runtimes)).Str("active", active).Msg("saving pump runtimes")
+	query := `
+		INSERT INTO pump_runtimes (rotation, pump_tag, runtime_seconds, active, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (rotation, pump_tag) DO UPDATE SET
+			runtime_seconds = EXCLUDED.runtime_seconds,
+			active = EXCLUDED.active,
+			updated_at = EXCLUDED.updated_at
+	`
+	return s.WithTx(ctx, func(tx *Storer) error {
+		if _, err := tx.db.ExecContext(ctx, `UPDATE pump_runtimes SET active = FALSE WHERE rotation = $1 AND pump_tag <> $2 AND active`, rotation, active); err != nil {
+			return fmt.Errorf("failed to clear active pump: %w", err)
+		}
+		for tag, runtime := range runtimes {
+			if _, err := tx.db.ExecContext(ctx, query, rotation, tag, int64(runtime/time.Second), tag == active); err != nil {
+				return fmt.Errorf("failed to set pump runtime: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func scanPumpRuntimes(rows *sql.Rows) (map[string]time.Duration, string, error) {
+	runtimes := make(map[string]time.Duration)
+	var active string
+	for rows.Next() {
+		var tag string
+		var seconds int64
+		var running bool
+		if err := rows.Scan(&tag, &seconds, &running); err != nil {
+			return nil, "", fmt.Errorf("failed to scan pump runtime: %w", err)
+		}
+		runtimes[tag] = time.Duration(seconds) * time.Second
+		if running {
+			active = tag
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to iterate pump runtimes: %w", err)
+	}
+	return runtimes, active, nil
+}
@@ -0,0 +1,450 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// expectedSchemaName is the scratch schema CheckSchema builds the expected schema in,
+// inside a transaction which is always rolled back
+const expectedSchemaName = "lifesupport_expected_schema"
+
+// SchemaDiffKind classifies a difference between the live and expected schemas
+type SchemaDiffKind string
+
+const (
+	SchemaMissingTable      SchemaDiffKind = "missing_table"
+	SchemaExtraTable        SchemaDiffKind = "extra_table"
+	SchemaMissingColumn     SchemaDiffKind = "missing_column"
+	SchemaExtraColumn       SchemaDiffKind = "extra_column"
+	SchemaColumnChanged     SchemaDiffKind = "column_changed"
+	SchemaMissingIndex      SchemaDiffKind = "missing_index"
+	SchemaExtraIndex        SchemaDiffKind = "extra_index"
+	SchemaIndexChanged      SchemaDiffKind = "index_changed"
+	SchemaMissingConstraint SchemaDiffKind = "missing_constraint"
+	SchemaExtraConstraint   SchemaDiffKind = "extra_constraint"
+	SchemaConstraintChanged SchemaDiffKind = "constraint_changed"
+	SchemaMissingTrigger    SchemaDiffKind = "missing_trigger"
+	SchemaExtraTrigger      SchemaDiffKind = "extra_trigger"
+)
+
+// schemaRecreateFix is the fix for drift no single statement repairs
+const schemaRecreateFix = "recreate it as defined in pkg/storer/migrations"
+
+// SchemaDiff is one way the live schema differs from the expected one
+type SchemaDiff struct {
+	Kind  SchemaDiffKind `json:"kind"`
+	Table string         `json:"table"`
+	// Name is the column, index, constraint or trigger; empty for tables
+	Name     string `json:"name,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	// Fix is SQL, or a command, correcting the drift. Fixes dropping extra objects are
+	// only suggestions: the object may be a deliberate local addition.
+	Fix string `json:"fix"`
+}
+
+func (d SchemaDiff) String() string {
+	s := string(d.Kind) + " " + d.Table
+	if d.Name != "" {
+		s += "." + d.Name
+	}
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf(" (expected %q, found %q)", d.Expected, d.Actual)
+	}
+	return s + ": " + d.Fix
+}
+
+// SchemaReport compares the live database with the schema this build expects
+type SchemaReport struct {
+	// Version is the newest migration applied to the database; 0 if none is
+	Version int `json:"version"`
+	// Expected is SchemaVersion
+	Expected int          `json:"expected"`
+	Diffs    []SchemaDiff `json:"diffs"`
+}
+
+// Drifted reports whether the database is on another version or differs from the
+// expected schema
+func (r *SchemaReport) Drifted() bool {
+	return r.Version != r.Expected || len(r.Diffs) > 0
+}
+
+// GetSchemaVersion returns the newest migration applied to the database, or 0 for an
+// empty database or one created before migrations
+func (s *Storer) GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" { // undefined_table
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
+
+// EnsureSchema applies pending migrations, then reports how the database differs from
+// the expected schema. Drift in a database already up to date is only reported:
+// migrations never run twice, so nothing quietly papers over it.
+func (s *Storer) EnsureSchema(ctx context.Context) (*SchemaReport, error) {
+	if err := s.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return s.CheckSchema(ctx)
+}
+
+// CheckSchema compares the live schema with the one the migrations create, which it
+// builds in a scratch schema inside a transaction that is rolled back
+func (s *Storer) CheckSchema(ctx context.Context) (*SchemaReport, error) {
+	version, err := s.GetSchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var current string
+	if err := s.db.QueryRowContext(ctx, `SELECT current_schema()`).Scan(&current); err != nil {
+		return nil, fmt.Errorf("failed to get current schema: %w", err)
+	}
+	actual, err := snapshotSchema(ctx, s.db, current)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := s.expectedSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaReport{
+		Version:  version,
+		Expected: SchemaVersion,
+		Diffs:    diffSchemas(expected, actual),
+	}, nil
+}
+
+func (s *Storer) expectedSchema(ctx context.Context) (*schemaSnapshot, error) {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE SCHEMA `+expectedSchemaName); err != nil {
+		return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SET LOCAL search_path TO `+expectedSchemaName); err != nil {
+		return nil, fmt.Errorf("failed to set search path: %w", err)
+	}
+	if err := applyMigrations(ctx, tx); err != nil {
+		return nil, fmt.Errorf("failed to build expected schema: %w", err)
+	}
+	if s.timescale != nil {
+		if _, err := tx.ExecContext(ctx, timescalePrimaryKey); err != nil {
+			return nil, fmt.Errorf("failed to build expected schema: %w", err)
+		}
+	}
+	return snapshotSchema(ctx, tx, expectedSchemaName)
+}
+
+// schemaKey names a column, index, constraint or trigger of a table
+type schemaKey struct {
+	table string
+	name  string
+}
+
+type schemaColumn struct {
+	typ     string
+	notNull bool
+	def     string
+}
+
+func (c schemaColumn) String() string {
+	s := c.typ
+	if c.notNull {
+		s += " NOT NULL"
+	}
+	if c.def != "" {
+		s += " DEFAULT " + c.def
+	}
+	return s
+}
+
+// schemaSnapshot is the part of a schema the application depends on. Definitions have
+// the schema name stripped, so snapshots of different schemas compare equal.
+type schemaSnapshot struct {
+	tables      map[string]bool
+	columns     map[schemaKey]schemaColumn
+	indexes     map[schemaKey]string
+	constraints map[schemaKey]string
+	triggers    map[schemaKey]bool
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func snapshotSchema(ctx context.Context, db queryer, schema string) (*schemaSnapshot, error) {
+	snap := &schemaSnapshot{
+		tables:      make(map[string]bool),
+		columns:     make(map[schemaKey]schemaColumn),
+		indexes:     make(map[schemaKey]string),
+		constraints: make(map[schemaKey]string),
+		triggers:    make(map[schemaKey]bool),
+	}
+	unqualify := func(def string) string {
+		return strings.ReplaceAll(def, schema+".", "")
+	}
+	query := func(what, q string, scan func(rows *sql.Rows) error) error {
+		rows, err := db.QueryContext(ctx, q, schema)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", what, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				return fmt.Errorf("failed to scan %s: %w", what, err)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to list %s: %w", what, err)
+		}
+		return nil
+	}
+
+	err := query("tables", `
+		SELECT c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p')
+	`, func(rows *sql.Rows) error {
+		var table string
+		err := rows.Scan(&table)
+		snap.tables[table] = true
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = query("columns", `
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			COALESCE(pg_get_expr(d.adbin, d.adrelid), '')
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND a.attnum > 0 AND NOT a.attisdropped
+	`, func(rows *sql.Rows) error {
+		var key schemaKey
+		var col schemaColumn
+		err := rows.Scan(&key.table, &key.name, &col.typ, &col.notNull, &col.def)
+		col.def = unqualify(col.def)
+		snap.columns[key] = col
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Indexes backing primary key, unique and exclusion constraints are compared as
+	// constraints
+	err = query("indexes", `
+		SELECT t.relname, i.relname, pg_get_indexdef(i.oid)
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		JOIN pg_class t ON t.oid = x.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint c
+			WHERE c.conindid = x.indexrelid AND c.conrelid = x.indrelid AND c.contype IN ('p', 'u', 'x')
+		)
+	`, func(rows *sql.Rows) error {
+		var key schemaKey
+		var def string
+		err := rows.Scan(&key.table, &key.name, &def)
+		snap.indexes[key] = unqualify(def)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = query("constraints", `
+		SELECT t.relname, c.conname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND c.contype IN ('p', 'u', 'f', 'c', 'x')
+	`, func(rows *sql.Rows) error {
+		var key schemaKey
+		var def string
+		err := rows.Scan(&key.table, &key.name, &def)
+		snap.constraints[key] = unqualify(def)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Triggers running an extension's functions, such as TimescaleDB's on hypertables,
+	// are the extension's to manage
+	err = query("triggers", `
+		SELECT t.relname, tg.tgname
+		FROM pg_trigger tg
+		JOIN pg_class t ON t.oid = tg.tgrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND NOT tg.tgisinternal AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d
+			WHERE d.classid = 'pg_proc'::regclass AND d.objid = tg.tgfoid AND d.deptype = 'e'
+		)
+	`, func(rows *sql.Rows) error {
+		var key schemaKey
+		err := rows.Scan(&key.table, &key.name)
+		snap.triggers[key] = true
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// diffSchemas lists how actual differs from expected, by table. Objects of a missing or
+// extra table aren't listed separately.
+func diffSchemas(expected, actual *schemaSnapshot) []SchemaDiff {
+	var diffs []SchemaDiff
+	add := func(d SchemaDiff) {
+		diffs = append(diffs, d)
+	}
+	compared := func(table string) bool {
+		return expected.tables[table] && actual.tables[table]
+	}
+
+	for table := range expected.tables {
+		if !actual.tables[table] {
+			add(SchemaDiff{Kind: SchemaMissingTable, Table: table, Fix: schemaRecreateFix})
+		}
+	}
+	for table := range actual.tables {
+		if !expected.tables[table] {
+			add(SchemaDiff{Kind: SchemaExtraTable, Table: table, Fix: fmt.Sprintf("DROP TABLE %s; -- if no longer used", table)})
+		}
+	}
+
+	for key, want := range expected.columns {
+		if !compared(key.table) {
+			continue
+		}
+		got, ok := actual.columns[key]
+		if !ok {
+			add(SchemaDiff{Kind: SchemaMissingColumn, Table: key.table, Name: key.name, Expected: want.String(),
+				Fix: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", key.table, key.name, want)})
+			continue
+		}
+		if got != want {
+			add(SchemaDiff{Kind: SchemaColumnChanged, Table: key.table, Name: key.name, Expected: want.String(), Actual: got.String(),
+				Fix: alterColumn(key, want, got)})
+		}
+	}
+	for key := range actual.columns {
+		if _, ok := expected.columns[key]; !ok && compared(key.table) {
+			add(SchemaDiff{Kind: SchemaExtraColumn, Table: key.table, Name: key.name, Actual: actual.columns[key].String(),
+				Fix: fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s; -- if no longer used", key.table, key.name)})
+		}
+	}
+
+	for key, want := range expected.indexes {
+		if !compared(key.table) {
+			continue
+		}
+		got, ok := actual.indexes[key]
+		switch {
+		case !ok:
+			add(SchemaDiff{Kind: SchemaMissingIndex, Table: key.table, Name: key.name, Expected: want, Fix: want + ";"})
+		case got != want:
+			add(SchemaDiff{Kind: SchemaIndexChanged, Table: key.table, Name: key.name, Expected: want, Actual: got,
+				Fix: fmt.Sprintf("DROP INDEX %s; %s;", key.name, want)})
+		}
+	}
+	for key, got := range actual.indexes {
+		if _, ok := expected.indexes[key]; !ok && compared(key.table) {
+			add(SchemaDiff{Kind: SchemaExtraIndex, Table: key.table, Name: key.name, Actual: got,
+				Fix: fmt.Sprintf("DROP INDEX %s; -- if no longer used", key.name)})
+		}
+	}
+
+	for key, want := range expected.constraints {
+		if !compared(key.table) {
+			continue
+		}
+		got, ok := actual.constraints[key]
+		addConstraint := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;", key.table, key.name, want)
+		switch {
+		case !ok:
+			add(SchemaDiff{Kind: SchemaMissingConstraint, Table: key.table, Name: key.name, Expected: want, Fix: addConstraint})
+		case got != want:
+			add(SchemaDiff{Kind: SchemaConstraintChanged, Table: key.table, Name: key.name, Expected: want, Actual: got,
+				Fix: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s; %s", key.table, key.name, addConstraint)})
+		}
+	}
+	for key, got := range actual.constraints {
+		if _, ok := expected.constraints[key]; !ok && compared(key.table) {
+			add(SchemaDiff{Kind: SchemaExtraConstraint, Table: key.table, Name: key.name, Actual: got,
+				Fix: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s; -- if no longer wanted", key.table, key.name)})
+		}
+	}
+
+	for key := range expected.triggers {
+		if compared(key.table) && !actual.triggers[key] {
+			add(SchemaDiff{Kind: SchemaMissingTrigger, Table: key.table, Name: key.name, Fix: schemaRecreateFix})
+		}
+	}
+	for key := range actual.triggers {
+		if compared(key.table) && !expected.triggers[key] {
+			add(SchemaDiff{Kind: SchemaExtraTrigger, Table: key.table, Name: key.name,
+				Fix: fmt.Sprintf("DROP TRIGGER %s ON %s; -- if no longer wanted", key.name, key.table)})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return diffs
+}
+
+// alterColumn returns the statements turning column got into want
+func alterColumn(key schemaKey, want, got schemaColumn) string {
+	var stmts []string
+	alter := func(change string) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;", key.table, key.name, change))
+	}
+	if got.typ != want.typ {
+		alter("TYPE " + want.typ)
+	}
+	if got.notNull != want.notNull {
+		if want.notNull {
+			alter("SET NOT NULL")
+		} else {
+			alter("DROP NOT NULL")
+		}
+	}
+	if got.def != want.def {
+		if want.def != "" {
+			alter("SET DEFAULT " + want.def)
+		} else {
+			alter("DROP DEFAULT")
+		}
+	}
+	return strings.Join(stmts, " ")
+}
@@ -0,0 +1,100 @@
+package storer
+
+import (
+	"testing"
+)
+
+func newTestSnapshot() *schemaSnapshot {
+	return &schemaSnapshot{
+		tables: map[string]bool{"devices": true},
+		columns: map[schemaKey]schemaColumn{
+			{"devices", "id"}:   {typ: "text", notNull: true},
+			{"devices", "name"}: {typ: "text", notNull: true, def: "''::text"},
+		},
+		indexes: map[schemaKey]string{
+			{"devices", "idx_devices_name"}: "CREATE INDEX idx_devices_name ON devices USING btree (name)",
+		},
+		constraints: map[schemaKey]string{
+			{"devices", "devices_pkey"}: "PRIMARY KEY (id)",
+		},
+		triggers: map[schemaKey]bool{
+			{"devices", "devices_changes"}: true,
+		},
+	}
+}
+
+func TestDiffSchemas_NoDrift(t *testing.T) {
+	if diffs := diffSchemas(newTestSnapshot(), newTestSnapshot()); len(diffs) != 0 {
+		t.Errorf("Expected no diffs, got %v", diffs)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	expected := newTestSnapshot()
+	actual := newTestSnapshot()
+	delete(actual.indexes, schemaKey{"devices", "idx_devices_name"})
+	delete(actual.triggers, schemaKey{"devices", "devices_changes"})
+	actual.columns[schemaKey{"devices", "legacy"}] = schemaColumn{typ: "integer"}
+	actual.columns[schemaKey{"devices", "name"}] = schemaColumn{typ: "character varying(64)"}
+	actual.tables["old_readings"] = true
+	actual.columns[schemaKey{"old_readings", "value"}] = schemaColumn{typ: "double precision"}
+
+	diffs := diffSchemas(expected, actual)
+	want := []SchemaDiff{
+		{
+			Kind: SchemaColumnChanged, Table: "devices", Name: "name",
+			Expected: "text NOT NULL DEFAULT ''::text", Actual: "character varying(64)",
+			Fix: "ALTER TABLE devices ALTER COLUMN name TYPE text; ALTER TABLE devices ALTER COLUMN name SET NOT NULL; ALTER TABLE devices ALTER COLUMN name SET DEFAULT ''::text;",
+		},
+		{
+			Kind: SchemaExtraColumn, Table: "devices", Name: "legacy", Actual: "integer",
+			Fix: "ALTER TABLE devices DROP COLUMN legacy; -- if no longer used",
+		},
+		{
+			Kind: SchemaMissingIndex, Table: "devices", Name: "idx_devices_name",
+			Expected: "CREATE INDEX idx_devices_name ON devices USING btree (name)",
+			Fix:      "CREATE INDEX idx_devices_name ON devices USING btree (name);",
+		},
+		{Kind: SchemaMissingTrigger, Table: "devices", Name: "devices_changes", Fix: schemaRecreateFix},
+		// The extra table's columns aren't listed separately
+		{Kind: SchemaExtraTable, Table: "old_readings", Fix: "DROP TABLE old_readings; -- if no longer used"},
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("Expected %d diffs, got %d: %v", len(want), len(diffs), diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("Expected diff %d to be %+v, got %+v", i, want[i], diffs[i])
+		}
+	}
+}
+
+func TestDiffSchemas_MissingTable(t *testing.T) {
+	actual := newTestSnapshot()
+	actual.tables = map[string]bool{}
+
+	diffs := diffSchemas(newTestSnapshot(), actual)
+	if len(diffs) != 1 || diffs[0].Kind != SchemaMissingTable || diffs[0].Table != "devices" {
+		t.Errorf("Expected only a missing devices table, got %v", diffs)
+	}
+}
+
+func TestSchemaReport_Drifted(t *testing.T) {
+	tests := []struct {
+		name   string
+		report SchemaReport
+		want   bool
+	}{
+		{"current", SchemaReport{Version: 1, Expected: 1}, false},
+		{"older", SchemaReport{Version: 0, Expected: 1}, true},
+		{"newer", SchemaReport{Version: 2, Expected: 1}, true},
+		{"diffs", SchemaReport{Version: 1, Expected: 1, Diffs: []SchemaDiff{{Kind: SchemaExtraTable}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.report.Drifted(); got != tt.want {
+				t.Errorf("Expected Drifted() = %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
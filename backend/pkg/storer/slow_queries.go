@@ -0,0 +1,283 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+const (
+	// slowQueryLimit bounds the captured slow queries kept; older captures are pruned
+	slowQueryLimit = 1000
+	// slowQueryBacklog bounds the slow queries waiting to be captured; more are dropped
+	// rather than slowing the queries down further
+	slowQueryBacklog = 64
+	// slowQueryTimeout bounds explaining and storing one slow query
+	slowQueryTimeout = 10 * time.Second
+)
+
+// slowQuery is one execution of a query which took at least the slow query threshold
+type slowQuery struct {
+	query string
+	args  []interface{}
+	took  time.Duration
+	at    time.Time
+}
+
+// slowConn times the queries run on conn, queuing those which take at least the
+// threshold to be captured
+type slowConn struct {
+	conn
+	s *Storer
+}
+
+func (c slowConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer c.s.observeQuery(query, args, time.Now())
+	return c.conn.ExecContext(ctx, query, args...)
+}
+
+func (c slowConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer c.s.observeQuery(query, args, time.Now())
+	return c.conn.QueryContext(ctx, query, args...)
+}
+
+func (c slowConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer c.s.observeQuery(query, args, time.Now())
+	return c.conn.QueryRowContext(ctx, query, args...)
+}
+
+// slowStmt times a prepared hot query like slowConn
+type slowStmt struct {
+	hotQuery
+	query string
+	s     *Storer
+}
+
+func (q slowStmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	defer q.s.observeQuery(q.query, args, time.Now())
+	return q.hotQuery.QueryContext(ctx, args...)
+}
+
+func (q slowStmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	defer q.s.observeQuery(q.query, args, time.Now())
+	return q.hotQuery.QueryRowContext(ctx, args...)
+}
+
+// observeQuery queues query for capture if it has taken at least the threshold since
+// start. Queries run until their first row is ready, not until all have been read.
+func (s *Storer) observeQuery(query string, args []interface{}, start time.Time) {
+	took := time.Since(start)
+	if took < s.slowThreshold {
+		return
+	}
+	select {
+	case s.slowQueries <- slowQuery{query: query, args: args, took: took, at: start}:
+	default:
+		ll := s.logCtx(context.Background(), "slow_queries")
+		ll.Debug().Dur("took", took).Msg("dropped slow query; capture backlog full")
+	}
+}
+
+// captureSlowQueries explains and stores the queued slow queries until the store is
+// closed. It runs on the pool, so capturing never counts as a slow query itself.
+func (s *Storer) captureSlowQueries() {
+	ll := s.logCtx(context.Background(), "slow_queries")
+	for {
+		var q slowQuery
+		select {
+		case <-s.done:
+			return
+		case q = <-s.slowQueries:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), slowQueryTimeout)
+		if err := s.captureSlowQuery(ctx, q); err != nil {
+			ll.Warn().Err(err).Msg("failed to capture slow query")
+		}
+		cancel()
+	}
+}
+
+func (s *Storer) captureSlowQuery(ctx context.Context, q slowQuery) error {
+	query := strings.TrimSpace(q.query)
+	plan := explain(ctx, s.dbPool, query, q.args)
+	_, err := s.dbPool.ExecContext(ctx, `
+		INSERT INTO slow_queries (query, duration_us, plan, captured_at) VALUES ($1, $2, $3, $4)
+	`, query, q.took.Microseconds(), plan, q.at)
+	if err != nil {
+		return fmt.Errorf("failed to store slow query: %w", err)
+	}
+	_, err = s.dbPool.ExecContext(ctx, `
+		DELETE FROM slow_queries WHERE id <= (SELECT MAX(id) FROM slow_queries) - $1
+	`, slowQueryLimit)
+	if err != nil {
+		return fmt.Errorf("failed to prune slow queries: %w", err)
+	}
+	return nil
+}
+
+// explainable matches the statements EXPLAIN accepts
+var explainable = regexp.MustCompile(`(?i)^(SELECT|WITH|INSERT|UPDATE|DELETE)\b`)
+
+// explain returns the plan of query with args, without running it. Statements EXPLAIN
+// doesn't accept have no plan; failing to explain one is described in its place.
+func explain(ctx context.Context, db *sql.DB, query string, args []interface{}) string {
+	if !explainable.MatchString(query) {
+		return ""
+	}
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "EXPLAIN failed: " + err.Error()
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "EXPLAIN failed: " + err.Error()
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "EXPLAIN failed: " + err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ListSlowQueries summarizes the captured slow queries, worst total time first, with the
+// plan of each one's latest capture and the indexes which might serve it
+func (s *Storer) ListSlowQueries(ctx context.Context, limit int) ([]*api.SlowQuery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT query, COUNT(*), SUM(duration_us), MAX(duration_us), MAX(captured_at),
+			(ARRAY_AGG(plan ORDER BY captured_at DESC, id DESC))[1]
+		FROM slow_queries
+		GROUP BY query
+		ORDER BY SUM(duration_us) DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query slow queries: %w", err)
+	}
+	defer rows.Close()
+
+	var queries []*api.SlowQuery
+	for rows.Next() {
+		var q api.SlowQuery
+		var total, max int64
+		if err := rows.Scan(&q.Query, &q.Count, &total, &max, &q.LastSeen, &q.Plan); err != nil {
+			return nil, fmt.Errorf("failed to scan slow query: %w", err)
+		}
+		q.Total = time.Duration(total) * time.Microsecond
+		q.Max = time.Duration(max) * time.Microsecond
+		q.Mean = q.Total / time.Duration(q.Count)
+		q.Suggestions = suggestIndexes(q.Plan)
+		queries = append(queries, &q)
+	}
+	return queries, rows.Err()
+}
+
+// ClearSlowQueries deletes the captured slow queries, such as once indexes have been
+// added for them
+func (s *Storer) ClearSlowQueries(ctx context.Context) error {
+	ll := s.logCtx(ctx, "slow_queries")
+	ll.Info().Msg("clearing slow queries")
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM slow_queries`); err != nil {
+		return fmt.Errorf("failed to clear slow queries: %w", err)
+	}
+	return nil
+}
+
+var (
+	// seqScan matches a sequential scan, capturing its table
+	seqScan = regexp.MustCompile(`Seq Scan on (\w+)`)
+	// unnestScan matches a scan of an unnested array, such as the tags of each row
+	unnestScan = regexp.MustCompile(`Function Scan on unnest`)
+	// filterCondition matches a column compared in a Filter line, capturing the column
+	// and the operator; columns may be qualified, quoted, cast and parenthesized
+	filterCondition = regexp.MustCompile(`\(*(?:\w+\.)?("?[a-z_][a-z0-9_]*"?)\)?(?:::[a-z ]+?)?\)? (=|<>|<=|>=|<|>|~~\*?) `)
+)
+
+// suggestIndexes reads a plan for sequential scans filtered on columns and suggests the
+// indexes which would let them be looked up instead. Prefix matches (LIKE) get a
+// text_pattern_ops index; prefix matches over unnested arrays, such as tag LIKE queries,
+// can't use one and get a note.
+func suggestIndexes(plan string) []string {
+	suggestions := []string{}
+	seen := map[string]bool{}
+	add := func(suggestion string) {
+		if !seen[suggestion] {
+			seen[suggestion] = true
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	// table is the table of the scan the following Filter lines belong to, if any
+	var table string
+	var unnest bool
+	var equal, ranged, like []string
+	flush := func() {
+		if table != "" {
+			cols := append(equal, ranged...)
+			if len(cols) > 0 {
+				add(fmt.Sprintf("CREATE INDEX ON %s (%s)", table, strings.Join(dedupe(cols), ", ")))
+			}
+			for _, col := range dedupe(like) {
+				add(fmt.Sprintf("CREATE INDEX ON %s (%s text_pattern_ops)", table, col))
+			}
+		}
+		if unnest && len(like) > 0 {
+			add("Prefix matches over an unnested array, such as tag LIKE, read every element of every row; " +
+				"no index on the array serves them, so keep the elements in a table of their own indexed with text_pattern_ops")
+		}
+		table, unnest, equal, ranged, like = "", false, nil, nil, nil
+	}
+
+	for _, line := range strings.Split(plan, "\n") {
+		// Plan nodes carry their estimated cost; the lines after them are their details
+		if strings.Contains(line, "(cost=") {
+			flush()
+			if m := seqScan.FindStringSubmatch(line); m != nil {
+				table = m[1]
+			}
+			unnest = unnestScan.MatchString(line)
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "Filter:") || table == "" && !unnest {
+			continue
+		}
+		for _, m := range filterCondition.FindAllStringSubmatch(trimmed, -1) {
+			switch col, op := m[1], m[2]; op {
+			case "=":
+				equal = append(equal, col)
+			case "~~", "~~*":
+				like = append(like, col)
+			case "<>":
+				// An index doesn't help to exclude one value
+			default:
+				ranged = append(ranged, col)
+			}
+		}
+	}
+	flush()
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+// dedupe returns values without repeats, in their first order
+func dedupe(values []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
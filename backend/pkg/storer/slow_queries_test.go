@@ -0,0 +1,59 @@
+package storer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSuggestIndexes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		plan string
+		want []string
+	}{
+		{
+			name: "filtered sequential scan",
+			plan: `Sort  (cost=25.01..25.02 rows=1 width=56)
+  Sort Key: "timestamp" DESC
+  ->  Seq Scan on sensor_readings  (cost=0.00..25.00 rows=1 width=56)
+        Filter: (((device_id)::text = $1) AND ((sensor_id)::text = $2) AND ("timestamp" >= $3))`,
+			want: []string{`CREATE INDEX ON sensor_readings (device_id, sensor_id, "timestamp")`},
+		},
+		{
+			name: "range on a plain column",
+			plan: `Seq Scan on audit_log  (cost=0.00..30.00 rows=10 width=100)
+  Filter: (id < $1)`,
+			want: []string{"CREATE INDEX ON audit_log (id)"},
+		},
+		{
+			name: "prefix match on a column",
+			plan: `Seq Scan on devices d  (cost=0.00..12.00 rows=1 width=200)
+  Filter: ((d.name)::text ~~ $1)`,
+			want: []string{"CREATE INDEX ON devices (name text_pattern_ops)"},
+		},
+		{
+			name: "tag prefix match",
+			plan: `Unique  (cost=100.00..110.00 rows=10 width=200)
+  ->  Sort  (cost=100.00..102.00 rows=100 width=200)
+        Sort Key: devices.name, devices.id
+        ->  Nested Loop  (cost=0.00..80.00 rows=100 width=200)
+              ->  Seq Scan on devices  (cost=0.00..12.00 rows=200 width=200)
+              ->  Function Scan on unnest tag  (cost=0.00..0.13 rows=1 width=0)
+                    Filter: (tag ~~ $1)`,
+			want: []string{"Prefix matches over an unnested array, such as tag LIKE, read every element of every row; " +
+				"no index on the array serves them, so keep the elements in a table of their own indexed with text_pattern_ops"},
+		},
+		{
+			name: "index scan",
+			plan: `Index Scan using sensor_latest_pkey on sensor_latest  (cost=0.15..8.17 rows=1 width=56)
+  Index Cond: (((device_id)::text = $1) AND ((sensor_id)::text = $2))`,
+			want: []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := suggestIndexes(tc.plan); !slices.Equal(got, tc.want) {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,1248 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteMigrationFiles are the SQLite schema changes, versioned separately from the
+// PostgreSQL ones
+//
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrationFiles embed.FS
+
+// sqliteMigrations are the embedded SQLite migrations in version order
+var sqliteMigrations = mustLoadMigrations(loadSQLiteMigrations)
+
+func loadSQLiteMigrations() ([]Migration, error) {
+	return readMigrations(sqliteMigrationFiles, "migrations/sqlite")
+}
+
+// sqliteScheme prefixes connection strings naming a SQLite database
+const sqliteScheme = "sqlite:"
+
+// sqliteTimeFormat stores timestamps as fixed-width UTC text, so comparing them as
+// strings compares them in time
+const sqliteTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// SQLite is an implementation of Interface backed by a single SQLite database file, for
+// deployments, such as a Raspberry Pi running the whole stack, where PostgreSQL is too
+// heavy. It has the Storer's semantics; schema drift checks are PostgreSQL only.
+//
+// The database is used through one connection, which serializes writes the way
+// SQLite would anyway and keeps change feed sequence numbers in commit order.
+type SQLite struct {
+	db  *sql.DB
+	log zerolog.Logger
+	// now is the store's clock for the timestamps PostgreSQL would take from NOW()
+	now func() time.Time
+}
+
+// Open connects to the store named by connString: a SQLite database for a sqlite: URL,
+// such as sqlite:///var/lib/lifesupport/lifesupport.db or sqlite::memory:, and
+// PostgreSQL otherwise. opts configure PostgreSQL only; SQLite always uses a single
+// connection.
+func Open(connString string, opts ...Option) (Interface, error) {
+	if path, ok := strings.CutPrefix(connString, sqliteScheme); ok {
+		return NewSQLite(strings.TrimPrefix(path, "//"))
+	}
+	return New(connString, opts...)
+}
+
+// NewSQLite opens, creating if need be, the SQLite database at path; ":memory:" opens a
+// database that lasts as long as the store
+func NewSQLite(path string) (*SQLite, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+	if path != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &SQLite{db: db, log: log.Logger, now: time.Now}, nil
+}
+
+func (s *SQLite) logCtx(ctx context.Context, sub string) zerolog.Logger {
+	return storerLogCtx(ctx, s.log, sub)
+}
+
+// Close closes the database
+func (s *SQLite) Close() error {
+	log.Debug().Msg("closing database connection")
+	return s.db.Close()
+}
+
+// Migrate applies each migration the database hasn't recorded in schema_migrations, in
+// order, each in its own transaction with its record
+func (s *SQLite) Migrate(ctx context.Context) error {
+	ll := s.logCtx(ctx, "schema")
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	for _, m := range sqliteMigrations {
+		applied, err := s.applyMigration(ctx, m)
+		if err != nil {
+			return err
+		}
+		if applied {
+			ll.Info().Int("version", m.Version).Str("name", m.Name).Msg("applied schema migration")
+		}
+	}
+	return nil
+}
+
+// applyMigration applies m unless it has been already, reporting whether it was
+func (s *SQLite) applyMigration(ctx context.Context, m Migration) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return false, fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+	}
+	query := `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, query, m.Version, m.Name, s.timestamp()); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// timestamp is the current time as stored
+func (s *SQLite) timestamp() string {
+	return sqliteTime(s.now())
+}
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// sqliteCode returns the extended result code of a SQLite error, or 0 for other errors
+func sqliteCode(err error) int {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()
+	}
+	return 0
+}
+
+// isConflict reports whether err violates a primary key, a unique constraint or the
+// tag uniqueness triggers
+func isConflict(err error) bool {
+	switch sqliteCode(err) {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_TRIGGER:
+		return true
+	}
+	return false
+}
+
+func isForeignKeyViolation(err error) bool {
+	return sqliteCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+// encodeEntity marshals the metadata and tags columns of a device, sensor or actuator
+func encodeEntity(metadata map[string]string, tags []string) (string, string, error) {
+	md, err := json.Marshal(metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	t, err := json.Marshal(tags)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	return string(md), string(t), nil
+}
+
+// decodeEntity unmarshals the metadata and tags columns of a device, sensor or actuator
+func decodeEntity(metadata sql.NullString, tags string, md *map[string]string, t *[]string) error {
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), md); err != nil {
+			return fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(tags), t); err != nil {
+		return fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Tag conditions over an entity's tags: $1 is the tag, or the prefix for sqlHasTagPrefix
+const (
+	sqlHasTag       = `EXISTS (SELECT 1 FROM json_each(tags) WHERE value = $1)`
+	sqlHasAliasTag  = `EXISTS (SELECT 1 FROM json_each(tags) WHERE value = (SELECT target FROM tag_aliases WHERE alias = $1))`
+	sqlHasTagPrefix = `EXISTS (SELECT 1 FROM json_each(tags) WHERE substr(value, 1, length($1)) = $1)`
+)
+
+// sqlMatchesMetadata is a condition that an entity's metadata has every key of the JSON
+// object param set to the same value
+func sqlMatchesMetadata(param string) string {
+	return `NOT EXISTS (SELECT 1 FROM json_each(` + param + `) f WHERE NOT EXISTS (
+		SELECT 1 FROM json_each(coalesce(metadata, '{}')) m WHERE m.key = f.key AND m.value = f.value))`
+}
+
+// Device operations
+
+const sqliteDeviceColumns = `id, driver, name, description, metadata, tags, external_id, version`
+
+func scanSQLiteDevice(row rowScanner) (*api.Device, error) {
+	var dev api.Device
+	var metadata sql.NullString
+	var tags string
+	if err := row.Scan(&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadata, &tags, &dev.ExternalID, &dev.Version); err != nil {
+		return nil, err
+	}
+	if err := decodeEntity(metadata, tags, &dev.Metadata, &dev.Tags); err != nil {
+		return nil, err
+	}
+	return &dev, nil
+}
+
+// queryDevices runs a query selecting sqliteDeviceColumns
+func (s *SQLite) queryDevices(ctx context.Context, query string, args ...any) ([]*api.Device, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*api.Device
+	for rows.Next() {
+		dev, err := scanSQLiteDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, dev)
+	}
+	return devices, rows.Err()
+}
+
+// CreateDevice creates a new device with its nested sensors and actuators in a transaction
+func (s *SQLite) CreateDevice(ctx context.Context, dev *api.Device) error {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", dev.ID).Str("driver", string(dev.Driver)).Msg("creating device")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.createDevice(ctx, tx, dev); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// CreateDevices creates devs, each with its nested sensors and actuators, in a single
+// transaction. A device that cannot be created is skipped, with its error at its index
+// in the returned slice; the returned error is for the batch as a whole.
+func (s *SQLite) CreateDevices(ctx context.Context, devs []*api.Device) ([]error, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Int("count", len(devs)).Msg("creating devices")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(devs))
+	for i, dev := range devs {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT create_device`); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if errs[i] = s.createDevice(ctx, tx, dev); errs[i] != nil {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT create_device`); err != nil {
+				return nil, fmt.Errorf("failed to roll back device %s: %w", dev.ID, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT create_device`); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return errs, nil
+}
+
+// createDevice creates dev with its nested sensors and actuators within tx
+func (s *SQLite) createDevice(ctx context.Context, tx *sql.Tx, dev *api.Device) error {
+	if err := tagDevice(ctx, tx, dev); err != nil {
+		return err
+	}
+	if err := ensureExternalID(&dev.ExternalID); err != nil {
+		return err
+	}
+	dev.Version = 1
+	metadata, tags, err := encodeEntity(dev.Metadata, dev.Tags)
+	if err != nil {
+		return err
+	}
+
+	now := s.timestamp()
+	query := `
+		INSERT INTO devices (id, driver, name, description, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+	`
+	err = s.audited(ctx, tx, api.AuditEntityDevice, []string{dev.ID}, func() error {
+		if _, err := tx.ExecContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, tags, dev.ExternalID, now); err != nil {
+			if isConflict(err) {
+				return fmt.Errorf("%w: device with id %s", ErrAlreadyExists, dev.ID)
+			}
+			return fmt.Errorf("failed to create device: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, sensor := range dev.Sensors {
+		sensor.DeviceID = dev.ID
+		err := s.audited(ctx, tx, api.AuditEntitySensor, []string{dev.ID, sensor.ID}, func() error {
+			return s.createSensor(ctx, tx, sensor)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, actuator := range dev.Actuators {
+		actuator.DeviceID = dev.ID
+		err := s.audited(ctx, tx, api.AuditEntityActuator, []string{dev.ID, actuator.ID}, func() error {
+			return s.createActuator(ctx, tx, actuator)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	change, err := newChange(api.ChangeDeviceCreated, dev.ID, dev)
+	if err != nil {
+		return err
+	}
+	return s.recordChanges(ctx, tx, change)
+}
+
+// GetDevice retrieves a device by ID, with its sensors and actuators ordered by name
+func (s *SQLite) GetDevice(ctx context.Context, id string) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", id).Msg("getting device")
+	query := `SELECT ` + sqliteDeviceColumns + ` FROM devices WHERE id = $1`
+	dev, err := scanSQLiteDevice(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: device %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+
+	query = `SELECT ` + sqliteSensorColumns + ` FROM sensors WHERE device_id = $1 ORDER BY name, id`
+	if dev.Sensors, err = s.querySensors(ctx, query, id); err != nil {
+		return nil, err
+	}
+	query = `SELECT ` + sqliteActuatorColumns + ` FROM actuators WHERE device_id = $1 ORDER BY name, id`
+	if dev.Actuators, err = s.queryActuators(ctx, query, id); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// UpdateDevice updates an existing device
+func (s *SQLite) UpdateDevice(ctx context.Context, dev *api.Device) error {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", dev.ID).Msg("updating device")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.updateDevice(ctx, tx, dev); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// updateDevice updates dev within tx
+func (s *SQLite) updateDevice(ctx context.Context, tx *sql.Tx, dev *api.Device) error {
+	templates, err := loadTagTemplates(ctx, tx)
+	if err != nil {
+		return err
+	}
+	dev.EnsureDefaultTag(templates)
+	metadata, tags, err := encodeEntity(dev.Metadata, dev.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE devices
+		SET driver = $2, name = $3, description = $4, metadata = $5, tags = $6, updated_at = $7, version = version + 1
+		WHERE id = $1 AND version = $8
+		RETURNING version
+	`
+	err = s.audited(ctx, tx, api.AuditEntityDevice, []string{dev.ID}, func() error {
+		err := tx.QueryRowContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, tags, s.timestamp(), dev.Version).Scan(&dev.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx, `SELECT version FROM devices WHERE id = $1`, fmt.Sprintf("device %s", dev.ID), dev.ID)
+		}
+		if err != nil {
+			if isConflict(err) {
+				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+			}
+			return fmt.Errorf("failed to update device: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	change, err := newChange(api.ChangeDeviceUpdated, dev.ID, dev)
+	if err != nil {
+		return err
+	}
+	return s.recordChanges(ctx, tx, change)
+}
+
+// PatchDevices applies patch to every device sel matches in a single transaction,
+// returning the matched devices ordered by name. A device the patch leaves as it was
+// isn't updated; one that cannot be is left as it was, with its error at its index in
+// the returned slice. The returned error is for the batch as a whole.
+func (s *SQLite) PatchDevices(ctx context.Context, sel api.DeviceSelector, patch *api.DevicePatch) ([]*api.Device, []error, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("driver", string(sel.Driver)).Strs("tags", sel.Tags).Msg("patching devices")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+sqliteDeviceColumns+` FROM devices ORDER BY name, id`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query devices: %w", err)
+	}
+	var candidates []*api.Device
+	for rows.Next() {
+		dev, err := scanSQLiteDevice(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		candidates = append(candidates, dev)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query devices: %w", err)
+	}
+
+	var devices []*api.Device
+	var errs []error
+	for _, dev := range candidates {
+		if !sel.Matches(dev) {
+			continue
+		}
+		devices = append(devices, dev)
+		errs = append(errs, nil)
+		if !patch.Apply(dev) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT patch_device`); err != nil {
+			return nil, nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if errs[len(errs)-1] = s.updateDevice(ctx, tx, dev); errs[len(errs)-1] != nil {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT patch_device`); err != nil {
+				return nil, nil, fmt.Errorf("failed to roll back device %s: %w", dev.ID, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT patch_device`); err != nil {
+			return nil, nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return devices, errs, nil
+}
+
+// PatchDevice applies update to the device with id, writing only the columns it
+// changes, and returns the device with its sensors and actuators. A device the update
+// leaves as it was isn't written.
+func (s *SQLite) PatchDevice(ctx context.Context, id string, update *api.DeviceMergePatch) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", id).Msg("patching device")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	what := fmt.Sprintf("device %s", id)
+	current, err := scanSQLiteDevice(tx.QueryRowContext(ctx, `SELECT `+sqliteDeviceColumns+` FROM devices WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	if err := patchVersion(what, update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	templates, err := loadTagTemplates(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	dev := copyDevice(current)
+	update.Apply(dev)
+	dev.EnsureDefaultTag(templates)
+
+	if cols := deviceColumnsChanged(current, dev); len(cols) > 0 {
+		metadata, tags, err := encodeEntity(dev.Metadata, dev.Tags)
+		if err != nil {
+			return nil, err
+		}
+		q := patchQuery("devices", cols, map[string]any{
+			"driver": dev.Driver, "name": dev.Name, "description": dev.Description, "metadata": metadata, "tags": tags,
+		}).Set("updated_at", s.timestamp()).Where(squirrel.Eq{"id": id, "version": current.Version})
+		err = s.audited(ctx, tx, api.AuditEntityDevice, []string{id}, func() error {
+			return patchRow(ctx, tx, q, &dev.Version, `SELECT version FROM devices WHERE id = $1`, what, id)
+		})
+		if err != nil {
+			return nil, err
+		}
+		change, err := newChange(api.ChangeDeviceUpdated, id, dev)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.recordChanges(ctx, tx, change); err != nil {
+			return nil, err
+		}
+	}
+	// The transaction holds the only connection, so ends before the components are read
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	query := `SELECT ` + sqliteSensorColumns + ` FROM sensors WHERE device_id = $1 ORDER BY name, id`
+	if dev.Sensors, err = s.querySensors(ctx, query, id); err != nil {
+		return nil, err
+	}
+	query = `SELECT ` + sqliteActuatorColumns + ` FROM actuators WHERE device_id = $1 ORDER BY name, id`
+	if dev.Actuators, err = s.queryActuators(ctx, query, id); err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// DeleteDevice deletes a device and everything belonging to it
+func (s *SQLite) DeleteDevice(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", id).Msg("deleting device")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = s.audited(ctx, tx, api.AuditEntityDevice, []string{id}, func() error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM devices WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete device: %w", err)
+		}
+		return expectRow(result, "device %s", id)
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.recordChanges(ctx, tx, &api.ChangeEvent{Type: api.ChangeDeviceDeleted, EntityID: id}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// expectRow returns ErrNotFound, naming the entity, unless result affected a row
+func expectRow(result sql.Result, format string, args ...any) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: "+format, append([]any{ErrNotFound}, args...)...)
+	}
+	return nil
+}
+
+// staleOrMissing explains an update that matched no row of a versioned entity, what:
+// ErrNotFound if it does not exist, otherwise ErrConflict. versionQuery selects its
+// version by keys within tx.
+func staleOrMissing(ctx context.Context, tx *sql.Tx, versionQuery, what string, keys ...any) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, versionQuery, keys...).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrNotFound, what)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s version: %w", what, err)
+	}
+	return fmt.Errorf("%w: %s is at version %d", ErrConflict, what, version)
+}
+
+// ListDevices retrieves all devices, ordered by name
+func (s *SQLite) ListDevices(ctx context.Context) ([]*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Msg("listing all devices")
+	return s.queryDevices(ctx, `SELECT `+sqliteDeviceColumns+` FROM devices ORDER BY name, id`)
+}
+
+// ListDevicesPage retrieves a page of devices, ordered by name, and the cursor of the
+// next page
+func (s *SQLite) ListDevicesPage(ctx context.Context, page Page) ([]*api.Device, string, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Int("limit", page.Limit).Msg("listing page of devices")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT ` + sqliteDeviceColumns + `
+		FROM devices
+		WHERE ($1 = '' OR (name, id) > ($2, $1))
+			AND ` + sqlMatchesMetadata("$4") + `
+		ORDER BY name, id
+		LIMIT coalesce($3, -1)
+	`
+	devices, err := s.queryDevices(ctx, query, after.ID, after.Name, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", err
+	}
+	devices, next := nextCursor(devices, page, devicePageKey)
+	return devices, next, nil
+}
+
+// GetDeviceByTag retrieves a device with a specific tag, falling back to resolving the
+// tag as an alias when no device carries it
+func (s *SQLite) GetDeviceByTag(ctx context.Context, tag string) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("tag", tag).Msg("getting device by tag")
+	query := `
+		SELECT ` + sqliteDeviceColumns + `
+		FROM devices
+		WHERE ` + sqlHasTag + ` OR ` + sqlHasAliasTag + `
+		ORDER BY ` + sqlHasTag + ` DESC
+		LIMIT 1
+	`
+	dev, err := scanSQLiteDevice(s.db.QueryRowContext(ctx, query, tag))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: device with tag %s", ErrNotFound, tag)
+		}
+		return nil, fmt.Errorf("failed to get device by tag: %w", err)
+	}
+	return dev, nil
+}
+
+// ListDevicesByTagPrefix retrieves devices with tags matching a prefix
+func (s *SQLite) ListDevicesByTagPrefix(ctx context.Context, prefix string) ([]*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("prefix", prefix).Msg("listing devices by tag prefix")
+	return s.queryDevices(ctx, `SELECT `+sqliteDeviceColumns+` FROM devices WHERE `+sqlHasTagPrefix+` ORDER BY name, id`, prefix)
+}
+
+// GetDeviceByExternalID retrieves a device, including its sensors and actuators, by its
+// immutable external ID
+func (s *SQLite) GetDeviceByExternalID(ctx context.Context, externalID string) (*api.Device, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("external_id", externalID).Msg("getting device by external id")
+	var id string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM devices WHERE external_id = $1`, externalID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: device with external id %s", ErrNotFound, externalID)
+		}
+		return nil, fmt.Errorf("failed to get device by external id: %w", err)
+	}
+	return s.GetDevice(ctx, id)
+}
+
+// Sensor operations
+
+const sqliteSensorColumns = `id, device_id, name, sensor_type, metadata, tags, external_id, version`
+
+func scanSQLiteSensor(row rowScanner) (*api.Sensor, error) {
+	var sensor api.Sensor
+	var metadata sql.NullString
+	var tags string
+	if err := row.Scan(&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadata, &tags, &sensor.ExternalID, &sensor.Version); err != nil {
+		return nil, err
+	}
+	if err := decodeEntity(metadata, tags, &sensor.Metadata, &sensor.Tags); err != nil {
+		return nil, err
+	}
+	return &sensor, nil
+}
+
+// querySensors runs a query selecting sqliteSensorColumns
+func (s *SQLite) querySensors(ctx context.Context, query string, args ...any) ([]*api.Sensor, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	defer rows.Close()
+
+	var sensors []*api.Sensor
+	for rows.Next() {
+		sensor, err := scanSQLiteSensor(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors, rows.Err()
+}
+
+// getSensor runs a query selecting a single sensor's sqliteSensorColumns
+func (s *SQLite) getSensor(ctx context.Context, what, query string, args ...any) (*api.Sensor, error) {
+	sensor, err := scanSQLiteSensor(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+		}
+		return nil, fmt.Errorf("failed to get sensor: %w", err)
+	}
+	return sensor, nil
+}
+
+// createSensor inserts a sensor using the provided execer (db or tx)
+func (s *SQLite) createSensor(ctx context.Context, exec execer, sensor *api.Sensor) error {
+	if len(sensor.Tags) == 0 {
+		sensor.Tags = []string{sensor.DefaultTag(sensor.DeviceID)}
+	}
+	if err := ensureExternalID(&sensor.ExternalID); err != nil {
+		return err
+	}
+	sensor.Version = 1
+	metadata, tags, err := encodeEntity(sensor.Metadata, sensor.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sensors (id, device_id, name, sensor_type, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+	`
+	_, err = exec.ExecContext(ctx, query, sensor.ID, sensor.DeviceID, sensor.Name, sensor.SensorType, metadata, tags, sensor.ExternalID, s.timestamp())
+	if err != nil {
+		if isConflict(err) {
+			return fmt.Errorf("%w: sensor %s/%s", ErrAlreadyExists, sensor.DeviceID, sensor.ID)
+		}
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: device %s", ErrNotFound, sensor.DeviceID)
+		}
+		return fmt.Errorf("failed to create sensor: %w", err)
+	}
+	return nil
+}
+
+// CreateSensor creates a new sensor
+func (s *SQLite) CreateSensor(ctx context.Context, sensor *api.Sensor) error {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", sensor.DeviceID).Str("sensor_id", sensor.ID).Str("sensor_type", string(sensor.SensorType)).Msg("creating sensor")
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, func(tx *sql.Tx) error {
+		if err := tagSensor(ctx, tx, sensor); err != nil {
+			return err
+		}
+		return s.createSensor(ctx, tx, sensor)
+	})
+}
+
+// GetSensor retrieves a sensor by device ID and sensor ID
+func (s *SQLite) GetSensor(ctx context.Context, deviceID, sensorID string) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("getting sensor")
+	return s.getSensor(ctx, "sensor "+deviceID+"/"+sensorID,
+		`SELECT `+sqliteSensorColumns+` FROM sensors WHERE device_id = $1 AND id = $2`, deviceID, sensorID)
+}
+
+// UpdateSensor updates an existing sensor
+func (s *SQLite) UpdateSensor(ctx context.Context, sensor *api.Sensor) error {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", sensor.DeviceID).Str("sensor_id", sensor.ID).Msg("updating sensor")
+	metadata, tags, err := encodeEntity(sensor.Metadata, sensor.Tags)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE sensors
+		SET name = $3, sensor_type = $4, metadata = $5, tags = $6, updated_at = $7, version = version + 1
+		WHERE device_id = $1 AND id = $2 AND version = $8
+		RETURNING version
+	`
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, sensor.DeviceID, sensor.ID, sensor.Name, sensor.SensorType, metadata, tags, s.timestamp(), sensor.Version).Scan(&sensor.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx, `SELECT version FROM sensors WHERE device_id = $1 AND id = $2`, fmt.Sprintf("sensor %s/%s", sensor.DeviceID, sensor.ID), sensor.DeviceID, sensor.ID)
+		}
+		if err != nil {
+			if isConflict(err) {
+				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+			}
+			return fmt.Errorf("failed to update sensor: %w", err)
+		}
+		return nil
+	})
+}
+
+// PatchSensor applies update to a sensor, writing only the columns it changes, and
+// returns the sensor
+func (s *SQLite) PatchSensor(ctx context.Context, deviceID, sensorID string, update *api.SensorMergePatch) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("patching sensor")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	what := fmt.Sprintf("sensor %s/%s", deviceID, sensorID)
+	current, err := scanSQLiteSensor(tx.QueryRowContext(ctx, `SELECT `+sqliteSensorColumns+` FROM sensors WHERE device_id = $1 AND id = $2`, deviceID, sensorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor: %w", err)
+	}
+	if err := patchVersion(what, update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	sensor := copySensor(current)
+	update.Apply(sensor)
+	cols := sensorColumnsChanged(current, sensor)
+	if len(cols) == 0 {
+		return sensor, nil
+	}
+
+	metadata, tags, err := encodeEntity(sensor.Metadata, sensor.Tags)
+	if err != nil {
+		return nil, err
+	}
+	q := patchQuery("sensors", cols, map[string]any{
+		"name": sensor.Name, "sensor_type": sensor.SensorType, "metadata": metadata, "tags": tags,
+	}).Set("updated_at", s.timestamp()).Where(squirrel.Eq{"device_id": deviceID, "id": sensorID, "version": current.Version})
+	err = s.audited(ctx, tx, api.AuditEntitySensor, []string{deviceID, sensorID}, func() error {
+		return patchRow(ctx, tx, q, &sensor.Version, `SELECT version FROM sensors WHERE device_id = $1 AND id = $2`, what, deviceID, sensorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return sensor, nil
+}
+
+// DeleteSensor deletes a sensor by device ID and sensor ID
+func (s *SQLite) DeleteSensor(ctx context.Context, deviceID, sensorID string) error {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting sensor")
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{deviceID, sensorID}, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM sensors WHERE device_id = $1 AND id = $2`, deviceID, sensorID)
+		if err != nil {
+			return fmt.Errorf("failed to delete sensor: %w", err)
+		}
+		return expectRow(result, "sensor %s/%s", deviceID, sensorID)
+	})
+}
+
+// ListSensors retrieves all sensors
+func (s *SQLite) ListSensors(ctx context.Context) ([]*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Msg("listing all sensors")
+	return s.querySensors(ctx, `SELECT `+sqliteSensorColumns+` FROM sensors ORDER BY name, device_id, id`)
+}
+
+// ListSensorsPage retrieves a page of sensors, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the sensors of every device.
+func (s *SQLite) ListSensorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Sensor, string, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Int("limit", page.Limit).Msg("listing page of sensors")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT ` + sqliteSensorColumns + `
+		FROM sensors
+		WHERE ($1 = '' OR device_id = $1)
+			AND ($2 = '' OR (name, device_id, id) > ($3, $4, $2))
+			AND ` + sqlMatchesMetadata("$6") + `
+		ORDER BY name, device_id, id
+		LIMIT coalesce($5, -1)
+	`
+	sensors, err := s.querySensors(ctx, query, deviceID, after.ID, after.Name, after.DeviceID, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", err
+	}
+	sensors, next := nextCursor(sensors, page, sensorPageKey)
+	return sensors, next, nil
+}
+
+// ListSensorsByDeviceID retrieves all sensors for a device
+func (s *SQLite) ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Msg("listing sensors by device")
+	return s.querySensors(ctx, `SELECT `+sqliteSensorColumns+` FROM sensors WHERE device_id = $1 ORDER BY name, id`, deviceID)
+}
+
+// GetSensorByTag retrieves a sensor with a specific tag, falling back to resolving the
+// tag as an alias when no sensor carries it
+func (s *SQLite) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("tag", tag).Msg("getting sensor by tag")
+	query := `
+		SELECT ` + sqliteSensorColumns + `
+		FROM sensors
+		WHERE ` + sqlHasTag + ` OR ` + sqlHasAliasTag + `
+		ORDER BY ` + sqlHasTag + ` DESC
+		LIMIT 1
+	`
+	return s.getSensor(ctx, "sensor with tag "+tag, query, tag)
+}
+
+// ListSensorsByTagPrefix retrieves sensors with tags matching a prefix
+func (s *SQLite) ListSensorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("prefix", prefix).Msg("listing sensors by tag prefix")
+	return s.querySensors(ctx, `SELECT `+sqliteSensorColumns+` FROM sensors WHERE `+sqlHasTagPrefix+` ORDER BY name, device_id, id`, prefix)
+}
+
+// GetSensorByExternalID retrieves a sensor by its immutable external ID
+func (s *SQLite) GetSensorByExternalID(ctx context.Context, externalID string) (*api.Sensor, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("external_id", externalID).Msg("getting sensor by external id")
+	return s.getSensor(ctx, "sensor with external id "+externalID,
+		`SELECT `+sqliteSensorColumns+` FROM sensors WHERE external_id = $1`, externalID)
+}
+
+// Actuator operations
+
+const sqliteActuatorColumns = `id, device_id, name, actuator_type, metadata, tags, external_id, version`
+
+func scanSQLiteActuator(row rowScanner) (*api.Actuator, error) {
+	var actuator api.Actuator
+	var metadata sql.NullString
+	var tags string
+	if err := row.Scan(&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadata, &tags, &actuator.ExternalID, &actuator.Version); err != nil {
+		return nil, err
+	}
+	if err := decodeEntity(metadata, tags, &actuator.Metadata, &actuator.Tags); err != nil {
+		return nil, err
+	}
+	return &actuator, nil
+}
+
+// queryActuators runs a query selecting sqliteActuatorColumns
+func (s *SQLite) queryActuators(ctx context.Context, query string, args ...any) ([]*api.Actuator, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query actuators: %w", err)
+	}
+	defer rows.Close()
+
+	var actuators []*api.Actuator
+	for rows.Next() {
+		actuator, err := scanSQLiteActuator(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan actuator: %w", err)
+		}
+		actuators = append(actuators, actuator)
+	}
+	return actuators, rows.Err()
+}
+
+// getActuator runs a query selecting a single actuator's sqliteActuatorColumns
+func (s *SQLite) getActuator(ctx context.Context, what, query string, args ...any) (*api.Actuator, error) {
+	actuator, err := scanSQLiteActuator(s.db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+		}
+		return nil, fmt.Errorf("failed to get actuator: %w", err)
+	}
+	return actuator, nil
+}
+
+// createActuator inserts an actuator using the provided execer (db or tx)
+func (s *SQLite) createActuator(ctx context.Context, exec execer, actuator *api.Actuator) error {
+	if len(actuator.Tags) == 0 {
+		actuator.Tags = []string{actuator.DefaultTag(actuator.DeviceID)}
+	}
+	if err := ensureExternalID(&actuator.ExternalID); err != nil {
+		return err
+	}
+	actuator.Version = 1
+	metadata, tags, err := encodeEntity(actuator.Metadata, actuator.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO actuators (id, device_id, name, actuator_type, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+	`
+	_, err = exec.ExecContext(ctx, query, actuator.ID, actuator.DeviceID, actuator.Name, actuator.ActuatorType, metadata, tags, actuator.ExternalID, s.timestamp())
+	if err != nil {
+		if isConflict(err) {
+			return fmt.Errorf("%w: actuator %s/%s", ErrAlreadyExists, actuator.DeviceID, actuator.ID)
+		}
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: device %s", ErrNotFound, actuator.DeviceID)
+		}
+		return fmt.Errorf("failed to create actuator: %w", err)
+	}
+	return nil
+}
+
+// CreateActuator creates a new actuator
+func (s *SQLite) CreateActuator(ctx context.Context, actuator *api.Actuator) error {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", actuator.DeviceID).Str("actuator_id", actuator.ID).Str("actuator_type", string(actuator.ActuatorType)).Msg("creating actuator")
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, func(tx *sql.Tx) error {
+		if err := tagActuator(ctx, tx, actuator); err != nil {
+			return err
+		}
+		return s.createActuator(ctx, tx, actuator)
+	})
+}
+
+// GetActuator retrieves an actuator by device ID and actuator ID
+func (s *SQLite) GetActuator(ctx context.Context, deviceID, actuatorID string) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("getting actuator")
+	return s.getActuator(ctx, "actuator "+deviceID+"/"+actuatorID,
+		`SELECT `+sqliteActuatorColumns+` FROM actuators WHERE device_id = $1 AND id = $2`, deviceID, actuatorID)
+}
+
+// UpdateActuator updates an existing actuator
+func (s *SQLite) UpdateActuator(ctx context.Context, actuator *api.Actuator) error {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", actuator.DeviceID).Str("actuator_id", actuator.ID).Msg("updating actuator")
+	metadata, tags, err := encodeEntity(actuator.Metadata, actuator.Tags)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE actuators
+		SET name = $3, actuator_type = $4, metadata = $5, tags = $6, updated_at = $7, version = version + 1
+		WHERE device_id = $1 AND id = $2 AND version = $8
+		RETURNING version
+	`
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, actuator.DeviceID, actuator.ID, actuator.Name, actuator.ActuatorType, metadata, tags, s.timestamp(), actuator.Version).Scan(&actuator.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx, `SELECT version FROM actuators WHERE device_id = $1 AND id = $2`, fmt.Sprintf("actuator %s/%s", actuator.DeviceID, actuator.ID), actuator.DeviceID, actuator.ID)
+		}
+		if err != nil {
+			if isConflict(err) {
+				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+			}
+			return fmt.Errorf("failed to update actuator: %w", err)
+		}
+		return nil
+	})
+}
+
+// PatchActuator applies update to an actuator, writing only the columns it changes, and
+// returns the actuator
+func (s *SQLite) PatchActuator(ctx context.Context, deviceID, actuatorID string, update *api.ActuatorMergePatch) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("patching actuator")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	what := fmt.Sprintf("actuator %s/%s", deviceID, actuatorID)
+	current, err := scanSQLiteActuator(tx.QueryRowContext(ctx, `SELECT `+sqliteActuatorColumns+` FROM actuators WHERE device_id = $1 AND id = $2`, deviceID, actuatorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, what)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get actuator: %w", err)
+	}
+	if err := patchVersion(what, update.Version, current.Version); err != nil {
+		return nil, err
+	}
+	actuator := copyActuator(current)
+	update.Apply(actuator)
+	cols := actuatorColumnsChanged(current, actuator)
+	if len(cols) == 0 {
+		return actuator, nil
+	}
+
+	metadata, tags, err := encodeEntity(actuator.Metadata, actuator.Tags)
+	if err != nil {
+		return nil, err
+	}
+	q := patchQuery("actuators", cols, map[string]any{
+		"name": actuator.Name, "actuator_type": actuator.ActuatorType, "metadata": metadata, "tags": tags,
+	}).Set("updated_at", s.timestamp()).Where(squirrel.Eq{"device_id": deviceID, "id": actuatorID, "version": current.Version})
+	err = s.audited(ctx, tx, api.AuditEntityActuator, []string{deviceID, actuatorID}, func() error {
+		return patchRow(ctx, tx, q, &actuator.Version, `SELECT version FROM actuators WHERE device_id = $1 AND id = $2`, what, deviceID, actuatorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return actuator, nil
+}
+
+// DeleteActuator deletes an actuator by device ID and actuator ID
+func (s *SQLite) DeleteActuator(ctx context.Context, deviceID, actuatorID string) error {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("deleting actuator")
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{deviceID, actuatorID}, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `DELETE FROM actuators WHERE device_id = $1 AND id = $2`, deviceID, actuatorID)
+		if err != nil {
+			return fmt.Errorf("failed to delete actuator: %w", err)
+		}
+		return expectRow(result, "actuator %s/%s", deviceID, actuatorID)
+	})
+}
+
+// ListActuators retrieves all actuators
+func (s *SQLite) ListActuators(ctx context.Context) ([]*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Msg("listing all actuators")
+	return s.queryActuators(ctx, `SELECT `+sqliteActuatorColumns+` FROM actuators ORDER BY name, device_id, id`)
+}
+
+// ListActuatorsPage retrieves a page of actuators, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the actuators of every device.
+func (s *SQLite) ListActuatorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Actuator, string, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Int("limit", page.Limit).Msg("listing page of actuators")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT ` + sqliteActuatorColumns + `
+		FROM actuators
+		WHERE ($1 = '' OR device_id = $1)
+			AND ($2 = '' OR (name, device_id, id) > ($3, $4, $2))
+			AND ` + sqlMatchesMetadata("$6") + `
+		ORDER BY name, device_id, id
+		LIMIT coalesce($5, -1)
+	`
+	actuators, err := s.queryActuators(ctx, query, deviceID, after.ID, after.Name, after.DeviceID, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", err
+	}
+	actuators, next := nextCursor(actuators, page, actuatorPageKey)
+	return actuators, next, nil
+}
+
+// ListActuatorsByDeviceID retrieves all actuators for a device
+func (s *SQLite) ListActuatorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Msg("listing actuators by device")
+	return s.queryActuators(ctx, `SELECT `+sqliteActuatorColumns+` FROM actuators WHERE device_id = $1 ORDER BY name, id`, deviceID)
+}
+
+// GetActuatorByTag retrieves an actuator with a specific tag, falling back to resolving
+// the tag as an alias when no actuator carries it
+func (s *SQLite) GetActuatorByTag(ctx context.Context, tag string) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("tag", tag).Msg("getting actuator by tag")
+	query := `
+		SELECT ` + sqliteActuatorColumns + `
+		FROM actuators
+		WHERE ` + sqlHasTag + ` OR ` + sqlHasAliasTag + `
+		ORDER BY ` + sqlHasTag + ` DESC
+		LIMIT 1
+	`
+	return s.getActuator(ctx, "actuator with tag "+tag, query, tag)
+}
+
+// ListActuatorsByTagPrefix retrieves actuators with tags matching a prefix
+func (s *SQLite) ListActuatorsByTagPrefix(ctx context.Context, prefix string) ([]*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("prefix", prefix).Msg("listing actuators by tag prefix")
+	return s.queryActuators(ctx, `SELECT `+sqliteActuatorColumns+` FROM actuators WHERE `+sqlHasTagPrefix+` ORDER BY name, device_id, id`, prefix)
+}
+
+// GetActuatorByExternalID retrieves an actuator by its immutable external ID
+func (s *SQLite) GetActuatorByExternalID(ctx context.Context, externalID string) (*api.Actuator, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("external_id", externalID).Msg("getting actuator by external id")
+	return s.getActuator(ctx, "actuator with external id "+externalID,
+		`SELECT `+sqliteActuatorColumns+` FROM actuators WHERE external_id = $1`, externalID)
+}
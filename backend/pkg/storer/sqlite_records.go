@@ -0,0 +1,1932 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/google/uuid"
+)
+
+// Tag aliases
+
+// SetTagAlias creates the alias or points an existing alias at a new target
+func (s *SQLite) SetTagAlias(ctx context.Context, alias *api.TagAlias) error {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias.Alias).Str("target", alias.Target).Msg("setting tag alias")
+	query := `
+		INSERT INTO tag_aliases (alias, target, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (alias) DO UPDATE SET target = excluded.target, updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, alias.Alias, alias.Target, s.timestamp()); err != nil {
+		return fmt.Errorf("failed to set tag alias: %w", err)
+	}
+	return nil
+}
+
+// GetTagAlias retrieves an alias by name
+func (s *SQLite) GetTagAlias(ctx context.Context, alias string) (*api.TagAlias, error) {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias).Msg("getting tag alias")
+	var a api.TagAlias
+	err := s.db.QueryRowContext(ctx, `SELECT alias, target FROM tag_aliases WHERE alias = $1`, alias).Scan(&a.Alias, &a.Target)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: tag alias %s", ErrNotFound, alias)
+		}
+		return nil, fmt.Errorf("failed to get tag alias: %w", err)
+	}
+	return &a, nil
+}
+
+// DeleteTagAlias deletes an alias; the tag it pointed at is unaffected
+func (s *SQLite) DeleteTagAlias(ctx context.Context, alias string) error {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Str("alias", alias).Msg("deleting tag alias")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM tag_aliases WHERE alias = $1`, alias)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag alias: %w", err)
+	}
+	return expectRow(result, "tag alias %s", alias)
+}
+
+// ListTagAliases retrieves all aliases, ordered by alias
+func (s *SQLite) ListTagAliases(ctx context.Context) ([]*api.TagAlias, error) {
+	ll := s.logCtx(ctx, "alias")
+	ll.Debug().Msg("listing tag aliases")
+	rows, err := s.db.QueryContext(ctx, `SELECT alias, target FROM tag_aliases ORDER BY alias`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []*api.TagAlias
+	for rows.Next() {
+		var a api.TagAlias
+		if err := rows.Scan(&a.Alias, &a.Target); err != nil {
+			return nil, fmt.Errorf("failed to scan tag alias: %w", err)
+		}
+		aliases = append(aliases, &a)
+	}
+	return aliases, rows.Err()
+}
+
+// Broken references
+
+// AddBrokenReferences records rules left depending on deleted resources; a reference
+// already recorded is refreshed
+func (s *SQLite) AddBrokenReferences(ctx context.Context, refs []*api.BrokenReference) error {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Int("count", len(refs)).Msg("adding broken references")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO broken_references (kind, name, tag, resource, detected_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (kind, name, tag) DO UPDATE SET resource = excluded.resource, detected_at = excluded.detected_at
+	`
+	for _, ref := range refs {
+		if _, err := tx.ExecContext(ctx, query, ref.Kind, ref.Name, ref.Tag, ref.Resource, sqliteTime(ref.DetectedAt)); err != nil {
+			return fmt.Errorf("failed to add broken reference: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListBrokenReferences retrieves all broken references, ordered by rule
+func (s *SQLite) ListBrokenReferences(ctx context.Context) ([]*api.BrokenReference, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Msg("listing broken references")
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, name, tag, resource, detected_at FROM broken_references ORDER BY kind, name, tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query broken references: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []*api.BrokenReference
+	for rows.Next() {
+		var ref api.BrokenReference
+		if err := rows.Scan(&ref.Kind, &ref.Name, &ref.Tag, &ref.Resource, &ref.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan broken reference: %w", err)
+		}
+		refs = append(refs, &ref)
+	}
+	return refs, rows.Err()
+}
+
+// DeleteBrokenReferences clears the broken references of one rule, re-enabling it, and
+// returns how many were cleared
+func (s *SQLite) DeleteBrokenReferences(ctx context.Context, kind, name string) (int64, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Str("kind", kind).Str("name", name).Msg("deleting broken references")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM broken_references WHERE kind = $1 AND name = $2`, kind, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete broken references: %w", err)
+	}
+	return rowsAffected(result)
+}
+
+// ResolveBrokenReferences clears broken references to any of tags and returns how many
+// were cleared
+func (s *SQLite) ResolveBrokenReferences(ctx context.Context, tags []string) (int64, error) {
+	ll := s.logCtx(ctx, "broken_references")
+	ll.Debug().Strs("tags", tags).Msg("resolving broken references")
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM broken_references WHERE tag IN (SELECT value FROM json_each($1))`, string(encoded))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve broken references: %w", err)
+	}
+	return rowsAffected(result)
+}
+
+func rowsAffected(result sql.Result) (int64, error) {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+// Sensor targets
+
+// SetSensorTarget creates or replaces a sensor's target range
+func (s *SQLite) SetSensorTarget(ctx context.Context, target *api.TargetRange) error {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", target.DeviceID).Str("sensor_id", target.SensorID).Msg("setting sensor target")
+	query := `
+		INSERT INTO sensor_targets (` + targetColumns + `, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+			ideal_min = excluded.ideal_min, ideal_max = excluded.ideal_max,
+			warning_min = excluded.warning_min, warning_max = excluded.warning_max,
+			critical_min = excluded.critical_min, critical_max = excluded.critical_max,
+			updated_at = excluded.updated_at
+	`
+	_, err := s.db.ExecContext(ctx, query, target.DeviceID, target.SensorID,
+		target.Ideal.Min, target.Ideal.Max, target.Warning.Min, target.Warning.Max, target.Critical.Min, target.Critical.Max,
+		s.timestamp())
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, target.DeviceID, target.SensorID)
+		}
+		return fmt.Errorf("failed to set sensor target: %w", err)
+	}
+	return nil
+}
+
+// GetSensorTarget retrieves a sensor's target range
+func (s *SQLite) GetSensorTarget(ctx context.Context, deviceID, sensorID string) (*api.TargetRange, error) {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("getting sensor target")
+	row := s.db.QueryRowContext(ctx, `SELECT `+targetColumns+` FROM sensor_targets WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	target, err := scanTarget(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: target for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor target: %w", err)
+	}
+	return target, nil
+}
+
+// DeleteSensorTarget removes a sensor's target range
+func (s *SQLite) DeleteSensorTarget(ctx context.Context, deviceID, sensorID string) error {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting sensor target")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sensor_targets WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor target: %w", err)
+	}
+	return expectRow(result, "target for sensor %s/%s", deviceID, sensorID)
+}
+
+// ListSensorTargets retrieves all target ranges, ordered by sensor
+func (s *SQLite) ListSensorTargets(ctx context.Context) ([]*api.TargetRange, error) {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Msg("listing sensor targets")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+targetColumns+` FROM sensor_targets ORDER BY device_id, sensor_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []*api.TargetRange
+	for rows.Next() {
+		target, err := scanTarget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor target: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// Readings
+
+// StoreSensorReading records a single sensor reading
+func (s *SQLite) StoreSensorReading(ctx context.Context, rec *api.ReadingRecord) error {
+	return s.StoreSensorReadings(ctx, []*api.ReadingRecord{rec})
+}
+
+// StoreSensorReadings records several readings in one transaction, so either all of them
+// are stored or none are
+func (s *SQLite) StoreSensorReadings(ctx context.Context, recs []*api.ReadingRecord) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Int("count", len(recs)).Msg("storing sensor readings")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sensor_readings (device_id, sensor_id, value, unit, valid, error, synthetic, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	changes := make([]*api.ChangeEvent, 0, len(recs))
+	for _, rec := range recs {
+		change, err := newChange(api.ChangeReadingStored, readingEntityID(rec), rec)
+		if err != nil {
+			return err
+		}
+		changes = append(changes, change)
+		_, err = tx.ExecContext(ctx, query, rec.DeviceID, rec.SensorID, rec.Reading.Value, rec.Reading.Unit, rec.Reading.Valid,
+			nullString(rec.Reading.Error), rec.Reading.Synthetic, sqliteTime(rec.Reading.Timestamp))
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, rec.DeviceID, rec.SensorID)
+			}
+			return fmt.Errorf("failed to store sensor reading: %w", err)
+		}
+	}
+	if err := s.recordChanges(ctx, tx, changes...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetSensorReadings returns readings matching filters, newest first
+func (s *SQLite) GetSensorReadings(ctx context.Context, filters SensorReadingFilters) ([]*api.ReadingRecord, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("getting sensor readings")
+
+	q, forward := readingsQuery(filters, sqliteArg)
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sensor readings query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+	defer rows.Close()
+
+	var readings []*api.ReadingRecord
+	for rows.Next() {
+		var rec api.ReadingRecord
+		var errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.SensorID, &rec.Reading.Value, &rec.Reading.Unit, &rec.Reading.Valid, &errMsg, &rec.Reading.Synthetic, &rec.Reading.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading: %w", err)
+		}
+		rec.Reading.Error = errMsg.String
+		readings = append(readings, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sensor readings: %w", err)
+	}
+	if forward {
+		slices.Reverse(readings)
+	}
+	return readings, nil
+}
+
+// CountSensorReadings returns how many readings match filters; Limit is ignored
+func (s *SQLite) CountSensorReadings(ctx context.Context, filters SensorReadingFilters) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("counting sensor readings")
+
+	query, args, err := filterReadings(builder.Select("COUNT(*)").From("sensor_readings"), filters, sqliteArg).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build sensor readings query: %w", err)
+	}
+	var count int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sensor readings: %w", err)
+	}
+	return count, nil
+}
+
+// GetAggregatedReadings aggregates a sensor's valid, measured readings taken in
+// [start, end) into buckets of interval, counted in whole seconds from the Unix epoch,
+// oldest first. Buckets without readings are left out, and only fns are set in each.
+func (s *SQLite) GetAggregatedReadings(ctx context.Context, deviceID, sensorID string, interval time.Duration, fns []api.AggregateFunc, start, end time.Time) ([]*api.ReadingBucket, error) {
+	if interval < time.Second {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: interval %s is too short", interval)
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().
+		Str("device_id", deviceID).
+		Str("sensor_id", sensorID).
+		Dur("interval", interval).
+		Time("start", start).
+		Time("end", end).
+		Msg("aggregating sensor readings")
+	seconds := int64(interval / time.Second)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT CAST(strftime('%s', timestamp) AS INTEGER) / $3 AS bucket,
+			AVG(value), MIN(value), MAX(value), COUNT(*)
+		FROM sensor_readings
+		WHERE device_id = $1 AND sensor_id = $2 AND valid AND NOT synthetic
+			AND timestamp >= $4 AND timestamp < $5
+		GROUP BY bucket
+		ORDER BY bucket
+	`, deviceID, sensorID, seconds, sqliteTime(start), sqliteTime(end))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate sensor readings: %w", err)
+	}
+	defer rows.Close()
+	return scanReadingBuckets(rows, seconds, fns)
+}
+
+// GetLatestSensorReading returns the most recent reading of a sensor
+func (s *SQLite) GetLatestSensorReading(ctx context.Context, deviceID, sensorID string) (*api.SensorReading, error) {
+	readings, err := s.latestReadings(ctx, `WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	if err != nil {
+		return nil, err
+	}
+	if len(readings) == 0 {
+		return nil, fmt.Errorf("%w: no readings for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return &readings[0].Reading, nil
+}
+
+// GetLatestReadingsForDevice returns the most recent reading of each sensor of a device
+// with any, ordered by sensor ID
+func (s *SQLite) GetLatestReadingsForDevice(ctx context.Context, deviceID string) ([]*api.ReadingRecord, error) {
+	return s.latestReadings(ctx, `WHERE device_id = $1`, deviceID)
+}
+
+// latestReadings selects from the latest reading of each sensor, ordered by sensor ID
+func (s *SQLite) latestReadings(ctx context.Context, where string, args ...any) ([]*api.ReadingRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT reading_id, device_id, sensor_id, value, unit, valid, error, synthetic, timestamp
+		FROM sensor_latest `+where+`
+		ORDER BY sensor_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings := []*api.ReadingRecord{}
+	for rows.Next() {
+		var rec api.ReadingRecord
+		var errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.SensorID, &rec.Reading.Value, &rec.Reading.Unit, &rec.Reading.Valid,
+			&errMsg, &rec.Reading.Synthetic, &rec.Reading.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan latest reading: %w", err)
+		}
+		rec.Reading.Error = errMsg.String
+		readings = append(readings, &rec)
+	}
+	return readings, rows.Err()
+}
+
+// DeleteOldSensorReadings removes readings taken before the given time and returns how
+// many were deleted
+func (s *SQLite) DeleteOldSensorReadings(ctx context.Context, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Time("before", before).Msg("deleting old sensor readings")
+	return s.deleteReadings(ctx, `timestamp < $1`, sqliteTime(before))
+}
+
+// deleteReadings deletes the readings, and latest readings, matching where and returns
+// how many readings were deleted. A sensor's latest reading matching means all of its
+// readings do.
+func (s *SQLite) deleteReadings(ctx context.Context, where string, args ...any) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sensor_readings WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old sensor readings: %w", err)
+	}
+	deleted, err := rowsAffected(result)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sensor_latest WHERE `+where, args...); err != nil {
+		return 0, fmt.Errorf("failed to delete old latest readings: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// Commands
+
+// RecordCommand appends a command to its device's history and sets its ID. The command
+// is also entered in the audit log, attributed to its origin, and the state it reported
+// in the change feed.
+func (s *SQLite) RecordCommand(ctx context.Context, rec *api.CommandRecord) error {
+	ll := s.logCtx(ctx, "commands")
+	ll.Debug().Str("device_id", rec.DeviceID).Str("actuator_id", rec.ActuatorID).Msg("recording command")
+
+	params, err := json.Marshal(rec.Command.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal command parameters: %w", err)
+	}
+	var state sql.NullString
+	if rec.State != nil {
+		b, err := json.Marshal(rec.State)
+		if err != nil {
+			return fmt.Errorf("failed to marshal actuator state: %w", err)
+		}
+		state = sql.NullString{String: string(b), Valid: true}
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO command_history (device_id, actuator_id, tag, source, source_name, action, parameters, state, error, issued_at, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	result, err := tx.ExecContext(ctx, query, rec.DeviceID, rec.ActuatorID, rec.Tag, rec.Origin.Source, rec.Origin.Name,
+		rec.Command.Action, string(params), state, nullString(rec.Error), sqliteTime(rec.IssuedAt), rec.LatencyMS)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: device %s", ErrNotFound, rec.DeviceID)
+		}
+		return fmt.Errorf("failed to record command: %w", err)
+	}
+	if rec.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get command id: %w", err)
+	}
+	entry, err := commandAuditEntry(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.recordAudit(ctx, tx, entry); err != nil {
+		return err
+	}
+	changes, err := commandChanges(rec)
+	if err != nil {
+		return err
+	}
+	if err := s.recordChanges(ctx, tx, changes...); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListCommands returns commands matching filters in the order they were issued
+func (s *SQLite) ListCommands(ctx context.Context, filters CommandFilters) ([]*api.CommandRecord, error) {
+	ll := s.logCtx(ctx, "commands")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("actuator_id", filters.ActuatorID).Msg("listing commands")
+
+	// Select the most recent commands, reversed into chronological order below
+	query, args, err := commandsQuery(filters, sqliteArg).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build commands query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commands: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []*api.CommandRecord
+	for rows.Next() {
+		var rec api.CommandRecord
+		var params, state, errMsg sql.NullString
+		if err := rows.Scan(&rec.ID, &rec.DeviceID, &rec.ActuatorID, &rec.Tag, &rec.Origin.Source, &rec.Origin.Name,
+			&rec.Command.Action, &params, &state, &errMsg, &rec.IssuedAt, &rec.LatencyMS); err != nil {
+			return nil, fmt.Errorf("failed to scan command: %w", err)
+		}
+		if params.Valid {
+			if err := json.Unmarshal([]byte(params.String), &rec.Command.Parameters); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal command parameters: %w", err)
+			}
+		}
+		if state.Valid {
+			rec.State = &api.ActuatorState{}
+			if err := json.Unmarshal([]byte(state.String), rec.State); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal actuator state: %w", err)
+			}
+		}
+		rec.Error = errMsg.String
+		commands = append(commands, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate commands: %w", err)
+	}
+	slices.Reverse(commands)
+	return commands, nil
+}
+
+// Reading labels
+
+// CreateReadingLabel stores a label, setting its ID and CreatedAt
+func (s *SQLite) CreateReadingLabel(ctx context.Context, label *api.ReadingLabel) error {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Str("key", label.Key).Str("value", label.Value).Msg("creating reading label")
+	now := s.now()
+	query := `
+		INSERT INTO reading_labels (key, value, device_id, sensor_id, start_time, end_time, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	result, err := s.db.ExecContext(ctx, query, label.Key, label.Value, label.DeviceID, label.SensorID,
+		sqliteTime(label.Start), sqliteTime(label.End), label.Note, sqliteTime(now))
+	if err != nil {
+		return fmt.Errorf("failed to create reading label: %w", err)
+	}
+	if label.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get reading label id: %w", err)
+	}
+	label.CreatedAt = now.UTC()
+	return nil
+}
+
+// ListReadingLabels returns labels matching filters, ordered by start time
+func (s *SQLite) ListReadingLabels(ctx context.Context, filters ReadingLabelFilters) ([]*api.ReadingLabel, error) {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Str("key", filters.Key).Str("device_id", filters.DeviceID).Str("sensor_id", filters.SensorID).Msg("listing reading labels")
+	query, args, err := readingLabelsQuery(filters, sqliteArg).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build reading labels query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reading labels: %w", err)
+	}
+	defer rows.Close()
+
+	var labels []*api.ReadingLabel
+	for rows.Next() {
+		var l api.ReadingLabel
+		if err := rows.Scan(&l.ID, &l.Key, &l.Value, &l.DeviceID, &l.SensorID, &l.Start, &l.End, &l.Note, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reading label: %w", err)
+		}
+		labels = append(labels, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reading labels: %w", err)
+	}
+	return labels, nil
+}
+
+// DeleteReadingLabel removes a label; the readings it covered are unaffected
+func (s *SQLite) DeleteReadingLabel(ctx context.Context, id int64) error {
+	ll := s.logCtx(ctx, "labels")
+	ll.Debug().Int64("id", id).Msg("deleting reading label")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM reading_labels WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete reading label: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: reading label %d", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Groups
+
+// CreateGroup creates a group with its devices in a transaction
+func (s *SQLite) CreateGroup(ctx context.Context, g *api.Group) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", g.ID).Msg("creating group")
+	metadata, err := json.Marshal(g.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.timestamp()
+	query := `
+		INSERT INTO device_groups (id, name, description, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+	if _, err := tx.ExecContext(ctx, query, g.ID, g.Name, g.Description, string(metadata), now); err != nil {
+		if isConflict(err) {
+			return fmt.Errorf("%w: group with id %s", ErrAlreadyExists, g.ID)
+		}
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+	if err := setGroupDevices(ctx, tx, g, isForeignKeyViolation); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetGroup retrieves a group with its devices
+func (s *SQLite) GetGroup(ctx context.Context, id string) (*api.Group, error) {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", id).Msg("getting group")
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, description, metadata FROM device_groups WHERE id = $1`, id)
+	g, err := scanGroup(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if err := loadGroupDevices(ctx, s.db, []*api.Group{g}); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// UpdateGroup replaces a group's name, description, metadata and devices
+func (s *SQLite) UpdateGroup(ctx context.Context, g *api.Group) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", g.ID).Msg("updating group")
+	metadata, err := json.Marshal(g.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE device_groups
+		SET name = $2, description = $3, metadata = $4, updated_at = $5
+		WHERE id = $1
+	`
+	result, err := tx.ExecContext(ctx, query, g.ID, g.Name, g.Description, string(metadata), s.timestamp())
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: group %s", ErrNotFound, g.ID)
+	}
+	if err := setGroupDevices(ctx, tx, g, isForeignKeyViolation); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group; its devices are unaffected
+func (s *SQLite) DeleteGroup(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", id).Msg("deleting group")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM device_groups WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: group %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// ListGroups retrieves all groups with their devices, ordered by name
+func (s *SQLite) ListGroups(ctx context.Context) ([]*api.Group, error) {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Msg("listing groups")
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, description, metadata FROM device_groups ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+	var groups []*api.Group
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate groups: %w", err)
+	}
+	// The single connection must be free before loading the members
+	if err := loadGroupDevices(ctx, s.db, groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// AddGroupDevice adds a device to a group; adding a member again does nothing
+func (s *SQLite) AddGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", groupID).Str("device_id", deviceID).Msg("adding group device")
+	query := `INSERT INTO device_group_members (group_id, device_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, query, groupID, deviceID); err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: group %s or device %s", ErrNotFound, groupID, deviceID)
+		}
+		return fmt.Errorf("failed to add group device: %w", err)
+	}
+	return nil
+}
+
+// RemoveGroupDevice removes a device from a group
+func (s *SQLite) RemoveGroupDevice(ctx context.Context, groupID, deviceID string) error {
+	ll := s.logCtx(ctx, "group")
+	ll.Debug().Str("group_id", groupID).Str("device_id", deviceID).Msg("removing group device")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM device_group_members WHERE group_id = $1 AND device_id = $2`, groupID, deviceID)
+	if err != nil {
+		return fmt.Errorf("failed to remove group device: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: device %s in group %s", ErrNotFound, deviceID, groupID)
+	}
+	return nil
+}
+
+// Leases
+
+// AcquireLease takes the named lease for holder until ttl from now, or renews it if
+// holder already has it. It returns ErrLeaseHeld while another holder's lease is
+// unexpired. Every process sharing the database file shares the host's clock.
+func (s *SQLite) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (*api.Lease, error) {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Str("name", name).Str("holder", holder).Msg("acquiring lease")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.now()
+	query := `
+		INSERT INTO leases (name, holder, acquired_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET
+			holder = excluded.holder,
+			acquired_at = CASE WHEN leases.holder = excluded.holder THEN leases.acquired_at ELSE excluded.acquired_at END,
+			expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < excluded.acquired_at
+	`
+	result, err := tx.ExecContext(ctx, query, name, holder, sqliteTime(now), sqliteTime(now.Add(ttl)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	if rows, err := rowsAffected(result); err != nil {
+		return nil, err
+	} else if rows == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLeaseHeld, name)
+	}
+
+	var lease api.Lease
+	err = tx.QueryRowContext(ctx, `SELECT name, holder, acquired_at, expires_at FROM leases WHERE name = $1`, name).
+		Scan(&lease.Name, &lease.Holder, &lease.AcquiredAt, &lease.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &lease, nil
+}
+
+// ReleaseLease gives up holder's lease so another worker can take over at once
+func (s *SQLite) ReleaseLease(ctx context.Context, name, holder string) error {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Str("name", name).Str("holder", holder).Msg("releasing lease")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return expectRow(result, "lease %s held by %s", name, holder)
+}
+
+// ListLeases returns every lease, including expired ones not yet taken over, by name
+func (s *SQLite) ListLeases(ctx context.Context) ([]*api.Lease, error) {
+	ll := s.logCtx(ctx, "leases")
+	ll.Debug().Msg("listing leases")
+	rows, err := s.db.QueryContext(ctx, `SELECT name, holder, acquired_at, expires_at FROM leases ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list leases: %w", err)
+	}
+	defer rows.Close()
+
+	var leases []*api.Lease
+	for rows.Next() {
+		var lease api.Lease
+		if err := rows.Scan(&lease.Name, &lease.Holder, &lease.AcquiredAt, &lease.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lease: %w", err)
+		}
+		leases = append(leases, &lease)
+	}
+	return leases, rows.Err()
+}
+
+// Credentials
+
+// SetCredential stores the device's named credential, replacing the secret of an existing
+// one while keeping its ID. It fills in the credential's ID and timestamps.
+func (s *SQLite) SetCredential(ctx context.Context, cred *api.SealedCredential) error {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("device_id", cred.DeviceID).Str("name", cred.Name).Msg("setting credential")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO credentials (id, device_id, name, key_id, wrapped_key, ciphertext, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (device_id, name) DO UPDATE SET
+			key_id = excluded.key_id,
+			wrapped_key = excluded.wrapped_key,
+			ciphertext = excluded.ciphertext,
+			updated_at = excluded.updated_at
+	`
+	_, err = tx.ExecContext(ctx, query, uuid.NewString(), cred.DeviceID, cred.Name, cred.KeyID, cred.WrappedKey, cred.Ciphertext, s.timestamp())
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: device %s", ErrNotFound, cred.DeviceID)
+		}
+		return fmt.Errorf("failed to set credential: %w", err)
+	}
+	err = tx.QueryRowContext(ctx, `SELECT id, created_at, updated_at FROM credentials WHERE device_id = $1 AND name = $2`, cred.DeviceID, cred.Name).
+		Scan(&cred.ID, &cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set credential: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetCredential retrieves a credential, with its sealed secret, by ID
+func (s *SQLite) GetCredential(ctx context.Context, id string) (*api.SealedCredential, error) {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("id", id).Msg("getting credential")
+	query := `
+		SELECT id, device_id, name, key_id, wrapped_key, ciphertext, created_at, updated_at
+		FROM credentials WHERE id = $1
+	`
+	var cred api.SealedCredential
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&cred.ID, &cred.DeviceID, &cred.Name, &cred.KeyID,
+		&cred.WrappedKey, &cred.Ciphertext, &cred.CreatedAt, &cred.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: credential %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to get credential: %w", err)
+	}
+	return &cred, nil
+}
+
+// ListCredentials describes the device's credentials, ordered by name, without their
+// secrets
+func (s *SQLite) ListCredentials(ctx context.Context, deviceID string) ([]*api.Credential, error) {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("device_id", deviceID).Msg("listing credentials")
+	query := `
+		SELECT id, device_id, name, key_id, created_at, updated_at
+		FROM credentials WHERE device_id = $1 ORDER BY name
+	`
+	rows, err := s.db.QueryContext(ctx, query, deviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credentials: %w", err)
+	}
+	defer rows.Close()
+
+	var creds []*api.Credential
+	for rows.Next() {
+		var cred api.Credential
+		if err := rows.Scan(&cred.ID, &cred.DeviceID, &cred.Name, &cred.KeyID, &cred.CreatedAt, &cred.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan credential: %w", err)
+		}
+		creds = append(creds, &cred)
+	}
+	return creds, rows.Err()
+}
+
+// DeleteCredential deletes a credential and its secret
+func (s *SQLite) DeleteCredential(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "credentials")
+	ll.Debug().Str("id", id).Msg("deleting credential")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM credentials WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return expectRow(result, "credential %s", id)
+}
+
+// Assets
+
+// CreateAsset records an attached image whose blobs have been stored under its ID
+func (s *SQLite) CreateAsset(ctx context.Context, asset *api.Asset) error {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", asset.ID).Str("device_id", asset.DeviceID).Str("subsystem", asset.Subsystem).Msg("creating asset")
+	now := s.now()
+	query := `
+		INSERT INTO assets (` + assetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	_, err := s.db.ExecContext(ctx, query, asset.ID, nullString(asset.DeviceID), nullString(asset.Subsystem),
+		asset.Kind, asset.Filename, asset.ContentType, asset.Size, asset.Width, asset.Height, asset.ThumbnailType, sqliteTime(now))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: device %s", ErrNotFound, asset.DeviceID)
+		}
+		return fmt.Errorf("failed to create asset: %w", err)
+	}
+	asset.CreatedAt = now.UTC()
+	return nil
+}
+
+// GetAsset retrieves an asset by ID
+func (s *SQLite) GetAsset(ctx context.Context, id string) (*api.Asset, error) {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", id).Msg("getting asset")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+assetColumns+` FROM assets WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+	assets, err := scanAssets(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("%w: asset %s", ErrNotFound, id)
+	}
+	return assets[0], nil
+}
+
+// ListAssets retrieves the assets matching filters, oldest first
+func (s *SQLite) ListAssets(ctx context.Context, filters AssetFilters) ([]*api.Asset, error) {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("device_id", filters.DeviceID).Str("subsystem", filters.Subsystem).Msg("listing assets")
+	query, args, err := assetsQuery(filters).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build assets query: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assets: %w", err)
+	}
+	return scanAssets(rows)
+}
+
+// DeleteAsset deletes an asset's record; its blobs are the caller's to remove
+func (s *SQLite) DeleteAsset(ctx context.Context, id string) error {
+	ll := s.logCtx(ctx, "assets")
+	ll.Debug().Str("id", id).Msg("deleting asset")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM assets WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete asset: %w", err)
+	}
+	return expectRow(result, "asset %s", id)
+}
+
+// Change feed
+
+// recordChanges appends changes to the outbox within tx. Writes to a SQLite database are
+// serialized, so sequence numbers are always assigned in commit order.
+func (s *SQLite) recordChanges(ctx context.Context, tx *sql.Tx, changes ...*api.ChangeEvent) error {
+	now := s.timestamp()
+	query := `INSERT INTO change_events (type, entity_id, data, timestamp) VALUES ($1, $2, $3, $4)`
+	for _, change := range changes {
+		var data sql.NullString
+		if change.Data != nil {
+			data = sql.NullString{String: string(change.Data), Valid: true}
+		}
+		if _, err := tx.ExecContext(ctx, query, change.Type, change.EntityID, data, now); err != nil {
+			return fmt.Errorf("failed to record changes: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordChange appends a change to the feed for entities, such as alerts, that are not
+// otherwise stored. Seq and Timestamp are assigned by the store.
+func (s *SQLite) RecordChange(ctx context.Context, change *api.ChangeEvent) error {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Str("type", string(change.Type)).Str("entity_id", change.EntityID).Msg("recording change")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.recordChanges(ctx, tx, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListChanges returns up to limit changes with a Seq greater than after, oldest first
+func (s *SQLite) ListChanges(ctx context.Context, after int64, limit int) ([]*api.ChangeEvent, error) {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Int64("after", after).Int("limit", limit).Msg("listing changes")
+	query := `
+		SELECT seq, type, entity_id, data, timestamp
+		FROM change_events
+		WHERE seq > $1
+		ORDER BY seq
+		LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []*api.ChangeEvent
+	for rows.Next() {
+		var change api.ChangeEvent
+		var data sql.NullString
+		if err := rows.Scan(&change.Seq, &change.Type, &change.EntityID, &data, &change.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan change: %w", err)
+		}
+		if data.Valid {
+			change.Data = json.RawMessage(data.String)
+		}
+		changes = append(changes, &change)
+	}
+	return changes, rows.Err()
+}
+
+// LatestChangeSeq returns the Seq of the most recent change, or 0 if the feed is empty
+func (s *SQLite) LatestChangeSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM change_events`).Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to get latest change: %w", err)
+	}
+	return seq, nil
+}
+
+// DeleteOldChanges prunes changes recorded before the cutoff
+func (s *SQLite) DeleteOldChanges(ctx context.Context, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "changes")
+	ll.Debug().Time("before", before).Msg("deleting old changes")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM change_events WHERE timestamp < $1`, sqliteTime(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old changes: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetChangeCursor returns the Seq of the last change a named consumer handled, or 0 if
+// it has handled none
+func (s *SQLite) GetChangeCursor(ctx context.Context, consumer string) (int64, error) {
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT seq FROM change_cursors WHERE consumer = $1`, consumer).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get change cursor: %w", err)
+	}
+	return seq, nil
+}
+
+// SetChangeCursor records that a named consumer has handled every change up to seq
+func (s *SQLite) SetChangeCursor(ctx context.Context, consumer string, seq int64) error {
+	query := `
+		INSERT INTO change_cursors (consumer, seq, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (consumer) DO UPDATE SET seq = excluded.seq, updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, consumer, seq, s.timestamp()); err != nil {
+		return fmt.Errorf("failed to set change cursor: %w", err)
+	}
+	return nil
+}
+
+// Maintenance mode
+
+// GetMaintenanceMode returns the current maintenance mode, which is off until first set
+func (s *SQLite) GetMaintenanceMode(ctx context.Context) (*api.MaintenanceMode, error) {
+	var mode api.MaintenanceMode
+	err := s.db.QueryRowContext(ctx, `SELECT read_only, message, updated_at FROM maintenance_mode WHERE id = 1`).
+		Scan(&mode.ReadOnly, &mode.Message, &mode.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &api.MaintenanceMode{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get maintenance mode: %w", err)
+	}
+	return &mode, nil
+}
+
+// SetMaintenanceMode replaces the maintenance mode, setting mode.UpdatedAt
+func (s *SQLite) SetMaintenanceMode(ctx context.Context, mode *api.MaintenanceMode) error {
+	ll := s.logCtx(ctx, "maintenance")
+	ll.Info().Bool("read_only", mode.ReadOnly).Str("message", mode.Message).Msg("setting maintenance mode")
+	now := s.now()
+	query := `
+		INSERT INTO maintenance_mode (id, read_only, message, updated_at)
+		VALUES (1, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET
+			read_only = excluded.read_only,
+			message = excluded.message,
+			updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, mode.ReadOnly, mode.Message, sqliteTime(now)); err != nil {
+		return fmt.Errorf("failed to set maintenance mode: %w", err)
+	}
+	mode.UpdatedAt = now.UTC()
+	return nil
+}
+
+// Feature flags
+
+const featureFlagColumns = `name, description, percent, updated_at`
+
+// SetFeatureFlag creates the flag or replaces an existing one, setting flag.UpdatedAt
+func (s *SQLite) SetFeatureFlag(ctx context.Context, flag *api.FeatureFlag) error {
+	ll := s.logCtx(ctx, "feature")
+	ll.Info().Str("flag", flag.Name).Int("percent", flag.Percent).Msg("setting feature flag")
+	now := s.now()
+	query := `
+		INSERT INTO feature_flags (` + featureFlagColumns + `)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET
+			description = excluded.description,
+			percent = excluded.percent,
+			updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, flag.Name, flag.Description, flag.Percent, sqliteTime(now)); err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	flag.UpdatedAt = now.UTC()
+	return nil
+}
+
+// GetFeatureFlag retrieves a flag by name
+func (s *SQLite) GetFeatureFlag(ctx context.Context, name string) (*api.FeatureFlag, error) {
+	var f api.FeatureFlag
+	err := s.db.QueryRowContext(ctx, `SELECT `+featureFlagColumns+` FROM feature_flags WHERE name = $1`, name).
+		Scan(&f.Name, &f.Description, &f.Percent, &f.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: feature flag %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to get feature flag: %w", err)
+	}
+	return &f, nil
+}
+
+// DeleteFeatureFlag deletes a flag, turning its feature off for everyone
+func (s *SQLite) DeleteFeatureFlag(ctx context.Context, name string) error {
+	ll := s.logCtx(ctx, "feature")
+	ll.Info().Str("flag", name).Msg("deleting feature flag")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+	return expectRow(result, "feature flag %s", name)
+}
+
+// ListFeatureFlags retrieves all flags, ordered by name
+func (s *SQLite) ListFeatureFlags(ctx context.Context) ([]*api.FeatureFlag, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+featureFlagColumns+` FROM feature_flags ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []*api.FeatureFlag
+	for rows.Next() {
+		var f api.FeatureFlag
+		if err := rows.Scan(&f.Name, &f.Description, &f.Percent, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		flags = append(flags, &f)
+	}
+	return flags, rows.Err()
+}
+
+// Tag templates
+
+// SetTagTemplate creates the template of its kind or replaces an existing one, setting
+// template.UpdatedAt. Entities created from then on are tagged by it; existing tags stay.
+func (s *SQLite) SetTagTemplate(ctx context.Context, template *api.TagTemplate) error {
+	ll := s.logCtx(ctx, "tags")
+	ll.Info().Str("kind", string(template.Kind)).Str("template", template.Template).Msg("setting tag template")
+	now := s.now()
+	query := `
+		INSERT INTO tag_templates (kind, template, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind) DO UPDATE SET
+			template = excluded.template,
+			updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, template.Kind, template.Template, sqliteTime(now)); err != nil {
+		return fmt.Errorf("failed to set tag template: %w", err)
+	}
+	template.UpdatedAt = now.UTC()
+	return nil
+}
+
+// DeleteTagTemplate deletes the template of a kind, restoring the built-in default tags
+func (s *SQLite) DeleteTagTemplate(ctx context.Context, kind api.TagKind) error {
+	ll := s.logCtx(ctx, "tags")
+	ll.Info().Str("kind", string(kind)).Msg("deleting tag template")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM tag_templates WHERE kind = $1`, kind)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag template: %w", err)
+	}
+	return expectRow(result, "tag template %s", kind)
+}
+
+// ListTagTemplates retrieves all tag templates, ordered by kind
+func (s *SQLite) ListTagTemplates(ctx context.Context) ([]*api.TagTemplate, error) {
+	return listTagTemplates(ctx, s.db)
+}
+
+// Retention policies
+
+// SetRetentionPolicy creates the policy for its sensor type or replaces an existing one,
+// setting policy.UpdatedAt. Retention is stored in whole seconds.
+func (s *SQLite) SetRetentionPolicy(ctx context.Context, policy *api.RetentionPolicy) error {
+	ll := s.logCtx(ctx, "retention")
+	ll.Info().Str("sensor_type", string(policy.SensorType)).Dur("retention", policy.Retention).Msg("setting retention policy")
+	now := s.now()
+	query := `
+		INSERT INTO retention_policies (sensor_type, retention_seconds, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sensor_type) DO UPDATE SET
+			retention_seconds = excluded.retention_seconds,
+			updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, policy.SensorType, int64(policy.Retention/time.Second), sqliteTime(now)); err != nil {
+		return fmt.Errorf("failed to set retention policy: %w", err)
+	}
+	policy.UpdatedAt = now.UTC()
+	return nil
+}
+
+// DeleteRetentionPolicy deletes the policy of a sensor type, keeping its readings forever
+func (s *SQLite) DeleteRetentionPolicy(ctx context.Context, sensorType api.SensorType) error {
+	ll := s.logCtx(ctx, "retention")
+	ll.Info().Str("sensor_type", string(sensorType)).Msg("deleting retention policy")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM retention_policies WHERE sensor_type = $1`, sensorType)
+	if err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	return expectRow(result, "retention policy %s", sensorType)
+}
+
+// ListRetentionPolicies retrieves all retention policies, ordered by sensor type
+func (s *SQLite) ListRetentionPolicies(ctx context.Context) ([]*api.RetentionPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sensor_type, retention_seconds, updated_at FROM retention_policies ORDER BY sensor_type
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+	return scanRetentionPolicies(rows)
+}
+
+// DeleteSensorReadingsByType removes readings of sensors of the given type taken before
+// the given time and returns how many were deleted
+func (s *SQLite) DeleteSensorReadingsByType(ctx context.Context, sensorType api.SensorType, before time.Time) (int64, error) {
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().Str("sensor_type", string(sensorType)).Time("before", before).Msg("deleting old sensor readings")
+	return s.deleteReadings(ctx, `timestamp < $2 AND (device_id, sensor_id) IN (
+		SELECT device_id, id FROM sensors WHERE sensor_type = $1)`, sensorType, sqliteTime(before))
+}
+
+// Storage quotas
+
+// SetStorageQuota creates the quota of its sensor or replaces an existing one, setting
+// quota.UpdatedAt. Downsampling is stored in whole seconds.
+func (s *SQLite) SetStorageQuota(ctx context.Context, quota *api.StorageQuota) error {
+	ll := s.logCtx(ctx, "storage")
+	ll.Info().
+		Str("device_id", quota.DeviceID).
+		Str("sensor_id", quota.SensorID).
+		Int64("max_readings", quota.MaxReadings).
+		Dur("downsample", quota.Downsample).
+		Msg("setting storage quota")
+	now := s.now()
+	query := `
+		INSERT INTO storage_quotas (device_id, sensor_id, max_readings, downsample_seconds, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+			max_readings = excluded.max_readings,
+			downsample_seconds = excluded.downsample_seconds,
+			updated_at = excluded.updated_at
+	`
+	_, err := s.db.ExecContext(ctx, query, quota.DeviceID, quota.SensorID, quota.MaxReadings,
+		int64(quota.Downsample/time.Second), sqliteTime(now))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, quota.DeviceID, quota.SensorID)
+		}
+		return fmt.Errorf("failed to set storage quota: %w", err)
+	}
+	quota.UpdatedAt = now.UTC()
+	return nil
+}
+
+// DeleteStorageQuota deletes the quota of a sensor, keeping all of its readings
+func (s *SQLite) DeleteStorageQuota(ctx context.Context, deviceID, sensorID string) error {
+	ll := s.logCtx(ctx, "storage")
+	ll.Info().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting storage quota")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM storage_quotas WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete storage quota: %w", err)
+	}
+	return expectRow(result, "storage quota %s/%s", deviceID, sensorID)
+}
+
+// ListStorageQuotas retrieves all storage quotas, ordered by device and sensor
+func (s *SQLite) ListStorageQuotas(ctx context.Context) ([]*api.StorageQuota, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, sensor_id, max_readings, downsample_seconds, updated_at
+		FROM storage_quotas ORDER BY device_id, sensor_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage quotas: %w", err)
+	}
+	defer rows.Close()
+	return scanStorageQuotas(rows)
+}
+
+// TableUsage reports the rows and bytes of every table. Bytes, indexes included, come
+// from the dbstat table and are reported as zero where SQLite was built without it.
+func (s *SQLite) TableUsage(ctx context.Context) ([]api.TableUsage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	var tables []api.TableUsage
+	for rows.Next() {
+		var t api.TableUsage
+		if err := rows.Scan(&t.Table); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+
+	bytes := map[string]int64{}
+	sizes, err := s.db.QueryContext(ctx, `
+		SELECT m.tbl_name, SUM(d.pgsize) FROM dbstat d JOIN sqlite_master m ON m.name = d.name
+		GROUP BY m.tbl_name
+	`)
+	if err == nil {
+		for sizes.Next() {
+			var table string
+			var n int64
+			if err := sizes.Scan(&table, &n); err != nil {
+				sizes.Close()
+				return nil, fmt.Errorf("failed to scan table size: %w", err)
+			}
+			bytes[table] = n
+		}
+		sizes.Close()
+	}
+
+	for i := range tables {
+		// Table names come from sqlite_master, so quoting them is enough
+		err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+tables[i].Table+`"`).Scan(&tables[i].Rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", tables[i].Table, err)
+		}
+		tables[i].Bytes = bytes[tables[i].Table]
+	}
+	return tables, nil
+}
+
+// SensorReadingUsage counts the readings kept of each sensor with any, and how many of
+// them were taken at or after since, ordered by device and sensor
+func (s *SQLite) SensorReadingUsage(ctx context.Context, since time.Time) ([]api.SensorUsage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, sensor_id, COUNT(*), SUM(CASE WHEN timestamp >= $1 THEN 1 ELSE 0 END)
+		FROM sensor_readings
+		GROUP BY device_id, sensor_id
+		ORDER BY device_id, sensor_id
+	`, sqliteTime(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor reading usage: %w", err)
+	}
+	defer rows.Close()
+	return scanSensorUsage(rows)
+}
+
+// ThinSensorReadings downsamples a sensor's readings taken before the given time to the
+// last one of each interval, counted in whole seconds from the Unix epoch, and returns
+// how many were deleted. The sensor's latest reading is always kept.
+func (s *SQLite) ThinSensorReadings(ctx context.Context, deviceID, sensorID string, before time.Time, interval time.Duration) (int64, error) {
+	if interval < time.Second {
+		return 0, fmt.Errorf("failed to thin sensor readings: interval %s is too short", interval)
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().
+		Str("device_id", deviceID).
+		Str("sensor_id", sensorID).
+		Time("before", before).
+		Dur("interval", interval).
+		Msg("thinning sensor readings")
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM sensor_readings WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY CAST(strftime('%s', timestamp) AS INTEGER) / $4
+					ORDER BY timestamp DESC, id DESC
+				) AS n
+				FROM sensor_readings
+				WHERE device_id = $1 AND sensor_id = $2 AND timestamp < $3
+			)
+			WHERE n > 1
+		)
+	`, deviceID, sensorID, sqliteTime(before), int64(interval/time.Second))
+	if err != nil {
+		return 0, fmt.Errorf("failed to thin sensor readings: %w", err)
+	}
+	return rowsAffected(result)
+}
+
+// Slow queries
+
+// ListSlowQueries returns nothing; SQLite doesn't capture slow queries
+func (s *SQLite) ListSlowQueries(ctx context.Context, limit int) ([]*api.SlowQuery, error) {
+	return nil, nil
+}
+
+// ClearSlowQueries is a no-op; SQLite doesn't capture slow queries
+func (s *SQLite) ClearSlowQueries(ctx context.Context) error {
+	return nil
+}
+
+// Audit log
+
+// snapshot returns an audited entity as stored within tx, or nil if it does not exist
+func (s *SQLite) snapshot(ctx context.Context, tx *sql.Tx, typ api.AuditEntityType, keys []string) (json.RawMessage, error) {
+	var entity any
+	var err error
+	switch typ {
+	case api.AuditEntityDevice:
+		entity, err = scanSQLiteDevice(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteDeviceColumns+` FROM devices WHERE id = $1`, keys[0]))
+	case api.AuditEntitySensor:
+		entity, err = scanSQLiteSensor(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteSensorColumns+` FROM sensors WHERE device_id = $1 AND id = $2`, keys[0], keys[1]))
+	case api.AuditEntityActuator:
+		entity, err = scanSQLiteActuator(tx.QueryRowContext(ctx,
+			`SELECT `+sqliteActuatorColumns+` FROM actuators WHERE device_id = $1 AND id = $2`, keys[0], keys[1]))
+	default:
+		return nil, fmt.Errorf("unknown audit entity type %q", typ)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot %s: %w", typ, err)
+	}
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", typ, err)
+	}
+	return data, nil
+}
+
+// audited runs write within tx and records its effect on the entity identified by keys
+// in the audit log
+func (s *SQLite) audited(ctx context.Context, tx *sql.Tx, typ api.AuditEntityType, keys []string, write func() error) error {
+	before, err := s.snapshot(ctx, tx, typ, keys)
+	if err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	after, err := s.snapshot(ctx, tx, typ, keys)
+	if err != nil {
+		return err
+	}
+	return s.recordAudit(ctx, tx, newAuditEntry(ctx, typ, keys, before, after))
+}
+
+// recordAudit appends entry to the audit log within tx
+func (s *SQLite) recordAudit(ctx context.Context, tx *sql.Tx, entry *api.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (entity_type, entity_id, action, actor, source, before, after, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	if _, err := tx.ExecContext(ctx, query, entry.EntityType, entry.EntityID, entry.Action, entry.Actor, entry.Source,
+		nullJSONString(entry.Before), nullJSONString(entry.After), s.timestamp()); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// auditedTx runs write in a transaction of its own, recording its effect on the entity
+// identified by keys in the audit log
+func (s *SQLite) auditedTx(ctx context.Context, typ api.AuditEntityType, keys []string, write func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.audited(ctx, tx, typ, keys, func() error { return write(tx) }); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// nullJSONString is data as a TEXT column value, NULL if there is none
+func nullJSONString(data json.RawMessage) sql.NullString {
+	if data == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+// ListAuditEntries returns audit entries matching filters, oldest first
+func (s *SQLite) ListAuditEntries(ctx context.Context, filters AuditFilters) ([]*api.AuditEntry, error) {
+	ll := s.logCtx(ctx, "audit")
+	ll.Debug().Str("entity_type", string(filters.EntityType)).Str("entity_id", filters.EntityID).Msg("listing audit entries")
+
+	// Select the most recent entries, reversed into chronological order below
+	query, args, err := auditQuery(filters, sqliteArg).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit log query: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*api.AuditEntry
+	for rows.Next() {
+		var entry api.AuditEntry
+		var before, after sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.EntityType, &entry.EntityID, &entry.Action, &entry.Actor, &entry.Source,
+			&before, &after, &entry.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, &entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate audit log: %w", err)
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// Retagging
+
+// RenameTag renames from to to on devices, sensors and actuators, along with the tags
+// beneath it, in one transaction. Tag aliases pointing at a renamed tag follow it. It
+// returns how many entities changed, or ErrNotFound if none carry the tag.
+func (s *SQLite) RenameTag(ctx context.Context, from, to string) (int64, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Str("from", from).Str("to", to).Msg("renaming tag")
+	return s.retag(ctx, renameRewrite(from, to), from)
+}
+
+// MergeTags replaces each of from with into on devices, sensors and actuators in one
+// transaction; entities carrying several of them keep into once. Tag aliases pointing at
+// a merged tag follow it. It returns how many entities changed, or ErrNotFound if none
+// carry any of from.
+func (s *SQLite) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Strs("from", from).Str("into", into).Msg("merging tags")
+	return s.retag(ctx, mergeRewrite(from, into), strings.Join(from, ", "))
+}
+
+// ListTagTree returns the tag hierarchy at and beneath prefix, or the whole hierarchy if
+// prefix is empty, with how many devices, sensors and actuators are tagged at or beneath
+// each level. Tags are read in one transaction, so the counts are consistent.
+func (s *SQLite) ListTagTree(ctx context.Context, prefix string) (*api.TagNode, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Str("prefix", prefix).Msg("listing tag tree")
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entities := make(map[api.AuditEntityType][]taggedEntity, len(retagTables))
+	for _, t := range retagTables {
+		if entities[t.typ], err = s.taggedEntities(ctx, tx, t.table, t.keys); err != nil {
+			return nil, err
+		}
+	}
+	return tagTree(prefix, entities), nil
+}
+
+// retag applies rewrite to every device, sensor and actuator and to tag alias targets;
+// what names the tags rewritten, for errors
+func (s *SQLite) retag(ctx context.Context, rewrite tagRewrite, what string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := s.timestamp()
+	var n int64
+	for _, t := range retagTables {
+		entities, err := s.taggedEntities(ctx, tx, t.table, t.keys)
+		if err != nil {
+			return 0, err
+		}
+		changed, err := retag(t.typ, entities, rewrite)
+		if err != nil {
+			return 0, err
+		}
+		query := fmt.Sprintf(`UPDATE %s SET tags = $%d, updated_at = $%d, version = version + 1 WHERE %s`,
+			t.table, len(t.keys)+1, len(t.keys)+2, keyCondition(t.keys))
+		for _, e := range changed {
+			tags, err := json.Marshal(e.tags)
+			if err != nil {
+				return 0, fmt.Errorf("failed to marshal tags: %w", err)
+			}
+			args := make([]any, 0, len(e.keys)+2)
+			for _, key := range e.keys {
+				args = append(args, key)
+			}
+			args = append(args, string(tags), now)
+			err = s.audited(ctx, tx, t.typ, e.keys, func() error {
+				if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+					return fmt.Errorf("failed to retag %s %s: %w", t.typ, strings.Join(e.keys, "/"), err)
+				}
+				return nil
+			})
+			if err != nil {
+				return 0, err
+			}
+			if t.typ != api.AuditEntityDevice {
+				continue
+			}
+			dev, err := s.snapshot(ctx, tx, t.typ, e.keys)
+			if err != nil {
+				return 0, err
+			}
+			change, err := newChange(api.ChangeDeviceUpdated, e.keys[0], dev)
+			if err != nil {
+				return 0, err
+			}
+			if err := s.recordChanges(ctx, tx, change); err != nil {
+				return 0, err
+			}
+		}
+		n += int64(len(changed))
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("%w: tag %s", ErrNotFound, what)
+	}
+
+	aliases, err := s.aliasTargets(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	for alias, target := range aliases {
+		if target, ok := rewrite(target); ok {
+			query := `UPDATE tag_aliases SET target = $2, updated_at = $3 WHERE alias = $1`
+			if _, err := tx.ExecContext(ctx, query, alias, target, now); err != nil {
+				return 0, fmt.Errorf("failed to retarget tag alias %s: %w", alias, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return n, nil
+}
+
+// taggedEntities returns the keys and tags of every row of table within tx
+func (s *SQLite) taggedEntities(ctx context.Context, tx *sql.Tx, table string, keys []string) ([]taggedEntity, error) {
+	cols := strings.Join(keys, ", ")
+	rows, err := tx.QueryContext(ctx, `SELECT `+cols+`, tags FROM `+table+` ORDER BY `+cols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s tags: %w", table, err)
+	}
+	defer rows.Close()
+
+	var entities []taggedEntity
+	for rows.Next() {
+		e := taggedEntity{keys: make([]string, len(keys))}
+		var tags string
+		dest := make([]any, 0, len(keys)+1)
+		for i := range e.keys {
+			dest = append(dest, &e.keys[i])
+		}
+		if err := rows.Scan(append(dest, &tags)...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s tags: %w", table, err)
+		}
+		if err := json.Unmarshal([]byte(tags), &e.tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+		entities = append(entities, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate %s tags: %w", table, err)
+	}
+	return entities, nil
+}
+
+// aliasTargets returns the target of every tag alias within tx, by alias
+func (s *SQLite) aliasTargets(ctx context.Context, tx *sql.Tx) (map[string]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT alias, target FROM tag_aliases`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag aliases: %w", err)
+	}
+	defer rows.Close()
+
+	targets := map[string]string{}
+	for rows.Next() {
+		var alias, target string
+		if err := rows.Scan(&alias, &target); err != nil {
+			return nil, fmt.Errorf("failed to scan tag alias: %w", err)
+		}
+		targets[alias] = target
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate tag aliases: %w", err)
+	}
+	return targets, nil
+}
+
+// Rule traces
+
+// RecordRuleTrace stores an evaluation of a rule, setting its ID, and keeps only the
+// rule's newest keep traces
+func (s *SQLite) RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error {
+	ll := s.logCtx(ctx, "rule_traces")
+	ll.Debug().Str("kind", trace.Kind).Str("name", trace.Name).Str("outcome", string(trace.Outcome)).Msg("recording rule trace")
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule trace: %w", err)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO rule_traces (kind, name, timestamp, outcome, trace)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	result, err := tx.ExecContext(ctx, query, trace.Kind, trace.Name, sqliteTime(trace.Timestamp), trace.Outcome, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to record rule trace: %w", err)
+	}
+	if trace.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to get rule trace id: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, pruneRuleTraces, trace.Kind, trace.Name, keep); err != nil {
+		return fmt.Errorf("failed to prune rule traces: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListRuleTraces returns a rule's latest limit traces, newest first
+func (s *SQLite) ListRuleTraces(ctx context.Context, kind, name string, limit int) ([]*api.RuleTrace, error) {
+	ll := s.logCtx(ctx, "rule_traces")
+	ll.Debug().Str("kind", kind).Str("name", name).Msg("listing rule traces")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trace FROM rule_traces
+		WHERE kind = $1 AND name = $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, kind, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule traces: %w", err)
+	}
+	return scanRuleTraces(rows)
+}
+
+// Pump runtimes
+
+// GetPumpRuntimes returns the saved runtime of each pump of a pump rotation, by tag, and
+// the tag of the pump saved as running
+func (s *SQLite) GetPumpRuntimes(ctx context.Context, rotation string) (map[string]time.Duration, string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT pump_tag, runtime_seconds, active FROM pump_runtimes WHERE rotation = $1`, rotation)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get pump runtimes: %w", err)
+	}
+	defer rows.Close()
+	return scanPumpRuntimes(rows)
+}
+
+// SetPumpRuntimes saves the runtime of each pump of a pump rotation, leaving pumps not
+// in runtimes as they were, and records active as the only running pump
+func (s *SQLite) SetPumpRuntimes(ctx context.Context, rotation string, runtimes map[string]time.Duration, active string) error {
+	ll := s.logCtx(ctx, "rotation")
+	ll.Debug().Str("rotation", rotation).Int("pumps", len(runtimes)).Str("active", active).Msg("saving pump runtimes")
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO pump_runtimes (rotation, pump_tag, runtime_seconds, active, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (rotation, pump_tag) DO UPDATE SET
+			runtime_seconds = excluded.runtime_seconds,
+			active = excluded.active,
+			updated_at = excluded.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, `UPDATE pump_runtimes SET active = FALSE WHERE rotation = $1 AND pump_tag <> $2 AND active`, rotation, active); err != nil {
+		return fmt.Errorf("failed to clear active pump: %w", err)
+	}
+	now := s.timestamp()
+	for tag, runtime := range runtimes {
+		if _, err := tx.ExecContext(ctx, query, rotation, tag, int64(runtime/time.Second), tag == active, now); err != nil {
+			return fmt.Errorf("failed to set pump runtime: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
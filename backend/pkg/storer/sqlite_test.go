@@ -0,0 +1,442 @@
+package storer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// newTestSQLite returns a migrated SQLite store in a temporary file
+func newTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+	store, err := NewSQLite(filepath.Join(t.TempDir(), "lifesupport.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	return store
+}
+
+func TestOpen_SQLite(t *testing.T) {
+	store, err := Open("sqlite://" + filepath.Join(t.TempDir(), "open.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+	if _, ok := store.(*SQLite); !ok {
+		t.Fatalf("Expected a sqlite: URL to open a SQLite store, got %T", store)
+	}
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if err := store.Migrate(ctx); err != nil {
+		t.Errorf("Expected migrating again to do nothing, got %v", err)
+	}
+}
+
+func TestSQLite_CreateAndGetDevice(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+
+	dev := newMemoryDevice()
+	dev.Metadata = map[string]string{"model": "SHPLG-S"}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	if err := store.CreateDevice(ctx, newMemoryDevice()); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for duplicate device, got %v", err)
+	}
+
+	got, err := store.GetDevice(ctx, "dev-1")
+	if err != nil {
+		t.Fatalf("GetDevice() error = %v", err)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "device.dev-1" {
+		t.Errorf("Expected default device tag, got %v", got.Tags)
+	}
+	if got.Metadata["model"] != "SHPLG-S" || got.ExternalID != dev.ExternalID {
+		t.Errorf("Expected metadata and external id to round trip, got %+v", got)
+	}
+	if len(got.Sensors) != 2 || got.Sensors[0].ID != "temp" {
+		t.Fatalf("Expected 2 sensors ordered by name, got %+v", got.Sensors)
+	}
+	if got.Sensors[1].Tags[0] != "device.dev-1.sensor.ph" {
+		t.Errorf("Expected default sensor tag, got %v", got.Sensors[1].Tags)
+	}
+	if len(got.Actuators) != 1 || got.Actuators[0].DeviceID != "dev-1" {
+		t.Errorf("Expected 1 actuator on dev-1, got %+v", got.Actuators)
+	}
+
+	if _, err := store.GetDevice(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := store.GetDeviceByExternalID(ctx, dev.ExternalID); err != nil {
+		t.Errorf("GetDeviceByExternalID() error = %v", err)
+	}
+}
+
+func TestSQLite_CreateDevices(t *testing.T) {
+	checkCreateDevices(t, newTestSQLite(t))
+}
+
+func TestSQLite_PatchDevices(t *testing.T) {
+	checkPatchDevices(t, newTestSQLite(t))
+}
+
+func TestSQLite_PatchEntities(t *testing.T) {
+	checkPatchEntities(t, newTestSQLite(t))
+}
+
+func TestSQLite_ListTagTree(t *testing.T) {
+	checkListTagTree(t, newTestSQLite(t))
+}
+
+func TestSQLite_Tags(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	other := &api.Device{
+		ID:      "dev-2",
+		Name:    "Other",
+		Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", Tags: []string{"tank.temp"}}},
+	}
+	if err := store.CreateDevice(ctx, other); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for sensor tag conflict, got %v", err)
+	}
+	if _, err := store.GetDevice(ctx, "dev-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected failed create to store nothing, got %v", err)
+	}
+	other.Sensors = nil
+	if err := store.CreateDevice(ctx, other); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	other.Tags = []string{"device.dev-1"}
+	if err := store.UpdateDevice(ctx, other); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists for device tag conflict, got %v", err)
+	}
+	sensor := &api.Sensor{ID: "temp", DeviceID: "missing", Name: "Temperature"}
+	if err := store.CreateSensor(ctx, sensor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing device, got %v", err)
+	}
+
+	sensors, err := store.ListSensorsByTagPrefix(ctx, "tank.")
+	if err != nil {
+		t.Fatalf("ListSensorsByTagPrefix() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].ID != "temp" {
+		t.Errorf("Expected only temp under tank., got %+v", sensors)
+	}
+	if sensors, _ := store.ListSensorsByTagPrefix(ctx, "TANK."); len(sensors) != 0 {
+		t.Errorf("Expected tag prefixes to be case sensitive, got %+v", sensors)
+	}
+
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "main.temp", Target: "tank.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+	if sensor, err := store.GetSensorByTag(ctx, "main.temp"); err != nil || sensor.ID != "temp" {
+		t.Errorf("Expected alias to resolve to temp, got %+v, %v", sensor, err)
+	}
+	// Real tags take precedence over aliases
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "device.dev-1.sensor.ph", Target: "tank.temp"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+	if sensor, err := store.GetSensorByTag(ctx, "device.dev-1.sensor.ph"); err != nil || sensor.ID != "ph" {
+		t.Errorf("Expected real tag to win over alias, got %+v, %v", sensor, err)
+	}
+	if _, err := store.GetActuatorByTag(ctx, "tank.temp"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another entity type's tag, got %v", err)
+	}
+}
+
+func TestSQLite_Readings(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var recs []*api.ReadingRecord
+	for i := 0; i < 3; i++ {
+		recs = append(recs, &api.ReadingRecord{
+			DeviceID: "dev-1",
+			SensorID: "temp",
+			Reading:  api.SensorReading{Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Minute), Valid: true},
+		})
+	}
+	if err := store.StoreSensorReadings(ctx, recs); err != nil {
+		t.Fatalf("StoreSensorReadings() error = %v", err)
+	}
+	missing := &api.ReadingRecord{DeviceID: "dev-1", SensorID: "missing", Reading: api.SensorReading{Timestamp: base}}
+	if err := store.StoreSensorReading(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown sensor, got %v", err)
+	}
+
+	start := base.Add(time.Minute)
+	readings, err := store.GetSensorReadings(ctx, SensorReadingFilters{DeviceID: "dev-1", StartTime: &start})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(readings) != 2 || readings[0].Reading.Value != 2 || !readings[0].Reading.Timestamp.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("Expected 2 readings newest first, got %+v", readings)
+	}
+	older, err := store.GetSensorReadings(ctx, SensorReadingFilters{Before: &ReadingKey{Timestamp: readings[1].Reading.Timestamp, ID: readings[1].ID}})
+	if err != nil {
+		t.Fatalf("GetSensorReadings() error = %v", err)
+	}
+	if len(older) != 1 || older[0].Reading.Value != 0 {
+		t.Errorf("Expected the one older reading, got %+v", older)
+	}
+	if count, err := store.CountSensorReadings(ctx, SensorReadingFilters{SensorID: "temp"}); err != nil || count != 3 {
+		t.Errorf("Expected 3 readings, got %d, %v", count, err)
+	}
+
+	deleted, err := store.DeleteOldSensorReadings(ctx, start)
+	if err != nil {
+		t.Fatalf("DeleteOldSensorReadings() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 reading deleted, got %d", deleted)
+	}
+	if err := store.DeleteDevice(ctx, "dev-1"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+	if count, _ := store.CountSensorReadings(ctx, SensorReadingFilters{}); count != 0 {
+		t.Errorf("Expected readings removed with device, got %d", count)
+	}
+}
+
+func TestSQLite_Leases(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	lease, err := store.AcquireLease(ctx, "driver/shelly", "worker-1", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() error = %v", err)
+	}
+	if lease.Holder != "worker-1" || !lease.ExpiresAt.Equal(now.Add(15*time.Second)) {
+		t.Errorf("Expected worker-1 to hold the lease for 15s, got %+v", lease)
+	}
+	if _, err := store.AcquireLease(ctx, "driver/shelly", "worker-2", 15*time.Second); !errors.Is(err, ErrLeaseHeld) {
+		t.Errorf("Expected ErrLeaseHeld for a second holder, got %v", err)
+	}
+
+	now = now.Add(10 * time.Second)
+	renewed, err := store.AcquireLease(ctx, "driver/shelly", "worker-1", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() renewal error = %v", err)
+	}
+	if !renewed.AcquiredAt.Equal(lease.AcquiredAt) || !renewed.ExpiresAt.Equal(now.Add(15*time.Second)) {
+		t.Errorf("Expected renewal to extend the lease only, got %+v", renewed)
+	}
+
+	now = now.Add(16 * time.Second)
+	taken, err := store.AcquireLease(ctx, "driver/shelly", "worker-2", 15*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLease() takeover error = %v", err)
+	}
+	if taken.Holder != "worker-2" || !taken.AcquiredAt.Equal(now) {
+		t.Errorf("Expected worker-2 to take over the expired lease, got %+v", taken)
+	}
+	if err := store.ReleaseLease(ctx, "driver/shelly", "worker-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound releasing a lease held by someone else, got %v", err)
+	}
+}
+
+func TestSQLite_Changes(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	now := time.Date(2026, 2, 16, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	if err := store.CreateDevice(ctx, newMemoryDevice()); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	reading := &api.ReadingRecord{DeviceID: "dev-1", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Valid: true, Timestamp: now}}
+	if err := store.StoreSensorReadings(ctx, []*api.ReadingRecord{reading, {DeviceID: "dev-1", SensorID: "nope"}}); err == nil {
+		t.Fatal("Expected storing a reading of an unknown sensor to fail")
+	}
+	if err := store.StoreSensorReading(ctx, reading); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+	now = now.Add(time.Hour)
+	if err := store.DeleteDevice(ctx, "dev-1"); err != nil {
+		t.Fatalf("DeleteDevice() error = %v", err)
+	}
+
+	changes, err := store.ListChanges(ctx, 0, 100)
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	want := []api.ChangeType{api.ChangeDeviceCreated, api.ChangeReadingStored, api.ChangeDeviceDeleted}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %d", len(want), len(changes))
+	}
+	for i, typ := range want {
+		if changes[i].Type != typ {
+			t.Errorf("Expected change %d to be %s, got %s", i+1, typ, changes[i].Type)
+		}
+	}
+	var stored api.ReadingRecord
+	if err := json.Unmarshal(changes[1].Data, &stored); err != nil || stored.Reading.Value != 25.5 {
+		t.Errorf("Expected the reading as change data, got %s", changes[1].Data)
+	}
+	if seq, err := store.LatestChangeSeq(ctx); err != nil || seq != changes[2].Seq {
+		t.Errorf("Expected the latest change to be %d, got %d, %v", changes[2].Seq, seq, err)
+	}
+	if deleted, err := store.DeleteOldChanges(ctx, now); err != nil || deleted != 2 {
+		t.Errorf("Expected 2 old changes deleted, got %d, %v", deleted, err)
+	}
+
+	if err := store.SetChangeCursor(ctx, "nats", changes[2].Seq); err != nil {
+		t.Fatalf("SetChangeCursor() error = %v", err)
+	}
+	if seq, _ := store.GetChangeCursor(ctx, "nats"); seq != changes[2].Seq {
+		t.Errorf("Expected cursor %d, got %d", changes[2].Seq, seq)
+	}
+}
+
+func TestSQLite_PumpRuntimes(t *testing.T) {
+	checkPumpRuntimes(t, newTestSQLite(t))
+}
+
+func TestSQLite_ListPages(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	for _, id := range []string{"dev-c", "dev-a", "dev-b"} {
+		dev := &api.Device{ID: id, Driver: api.DriverShelly, Name: "Shelly " + id,
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temperature", SensorType: api.SensorTypeTemperature}}}
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+
+	devices, next, err := store.ListDevicesPage(ctx, Page{Limit: 2})
+	if err != nil {
+		t.Fatalf("ListDevicesPage() error = %v", err)
+	}
+	if len(devices) != 2 || devices[0].ID != "dev-a" || next == "" {
+		t.Fatalf("Expected the first 2 devices and a next cursor, got %+v %q", devices, next)
+	}
+	devices, next, err = store.ListDevicesPage(ctx, Page{Cursor: next})
+	if err != nil {
+		t.Fatalf("ListDevicesPage() error = %v", err)
+	}
+	if len(devices) != 1 || devices[0].ID != "dev-c" || next != "" {
+		t.Errorf("Expected the last device and no next cursor, got %+v %q", devices, next)
+	}
+
+	sensors, _, err := store.ListSensorsPage(ctx, "dev-b", Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSensorsPage() error = %v", err)
+	}
+	if len(sensors) != 1 || sensors[0].DeviceID != "dev-b" {
+		t.Errorf("Expected only dev-b's sensor, got %+v", sensors)
+	}
+}
+
+func TestSQLite_FeatureFlags(t *testing.T) {
+	store := newTestSQLite(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	flag := &api.FeatureFlag{Name: "rules-engine", Description: "Control rules", Percent: 10}
+	if err := store.SetFeatureFlag(ctx, flag); err != nil {
+		t.Fatalf("SetFeatureFlag() error = %v", err)
+	}
+	flag.Percent = 50
+	if err := store.SetFeatureFlag(ctx, flag); err != nil {
+		t.Fatalf("SetFeatureFlag() error = %v", err)
+	}
+	if err := store.SetFeatureFlag(ctx, &api.FeatureFlag{Name: "broken", Percent: 150}); err == nil {
+		t.Error("Expected an out of range percent to be rejected")
+	}
+
+	got, err := store.GetFeatureFlag(ctx, "rules-engine")
+	if err != nil {
+		t.Fatalf("GetFeatureFlag() error = %v", err)
+	}
+	if got.Percent != 50 || got.Description != "Control rules" || !got.UpdatedAt.Equal(now) {
+		t.Errorf("Expected the updated flag, got %+v", got)
+	}
+	if flags, _ := store.ListFeatureFlags(ctx); len(flags) != 1 {
+		t.Errorf("Expected 1 flag, got %d", len(flags))
+	}
+
+	if err := store.DeleteFeatureFlag(ctx, "rules-engine"); err != nil {
+		t.Fatalf("DeleteFeatureFlag() error = %v", err)
+	}
+	if _, err := store.GetFeatureFlag(ctx, "rules-engine"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.DeleteFeatureFlag(ctx, "rules-engine"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting again, got %v", err)
+	}
+}
+
+func TestSQLite_AuditLog(t *testing.T) {
+	store := newTestSQLite(t)
+	now := time.Date(2026, 2, 16, 10, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	checkAuditLog(t, store, func(d time.Duration) { now = now.Add(d) })
+}
+
+func TestSQLite_VersionConflicts(t *testing.T) {
+	checkVersionConflicts(t, newTestSQLite(t))
+}
+
+func TestSQLite_MetadataFilter(t *testing.T) {
+	checkMetadataFilter(t, newTestSQLite(t))
+}
+
+func TestSQLite_ReadingLabels(t *testing.T) {
+	checkReadingLabels(t, newTestSQLite(t))
+}
+
+func TestSQLite_Groups(t *testing.T) {
+	checkGroups(t, newTestSQLite(t))
+}
+
+func TestSQLite_RuleTraces(t *testing.T) {
+	checkRuleTraces(t, newTestSQLite(t))
+}
+
+func TestSQLite_LatestReadings(t *testing.T) {
+	checkLatestReadings(t, newTestSQLite(t))
+}
+
+func TestSQLite_AggregatedReadings(t *testing.T) {
+	checkAggregatedReadings(t, newTestSQLite(t))
+}
+
+func TestSQLite_Retention(t *testing.T) {
+	checkRetention(t, newTestSQLite(t))
+}
+
+func TestSQLite_StorageQuotas(t *testing.T) {
+	checkStorageQuotas(t, newTestSQLite(t))
+}
+
+func TestSQLite_TagTemplates(t *testing.T) {
+	checkTagTemplates(t, newTestSQLite(t))
+}
+
+func TestSQLite_Retag(t *testing.T) {
+	checkRetag(t, newTestSQLite(t))
+}
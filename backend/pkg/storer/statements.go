@@ -0,0 +1,72 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// hotQuery runs a fixed query with varying arguments; *sql.Stmt is one
+type hotQuery interface {
+	QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row
+}
+
+// unprepared runs its query afresh each time, for when statements aren't cached
+type unprepared struct {
+	db    conn
+	query string
+}
+
+func (u unprepared) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	return u.db.QueryContext(ctx, u.query, args...)
+}
+
+func (u unprepared) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	return u.db.QueryRowContext(ctx, u.query, args...)
+}
+
+// stmtCache holds the prepared statements of hot queries, so workflows calling them
+// constantly skip planning each time. database/sql prepares a statement again on each
+// pooled connection it runs on.
+type stmtCache struct {
+	disabled bool
+	lock     sync.Mutex
+	stmts    map[string]*sql.Stmt
+}
+
+// hot returns query prepared, preparing it on first use
+func (s *Storer) hot(ctx context.Context, query string) (hotQuery, error) {
+	if s.stmts.disabled {
+		return unprepared{db: s.db, query: query}, nil
+	}
+	s.stmts.lock.Lock()
+	defer s.stmts.lock.Unlock()
+	stmt, ok := s.stmts.stmts[query]
+	if !ok {
+		var err error
+		stmt, err = s.db.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		if s.stmts.stmts == nil {
+			s.stmts.stmts = map[string]*sql.Stmt{}
+		}
+		s.stmts.stmts[query] = stmt
+	}
+	if s.slowQueries != nil {
+		return slowStmt{hotQuery: stmt, query: query, s: s}, nil
+	}
+	return stmt, nil
+}
+
+// close closes the cached statements
+func (c *stmtCache) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, stmt := range c.stmts {
+		stmt.Close()
+	}
+	c.stmts = nil
+}
@@ -0,0 +1,197 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/lib/pq"
+)
+
+// SetStorageQuota creates the quota of its sensor or replaces an existing one, setting
+// quota.UpdatedAt. Downsampling is stored in whole seconds.
+func (s *Storer) SetStorageQuota(ctx context.Context, quota *api.StorageQuota) error {
+	ll := s.logCtx(ctx, "storage")
+	ll.Info().
+		Str("device_id", quota.DeviceID).
+		Str("sensor_id", quota.SensorID).
+		Int64("max_readings", quota.MaxReadings).
+		Dur("downsample", quota.Downsample).
+		Msg("setting storage quota")
+	query := `
+		INSERT INTO storage_quotas (device_id, sensor_id, max_readings, downsample_seconds, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+			max_readings = EXCLUDED.max_readings,
+			downsample_seconds = EXCLUDED.downsample_seconds,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	err := s.db.QueryRowContext(ctx, query, quota.DeviceID, quota.SensorID, quota.MaxReadings,
+		int64(quota.Downsample/time.Second)).Scan(&quota.UpdatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, quota.DeviceID, quota.SensorID)
+			}
+		}
+		return fmt.Errorf("failed to set storage quota: %w", err)
+	}
+	return nil
+}
+
+// DeleteStorageQuota deletes the quota of a sensor, keeping all of its readings
+func (s *Storer) DeleteStorageQuota(ctx context.Context, deviceID, sensorID string) error {
+	ll := s.logCtx(ctx, "storage")
+	ll.Info().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting storage quota")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM storage_quotas WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete storage quota: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: storage quota %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return nil
+}
+
+// ListStorageQuotas retrieves all storage quotas, ordered by device and sensor
+func (s *Storer) ListStorageQuotas(ctx context.Context) ([]*api.StorageQuota, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, sensor_id, max_readings, downsample_seconds, updated_at
+		FROM storage_quotas ORDER BY device_id, sensor_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage quotas: %w", err)
+	}
+	defer rows.Close()
+	return scanStorageQuotas(rows)
+}
+
+func scanStorageQuotas(rows *sql.Rows) ([]*api.StorageQuota, error) {
+	var quotas []*api.StorageQuota
+	for rows.Next() {
+		var q api.StorageQuota
+		var seconds int64
+		if err := rows.Scan(&q.DeviceID, &q.SensorID, &q.MaxReadings, &seconds, &q.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan storage quota: %w", err)
+		}
+		q.Downsample = time.Duration(seconds) * time.Second
+		quotas = append(quotas, &q)
+	}
+	return quotas, rows.Err()
+}
+
+// TableUsage reports the rows and bytes, indexes and TOAST included, of every table in
+// the schema. Row counts are the planner's estimates, so reporting stays cheap on large
+// tables. A sensor_readings hypertable is measured across its chunks.
+func (s *Storer) TableUsage(ctx context.Context) ([]api.TableUsage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT relname, GREATEST(n_live_tup, 0), pg_total_relation_size(relid)
+		FROM pg_stat_user_tables WHERE schemaname = current_schema()
+		ORDER BY relname
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table usage: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []api.TableUsage
+	for rows.Next() {
+		var t api.TableUsage
+		if err := rows.Scan(&t.Table, &t.Rows, &t.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan table usage: %w", err)
+		}
+		tables = append(tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query table usage: %w", err)
+	}
+
+	if s.timescale != nil {
+		var readings api.TableUsage
+		err := s.db.QueryRowContext(ctx, `
+			SELECT COALESCE(hypertable_size('sensor_readings'), 0), approximate_row_count('sensor_readings')
+		`).Scan(&readings.Bytes, &readings.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query hypertable usage: %w", err)
+		}
+		for i := range tables {
+			if tables[i].Table == "sensor_readings" {
+				tables[i].Rows, tables[i].Bytes = readings.Rows, readings.Bytes
+			}
+		}
+	}
+	return tables, nil
+}
+
+// SensorReadingUsage counts the readings kept of each sensor with any, and how many of
+// them were taken at or after since, ordered by device and sensor
+func (s *Storer) SensorReadingUsage(ctx context.Context, since time.Time) ([]api.SensorUsage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT device_id, sensor_id, COUNT(*), COUNT(*) FILTER (WHERE timestamp >= $1)
+		FROM sensor_readings
+		GROUP BY device_id, sensor_id
+		ORDER BY device_id, sensor_id
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor reading usage: %w", err)
+	}
+	defer rows.Close()
+	return scanSensorUsage(rows)
+}
+
+func scanSensorUsage(rows *sql.Rows) ([]api.SensorUsage, error) {
+	var usage []api.SensorUsage
+	for rows.Next() {
+		var u api.SensorUsage
+		if err := rows.Scan(&u.DeviceID, &u.SensorID, &u.Readings, &u.Recent); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor reading usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// ThinSensorReadings downsamples a sensor's readings taken before the given time to the
+// last one of each interval, counted in whole seconds from the Unix epoch, and returns
+// how many were deleted. The sensor's latest reading is always kept.
+func (s *Storer) ThinSensorReadings(ctx context.Context, deviceID, sensorID string, before time.Time, interval time.Duration) (int64, error) {
+	if interval < time.Second {
+		return 0, fmt.Errorf("failed to thin sensor readings: interval %s is too short", interval)
+	}
+	ll := s.logCtx(ctx, "readings")
+	ll.Debug().
+		Str("device_id", deviceID).
+		Str("sensor_id", sensorID).
+		Time("before", before).
+		Dur("interval", interval).
+		Msg("thinning sensor readings")
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM sensor_readings r USING (
+			SELECT id, ROW_NUMBER() OVER (
+				PARTITION BY FLOOR(EXTRACT(EPOCH FROM timestamp) / $4)
+				ORDER BY timestamp DESC, id DESC
+			) AS n
+			FROM sensor_readings
+			WHERE device_id = $1 AND sensor_id = $2 AND timestamp < $3
+		) ranked
+		WHERE r.device_id = $1 AND r.sensor_id = $2 AND r.timestamp < $3
+			AND r.id = ranked.id AND ranked.n > 1
+	`, deviceID, sensorID, before, int64(interval/time.Second))
+	if err != nil {
+		return 0, fmt.Errorf("failed to thin sensor readings: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows, nil
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
+
 	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/logging"
 
 	"github.com/lib/pq"
 	_ "github.com/lib/pq"
@@ -18,6 +21,10 @@ import (
 var (
 	ErrNotFound      = errors.New("not found")
 	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict means an update was based on a version that has since changed
+	ErrConflict = errors.New("version conflict")
+	// ErrLeaseHeld means another holder has an unexpired lease
+	ErrLeaseHeld = errors.New("lease held by another holder")
 )
 
 // execer is an interface that both *sql.DB and *sql.Tx implement
@@ -27,13 +34,29 @@ type execer interface {
 
 // Storer provides database operations for device data
 type Storer struct {
-	db  *sql.DB
-	log zerolog.Logger
+	// db runs queries: the pool, or the transaction tx bound by WithTx
+	db     conn
+	dbPool *sql.DB
+	tx     *sql.Tx
+	log    zerolog.Logger
+	// pool configures the connection pool once it is opened
+	pool          []func(*sql.DB)
+	statsInterval time.Duration
+	done          chan struct{}
+	stmts         stmtCache
+	// timescale, when set, makes sensor_readings a hypertable
+	timescale *TimescaleConfig
+	// slowThreshold, when positive, captures queries taking at least as long, queuing
+	// them on slowQueries
+	slowThreshold time.Duration
+	slowQueries   chan slowQuery
+	// committed wakes relayChanges after a transaction commits
+	committed chan struct{}
 }
 
 // New creates a new Storer instance with a PostgreSQL connection
 func New(connString string, opts ...Option) (*Storer, error) {
-	s := &Storer{}
+	s := &Storer{done: make(chan struct{}), committed: make(chan struct{}, 1)}
 	for _, opt := range opts {
 		opt(s)
 	}
@@ -41,222 +64,123 @@ func New(connString string, opts ...Option) (*Storer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	for _, configure := range s.pool {
+		configure(db)
+	}
 	s.db = db
+	s.dbPool = db
+	if s.slowThreshold > 0 {
+		s.slowQueries = make(chan slowQuery, slowQueryBacklog)
+		s.db = slowConn{conn: db, s: s}
+	}
 
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	if s.statsInterval > 0 {
+		go s.logPoolStats()
+	}
+	if s.slowQueries != nil {
+		go s.captureSlowQueries()
+	}
+	go s.relayChanges()
 	return s, nil
 }
 
+// logPoolStats logs the connection pool's statistics every statsInterval until the store
+// is closed. Wait counts and durations are totals since the pool was opened.
+func (s *Storer) logPoolStats() {
+	ll := s.logCtx(context.Background(), "pool")
+	ticker := time.NewTicker(s.statsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		stats := s.dbPool.Stats()
+		ll.Info().
+			Int("max_open", stats.MaxOpenConnections).
+			Int("open", stats.OpenConnections).
+			Int("in_use", stats.InUse).
+			Int("idle", stats.Idle).
+			Int64("wait_count", stats.WaitCount).
+			Dur("wait_duration", stats.WaitDuration).
+			Int64("max_idle_closed", stats.MaxIdleClosed).
+			Int64("max_lifetime_closed", stats.MaxLifetimeClosed).
+			Msg("Database pool stats")
+	}
+}
+
 func (s *Storer) logCtx(ctx context.Context, sub string) zerolog.Logger {
+	return storerLogCtx(ctx, s.log, sub)
+}
+
+// storerLogCtx returns the context's logger, or base without one, for a subcomponent of
+// the store
+func storerLogCtx(ctx context.Context, base zerolog.Logger, sub string) zerolog.Logger {
 	var ll zerolog.Context
 	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
 		ll = ctxLog.With()
 	} else {
-		ll = s.log.With()
+		ll = base.With()
 	}
 	ll = ll.Str("component", "storer")
 	if sub != "" {
 		ll = ll.Str("subcomponent", sub)
 	}
-	return ll.Logger()
+	return logging.Component(ll.Logger(), "storer")
 }
 
 // Close closes the database connection
 func (s *Storer) Close() error {
-	log.Debug().Msg("closing database connection")
-	return s.db.Close()
-}
-
-// InitSchema creates the necessary database tables
-func (s *Storer) InitSchema(ctx context.Context) error {
-	ll := s.logCtx(ctx, "schema")
-	ll.Debug().Msg("initializing database schema")
-	schema := `
-	CREATE TABLE IF NOT EXISTS devices (
-		id VARCHAR(255) PRIMARY KEY,
-		driver VARCHAR(50) NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		description TEXT,
-		metadata JSONB,
-		tags TEXT[],
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_devices_tags ON devices USING GIN(tags);
-
-	CREATE TABLE IF NOT EXISTS sensors (
-		id VARCHAR(255) NOT NULL,
-		device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
-		name VARCHAR(255) NOT NULL,
-		sensor_type VARCHAR(50) NOT NULL,
-		metadata JSONB,
-		tags TEXT[],
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		PRIMARY KEY (device_id, id)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_sensors_device_id ON sensors(device_id);
-	CREATE INDEX IF NOT EXISTS idx_sensors_tags ON sensors USING GIN(tags);
-	CREATE INDEX IF NOT EXISTS idx_sensors_type ON sensors(sensor_type);
-
-	CREATE TABLE IF NOT EXISTS actuators (
-		id VARCHAR(255) NOT NULL,
-		device_id VARCHAR(255) NOT NULL REFERENCES devices(id) ON DELETE CASCADE,
-		name VARCHAR(255) NOT NULL,
-		actuator_type VARCHAR(50) NOT NULL,
-		metadata JSONB,
-		tags TEXT[],
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		PRIMARY KEY (device_id, id)
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_actuators_device_id ON actuators(device_id);
-	CREATE INDEX IF NOT EXISTS idx_actuators_tags ON actuators USING GIN(tags);
-	CREATE INDEX IF NOT EXISTS idx_actuators_type ON actuators(actuator_type);
-	`
-
-	// Create trigger functions to enforce tag uniqueness
-	triggerFunctions := `
-	-- Function to check unique tags for devices
-	CREATE OR REPLACE FUNCTION check_device_tags_unique()
-	RETURNS TRIGGER AS $$
-	BEGIN
-		IF EXISTS (
-			SELECT 1 FROM devices 
-			WHERE id != NEW.id 
-			AND tags && NEW.tags
-		) THEN
-			RAISE EXCEPTION 'Tag already exists in another device';
-		END IF;
-		RETURN NEW;
-	END;
-	$$ LANGUAGE plpgsql;
-
-	DROP TRIGGER IF EXISTS device_tags_unique_trigger ON devices;
-	CREATE TRIGGER device_tags_unique_trigger
-		BEFORE INSERT OR UPDATE ON devices
-		FOR EACH ROW EXECUTE FUNCTION check_device_tags_unique();
-
-	-- Function to check unique tags for sensors
-	CREATE OR REPLACE FUNCTION check_sensor_tags_unique()
-	RETURNS TRIGGER AS $$
-	BEGIN
-		IF EXISTS (
-			SELECT 1 FROM sensors 
-			WHERE (device_id != NEW.device_id OR id != NEW.id)
-			AND tags && NEW.tags
-		) THEN
-			RAISE EXCEPTION 'Tag already exists in another sensor';
-		END IF;
-		RETURN NEW;
-	END;
-	$$ LANGUAGE plpgsql;
-
-	DROP TRIGGER IF EXISTS sensor_tags_unique_trigger ON sensors;
-	CREATE TRIGGER sensor_tags_unique_trigger
-		BEFORE INSERT OR UPDATE ON sensors
-		FOR EACH ROW EXECUTE FUNCTION check_sensor_tags_unique();
-
-	-- Function to check unique tags for actuators
-	CREATE OR REPLACE FUNCTION check_actuator_tags_unique()
-	RETURNS TRIGGER AS $$
-	BEGIN
-		IF EXISTS (
-			SELECT 1 FROM actuators 
-			WHERE (device_id != NEW.device_id OR id != NEW.id)
-			AND tags && NEW.tags
-		) THEN
-			RAISE EXCEPTION 'Tag already exists in another actuator';
-		END IF;
-		RETURN NEW;
-	END;
-	$$ LANGUAGE plpgsql;
-
-	DROP TRIGGER IF EXISTS actuator_tags_unique_trigger ON actuators;
-	CREATE TRIGGER actuator_tags_unique_trigger
-		BEFORE INSERT OR UPDATE ON actuators
-		FOR EACH ROW EXECUTE FUNCTION check_actuator_tags_unique();
-	`
-
-	_, err := s.db.ExecContext(ctx, schema)
-	if err != nil {
-		return fmt.Errorf("failed to initialize schema: %w", err)
-	}
-
-	_, err = s.db.ExecContext(ctx, triggerFunctions)
-	if err != nil {
-		return fmt.Errorf("failed to create trigger functions: %w", err)
+	if s.tx != nil {
+		return errBoundClose
 	}
-
-	return nil
+	log.Debug().Msg("closing database connection")
+	close(s.done)
+	s.stmts.close()
+	return s.dbPool.Close()
 }
 
 // Device operations
 
-func (s *Storer) createDevice(ctx context.Context, dev *api.Device) error {
+// createDevice creates dev with its nested sensors and actuators within tx
+func (s *Storer) createDevice(ctx context.Context, tx *sql.Tx, dev *api.Device) error {
 	metadata, err := json.Marshal(dev.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	// Ensure default tag is present
-	dev.EnsureDefaultTag()
-
-	query := `
-		INSERT INTO devices (id, driver, name, description, metadata, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-	`
-	_, err = s.db.ExecContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, pq.Array(dev.Tags))
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return fmt.Errorf("%w: device with id %s", ErrAlreadyExists, dev.ID)
-			}
-		}
-		return fmt.Errorf("failed to create device: %w", err)
-	}
-
-	return nil
-}
-
-// CreateDevice creates a new device with its nested sensors and actuators in a transaction
-func (s *Storer) CreateDevice(ctx context.Context, dev *api.Device) error {
-	ll := s.logCtx(ctx, "device")
-	ll.Debug().Str("device_id", dev.ID).Str("driver", string(dev.Driver)).Msg("creating device")
-	// Start a transaction
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	// Ensure default tags are present
+	if err := tagDevice(ctx, tx, dev); err != nil {
+		return err
 	}
-	defer tx.Rollback()
-
-	// Create the device
-	metadata, err := json.Marshal(dev.Metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+	if err := ensureExternalID(&dev.ExternalID); err != nil {
+		return err
 	}
-
-	// Ensure default tag is present
-	dev.EnsureDefaultTag()
+	dev.Version = 1
 
 	query := `
-		INSERT INTO devices (id, driver, name, description, metadata, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		INSERT INTO devices (id, driver, name, description, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
 	`
-	_, err = tx.ExecContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, pq.Array(dev.Tags))
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return fmt.Errorf("%w: device with id %s", ErrAlreadyExists, dev.ID)
+	err = s.audited(ctx, tx, api.AuditEntityDevice, []string{dev.ID}, func() error {
+		_, err := tx.ExecContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, pq.Array(dev.Tags), dev.ExternalID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == "23505" { // unique_violation
+					return fmt.Errorf("%w: device with id %s", ErrAlreadyExists, dev.ID)
+				}
 			}
+			return fmt.Errorf("failed to create device: %w", err)
 		}
-		return fmt.Errorf("failed to create device: %w", err)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Insert nested sensors
@@ -264,7 +188,10 @@ func (s *Storer) CreateDevice(ctx context.Context, dev *api.Device) error {
 		// Ensure device_id is set
 		sensor.DeviceID = dev.ID
 
-		if err := s.createSensor(ctx, tx, sensor); err != nil {
+		err := s.audited(ctx, tx, api.AuditEntitySensor, []string{dev.ID, sensor.ID}, func() error {
+			return s.createSensor(ctx, tx, sensor)
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -274,25 +201,82 @@ func (s *Storer) CreateDevice(ctx context.Context, dev *api.Device) error {
 		// Ensure device_id is set
 		actuator.DeviceID = dev.ID
 
-		if err := s.createActuator(ctx, tx, actuator); err != nil {
+		err := s.audited(ctx, tx, api.AuditEntityActuator, []string{dev.ID, actuator.ID}, func() error {
+			return s.createActuator(ctx, tx, actuator)
+		})
+		if err != nil {
 			return err
 		}
 	}
 
-	// Commit the transaction
+	change, err := newChange(api.ChangeDeviceCreated, dev.ID, dev)
+	if err != nil {
+		return err
+	}
+	if err := s.recordChanges(ctx, tx, change); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateDevice creates a new device with its nested sensors and actuators in a transaction
+func (s *Storer) CreateDevice(ctx context.Context, dev *api.Device) error {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Str("device_id", dev.ID).Str("driver", string(dev.Driver)).Msg("creating device")
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.createDevice(ctx, tx.Tx, dev); err != nil {
+		return err
+	}
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
 	return nil
 }
 
+// CreateDevices creates devs, each with its nested sensors and actuators, in a single
+// transaction. A device that cannot be created is skipped, with its error at its index
+// in the returned slice; the returned error is for the batch as a whole.
+func (s *Storer) CreateDevices(ctx context.Context, devs []*api.Device) ([]error, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Int("count", len(devs)).Msg("creating devices")
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// A savepoint per device lets the rest of the batch go on after one fails
+	errs := make([]error, len(devs))
+	for i, dev := range devs {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT create_device`); err != nil {
+			return nil, fmt.Errorf("failed to create savepoint: %w", err)
+		}
+		if errs[i] = s.createDevice(ctx, tx.Tx, dev); errs[i] != nil {
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT create_device`); err != nil {
+				return nil, fmt.Errorf("failed to roll back device %s: %w", dev.ID, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT create_device`); err != nil {
+			return nil, fmt.Errorf("failed to release savepoint: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return errs, nil
+}
+
 // GetDevice retrieves a device by ID
 func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error) {
 	ll := s.logCtx(ctx, "device")
 	ll.Debug().Str("device_id", id).Msg("getting device")
 	query := `
-		SELECT id, driver, name, description, metadata, tags
+		SELECT id, driver, name, description, metadata, tags, external_id, version
 		FROM devices 
 		WHERE id = $1
 	`
@@ -301,8 +285,12 @@ func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error)
 	var metadataJSON []byte
 	var tags []string
 
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags),
+	stmt, err := s.hot(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	err = stmt.QueryRowContext(ctx, id).Scan(
+		&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags), &dev.ExternalID, &dev.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -321,11 +309,15 @@ func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error)
 
 	// Fetch sensors for this device
 	sensorsQuery := `
-		SELECT id, device_id, name, sensor_type, metadata, tags
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors
 		WHERE device_id = $1
 	`
-	sensorRows, err := s.db.QueryContext(ctx, sensorsQuery, id)
+	sensorsStmt, err := s.hot(ctx, sensorsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	sensorRows, err := sensorsStmt.QueryContext(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sensors: %w", err)
 	}
@@ -338,7 +330,7 @@ func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error)
 
 		err := sensorRows.Scan(
 			&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType,
-			&sensorMetadataJSON, pq.Array(&sensorTags),
+			&sensorMetadataJSON, pq.Array(&sensorTags), &sensor.ExternalID, &sensor.Version,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan sensor: %w", err)
@@ -360,11 +352,15 @@ func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error)
 
 	// Fetch actuators for this device
 	actuatorsQuery := `
-		SELECT id, device_id, name, actuator_type, metadata, tags
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators
 		WHERE device_id = $1
 	`
-	actuatorRows, err := s.db.QueryContext(ctx, actuatorsQuery, id)
+	actuatorsStmt, err := s.hot(ctx, actuatorsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query actuators: %w", err)
+	}
+	actuatorRows, err := actuatorsStmt.QueryContext(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query actuators: %w", err)
 	}
@@ -377,7 +373,7 @@ func (s *Storer) GetDevice(ctx context.Context, id string) (*api.Device, error)
 
 		err := actuatorRows.Scan(
 			&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType,
-			&actuatorMetadataJSON, pq.Array(&actuatorTags),
+			&actuatorMetadataJSON, pq.Array(&actuatorTags), &actuator.ExternalID, &actuator.Version,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan actuator: %w", err)
@@ -409,30 +405,53 @@ func (s *Storer) UpdateDevice(ctx context.Context, dev *api.Device) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// Ensure default tag is present
-	dev.EnsureDefaultTag()
+	templates, err := loadTagTemplates(ctx, tx.Tx)
+	if err != nil {
+		return err
+	}
+	dev.EnsureDefaultTag(templates)
 
 	query := `
 		UPDATE devices 
-		SET driver = $2, name = $3, description = $4, metadata = $5, tags = $6, updated_at = NOW()
-		WHERE id = $1
+		SET driver = $2, name = $3, description = $4, metadata = $5, tags = $6, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $7
+		RETURNING version
 	`
-	result, err := s.db.ExecContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, pq.Array(dev.Tags))
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	err = s.audited(ctx, tx.Tx, api.AuditEntityDevice, []string{dev.ID}, func() error {
+		err := tx.QueryRowContext(ctx, query, dev.ID, dev.Driver, dev.Name, dev.Description, metadata, pq.Array(dev.Tags), dev.Version).Scan(&dev.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx.Tx, `SELECT version FROM devices WHERE id = $1`, fmt.Sprintf("device %s", dev.ID), dev.ID)
+		}
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == "23505" { // unique_violation
+					return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+				}
 			}
+			return fmt.Errorf("failed to update device: %w", err)
 		}
-		return fmt.Errorf("failed to update device: %w", err)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	rows, err := result.RowsAffected()
+	change, err := newChange(api.ChangeDeviceUpdated, dev.ID, dev)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("%w: device %s", ErrNotFound, dev.ID)
+	if err := s.recordChanges(ctx, tx.Tx, change); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
@@ -442,18 +461,29 @@ func (s *Storer) UpdateDevice(ctx context.Context, dev *api.Device) error {
 func (s *Storer) DeleteDevice(ctx context.Context, id string) error {
 	ll := s.logCtx(ctx, "device")
 	ll.Debug().Str("device_id", id).Msg("deleting device")
-	query := `DELETE FROM devices WHERE id = $1`
-	result, err := s.db.ExecContext(ctx, query, id)
+	tx, err := s.begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to delete device: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := result.RowsAffected()
+	query := `DELETE FROM devices WHERE id = $1`
+	err = s.audited(ctx, tx.Tx, api.AuditEntityDevice, []string{id}, func() error {
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete device: %w", err)
+		}
+		return expectRow(result, "device %s", id)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return err
 	}
-	if rows == 0 {
-		return fmt.Errorf("%w: device %s", ErrNotFound, id)
+
+	if err := s.recordChanges(ctx, tx.Tx, &api.ChangeEvent{Type: api.ChangeDeviceDeleted, EntityID: id}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
@@ -464,7 +494,7 @@ func (s *Storer) ListDevices(ctx context.Context) ([]*api.Device, error) {
 	ll := s.logCtx(ctx, "device")
 	ll.Debug().Msg("listing all devices")
 	query := `
-		SELECT id, driver, name, description, metadata, tags
+		SELECT id, driver, name, description, metadata, tags, external_id, version
 		FROM devices 
 		ORDER BY name
 	`
@@ -475,13 +505,52 @@ func (s *Storer) ListDevices(ctx context.Context) ([]*api.Device, error) {
 	}
 	defer rows.Close()
 
+	return scanDevices(rows)
+}
+
+// ListDevicesPage retrieves a page of devices, ordered by name, and the cursor of the
+// next page
+func (s *Storer) ListDevicesPage(ctx context.Context, page Page) ([]*api.Device, string, error) {
+	ll := s.logCtx(ctx, "device")
+	ll.Debug().Int("limit", page.Limit).Msg("listing page of devices")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT id, driver, name, description, metadata, tags, external_id, version
+		FROM devices
+		WHERE ($1 = '' OR (name, id) > ($2, $1))
+			AND ($4::jsonb = '{}' OR metadata @> $4::jsonb)
+		ORDER BY name, id
+		LIMIT $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, after.ID, after.Name, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices, err := scanDevices(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	devices, next := nextCursor(devices, page, devicePageKey)
+	return devices, next, nil
+}
+
+func scanDevices(rows *sql.Rows) ([]*api.Device, error) {
 	var devices []*api.Device
 	for rows.Next() {
 		var dev api.Device
 		var metadataJSON []byte
 		var tags []string
 
-		err := rows.Scan(&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags))
+		err := rows.Scan(&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags), &dev.ExternalID, &dev.Version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan device: %w", err)
 		}
@@ -500,14 +569,17 @@ func (s *Storer) ListDevices(ctx context.Context) ([]*api.Device, error) {
 	return devices, rows.Err()
 }
 
-// GetDeviceByTag retrieves a device with a specific tag
+// GetDeviceByTag retrieves a device with a specific tag, falling back to resolving the
+// tag as an alias when no device carries it
 func (s *Storer) GetDeviceByTag(ctx context.Context, tag string) (*api.Device, error) {
 	ll := s.logCtx(ctx, "device")
 	ll.Debug().Str("tag", tag).Msg("getting device by tag")
 	query := `
-		SELECT id, driver, name, description, metadata, tags
+		SELECT id, driver, name, description, metadata, tags, external_id, version
 		FROM devices 
 		WHERE $1 = ANY(tags)
+			OR (SELECT target FROM tag_aliases WHERE alias = $1) = ANY(tags)
+		ORDER BY $1 = ANY(tags) DESC
 		LIMIT 1
 	`
 
@@ -516,7 +588,7 @@ func (s *Storer) GetDeviceByTag(ctx context.Context, tag string) (*api.Device, e
 	var tags []string
 
 	err := s.db.QueryRowContext(ctx, query, tag).Scan(
-		&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags),
+		&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags), &dev.ExternalID, &dev.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -540,7 +612,7 @@ func (s *Storer) ListDevicesByTagPrefix(ctx context.Context, prefix string) ([]*
 	ll := s.logCtx(ctx, "device")
 	ll.Debug().Str("prefix", prefix).Msg("listing devices by tag prefix")
 	query := `
-		SELECT DISTINCT id, driver, name, description, metadata, tags
+		SELECT DISTINCT id, driver, name, description, metadata, tags, external_id, version
 		FROM devices, unnest(tags) AS tag
 		WHERE tag LIKE $1
 		ORDER BY name
@@ -563,7 +635,7 @@ func (s *Storer) scanDevices(rows *sql.Rows) ([]*api.Device, error) {
 		var metadataJSON []byte
 		var tags []string
 
-		err := rows.Scan(&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags))
+		err := rows.Scan(&dev.ID, &dev.Driver, &dev.Name, &dev.Description, &metadataJSON, pq.Array(&tags), &dev.ExternalID, &dev.Version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan device: %w", err)
 		}
@@ -594,12 +666,16 @@ func (s *Storer) createSensor(ctx context.Context, exec execer, sensor *api.Sens
 	if len(sensor.Tags) == 0 {
 		sensor.Tags = []string{sensor.DefaultTag(sensor.DeviceID)}
 	}
+	if err := ensureExternalID(&sensor.ExternalID); err != nil {
+		return err
+	}
+	sensor.Version = 1
 
 	query := `
-		INSERT INTO sensors (id, device_id, name, sensor_type, metadata, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		INSERT INTO sensors (id, device_id, name, sensor_type, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
 	`
-	_, err = exec.ExecContext(ctx, query, sensor.ID, sensor.DeviceID, sensor.Name, sensor.SensorType, metadata, pq.Array(sensor.Tags))
+	_, err = exec.ExecContext(ctx, query, sensor.ID, sensor.DeviceID, sensor.Name, sensor.SensorType, metadata, pq.Array(sensor.Tags), sensor.ExternalID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			if pqErr.Code == "23505" { // unique_violation
@@ -616,7 +692,12 @@ func (s *Storer) createSensor(ctx context.Context, exec execer, sensor *api.Sens
 func (s *Storer) CreateSensor(ctx context.Context, sensor *api.Sensor) error {
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("device_id", sensor.DeviceID).Str("sensor_id", sensor.ID).Str("sensor_type", string(sensor.SensorType)).Msg("creating sensor")
-	return s.createSensor(ctx, s.db, sensor)
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, func(tx *sql.Tx) error {
+		if err := tagSensor(ctx, tx, sensor); err != nil {
+			return err
+		}
+		return s.createSensor(ctx, tx, sensor)
+	})
 }
 
 // GetSensor retrieves a sensor by device ID and sensor ID
@@ -624,7 +705,7 @@ func (s *Storer) GetSensor(ctx context.Context, deviceID, sensorID string) (*api
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("getting sensor")
 	query := `
-		SELECT id, device_id, name, sensor_type, metadata, tags
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors 
 		WHERE device_id = $1 AND id = $2
 	`
@@ -634,7 +715,7 @@ func (s *Storer) GetSensor(ctx context.Context, deviceID, sensorID string) (*api
 	var tags []string
 
 	err := s.db.QueryRowContext(ctx, query, deviceID, sensorID).Scan(
-		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags),
+		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags), &sensor.ExternalID, &sensor.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -664,28 +745,25 @@ func (s *Storer) UpdateSensor(ctx context.Context, sensor *api.Sensor) error {
 
 	query := `
 		UPDATE sensors 
-		SET name = $3, sensor_type = $4, metadata = $5, tags = $6, updated_at = NOW()
-		WHERE device_id = $1 AND id = $2
+		SET name = $3, sensor_type = $4, metadata = $5, tags = $6, updated_at = NOW(), version = version + 1
+		WHERE device_id = $1 AND id = $2 AND version = $7
+		RETURNING version
 	`
-	result, err := s.db.ExecContext(ctx, query, sensor.DeviceID, sensor.ID, sensor.Name, sensor.SensorType, metadata, pq.Array(sensor.Tags))
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{sensor.DeviceID, sensor.ID}, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, sensor.DeviceID, sensor.ID, sensor.Name, sensor.SensorType, metadata, pq.Array(sensor.Tags), sensor.Version).Scan(&sensor.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx, `SELECT version FROM sensors WHERE device_id = $1 AND id = $2`, fmt.Sprintf("sensor %s/%s", sensor.DeviceID, sensor.ID), sensor.DeviceID, sensor.ID)
+		}
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == "23505" { // unique_violation
+					return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+				}
 			}
+			return fmt.Errorf("failed to update sensor: %w", err)
 		}
-		return fmt.Errorf("failed to update sensor: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rows == 0 {
-		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, sensor.DeviceID, sensor.ID)
-	}
-
-	return nil
+		return nil
+	})
 }
 
 // DeleteSensor deletes a sensor by device ID and sensor ID
@@ -693,20 +771,13 @@ func (s *Storer) DeleteSensor(ctx context.Context, deviceID, sensorID string) er
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting sensor")
 	query := `DELETE FROM sensors WHERE device_id = $1 AND id = $2`
-	result, err := s.db.ExecContext(ctx, query, deviceID, sensorID)
-	if err != nil {
-		return fmt.Errorf("failed to delete sensor: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rows == 0 {
-		return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, deviceID, sensorID)
-	}
-
-	return nil
+	return s.auditedTx(ctx, api.AuditEntitySensor, []string{deviceID, sensorID}, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, deviceID, sensorID)
+		if err != nil {
+			return fmt.Errorf("failed to delete sensor: %w", err)
+		}
+		return expectRow(result, "sensor %s/%s", deviceID, sensorID)
+	})
 }
 
 // ListSensors retrieves all sensors
@@ -714,7 +785,7 @@ func (s *Storer) ListSensors(ctx context.Context) ([]*api.Sensor, error) {
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Msg("listing all sensors")
 	query := `
-		SELECT id, device_id, name, sensor_type, metadata, tags
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors 
 		ORDER BY name
 	`
@@ -728,12 +799,48 @@ func (s *Storer) ListSensors(ctx context.Context) ([]*api.Sensor, error) {
 	return s.scanSensors(rows)
 }
 
+// ListSensorsPage retrieves a page of sensors, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the sensors of every device.
+func (s *Storer) ListSensorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Sensor, string, error) {
+	ll := s.logCtx(ctx, "sensor")
+	ll.Debug().Str("device_id", deviceID).Int("limit", page.Limit).Msg("listing page of sensors")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
+		FROM sensors
+		WHERE ($1 = '' OR device_id = $1)
+			AND ($2 = '' OR (name, device_id, id) > ($3, $4, $2))
+			AND ($6::jsonb = '{}' OR metadata @> $6::jsonb)
+		ORDER BY name, device_id, id
+		LIMIT $5
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, deviceID, after.ID, after.Name, after.DeviceID, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors, err := s.scanSensors(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	sensors, next := nextCursor(sensors, page, sensorPageKey)
+	return sensors, next, nil
+}
+
 // ListSensorsByDeviceID retrieves all sensors for a device
 func (s *Storer) ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Sensor, error) {
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("device_id", deviceID).Msg("listing sensors by device")
 	query := `
-		SELECT id, device_id, name, sensor_type, metadata, tags
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors 
 		WHERE device_id = $1
 		ORDER BY name
@@ -748,14 +855,17 @@ func (s *Storer) ListSensorsByDeviceID(ctx context.Context, deviceID string) ([]
 	return s.scanSensors(rows)
 }
 
-// GetSensorByTag retrieves a sensor with a specific tag
+// GetSensorByTag retrieves a sensor with a specific tag, falling back to resolving the
+// tag as an alias when no sensor carries it
 func (s *Storer) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, error) {
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("tag", tag).Msg("getting sensor by tag")
 	query := `
-		SELECT id, device_id, name, sensor_type, metadata, tags
+		SELECT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors 
 		WHERE $1 = ANY(tags)
+			OR (SELECT target FROM tag_aliases WHERE alias = $1) = ANY(tags)
+		ORDER BY $1 = ANY(tags) DESC
 		LIMIT 1
 	`
 
@@ -764,7 +874,7 @@ func (s *Storer) GetSensorByTag(ctx context.Context, tag string) (*api.Sensor, e
 	var tags []string
 
 	err := s.db.QueryRowContext(ctx, query, tag).Scan(
-		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags),
+		&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags), &sensor.ExternalID, &sensor.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -788,7 +898,7 @@ func (s *Storer) ListSensorsByTagPrefix(ctx context.Context, prefix string) ([]*
 	ll := s.logCtx(ctx, "sensor")
 	ll.Debug().Str("prefix", prefix).Msg("listing sensors by tag prefix")
 	query := `
-		SELECT DISTINCT id, device_id, name, sensor_type, metadata, tags
+		SELECT DISTINCT id, device_id, name, sensor_type, metadata, tags, external_id, version
 		FROM sensors, unnest(tags) AS tag
 		WHERE tag LIKE $1
 		ORDER BY name
@@ -811,7 +921,7 @@ func (s *Storer) scanSensors(rows *sql.Rows) ([]*api.Sensor, error) {
 		var metadataJSON []byte
 		var tags []string
 
-		err := rows.Scan(&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags))
+		err := rows.Scan(&sensor.ID, &sensor.DeviceID, &sensor.Name, &sensor.SensorType, &metadataJSON, pq.Array(&tags), &sensor.ExternalID, &sensor.Version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan sensor: %w", err)
 		}
@@ -842,12 +952,16 @@ func (s *Storer) createActuator(ctx context.Context, exec execer, actuator *api.
 	if len(actuator.Tags) == 0 {
 		actuator.Tags = []string{actuator.DefaultTag(actuator.DeviceID)}
 	}
+	if err := ensureExternalID(&actuator.ExternalID); err != nil {
+		return err
+	}
+	actuator.Version = 1
 
 	query := `
-		INSERT INTO actuators (id, device_id, name, actuator_type, metadata, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		INSERT INTO actuators (id, device_id, name, actuator_type, metadata, tags, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
 	`
-	_, err = exec.ExecContext(ctx, query, actuator.ID, actuator.DeviceID, actuator.Name, actuator.ActuatorType, metadata, pq.Array(actuator.Tags))
+	_, err = exec.ExecContext(ctx, query, actuator.ID, actuator.DeviceID, actuator.Name, actuator.ActuatorType, metadata, pq.Array(actuator.Tags), actuator.ExternalID)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
 			if pqErr.Code == "23505" { // unique_violation
@@ -864,7 +978,12 @@ func (s *Storer) createActuator(ctx context.Context, exec execer, actuator *api.
 func (s *Storer) CreateActuator(ctx context.Context, actuator *api.Actuator) error {
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("device_id", actuator.DeviceID).Str("actuator_id", actuator.ID).Str("actuator_type", string(actuator.ActuatorType)).Msg("creating actuator")
-	return s.createActuator(ctx, s.db, actuator)
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, func(tx *sql.Tx) error {
+		if err := tagActuator(ctx, tx, actuator); err != nil {
+			return err
+		}
+		return s.createActuator(ctx, tx, actuator)
+	})
 }
 
 // GetActuator retrieves an actuator by device ID and actuator ID
@@ -872,7 +991,7 @@ func (s *Storer) GetActuator(ctx context.Context, deviceID, actuatorID string) (
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("getting actuator")
 	query := `
-		SELECT id, device_id, name, actuator_type, metadata, tags
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators 
 		WHERE device_id = $1 AND id = $2
 	`
@@ -882,7 +1001,7 @@ func (s *Storer) GetActuator(ctx context.Context, deviceID, actuatorID string) (
 	var tags []string
 
 	err := s.db.QueryRowContext(ctx, query, deviceID, actuatorID).Scan(
-		&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags),
+		&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags), &actuator.ExternalID, &actuator.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -912,28 +1031,25 @@ func (s *Storer) UpdateActuator(ctx context.Context, actuator *api.Actuator) err
 
 	query := `
 		UPDATE actuators 
-		SET name = $3, actuator_type = $4, metadata = $5, tags = $6, updated_at = NOW()
-		WHERE device_id = $1 AND id = $2
+		SET name = $3, actuator_type = $4, metadata = $5, tags = $6, updated_at = NOW(), version = version + 1
+		WHERE device_id = $1 AND id = $2 AND version = $7
+		RETURNING version
 	`
-	result, err := s.db.ExecContext(ctx, query, actuator.DeviceID, actuator.ID, actuator.Name, actuator.ActuatorType, metadata, pq.Array(actuator.Tags))
-	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" { // unique_violation
-				return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{actuator.DeviceID, actuator.ID}, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, actuator.DeviceID, actuator.ID, actuator.Name, actuator.ActuatorType, metadata, pq.Array(actuator.Tags), actuator.Version).Scan(&actuator.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return staleOrMissing(ctx, tx, `SELECT version FROM actuators WHERE device_id = $1 AND id = $2`, fmt.Sprintf("actuator %s/%s", actuator.DeviceID, actuator.ID), actuator.DeviceID, actuator.ID)
+		}
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok {
+				if pqErr.Code == "23505" { // unique_violation
+					return fmt.Errorf("%w: tag conflict", ErrAlreadyExists)
+				}
 			}
+			return fmt.Errorf("failed to update actuator: %w", err)
 		}
-		return fmt.Errorf("failed to update actuator: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rows == 0 {
-		return fmt.Errorf("%w: actuator %s/%s", ErrNotFound, actuator.DeviceID, actuator.ID)
-	}
-
-	return nil
+		return nil
+	})
 }
 
 // DeleteActuator deletes an actuator by device ID and actuator ID
@@ -941,20 +1057,13 @@ func (s *Storer) DeleteActuator(ctx context.Context, deviceID, actuatorID string
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("device_id", deviceID).Str("actuator_id", actuatorID).Msg("deleting actuator")
 	query := `DELETE FROM actuators WHERE device_id = $1 AND id = $2`
-	result, err := s.db.ExecContext(ctx, query, deviceID, actuatorID)
-	if err != nil {
-		return fmt.Errorf("failed to delete actuator: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rows == 0 {
-		return fmt.Errorf("%w: actuator %s/%s", ErrNotFound, deviceID, actuatorID)
-	}
-
-	return nil
+	return s.auditedTx(ctx, api.AuditEntityActuator, []string{deviceID, actuatorID}, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, deviceID, actuatorID)
+		if err != nil {
+			return fmt.Errorf("failed to delete actuator: %w", err)
+		}
+		return expectRow(result, "actuator %s/%s", deviceID, actuatorID)
+	})
 }
 
 // ListActuators retrieves all actuators
@@ -962,7 +1071,7 @@ func (s *Storer) ListActuators(ctx context.Context) ([]*api.Actuator, error) {
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Msg("listing all actuators")
 	query := `
-		SELECT id, device_id, name, actuator_type, metadata, tags
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators 
 		ORDER BY name
 	`
@@ -976,12 +1085,48 @@ func (s *Storer) ListActuators(ctx context.Context) ([]*api.Actuator, error) {
 	return s.scanActuators(rows)
 }
 
+// ListActuatorsPage retrieves a page of actuators, ordered by name, and the cursor of the
+// next page. An empty deviceID lists the actuators of every device.
+func (s *Storer) ListActuatorsPage(ctx context.Context, deviceID string, page Page) ([]*api.Actuator, string, error) {
+	ll := s.logCtx(ctx, "actuator")
+	ll.Debug().Str("device_id", deviceID).Int("limit", page.Limit).Msg("listing page of actuators")
+	after, err := parseCursor(page)
+	if err != nil {
+		return nil, "", err
+	}
+	if after == nil {
+		after = &pageKey{}
+	}
+	query := `
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
+		FROM actuators
+		WHERE ($1 = '' OR device_id = $1)
+			AND ($2 = '' OR (name, device_id, id) > ($3, $4, $2))
+			AND ($6::jsonb = '{}' OR metadata @> $6::jsonb)
+		ORDER BY name, device_id, id
+		LIMIT $5
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, deviceID, after.ID, after.Name, after.DeviceID, pageLimit(page), metadataFilter(page))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query actuators: %w", err)
+	}
+	defer rows.Close()
+
+	actuators, err := s.scanActuators(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	actuators, next := nextCursor(actuators, page, actuatorPageKey)
+	return actuators, next, nil
+}
+
 // ListActuatorsByDeviceID retrieves all actuators for a device
 func (s *Storer) ListActuatorsByDeviceID(ctx context.Context, deviceID string) ([]*api.Actuator, error) {
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("device_id", deviceID).Msg("listing actuators by device")
 	query := `
-		SELECT id, device_id, name, actuator_type, metadata, tags
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators 
 		WHERE device_id = $1
 		ORDER BY name
@@ -996,14 +1141,17 @@ func (s *Storer) ListActuatorsByDeviceID(ctx context.Context, deviceID string) (
 	return s.scanActuators(rows)
 }
 
-// GetActuatorByTag retrieves an actuator with a specific tag
+// GetActuatorByTag retrieves an actuator with a specific tag, falling back to resolving the
+// tag as an alias when no actuator carries it
 func (s *Storer) GetActuatorByTag(ctx context.Context, tag string) (*api.Actuator, error) {
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("tag", tag).Msg("getting actuator by tag")
 	query := `
-		SELECT id, device_id, name, actuator_type, metadata, tags
+		SELECT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators 
 		WHERE $1 = ANY(tags)
+			OR (SELECT target FROM tag_aliases WHERE alias = $1) = ANY(tags)
+		ORDER BY $1 = ANY(tags) DESC
 		LIMIT 1
 	`
 
@@ -1012,7 +1160,7 @@ func (s *Storer) GetActuatorByTag(ctx context.Context, tag string) (*api.Actuato
 	var tags []string
 
 	err := s.db.QueryRowContext(ctx, query, tag).Scan(
-		&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags),
+		&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags), &actuator.ExternalID, &actuator.Version,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1036,7 +1184,7 @@ func (s *Storer) ListActuatorsByTagPrefix(ctx context.Context, prefix string) ([
 	ll := s.logCtx(ctx, "actuator")
 	ll.Debug().Str("prefix", prefix).Msg("listing actuators by tag prefix")
 	query := `
-		SELECT DISTINCT id, device_id, name, actuator_type, metadata, tags
+		SELECT DISTINCT id, device_id, name, actuator_type, metadata, tags, external_id, version
 		FROM actuators, unnest(tags) AS tag
 		WHERE tag LIKE $1
 		ORDER BY name
@@ -1059,7 +1207,7 @@ func (s *Storer) scanActuators(rows *sql.Rows) ([]*api.Actuator, error) {
 		var metadataJSON []byte
 		var tags []string
 
-		err := rows.Scan(&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags))
+		err := rows.Scan(&actuator.ID, &actuator.DeviceID, &actuator.Name, &actuator.ActuatorType, &metadataJSON, pq.Array(&tags), &actuator.ExternalID, &actuator.Version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan actuator: %w", err)
 		}
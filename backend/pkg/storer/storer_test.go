@@ -2,9 +2,11 @@ package storer
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"lifesupport/backend/pkg/api"
 )
@@ -28,8 +30,8 @@ func setupTestDB(t *testing.T) *Storer {
 	}
 
 	ctx := context.Background()
-	if err := store.InitSchema(ctx); err != nil {
-		t.Fatalf("Failed to initialize schema: %v", err)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Failed to migrate schema: %v", err)
 	}
 
 	return store
@@ -61,7 +63,84 @@ func TestNew(t *testing.T) {
 	}
 }
 
-func TestInitSchema(t *testing.T) {
+func TestPoolOptions(t *testing.T) {
+	s := &Storer{}
+	for _, opt := range []Option{WithMaxOpenConns(7), WithPoolStatsInterval(time.Minute)} {
+		opt(s)
+	}
+	// Opening doesn't connect, so the pool can be checked without a database.
+	db, err := sql.Open("postgres", getTestConnString())
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	for _, configure := range s.pool {
+		configure(db)
+	}
+	if got := db.Stats().MaxOpenConnections; got != 7 {
+		t.Errorf("Expected max open connections 7, got %d", got)
+	}
+	if s.statsInterval != time.Minute {
+		t.Errorf("Expected stats interval 1m, got %s", s.statsInterval)
+	}
+}
+
+// TestTimescale converts sensor_readings in the database at TEST_TIMESCALE_CONN, which
+// must have the timescaledb extension available. Conversion can't be undone, so it never
+// runs against the shared test database.
+func TestTimescale(t *testing.T) {
+	connStr := os.Getenv("TEST_TIMESCALE_CONN")
+	if connStr == "" {
+		t.Skip("TEST_TIMESCALE_CONN not set")
+	}
+	store, err := New(connStr, WithTimescale(TimescaleConfig{ChunkInterval: 24 * time.Hour, CompressAfter: 7 * 24 * time.Hour}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer cleanupTestDB(t, store)
+
+	ctx := context.Background()
+	// Migrating again finds the hypertable and only refreshes its settings
+	for i := 0; i < 2; i++ {
+		if err := store.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate() error = %v", err)
+		}
+	}
+	var policies int
+	err = store.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM timescaledb_information.jobs
+		WHERE hypertable_name = 'sensor_readings' AND proc_name = 'policy_compression'
+	`).Scan(&policies)
+	if err != nil {
+		t.Fatalf("Failed to list compression policies: %v", err)
+	}
+	if policies != 1 {
+		t.Errorf("Expected one compression policy, got %d", policies)
+	}
+
+	report, err := store.CheckSchema(ctx)
+	if err != nil {
+		t.Fatalf("CheckSchema() error = %v", err)
+	}
+	if len(report.Diffs) != 0 {
+		t.Errorf("Expected the hypertable to match the expected schema, got %v", report.Diffs)
+	}
+
+	dev := &api.Device{ID: "ts-dev", Driver: api.DriverShelly, Name: "Timescale", Sensors: []*api.Sensor{{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature}}}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	rec := &api.ReadingRecord{DeviceID: "ts-dev", SensorID: "temp", Reading: api.SensorReading{Value: 25.5, Unit: api.UnitCelsius, Valid: true, Timestamp: time.Now().UTC()}}
+	if err := store.StoreSensorReading(ctx, rec); err != nil {
+		t.Fatalf("StoreSensorReading() error = %v", err)
+	}
+	latest, err := store.GetLatestSensorReading(ctx, "ts-dev", "temp")
+	if err != nil || latest.Value != 25.5 {
+		t.Errorf("Expected the stored reading back, got %+v, %v", latest, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
 	store := setupTestDB(t)
 	defer cleanupTestDB(t, store)
 
@@ -118,6 +197,53 @@ func TestCreateAndGetDevice(t *testing.T) {
 	}
 }
 
+func TestWithTx(t *testing.T) {
+	store := setupTestDB(t)
+	defer cleanupTestDB(t, store)
+
+	ctx := context.Background()
+	dev := &api.Device{ID: "tx-device", Driver: api.DriverShelly, Name: "Tx Device"}
+	relay := &api.Actuator{DeviceID: dev.ID, ID: "relay", Name: "Relay", ActuatorType: api.ActuatorTypeRelay}
+
+	// A failure rolls back everything fn did
+	errAbort := fmt.Errorf("abort")
+	err := store.WithTx(ctx, func(tx *Storer) error {
+		if err := tx.CreateDevice(ctx, dev); err != nil {
+			return err
+		}
+		if err := tx.CreateActuator(ctx, relay); err != nil {
+			return err
+		}
+		if _, err := tx.GetActuator(ctx, dev.ID, relay.ID); err != nil {
+			t.Errorf("Expected the actuator visible within the transaction, got %v", err)
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("WithTx() error = %v, want %v", err, errAbort)
+	}
+	if _, err := store.GetDevice(ctx, dev.ID); err == nil {
+		t.Error("Expected the device rolled back")
+	}
+
+	// A failed method is undone alone, leaving the transaction usable
+	err = store.WithTx(ctx, func(tx *Storer) error {
+		if err := tx.CreateDevice(ctx, dev); err != nil {
+			return err
+		}
+		if err := tx.CreateDevice(ctx, dev); err == nil {
+			t.Error("Expected creating the device twice to fail")
+		}
+		return tx.CreateActuator(ctx, relay)
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if _, err := store.GetActuator(ctx, dev.ID, relay.ID); err != nil {
+		t.Errorf("Expected the actuator committed, got %v", err)
+	}
+}
+
 func TestUpdateDevice(t *testing.T) {
 	store := setupTestDB(t)
 	defer cleanupTestDB(t, store)
@@ -360,3 +486,54 @@ func TestCreateDeviceWithNestedSensorsAndActuators(t *testing.T) {
 		t.Errorf("ListActuatorsByDeviceID() after device delete returned %d actuators, want 0", len(actuators))
 	}
 }
+
+// BenchmarkHotReads compares the hot read paths with and without prepared statements,
+// read concurrently as workflows do under worker load
+func BenchmarkHotReads(b *testing.B) {
+	ctx := context.Background()
+	for _, prepared := range []bool{false, true} {
+		store, err := New(getTestConnString(), WithPreparedStatements(prepared))
+		if err != nil {
+			b.Fatalf("Failed to connect to test database: %v", err)
+		}
+		if err := store.Migrate(ctx); err != nil {
+			b.Fatalf("Failed to migrate schema: %v", err)
+		}
+		dev := &api.Device{
+			ID:      "bench-device",
+			Driver:  api.DriverShelly,
+			Name:    "Bench Device",
+			Sensors: []*api.Sensor{{ID: "temp", Name: "Temp", SensorType: api.SensorTypeTemperature}},
+		}
+		_ = store.DeleteDevice(ctx, dev.ID)
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			b.Fatalf("CreateDevice() error = %v", err)
+		}
+		rec := &api.ReadingRecord{DeviceID: dev.ID, SensorID: "temp", Reading: api.SensorReading{Value: 25, Unit: api.UnitCelsius, Valid: true, Timestamp: time.Now()}}
+		if err := store.StoreSensorReadings(ctx, []*api.ReadingRecord{rec}); err != nil {
+			b.Fatalf("StoreSensorReadings() error = %v", err)
+		}
+
+		b.Run(fmt.Sprintf("GetDevice/prepared=%v", prepared), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := store.GetDevice(ctx, dev.ID); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+		b.Run(fmt.Sprintf("GetLatestSensorReading/prepared=%v", prepared), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := store.GetLatestSensorReading(ctx, dev.ID, "temp"); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+		})
+
+		_ = store.DeleteDevice(ctx, dev.ID)
+		store.Close()
+	}
+}
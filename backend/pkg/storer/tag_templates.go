@@ -0,0 +1,168 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// SetTagTemplate creates the template of its kind or replaces an existing one, setting
+// template.UpdatedAt. Entities created from then on are tagged by it; existing tags stay.
+func (s *Storer) SetTagTemplate(ctx context.Context, template *api.TagTemplate) error {
+	ll := s.logCtx(ctx, "tags")
+	ll.Info().Str("kind", string(template.Kind)).Str("template", template.Template).Msg("setting tag template")
+	query := `
+		INSERT INTO tag_templates (kind, template, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (kind) DO UPDATE SET
+			template = EXCLUDED.template,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`
+	err := s.db.QueryRowContext(ctx, query, template.Kind, template.Template).Scan(&template.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set tag template: %w", err)
+	}
+	return nil
+}
+
+// DeleteTagTemplate deletes the template of a kind, restoring the built-in default tags
+func (s *Storer) DeleteTagTemplate(ctx context.Context, kind api.TagKind) error {
+	ll := s.logCtx(ctx, "tags")
+	ll.Info().Str("kind", string(kind)).Msg("deleting tag template")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM tag_templates WHERE kind = $1`, kind)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag template: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: tag template %s", ErrNotFound, kind)
+	}
+	return nil
+}
+
+// ListTagTemplates retrieves all tag templates, ordered by kind
+func (s *Storer) ListTagTemplates(ctx context.Context) ([]*api.TagTemplate, error) {
+	return listTagTemplates(ctx, s.db)
+}
+
+func listTagTemplates(ctx context.Context, db queryer) ([]*api.TagTemplate, error) {
+	rows, err := db.QueryContext(ctx, `SELECT kind, template, updated_at FROM tag_templates ORDER BY kind`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []*api.TagTemplate
+	for rows.Next() {
+		var t api.TagTemplate
+		if err := rows.Scan(&t.Kind, &t.Template, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag template: %w", err)
+		}
+		templates = append(templates, &t)
+	}
+	return templates, rows.Err()
+}
+
+// loadTagTemplates reads the tag templates within tx, for the default tags of the
+// entities it creates. The SQL stores share it.
+func loadTagTemplates(ctx context.Context, tx *sql.Tx) (api.TagTemplates, error) {
+	list, err := listTagTemplates(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	templates := make(api.TagTemplates, len(list))
+	for _, t := range list {
+		templates[t.Kind] = t.Template
+	}
+	return templates, nil
+}
+
+// componentDeviceMetadata reads the metadata of device deviceID within tx when templates
+// tag components of kind by a template, which may need it. A missing device has none;
+// creating its component fails anyway.
+func componentDeviceMetadata(ctx context.Context, tx *sql.Tx, templates api.TagTemplates, kind api.TagKind, deviceID string) (map[string]string, error) {
+	if templates[kind] == "" {
+		return nil, nil
+	}
+	var raw sql.NullString
+	err := tx.QueryRowContext(ctx, `SELECT metadata FROM devices WHERE id = $1`, deviceID).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read device metadata: %w", err)
+	}
+	var metadata map[string]string
+	if raw.Valid && raw.String != "" {
+		if err := json.Unmarshal([]byte(raw.String), &metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	return metadata, nil
+}
+
+// tagSensor gives a sensor created within tx without tags its default tag
+func tagSensor(ctx context.Context, tx *sql.Tx, sensor *api.Sensor) error {
+	if len(sensor.Tags) > 0 {
+		return nil
+	}
+	templates, err := loadTagTemplates(ctx, tx)
+	if err != nil {
+		return err
+	}
+	metadata, err := componentDeviceMetadata(ctx, tx, templates, api.TagKindSensor, sensor.DeviceID)
+	if err != nil {
+		return err
+	}
+	sensor.Tags = []string{templates.SensorTag(sensor, metadata)}
+	return nil
+}
+
+// tagActuator gives an actuator created within tx without tags its default tag
+func tagActuator(ctx context.Context, tx *sql.Tx, actuator *api.Actuator) error {
+	if len(actuator.Tags) > 0 {
+		return nil
+	}
+	templates, err := loadTagTemplates(ctx, tx)
+	if err != nil {
+		return err
+	}
+	metadata, err := componentDeviceMetadata(ctx, tx, templates, api.TagKindActuator, actuator.DeviceID)
+	if err != nil {
+		return err
+	}
+	actuator.Tags = []string{templates.ActuatorTag(actuator, metadata)}
+	return nil
+}
+
+// tagDevice gives a device created or updated within tx its default tag, and its
+// untagged sensors and actuators theirs
+func tagDevice(ctx context.Context, tx *sql.Tx, dev *api.Device) error {
+	templates, err := loadTagTemplates(ctx, tx)
+	if err != nil {
+		return err
+	}
+	dev.EnsureDefaultTag(templates)
+	for _, sensor := range dev.Sensors {
+		sensor.DeviceID = dev.ID
+		if len(sensor.Tags) == 0 {
+			sensor.Tags = []string{templates.SensorTag(sensor, dev.Metadata)}
+		}
+	}
+	for _, actuator := range dev.Actuators {
+		actuator.DeviceID = dev.ID
+		if len(actuator.Tags) == 0 {
+			actuator.Tags = []string{templates.ActuatorTag(actuator, dev.Metadata)}
+		}
+	}
+	return nil
+}
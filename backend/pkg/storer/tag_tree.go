@@ -0,0 +1,112 @@
+package storer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// tagTree builds the tag hierarchy at and beneath prefix, or the whole hierarchy if
+// prefix is empty, from the tags of every device, sensor and actuator by kind
+func tagTree(prefix string, entities map[api.AuditEntityType][]taggedEntity) *api.TagNode {
+	root := &api.TagNode{Tag: prefix, Children: []*api.TagNode{}}
+	if i := strings.LastIndex(prefix, "."); i >= 0 {
+		root.Name = prefix[i+1:]
+	} else {
+		root.Name = prefix
+	}
+
+	for typ, list := range entities {
+		for _, e := range list {
+			// Nodes this entity has already been counted at
+			counted := map[*api.TagNode]bool{}
+			for _, tag := range e.tags {
+				var rest string
+				switch {
+				case prefix == "":
+					rest = tag
+				case tag == prefix:
+				case strings.HasPrefix(tag, prefix+"."):
+					rest = tag[len(prefix)+1:]
+				default:
+					continue
+				}
+
+				node := root
+				count(node, typ, counted)
+				if rest == "" {
+					continue
+				}
+				for _, seg := range strings.Split(rest, ".") {
+					node = child(node, seg)
+					count(node, typ, counted)
+				}
+			}
+		}
+	}
+	sortTagTree(root)
+	return root
+}
+
+// child returns the node named seg beneath node, adding it if need be
+func child(node *api.TagNode, seg string) *api.TagNode {
+	for _, c := range node.Children {
+		if c.Name == seg {
+			return c
+		}
+	}
+	tag := seg
+	if node.Tag != "" {
+		tag = node.Tag + "." + seg
+	}
+	c := &api.TagNode{Name: seg, Tag: tag, Children: []*api.TagNode{}}
+	node.Children = append(node.Children, c)
+	return c
+}
+
+func count(node *api.TagNode, typ api.AuditEntityType, counted map[*api.TagNode]bool) {
+	if counted[node] {
+		return
+	}
+	counted[node] = true
+	switch typ {
+	case api.AuditEntityDevice:
+		node.Counts.Devices++
+	case api.AuditEntitySensor:
+		node.Counts.Sensors++
+	case api.AuditEntityActuator:
+		node.Counts.Actuators++
+	}
+}
+
+func sortTagTree(node *api.TagNode) {
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Name < node.Children[j].Name })
+	for _, c := range node.Children {
+		sortTagTree(c)
+	}
+}
+
+// ListTagTree returns the tag hierarchy at and beneath prefix, or the whole hierarchy if
+// prefix is empty, with how many devices, sensors and actuators are tagged at or beneath
+// each level. Tags are read in one transaction, so the counts are consistent.
+func (s *Storer) ListTagTree(ctx context.Context, prefix string) (*api.TagNode, error) {
+	ll := s.logCtx(ctx, "tag")
+	ll.Debug().Str("prefix", prefix).Msg("listing tag tree")
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	entities := make(map[api.AuditEntityType][]taggedEntity, len(retagTables))
+	for _, t := range retagTables {
+		if entities[t.typ], err = s.taggedEntities(ctx, tx.Tx, t.table, t.keys); err != nil {
+			return nil, err
+		}
+	}
+	return tagTree(prefix, entities), nil
+}
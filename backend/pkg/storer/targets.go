@@ -0,0 +1,114 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+
+	"github.com/lib/pq"
+)
+
+const targetColumns = `device_id, sensor_id, ideal_min, ideal_max, warning_min, warning_max, critical_min, critical_max`
+
+// SetSensorTarget creates or replaces a sensor's target range
+func (s *Storer) SetSensorTarget(ctx context.Context, target *api.TargetRange) error {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", target.DeviceID).Str("sensor_id", target.SensorID).Msg("setting sensor target")
+	query := `
+		INSERT INTO sensor_targets (` + targetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (device_id, sensor_id) DO UPDATE SET
+			ideal_min = EXCLUDED.ideal_min, ideal_max = EXCLUDED.ideal_max,
+			warning_min = EXCLUDED.warning_min, warning_max = EXCLUDED.warning_max,
+			critical_min = EXCLUDED.critical_min, critical_max = EXCLUDED.critical_max,
+			updated_at = NOW()
+	`
+	_, err := s.db.ExecContext(ctx, query, target.DeviceID, target.SensorID,
+		target.Ideal.Min, target.Ideal.Max, target.Warning.Min, target.Warning.Max, target.Critical.Min, target.Critical.Max)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			if pqErr.Code == "23503" { // foreign_key_violation
+				return fmt.Errorf("%w: sensor %s/%s", ErrNotFound, target.DeviceID, target.SensorID)
+			}
+		}
+		return fmt.Errorf("failed to set sensor target: %w", err)
+	}
+	return nil
+}
+
+// GetSensorTarget retrieves a sensor's target range
+func (s *Storer) GetSensorTarget(ctx context.Context, deviceID, sensorID string) (*api.TargetRange, error) {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("getting sensor target")
+	row := s.db.QueryRowContext(ctx, `SELECT `+targetColumns+` FROM sensor_targets WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	target, err := scanTarget(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: target for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor target: %w", err)
+	}
+	return target, nil
+}
+
+// DeleteSensorTarget removes a sensor's target range
+func (s *Storer) DeleteSensorTarget(ctx context.Context, deviceID, sensorID string) error {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Str("device_id", deviceID).Str("sensor_id", sensorID).Msg("deleting sensor target")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sensor_targets WHERE device_id = $1 AND sensor_id = $2`, deviceID, sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor target: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: target for sensor %s/%s", ErrNotFound, deviceID, sensorID)
+	}
+	return nil
+}
+
+// ListSensorTargets retrieves all target ranges, ordered by sensor
+func (s *Storer) ListSensorTargets(ctx context.Context) ([]*api.TargetRange, error) {
+	ll := s.logCtx(ctx, "targets")
+	ll.Debug().Msg("listing sensor targets")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+targetColumns+` FROM sensor_targets ORDER BY device_id, sensor_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []*api.TargetRange
+	for rows.Next() {
+		target, err := scanTarget(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sensor target: %w", err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+func scanTarget(row interface{ Scan(...any) error }) (*api.TargetRange, error) {
+	var target api.TargetRange
+	var bounds [6]sql.NullFloat64
+	if err := row.Scan(&target.DeviceID, &target.SensorID,
+		&bounds[0], &bounds[1], &bounds[2], &bounds[3], &bounds[4], &bounds[5]); err != nil {
+		return nil, err
+	}
+	for i, dst := range []**float64{
+		&target.Ideal.Min, &target.Ideal.Max,
+		&target.Warning.Min, &target.Warning.Max,
+		&target.Critical.Min, &target.Critical.Max,
+	} {
+		if bounds[i].Valid {
+			v := bounds[i].Float64
+			*dst = &v
+		}
+	}
+	return &target, nil
+}
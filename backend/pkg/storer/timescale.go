@@ -0,0 +1,109 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimescaleConfig configures sensor_readings as a TimescaleDB hypertable
+type TimescaleConfig struct {
+	// ChunkInterval is the span of readings each chunk holds; it applies to chunks
+	// created from then on
+	ChunkInterval time.Duration
+	// CompressAfter compresses chunks once their readings are this old; zero or less
+	// leaves them uncompressed
+	CompressAfter time.Duration
+}
+
+// timescalePrimaryKey widens the sensor_readings primary key to include timestamp, which
+// TimescaleDB requires of every unique index on a hypertable
+const timescalePrimaryKey = `
+	ALTER TABLE sensor_readings
+		DROP CONSTRAINT sensor_readings_pkey,
+		ADD PRIMARY KEY (id, timestamp)`
+
+// enableTimescale turns sensor_readings into a hypertable, creating the TimescaleDB
+// extension if the server has it, and brings its chunk interval and compression policy
+// in line with the config. It runs under the migration lock, so processes starting
+// together convert the table once.
+func (s *Storer) enableTimescale(ctx context.Context) error {
+	ll := s.logCtx(ctx, "schema")
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock schema_migrations: %w", err)
+	}
+	var version string
+	err = tx.QueryRowContext(ctx, `SELECT default_version FROM pg_available_extensions WHERE name = 'timescaledb'`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("timescaledb extension is not available on this server")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to detect timescaledb: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE EXTENSION IF NOT EXISTS timescaledb`); err != nil {
+		return fmt.Errorf("failed to create timescaledb extension: %w", err)
+	}
+
+	var hypertable, compressed bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT TRUE, compression_enabled FROM timescaledb_information.hypertables
+		WHERE hypertable_schema = current_schema() AND hypertable_name = 'sensor_readings'
+	`).Scan(&hypertable, &compressed)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check for hypertable: %w", err)
+	}
+	chunk := s.timescale.ChunkInterval.Seconds()
+	if !hypertable {
+		if _, err := tx.ExecContext(ctx, timescalePrimaryKey); err != nil {
+			return fmt.Errorf("failed to widen sensor_readings primary key: %w", err)
+		}
+		// Existing readings move into chunks, which takes a while on a large table
+		_, err := tx.ExecContext(ctx, `
+			SELECT create_hypertable('sensor_readings', 'timestamp',
+				chunk_time_interval => make_interval(secs => $1),
+				create_default_indexes => FALSE,
+				migrate_data => TRUE)
+		`, chunk)
+		if err != nil {
+			return fmt.Errorf("failed to create hypertable: %w", err)
+		}
+		ll.Info().Str("timescaledb", version).Msg("converted sensor_readings to a hypertable")
+	} else if _, err := tx.ExecContext(ctx, `SELECT set_chunk_time_interval('sensor_readings', make_interval(secs => $1))`, chunk); err != nil {
+		return fmt.Errorf("failed to set chunk interval: %w", err)
+	}
+
+	// Replacing the policy picks up a changed CompressAfter
+	if _, err := tx.ExecContext(ctx, `SELECT remove_compression_policy('sensor_readings', if_exists => TRUE)`); err != nil {
+		return fmt.Errorf("failed to remove compression policy: %w", err)
+	}
+	if s.timescale.CompressAfter > 0 {
+		if !compressed {
+			_, err := tx.ExecContext(ctx, `
+				ALTER TABLE sensor_readings SET (
+					timescaledb.compress,
+					timescaledb.compress_segmentby = 'device_id, sensor_id',
+					timescaledb.compress_orderby = 'timestamp DESC, id DESC')
+			`)
+			if err != nil {
+				return fmt.Errorf("failed to enable compression: %w", err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, `SELECT add_compression_policy('sensor_readings', make_interval(secs => $1))`,
+			s.timescale.CompressAfter.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to add compression policy: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit timescaledb setup: %w", err)
+	}
+	return nil
+}
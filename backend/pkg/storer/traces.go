@@ -0,0 +1,89 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// pruneRuleTraces deletes a rule's traces older than its newest $3
+const pruneRuleTraces = `
+	DELETE FROM rule_traces
+	WHERE kind = $1 AND name = $2 AND id <= (
+		SELECT id FROM rule_traces WHERE kind = $1 AND name = $2 ORDER BY id DESC LIMIT 1 OFFSET $3
+	)
+`
+
+// RecordRuleTrace stores an evaluation of a rule, setting its ID, and keeps only the
+// rule's newest keep traces
+func (s *Storer) RecordRuleTrace(ctx context.Context, trace *api.RuleTrace, keep int) error {
+	ll := s.logCtx(ctx, "rule_traces")
+	ll.Debug().Str("kind", trace.Kind).Str("name", trace.Name).Str("outcome", string(trace.Outcome)).Msg("recording rule trace")
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rule trace: %w", err)
+	}
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO rule_traces (kind, name, timestamp, outcome, trace)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, trace.Kind, trace.Name, trace.Timestamp, trace.Outcome, data).Scan(&trace.ID); err != nil {
+		return fmt.Errorf("failed to record rule trace: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, pruneRuleTraces, trace.Kind, trace.Name, keep); err != nil {
+		return fmt.Errorf("failed to prune rule traces: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListRuleTraces returns a rule's latest limit traces, newest first
+func (s *Storer) ListRuleTraces(ctx context.Context, kind, name string, limit int) ([]*api.RuleTrace, error) {
+	ll := s.logCtx(ctx, "rule_traces")
+	ll.Debug().Str("kind", kind).Str("name", name).Msg("listing rule traces")
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, trace FROM rule_traces
+		WHERE kind = $1 AND name = $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, kind, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule traces: %w", err)
+	}
+	return scanRuleTraces(rows)
+}
+
+// scanRuleTraces reads id and trace columns, closing rows
+func scanRuleTraces(rows *sql.Rows) ([]*api.RuleTrace, error) {
+	defer rows.Close()
+	var traces []*api.RuleTrace
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan rule trace: %w", err)
+		}
+		var trace api.RuleTrace
+		if err := json.Unmarshal(data, &trace); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal rule trace: %w", err)
+		}
+		trace.ID = id
+		traces = append(traces, &trace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rule traces: %w", err)
+	}
+	return traces, nil
+}
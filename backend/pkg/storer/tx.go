@@ -0,0 +1,118 @@
+package storer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// conn runs queries; *sql.DB and *sql.Tx are both one
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// WithTx calls fn with a Storer whose methods all run within one transaction, committing
+// it if fn succeeds and rolling it back if not. Methods which are transactions of their
+// own become savepoints within it, so one failing leaves the rest of fn's work intact.
+// The Storer passed to fn must not be used once fn returns. Calling WithTx on it nests
+// a savepoint.
+func (s *Storer) WithTx(ctx context.Context, fn func(tx *Storer) error) error {
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	bound := &Storer{
+		db:            tx.Tx,
+		tx:            tx.Tx,
+		dbPool:        s.dbPool,
+		log:           s.log,
+		timescale:     s.timescale,
+		slowThreshold: s.slowThreshold,
+		slowQueries:   s.slowQueries,
+		// Statements are prepared on the pool, outside the transaction
+		stmts: stmtCache{disabled: true},
+	}
+	if bound.slowQueries != nil {
+		bound.db = slowConn{conn: tx.Tx, s: bound}
+	}
+	if err := fn(bound); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// txn is a transaction begun by begin: a transaction of its own, or a savepoint within
+// the transaction the Storer is bound to by WithTx
+type txn struct {
+	*sql.Tx
+	ctx       context.Context
+	savepoint bool
+	done      bool
+	// committed, if set, is signalled once the transaction commits
+	committed chan struct{}
+}
+
+// begin starts a transaction, or a savepoint when the Storer is bound to one
+func (s *Storer) begin(ctx context.Context) (*txn, error) {
+	if s.tx == nil {
+		tx, err := s.dbPool.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: tx, ctx: ctx, committed: s.committed}, nil
+	}
+	if _, err := s.tx.ExecContext(ctx, `SAVEPOINT storer_tx`); err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	return &txn{Tx: s.tx, ctx: ctx, savepoint: true}, nil
+}
+
+// Commit commits the transaction, or releases the savepoint into the enclosing one
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		if err := t.Tx.Commit(); err != nil {
+			return err
+		}
+		// Wake relayChanges to number any changes the transaction recorded
+		select {
+		case t.committed <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, `RELEASE SAVEPOINT storer_tx`)
+	return err
+}
+
+// Rollback rolls back the transaction, or the enclosing one to the savepoint. Like
+// sql.Tx's, it does nothing once committed.
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.ExecContext(t.ctx, `ROLLBACK TO SAVEPOINT storer_tx`)
+	if err == nil {
+		_, err = t.Tx.ExecContext(t.ctx, `RELEASE SAVEPOINT storer_tx`)
+	}
+	return err
+}
+
+// errBoundClose is returned when closing a Storer bound to a transaction by WithTx
+var errBoundClose = errors.New("a Storer bound to a transaction cannot be closed")
@@ -0,0 +1,203 @@
+// Package supervise keeps background work running through panics. Loops are restarted
+// with backoff and callbacks recover, and each failure is logged, counted and reported,
+// so one bad message can't quietly stop ingestion until the next restart.
+package supervise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"lifesupport/backend/pkg/logging"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// PanicError is returned in place of a panic recovered from supervised work
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Reporter sends failures somewhere they can be seen, such as Sentry
+type Reporter interface {
+	CaptureError(ctx context.Context, err error, tags map[string]string)
+	CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string)
+}
+
+// Failure counts the failures of one supervised task
+type Failure struct {
+	Errors    int       `json:"errors"`
+	Panics    int       `json:"panics"`
+	Last      time.Time `json:"last"`
+	LastError string    `json:"last_error"`
+}
+
+var (
+	lock     sync.Mutex
+	reporter Reporter
+	failures = map[string]*Failure{}
+)
+
+// SetReporter sends every later failure to r as well as the log; nil stops reporting
+func SetReporter(r Reporter) {
+	lock.Lock()
+	defer lock.Unlock()
+	reporter = r
+}
+
+// Failures returns the failures recorded for each task since the process started
+func Failures() map[string]Failure {
+	lock.Lock()
+	defer lock.Unlock()
+	out := make(map[string]Failure, len(failures))
+	for name, f := range failures {
+		out[name] = *f
+	}
+	return out
+}
+
+// record counts a failure, returning the reporter to send it to
+func record(name string, err error) Reporter {
+	lock.Lock()
+	defer lock.Unlock()
+	f, ok := failures[name]
+	if !ok {
+		f = &Failure{}
+		failures[name] = f
+	}
+	if _, ok := err.(*PanicError); ok {
+		f.Panics++
+	} else {
+		f.Errors++
+	}
+	f.Last = time.Now()
+	f.LastError = err.Error()
+	return reporter
+}
+
+type options struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	log        zerolog.Logger
+}
+
+// Option configures Run
+type Option func(*options)
+
+// WithBackoff waits first before the first restart, doubling up to limit for each
+// failure in a row
+func WithBackoff(first, limit time.Duration) Option {
+	return func(o *options) {
+		o.minBackoff = first
+		o.maxBackoff = limit
+	}
+}
+
+// WithLogger sets the logger failures are logged to when the context has none
+func WithLogger(l zerolog.Logger) Option {
+	return func(o *options) {
+		o.log = l
+	}
+}
+
+func logCtx(ctx context.Context, fallback zerolog.Logger, name string) zerolog.Logger {
+	var ll zerolog.Context
+	if ctxLog := log.Ctx(ctx); ctxLog.GetLevel() != zerolog.Disabled {
+		ll = ctxLog.With()
+	} else {
+		ll = fallback.With()
+	}
+	ll = ll.Str("component", "supervise").Str("task", name)
+	return logging.Component(ll.Logger(), "supervise")
+}
+
+// Run calls fn until it returns nil or ctx is done. When fn panics or returns an error it
+// is restarted after a backoff, which resets once fn has run for the longest backoff.
+// Run returns ctx's error if it was cancelled.
+func Run(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...Option) error {
+	o := options{minBackoff: defaultMinBackoff, maxBackoff: defaultMaxBackoff, log: log.Logger}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ll := logCtx(ctx, o.log, name)
+
+	backoff := o.minBackoff
+	for {
+		start := time.Now()
+		err := call(ctx, name, o.log, func() error { return fn(ctx) })
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			return nil
+		}
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			if r := record(name, err); r != nil {
+				r.CaptureError(ctx, err, map[string]string{"task": name})
+			}
+		}
+		if time.Since(start) >= o.maxBackoff {
+			backoff = o.minBackoff
+		}
+		ll.Error().Err(err).Dur("backoff", backoff).Msg("supervised task failed; restarting")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, o.maxBackoff)
+	}
+}
+
+// Call runs fn, recovering a panic and returning it as a *PanicError. Panics are
+// recorded under name; errors fn returns are left to the caller.
+func Call(ctx context.Context, name string, fn func() error) error {
+	return call(ctx, name, log.Logger, fn)
+}
+
+func call(ctx context.Context, name string, fallback zerolog.Logger, fn func() error) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		panicErr := &PanicError{Value: recovered, Stack: debug.Stack()}
+		if r := record(name, panicErr); r != nil {
+			r.CapturePanic(ctx, recovered, map[string]string{"task": name})
+		}
+		ll := logCtx(ctx, fallback, name)
+		ll.Error().Interface("panic", recovered).Bytes("stack", panicErr.Stack).Msg("recovered from panic")
+		err = panicErr
+	}()
+	return fn()
+}
+
+// MQTTHandler wraps an MQTT message callback so a panic drops the message rather than
+// crashing the client
+func MQTTHandler(name string, h mqtt.MessageHandler) mqtt.MessageHandler {
+	return func(c mqtt.Client, m mqtt.Message) {
+		Call(context.Background(), name, func() error {
+			h(c, m)
+			return nil
+		})
+	}
+}
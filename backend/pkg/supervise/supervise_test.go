@@ -0,0 +1,96 @@
+package supervise
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeReporter struct {
+	errors []error
+	panics []interface{}
+}
+
+func (f *fakeReporter) CaptureError(ctx context.Context, err error, tags map[string]string) {
+	f.errors = append(f.errors, err)
+}
+
+func (f *fakeReporter) CapturePanic(ctx context.Context, recovered interface{}, tags map[string]string) {
+	f.panics = append(f.panics, recovered)
+}
+
+func TestCall_RecoversPanic(t *testing.T) {
+	reporter := &fakeReporter{}
+	SetReporter(reporter)
+	t.Cleanup(func() { SetReporter(nil) })
+
+	err := Call(context.Background(), "test.call", func() error {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("Expected a PanicError for boom, got %v", err)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("Expected the panic's stack")
+	}
+	if len(reporter.panics) != 1 {
+		t.Errorf("Expected the panic to be reported, got %d reports", len(reporter.panics))
+	}
+	if f := Failures()["test.call"]; f.Panics != 1 || f.LastError != "panic: boom" {
+		t.Errorf("Expected 1 recorded panic, got %+v", f)
+	}
+
+	sentinel := errors.New("failed")
+	if err := Call(context.Background(), "test.call", func() error { return sentinel }); err != sentinel {
+		t.Errorf("Expected the error to be returned as is, got %v", err)
+	}
+	if f := Failures()["test.call"]; f.Errors != 0 {
+		t.Errorf("Expected errors from Call not to be recorded, got %d", f.Errors)
+	}
+}
+
+func TestRun_Restarts(t *testing.T) {
+	runs := 0
+	err := Run(context.Background(), "test.restart", func(ctx context.Context) error {
+		runs++
+		switch runs {
+		case 1:
+			panic("boom")
+		case 2:
+			return errors.New("failed")
+		}
+		return nil
+	}, WithBackoff(time.Millisecond, 2*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Expected Run to end when fn returns nil, got %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("Expected 3 runs, got %d", runs)
+	}
+	if f := Failures()["test.restart"]; f.Panics != 1 || f.Errors != 1 {
+		t.Errorf("Expected 1 panic and 1 error recorded, got %+v", f)
+	}
+}
+
+func TestRun_StopsDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, "test.cancel", func(ctx context.Context) error {
+			return errors.New("failed")
+		}, WithBackoff(time.Hour, time.Hour))
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return once cancelled")
+	}
+}
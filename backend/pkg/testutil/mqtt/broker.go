@@ -0,0 +1,277 @@
+// Package mqtt provides an embedded MQTT broker and fake devices for tests, so drivers
+// and discovery can be exercised through the real paho client rather than mocks.
+//
+// The broker implements enough of MQTT 3.1.1 for a single process: CONNECT, SUBSCRIBE,
+// UNSUBSCRIBE, PUBLISH at QoS 0-2 (always delivered at QoS 0), PINGREQ and DISCONNECT.
+// There is no persistence, retained messages or authentication.
+package mqtt
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// Handler receives messages published to a broker-side subscription
+type Handler func(topic string, payload []byte)
+
+// Broker is an in-process MQTT broker listening on a random loopback port
+type Broker struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+	handlers []*handlerSub
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type handlerSub struct {
+	filter string
+	fn     Handler
+}
+
+type conn struct {
+	net.Conn
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	subs map[string]struct{}
+}
+
+// NewBroker starts a broker which is shut down when the test finishes
+func NewBroker(t testing.TB) *Broker {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen for MQTT: %v", err)
+	}
+	b := &Broker{
+		listener: l,
+		conns:    make(map[*conn]struct{}),
+	}
+	b.wg.Add(1)
+	go b.accept()
+	t.Cleanup(b.Close)
+	return b
+}
+
+// URL returns the broker address in the form expected by paho's AddBroker
+func (b *Broker) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// Client returns a paho client connected to the broker, disconnected when the test
+// finishes
+func (b *Broker) Client(t testing.TB, clientID string) paho.Client {
+	t.Helper()
+	opts := paho.NewClientOptions().
+		AddBroker(b.URL()).
+		SetClientID(clientID).
+		SetAutoReconnect(false).
+		SetConnectTimeout(5 * time.Second)
+	c := paho.NewClient(opts)
+	token := c.Connect()
+	if !token.WaitTimeout(5 * time.Second) {
+		t.Fatalf("Timed out connecting to MQTT broker")
+	}
+	if err := token.Error(); err != nil {
+		t.Fatalf("Failed to connect to MQTT broker: %v", err)
+	}
+	t.Cleanup(func() { c.Disconnect(100) })
+	return c
+}
+
+// Subscribe registers a broker-side handler for messages matching filter. Handlers run
+// on the publisher's goroutine and may publish.
+func (b *Broker) Subscribe(filter string, fn Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, &handlerSub{filter: filter, fn: fn})
+}
+
+// Publish delivers a message to every matching client subscription and handler
+func (b *Broker) Publish(topic string, payload []byte) {
+	b.mu.Lock()
+	var conns []*conn
+	for c := range b.conns {
+		if c.subscribed(topic) {
+			conns = append(conns, c)
+		}
+	}
+	var handlers []Handler
+	for _, h := range b.handlers {
+		if TopicMatches(h.filter, topic) {
+			handlers = append(handlers, h.fn)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, c := range conns {
+		pub := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		pub.TopicName = topic
+		pub.Payload = payload
+		// A failed write means the client went away; its read loop cleans up
+		_ = c.write(pub)
+	}
+	for _, fn := range handlers {
+		fn(topic, payload)
+	}
+}
+
+// Close stops the broker and drops every client connection
+func (b *Broker) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	b.listener.Close()
+	for c := range b.conns {
+		c.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+func (b *Broker) accept() {
+	defer b.wg.Done()
+	for {
+		nc, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc, subs: make(map[string]struct{})}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			nc.Close()
+			return
+		}
+		b.conns[c] = struct{}{}
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go b.serve(c)
+	}
+}
+
+func (b *Broker) serve(c *conn) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.Close()
+	}()
+
+	for {
+		cp, err := packets.ReadPacket(c)
+		if err != nil {
+			return
+		}
+		if err := b.handle(c, cp); err != nil {
+			return
+		}
+	}
+}
+
+var errDisconnect = errors.New("client disconnected")
+
+func (b *Broker) handle(c *conn, cp packets.ControlPacket) error {
+	switch p := cp.(type) {
+	case *packets.ConnectPacket:
+		ack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+		ack.ReturnCode = packets.Accepted
+		return c.write(ack)
+	case *packets.SubscribePacket:
+		ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+		ack.MessageID = p.MessageID
+		c.mu.Lock()
+		for i, filter := range p.Topics {
+			c.subs[filter] = struct{}{}
+			ack.ReturnCodes = append(ack.ReturnCodes, min(p.Qoss[i], 1))
+		}
+		c.mu.Unlock()
+		return c.write(ack)
+	case *packets.UnsubscribePacket:
+		c.mu.Lock()
+		for _, filter := range p.Topics {
+			delete(c.subs, filter)
+		}
+		c.mu.Unlock()
+		ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+		ack.MessageID = p.MessageID
+		return c.write(ack)
+	case *packets.PublishPacket:
+		switch p.Qos {
+		case 1:
+			ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+			ack.MessageID = p.MessageID
+			if err := c.write(ack); err != nil {
+				return err
+			}
+		case 2:
+			rec := packets.NewControlPacket(packets.Pubrec).(*packets.PubrecPacket)
+			rec.MessageID = p.MessageID
+			if err := c.write(rec); err != nil {
+				return err
+			}
+		}
+		b.Publish(p.TopicName, p.Payload)
+		return nil
+	case *packets.PubrelPacket:
+		comp := packets.NewControlPacket(packets.Pubcomp).(*packets.PubcompPacket)
+		comp.MessageID = p.MessageID
+		return c.write(comp)
+	case *packets.PingreqPacket:
+		return c.write(packets.NewControlPacket(packets.Pingresp))
+	case *packets.DisconnectPacket:
+		return errDisconnect
+	}
+	// PUBACK and friends for QoS 0 deliveries never arrive; ignore anything else
+	return nil
+}
+
+func (c *conn) write(p packets.ControlPacket) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return p.Write(c.Conn)
+}
+
+func (c *conn) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filter := range c.subs {
+		if TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// TopicMatches reports whether topic matches an MQTT subscription filter, honouring the
+// single-level "+" and multi-level "#" wildcards
+func TopicMatches(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) {
+			return false
+		}
+		if part != "+" && part != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
@@ -0,0 +1,57 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"+/events/rpc", "shelly-1/events/rpc", true},
+		{"+/events/rpc", "a/b/events/rpc", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/+", "a", false},
+		{"#", "anything/at/all", true},
+	}
+	for _, tt := range tests {
+		if got := TopicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("TopicMatches(%q, %q) = %v, expected %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	b := NewBroker(t)
+	sub := b.Client(t, "sub")
+	pub := b.Client(t, "pub")
+
+	got := make(chan string, 1)
+	token := sub.Subscribe("test/+", 1, func(_ paho.Client, m paho.Message) {
+		got <- m.Topic() + "=" + string(m.Payload())
+	})
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("Subscribe failed: %v", token.Error())
+	}
+
+	token = pub.Publish("test/one", 1, false, "hello")
+	if !token.WaitTimeout(time.Second) || token.Error() != nil {
+		t.Fatalf("Publish failed: %v", token.Error())
+	}
+
+	select {
+	case msg := <-got:
+		if msg != "test/one=hello" {
+			t.Errorf("Expected test/one=hello, got %s", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+}
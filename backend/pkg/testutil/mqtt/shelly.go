@@ -0,0 +1,251 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	shellyCommandTopic  = "shellies/command"
+	shellyAnnounceTopic = "shellies/announce"
+)
+
+// RPCError is returned by a fake RPC method to produce an error response
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCMethod implements a fake RPC method. Returning a *RPCError produces an error
+// response; any other error is reported with code -1.
+type RPCMethod func(params json.RawMessage) (any, error)
+
+// Shelly is a fake Gen2 Shelly device. It answers "announce" on shellies/command,
+// serves RPC requests on <id>/rpc and publishes NotifyStatus on <id>/events/rpc.
+// Switch.Set and Switch.Toggle change the switch outputs and notify as a real device
+// would.
+type Shelly struct {
+	ID  string
+	App string
+	MAC string
+
+	broker *Broker
+
+	mu       sync.Mutex
+	outputs  []bool
+	methods  map[string]RPCMethod
+	calls    []string
+	silenced bool
+}
+
+// NewShelly attaches a fake Shelly with the given number of switches to the broker
+func NewShelly(b *Broker, id string, switches int) *Shelly {
+	s := &Shelly{
+		ID:      id,
+		App:     "Plus1PM",
+		MAC:     "AABBCCDDEEFF",
+		broker:  b,
+		outputs: make([]bool, switches),
+	}
+	s.methods = map[string]RPCMethod{
+		"Shelly.GetDeviceInfo": func(json.RawMessage) (any, error) { return s.deviceInfo(), nil },
+		"Shelly.GetConfig":     s.getConfig,
+		"Switch.Set":           s.switchSet,
+		"Switch.Toggle":        s.switchToggle,
+		"Switch.GetStatus":     s.switchGetStatus,
+	}
+	b.Subscribe(shellyCommandTopic, s.handleCommand)
+	b.Subscribe(id+"/rpc", s.handleRPC)
+	return s
+}
+
+// Handle replaces or adds an RPC method
+func (s *Shelly) Handle(method string, fn RPCMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[method] = fn
+}
+
+// Silence makes the device ignore all requests, simulating one that has gone offline
+func (s *Shelly) Silence(silenced bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.silenced = silenced
+}
+
+// SetOnline publishes the device's connection state on <id>/online, as a real device
+// does on connect and its broker-held last will does on disconnect. Going offline also
+// silences the device.
+func (s *Shelly) SetOnline(online bool) {
+	s.Silence(!online)
+	s.broker.Publish(s.ID+"/online", []byte(fmt.Sprint(online)))
+}
+
+// Calls returns the RPC methods received so far, in order
+func (s *Shelly) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+// Output reports whether a switch is on
+func (s *Shelly) Output(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outputs[id]
+}
+
+// SetOutput changes a switch locally, as if toggled by its button, and notifies
+func (s *Shelly) SetOutput(id int, on bool) {
+	s.mu.Lock()
+	s.outputs[id] = on
+	s.mu.Unlock()
+	s.NotifyStatus(map[string]any{fmt.Sprintf("switch:%d", id): map[string]any{"id": id, "output": on}})
+}
+
+// NotifyStatus publishes a NotifyStatus notification with the given component params
+func (s *Shelly) NotifyStatus(params map[string]any) {
+	s.notify("NotifyStatus", params)
+}
+
+// NotifyFullStatus publishes a NotifyFullStatus notification with the given params
+func (s *Shelly) NotifyFullStatus(params map[string]any) {
+	s.notify("NotifyFullStatus", params)
+}
+
+func (s *Shelly) notify(method string, params map[string]any) {
+	withTS := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		withTS[k] = v
+	}
+	if _, ok := withTS["ts"]; !ok {
+		withTS["ts"] = float64(time.Now().UnixMilli()) / 1000
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"src":    s.ID,
+		"dst":    s.ID + "/events",
+		"method": method,
+		"params": withTS,
+	})
+	s.broker.Publish(s.ID+"/events/rpc", payload)
+}
+
+func (s *Shelly) handleCommand(_ string, payload []byte) {
+	if string(payload) != "announce" {
+		return
+	}
+	s.mu.Lock()
+	silenced := s.silenced
+	s.mu.Unlock()
+	if silenced {
+		return
+	}
+	b, _ := json.Marshal(s.deviceInfo())
+	s.broker.Publish(shellyAnnounceTopic, b)
+}
+
+func (s *Shelly) handleRPC(_ string, payload []byte) {
+	var req struct {
+		ID     uint64          `json:"id"`
+		Src    string          `json:"src"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil || req.Src == "" {
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, req.Method)
+	method, ok := s.methods[req.Method]
+	silenced := s.silenced
+	s.mu.Unlock()
+	if silenced {
+		return
+	}
+
+	resp := map[string]any{"id": req.ID, "src": s.ID, "dst": req.Src}
+	if !ok {
+		resp["error"] = &RPCError{Code: 404, Message: "No handler for " + req.Method}
+	} else if result, err := method(req.Params); err != nil {
+		rpcErr, isRPC := err.(*RPCError)
+		if !isRPC {
+			rpcErr = &RPCError{Code: -1, Message: err.Error()}
+		}
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	b, _ := json.Marshal(resp)
+	s.broker.Publish(req.Src+"/rpc", b)
+}
+
+func (s *Shelly) deviceInfo() map[string]any {
+	return map[string]any{
+		"id":    s.ID,
+		"mac":   s.MAC,
+		"model": "SNSW-001P16EU",
+		"gen":   2,
+		"fw_id": "20240101-000000/1.0.0-test",
+		"ver":   "1.0.0",
+		"app":   s.App,
+	}
+}
+
+func (s *Shelly) getConfig(json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config := map[string]any{}
+	for i := range s.outputs {
+		config[fmt.Sprintf("switch:%d", i)] = map[string]any{"id": i, "name": nil}
+	}
+	return config, nil
+}
+
+func (s *Shelly) switchParams(params json.RawMessage) (id int, on bool, err error) {
+	var p struct {
+		ID int  `json:"id"`
+		On bool `json:"on"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return 0, false, &RPCError{Code: -103, Message: "Invalid argument: " + err.Error()}
+	}
+	if p.ID < 0 || p.ID >= len(s.outputs) {
+		return 0, false, &RPCError{Code: -105, Message: fmt.Sprintf("Argument 'id', value %d not found!", p.ID)}
+	}
+	return p.ID, p.On, nil
+}
+
+func (s *Shelly) switchSet(params json.RawMessage) (any, error) {
+	id, on, err := s.switchParams(params)
+	if err != nil {
+		return nil, err
+	}
+	wasOn := s.Output(id)
+	s.SetOutput(id, on)
+	return map[string]any{"was_on": wasOn}, nil
+}
+
+func (s *Shelly) switchToggle(params json.RawMessage) (any, error) {
+	id, _, err := s.switchParams(params)
+	if err != nil {
+		return nil, err
+	}
+	wasOn := s.Output(id)
+	s.SetOutput(id, !wasOn)
+	return map[string]any{"was_on": wasOn}, nil
+}
+
+func (s *Shelly) switchGetStatus(params json.RawMessage) (any, error) {
+	id, _, err := s.switchParams(params)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": id, "output": s.Output(id)}, nil
+}
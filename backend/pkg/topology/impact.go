@@ -0,0 +1,97 @@
+package topology
+
+import (
+	"sort"
+	"strings"
+
+	"lifesupport/backend/pkg/api"
+)
+
+// impactCategories groups rule kinds by how they run; kinds not listed are rules
+var impactCategories = map[string]api.ImpactCategory{
+	api.RuleKindPumpRotation:       api.ImpactControlLoop,
+	api.RuleKindDryRun:             api.ImpactControlLoop,
+	api.RuleKindSPC:                api.ImpactControlLoop,
+	api.RuleKindRateOfChange:       api.ImpactControlLoop,
+	api.RuleKindLeakResponse:       api.ImpactRule,
+	api.RuleKindEventReaction:      api.ImpactRule,
+	api.RuleKindComposite:          api.ImpactRule,
+	api.RuleKindLogicalMeasurement: api.ImpactDerivedSensor,
+}
+
+// fallbacks describe what each kind of rule does while a sensor it reads is not
+// reporting, as implemented in package control
+var fallbacks = map[string]string{
+	api.RuleKindPumpRotation:       "Switchovers cannot confirm flow, so each fails and the active pump is restarted; the pumps stop rotating",
+	api.RuleKindDryRun:             "The sensor is treated as healthy, so the pump keeps running without dry-run protection",
+	api.RuleKindSPC:                "No alerts are raised while the sensor reports nothing; the chart resumes from stored readings once it returns",
+	api.RuleKindRateOfChange:       "The rate cannot be measured, so no alerts are raised until the sensor reports again",
+	api.RuleKindLeakResponse:       "Leaks this sensor would report go undetected; any other leak sensors still isolate the subsystem",
+	api.RuleKindEventReaction:      "The reaction does not fire while the sensor reports nothing",
+	api.RuleKindComposite:          "Comparisons on this sensor are unknown, so the rule does not fire unless the rest of its condition settles it",
+	api.RuleKindLogicalMeasurement: "The vote goes on without this probe; below quorum the measurement follows its degraded policy",
+}
+
+// Impact lists the rules in topo which read the sensor, grouped by category, with what
+// each falls back to while the sensor is not reporting
+func Impact(topo *api.Topology, deviceID, sensorID string) *api.SensorImpact {
+	impact := &api.SensorImpact{
+		DeviceID:       deviceID,
+		SensorID:       sensorID,
+		ControlLoops:   []api.RuleImpact{},
+		Rules:          []api.RuleImpact{},
+		DerivedSensors: []api.RuleImpact{},
+	}
+	sensor := string(api.TopologyNodeSensor) + ":" + componentID(deviceID, sensorID)
+
+	nodes := make(map[string]api.TopologyNode, len(topo.Nodes))
+	for _, n := range topo.Nodes {
+		nodes[n.ID] = n
+	}
+	// tags holds the tags each rule reads the sensor by
+	tags := map[string][]string{}
+	others := map[string]map[string]bool{}
+	actuators := map[string][]string{}
+	for _, e := range topo.Edges {
+		switch {
+		case e.Kind == api.TopologyEdgeReads && e.To == sensor:
+			tags[e.From] = append(tags[e.From], e.Tag)
+		case e.Kind == api.TopologyEdgeReads:
+			if others[e.From] == nil {
+				others[e.From] = map[string]bool{}
+			}
+			others[e.From][e.To] = true
+		case e.Kind == api.TopologyEdgeDrives:
+			actuators[e.From] = append(actuators[e.From], strings.TrimPrefix(e.To, string(api.TopologyNodeActuator)+":"))
+		}
+	}
+
+	rules := make([]string, 0, len(tags))
+	for id := range tags {
+		rules = append(rules, id)
+	}
+	sort.Strings(rules)
+	for _, id := range rules {
+		rule := nodes[id]
+		ri := api.RuleImpact{
+			Kind:         rule.RuleKind,
+			Name:         rule.Label,
+			Tags:         tags[id],
+			Fallback:     fallbacks[rule.RuleKind],
+			OtherSensors: len(others[id]),
+			Actuators:    actuators[id],
+		}
+		if ri.Actuators == nil {
+			ri.Actuators = []string{}
+		}
+		switch impactCategories[rule.RuleKind] {
+		case api.ImpactControlLoop:
+			impact.ControlLoops = append(impact.ControlLoops, ri)
+		case api.ImpactDerivedSensor:
+			impact.DerivedSensors = append(impact.DerivedSensors, ri)
+		default:
+			impact.Rules = append(impact.Rules, ri)
+		}
+	}
+	return impact
+}
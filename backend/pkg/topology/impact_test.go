@@ -0,0 +1,55 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+func TestImpact(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	dev := &api.Device{ID: "sump", Driver: api.DriverShelly, Name: "Sump",
+		Sensors: []*api.Sensor{
+			{ID: "flow", Name: "Flow", Tags: []string{"sump.flow"}},
+			{ID: "power", Name: "Power", Tags: []string{"sump.power"}},
+			{ID: "ph", Name: "pH", Tags: []string{"sump.ph"}},
+		},
+		Actuators: []*api.Actuator{{ID: "pump", Name: "Pump", Tags: []string{"sump.pump"}}},
+	}
+	if err := store.CreateDevice(ctx, dev); err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	rules := []api.RuleRef{
+		api.DryRunRule{Name: "sump-dry", PumpTag: "sump.pump", PowerTag: "sump.power", FlowTag: "sump.flow"}.Ref(),
+		api.EventReaction{Name: "no-flow", Tag: "sump.flow", ActuatorTags: []string{"sump.pump"}}.Ref(),
+		api.LogicalMeasurement{Name: "flow", SensorTags: []string{"sump.flow"}}.Ref(),
+		api.LeakResponse{Subsystem: "sump", LeakTags: []string{"sump.leak"}}.Ref(),
+	}
+	topo, err := Build(ctx, store, rules)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	impact := Impact(topo, "sump", "flow")
+	if len(impact.ControlLoops) != 1 || len(impact.Rules) != 1 || len(impact.DerivedSensors) != 1 {
+		t.Fatalf("Expected one control loop, rule and derived sensor, got %+v", impact)
+	}
+	loop := impact.ControlLoops[0]
+	if loop.Kind != api.RuleKindDryRun || loop.Name != "sump-dry" || loop.Fallback == "" {
+		t.Errorf("Expected the dry-run rule with a fallback, got %+v", loop)
+	}
+	if loop.OtherSensors != 1 || len(loop.Actuators) != 1 || loop.Actuators[0] != "sump/pump" {
+		t.Errorf("Expected the dry-run rule to still read power and drive the pump, got %+v", loop)
+	}
+	if rule := impact.Rules[0]; rule.Name != "no-flow" || len(rule.Tags) != 1 || rule.Tags[0] != "sump.flow" {
+		t.Errorf("Expected the no-flow reaction by its tag, got %+v", rule)
+	}
+
+	impact = Impact(topo, "sump", "ph")
+	if len(impact.ControlLoops)+len(impact.Rules)+len(impact.DerivedSensors) != 0 {
+		t.Errorf("Expected nothing to depend on the pH probe, got %+v", impact)
+	}
+}
@@ -0,0 +1,121 @@
+// Package topology builds a graph of how subsystems, devices, sensors, actuators and
+// control rules relate, for rendering dependency maps
+package topology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// Build returns the topology of the devices in store and of rules. Devices belong to the
+// subsystem named by their subsystem type metadata, if any. Rule tags are resolved
+// through tag aliases to the sensors and actuators carrying them.
+func Build(ctx context.Context, store storer.Interface, rules []api.RuleRef) (*api.Topology, error) {
+	devices, err := store.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	sensors, err := store.ListSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	actuators, err := store.ListActuators(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list actuators: %w", err)
+	}
+	aliases, err := store.ListTagAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tag aliases: %w", err)
+	}
+
+	topo := &api.Topology{Nodes: []api.TopologyNode{}, Edges: []api.TopologyEdge{}, Unresolved: []string{}}
+	node := func(kind api.TopologyNodeKind, id, label string) string {
+		nodeID := string(kind) + ":" + id
+		topo.Nodes = append(topo.Nodes, api.TopologyNode{ID: nodeID, Kind: kind, Label: label})
+		return nodeID
+	}
+	edge := func(from, to string, kind api.TopologyEdgeKind, tag string) {
+		topo.Edges = append(topo.Edges, api.TopologyEdge{From: from, To: to, Kind: kind, Tag: tag})
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+	sort.Slice(sensors, func(i, j int) bool {
+		return componentID(sensors[i].DeviceID, sensors[i].ID) < componentID(sensors[j].DeviceID, sensors[j].ID)
+	})
+	sort.Slice(actuators, func(i, j int) bool {
+		return componentID(actuators[i].DeviceID, actuators[i].ID) < componentID(actuators[j].DeviceID, actuators[j].ID)
+	})
+
+	subsystems := map[string]string{}
+	deviceNodes := make(map[string]string, len(devices))
+	for _, dev := range devices {
+		deviceNodes[dev.ID] = node(api.TopologyNodeDevice, dev.ID, dev.Name)
+		subsystem := dev.Metadata[api.MetadataSubsystemType]
+		if subsystem == "" {
+			continue
+		}
+		if _, ok := subsystems[subsystem]; !ok {
+			subsystems[subsystem] = node(api.TopologyNodeSubsystem, subsystem, subsystem)
+		}
+		edge(subsystems[subsystem], deviceNodes[dev.ID], api.TopologyEdgeContains, "")
+	}
+
+	// tagged maps each tag to the sensor and actuator nodes carrying it
+	tagged := map[string][]string{}
+	kinds := map[string]api.TopologyEdgeKind{}
+	for _, sensor := range sensors {
+		id := node(api.TopologyNodeSensor, componentID(sensor.DeviceID, sensor.ID), sensor.Name)
+		if dev, ok := deviceNodes[sensor.DeviceID]; ok {
+			edge(dev, id, api.TopologyEdgeContains, "")
+		}
+		kinds[id] = api.TopologyEdgeReads
+		for _, tag := range sensor.Tags {
+			tagged[tag] = append(tagged[tag], id)
+		}
+	}
+	for _, actuator := range actuators {
+		id := node(api.TopologyNodeActuator, componentID(actuator.DeviceID, actuator.ID), actuator.Name)
+		if dev, ok := deviceNodes[actuator.DeviceID]; ok {
+			edge(dev, id, api.TopologyEdgeContains, "")
+		}
+		kinds[id] = api.TopologyEdgeDrives
+		for _, tag := range actuator.Tags {
+			tagged[tag] = append(tagged[tag], id)
+		}
+	}
+	targets := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		targets[alias.Alias] = alias.Target
+	}
+
+	unresolved := map[string]bool{}
+	for _, rule := range rules {
+		id := node(api.TopologyNodeRule, rule.Kind+"/"+rule.Name, rule.Name)
+		topo.Nodes[len(topo.Nodes)-1].RuleKind = rule.Kind
+		for _, tag := range rule.Tags {
+			resolved := tagged[tag]
+			if target, ok := targets[tag]; ok && len(resolved) == 0 {
+				resolved = tagged[target]
+			}
+			if len(resolved) == 0 {
+				unresolved[tag] = true
+			}
+			for _, to := range resolved {
+				edge(id, to, kinds[to], tag)
+			}
+		}
+	}
+	for tag := range unresolved {
+		topo.Unresolved = append(topo.Unresolved, tag)
+	}
+	sort.Strings(topo.Unresolved)
+	return topo, nil
+}
+
+func componentID(deviceID, id string) string {
+	return deviceID + "/" + id
+}
@@ -0,0 +1,74 @@
+package topology
+
+import (
+	"context"
+	"testing"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+func TestBuild(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	for _, dev := range []*api.Device{
+		{ID: "tank", Driver: api.DriverShelly, Name: "Tank", Metadata: map[string]string{api.MetadataSubsystemType: "aquarium"},
+			Sensors:   []*api.Sensor{{ID: "flow", Name: "Flow", Tags: []string{"tank.flow"}}},
+			Actuators: []*api.Actuator{{ID: "pump", Name: "Pump", Tags: []string{"tank.pump"}}},
+		},
+		{ID: "spare", Driver: api.DriverShelly, Name: "Spare"},
+	} {
+		if err := store.CreateDevice(ctx, dev); err != nil {
+			t.Fatalf("CreateDevice() error = %v", err)
+		}
+	}
+	if err := store.SetTagAlias(ctx, &api.TagAlias{Alias: "main-pump", Target: "tank.pump"}); err != nil {
+		t.Fatalf("SetTagAlias() error = %v", err)
+	}
+	rules := []api.RuleRef{
+		{Kind: api.RuleKindDryRun, Name: "protect", Tags: []string{"main-pump", "tank.flow", "tank.power"}},
+	}
+
+	topo, err := Build(ctx, store, rules)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	nodes := map[string]api.TopologyNodeKind{}
+	for _, n := range topo.Nodes {
+		nodes[n.ID] = n.Kind
+	}
+	for id, kind := range map[string]api.TopologyNodeKind{
+		"subsystem:aquarium":   api.TopologyNodeSubsystem,
+		"device:tank":          api.TopologyNodeDevice,
+		"device:spare":         api.TopologyNodeDevice,
+		"sensor:tank/flow":     api.TopologyNodeSensor,
+		"actuator:tank/pump":   api.TopologyNodeActuator,
+		"rule:dry_run/protect": api.TopologyNodeRule,
+	} {
+		if nodes[id] != kind {
+			t.Errorf("Expected node %s of kind %s, got %q", id, kind, nodes[id])
+		}
+	}
+
+	edges := map[api.TopologyEdge]bool{}
+	for _, e := range topo.Edges {
+		edges[e] = true
+	}
+	for _, want := range []api.TopologyEdge{
+		{From: "subsystem:aquarium", To: "device:tank", Kind: api.TopologyEdgeContains},
+		{From: "device:tank", To: "sensor:tank/flow", Kind: api.TopologyEdgeContains},
+		{From: "device:tank", To: "actuator:tank/pump", Kind: api.TopologyEdgeContains},
+		{From: "rule:dry_run/protect", To: "sensor:tank/flow", Kind: api.TopologyEdgeReads, Tag: "tank.flow"},
+		{From: "rule:dry_run/protect", To: "actuator:tank/pump", Kind: api.TopologyEdgeDrives, Tag: "main-pump"},
+	} {
+		if !edges[want] {
+			t.Errorf("Expected edge %+v, got %+v", want, topo.Edges)
+		}
+	}
+	if len(topo.Edges) != 5 {
+		t.Errorf("Expected 5 edges, got %d", len(topo.Edges))
+	}
+	if len(topo.Unresolved) != 1 || topo.Unresolved[0] != "tank.power" {
+		t.Errorf("Expected tank.power to be unresolved, got %v", topo.Unresolved)
+	}
+}
@@ -0,0 +1,456 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsumer is a push consumer on fakeJetStream
+type fakeConsumer struct {
+	config  consumerConfig
+	pending []int // stream sequences awaiting delivery
+	acked   map[int]bool
+}
+
+// fakeJetStream speaks enough of the NATS protocol and JetStream API for JetStream:
+// one stream, durable push consumers, and acknowledgements
+type fakeJetStream struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	conns     map[net.Conn]*fakeConn
+	stream    *streamConfig
+	creates   int
+	denied    bool        // refuses to create the stream
+	messages  []jsMessage // by stream sequence - 1
+	consumers map[string]*fakeConsumer
+}
+
+type fakeConn struct {
+	conn net.Conn
+	subs map[string]string // sid to subject
+}
+
+func startFakeJetStream(t *testing.T) *fakeJetStream {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	f := &fakeJetStream{ln: ln, conns: make(map[net.Conn]*fakeConn), consumers: make(map[string]*fakeConsumer)}
+	t.Cleanup(func() {
+		ln.Close()
+		f.drop()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeJetStream) url() string {
+	return "nats://" + f.ln.Addr().String()
+}
+
+// drop closes every client connection
+func (f *fakeJetStream) drop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+func (f *fakeJetStream) acked(durable string) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var seqs []int
+	for seq := 1; seq <= len(f.messages); seq++ {
+		if c, ok := f.consumers[durable]; ok && c.acked[seq] {
+			seqs = append(seqs, seq)
+		}
+	}
+	return seqs
+}
+
+func (f *fakeJetStream) serve(conn net.Conn) {
+	fc := &fakeConn{conn: conn, subs: make(map[string]string)}
+	f.mu.Lock()
+	f.conns[conn] = fc
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"headers\":true,\"jetstream\":true}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			f.mu.Lock()
+			fmt.Fprintf(conn, "PONG\r\n")
+			f.mu.Unlock()
+		case "SUB":
+			f.mu.Lock()
+			fc.subs[fields[len(fields)-1]] = fields[1]
+			f.pump()
+			f.mu.Unlock()
+		case "UNSUB":
+			f.mu.Lock()
+			delete(fc.subs, fields[1])
+			f.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			var reply string
+			if len(fields) == 4 {
+				reply = fields[2]
+			}
+			f.mu.Lock()
+			f.publish(fc, fields[1], reply, payload[:size])
+			f.mu.Unlock()
+		}
+	}
+}
+
+// publish handles a PUB with f.mu held
+func (f *fakeJetStream) publish(from *fakeConn, subject, reply string, payload []byte) {
+	respond := func(v any) {
+		b, _ := json.Marshal(v)
+		fmt.Fprintf(from.conn, "MSG %s %s %d\r\n%s\r\n", reply, from.sid(reply), len(b), b)
+	}
+	apiError := func(code, errCode int, description string) map[string]any {
+		return map[string]any{"error": APIError{Code: code, ErrCode: errCode, Description: description}}
+	}
+
+	switch {
+	case strings.HasPrefix(subject, "$JS.API.STREAM.INFO."):
+		if f.stream == nil {
+			respond(apiError(404, errCodeStreamNotFound, "stream not found"))
+			return
+		}
+		respond(map[string]any{"config": f.stream})
+	case strings.HasPrefix(subject, "$JS.API.STREAM.CREATE.") && f.denied:
+		respond(apiError(403, 10003, "insufficient permissions"))
+	case strings.HasPrefix(subject, "$JS.API.STREAM.CREATE."):
+		f.stream = &streamConfig{}
+		json.Unmarshal(payload, f.stream)
+		f.creates++
+		respond(map[string]any{"config": f.stream})
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.DURABLE.CREATE."):
+		var req createConsumerRequest
+		json.Unmarshal(payload, &req)
+		if c, ok := f.consumers[req.Config.DurableName]; ok {
+			if c.config != req.Config {
+				respond(apiError(400, 10148, "consumer already exists"))
+				return
+			}
+		} else {
+			c := &fakeConsumer{config: req.Config, acked: make(map[int]bool)}
+			for i, msg := range f.messages {
+				if subjectMatches(c.config.FilterSubject, msg.subject) {
+					c.pending = append(c.pending, i+1)
+				}
+			}
+			f.consumers[req.Config.DurableName] = c
+		}
+		respond(map[string]any{"name": req.Config.DurableName})
+		f.pump()
+	case strings.HasPrefix(subject, "$JS.ACK."):
+		// $JS.ACK.<stream>.<durable>.<seq>
+		parts := strings.Split(subject, ".")
+		c := f.consumers[parts[3]]
+		seq, _ := strconv.Atoi(parts[4])
+		if string(payload) == "+ACK" {
+			c.acked[seq] = true
+		} else {
+			c.pending = append(c.pending, seq)
+			f.pump()
+		}
+	case f.stream != nil && matchesAny(f.stream.Subjects, subject):
+		f.messages = append(f.messages, jsMessage{subject: subject, payload: payload})
+		seq := len(f.messages)
+		for _, c := range f.consumers {
+			if subjectMatches(c.config.FilterSubject, subject) {
+				c.pending = append(c.pending, seq)
+			}
+		}
+		if reply != "" {
+			respond(pubAck{Stream: f.stream.Name, Seq: uint64(seq)})
+		}
+		f.pump()
+	case reply != "":
+		fmt.Fprintf(from.conn, "HMSG %s %s 16 16\r\nNATS/1.0 503\r\n\r\n\r\n", reply, from.sid(reply))
+	}
+}
+
+// pump delivers pending messages to a subscriber of each consumer's deliver subject
+func (f *fakeJetStream) pump() {
+	for durable, c := range f.consumers {
+		for len(c.pending) > 0 {
+			conn, sid := f.subscriber(c.config.DeliverSubject)
+			if conn == nil {
+				break
+			}
+			seq := c.pending[0]
+			c.pending = c.pending[1:]
+			msg := f.messages[seq-1]
+			ack := fmt.Sprintf("$JS.ACK.%s.%s.%d", f.stream.Name, durable, seq)
+			// An idle heartbeat precedes each message, as a reminder that status
+			// messages share the subscription
+			heartbeat := "NATS/1.0 100 Idle Heartbeat\r\n\r\n"
+			fmt.Fprintf(conn.conn, "HMSG %s %s %d %d\r\n%s\r\n", c.config.DeliverSubject, sid, len(heartbeat), len(heartbeat), heartbeat)
+			fmt.Fprintf(conn.conn, "MSG %s %s %s %d\r\n%s\r\n", msg.subject, sid, ack, len(msg.payload), msg.payload)
+		}
+	}
+}
+
+func (f *fakeJetStream) subscriber(subject string) (*fakeConn, string) {
+	for _, fc := range f.conns {
+		for sid, filter := range fc.subs {
+			if subjectMatches(filter, subject) {
+				return fc, sid
+			}
+		}
+	}
+	return nil, ""
+}
+
+func (fc *fakeConn) sid(subject string) string {
+	for sid, filter := range fc.subs {
+		if subjectMatches(filter, subject) {
+			return sid
+		}
+	}
+	return "0"
+}
+
+func matchesAny(filters []string, subject string) bool {
+	for _, filter := range filters {
+		if subjectMatches(filter, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+func subjectMatches(filter, subject string) bool {
+	f, s := strings.Split(filter, "."), strings.Split(subject, ".")
+	for i, token := range f {
+		if token == ">" {
+			return len(s) > i
+		}
+		if i >= len(s) || (token != "*" && token != s[i]) {
+			return false
+		}
+	}
+	return len(f) == len(s)
+}
+
+func connectJetStream(t *testing.T, server *fakeJetStream, opts ...JetStreamOption) *JetStream {
+	t.Helper()
+	js, err := NewJetStream(server.url(), "LIFESUPPORT", append([]JetStreamOption{WithJetStreamTimeout(2 * time.Second)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewJetStream() error = %v", err)
+	}
+	js.nc.backoff = 10 * time.Millisecond
+	if err := js.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { js.Close() })
+	return js
+}
+
+type received struct {
+	subject string
+	payload string
+}
+
+func TestJetStream(t *testing.T) {
+	server := startFakeJetStream(t)
+	ctx := context.Background()
+
+	js := connectJetStream(t, server, WithStreamSubjects("lifesupport.>"), WithStreamMaxAge(time.Hour))
+	if server.creates != 1 || server.stream.Name != "LIFESUPPORT" || server.stream.Subjects[0] != "lifesupport.>" {
+		t.Fatalf("Expected stream LIFESUPPORT on lifesupport.>, got %d creates of %+v", server.creates, server.stream)
+	}
+	if server.stream.MaxAge != time.Hour.Nanoseconds() {
+		t.Errorf("Expected max age of an hour, got %d", server.stream.MaxAge)
+	}
+	if subject := js.Subject("lifesupport", "ingest", "pump-3"); subject != "lifesupport.ingest.pump-3" {
+		t.Errorf("Expected lifesupport.ingest.pump-3, got %s", subject)
+	}
+
+	// Messages published before anyone subscribes are replayed to a new consumer
+	if err := js.Publish(ctx, "lifesupport.ingest.pump-3", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := js.Publish(ctx, "lifesupport.changes.device", []byte(`{}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := js.Publish(ctx, "elsewhere.ingest", []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "no responders") {
+		t.Errorf("Expected no responders outside the stream, got %v", err)
+	}
+
+	got := make(chan received, 10)
+	failed := false
+	sub, err := js.Subscribe(ctx, "lifesupport.ingest.>", "ingest", func(ctx context.Context, subject string, payload []byte) error {
+		if string(payload) == `{"n":2}` && !failed {
+			failed = true
+			return errors.New("database unavailable")
+		}
+		got <- received{subject, string(payload)}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := js.Publish(ctx, "lifesupport.ingest.pump-4", []byte(`{"n":2}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	for _, want := range []received{{"lifesupport.ingest.pump-3", `{"n":1}`}, {"lifesupport.ingest.pump-4", `{"n":2}`}} {
+		select {
+		case msg := <-got:
+			if msg != want {
+				t.Errorf("Expected %+v, got %+v", want, msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %+v", want)
+		}
+	}
+	if !failed {
+		t.Error("Expected the failed message to be redelivered")
+	}
+	waitFor(t, func() bool { return len(server.acked("ingest")) == 2 })
+
+	if err := sub.Unsubscribe(ctx); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if err := js.Publish(ctx, "lifesupport.ingest.pump-5", []byte(`{"n":3}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	// A restarted subscriber resumes after the messages its group acknowledged
+	other := connectJetStream(t, server)
+	if server.creates != 1 {
+		t.Errorf("Expected the existing stream to be reused, got %d creates", server.creates)
+	}
+	if _, err := other.Subscribe(ctx, "lifesupport.ingest.>", "ingest", func(ctx context.Context, subject string, payload []byte) error {
+		got <- received{subject, string(payload)}
+		return nil
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	select {
+	case msg := <-got:
+		if msg.payload != `{"n":3}` {
+			t.Errorf("Expected only the message published while away, got %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the message published while away")
+	}
+}
+
+func TestJetStream_Reconnect(t *testing.T) {
+	server := startFakeJetStream(t)
+	ctx := context.Background()
+	js := connectJetStream(t, server)
+
+	got := make(chan string, 10)
+	if _, err := js.Subscribe(ctx, "lifesupport.>", "worker", func(ctx context.Context, subject string, payload []byte) error {
+		got <- string(payload)
+		return nil
+	}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	server.drop()
+	waitFor(t, func() bool {
+		return js.Publish(ctx, "lifesupport.readings", []byte("after")) == nil
+	})
+	select {
+	case payload := <-got:
+		if payload != "after" {
+			t.Errorf("Expected after, got %s", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the subscription to be restored after reconnecting")
+	}
+}
+
+func TestJetStream_ConnectFailure(t *testing.T) {
+	server := startFakeJetStream(t)
+	server.mu.Lock()
+	server.denied = true
+	server.mu.Unlock()
+
+	js, err := NewJetStream(server.url(), "LIFESUPPORT", WithJetStreamTimeout(2*time.Second))
+	if err != nil {
+		t.Fatalf("NewJetStream() error = %v", err)
+	}
+	if err := js.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), "insufficient permissions") {
+		t.Fatalf("Expected the stream creation to fail, got %v", err)
+	}
+	// The connection isn't left open behind the failure
+	waitFor(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.conns) == 0
+	})
+	if err := js.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestNewJetStream_Invalid(t *testing.T) {
+	for _, tc := range []struct{ url, stream string }{
+		{"mqtt://localhost", "LIFESUPPORT"},
+		{"nats://localhost", "LIFE.SUPPORT"},
+		{"nats://localhost", ""},
+	} {
+		if _, err := NewJetStream(tc.url, tc.stream); err == nil {
+			t.Errorf("Expected an error for %s stream %q", tc.url, tc.stream)
+		}
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
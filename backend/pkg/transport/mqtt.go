@@ -0,0 +1,61 @@
+package transport
+
+import (
+	"context"
+	"strings"
+
+	"lifesupport/backend/pkg/supervise"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTT carries messages on an MQTT broker at QoS 1. Subjects are topics and may use MQTT
+// wildcards when subscribing. Durable names are ignored, so every subscriber receives
+// every message, and messages published while a subscriber is away are lost.
+type MQTT struct {
+	client mqtt.Client
+}
+
+func NewMQTT(client mqtt.Client) *MQTT {
+	return &MQTT{client: client}
+}
+
+func (m *MQTT) Publish(ctx context.Context, subject string, payload []byte) error {
+	return wait(ctx, m.client.Publish(subject, 1, false, payload))
+}
+
+func (m *MQTT) Subscribe(ctx context.Context, subject, durable string, handler Handler) (Subscription, error) {
+	handlerCtx := context.WithoutCancel(ctx)
+	token := m.client.Subscribe(subject, 1, func(_ mqtt.Client, msg mqtt.Message) {
+		// A panicking handler loses this message rather than the client
+		supervise.Call(handlerCtx, "transport.mqtt", func() error {
+			return handler(handlerCtx, msg.Topic(), msg.Payload())
+		})
+	})
+	if err := wait(ctx, token); err != nil {
+		return nil, err
+	}
+	return &mqttSubscription{client: m.client, topic: subject}, nil
+}
+
+func (m *MQTT) Subject(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+type mqttSubscription struct {
+	client mqtt.Client
+	topic  string
+}
+
+func (s *mqttSubscription) Unsubscribe(ctx context.Context) error {
+	return wait(ctx, s.client.Unsubscribe(s.topic))
+}
+
+func wait(ctx context.Context, t mqtt.Token) error {
+	select {
+	case <-t.Done():
+		return t.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
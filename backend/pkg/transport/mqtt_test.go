@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testmqtt "lifesupport/backend/pkg/testutil/mqtt"
+)
+
+func TestMQTT(t *testing.T) {
+	broker := testmqtt.NewBroker(t)
+	m := NewMQTT(broker.Client(t, "transport-test"))
+	ctx := context.Background()
+
+	if subject := m.Subject("lifesupport", "ingest", "gw-1"); subject != "lifesupport/ingest/gw-1" {
+		t.Errorf("Expected lifesupport/ingest/gw-1, got %s", subject)
+	}
+
+	got := make(chan received, 10)
+	sub, err := m.Subscribe(ctx, "lifesupport/ingest/#", "ingest", func(ctx context.Context, subject string, payload []byte) error {
+		got <- received{subject, string(payload)}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := m.Publish(ctx, "lifesupport/ingest/gw-1", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	select {
+	case msg := <-got:
+		if msg != (received{"lifesupport/ingest/gw-1", `{"n":1}`}) {
+			t.Errorf("Expected the published message, got %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for message")
+	}
+
+	if err := sub.Unsubscribe(ctx); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	broker.Publish("lifesupport/ingest/gw-2", []byte(`{"n":2}`))
+	select {
+	case msg := <-got:
+		t.Errorf("Expected no messages after unsubscribing, got %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
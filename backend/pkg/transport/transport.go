@@ -0,0 +1,31 @@
+// Package transport carries internal events, such as ingested readings, the change feed
+// and alert notifications, over either MQTT or NATS JetStream. JetStream keeps messages
+// in a stream, so subscribers that were down catch up when they return.
+package transport
+
+import (
+	"context"
+)
+
+// Handler processes one message. Returning an error asks for redelivery on transports
+// which support it; MQTT drops the message either way.
+type Handler func(ctx context.Context, subject string, payload []byte) error
+
+// Transport publishes and subscribes to messages by subject
+type Transport interface {
+	// Publish returns once the transport has accepted the message: a PUBACK from an MQTT
+	// broker, or the stream's acknowledgement on JetStream
+	Publish(ctx context.Context, subject string, payload []byte) error
+	// Subscribe delivers messages matching subject to handler one at a time. Subscribers
+	// sharing a durable name share its messages, and on JetStream the subscription
+	// outlives the process, so messages published meanwhile are delivered on return.
+	Subscribe(ctx context.Context, subject, durable string, handler Handler) (Subscription, error)
+	// Subject joins parts with the transport's separator, e.g. "/" for MQTT topics and
+	// "." for NATS subjects
+	Subject(parts ...string) string
+}
+
+// Subscription stops a Subscribe
+type Subscription interface {
+	Unsubscribe(ctx context.Context) error
+}
@@ -0,0 +1,259 @@
+// Package trend compares a sensor's readings across time windows and labelled experiments
+package trend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+)
+
+// SignificanceLevel is the p-value below which a change counts as significant
+const SignificanceLevel = 0.05
+
+// Window is a half-open time range [Start, End)
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Compare summarises deviceID/sensorID's readings in baseline and current, and tests
+// whether the mean changed. Invalid and synthetic readings are ignored.
+func Compare(ctx context.Context, store storer.Interface, deviceID, sensorID string, baseline, current Window) (*api.TrendComparison, error) {
+	cmp := &api.TrendComparison{DeviceID: deviceID, SensorID: sensorID, Direction: api.TrendFlat}
+
+	var samples [2][]float64
+	for i, w := range []Window{baseline, current} {
+		values, unit, err := windowValues(ctx, store, deviceID, sensorID, w)
+		if err != nil {
+			return nil, err
+		}
+		if cmp.Unit == "" {
+			cmp.Unit = unit
+		}
+		samples[i] = values
+	}
+	cmp.Baseline = summarise(baseline, samples[0])
+	cmp.Current = summarise(current, samples[1])
+
+	if cmp.Baseline.Mean == nil || cmp.Current.Mean == nil {
+		return cmp, nil
+	}
+	delta := *cmp.Current.Mean - *cmp.Baseline.Mean
+	cmp.MeanDelta = &delta
+	if *cmp.Baseline.Mean != 0 {
+		percent := 100 * delta / math.Abs(*cmp.Baseline.Mean)
+		cmp.MeanDeltaPercent = &percent
+	}
+
+	p, ok := welch(samples[0], samples[1])
+	if !ok {
+		return cmp, nil
+	}
+	cmp.PValue = &p
+	cmp.Significant = p < SignificanceLevel
+	switch {
+	case !cmp.Significant:
+	case delta > 0:
+		cmp.Direction = api.TrendUp
+	case delta < 0:
+		cmp.Direction = api.TrendDown
+	}
+	return cmp, nil
+}
+
+// CompareLabels summarises deviceID/sensorID's readings under each value of the label
+// key, such as each experiment. Overlapping windows with the same value are merged so no
+// reading counts twice. Invalid and synthetic readings are ignored.
+func CompareLabels(ctx context.Context, store storer.Interface, deviceID, sensorID, key string) (*api.LabelComparison, error) {
+	labels, err := store.ListReadingLabels(ctx, storer.ReadingLabelFilters{Key: key, DeviceID: deviceID, SensorID: sensorID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reading labels: %w", err)
+	}
+	var values []string
+	windows := make(map[string][]Window)
+	for _, l := range labels {
+		if _, ok := windows[l.Value]; !ok {
+			values = append(values, l.Value)
+		}
+		windows[l.Value] = append(windows[l.Value], Window{Start: l.Start, End: l.End})
+	}
+	sort.Strings(values)
+
+	cmp := &api.LabelComparison{DeviceID: deviceID, SensorID: sensorID, Key: key, Groups: []api.LabelGroup{}}
+	for _, value := range values {
+		merged := mergeWindows(windows[value])
+		var samples []float64
+		for _, w := range merged {
+			values, unit, err := windowValues(ctx, store, deviceID, sensorID, w)
+			if err != nil {
+				return nil, err
+			}
+			if cmp.Unit == "" {
+				cmp.Unit = unit
+			}
+			samples = append(samples, values...)
+		}
+		span := Window{Start: merged[0].Start, End: merged[len(merged)-1].End}
+		cmp.Groups = append(cmp.Groups, api.LabelGroup{Value: value, Windows: len(windows[value]), Stats: summarise(span, samples)})
+	}
+	return cmp, nil
+}
+
+// mergeWindows merges overlapping windows ordered by start
+func mergeWindows(windows []Window) []Window {
+	merged := []Window{windows[0]}
+	for _, w := range windows[1:] {
+		last := &merged[len(merged)-1]
+		if w.Start.After(last.End) {
+			merged = append(merged, w)
+			continue
+		}
+		if w.End.After(last.End) {
+			last.End = w.End
+		}
+	}
+	return merged
+}
+
+// windowValues returns the values of deviceID/sensorID's valid, measured readings in w,
+// and their unit
+func windowValues(ctx context.Context, store storer.Interface, deviceID, sensorID string, w Window) ([]float64, api.Unit, error) {
+	readings, err := store.GetSensorReadings(ctx, storer.SensorReadingFilters{
+		DeviceID:  deviceID,
+		SensorID:  sensorID,
+		StartTime: &w.Start,
+		EndTime:   &w.End,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get sensor readings: %w", err)
+	}
+	var values []float64
+	var unit api.Unit
+	for _, rec := range readings {
+		r := rec.Reading
+		if !r.Valid || r.Synthetic || !r.Timestamp.Before(w.End) {
+			continue
+		}
+		if unit == "" {
+			unit = r.Unit
+		}
+		values = append(values, r.Value)
+	}
+	return values, unit, nil
+}
+
+func summarise(w Window, values []float64) api.WindowStats {
+	stats := api.WindowStats{Start: w.Start, End: w.End, Count: len(values)}
+	if len(values) == 0 {
+		return stats
+	}
+	mean, variance := meanVariance(values)
+	lo, hi := values[0], values[0]
+	for _, v := range values[1:] {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	stddev := math.Sqrt(variance)
+	stats.Mean, stats.Min, stats.Max, stats.StdDev = &mean, &lo, &hi, &stddev
+	return stats
+}
+
+// meanVariance returns the mean and sample variance of values
+func meanVariance(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, sq / float64(len(values)-1)
+}
+
+// welch returns the two-sided p-value of Welch's t-test for a difference in means. It
+// reports false when either sample has fewer than two values.
+func welch(a, b []float64) (float64, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return 0, false
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	sa, sb := varA/float64(len(a)), varB/float64(len(b))
+	se := sa + sb
+	if se == 0 {
+		// Both windows are constant, so any difference is certain
+		if meanA == meanB {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (meanB - meanA) / math.Sqrt(se)
+	df := se * se / (sa*sa/float64(len(a)-1) + sb*sb/float64(len(b)-1))
+	// P(|T| > t) for Student's t with df degrees of freedom
+	return incompleteBeta(df/2, 0.5, df/(df+t*t)), true
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b), evaluated with
+// the continued fraction from Numerical Recipes
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a + b)
+	lb, _ := math.Lgamma(a)
+	lc, _ := math.Lgamma(b)
+	front := math.Exp(la - lb - lc + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+func betaFraction(a, b, x float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-12
+		tiny          = 1e-300
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		for _, num := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,145 @@
+package trend
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer/storertest"
+)
+
+func TestCompare(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:      "tank",
+		Driver:  api.DriverShelly,
+		Name:    "Tank",
+		Sensors: []*api.Sensor{{ID: "nitrate", Name: "Nitrate", SensorType: api.SensorTypeTemperature}},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	lastWeek := Window{Start: start, End: start.Add(7 * 24 * time.Hour)}
+	thisWeek := Window{Start: lastWeek.End, End: lastWeek.End.Add(7 * 24 * time.Hour)}
+	store.StoreSensorReadings(ctx, []*api.ReadingRecord{
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 5, Valid: true, Timestamp: start.Add(24 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 6, Valid: true, Timestamp: start.Add(48 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 5, Valid: true, Timestamp: start.Add(72 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 6, Valid: true, Timestamp: start.Add(96 * time.Hour)}},
+		// On the boundary, so it belongs to this week only
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 10, Valid: true, Timestamp: thisWeek.Start}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 11, Valid: true, Timestamp: thisWeek.Start.Add(24 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 10, Valid: true, Timestamp: thisWeek.Start.Add(48 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 11, Valid: true, Timestamp: thisWeek.Start.Add(72 * time.Hour)}},
+		// Ignored
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 99, Valid: false, Timestamp: thisWeek.Start.Add(96 * time.Hour)}},
+		{DeviceID: "tank", SensorID: "nitrate", Reading: api.SensorReading{Value: 99, Valid: true, Synthetic: true, Timestamp: thisWeek.Start.Add(96 * time.Hour)}},
+	})
+
+	cmp, err := Compare(ctx, store, "tank", "nitrate", lastWeek, thisWeek)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if cmp.Baseline.Count != 4 || cmp.Current.Count != 4 {
+		t.Fatalf("Expected 4 readings in each window, got %d and %d", cmp.Baseline.Count, cmp.Current.Count)
+	}
+	if *cmp.Baseline.Mean != 5.5 || *cmp.Current.Mean != 10.5 || *cmp.Current.Max != 11 {
+		t.Errorf("Expected means 5.5 and 10.5, got %v and %v", *cmp.Baseline.Mean, *cmp.Current.Mean)
+	}
+	if *cmp.MeanDelta != 5 || math.Abs(*cmp.MeanDeltaPercent-90.909) > 0.001 {
+		t.Errorf("Expected a delta of 5 (90.9%%), got %v (%v%%)", *cmp.MeanDelta, *cmp.MeanDeltaPercent)
+	}
+	if cmp.PValue == nil || *cmp.PValue >= 0.001 || !cmp.Significant || cmp.Direction != api.TrendUp {
+		t.Errorf("Expected a significant rise, got p=%v direction=%s", cmp.PValue, cmp.Direction)
+	}
+
+	empty := Window{Start: thisWeek.End, End: thisWeek.End.Add(time.Hour)}
+	cmp, err = Compare(ctx, store, "tank", "nitrate", thisWeek, empty)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if cmp.MeanDelta != nil || cmp.PValue != nil || cmp.Direction != api.TrendFlat {
+		t.Errorf("Expected no comparison against an empty window, got %+v", cmp)
+	}
+}
+
+func TestCompareLabels(t *testing.T) {
+	store := storertest.New(t)
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:      "tank",
+		Driver:  api.DriverShelly,
+		Name:    "Tank",
+		Sensors: []*api.Sensor{{ID: "growth", Name: "Growth", SensorType: api.SensorTypeTemperature}},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var recs []*api.ReadingRecord
+	for day, v := range []float64{4, 5, 6, 10, 12, 2} {
+		recs = append(recs, &api.ReadingRecord{DeviceID: "tank", SensorID: "growth",
+			Reading: api.SensorReading{Value: v, Valid: true, Timestamp: start.Add(time.Duration(day) * 24 * time.Hour)}})
+	}
+	store.StoreSensorReadings(ctx, recs)
+
+	day := func(n int) time.Time { return start.Add(time.Duration(n) * 24 * time.Hour) }
+	for _, label := range []*api.ReadingLabel{
+		{Key: "experiment", Value: "control", Start: day(0), End: day(2)},
+		// Overlaps the first control window, so day 1 counts once
+		{Key: "experiment", Value: "control", Start: day(1), End: day(3)},
+		{Key: "experiment", Value: "reduced-photoperiod", DeviceID: "tank", SensorID: "growth", Start: day(3), End: day(5)},
+		{Key: "experiment", Value: "other-sensor", DeviceID: "tank", SensorID: "nitrate", Start: day(5), End: day(6)},
+		{Key: "feeding", Value: "heavy", Start: day(0), End: day(6)},
+	} {
+		if err := store.CreateReadingLabel(ctx, label); err != nil {
+			t.Fatalf("CreateReadingLabel() error = %v", err)
+		}
+	}
+
+	cmp, err := CompareLabels(ctx, store, "tank", "growth", "experiment")
+	if err != nil {
+		t.Fatalf("CompareLabels() error = %v", err)
+	}
+	if len(cmp.Groups) != 2 || cmp.Groups[0].Value != "control" || cmp.Groups[1].Value != "reduced-photoperiod" {
+		t.Fatalf("Expected control and reduced-photoperiod groups, got %+v", cmp.Groups)
+	}
+	control, reduced := cmp.Groups[0], cmp.Groups[1]
+	if control.Windows != 2 || control.Stats.Count != 3 || *control.Stats.Mean != 5 || !control.Stats.End.Equal(day(3)) {
+		t.Errorf("Expected 3 control readings averaging 5 over 2 windows, got %+v", control)
+	}
+	if reduced.Windows != 1 || reduced.Stats.Count != 2 || *reduced.Stats.Mean != 11 {
+		t.Errorf("Expected 2 reduced-photoperiod readings averaging 11, got %+v", reduced)
+	}
+
+	cmp, err = CompareLabels(ctx, store, "tank", "growth", "unknown")
+	if err != nil {
+		t.Fatalf("CompareLabels() error = %v", err)
+	}
+	if len(cmp.Groups) != 0 {
+		t.Errorf("Expected no groups for an unused key, got %+v", cmp.Groups)
+	}
+}
+
+func TestWelch(t *testing.T) {
+	// t = 2.074 with 10.2 degrees of freedom
+	a := []float64{19.8, 20.4, 19.6, 17.8, 18.5, 18.9, 18.3, 18.9, 19.5, 22.0}
+	b := []float64{28.2, 26.6, 20.1, 23.3, 25.2, 22.1, 17.7, 27.6, 20.6, 13.7}
+	p, ok := welch(a, b)
+	if !ok {
+		t.Fatal("Expected a p-value")
+	}
+	if math.Abs(p-0.0643) > 0.0001 {
+		t.Errorf("Expected p ~ 0.0643, got %v", p)
+	}
+
+	if _, ok := welch([]float64{1}, b); ok {
+		t.Error("Expected no p-value for a single reading")
+	}
+}
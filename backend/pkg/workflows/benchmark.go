@@ -0,0 +1,105 @@
+package workflows
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"lifesupport/backend/pkg/benchmark"
+
+	"github.com/rs/zerolog"
+	"go.temporal.io/sdk/temporal"
+	temporalWorker "go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// BenchmarkExportScheduleID is the Temporal schedule running BenchmarkExportWorkflow
+const BenchmarkExportScheduleID = "benchmark-export"
+
+const defaultBenchmarkWindow = 24 * time.Hour
+
+func (w *WorkflowCtx) registerBenchmarkExportWorkflow(worker temporalWorker.Worker) {
+	worker.RegisterWorkflow(w.BenchmarkExportWorkflow)
+	worker.RegisterActivity(w.ExportBenchmarkDataset)
+}
+
+// BenchmarkExportParams configures a scheduled benchmark export
+type BenchmarkExportParams struct {
+	// Window is how far back from the start of the current hour the dataset reaches;
+	// defaults to 24 hours
+	Window time.Duration `json:"window,omitempty"`
+}
+
+// ExportBenchmarkDatasetParams selects the readings summarised by one export
+type ExportBenchmarkDatasetParams struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// BenchmarkExportResult describes a written dataset
+type BenchmarkExportResult struct {
+	Path   string `json:"path"`
+	Series int    `json:"series"`
+}
+
+// BenchmarkExportWorkflow writes an anonymized dataset of the readings of the last
+// Window, for sharing with community benchmarks. It is run by the
+// BenchmarkExportScheduleID schedule on workers that opted in.
+func (w *WorkflowCtx) BenchmarkExportWorkflow(ctx workflow.Context, params BenchmarkExportParams) (*BenchmarkExportResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	window := params.Window
+	if window <= 0 {
+		window = defaultBenchmarkWindow
+	}
+	end := workflow.Now(ctx).UTC().Truncate(time.Hour)
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		RetryPolicy:         w.retryPolicy("ExportBenchmarkDataset", nil),
+	})
+	var result *BenchmarkExportResult
+	err := workflow.ExecuteActivity(ctx, w.ExportBenchmarkDataset, ExportBenchmarkDatasetParams{
+		Start: end.Add(-window),
+		End:   end,
+	}).Get(ctx, &result)
+	if err != nil {
+		logger.Error("Benchmark export failed", "error", err)
+		return nil, err
+	}
+	logger.Info("Benchmark export completed", "path", result.Path, "series", result.Series)
+	return result, nil
+}
+
+// ExportBenchmarkDataset builds the anonymized dataset for a window and writes it to
+// the export bucket
+func (w *WorkflowCtx) ExportBenchmarkDataset(ctx context.Context, params ExportBenchmarkDatasetParams) (*BenchmarkExportResult, error) {
+	if w.benchmarkExports == nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			"benchmark export is not enabled on this worker", ErrTypeNotEnabled, nil)
+	}
+	logger := zerolog.Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		logger = &w.logger
+	}
+
+	dataset, err := benchmark.Build(ctx, w.storer, params.Start, params.End)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(dataset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode benchmark dataset: %w", err)
+	}
+
+	key := "benchmark-" + params.End.UTC().Format("20060102T1504Z") + ".json"
+	if err := w.benchmarkExports.Put(ctx, key, bytes.NewReader(b), "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to write benchmark dataset: %w", err)
+	}
+	path := w.benchmarkExports.Location(key)
+
+	logger.Info().Str("path", path).Int("series", len(dataset.Series)).Msg("Wrote benchmark dataset")
+	return &BenchmarkExportResult{Path: path, Series: len(dataset.Series)}, nil
+}
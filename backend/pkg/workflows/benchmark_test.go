@@ -0,0 +1,83 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/storer"
+
+	"github.com/rs/zerolog"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestBenchmarkExportWorkflow(t *testing.T) {
+	store := storer.NewMemory()
+	ctx := context.Background()
+	err := store.CreateDevice(ctx, &api.Device{
+		ID:       "sump",
+		Driver:   "gateway",
+		Name:     "Sump",
+		Metadata: map[string]string{api.MetadataSubsystemType: "aquarium"},
+		Sensors:  []*api.Sensor{{ID: "temp", DeviceID: "sump", Name: "Temperature", SensorType: api.SensorTypeTemperature}},
+	})
+	if err != nil {
+		t.Fatalf("CreateDevice() error = %v", err)
+	}
+	now := time.Date(2026, 1, 31, 10, 30, 0, 0, time.UTC)
+	store.StoreSensorReading(ctx, &api.ReadingRecord{DeviceID: "sump", SensorID: "temp", Reading: api.SensorReading{
+		Value: 25, Unit: api.UnitCelsius, Valid: true, Timestamp: now.Add(-2 * time.Hour),
+	}})
+
+	dir := t.TempDir()
+	w := New(zerolog.Nop(), store, nil, WithBenchmarkExportDir(dir))
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetStartTime(now)
+	env.RegisterWorkflow(w.BenchmarkExportWorkflow)
+	env.RegisterActivity(w.ExportBenchmarkDataset)
+
+	env.ExecuteWorkflow(w.BenchmarkExportWorkflow, BenchmarkExportParams{})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("Workflow error = %v", err)
+	}
+	var result BenchmarkExportResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("GetWorkflowResult() error = %v", err)
+	}
+	if result.Series != 1 {
+		t.Errorf("Expected 1 series, got %d", result.Series)
+	}
+
+	b, err := os.ReadFile(result.Path)
+	if err != nil {
+		t.Fatalf("Failed to read dataset: %v", err)
+	}
+	var dataset api.BenchmarkDataset
+	if err := json.Unmarshal(b, &dataset); err != nil {
+		t.Fatalf("Failed to decode dataset: %v", err)
+	}
+	wantEnd := time.Date(2026, 1, 31, 10, 0, 0, 0, time.UTC)
+	if !dataset.End.Equal(wantEnd) || !dataset.Start.Equal(wantEnd.Add(-24*time.Hour)) {
+		t.Errorf("Expected the 24 hours before %s, got %s to %s", wantEnd, dataset.Start, dataset.End)
+	}
+}
+
+func TestBenchmarkExportWorkflow_NotEnabled(t *testing.T) {
+	w := New(zerolog.Nop(), storer.NewMemory(), nil)
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(w.BenchmarkExportWorkflow)
+	env.RegisterActivity(w.ExportBenchmarkDataset)
+
+	env.ExecuteWorkflow(w.BenchmarkExportWorkflow, BenchmarkExportParams{})
+	var appErr *temporal.ApplicationError
+	if err := env.GetWorkflowError(); !errors.As(err, &appErr) || appErr.Type() != ErrTypeNotEnabled {
+		t.Errorf("Expected a %s error, got %v", ErrTypeNotEnabled, err)
+	}
+}
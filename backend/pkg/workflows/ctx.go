@@ -1,6 +1,8 @@
 package workflows
 
 import (
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/blob"
 	"lifesupport/backend/pkg/drivers/shelly"
 	"lifesupport/backend/pkg/storer"
 
@@ -10,20 +12,31 @@ import (
 
 type WorkflowCtx struct {
 	logger zerolog.Logger
-	storer *storer.Storer
+	storer storer.Interface
 
 	// drivers
 	shellyDriver *shelly.Driver
+
+	// activity retry policies keyed by activity name or DefaultRetryPolicyKey
+	retryPolicies map[string]api.RetryPolicy
+
+	// benchmarkExports receives anonymized benchmark datasets; nil when not opted in
+	benchmarkExports blob.Bucket
 }
 
-func New(logger zerolog.Logger, storer *storer.Storer, shellyDriver *shelly.Driver) *WorkflowCtx {
-	return &WorkflowCtx{
+func New(logger zerolog.Logger, storer storer.Interface, shellyDriver *shelly.Driver, opts ...Option) *WorkflowCtx {
+	w := &WorkflowCtx{
 		logger:       logger,
 		storer:       storer,
 		shellyDriver: shellyDriver,
 	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
 func (w *WorkflowCtx) Register(worker temporalWorker.Worker) {
 	w.registerDiscoveryWorkflow(worker)
+	w.registerBenchmarkExportWorkflow(worker)
 }
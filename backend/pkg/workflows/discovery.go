@@ -2,20 +2,31 @@ package workflows
 
 import (
 	"context"
+	"fmt"
 	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
 	"time"
 
 	"github.com/rs/zerolog"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 	temporalWorker "go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 )
 
 func (w *WorkflowCtx) registerDiscoveryWorkflow(worker temporalWorker.Worker) {
 	worker.RegisterWorkflow(w.DeviceDiscoveryWorkflow)
+	worker.RegisterActivity(w.DiscoverDevices)
+	// Kept for executions started before changeDiscoveryByDriver
 	worker.RegisterActivity(w.ShellyDiscovery)
 }
 
+// DiscoverDevicesParams selects the driver to run discovery with
+type DiscoverDevicesParams struct {
+	Driver  api.DriverName       `json:"driver"`
+	Options api.DiscoveryOptions `json:"options"`
+}
+
 type DiscoveryWorkflowResult struct {
 	// Add any fields needed for the discovery workflow result
 }
@@ -32,23 +43,52 @@ func (w *WorkflowCtx) DeviceDiscoveryWorkflow(ctx workflow.Context, params api.D
 		"TaskQueue", info.TaskQueueName,
 	)
 
-	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Second * 30,
-	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
-
 	var result *api.DiscoveryResult
-	err := workflow.ExecuteActivity(ctx, w.ShellyDiscovery, params).Get(ctx, &result)
+	var err error
+	if v := workflow.GetVersion(ctx, changeDiscoveryByDriver, workflow.DefaultVersion, 1); v == workflow.DefaultVersion {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Second * 30,
+			RetryPolicy:         w.retryPolicy("ShellyDiscovery", params.Retry),
+		})
+		err = workflow.ExecuteActivity(ctx, w.ShellyDiscovery, params).Get(ctx, &result)
+	} else {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Second * 30,
+			RetryPolicy:         w.retryPolicy("DiscoverDevices", params.Retry),
+		})
+		err = workflow.ExecuteActivity(ctx, w.DiscoverDevices, DiscoverDevicesParams{
+			Driver:  api.DriverShelly,
+			Options: params,
+		}).Get(ctx, &result)
+	}
 	if err != nil {
 		logger.Error("Device discovery activity failed", "error", err)
 		return nil, err
 	}
 
-	logger.Info("Device discovery workflow completed", "tagsFound", len(result.DiscoveredTags))
+	logger.Info("Device discovery workflow completed", "tagsFound", len(result.DiscoveredTags), "errors", len(result.Errors))
 	return &DiscoveryWorkflowResult{}, nil
 }
 
+// DiscoverDevices runs discovery with the requested driver
+func (w *WorkflowCtx) DiscoverDevices(ctx context.Context, params DiscoverDevicesParams) (*api.DiscoveryResult, error) {
+	var driver drivers.Driver
+	switch params.Driver {
+	case api.DriverShelly:
+		driver = w.shellyDriver
+	default:
+		return nil, temporal.NewApplicationError(
+			fmt.Sprintf("unsupported discovery driver %q", params.Driver), ErrTypeUnsupportedDriver)
+	}
+	return w.discover(ctx, string(params.Driver), driver, params.Options)
+}
+
+// ShellyDiscovery runs discovery with the shelly driver. Superseded by DiscoverDevices.
 func (w *WorkflowCtx) ShellyDiscovery(ctx context.Context, params api.DiscoveryOptions) (*api.DiscoveryResult, error) {
+	return w.discover(ctx, string(api.DriverShelly), w.shellyDriver, params)
+}
+
+func (w *WorkflowCtx) discover(ctx context.Context, driverName string, driver drivers.Driver, params api.DiscoveryOptions) (*api.DiscoveryResult, error) {
 	// Extract activity info and create structured logger
 	info := activity.GetInfo(ctx)
 
@@ -67,19 +107,27 @@ func (w *WorkflowCtx) ShellyDiscovery(ctx context.Context, params api.DiscoveryO
 		Str("ActivityType", info.ActivityType.Name).
 		Str("TaskQueue", info.TaskQueue).
 		Int32("Attempt", info.Attempt).
+		Str("driver", driverName).
 		Logger()
 
-	activityLogger.Info().Msg("Starting Shelly device discovery")
+	activityLogger.Info().Msg("Starting device discovery")
 
-	result, err := w.shellyDriver.DiscoverDevices(activityLogger.WithContext(ctx), params, w.storer)
+	result, err := driver.DiscoverDevices(activityLogger.WithContext(ctx), params, w.storer)
 	if err != nil {
-		activityLogger.Error().Err(err).Msg("Shelly device discovery failed")
-		return nil, err
+		activityLogger.Error().Err(err).Msg("Device discovery failed")
+		return nil, activityError(err)
 	}
 
+	for _, failed := range result.Errors {
+		activityLogger.Warn().
+			Str("device_id", failed.DeviceID).
+			Str("error", failed.Error).
+			Msg("Discovered device could not be added")
+	}
 	activityLogger.Info().
 		Int("tagsFound", len(result.DiscoveredTags)).
-		Msg("Shelly device discovery completed")
+		Int("errors", len(result.Errors)).
+		Msg("Device discovery completed")
 
 	return result, nil
 }
@@ -0,0 +1,30 @@
+package workflows
+
+import (
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/blob"
+)
+
+type Option func(*WorkflowCtx)
+
+// WithRetryPolicies sets activity retry policies keyed by activity name, with
+// DefaultRetryPolicyKey applying to every activity. Each overrides the built-in default
+// field by field.
+func WithRetryPolicies(policies map[string]api.RetryPolicy) Option {
+	return func(w *WorkflowCtx) {
+		w.retryPolicies = policies
+	}
+}
+
+// WithBenchmarkExports opts in to anonymized benchmark exports, written to bucket by
+// the BenchmarkExportWorkflow. Without it the export activity refuses to run.
+func WithBenchmarkExports(bucket blob.Bucket) Option {
+	return func(w *WorkflowCtx) {
+		w.benchmarkExports = bucket
+	}
+}
+
+// WithBenchmarkExportDir opts in to anonymized benchmark exports written to dir
+func WithBenchmarkExportDir(dir string) Option {
+	return WithBenchmarkExports(blob.NewFilesystem(dir))
+}
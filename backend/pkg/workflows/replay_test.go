@@ -0,0 +1,54 @@
+package workflows
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+var replayLogger = log.NewStructuredLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+// TestReplay replays every captured history in testdata against the current workflow
+// code, catching changes that would fail in-flight executions with non-determinism
+// errors. Capture a new history with:
+//
+//	temporal workflow show --workflow-id <id> --output json > testdata/<name>.json
+func TestReplay(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.json")
+	if err != nil {
+		t.Fatalf("Failed to list histories: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected captured histories in testdata")
+	}
+
+	w := New(zerolog.Nop(), nil, nil)
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(w.DeviceDiscoveryWorkflow)
+			if err := replayer.ReplayWorkflowHistoryFromJSONFile(replayLogger, file); err != nil {
+				t.Errorf("Replay of %s failed: %v", file, err)
+			}
+		})
+	}
+}
+
+// TestReplay_DetectsNonDeterminism guards the replay test itself: a workflow issuing
+// different commands than the history must fail to replay
+func TestReplay_DetectsNonDeterminism(t *testing.T) {
+	changed := func(ctx workflow.Context) error {
+		return workflow.Sleep(ctx, 0)
+	}
+	replayer := worker.NewWorkflowReplayer()
+	replayer.RegisterWorkflowWithOptions(changed, workflow.RegisterOptions{Name: "DeviceDiscoveryWorkflow"})
+	if err := replayer.ReplayWorkflowHistoryFromJSONFile(replayLogger, "testdata/discovery_v0.json"); err == nil {
+		t.Error("Expected replay of a changed workflow to fail")
+	}
+}
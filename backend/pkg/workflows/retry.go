@@ -0,0 +1,116 @@
+package workflows
+
+import (
+	"errors"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Activity error types. Activities report failures with these types so retry policies
+// can name the ones not worth retrying.
+const (
+	// ErrTypeUnsupportedDriver is returned for a driver the worker does not run
+	ErrTypeUnsupportedDriver = "UnsupportedDriver"
+	// ErrTypeDeviceRejected is returned when a device refused a request
+	ErrTypeDeviceRejected = "DeviceRejected"
+	// ErrTypeDeviceAuth is returned when a device refused our credentials
+	ErrTypeDeviceAuth = "DeviceAuth"
+	// ErrTypeUnsupported is returned for a resource or action a device cannot handle
+	ErrTypeUnsupported = "Unsupported"
+	// ErrTypeDeviceOffline is returned when a device is known to be disconnected
+	ErrTypeDeviceOffline = "DeviceOffline"
+	// ErrTypeDeviceTimeout is returned when a device did not answer in time
+	ErrTypeDeviceTimeout = "DeviceTimeout"
+	// ErrTypeNotEnabled is returned by activities for features the worker has not
+	// opted in to
+	ErrTypeNotEnabled = "NotEnabled"
+)
+
+// driverErrorTypes maps driver errors to activity error types, most specific first
+var driverErrorTypes = []struct {
+	err     error
+	errType string
+}{
+	{drivers.ErrAuth, ErrTypeDeviceAuth},
+	{drivers.ErrUnsupported, ErrTypeUnsupported},
+	{drivers.ErrRejected, ErrTypeDeviceRejected},
+	{drivers.ErrDeviceOffline, ErrTypeDeviceOffline},
+	{drivers.ErrTimeout, ErrTypeDeviceTimeout},
+}
+
+// DefaultRetryPolicyKey is the retry policy key applied to every activity without a
+// policy of its own
+const DefaultRetryPolicyKey = "default"
+
+// defaultRetryPolicy bounds retries so permanent hardware-side failures surface instead
+// of retrying forever
+var defaultRetryPolicy = api.RetryPolicy{
+	InitialInterval:        time.Second,
+	BackoffCoefficient:     2,
+	MaximumInterval:        time.Minute,
+	MaximumAttempts:        5,
+	NonRetryableErrorTypes: []string{ErrTypeUnsupportedDriver, ErrTypeDeviceRejected, ErrTypeDeviceAuth, ErrTypeUnsupported},
+}
+
+// mergeRetryPolicy returns base with the non-zero fields of override applied
+func mergeRetryPolicy(base api.RetryPolicy, override *api.RetryPolicy) api.RetryPolicy {
+	if override == nil {
+		return base
+	}
+	if override.InitialInterval > 0 {
+		base.InitialInterval = override.InitialInterval
+	}
+	if override.BackoffCoefficient > 0 {
+		base.BackoffCoefficient = override.BackoffCoefficient
+	}
+	if override.MaximumInterval > 0 {
+		base.MaximumInterval = override.MaximumInterval
+	}
+	if override.MaximumAttempts != 0 {
+		base.MaximumAttempts = override.MaximumAttempts
+	}
+	if override.NonRetryableErrorTypes != nil {
+		base.NonRetryableErrorTypes = override.NonRetryableErrorTypes
+	}
+	return base
+}
+
+// retryPolicy resolves the policy for an activity: the built-in default, then the
+// worker's configured default and per-activity policy, then the caller's override
+func (w *WorkflowCtx) retryPolicy(activity string, override *api.RetryPolicy) *temporal.RetryPolicy {
+	p := defaultRetryPolicy
+	if configured, ok := w.retryPolicies[DefaultRetryPolicyKey]; ok {
+		p = mergeRetryPolicy(p, &configured)
+	}
+	if configured, ok := w.retryPolicies[activity]; ok {
+		p = mergeRetryPolicy(p, &configured)
+	}
+	p = mergeRetryPolicy(p, override)
+
+	maxAttempts := p.MaximumAttempts
+	if maxAttempts < 0 {
+		maxAttempts = 0 // unlimited
+	}
+	return &temporal.RetryPolicy{
+		InitialInterval:        p.InitialInterval,
+		BackoffCoefficient:     p.BackoffCoefficient,
+		MaximumInterval:        p.MaximumInterval,
+		MaximumAttempts:        maxAttempts,
+		NonRetryableErrorTypes: p.NonRetryableErrorTypes,
+	}
+}
+
+// activityError tags err with an error type retry policies can match. Offline and
+// timed out devices stay retryable by default; they often come back.
+func activityError(err error) error {
+	for _, m := range driverErrorTypes {
+		if errors.Is(err, m.err) {
+			return temporal.NewApplicationErrorWithCause(err.Error(), m.errType, err)
+		}
+	}
+	return err
+}
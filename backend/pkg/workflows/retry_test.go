@@ -0,0 +1,126 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"lifesupport/backend/pkg/api"
+	"lifesupport/backend/pkg/drivers"
+
+	"github.com/rs/zerolog"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRetryPolicy_Merge(t *testing.T) {
+	w := New(zerolog.Nop(), nil, nil, WithRetryPolicies(map[string]api.RetryPolicy{
+		DefaultRetryPolicyKey: {MaximumAttempts: 3},
+		"DiscoverDevices":     {InitialInterval: 5 * time.Second},
+	}))
+
+	p := w.retryPolicy("DiscoverDevices", nil)
+	if p.MaximumAttempts != 3 || p.InitialInterval != 5*time.Second || p.BackoffCoefficient != 2 {
+		t.Errorf("Expected configured policies layered over defaults, got %+v", p)
+	}
+	if len(p.NonRetryableErrorTypes) != 4 {
+		t.Errorf("Expected default non-retryable types, got %v", p.NonRetryableErrorTypes)
+	}
+
+	p = w.retryPolicy("DiscoverDevices", &api.RetryPolicy{MaximumAttempts: -1, NonRetryableErrorTypes: []string{}})
+	if p.MaximumAttempts != 0 {
+		t.Errorf("Expected negative attempts to mean unlimited, got %d", p.MaximumAttempts)
+	}
+	if len(p.NonRetryableErrorTypes) != 0 {
+		t.Errorf("Expected override to clear non-retryable types, got %v", p.NonRetryableErrorTypes)
+	}
+}
+
+// runDiscovery runs the discovery workflow with a fake DiscoverDevices activity that
+// fails with err and returns how many attempts were made
+func runDiscovery(t *testing.T, w *WorkflowCtx, opts api.DiscoveryOptions, err error) int {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	attempts := 0
+	env.RegisterWorkflow(w.DeviceDiscoveryWorkflow)
+	env.RegisterActivityWithOptions(func(ctx context.Context, params DiscoverDevicesParams) (*api.DiscoveryResult, error) {
+		attempts++
+		return nil, activityError(err)
+	}, activity.RegisterOptions{Name: "DiscoverDevices"})
+
+	env.ExecuteWorkflow(w.DeviceDiscoveryWorkflow, opts)
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("Expected workflow to complete")
+	}
+	if env.GetWorkflowError() == nil {
+		t.Fatal("Expected workflow to fail")
+	}
+	return attempts
+}
+
+func TestDiscoveryWorkflow_RetriesTransientErrors(t *testing.T) {
+	w := New(zerolog.Nop(), nil, nil)
+	if attempts := runDiscovery(t, w, api.DiscoveryOptions{}, errors.New("mqtt timeout")); attempts != 5 {
+		t.Errorf("Expected 5 attempts, got %d", attempts)
+	}
+}
+
+func TestDiscoveryWorkflow_DoesNotRetryRejections(t *testing.T) {
+	w := New(zerolog.Nop(), nil, nil)
+	rejected := fmt.Errorf("querying config: %w", drivers.ErrRejected)
+	if attempts := runDiscovery(t, w, api.DiscoveryOptions{}, rejected); attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestDiscoveryWorkflow_RequestOverride(t *testing.T) {
+	w := New(zerolog.Nop(), nil, nil, WithRetryPolicies(map[string]api.RetryPolicy{
+		"DiscoverDevices": {MaximumAttempts: 4},
+	}))
+	opts := api.DiscoveryOptions{Retry: &api.RetryPolicy{MaximumAttempts: 2}}
+	if attempts := runDiscovery(t, w, opts, errors.New("mqtt timeout")); attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestActivityError_Types(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{drivers.ErrAuth, ErrTypeDeviceAuth},
+		{drivers.ErrUnsupported, ErrTypeUnsupported},
+		{drivers.ErrRejected, ErrTypeDeviceRejected},
+		{drivers.ErrDeviceOffline, ErrTypeDeviceOffline},
+		{drivers.ErrTimeout, ErrTypeDeviceTimeout},
+	}
+	for _, tt := range tests {
+		err := activityError(fmt.Errorf("sending Switch.Set: %w", tt.err))
+		var appErr *temporal.ApplicationError
+		if !errors.As(err, &appErr) {
+			t.Errorf("Expected application error for %v, got %T", tt.err, err)
+			continue
+		}
+		if appErr.Type() != tt.want {
+			t.Errorf("Expected type %s for %v, got %s", tt.want, tt.err, appErr.Type())
+		}
+	}
+
+	plain := errors.New("boom")
+	if err := activityError(plain); err != plain {
+		t.Errorf("Expected untyped errors to pass through, got %v", err)
+	}
+}
+
+func TestDiscoveryWorkflow_RetriesOfflineDevices(t *testing.T) {
+	w := New(zerolog.Nop(), nil, nil)
+	offline := fmt.Errorf("shellyplus1pm-a: %w", drivers.ErrDeviceOffline)
+	if attempts := runDiscovery(t, w, api.DiscoveryOptions{}, offline); attempts != 5 {
+		t.Errorf("Expected 5 attempts, got %d", attempts)
+	}
+}
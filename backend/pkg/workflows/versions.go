@@ -0,0 +1,23 @@
+package workflows
+
+// Change IDs for workflow.GetVersion gates.
+//
+// Workflow code must replay identically against the history of every execution still
+// in flight. Any change to the sequence of commands a workflow issues (activities,
+// timers, child workflows, signals) must be gated:
+//
+//	v := workflow.GetVersion(ctx, changeFoo, workflow.DefaultVersion, 1)
+//	if v == workflow.DefaultVersion {
+//		// old behaviour, kept until no execution started before the change remains
+//	} else {
+//		// new behaviour
+//	}
+//
+// Add a history for the new version under testdata/ and keep the old ones, so
+// TestReplay proves both paths still replay. Once every pre-change execution has
+// closed, raise minSupported and delete the old branch and its history together.
+const (
+	// changeDiscoveryByDriver replaces the ShellyDiscovery activity with the
+	// driver-agnostic DiscoverDevices activity.
+	changeDiscoveryByDriver = "discovery-by-driver"
+)
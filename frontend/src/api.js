@@ -226,3 +226,111 @@ export const workflowAPI = {
     return request('/workflows');
   },
 };
+
+// Asset API for device and subsystem photos and diagrams
+export const assetAPI = {
+  async listForDevice(deviceId) {
+    return request(`/devices/${deviceId}/assets`);
+  },
+
+  async listForSubsystem(subsystem) {
+    return request(`/subsystems/${subsystem}/assets`);
+  },
+
+  // Uploads go as multipart form data, so the browser must set the Content-Type
+  async uploadForDevice(deviceId, file, kind = 'photo') {
+    return upload(`/devices/${deviceId}/assets`, file, kind);
+  },
+
+  async uploadForSubsystem(subsystem, file, kind = 'photo') {
+    return upload(`/subsystems/${subsystem}/assets`, file, kind);
+  },
+
+  contentURL(assetId) {
+    return `${API_BASE_URL}/assets/${assetId}/content`;
+  },
+
+  thumbnailURL(assetId) {
+    return `${API_BASE_URL}/assets/${assetId}/thumbnail`;
+  },
+
+  async delete(assetId) {
+    return request(`/assets/${assetId}`, {
+      method: 'DELETE',
+    });
+  },
+};
+
+// Maintenance API; while read_only is set every change is rejected
+export const maintenanceAPI = {
+  async get() {
+    return request('/maintenance');
+  },
+};
+
+async function upload(url, file, kind) {
+  const form = new FormData();
+  form.append('kind', kind);
+  form.append('file', file);
+  const response = await fetch(`${API_BASE_URL}${url}`, {
+    method: 'POST',
+    body: form,
+  });
+  if (!response.ok) {
+    const errorText = await response.text();
+    throw new Error(`API Error: ${response.status} - ${errorText}`);
+  }
+  return response.json();
+}
+
+// Session WebSocket for actuator commands. command() resolves with the state the
+// hardware reported once confirmed, calling onProgress at each stage
+// (queued -> sent -> confirmed), and rejects if the command fails.
+export function openSession(user = '') {
+  const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+  const query = user ? `?user=${encodeURIComponent(user)}` : '';
+  const ws = new WebSocket(`${protocol}//${window.location.host}${API_BASE_URL}/session${query}`);
+  const ready = new Promise((resolve, reject) => {
+    ws.addEventListener('open', resolve, { once: true });
+    ws.addEventListener('error', reject, { once: true });
+  });
+  const pending = new Map();
+  let nextId = 1;
+
+  ws.addEventListener('message', (event) => {
+    const progress = JSON.parse(event.data);
+    const entry = pending.get(progress.id);
+    if (!entry) {
+      return;
+    }
+    entry.onProgress?.(progress);
+    if (progress.stage === 'confirmed') {
+      pending.delete(progress.id);
+      entry.resolve(progress.state);
+    } else if (progress.stage === 'failed') {
+      pending.delete(progress.id);
+      entry.reject(new Error(progress.error));
+    }
+  });
+  ws.addEventListener('close', () => {
+    for (const entry of pending.values()) {
+      entry.reject(new Error('Session closed'));
+    }
+    pending.clear();
+  });
+
+  return {
+    async command(tag, command, onProgress) {
+      await ready;
+      const id = String(nextId++);
+      return new Promise((resolve, reject) => {
+        pending.set(id, { resolve, reject, onProgress });
+        ws.send(JSON.stringify({ type: 'command', id, tag, command }));
+      });
+    },
+
+    close() {
+      ws.close();
+    },
+  };
+}